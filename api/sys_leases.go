@@ -3,6 +3,9 @@ package api
 import (
 	"context"
 	"errors"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
 )
 
 func (c *Sys) Renew(id string, increment int) (*Secret, error) {
@@ -103,3 +106,108 @@ type RevokeOptions struct {
 	Prefix  bool
 	Sync    bool
 }
+
+// LeaseFilter selects leases. Empty fields match all the leases.
+type LeaseFilter struct {
+	Prefix        string
+	Mount         string
+	Role          string
+	TokenAccessor string
+}
+
+func (f *LeaseFilter) body() map[string]interface{} {
+	body := map[string]interface{}{}
+	if f == nil {
+		return body
+	}
+	if f.Prefix != "" {
+		body["prefix"] = f.Prefix
+	}
+	if f.Mount != "" {
+		body["mount"] = f.Mount
+	}
+	if f.Role != "" {
+		body["role"] = f.Role
+	}
+	if f.TokenAccessor != "" {
+		body["token_accessor"] = f.TokenAccessor
+	}
+	return body
+}
+
+type LeaseInfo struct {
+	ID            string    `json:"id" mapstructure:"id"`
+	IssueTime     time.Time `json:"issue_time" mapstructure:"issue_time"`
+	ExpireTime    time.Time `json:"expire_time" mapstructure:"expire_time"`
+	LastRenewal   time.Time `json:"last_renewal" mapstructure:"last_renewal"`
+	TTL           int       `json:"ttl" mapstructure:"ttl"`
+	Renewable     bool      `json:"renewable" mapstructure:"renewable"`
+	TokenAccessor string    `json:"token_accessor" mapstructure:"token_accessor"`
+	Mount         string    `json:"mount" mapstructure:"mount"`
+	Role          string    `json:"role" mapstructure:"role"`
+}
+
+type LeaseCountOutput struct {
+	Counts map[string]int `json:"counts" mapstructure:"counts"`
+	Total  int            `json:"total" mapstructure:"total"`
+}
+
+func (c *Sys) ExportLeases(filter *LeaseFilter) ([]*LeaseInfo, error) {
+	secret, err := c.leaseFilterRequest("/v1/sys/leases/export", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Leases []*LeaseInfo `mapstructure:"leases"`
+	}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeHookFunc(time.RFC3339Nano),
+		WeaklyTypedInput: true,
+		Result:           &result,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := decoder.Decode(secret.Data); err != nil {
+		return nil, err
+	}
+	return result.Leases, nil
+}
+
+func (c *Sys) CountLeases(filter *LeaseFilter) (*LeaseCountOutput, error) {
+	secret, err := c.leaseFilterRequest("/v1/sys/leases/count", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var result LeaseCountOutput
+	if err := mapstructure.WeakDecode(secret.Data, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Sys) leaseFilterRequest(path string, filter *LeaseFilter) (*Secret, error) {
+	r := c.c.NewRequest("PUT", path)
+	if err := r.SetJSONBody(filter.body()); err != nil {
+		return nil, err
+	}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+	resp, err := c.c.RawRequestWithContext(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	secret, err := ParseSecret(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("data from server response is empty")
+	}
+	return secret, nil
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// RaftJoinResponse represents the response of the raft join API
+type RaftJoinResponse struct {
+	Joined bool `json:"joined"`
+}
+
+// RaftJoinRequest represents the parameters consumed by the raft join API
+type RaftJoinRequest struct {
+	LeaderAPIAddr    string `json:"leader_api_addr"`
+	LeaderCACert     string `json:"leader_ca_cert"`
+	LeaderClientCert string `json:"leader_client_cert"`
+	LeaderClientKey  string `json:"leader_client_key"`
+}
+
+// RaftServer represents a single member of the raft cluster
+type RaftServer struct {
+	NodeID  string `json:"node_id" mapstructure:"node_id"`
+	Address string `json:"address" mapstructure:"address"`
+	Leader  bool   `json:"leader" mapstructure:"leader"`
+	Voter   bool   `json:"voter" mapstructure:"voter"`
+}
+
+// RaftConfigurationResponse represents the members of the raft cluster
+type RaftConfigurationResponse struct {
+	Servers []*RaftServer `json:"servers" mapstructure:"servers"`
+}
+
+// RaftJoin adds the node from which this call is invoked from to the raft
+// cluster represented by the leader address in the parameter.
+func (c *Sys) RaftJoin(opts *RaftJoinRequest) (*RaftJoinResponse, error) {
+	r := c.c.NewRequest("POST", "/v1/sys/storage/raft/join")
+
+	if err := r.SetJSONBody(opts); err != nil {
+		return nil, err
+	}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+	resp, err := c.c.RawRequestWithContext(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result RaftJoinResponse
+	err = resp.DecodeJSON(&result)
+	return &result, err
+}
+
+// RaftConfiguration returns the members of the raft cluster.
+func (c *Sys) RaftConfiguration() (*RaftConfigurationResponse, error) {
+	r := c.c.NewRequest("GET", "/v1/sys/storage/raft/configuration")
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+	resp, err := c.c.RawRequestWithContext(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	secret, err := ParseSecret(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("data from server response is empty")
+	}
+
+	var result RaftConfigurationResponse
+	err = mapstructure.Decode(secret.Data, &result)
+	return &result, err
+}
+
+// RaftRemovePeer removes the node with the given ID from the raft cluster.
+func (c *Sys) RaftRemovePeer(serverID string) error {
+	r := c.c.NewRequest("PUT", "/v1/sys/storage/raft/remove-peer")
+
+	body := map[string]interface{}{
+		"server_id": serverID,
+	}
+	if err := r.SetJSONBody(body); err != nil {
+		return err
+	}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+	resp, err := c.c.RawRequestWithContext(ctx, r)
+	if err == nil {
+		defer resp.Body.Close()
+	}
+	return err
+}
+
+// RaftSnapshot invokes the API that takes the snapshot of the raft cluster
+// and writes it to the supplied io.Writer.
+func (c *Sys) RaftSnapshot(snapWriter io.Writer) error {
+	r := c.c.NewRequest("GET", "/v1/sys/storage/raft/snapshot")
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+	resp, err := c.c.RawRequestWithContext(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(snapWriter, resp.Body)
+	return err
+}
+
+// RaftSnapshotRestore reads the snapshot from the io.Reader and installs it
+// into the raft cluster.
+func (c *Sys) RaftSnapshotRestore(snapReader io.Reader) error {
+	snapshot, err := ioutil.ReadAll(snapReader)
+	if err != nil {
+		return err
+	}
+
+	r := c.c.NewRequest("PUT", "/v1/sys/storage/raft/snapshot")
+
+	body := map[string]interface{}{
+		"snapshot": base64.StdEncoding.EncodeToString(snapshot),
+	}
+	if err := r.SetJSONBody(body); err != nil {
+		return err
+	}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+	resp, err := c.c.RawRequestWithContext(ctx, r)
+	if err == nil {
+		defer resp.Body.Close()
+	}
+	return err
+}
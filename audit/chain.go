@@ -0,0 +1,129 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// AuditChain links an entry of a hash chained audit log to the entry before
+// it. Only the first entry written by an audit device has sequence 0 and no
+// previous entry.
+type AuditChain struct {
+	Sequence uint64 `json:"sequence"`
+
+	// Previous is the HMAC of the previous entry, computed with the salt of
+	// the audit device
+	Previous string `json:"previous"`
+}
+
+// AuditCheckpointEntry is written periodically to a hash chained audit log.
+// Its signature is the HMAC of its time and chain link, so that a checkpoint
+// vouches for the entries before it even when they are verified on their own.
+type AuditCheckpointEntry struct {
+	Time      string      `json:"time,omitempty"`
+	Type      string      `json:"type"`
+	Chain     *AuditChain `json:"chain"`
+	Signature string      `json:"signature"`
+}
+
+// CheckpointSignatureInput returns the data whose HMAC is the signature of a
+// checkpoint
+func CheckpointSignatureInput(time string, chain *AuditChain) string {
+	return fmt.Sprintf("checkpoint:%s:%d:%s", time, chain.Sequence, chain.Previous)
+}
+
+// ChainVerifier checks the links of a hash chained audit log one line at a
+// time, in the order the lines were written.
+type ChainVerifier struct {
+	// HashFunc returns the HMAC of the given data with the salt of the audit
+	// device that wrote the log
+	HashFunc func(string) (string, error)
+
+	// Entries is the number of chained entries verified so far, including
+	// checkpoints
+	Entries int
+
+	// Checkpoints is the number of checkpoints verified so far
+	Checkpoints int
+
+	// Unchained is the number of entries before the first chained entry
+	Unchained int
+
+	started  bool
+	sequence uint64
+	previous string
+}
+
+// Verify checks the next line of the log. The line must not include the
+// trailing newline.
+func (v *ChainVerifier) Verify(line []byte) error {
+	var entry struct {
+		Type      string      `json:"type"`
+		Time      string      `json:"time"`
+		Chain     *AuditChain `json:"chain"`
+		Signature string      `json:"signature"`
+	}
+
+	// Skip the prefix of the audit device, if any
+	start := bytes.IndexByte(line, '{')
+	if start < 0 {
+		return fmt.Errorf("entry is not a JSON object")
+	}
+	if err := json.Unmarshal(line[start:], &entry); err != nil {
+		return fmt.Errorf("failed to parse entry: %v", err)
+	}
+
+	if entry.Chain == nil {
+		if v.started {
+			return fmt.Errorf("entry is not chained")
+		}
+		v.Unchained++
+		return nil
+	}
+
+	switch {
+	case entry.Chain.Sequence == 0:
+		// Restarts and rotations continue the chain, so a new chain can only
+		// start the log
+		if v.started {
+			return fmt.Errorf("entry starts a new chain instead of linking to entry %d", v.sequence)
+		}
+		if entry.Chain.Previous != "" {
+			return fmt.Errorf("first entry of a chain links to a previous entry")
+		}
+
+	case !v.started:
+		// The log starts in the middle of a chain, such as when the oldest
+		// rotated files have been removed
+
+	default:
+		if entry.Chain.Sequence != v.sequence+1 {
+			return fmt.Errorf("expected sequence %d, got %d", v.sequence+1, entry.Chain.Sequence)
+		}
+		hash, err := v.HashFunc(v.previous)
+		if err != nil {
+			return err
+		}
+		if entry.Chain.Previous != hash {
+			return fmt.Errorf("entry %d does not match the previous entry", entry.Chain.Sequence)
+		}
+	}
+
+	if entry.Type == "checkpoint" {
+		signature, err := v.HashFunc(CheckpointSignatureInput(entry.Time, entry.Chain))
+		if err != nil {
+			return err
+		}
+		if entry.Signature != signature {
+			return fmt.Errorf("checkpoint %d has an invalid signature", entry.Chain.Sequence)
+		}
+		v.Checkpoints++
+	}
+
+	v.started = true
+	v.sequence = entry.Chain.Sequence
+	v.previous = string(line)
+	v.Entries++
+	return nil
+}
@@ -0,0 +1,317 @@
+package audit
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// FilterFields are the fields of an audit entry that a filter can match on
+var FilterFields = []string{
+	// The operation of the request, such as read or update
+	"operation",
+
+	// The path of the request, relative to its namespace
+	"path",
+
+	// The path and type of the mount serving the request
+	"mount_point",
+	"mount_type",
+
+	// The path of the request's namespace, empty for the root namespace
+	"namespace",
+
+	// The type of the auth method that issued the request's token or, for
+	// requests to auth methods such as logins, the type of that auth method
+	"auth_method",
+
+	// "true" if the request failed before it was handled, "false" otherwise.
+	// Responses are only matched on their errors if their request was not
+	// logged.
+	"error",
+}
+
+// Filter is a parsed filter expression of an audit device. Expressions
+// compare the fields of an audit entry with the ==, != and matches
+// operators, and combine comparisons with and, or, not and parentheses:
+//
+//   mount_type == kv and not (operation == read or operation == list)
+//   path matches "^sys/(health|seal-status)"
+//
+// Values are bare words or double quoted strings; matches takes a regular
+// expression.
+type Filter struct {
+	expr string
+	root filterNode
+}
+
+// NewFilter parses a filter expression
+func NewFilter(expr string) (*Filter, error) {
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in filter", p.tokens[p.pos].value)
+	}
+
+	return &Filter{
+		expr: expr,
+		root: root,
+	}, nil
+}
+
+// String returns the expression of the filter
+func (f *Filter) String() string {
+	return f.expr
+}
+
+// Matches returns whether an audit entry with the given fields passes the
+// filter. Missing fields are empty.
+func (f *Filter) Matches(fields map[string]string) bool {
+	return f.root.eval(fields)
+}
+
+type filterNode interface {
+	eval(map[string]string) bool
+}
+
+type filterAnd struct{ left, right filterNode }
+
+func (n *filterAnd) eval(fields map[string]string) bool {
+	return n.left.eval(fields) && n.right.eval(fields)
+}
+
+type filterOr struct{ left, right filterNode }
+
+func (n *filterOr) eval(fields map[string]string) bool {
+	return n.left.eval(fields) || n.right.eval(fields)
+}
+
+type filterNot struct{ node filterNode }
+
+func (n *filterNot) eval(fields map[string]string) bool {
+	return !n.node.eval(fields)
+}
+
+type filterCompare struct {
+	field  string
+	op     string
+	value  string
+	regexp *regexp.Regexp
+}
+
+func (n *filterCompare) eval(fields map[string]string) bool {
+	value := fields[n.field]
+	switch n.op {
+	case "==":
+		return value == n.value
+	case "!=":
+		return value != n.value
+	default:
+		return n.regexp.MatchString(value)
+	}
+}
+
+type filterTokenType int
+
+const (
+	filterTokenWord filterTokenType = iota
+	filterTokenString
+	filterTokenOperator
+	filterTokenOpen
+	filterTokenClose
+)
+
+type filterToken struct {
+	typ   filterTokenType
+	value string
+}
+
+func tokenizeFilter(expr string) ([]filterToken, error) {
+	var tokens []filterToken
+	for i := 0; i < len(expr); {
+		switch c := expr[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, filterToken{filterTokenOpen, "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, filterToken{filterTokenClose, ")"})
+			i++
+		case strings.HasPrefix(expr[i:], "=="), strings.HasPrefix(expr[i:], "!="):
+			tokens = append(tokens, filterToken{filterTokenOperator, expr[i : i+2]})
+			i += 2
+		case c == '"':
+			end := i + 1
+			for ; end < len(expr) && expr[end] != '"'; end++ {
+				if expr[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("unterminated string in filter")
+			}
+			value, err := strconv.Unquote(expr[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s in filter", expr[i:end+1])
+			}
+			tokens = append(tokens, filterToken{filterTokenString, value})
+			i = end + 1
+		default:
+			end := i
+			for end < len(expr) && isFilterWordChar(rune(expr[end])) {
+				end++
+			}
+			if end == i {
+				return nil, fmt.Errorf("unexpected character %q in filter", c)
+			}
+			tokens = append(tokens, filterToken{filterTokenWord, expr[i:end]})
+			i = end
+		}
+	}
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty filter")
+	}
+	return tokens, nil
+}
+
+func isFilterWordChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-./*+:@", r)
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek() (filterToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *filterParser) acceptKeyword(keyword string) bool {
+	if t, ok := p.peek(); ok && t.typ == filterTokenWord && t.value == keyword {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &filterOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &filterAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	if p.acceptKeyword("not") {
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &filterNot{node}, nil
+	}
+
+	t, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("unexpected end of filter")
+	}
+	if t.typ == filterTokenOpen {
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := p.peek(); !ok || t.typ != filterTokenClose {
+			return nil, fmt.Errorf("missing closing parenthesis in filter")
+		}
+		p.pos++
+		return node, nil
+	}
+
+	return p.parseCompare()
+}
+
+func (p *filterParser) parseCompare() (filterNode, error) {
+	field, _ := p.peek()
+	if field.typ != filterTokenWord {
+		return nil, fmt.Errorf("expected a field name in filter, got %q", field.value)
+	}
+	if !isFilterField(field.value) {
+		return nil, fmt.Errorf("unknown field %q in filter", field.value)
+	}
+	p.pos++
+
+	op, ok := p.peek()
+	if !ok || !(op.typ == filterTokenOperator || (op.typ == filterTokenWord && op.value == "matches")) {
+		return nil, fmt.Errorf("expected ==, != or matches after %q in filter", field.value)
+	}
+	p.pos++
+
+	value, ok := p.peek()
+	if !ok || (value.typ != filterTokenWord && value.typ != filterTokenString) {
+		return nil, fmt.Errorf("expected a value after %q in filter", op.value)
+	}
+	p.pos++
+
+	node := &filterCompare{
+		field: field.value,
+		op:    op.value,
+		value: value.value,
+	}
+	if op.value == "matches" {
+		re, err := regexp.Compile(value.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q in filter: %v", value.value, err)
+		}
+		node.regexp = re
+	}
+
+	return node, nil
+}
+
+func isFilterField(name string) bool {
+	for _, field := range FilterFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	fields := map[string]string{
+		"operation":   "read",
+		"path":        "secret/foo",
+		"mount_point": "secret/",
+		"mount_type":  "kv",
+		"namespace":   "",
+		"error":       "false",
+	}
+
+	cases := map[string]bool{
+		`mount_type == kv`:                         true,
+		`mount_type == "kv"`:                       true,
+		`mount_type != kv`:                         false,
+		`mount_type == kv and operation == update`: false,
+		`mount_type == kv and not (operation == read or operation == list)`: false,
+		`operation == update or path matches "^secret/"`:                    true,
+		`not error == true`:                              true,
+		`namespace == ""`:                                true,
+		`auth_method == ""`:                              true,
+		`path matches "^sys/(health|seal-status)$"`:      false,
+		`mount_point == secret/ and (operation == read)`: true,
+	}
+	for expr, expected := range cases {
+		filter, err := NewFilter(expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		if filter.String() != expr {
+			t.Fatalf("bad expression: %q", filter.String())
+		}
+		if actual := filter.Matches(fields); actual != expected {
+			t.Fatalf("%s: expected %t, got %t", expr, expected, actual)
+		}
+	}
+
+	invalid := []string{
+		``,
+		`mount_type`,
+		`mount_type ==`,
+		`color == green`,
+		`mount_type == kv and`,
+		`(mount_type == kv`,
+		`mount_type == kv)`,
+		`path matches "("`,
+		`path == "unterminated`,
+		`mount_type = kv`,
+	}
+	for _, expr := range invalid {
+		if _, err := NewFilter(expr); err == nil {
+			t.Fatalf("%s: expected error", expr)
+		}
+	}
+}
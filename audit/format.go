@@ -394,6 +394,7 @@ type AuditRequestEntry struct {
 	Auth    AuditAuth    `json:"auth"`
 	Request AuditRequest `json:"request"`
 	Error   string       `json:"error"`
+	Chain   *AuditChain  `json:"chain,omitempty"`
 }
 
 // AuditResponseEntry is the structure of a response audit log entry in Audit.
@@ -404,6 +405,7 @@ type AuditResponseEntry struct {
 	Request  AuditRequest  `json:"request"`
 	Response AuditResponse `json:"response"`
 	Error    string        `json:"error"`
+	Chain    *AuditChain   `json:"chain,omitempty"`
 }
 
 type AuditRequest struct {
@@ -1,14 +1,16 @@
 package file
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/audit"
@@ -82,8 +84,37 @@ func Factory(ctx context.Context, conf *audit.BackendConfig) (audit.Backend, err
 		}
 	}
 
+	// Check if hash chaining is enabled
+	var chain *hashChain
+	if raw, ok := conf.Config["hash_chain"]; ok {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, err
+		}
+		if value {
+			if format != "json" {
+				return nil, fmt.Errorf("hash_chain requires the json format")
+			}
+			chain = &hashChain{
+				checkpointInterval: time.Hour,
+			}
+		}
+	}
+	if raw, ok := conf.Config["checkpoint_interval"]; ok {
+		if chain == nil {
+			return nil, fmt.Errorf("checkpoint_interval requires hash_chain")
+		}
+		interval, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to parse checkpoint_interval: {{err}}", err)
+		}
+		chain.checkpointInterval = interval
+	}
+
 	b := &Backend{
 		path:       path,
+		chain:      chain,
+		prefix:     conf.Config["prefix"],
 		mode:       mode,
 		saltConfig: conf.SaltConfig,
 		saltView:   conf.SaltView,
@@ -106,6 +137,13 @@ func Factory(ctx context.Context, conf *audit.BackendConfig) (audit.Backend, err
 		}
 	}
 
+	if chain != nil {
+		b.formatter.AuditFormatWriter = &chainFormatWriter{
+			AuditFormatWriter: b.formatter.AuditFormatWriter,
+			chain:             chain,
+		}
+	}
+
 	switch path {
 	case "stdout", "discard":
 		// no need to test opening file if outputting to stdout or discarding
@@ -127,7 +165,8 @@ func Factory(ctx context.Context, conf *audit.BackendConfig) (audit.Backend, err
 // It doesn't do anything more at the moment to assist with rotation
 // or reset the write cursor, this should be done in the future.
 type Backend struct {
-	path string
+	path   string
+	prefix string
 
 	formatter    audit.AuditFormatter
 	formatConfig audit.FormatterConfig
@@ -136,6 +175,10 @@ type Backend struct {
 	f        *os.File
 	mode     os.FileMode
 
+	// chain is the state of the hash chain of the log, nil if hash chaining
+	// is disabled. It is protected by the file lock.
+	chain *hashChain
+
 	saltMutex  sync.RWMutex
 	salt       *salt.Salt
 	saltConfig *salt.Config
@@ -176,18 +219,74 @@ func (b *Backend) LogRequest(ctx context.Context, in *audit.LogInput) error {
 	b.fileLock.Lock()
 	defer b.fileLock.Unlock()
 
+	return b.log(ctx, func(buf *bytes.Buffer) error {
+		return b.formatter.FormatRequest(ctx, buf, b.formatConfig, in)
+	})
+}
+
+func (b *Backend) LogResponse(ctx context.Context, in *audit.LogInput) error {
+	b.fileLock.Lock()
+	defer b.fileLock.Unlock()
+
+	return b.log(ctx, func(buf *bytes.Buffer) error {
+		return b.formatter.FormatResponse(ctx, buf, b.formatConfig, in)
+	})
+}
+
+// log formats an entry and writes it out, linking it to the hash chain if
+// enabled. The file lock must be held before calling this.
+func (b *Backend) log(ctx context.Context, format func(*bytes.Buffer) error) error {
+	if b.chain != nil {
+		if err := b.restoreChain(ctx); err != nil {
+			return err
+		}
+		if b.chain.checkpointDue() {
+			if err := b.writeCheckpoint(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := format(&buf); err != nil {
+		return err
+	}
+
+	if b.chain == nil {
+		return b.write(buf.Bytes())
+	}
+
+	// The link was added to the entry by the chainFormatWriter; the entry is
+	// hashed before it is written so that a failure to hash it cannot break
+	// the chain
+	link := b.chain.next()
+	hash, err := b.GetHash(ctx, strings.TrimSuffix(buf.String(), "\n"))
+	if err != nil {
+		return err
+	}
+	if err := b.write(buf.Bytes()); err != nil {
+		return err
+	}
+	b.chain.advance(link, hash)
+	return nil
+}
+
+// write writes a formatted entry. The file lock must be held before calling
+// this.
+func (b *Backend) write(entry []byte) error {
 	switch b.path {
 	case "stdout":
-		return b.formatter.FormatRequest(ctx, os.Stdout, b.formatConfig, in)
+		_, err := os.Stdout.Write(entry)
+		return err
 	case "discard":
-		return b.formatter.FormatRequest(ctx, ioutil.Discard, b.formatConfig, in)
+		return nil
 	}
 
 	if err := b.open(); err != nil {
 		return err
 	}
 
-	if err := b.formatter.FormatRequest(ctx, b.f, b.formatConfig, in); err == nil {
+	if _, err := b.f.Write(entry); err == nil {
 		return nil
 	}
 
@@ -199,38 +298,103 @@ func (b *Backend) LogRequest(ctx context.Context, in *audit.LogInput) error {
 		return err
 	}
 
-	return b.formatter.FormatRequest(ctx, b.f, b.formatConfig, in)
+	_, err := b.f.Write(entry)
+	return err
 }
 
-func (b *Backend) LogResponse(ctx context.Context, in *audit.LogInput) error {
-
-	b.fileLock.Lock()
-	defer b.fileLock.Unlock()
+// restoreChain continues the hash chain of the existing log file the first
+// time an entry is logged. The file lock must be held before calling this.
+func (b *Backend) restoreChain(ctx context.Context) error {
+	if b.chain.restored {
+		return nil
+	}
 
+	var line []byte
 	switch b.path {
-	case "stdout":
-		return b.formatter.FormatResponse(ctx, os.Stdout, b.formatConfig, in)
-	case "discard":
-		return b.formatter.FormatResponse(ctx, ioutil.Discard, b.formatConfig, in)
+	case "stdout", "discard":
+	default:
+		var err error
+		line, err = lastLine(b.path)
+		if err != nil {
+			return errwrap.Wrapf("failed to read the last entry of the audit log: {{err}}", err)
+		}
 	}
 
-	if err := b.open(); err != nil {
-		return err
+	var hash string
+	if len(line) > 0 {
+		var err error
+		hash, err = b.GetHash(ctx, string(line))
+		if err != nil {
+			return err
+		}
 	}
 
-	if err := b.formatter.FormatResponse(ctx, b.f, b.formatConfig, in); err == nil {
+	b.chain.restore(line, hash)
+	if b.chain.started || line != nil || b.path == "stdout" || b.path == "discard" {
 		return nil
 	}
 
-	// Opportunistically try to re-open the FD, once per call
-	b.f.Close()
-	b.f = nil
+	// The log file is empty, so the chain continues from the last checkpoint
+	// written to the previous file. A new chain is only started by a new
+	// audit device.
+	entry, err := b.saltView.Get(ctx, chainStoragePath)
+	if err != nil {
+		return errwrap.Wrapf("failed to read the last checkpoint of the hash chain: {{err}}", err)
+	}
+	if entry == nil {
+		return nil
+	}
+	var checkpoint chainCheckpoint
+	if err := entry.DecodeJSON(&checkpoint); err != nil {
+		return err
+	}
+	b.chain.advance(&audit.AuditChain{Sequence: checkpoint.Sequence}, checkpoint.Hash)
+	return nil
+}
 
-	if err := b.open(); err != nil {
+// writeCheckpoint writes a signed checkpoint to a hash chained log. The file
+// lock must be held before calling this.
+func (b *Backend) writeCheckpoint(ctx context.Context) error {
+	entry := &audit.AuditCheckpointEntry{
+		Time:  time.Now().UTC().Format(time.RFC3339Nano),
+		Type:  "checkpoint",
+		Chain: b.chain.next(),
+	}
+
+	signature, err := b.GetHash(ctx, audit.CheckpointSignatureInput(entry.Time, entry.Chain))
+	if err != nil {
+		return err
+	}
+	entry.Signature = signature
+
+	line, err := json.Marshal(entry)
+	if err != nil {
 		return err
 	}
+	line = append([]byte(b.prefix), line...)
 
-	return b.formatter.FormatResponse(ctx, b.f, b.formatConfig, in)
+	hash, err := b.GetHash(ctx, string(line))
+	if err != nil {
+		return err
+	}
+	if err := b.write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	b.chain.advance(entry.Chain, hash)
+	b.chain.lastCheckpoint = time.Now()
+
+	stored, err := logical.StorageEntryJSON(chainStoragePath, &chainCheckpoint{
+		Sequence: entry.Chain.Sequence,
+		Hash:     hash,
+	})
+	if err != nil {
+		return err
+	}
+	if err := b.saltView.Put(ctx, stored); err != nil {
+		return errwrap.Wrapf("failed to store the last checkpoint of the hash chain: {{err}}", err)
+	}
+	return nil
 }
 
 // The file lock must be held before calling this
@@ -264,7 +428,7 @@ func (b *Backend) open() error {
 	return nil
 }
 
-func (b *Backend) Reload(_ context.Context) error {
+func (b *Backend) Reload(ctx context.Context) error {
 	switch b.path {
 	case "stdout", "discard":
 		return nil
@@ -277,6 +441,13 @@ func (b *Backend) Reload(_ context.Context) error {
 		return b.open()
 	}
 
+	// Mark the end of the file with a checkpoint in case it is being rotated
+	if b.chain != nil && b.chain.started {
+		if err := b.writeCheckpoint(ctx); err != nil {
+			return err
+		}
+	}
+
 	err := b.f.Close()
 	// Set to nil here so that even if we error out, on the next access open()
 	// will be tried
@@ -1,14 +1,17 @@
 package file
 
 import (
+	"bufio"
 	"context"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/salt"
 	"github.com/hashicorp/vault/logical"
 )
@@ -90,3 +93,121 @@ func TestAuditFile_fileModeExisting(t *testing.T) {
 		t.Fatalf("File mode does not match.")
 	}
 }
+
+func TestAuditFile_hashChain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-test_audit_file-hash_chain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "audit.log")
+	saltView := &logical.InmemStorage{}
+	newBackend := func() *Backend {
+		b, err := Factory(context.Background(), &audit.BackendConfig{
+			SaltConfig: &salt.Config{},
+			SaltView:   saltView,
+			Config: map[string]string{
+				"path":       file,
+				"hash_chain": "true",
+				"prefix":     "vault: ",
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b.(*Backend)
+	}
+	logRequests := func(b *Backend, n int) {
+		for i := 0; i < n; i++ {
+			in := &audit.LogInput{
+				Request: &logical.Request{
+					Operation: logical.ReadOperation,
+					Path:      "secret/foo",
+				},
+			}
+			if err := b.LogRequest(namespace.RootContext(nil), in); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	b := newBackend()
+	logRequests(b, 3)
+
+	// The chain continues across a rotation and a restart
+	if err := os.Rename(file, file+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	logRequests(b, 2)
+	b = newBackend()
+	logRequests(b, 2)
+
+	// A restart with an empty log file continues from the checkpoint written
+	// when the file was rotated
+	if err := os.Rename(file, file+".2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	b = newBackend()
+	logRequests(b, 1)
+
+	salter, err := b.Salt(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify := func(lines []string) (*audit.ChainVerifier, error) {
+		v := &audit.ChainVerifier{
+			HashFunc: func(data string) (string, error) {
+				return audit.HashString(salter, data), nil
+			},
+		}
+		for _, line := range lines {
+			if err := v.Verify([]byte(line)); err != nil {
+				return v, err
+			}
+		}
+		return v, nil
+	}
+
+	var lines []string
+	for _, path := range []string{file + ".1", file + ".2", file} {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		f.Close()
+	}
+
+	v, err := verify(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Entries != 10 || v.Checkpoints != 2 {
+		t.Fatalf("bad verification: %#v", v)
+	}
+
+	// Removing or editing an entry breaks the chain
+	if _, err := verify(append(append([]string(nil), lines[:2]...), lines[3:]...)); err == nil {
+		t.Fatal("expected removed entry to break the chain")
+	}
+	tampered := append([]string(nil), lines...)
+	tampered[1] = strings.Replace(tampered[1], "secret/foo", "secret/bar", 1)
+	if _, err := verify(tampered); err == nil {
+		t.Fatal("expected edited entry to break the chain")
+	}
+
+	// A new chain cannot start in the middle of the log
+	if _, err := verify(append(append([]string(nil), lines...), lines[0])); err == nil {
+		t.Fatal("expected a new chain to break the chain")
+	}
+}
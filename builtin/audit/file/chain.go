@@ -0,0 +1,144 @@
+package file
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+)
+
+// chainStoragePath is where the link of the last checkpoint of a hash chain
+// is kept in the salt view, so that the chain can continue when the log file
+// is empty, such as after it was rotated
+const chainStoragePath = "chain"
+
+// chainCheckpoint is the link of the last checkpoint of a hash chain
+type chainCheckpoint struct {
+	Sequence uint64 `json:"sequence"`
+	Hash     string `json:"hash"`
+}
+
+// hashChain is the state of a hash chained log: each entry carries the HMAC
+// of the entry written before it.
+type hashChain struct {
+	// restored is set once the chain has been picked up from the end of an
+	// existing log file
+	restored bool
+
+	started  bool
+	sequence uint64
+	last     string
+
+	checkpointInterval time.Duration
+	lastCheckpoint     time.Time
+}
+
+// next returns the link of the next entry of the chain
+func (c *hashChain) next() *audit.AuditChain {
+	if !c.started {
+		return &audit.AuditChain{}
+	}
+	return &audit.AuditChain{
+		Sequence: c.sequence + 1,
+		Previous: c.last,
+	}
+}
+
+// advance records that an entry with the given link and HMAC was written
+func (c *hashChain) advance(link *audit.AuditChain, hash string) {
+	c.started = true
+	c.sequence = link.Sequence
+	c.last = hash
+}
+
+// restore continues the chain of the last entry of a log file, or leaves the
+// chain to start anew if the entry is not chained
+func (c *hashChain) restore(line []byte, hash string) {
+	c.restored = true
+	c.lastCheckpoint = time.Now()
+
+	var entry struct {
+		Chain *audit.AuditChain `json:"chain"`
+	}
+	start := bytes.IndexByte(line, '{')
+	if start < 0 || json.Unmarshal(line[start:], &entry) != nil || entry.Chain == nil {
+		return
+	}
+	c.advance(entry.Chain, hash)
+}
+
+// checkpointDue returns whether a checkpoint should be written before the
+// next entry
+func (c *hashChain) checkpointDue() bool {
+	return c.started && c.checkpointInterval > 0 && time.Since(c.lastCheckpoint) >= c.checkpointInterval
+}
+
+// chainFormatWriter adds the next link of a hash chain to the entries
+// written by an AuditFormatWriter
+type chainFormatWriter struct {
+	audit.AuditFormatWriter
+	chain *hashChain
+}
+
+func (w *chainFormatWriter) WriteRequest(out io.Writer, req *audit.AuditRequestEntry) error {
+	if req != nil {
+		req.Chain = w.chain.next()
+	}
+	return w.AuditFormatWriter.WriteRequest(out, req)
+}
+
+func (w *chainFormatWriter) WriteResponse(out io.Writer, resp *audit.AuditResponseEntry) error {
+	if resp != nil {
+		resp.Chain = w.chain.next()
+	}
+	return w.AuditFormatWriter.WriteResponse(out, resp)
+}
+
+// lastLine returns the last line of a file without its trailing newline, or
+// nil if the file is empty or does not exist
+func lastLine(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// Read backwards from the end of the file until the start of the line
+	var line []byte
+	chunk := make([]byte, 4096)
+	for offset := info.Size(); offset > 0; {
+		n := int64(len(chunk))
+		if offset < n {
+			n = offset
+		}
+		offset -= n
+		if _, err := f.ReadAt(chunk[:n], offset); err != nil {
+			return nil, err
+		}
+		line = append(append([]byte(nil), chunk[:n]...), line...)
+
+		trimmed := bytes.TrimRight(line, "\n")
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 {
+			return trimmed[i+1:], nil
+		}
+		if offset == 0 {
+			if len(trimmed) == 0 {
+				return nil, nil
+			}
+			return trimmed, nil
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,590 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	cleanhttp "github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/helper/parseutil"
+	"github.com/hashicorp/vault/helper/salt"
+	"github.com/hashicorp/vault/helper/tlsutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/jefferai/jsonx"
+)
+
+// errClosed is returned for entries that could not be delivered before the
+// backend was closed
+var errClosed = errors.New("audit backend is closed")
+
+func Factory(ctx context.Context, conf *audit.BackendConfig) (audit.Backend, error) {
+	if conf.SaltConfig == nil {
+		return nil, fmt.Errorf("nil salt config")
+	}
+	if conf.SaltView == nil {
+		return nil, fmt.Errorf("nil salt view")
+	}
+
+	address, ok := conf.Config["url"]
+	if !ok {
+		return nil, fmt.Errorf("url is required")
+	}
+	if u, err := url.Parse(address); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("url must be an http or https URL")
+	}
+
+	format, ok := conf.Config["format"]
+	if !ok {
+		format = "json"
+	}
+	switch format {
+	case "json", "jsonx":
+	default:
+		return nil, fmt.Errorf("unknown format type %q", format)
+	}
+
+	// Check if hashing of accessor is disabled
+	hmacAccessor := true
+	if hmacAccessorRaw, ok := conf.Config["hmac_accessor"]; ok {
+		value, err := strconv.ParseBool(hmacAccessorRaw)
+		if err != nil {
+			return nil, err
+		}
+		hmacAccessor = value
+	}
+
+	// Check if raw logging is enabled
+	logRaw := false
+	if raw, ok := conf.Config["log_raw"]; ok {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, err
+		}
+		logRaw = b
+	}
+
+	// Headers are given as a JSON object of header names to a value or a list
+	// of values
+	headers := make(http.Header)
+	if headersRaw, ok := conf.Config["headers"]; ok {
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(headersRaw), &parsed); err != nil {
+			return nil, errwrap.Wrapf("failed to parse headers: {{err}}", err)
+		}
+		for name, value := range parsed {
+			switch value := value.(type) {
+			case string:
+				headers.Add(name, value)
+			case []interface{}:
+				for _, v := range value {
+					s, ok := v.(string)
+					if !ok {
+						return nil, fmt.Errorf("values of header %q must be strings", name)
+					}
+					headers.Add(name, s)
+				}
+			default:
+				return nil, fmt.Errorf("value of header %q must be a string or a list of strings", name)
+			}
+		}
+	}
+
+	blocking := true
+	if raw, ok := conf.Config["blocking"]; ok {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, err
+		}
+		blocking = b
+	}
+
+	durations := map[string]time.Duration{
+		"timeout":           5 * time.Second,
+		"batch_interval":    time.Second,
+		"retry_backoff":     250 * time.Millisecond,
+		"max_retry_backoff": 30 * time.Second,
+	}
+	for name := range durations {
+		if raw, ok := conf.Config[name]; ok {
+			d, err := parseutil.ParseDurationSecond(raw)
+			if err != nil {
+				return nil, errwrap.Wrapf(fmt.Sprintf("failed to parse %s: {{err}}", name), err)
+			}
+			if d <= 0 {
+				return nil, fmt.Errorf("%s must be positive", name)
+			}
+			durations[name] = d
+		}
+	}
+
+	ints := map[string]int64{
+		"batch_size":       1,
+		"max_retries":      3,
+		"buffer_max_bytes": 64 * 1024 * 1024,
+	}
+	for name := range ints {
+		if raw, ok := conf.Config[name]; ok {
+			i, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				return nil, errwrap.Wrapf(fmt.Sprintf("failed to parse %s: {{err}}", name), err)
+			}
+			if i < 0 || (i == 0 && name != "max_retries") {
+				return nil, fmt.Errorf("%s must be positive", name)
+			}
+			ints[name] = i
+		}
+	}
+
+	b := &Backend{
+		saltConfig: conf.SaltConfig,
+		saltView:   conf.SaltView,
+		formatConfig: audit.FormatterConfig{
+			Raw:          logRaw,
+			HMACAccessor: hmacAccessor,
+		},
+
+		config:          conf.Config,
+		url:             address,
+		headers:         headers,
+		timeout:         durations["timeout"],
+		blocking:        blocking,
+		batchSize:       int(ints["batch_size"]),
+		batchInterval:   durations["batch_interval"],
+		maxRetries:      int(ints["max_retries"]),
+		retryBackoff:    durations["retry_backoff"],
+		maxRetryBackoff: durations["max_retry_backoff"],
+		stopCh:          make(chan struct{}),
+	}
+
+	switch format {
+	case "json":
+		b.contentType = "application/x-ndjson"
+		b.formatter.AuditFormatWriter = &audit.JSONFormatWriter{
+			Prefix:   conf.Config["prefix"],
+			SaltFunc: b.Salt,
+		}
+	case "jsonx":
+		b.contentType = "application/xml"
+		b.jsonxBatches = b.batchSize > 1
+		b.formatter.AuditFormatWriter = &audit.JSONxFormatWriter{
+			Prefix:   conf.Config["prefix"],
+			SaltFunc: b.Salt,
+		}
+	}
+
+	client, err := b.newClient()
+	if err != nil {
+		return nil, err
+	}
+	b.client = client
+
+	if blocking {
+		b.queue = make(chan *pendingEntry, b.batchSize)
+		b.wg.Add(1)
+		go b.runBlocking()
+		return b, nil
+	}
+
+	// In non-blocking mode entries are buffered until they are delivered
+	if path, ok := conf.Config["buffer_path"]; ok {
+		b.buffer, err = newFileBuffer(path, ints["buffer_max_bytes"])
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("failed to open buffer file %q: {{err}}", path), err)
+		}
+	} else {
+		b.buffer = newMemoryBuffer(ints["buffer_max_bytes"])
+	}
+	b.notify = make(chan struct{}, 1)
+	b.wg.Add(1)
+	go b.runNonBlocking()
+
+	return b, nil
+}
+
+// Backend is the audit backend for HTTP endpoints. Entries are POSTed to the
+// configured URL in batches. JSON entries are separated by newlines, and
+// batched JSONx entries are sent as a single JSONx array.
+//
+// In blocking mode, logging an entry waits until its batch is delivered, and
+// fails if it cannot be delivered. Otherwise, entries are buffered, in memory
+// or in a file, and delivered in the background; logging only fails if the
+// buffer is full.
+type Backend struct {
+	formatter    audit.AuditFormatter
+	formatConfig audit.FormatterConfig
+
+	config      map[string]string
+	url         string
+	headers     http.Header
+	contentType string
+	timeout     time.Duration
+
+	// jsonxBatches is set when JSONx entries are batched, and must be
+	// wrapped into a single XML document
+	jsonxBatches bool
+
+	clientLock sync.RWMutex
+	client     *http.Client
+
+	blocking        bool
+	batchSize       int
+	batchInterval   time.Duration
+	maxRetries      int
+	retryBackoff    time.Duration
+	maxRetryBackoff time.Duration
+
+	// queue holds the entries waiting to be sent in blocking mode
+	queue chan *pendingEntry
+
+	// buffer holds the entries waiting to be sent in non-blocking mode, and
+	// notify is signalled when entries are added
+	buffer entryBuffer
+	notify chan struct{}
+
+	stopCh    chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+
+	saltMutex  sync.RWMutex
+	salt       *salt.Salt
+	saltConfig *salt.Config
+	saltView   logical.Storage
+}
+
+// pendingEntry is an entry waiting to be sent in blocking mode
+type pendingEntry struct {
+	data []byte
+	done chan error
+}
+
+var _ audit.Backend = (*Backend)(nil)
+
+func (b *Backend) newClient() (*http.Client, error) {
+	var caCert, clientCert, clientKey []byte
+	for name, dest := range map[string]*[]byte{
+		"tls_ca_cert":     &caCert,
+		"tls_client_cert": &clientCert,
+		"tls_client_key":  &clientKey,
+	} {
+		path, ok := b.config[name]
+		if !ok {
+			continue
+		}
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("failed to read %s: {{err}}", name), err)
+		}
+		*dest = contents
+	}
+	if (len(clientCert) == 0) != (len(clientKey) == 0) {
+		return nil, fmt.Errorf("tls_client_cert and tls_client_key must be set together")
+	}
+
+	tlsConfig, err := tlsutil.ClientTLSConfig(caCert, clientCert, clientKey)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+	tlsConfig.ServerName = b.config["tls_server_name"]
+	if raw, ok := b.config["tls_skip_verify"]; ok {
+		skipVerify, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.InsecureSkipVerify = skipVerify
+	}
+
+	client := cleanhttp.DefaultPooledClient()
+	client.Timeout = b.timeout
+	client.Transport.(*http.Transport).TLSClientConfig = tlsConfig
+	return client, nil
+}
+
+func (b *Backend) GetHash(ctx context.Context, data string) (string, error) {
+	salt, err := b.Salt(ctx)
+	if err != nil {
+		return "", err
+	}
+	return audit.HashString(salt, data), nil
+}
+
+func (b *Backend) LogRequest(ctx context.Context, in *audit.LogInput) error {
+	var buf bytes.Buffer
+	if err := b.formatter.FormatRequest(ctx, &buf, b.formatConfig, in); err != nil {
+		return err
+	}
+
+	return b.log(ctx, buf.Bytes())
+}
+
+func (b *Backend) LogResponse(ctx context.Context, in *audit.LogInput) error {
+	var buf bytes.Buffer
+	if err := b.formatter.FormatResponse(ctx, &buf, b.formatConfig, in); err != nil {
+		return err
+	}
+
+	return b.log(ctx, buf.Bytes())
+}
+
+func (b *Backend) log(ctx context.Context, data []byte) error {
+	if !b.blocking {
+		if err := b.buffer.Append(data); err != nil {
+			return err
+		}
+		select {
+		case b.notify <- struct{}{}:
+		default:
+		}
+		return nil
+	}
+
+	entry := &pendingEntry{
+		data: data,
+		done: make(chan error, 1),
+	}
+	select {
+	case b.queue <- entry:
+	case <-b.stopCh:
+		return errClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-entry.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runBlocking sends the queued entries in blocking mode, once a batch is full
+// or the batch interval has passed since its first entry
+func (b *Backend) runBlocking() {
+	defer b.wg.Done()
+
+	for {
+		var batch []*pendingEntry
+		select {
+		case entry := <-b.queue:
+			batch = append(batch, entry)
+		case <-b.stopCh:
+			return
+		}
+
+		timer := time.NewTimer(b.batchInterval)
+	COLLECT:
+		for len(batch) < b.batchSize {
+			select {
+			case entry := <-b.queue:
+				batch = append(batch, entry)
+			case <-timer.C:
+				break COLLECT
+			case <-b.stopCh:
+				timer.Stop()
+				for _, entry := range batch {
+					entry.done <- errClosed
+				}
+				return
+			}
+		}
+		timer.Stop()
+
+		entries := make([][]byte, 0, len(batch))
+		for _, entry := range batch {
+			entries = append(entries, entry.data)
+		}
+		err := b.sendWithRetries(entries, b.maxRetries)
+		for _, entry := range batch {
+			entry.done <- err
+		}
+	}
+}
+
+// runNonBlocking sends the buffered entries in non-blocking mode, once there
+// are enough for a batch or on every batch interval. Entries are retried until
+// they are delivered.
+func (b *Backend) runNonBlocking() {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(b.batchInterval)
+	defer ticker.Stop()
+
+	for {
+		var flushAll bool
+		select {
+		case <-b.notify:
+		case <-ticker.C:
+			flushAll = true
+		case <-b.stopCh:
+			return
+		}
+
+		for flushAll || b.buffer.Len() >= b.batchSize {
+			entries, err := b.buffer.Peek(b.batchSize)
+			if err != nil || len(entries) == 0 {
+				break
+			}
+			if err := b.sendWithRetries(entries, -1); err != nil {
+				// Only returned once the backend is closed
+				return
+			}
+			if err := b.buffer.Drop(len(entries)); err != nil {
+				break
+			}
+		}
+	}
+}
+
+// sendWithRetries sends the entries, retrying with exponential backoff up to
+// maxRetries times, or until the backend is closed if maxRetries is negative
+func (b *Backend) sendWithRetries(entries [][]byte, maxRetries int) error {
+	backoff := b.retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := b.send(entries)
+		if err == nil {
+			return nil
+		}
+		if maxRetries >= 0 && attempt >= maxRetries {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-b.stopCh:
+			return err
+		}
+		backoff *= 2
+		if backoff > b.maxRetryBackoff {
+			backoff = b.maxRetryBackoff
+		}
+	}
+}
+
+func (b *Backend) send(entries [][]byte) error {
+	var body []byte
+	if b.jsonxBatches {
+		body = jsonxBatch(entries)
+	} else {
+		body = bytes.Join(entries, nil)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, b.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range b.headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", b.contentType)
+
+	b.clientLock.RLock()
+	client := b.client
+	b.clientLock.RUnlock()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d from audit endpoint", resp.StatusCode)
+	}
+	return nil
+}
+
+// jsonxBatch wraps JSONx entries into a single document, as a JSONx array of
+// objects, since the formatter only writes the members of each entry
+func jsonxBatch(entries [][]byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(jsonx.XMLHeader)
+	buf.WriteString(`<json:array xmlns:json="http://www.ibm.com/xmlns/prod/2009/jsonx">`)
+	for _, entry := range entries {
+		buf.WriteString("<json:object>")
+		buf.Write(entry)
+		buf.WriteString("</json:object>")
+	}
+	buf.WriteString("</json:array>")
+	return buf.Bytes()
+}
+
+// Reload reloads the TLS certificates of the backend
+func (b *Backend) Reload(ctx context.Context) error {
+	client, err := b.newClient()
+	if err != nil {
+		return err
+	}
+
+	b.clientLock.Lock()
+	b.client = client
+	b.clientLock.Unlock()
+	return nil
+}
+
+// Close stops the delivery of entries. Buffered entries that were not
+// delivered are kept in the buffer file, if there is one.
+func (b *Backend) Close() error {
+	b.closeOnce.Do(func() {
+		close(b.stopCh)
+	})
+	b.wg.Wait()
+
+	// Fail the entries that were queued but not collected into a batch
+	for drained := b.queue == nil; !drained; {
+		select {
+		case entry := <-b.queue:
+			entry.done <- errClosed
+		default:
+			drained = true
+		}
+	}
+
+	if b.buffer != nil {
+		return b.buffer.Close()
+	}
+	return nil
+}
+
+func (b *Backend) Salt(ctx context.Context) (*salt.Salt, error) {
+	b.saltMutex.RLock()
+	if b.salt != nil {
+		defer b.saltMutex.RUnlock()
+		return b.salt, nil
+	}
+	b.saltMutex.RUnlock()
+	b.saltMutex.Lock()
+	defer b.saltMutex.Unlock()
+	if b.salt != nil {
+		return b.salt, nil
+	}
+	salt, err := salt.NewSalt(ctx, b.saltView, b.saltConfig)
+	if err != nil {
+		return nil, err
+	}
+	b.salt = salt
+	return salt, nil
+}
+
+func (b *Backend) Invalidate(_ context.Context) {
+	b.saltMutex.Lock()
+	defer b.saltMutex.Unlock()
+	b.salt = nil
+}
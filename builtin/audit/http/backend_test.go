@@ -0,0 +1,357 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/helper/salt"
+	"github.com/hashicorp/vault/logical"
+)
+
+type testSink struct {
+	sync.Mutex
+	fail    bool
+	headers []http.Header
+	entries []map[string]interface{}
+}
+
+func (s *testSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	s.headers = append(s.headers, r.Header)
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.entries = append(s.entries, entry)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *testSink) setFail(fail bool) {
+	s.Lock()
+	defer s.Unlock()
+	s.fail = fail
+}
+
+func (s *testSink) paths() []string {
+	s.Lock()
+	defer s.Unlock()
+	var paths []string
+	for _, entry := range s.entries {
+		paths = append(paths, entry["request"].(map[string]interface{})["path"].(string))
+	}
+	return paths
+}
+
+func testBackend(t *testing.T, config map[string]string) *Backend {
+	t.Helper()
+	b, err := Factory(context.Background(), &audit.BackendConfig{
+		SaltConfig: &salt.Config{},
+		SaltView:   &logical.InmemStorage{},
+		Config:     config,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.(*Backend)
+}
+
+func testLogInput(path string) *audit.LogInput {
+	return &audit.LogInput{
+		Request: &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      path,
+		},
+	}
+}
+
+func TestAuditHTTP_Blocking(t *testing.T) {
+	sink := &testSink{}
+	server := httptest.NewServer(sink)
+	defer server.Close()
+
+	b := testBackend(t, map[string]string{
+		"url":           server.URL,
+		"headers":       `{"Authorization": "Bearer secret", "X-Test": ["a", "b"]}`,
+		"max_retries":   "1",
+		"retry_backoff": "10ms",
+	})
+	defer b.Close()
+
+	if err := b.LogRequest(namespace.RootContext(nil), testLogInput("secret/foo")); err != nil {
+		t.Fatal(err)
+	}
+	if paths := sink.paths(); len(paths) != 1 || paths[0] != "secret/foo" {
+		t.Fatalf("bad entries: %v", paths)
+	}
+	headers := sink.headers[0]
+	if headers.Get("Authorization") != "Bearer secret" || len(headers["X-Test"]) != 2 || headers.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("bad headers: %v", headers)
+	}
+
+	// Requests fail while the sink is down
+	sink.setFail(true)
+	if err := b.LogRequest(namespace.RootContext(nil), testLogInput("secret/bar")); err == nil {
+		t.Fatal("expected error")
+	}
+	sink.setFail(false)
+	if err := b.LogRequest(namespace.RootContext(nil), testLogInput("secret/baz")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuditHTTP_Batching(t *testing.T) {
+	sink := &testSink{}
+	server := httptest.NewServer(sink)
+	defer server.Close()
+
+	b := testBackend(t, map[string]string{
+		"url":            server.URL,
+		"batch_size":     "3",
+		"batch_interval": "1h",
+	})
+	defer b.Close()
+
+	var wg sync.WaitGroup
+	for _, path := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			if err := b.LogRequest(namespace.RootContext(nil), testLogInput(path)); err != nil {
+				t.Error(err)
+			}
+		}(path)
+	}
+	wg.Wait()
+
+	if len(sink.headers) != 1 || len(sink.paths()) != 3 {
+		t.Fatalf("expected one batch of 3 entries, got %d requests: %v", len(sink.headers), sink.paths())
+	}
+}
+
+func TestAuditHTTP_JSONxBatching(t *testing.T) {
+	var lock sync.Mutex
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil || r.Header.Get("Content-Type") != "application/xml" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		lock.Lock()
+		bodies = append(bodies, body)
+		lock.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	b := testBackend(t, map[string]string{
+		"url":            server.URL,
+		"format":         "jsonx",
+		"batch_size":     "3",
+		"batch_interval": "1h",
+	})
+	defer b.Close()
+
+	var wg sync.WaitGroup
+	for _, path := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			if err := b.LogRequest(namespace.RootContext(nil), testLogInput(path)); err != nil {
+				t.Error(err)
+			}
+		}(path)
+	}
+	wg.Wait()
+
+	if len(bodies) != 1 {
+		t.Fatalf("expected one batch, got %d requests", len(bodies))
+	}
+
+	// The batch must be a single well-formed document holding every entry
+	var roots, entries, depth int
+	dec := xml.NewDecoder(bytes.NewReader(bodies[0]))
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("batch is not well-formed XML: %v: %s", err, bodies[0])
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			switch depth {
+			case 0:
+				roots++
+			case 1:
+				if token.Name.Local == "object" {
+					entries++
+				}
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+	if roots != 1 || entries != 3 {
+		t.Fatalf("expected a single root with 3 entries, got %d roots and %d entries: %s", roots, entries, bodies[0])
+	}
+	for _, path := range []string{"a", "b", "c"} {
+		if !bytes.Contains(bodies[0], []byte(`<json:string name="path">`+path+`</json:string>`)) {
+			t.Fatalf("missing entry for path %q: %s", path, bodies[0])
+		}
+	}
+}
+
+func TestAuditHTTP_NonBlocking(t *testing.T) {
+	sink := &testSink{fail: true}
+	server := httptest.NewServer(sink)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "vault-test_audit_http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bufferPath := filepath.Join(dir, "buffer")
+
+	config := map[string]string{
+		"url":               server.URL,
+		"blocking":          "false",
+		"batch_size":        "2",
+		"batch_interval":    "20ms",
+		"retry_backoff":     "10ms",
+		"max_retry_backoff": "20ms",
+		"buffer_path":       bufferPath,
+	}
+	b := testBackend(t, config)
+
+	// Entries are buffered while the sink is down
+	for _, path := range []string{"a", "b", "c"} {
+		if err := b.LogRequest(namespace.RootContext(nil), testLogInput(path)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Buffered entries survive a restart
+	b = testBackend(t, config)
+	defer b.Close()
+	if b.buffer.Len() != 3 {
+		t.Fatalf("expected 3 buffered entries, got %d", b.buffer.Len())
+	}
+
+	sink.setFail(false)
+	deadline := time.Now().Add(5 * time.Second)
+	for b.buffer.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if paths := sink.paths(); len(paths) != 3 || paths[0] != "a" || paths[2] != "c" {
+		t.Fatalf("bad entries: %v", paths)
+	}
+	info, err := os.Stat(bufferPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 0 {
+		t.Fatalf("expected buffer file to be truncated, got %d bytes", info.Size())
+	}
+}
+
+func TestAuditHTTP_BufferFull(t *testing.T) {
+	sink := &testSink{fail: true}
+	server := httptest.NewServer(sink)
+	defer server.Close()
+
+	b := testBackend(t, map[string]string{
+		"url":              server.URL,
+		"blocking":         "false",
+		"buffer_max_bytes": "1024",
+	})
+	defer b.Close()
+
+	var err error
+	for i := 0; i < 100 && err == nil; i++ {
+		err = b.LogRequest(namespace.RootContext(nil), testLogInput("secret/foo"))
+	}
+	if err != errBufferFull {
+		t.Fatalf("expected full buffer, got %v", err)
+	}
+}
+
+func TestFileBuffer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-test_audit_http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "buffer")
+
+	buf, err := newFileBuffer(path, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range []string{"one", "two", "three"} {
+		if err := buf.Append([]byte(entry)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := buf.Drop(1); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := buf.Peek(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || !bytes.Equal(entries[0], []byte("two")) || !bytes.Equal(entries[1], []byte("three")) {
+		t.Fatalf("bad entries: %q", entries)
+	}
+	buf.Close()
+
+	// Entries are only removed from the file once it is truncated or
+	// compacted, and a partially written entry is dropped on reopening
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 0, 10, 'x'})
+	f.Close()
+
+	buf, err = newFileBuffer(path, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer buf.Close()
+	if buf.Len() != 3 {
+		t.Fatalf("expected 3 entries, got %d", buf.Len())
+	}
+}
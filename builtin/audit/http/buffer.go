@@ -0,0 +1,268 @@
+package http
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// errBufferFull is returned when an entry does not fit in the buffer
+var errBufferFull = errors.New("audit buffer is full")
+
+// entryBuffer holds the entries that have not been delivered yet in
+// non-blocking mode
+type entryBuffer interface {
+	// Append adds an entry to the end of the buffer
+	Append([]byte) error
+
+	// Peek returns up to n entries from the start of the buffer
+	Peek(n int) ([][]byte, error)
+
+	// Drop removes n entries from the start of the buffer
+	Drop(n int) error
+
+	// Len returns the number of entries in the buffer
+	Len() int
+
+	Close() error
+}
+
+// memoryBuffer is an entryBuffer bounded by the total size of its entries
+type memoryBuffer struct {
+	sync.Mutex
+	entries  [][]byte
+	size     int64
+	maxBytes int64
+}
+
+func newMemoryBuffer(maxBytes int64) *memoryBuffer {
+	return &memoryBuffer{
+		maxBytes: maxBytes,
+	}
+}
+
+func (m *memoryBuffer) Append(entry []byte) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.size+int64(len(entry)) > m.maxBytes {
+		return errBufferFull
+	}
+	m.entries = append(m.entries, entry)
+	m.size += int64(len(entry))
+	return nil
+}
+
+func (m *memoryBuffer) Peek(n int) ([][]byte, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if n > len(m.entries) {
+		n = len(m.entries)
+	}
+	return append([][]byte(nil), m.entries[:n]...), nil
+}
+
+func (m *memoryBuffer) Drop(n int) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if n > len(m.entries) {
+		n = len(m.entries)
+	}
+	for _, entry := range m.entries[:n] {
+		m.size -= int64(len(entry))
+	}
+	m.entries = m.entries[n:]
+	return nil
+}
+
+func (m *memoryBuffer) Len() int {
+	m.Lock()
+	defer m.Unlock()
+	return len(m.entries)
+}
+
+func (m *memoryBuffer) Close() error {
+	return nil
+}
+
+// fileCompactThreshold is how many bytes of delivered entries are kept at the
+// start of a buffer file before it is rewritten
+const fileCompactThreshold = 1 << 20
+
+// fileBuffer is an entryBuffer kept in a file, so that undelivered entries
+// survive restarts. Entries are stored with a length prefix; the entries
+// before the read offset have been delivered. As the read offset is not
+// persisted, entries may be delivered again after a restart.
+type fileBuffer struct {
+	sync.Mutex
+	path     string
+	f        *os.File
+	maxBytes int64
+
+	readOffset int64
+	size       int64
+	count      int
+}
+
+func newFileBuffer(path string, maxBytes int64) (*fileBuffer, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &fileBuffer{
+		path:     path,
+		f:        f,
+		maxBytes: maxBytes,
+	}
+
+	// Count the entries left over from a previous run, dropping a partially
+	// written entry at the end
+	for {
+		length, err := b.readLength(b.size)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if b.size+4+int64(length) > info.Size() {
+			break
+		}
+		b.size += 4 + int64(length)
+		b.count++
+	}
+	if err := f.Truncate(b.size); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (b *fileBuffer) readLength(offset int64) (uint32, error) {
+	var length [4]byte
+	if _, err := b.f.ReadAt(length[:], offset); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(length[:]), nil
+}
+
+func (b *fileBuffer) Append(entry []byte) error {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.size-b.readOffset+int64(len(entry))+4 > b.maxBytes {
+		return errBufferFull
+	}
+
+	record := make([]byte, 4+len(entry))
+	binary.BigEndian.PutUint32(record, uint32(len(entry)))
+	copy(record[4:], entry)
+	if _, err := b.f.WriteAt(record, b.size); err != nil {
+		return err
+	}
+
+	b.size += int64(len(record))
+	b.count++
+	return nil
+}
+
+func (b *fileBuffer) Peek(n int) ([][]byte, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	var entries [][]byte
+	offset := b.readOffset
+	for len(entries) < n && offset < b.size {
+		length, err := b.readLength(offset)
+		if err != nil {
+			return nil, err
+		}
+		entry := make([]byte, length)
+		if _, err := b.f.ReadAt(entry, offset+4); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+		offset += 4 + int64(length)
+	}
+
+	return entries, nil
+}
+
+func (b *fileBuffer) Drop(n int) error {
+	b.Lock()
+	defer b.Unlock()
+
+	for ; n > 0 && b.readOffset < b.size; n-- {
+		length, err := b.readLength(b.readOffset)
+		if err != nil {
+			return err
+		}
+		b.readOffset += 4 + int64(length)
+		b.count--
+	}
+
+	switch {
+	case b.readOffset == b.size:
+		b.readOffset, b.size = 0, 0
+		return b.f.Truncate(0)
+	case b.readOffset >= fileCompactThreshold:
+		return b.compact()
+	}
+	return nil
+}
+
+// compact rewrites the buffer file without the delivered entries
+func (b *fileBuffer) compact() error {
+	tmpPath := b.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(tmp, io.NewSectionReader(b.f, b.readOffset, b.size-b.readOffset)); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, b.path); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace audit buffer file: %v", err)
+	}
+
+	b.f.Close()
+	b.f = tmp
+	b.size -= b.readOffset
+	b.readOffset = 0
+	return nil
+}
+
+func (b *fileBuffer) Len() int {
+	b.Lock()
+	defer b.Unlock()
+	return b.count
+}
+
+func (b *fileBuffer) Close() error {
+	b.Lock()
+	defer b.Unlock()
+	return b.f.Close()
+}
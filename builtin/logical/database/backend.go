@@ -12,13 +12,17 @@ import (
 	"github.com/hashicorp/errwrap"
 	uuid "github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/queue"
 	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 	"github.com/hashicorp/vault/plugins/helper/database/dbutil"
 )
 
-const databaseConfigPath = "database/config/"
+const (
+	databaseConfigPath = "database/config/"
+	staticRolePath     = "static-role/"
+)
 
 type dbPluginInstance struct {
 	sync.RWMutex
@@ -46,6 +50,13 @@ func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend,
 	if err := b.Setup(ctx, conf); err != nil {
 		return nil, err
 	}
+
+	// The queue outlives the request that mounted the backend, so it gets its
+	// own context which is canceled when the backend is cleaned up
+	queueCtx, cancel := context.WithCancel(context.Background())
+	b.cancelQueue = cancel
+	go b.initQueue(queueCtx, conf)
+
 	return b, nil
 }
 
@@ -57,6 +68,7 @@ func Backend(conf *logical.BackendConfig) *databaseBackend {
 		PathsSpecial: &logical.Paths{
 			SealWrapStorage: []string{
 				"config/*",
+				"static-role/*",
 			},
 		},
 
@@ -65,7 +77,10 @@ func Backend(conf *logical.BackendConfig) *databaseBackend {
 			pathConfigurePluginConnection(&b),
 			pathListRoles(&b),
 			pathRoles(&b),
+			pathListStaticRoles(&b),
+			pathStaticRoles(&b),
 			pathCredsCreate(&b),
+			pathStaticCredsRead(&b),
 			pathResetConnection(&b),
 			pathRotateCredentials(&b),
 		},
@@ -73,13 +88,14 @@ func Backend(conf *logical.BackendConfig) *databaseBackend {
 		Secrets: []*framework.Secret{
 			secretCreds(&b),
 		},
-		Clean:       b.closeAllDBs,
+		Clean:       b.clean,
 		Invalidate:  b.invalidate,
 		BackendType: logical.TypeLogical,
 	}
 
 	b.logger = conf.Logger
 	b.connections = make(map[string]*dbPluginInstance)
+	b.credRotationQueue = queue.New()
 	return &b
 }
 
@@ -87,6 +103,14 @@ type databaseBackend struct {
 	connections map[string]*dbPluginInstance
 	logger      log.Logger
 
+	// credRotationQueue holds the static roles ordered by the time their
+	// password is next due to be rotated
+	credRotationQueue *queue.PriorityQueue
+	cancelQueue       context.CancelFunc
+
+	// staticRoleLock serializes the password rotations of static roles
+	staticRoleLock sync.Mutex
+
 	*framework.Backend
 	sync.RWMutex
 }
@@ -169,6 +193,25 @@ func (b *databaseBackend) Role(ctx context.Context, s logical.Storage, roleName
 	return &result, nil
 }
 
+// StaticRole returns the static role with the given name, or nil if it does not
+// exist
+func (b *databaseBackend) StaticRole(ctx context.Context, s logical.Storage, roleName string) (*roleEntry, error) {
+	entry, err := s.Get(ctx, staticRolePath+roleName)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result roleEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
 func (b *databaseBackend) invalidate(ctx context.Context, key string) {
 	switch {
 	case strings.HasPrefix(key, databaseConfigPath):
@@ -267,6 +310,14 @@ func (b *databaseBackend) CloseIfShutdown(db *dbPluginInstance, err error) {
 	}
 }
 
+// clean stops the rotation queue and closes all database connections
+func (b *databaseBackend) clean(ctx context.Context) {
+	if b.cancelQueue != nil {
+		b.cancelQueue()
+	}
+	b.closeAllDBs(ctx)
+}
+
 // closeAllDBs closes all connections from all database types
 func (b *databaseBackend) closeAllDBs(ctx context.Context) {
 	b.Lock()
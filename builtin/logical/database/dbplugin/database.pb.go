@@ -330,6 +330,7 @@ type Statements struct {
 	Revocation           []string `protobuf:"bytes,6,rep,name=revocation,proto3" json:"revocation,omitempty"`
 	Rollback             []string `protobuf:"bytes,7,rep,name=rollback,proto3" json:"rollback,omitempty"`
 	Renewal              []string `protobuf:"bytes,8,rep,name=renewal,proto3" json:"renewal,omitempty"`
+	Rotation             []string `protobuf:"bytes,9,rep,name=rotation,proto3" json:"rotation,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -420,6 +421,13 @@ func (m *Statements) GetRenewal() []string {
 	return nil
 }
 
+func (m *Statements) GetRotation() []string {
+	if m != nil {
+		return m.Rotation
+	}
+	return nil
+}
+
 type UsernameConfig struct {
 	DisplayName          string   `protobuf:"bytes,1,opt,name=DisplayName,proto3" json:"DisplayName,omitempty"`
 	RoleName             string   `protobuf:"bytes,2,opt,name=RoleName,proto3" json:"RoleName,omitempty"`
@@ -662,6 +670,147 @@ func (m *Empty) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_Empty proto.InternalMessageInfo
 
+type StaticUserConfig struct {
+	Username             string   `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Password             string   `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StaticUserConfig) Reset()         { *m = StaticUserConfig{} }
+func (m *StaticUserConfig) String() string { return proto.CompactTextString(m) }
+func (*StaticUserConfig) ProtoMessage()    {}
+func (*StaticUserConfig) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7bf7b4c7fef2f66e, []int{13}
+}
+
+func (m *StaticUserConfig) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StaticUserConfig.Unmarshal(m, b)
+}
+func (m *StaticUserConfig) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StaticUserConfig.Marshal(b, m, deterministic)
+}
+func (m *StaticUserConfig) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StaticUserConfig.Merge(m, src)
+}
+func (m *StaticUserConfig) XXX_Size() int {
+	return xxx_messageInfo_StaticUserConfig.Size(m)
+}
+func (m *StaticUserConfig) XXX_DiscardUnknown() {
+	xxx_messageInfo_StaticUserConfig.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StaticUserConfig proto.InternalMessageInfo
+
+func (m *StaticUserConfig) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *StaticUserConfig) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
+type SetCredentialsRequest struct {
+	Statements           *Statements       `protobuf:"bytes,1,opt,name=statements,proto3" json:"statements,omitempty"`
+	StaticUserConfig     *StaticUserConfig `protobuf:"bytes,2,opt,name=static_user_config,json=staticUserConfig,proto3" json:"static_user_config,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *SetCredentialsRequest) Reset()         { *m = SetCredentialsRequest{} }
+func (m *SetCredentialsRequest) String() string { return proto.CompactTextString(m) }
+func (*SetCredentialsRequest) ProtoMessage()    {}
+func (*SetCredentialsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7bf7b4c7fef2f66e, []int{14}
+}
+
+func (m *SetCredentialsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetCredentialsRequest.Unmarshal(m, b)
+}
+func (m *SetCredentialsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetCredentialsRequest.Marshal(b, m, deterministic)
+}
+func (m *SetCredentialsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetCredentialsRequest.Merge(m, src)
+}
+func (m *SetCredentialsRequest) XXX_Size() int {
+	return xxx_messageInfo_SetCredentialsRequest.Size(m)
+}
+func (m *SetCredentialsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetCredentialsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetCredentialsRequest proto.InternalMessageInfo
+
+func (m *SetCredentialsRequest) GetStatements() *Statements {
+	if m != nil {
+		return m.Statements
+	}
+	return nil
+}
+
+func (m *SetCredentialsRequest) GetStaticUserConfig() *StaticUserConfig {
+	if m != nil {
+		return m.StaticUserConfig
+	}
+	return nil
+}
+
+type SetCredentialsResponse struct {
+	Username             string   `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	Password             string   `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetCredentialsResponse) Reset()         { *m = SetCredentialsResponse{} }
+func (m *SetCredentialsResponse) String() string { return proto.CompactTextString(m) }
+func (*SetCredentialsResponse) ProtoMessage()    {}
+func (*SetCredentialsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7bf7b4c7fef2f66e, []int{15}
+}
+
+func (m *SetCredentialsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetCredentialsResponse.Unmarshal(m, b)
+}
+func (m *SetCredentialsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetCredentialsResponse.Marshal(b, m, deterministic)
+}
+func (m *SetCredentialsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetCredentialsResponse.Merge(m, src)
+}
+func (m *SetCredentialsResponse) XXX_Size() int {
+	return xxx_messageInfo_SetCredentialsResponse.Size(m)
+}
+func (m *SetCredentialsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetCredentialsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetCredentialsResponse proto.InternalMessageInfo
+
+func (m *SetCredentialsResponse) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *SetCredentialsResponse) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*InitializeRequest)(nil), "dbplugin.InitializeRequest")
 	proto.RegisterType((*InitRequest)(nil), "dbplugin.InitRequest")
@@ -676,6 +825,9 @@ func init() {
 	proto.RegisterType((*TypeResponse)(nil), "dbplugin.TypeResponse")
 	proto.RegisterType((*RotateRootCredentialsResponse)(nil), "dbplugin.RotateRootCredentialsResponse")
 	proto.RegisterType((*Empty)(nil), "dbplugin.Empty")
+	proto.RegisterType((*StaticUserConfig)(nil), "dbplugin.StaticUserConfig")
+	proto.RegisterType((*SetCredentialsRequest)(nil), "dbplugin.SetCredentialsRequest")
+	proto.RegisterType((*SetCredentialsResponse)(nil), "dbplugin.SetCredentialsResponse")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -695,6 +847,7 @@ type DatabaseClient interface {
 	RenewUser(ctx context.Context, in *RenewUserRequest, opts ...grpc.CallOption) (*Empty, error)
 	RevokeUser(ctx context.Context, in *RevokeUserRequest, opts ...grpc.CallOption) (*Empty, error)
 	RotateRootCredentials(ctx context.Context, in *RotateRootCredentialsRequest, opts ...grpc.CallOption) (*RotateRootCredentialsResponse, error)
+	SetCredentials(ctx context.Context, in *SetCredentialsRequest, opts ...grpc.CallOption) (*SetCredentialsResponse, error)
 	Init(ctx context.Context, in *InitRequest, opts ...grpc.CallOption) (*InitResponse, error)
 	Close(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error)
 	Initialize(ctx context.Context, in *InitializeRequest, opts ...grpc.CallOption) (*Empty, error)
@@ -753,6 +906,15 @@ func (c *databaseClient) RotateRootCredentials(ctx context.Context, in *RotateRo
 	return out, nil
 }
 
+func (c *databaseClient) SetCredentials(ctx context.Context, in *SetCredentialsRequest, opts ...grpc.CallOption) (*SetCredentialsResponse, error) {
+	out := new(SetCredentialsResponse)
+	err := c.cc.Invoke(ctx, "/dbplugin.Database/SetCredentials", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *databaseClient) Init(ctx context.Context, in *InitRequest, opts ...grpc.CallOption) (*InitResponse, error) {
 	out := new(InitResponse)
 	err := c.cc.Invoke(ctx, "/dbplugin.Database/Init", in, out, opts...)
@@ -788,6 +950,7 @@ type DatabaseServer interface {
 	RenewUser(context.Context, *RenewUserRequest) (*Empty, error)
 	RevokeUser(context.Context, *RevokeUserRequest) (*Empty, error)
 	RotateRootCredentials(context.Context, *RotateRootCredentialsRequest) (*RotateRootCredentialsResponse, error)
+	SetCredentials(context.Context, *SetCredentialsRequest) (*SetCredentialsResponse, error)
 	Init(context.Context, *InitRequest) (*InitResponse, error)
 	Close(context.Context, *Empty) (*Empty, error)
 	Initialize(context.Context, *InitializeRequest) (*Empty, error)
@@ -887,6 +1050,24 @@ func _Database_RotateRootCredentials_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Database_SetCredentials_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetCredentialsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatabaseServer).SetCredentials(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dbplugin.Database/SetCredentials",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatabaseServer).SetCredentials(ctx, req.(*SetCredentialsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Database_Init_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(InitRequest)
 	if err := dec(in); err != nil {
@@ -965,6 +1146,10 @@ var _Database_serviceDesc = grpc.ServiceDesc{
 			MethodName: "RotateRootCredentials",
 			Handler:    _Database_RotateRootCredentials_Handler,
 		},
+		{
+			MethodName: "SetCredentials",
+			Handler:    _Database_SetCredentials_Handler,
+		},
 		{
 			MethodName: "Init",
 			Handler:    _Database_Init_Handler,
@@ -987,51 +1172,56 @@ func init() {
 }
 
 var fileDescriptor_7bf7b4c7fef2f66e = []byte{
-	// 724 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x55, 0xd1, 0x4e, 0xdb, 0x4a,
-	0x10, 0x95, 0x93, 0x00, 0xc9, 0x80, 0x80, 0xec, 0x05, 0x64, 0xf9, 0x72, 0x6f, 0x91, 0x1f, 0x28,
-	0x55, 0xd5, 0xb8, 0x82, 0x56, 0x54, 0xa8, 0xa2, 0x2a, 0xa1, 0xaa, 0x2a, 0x55, 0x3c, 0x2c, 0xf0,
-	0x52, 0x55, 0x42, 0x1b, 0x67, 0x49, 0x56, 0x38, 0x5e, 0xd7, 0xbb, 0x0e, 0x4d, 0x7f, 0xa0, 0xfd,
-	0x8c, 0x7e, 0x4e, 0x1f, 0xfb, 0x49, 0x95, 0x37, 0x59, 0xef, 0x26, 0x86, 0xf2, 0x40, 0xfb, 0xe6,
-	0xd9, 0x99, 0x33, 0x73, 0xe6, 0x78, 0x76, 0x16, 0x9e, 0x76, 0x32, 0x16, 0x49, 0x16, 0x07, 0x11,
-	0xef, 0xb1, 0x90, 0x44, 0x41, 0x97, 0x48, 0xd2, 0x21, 0x82, 0x06, 0xdd, 0x4e, 0x12, 0x65, 0x3d,
-	0x16, 0x17, 0x27, 0xad, 0x24, 0xe5, 0x92, 0xa3, 0xba, 0x76, 0x78, 0x0f, 0x7a, 0x9c, 0xf7, 0x22,
-	0x1a, 0xa8, 0xf3, 0x4e, 0x76, 0x19, 0x48, 0x36, 0xa0, 0x42, 0x92, 0x41, 0x32, 0x0e, 0xf5, 0x3f,
-	0x42, 0xf3, 0x5d, 0xcc, 0x24, 0x23, 0x11, 0xfb, 0x42, 0x31, 0xfd, 0x94, 0x51, 0x21, 0xd1, 0x06,
-	0xcc, 0x87, 0x3c, 0xbe, 0x64, 0x3d, 0xd7, 0xd9, 0x72, 0x76, 0x96, 0xf0, 0xc4, 0x42, 0x8f, 0xa1,
-	0x39, 0xa4, 0x29, 0xbb, 0x1c, 0x5d, 0x84, 0x3c, 0x8e, 0x69, 0x28, 0x19, 0x8f, 0xdd, 0xca, 0x96,
-	0xb3, 0x53, 0xc7, 0xab, 0x63, 0x47, 0xbb, 0x38, 0x3f, 0xa8, 0xb8, 0x8e, 0x8f, 0x61, 0x31, 0xcf,
-	0xfe, 0x27, 0xf3, 0xfa, 0x3f, 0x1c, 0x68, 0xb6, 0x53, 0x4a, 0x24, 0x3d, 0x17, 0x34, 0xd5, 0xa9,
-	0x9f, 0x01, 0x08, 0x49, 0x24, 0x1d, 0xd0, 0x58, 0x0a, 0x95, 0x7e, 0x71, 0x77, 0xad, 0xa5, 0x75,
-	0x68, 0x9d, 0x16, 0x3e, 0x6c, 0xc5, 0xa1, 0xd7, 0xb0, 0x92, 0x09, 0x9a, 0xc6, 0x64, 0x40, 0x2f,
-	0x26, 0xcc, 0x2a, 0x0a, 0xea, 0x1a, 0xe8, 0xf9, 0x24, 0xa0, 0xad, 0xfc, 0x78, 0x39, 0x9b, 0xb2,
-	0xd1, 0x01, 0x00, 0xfd, 0x9c, 0xb0, 0x94, 0x28, 0xd2, 0x55, 0x85, 0xf6, 0x5a, 0x63, 0xd9, 0x5b,
-	0x5a, 0xf6, 0xd6, 0x99, 0x96, 0x1d, 0x5b, 0xd1, 0xfe, 0x77, 0x07, 0x56, 0x31, 0x8d, 0xe9, 0xf5,
-	0xfd, 0x3b, 0xf1, 0xa0, 0xae, 0x89, 0xa9, 0x16, 0x1a, 0xb8, 0xb0, 0xef, 0x45, 0x91, 0x42, 0x13,
-	0xd3, 0x21, 0xbf, 0xa2, 0x7f, 0x95, 0xa2, 0x7f, 0x08, 0x9b, 0x98, 0xe7, 0xa1, 0x98, 0x73, 0xd9,
-	0x4e, 0x69, 0x97, 0xc6, 0xf9, 0x4c, 0x0a, 0x5d, 0xf1, 0xff, 0x99, 0x8a, 0xd5, 0x9d, 0x86, 0x9d,
-	0xdb, 0xff, 0x59, 0x01, 0x30, 0x65, 0xd1, 0x1e, 0xfc, 0x13, 0xe6, 0x23, 0xc2, 0x78, 0x7c, 0x31,
-	0xc3, 0xb4, 0x71, 0x54, 0x71, 0x1d, 0x8c, 0xb4, 0xdb, 0x02, 0xed, 0xc3, 0x7a, 0x4a, 0x87, 0x3c,
-	0x2c, 0xc1, 0x2a, 0x05, 0x6c, 0xcd, 0x04, 0x4c, 0x57, 0x4b, 0x79, 0x14, 0x75, 0x48, 0x78, 0x65,
-	0xc3, 0xaa, 0xa6, 0x9a, 0x76, 0x5b, 0xa0, 0x27, 0xb0, 0x9a, 0xe6, 0xbf, 0xde, 0x46, 0xd4, 0x0a,
-	0xc4, 0x8a, 0xf2, 0x9d, 0x4e, 0x89, 0xa7, 0x29, 0xbb, 0x73, 0xaa, 0xfd, 0xc2, 0xce, 0xc5, 0x31,
-	0xbc, 0xdc, 0xf9, 0xb1, 0x38, 0xe6, 0x24, 0xc7, 0x6a, 0x02, 0xee, 0xc2, 0x18, 0xab, 0x6d, 0xe4,
-	0xc2, 0x82, 0x2a, 0x45, 0x22, 0xb7, 0xae, 0x5c, 0xda, 0xf4, 0x4f, 0x60, 0x79, 0x7a, 0xf4, 0xd1,
-	0x16, 0x2c, 0x1e, 0x33, 0x91, 0x44, 0x64, 0x74, 0x92, 0xff, 0x43, 0xa5, 0x26, 0xb6, 0x8f, 0xf2,
-	0x4a, 0x98, 0x47, 0xf4, 0xc4, 0xfa, 0xc5, 0xda, 0xf6, 0xb7, 0x61, 0x69, 0xbc, 0x0b, 0x44, 0xc2,
-	0x63, 0x41, 0x6f, 0x5b, 0x06, 0xfe, 0x7b, 0x40, 0xf6, 0xf5, 0x9e, 0x44, 0xdb, 0xc3, 0xe3, 0xcc,
-	0xcc, 0xb7, 0x07, 0xf5, 0x84, 0x08, 0x71, 0xcd, 0xd3, 0xae, 0xae, 0xaa, 0x6d, 0xdf, 0x87, 0xa5,
-	0xb3, 0x51, 0x42, 0x8b, 0x3c, 0x08, 0x6a, 0x72, 0x94, 0xe8, 0x1c, 0xea, 0xdb, 0xdf, 0x87, 0xff,
-	0x6e, 0x19, 0xbe, 0x3b, 0xa8, 0x2e, 0xc0, 0xdc, 0x9b, 0x41, 0x22, 0x47, 0xbb, 0x5f, 0x6b, 0x50,
-	0x3f, 0x9e, 0xec, 0x60, 0x14, 0x40, 0x2d, 0x2f, 0x89, 0x56, 0xcc, 0x8d, 0x50, 0x51, 0xde, 0x86,
-	0x39, 0x98, 0xe2, 0xf4, 0x16, 0xc0, 0x74, 0x8c, 0xfe, 0x35, 0x51, 0xa5, 0x35, 0xe7, 0x6d, 0xde,
-	0xec, 0x9c, 0x24, 0x7a, 0x01, 0x8d, 0x62, 0x9d, 0x20, 0xcf, 0x84, 0xce, 0xee, 0x18, 0x6f, 0x96,
-	0x5a, 0xbe, 0x22, 0xcc, 0x35, 0xb7, 0x29, 0x94, 0x2e, 0x7f, 0x19, 0xdb, 0x87, 0xf5, 0x1b, 0xe5,
-	0x43, 0xdb, 0x56, 0x9a, 0xdf, 0x5c, 0x6e, 0xef, 0xe1, 0x9d, 0x71, 0x93, 0xfe, 0x9e, 0x43, 0x2d,
-	0x1f, 0x21, 0xb4, 0x6e, 0x00, 0xd6, 0xf3, 0x62, 0xeb, 0x3b, 0x35, 0x69, 0x8f, 0x60, 0xae, 0x1d,
-	0x71, 0x71, 0xc3, 0x1f, 0x29, 0xf5, 0xf2, 0x0a, 0xc0, 0x3c, 0x87, 0xb6, 0x0e, 0xa5, 0x47, 0xb2,
-	0x84, 0xf5, 0xab, 0xdf, 0x2a, 0xce, 0xd1, 0xe1, 0x87, 0x97, 0x3d, 0x26, 0xfb, 0x59, 0xa7, 0x15,
-	0xf2, 0x41, 0xd0, 0x27, 0xa2, 0xcf, 0x42, 0x9e, 0x26, 0xc1, 0x90, 0x64, 0x91, 0x0c, 0xee, 0x7c,
-	0xc9, 0x3b, 0xf3, 0x6a, 0x1f, 0xef, 0xfd, 0x0a, 0x00, 0x00, 0xff, 0xff, 0x9c, 0x49, 0x0b, 0x5b,
-	0xf5, 0x07, 0x00, 0x00,
+	// 808 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x56, 0xdd, 0x6e, 0xeb, 0x44,
+	0x10, 0x96, 0x93, 0xb4, 0x4d, 0xa6, 0x55, 0x9b, 0x2c, 0x27, 0x91, 0x65, 0x0e, 0x9c, 0xc8, 0x17,
+	0x87, 0x22, 0x44, 0x8c, 0x4e, 0x41, 0x45, 0x15, 0x2a, 0xa2, 0x29, 0xe2, 0x47, 0xa8, 0x42, 0x9b,
+	0xf6, 0x06, 0x21, 0x45, 0x1b, 0x67, 0x9b, 0xac, 0xea, 0x78, 0x8d, 0x77, 0x9d, 0x12, 0x9e, 0x80,
+	0x37, 0xe0, 0x96, 0xc7, 0xe1, 0x21, 0x78, 0x04, 0x1e, 0x02, 0x79, 0xed, 0xb5, 0xd7, 0x49, 0x4a,
+	0xa5, 0x96, 0x73, 0xe7, 0xf9, 0xf9, 0x66, 0xbe, 0x9d, 0x99, 0x1d, 0x2f, 0x7c, 0x32, 0x49, 0x58,
+	0x20, 0x59, 0xe8, 0x05, 0x7c, 0xc6, 0x7c, 0x12, 0x78, 0x53, 0x22, 0xc9, 0x84, 0x08, 0xea, 0x4d,
+	0x27, 0x51, 0x90, 0xcc, 0x58, 0x58, 0x68, 0x06, 0x51, 0xcc, 0x25, 0x47, 0x4d, 0x6d, 0x70, 0x5e,
+	0xcd, 0x38, 0x9f, 0x05, 0xd4, 0x53, 0xfa, 0x49, 0x72, 0xeb, 0x49, 0xb6, 0xa0, 0x42, 0x92, 0x45,
+	0x94, 0xb9, 0xba, 0x3f, 0x43, 0xe7, 0xbb, 0x90, 0x49, 0x46, 0x02, 0xf6, 0x1b, 0xc5, 0xf4, 0x97,
+	0x84, 0x0a, 0x89, 0x7a, 0xb0, 0xeb, 0xf3, 0xf0, 0x96, 0xcd, 0x6c, 0xab, 0x6f, 0x1d, 0x1f, 0xe0,
+	0x5c, 0x42, 0x1f, 0x41, 0x67, 0x49, 0x63, 0x76, 0xbb, 0x1a, 0xfb, 0x3c, 0x0c, 0xa9, 0x2f, 0x19,
+	0x0f, 0xed, 0x5a, 0xdf, 0x3a, 0x6e, 0xe2, 0x76, 0x66, 0x18, 0x16, 0xfa, 0xb3, 0x9a, 0x6d, 0xb9,
+	0x18, 0xf6, 0xd3, 0xe8, 0xff, 0x67, 0x5c, 0xf7, 0x2f, 0x0b, 0x3a, 0xc3, 0x98, 0x12, 0x49, 0x6f,
+	0x04, 0x8d, 0x75, 0xe8, 0x4f, 0x01, 0x84, 0x24, 0x92, 0x2e, 0x68, 0x28, 0x85, 0x0a, 0xbf, 0xff,
+	0xe6, 0xc5, 0x40, 0xd7, 0x61, 0x30, 0x2a, 0x6c, 0xd8, 0xf0, 0x43, 0x5f, 0xc1, 0x51, 0x22, 0x68,
+	0x1c, 0x92, 0x05, 0x1d, 0xe7, 0xcc, 0x6a, 0x0a, 0x6a, 0x97, 0xd0, 0x9b, 0xdc, 0x61, 0xa8, 0xec,
+	0xf8, 0x30, 0xa9, 0xc8, 0xe8, 0x0c, 0x80, 0xfe, 0x1a, 0xb1, 0x98, 0x28, 0xd2, 0x75, 0x85, 0x76,
+	0x06, 0x59, 0xd9, 0x07, 0xba, 0xec, 0x83, 0x6b, 0x5d, 0x76, 0x6c, 0x78, 0xbb, 0x7f, 0x5a, 0xd0,
+	0xc6, 0x34, 0xa4, 0xf7, 0xcf, 0x3f, 0x89, 0x03, 0x4d, 0x4d, 0x4c, 0x1d, 0xa1, 0x85, 0x0b, 0xf9,
+	0x59, 0x14, 0x29, 0x74, 0x30, 0x5d, 0xf2, 0x3b, 0xfa, 0x56, 0x29, 0xba, 0xe7, 0xf0, 0x12, 0xf3,
+	0xd4, 0x15, 0x73, 0x2e, 0x87, 0x31, 0x9d, 0xd2, 0x30, 0x9d, 0x49, 0xa1, 0x33, 0xbe, 0xbf, 0x96,
+	0xb1, 0x7e, 0xdc, 0x32, 0x63, 0xbb, 0xff, 0xd4, 0x00, 0xca, 0xb4, 0xe8, 0x04, 0xde, 0xf1, 0xd3,
+	0x11, 0x61, 0x3c, 0x1c, 0xaf, 0x31, 0x6d, 0x5d, 0xd4, 0x6c, 0x0b, 0x23, 0x6d, 0x36, 0x40, 0xa7,
+	0xd0, 0x8d, 0xe9, 0x92, 0xfb, 0x1b, 0xb0, 0x5a, 0x01, 0x7b, 0x51, 0x3a, 0x54, 0xb3, 0xc5, 0x3c,
+	0x08, 0x26, 0xc4, 0xbf, 0x33, 0x61, 0xf5, 0x32, 0x9b, 0x36, 0x1b, 0xa0, 0x8f, 0xa1, 0x1d, 0xa7,
+	0xad, 0x37, 0x11, 0x8d, 0x02, 0x71, 0xa4, 0x6c, 0xa3, 0x4a, 0xf1, 0x34, 0x65, 0x7b, 0x47, 0x1d,
+	0xbf, 0x90, 0xd3, 0xe2, 0x94, 0xbc, 0xec, 0xdd, 0xac, 0x38, 0xa5, 0x26, 0xc5, 0x6a, 0x02, 0xf6,
+	0x5e, 0x86, 0xd5, 0x32, 0xb2, 0x61, 0x4f, 0xa5, 0x22, 0x81, 0xdd, 0x54, 0x26, 0x2d, 0x66, 0x28,
+	0x99, 0xc5, 0x6c, 0x69, 0x54, 0x26, 0xbb, 0x57, 0x70, 0x58, 0xbd, 0x16, 0xa8, 0x0f, 0xfb, 0x97,
+	0x4c, 0x44, 0x01, 0x59, 0x5d, 0xa5, 0xfd, 0x55, 0x95, 0xc6, 0xa6, 0x2a, 0x8d, 0x87, 0x79, 0x40,
+	0xaf, 0x8c, 0xf6, 0x6b, 0xd9, 0x7d, 0x0d, 0x07, 0xd9, 0x9e, 0x10, 0x11, 0x0f, 0x05, 0x7d, 0x68,
+	0x51, 0xb8, 0x3f, 0x00, 0x32, 0xaf, 0x7e, 0xee, 0x6d, 0x0e, 0x96, 0xb5, 0x36, 0xfb, 0x0e, 0x34,
+	0x23, 0x22, 0xc4, 0x3d, 0x8f, 0xa7, 0x3a, 0xab, 0x96, 0x5d, 0x17, 0x0e, 0xae, 0x57, 0x11, 0x2d,
+	0xe2, 0x20, 0x68, 0xc8, 0x55, 0xa4, 0x63, 0xa8, 0x6f, 0xf7, 0x14, 0xde, 0x7b, 0x60, 0x30, 0x1f,
+	0xa1, 0xba, 0x07, 0x3b, 0x5f, 0x2f, 0x22, 0xb9, 0x72, 0xbf, 0x87, 0x76, 0xda, 0x47, 0xe6, 0xa7,
+	0x9c, 0xf3, 0x6a, 0x3d, 0x95, 0xf1, 0x1f, 0x16, 0x74, 0x47, 0x74, 0xdb, 0x05, 0x79, 0xda, 0x95,
+	0xfc, 0x16, 0x90, 0x50, 0xdc, 0xc6, 0x69, 0xfa, 0xea, 0x0a, 0x74, 0xaa, 0x68, 0x93, 0x3f, 0x6e,
+	0x8b, 0x35, 0x8d, 0xfb, 0x23, 0xf4, 0x46, 0x74, 0x6b, 0x81, 0x9e, 0x78, 0xd6, 0x37, 0x7f, 0x37,
+	0xa0, 0x79, 0x99, 0xff, 0xd7, 0x90, 0x07, 0x8d, 0xb4, 0x55, 0xe8, 0xa8, 0x24, 0xa5, 0xaa, 0xeb,
+	0xf4, 0x4a, 0x45, 0xa5, 0x97, 0xdf, 0x00, 0x94, 0x93, 0x82, 0xde, 0x2d, 0xbd, 0x36, 0x7e, 0x1d,
+	0xce, 0xcb, 0xed, 0xc6, 0x3c, 0xd0, 0xe7, 0xd0, 0x2a, 0x56, 0x34, 0x32, 0x6a, 0xb2, 0xbe, 0xb7,
+	0x9d, 0x75, 0x6a, 0xe9, 0xda, 0x2d, 0x57, 0xa7, 0x49, 0x61, 0x63, 0xa1, 0x6e, 0x62, 0xe7, 0xd0,
+	0xdd, 0x3a, 0x76, 0xe8, 0xb5, 0x11, 0xe6, 0x3f, 0x16, 0xa6, 0xf3, 0xc1, 0xa3, 0x7e, 0xf9, 0xf9,
+	0x46, 0x70, 0x58, 0x6d, 0x1c, 0x7a, 0x65, 0x34, 0x7e, 0xdb, 0xac, 0x39, 0xfd, 0x87, 0x1d, 0xf2,
+	0xa0, 0x9f, 0x41, 0x23, 0xbd, 0xcf, 0xa8, 0x5b, 0x7a, 0x1a, 0xef, 0x00, 0xa7, 0xb7, 0xae, 0xce,
+	0x61, 0x1f, 0xc2, 0xce, 0x30, 0xe0, 0x62, 0x4b, 0x9b, 0x37, 0x0a, 0xf4, 0x25, 0x40, 0xf9, 0x6e,
+	0x31, 0x8b, 0xbb, 0xf1, 0x9a, 0xd9, 0xc0, 0xba, 0xf5, 0xdf, 0x6b, 0xd6, 0xc5, 0xf9, 0x4f, 0x5f,
+	0xcc, 0x98, 0x9c, 0x27, 0x93, 0x81, 0xcf, 0x17, 0xde, 0x9c, 0x88, 0x39, 0xf3, 0x79, 0x1c, 0x79,
+	0x4b, 0x92, 0x04, 0xd2, 0x7b, 0xf4, 0xc9, 0x35, 0xd9, 0x55, 0x3f, 0xce, 0x93, 0x7f, 0x07, 0x00,
+	0xec, 0x4a, 0x20, 0x89, 0x9e, 0x09, 0x00, 0x00,
 }
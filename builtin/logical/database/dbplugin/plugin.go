@@ -24,6 +24,12 @@ type Database interface {
 
 	RotateRootCredentials(ctx context.Context, statements []string) (config map[string]interface{}, err error)
 
+	// SetCredentials sets the password of an existing user to the one given
+	// in the static user config, using the rotation statements. It returns
+	// the credentials that were set. Plugins that don't support static roles
+	// return ErrPluginStaticUnsupported.
+	SetCredentials(ctx context.Context, statements Statements, staticConfig StaticUserConfig) (username string, password string, err error)
+
 	Init(ctx context.Context, config map[string]interface{}, verifyConnection bool) (saveConfig map[string]interface{}, err error)
 	Close() error
 
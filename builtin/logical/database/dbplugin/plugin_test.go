@@ -66,6 +66,15 @@ func (m *mockPlugin) RevokeUser(_ context.Context, statements dbplugin.Statement
 func (m *mockPlugin) RotateRootCredentials(_ context.Context, statements []string) (map[string]interface{}, error) {
 	return nil, nil
 }
+func (m *mockPlugin) SetCredentials(_ context.Context, statements dbplugin.Statements, staticConfig dbplugin.StaticUserConfig) (string, string, error) {
+	if staticConfig.Username == "" || staticConfig.Password == "" {
+		return "", "", errors.New("err")
+	}
+
+	m.users[staticConfig.Username] = []string{staticConfig.Password}
+
+	return staticConfig.Username, staticConfig.Password, nil
+}
 func (m *mockPlugin) Init(_ context.Context, conf map[string]interface{}, _ bool) (map[string]interface{}, error) {
 	err := errors.New("err")
 	if len(conf) != 1 {
@@ -285,6 +294,44 @@ func TestPlugin_RevokeUser(t *testing.T) {
 	}
 }
 
+func TestPlugin_SetCredentials(t *testing.T) {
+	cluster, sys := getCluster(t)
+	defer cluster.Cleanup()
+
+	db, err := dbplugin.PluginFactory(namespace.RootContext(nil), "test-plugin", sys, log.NewNullLogger())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	connectionDetails := map[string]interface{}{
+		"test": 1,
+	}
+	_, err = db.Init(context.Background(), connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	staticConfig := dbplugin.StaticUserConfig{
+		Username: "test",
+		Password: "secret",
+	}
+
+	us, pw, err := db.SetCredentials(context.Background(), dbplugin.Statements{}, staticConfig)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if us != "test" || pw != "secret" {
+		t.Fatalf("expected credentials to be 'test'/'secret', got %q/%q", us, pw)
+	}
+
+	// A password is required
+	_, _, err = db.SetCredentials(context.Background(), dbplugin.Statements{}, dbplugin.StaticUserConfig{Username: "test"})
+	if err == nil {
+		t.Fatal("expected an error setting credentials without a password")
+	}
+}
+
 // Test the code is still compatible with an old netRPC plugin
 func TestPlugin_NetRPC_Init(t *testing.T) {
 	cluster, sys := getCluster(t)
@@ -249,10 +249,41 @@ func (b *databaseBackend) pathRoleCreateUpdate() framework.OperationFunc {
 }
 
 type roleEntry struct {
-	DBName     string              `json:"db_name"`
-	Statements dbplugin.Statements `json:"statements"`
-	DefaultTTL time.Duration       `json:"default_ttl"`
-	MaxTTL     time.Duration       `json:"max_ttl"`
+	DBName        string              `json:"db_name"`
+	Statements    dbplugin.Statements `json:"statements"`
+	DefaultTTL    time.Duration       `json:"default_ttl"`
+	MaxTTL        time.Duration       `json:"max_ttl"`
+	StaticAccount *staticAccount      `json:"static_account" mapstructure:"static_account"`
+}
+
+// staticAccount is an existing database user whose password is rotated by
+// Vault
+type staticAccount struct {
+	// Username is the name of the database user
+	Username string `json:"username"`
+
+	// Password is the current password of the database user
+	Password string `json:"password"`
+
+	// LastVaultRotation is the time the password was last rotated by Vault
+	LastVaultRotation time.Time `json:"last_vault_rotation"`
+
+	// RotationPeriod is how often the password is rotated
+	RotationPeriod time.Duration `json:"rotation_period"`
+}
+
+// NextRotationTime returns the time the password is next due to be rotated
+func (s *staticAccount) NextRotationTime() time.Time {
+	return s.LastVaultRotation.Add(s.RotationPeriod)
+}
+
+// PasswordTTL returns how long the current password remains valid
+func (s *staticAccount) PasswordTTL() time.Duration {
+	ttl := s.NextRotationTime().Sub(time.Now())
+	if ttl < 0 {
+		return 0
+	}
+	return ttl
 }
 
 const pathRoleHelpSyn = `
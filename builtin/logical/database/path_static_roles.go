@@ -0,0 +1,300 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/helper/queue"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// minRotationPeriod is the shortest rotation period a static role can have,
+// since the rotation queue is only checked every few seconds
+const minRotationPeriod = 5 * time.Second
+
+func pathListStaticRoles(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "static-roles/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathStaticRoleList(),
+		},
+
+		HelpSynopsis:    pathStaticRoleHelpSyn,
+		HelpDescription: pathStaticRoleHelpDesc,
+	}
+}
+
+func pathStaticRoles(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "static-roles/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+
+			"db_name": {
+				Type:        framework.TypeString,
+				Description: "Name of the database this role acts on.",
+			},
+			"username": {
+				Type: framework.TypeString,
+				Description: `Name of the static user account for Vault to manage.
+				The user must already exist in the database.`,
+			},
+			"rotation_period": {
+				Type: framework.TypeDurationSecond,
+				Description: `Period for automatic credential rotation of the
+				given username. Must be at least 5 seconds.`,
+			},
+			"rotation_statements": {
+				Type: framework.TypeStringSlice,
+				Description: `Specifies the database statements to be executed
+				to rotate the password of the user. See the plugin's API page for
+				more information on support and formatting for this parameter.`,
+			},
+		},
+
+		ExistenceCheck: b.pathStaticRoleExistenceCheck(),
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathStaticRoleRead(),
+			logical.CreateOperation: b.pathStaticRoleCreateUpdate(),
+			logical.UpdateOperation: b.pathStaticRoleCreateUpdate(),
+			logical.DeleteOperation: b.pathStaticRoleDelete(),
+		},
+
+		HelpSynopsis:    pathStaticRoleHelpSyn,
+		HelpDescription: pathStaticRoleHelpDesc,
+	}
+}
+
+func (b *databaseBackend) pathStaticRoleExistenceCheck() framework.ExistenceFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
+		role, err := b.StaticRole(ctx, req.Storage, data.Get("name").(string))
+		if err != nil {
+			return false, err
+		}
+
+		return role != nil, nil
+	}
+}
+
+func (b *databaseBackend) pathStaticRoleDelete() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := data.Get("name").(string)
+
+		// Remove the role from the queue first so that it isn't rotated
+		// while being deleted
+		if _, err := b.popFromRotationQueueByKey(name); err != nil {
+			return nil, err
+		}
+
+		b.staticRoleLock.Lock()
+		defer b.staticRoleLock.Unlock()
+
+		if err := req.Storage.Delete(ctx, staticRolePath+name); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+}
+
+func (b *databaseBackend) pathStaticRoleRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		role, err := b.StaticRole(ctx, req.Storage, d.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		if role == nil || role.StaticAccount == nil {
+			return nil, nil
+		}
+
+		data := map[string]interface{}{
+			"db_name":             role.DBName,
+			"username":            role.StaticAccount.Username,
+			"rotation_period":     role.StaticAccount.RotationPeriod.Seconds(),
+			"rotation_statements": role.Statements.Rotation,
+			"last_vault_rotation": role.StaticAccount.LastVaultRotation,
+		}
+		if len(role.Statements.Rotation) == 0 {
+			data["rotation_statements"] = []string{}
+		}
+
+		return &logical.Response{
+			Data: data,
+		}, nil
+	}
+}
+
+func (b *databaseBackend) pathStaticRoleList() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		entries, err := req.Storage.List(ctx, staticRolePath)
+		if err != nil {
+			return nil, err
+		}
+
+		return logical.ListResponse(entries), nil
+	}
+}
+
+func (b *databaseBackend) pathStaticRoleCreateUpdate() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := data.Get("name").(string)
+		if name == "" {
+			return logical.ErrorResponse("empty role name attribute given"), nil
+		}
+
+		role, err := b.StaticRole(ctx, req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			role = &roleEntry{}
+		}
+		if role.StaticAccount == nil {
+			role.StaticAccount = &staticAccount{}
+		}
+
+		// DB Attributes
+		{
+			if dbNameRaw, ok := data.GetOk("db_name"); ok {
+				role.DBName = dbNameRaw.(string)
+			} else if req.Operation == logical.CreateOperation {
+				role.DBName = data.Get("db_name").(string)
+			}
+			if role.DBName == "" {
+				return logical.ErrorResponse("empty database name attribute"), nil
+			}
+		}
+
+		// Static account
+		{
+			if usernameRaw, ok := data.GetOk("username"); ok {
+				username := usernameRaw.(string)
+				if req.Operation == logical.UpdateOperation && username != role.StaticAccount.Username {
+					return logical.ErrorResponse("cannot update static account username"), nil
+				}
+				role.StaticAccount.Username = username
+			}
+			if role.StaticAccount.Username == "" {
+				return logical.ErrorResponse("username is a required field to create a static account"), nil
+			}
+
+			if rotationPeriodRaw, ok := data.GetOk("rotation_period"); ok {
+				role.StaticAccount.RotationPeriod = time.Duration(rotationPeriodRaw.(int)) * time.Second
+			} else if req.Operation == logical.CreateOperation {
+				return logical.ErrorResponse("rotation_period is required to create static accounts"), nil
+			}
+			if role.StaticAccount.RotationPeriod < minRotationPeriod {
+				return logical.ErrorResponse(fmt.Sprintf("rotation_period must be %d seconds or more", int(minRotationPeriod.Seconds()))), nil
+			}
+		}
+
+		// Statements
+		{
+			if rotationStmtsRaw, ok := data.GetOk("rotation_statements"); ok {
+				role.Statements.Rotation = rotationStmtsRaw.([]string)
+			} else if req.Operation == logical.CreateOperation {
+				role.Statements.Rotation = data.Get("rotation_statements").([]string)
+			}
+		}
+
+		// The role is taken out of the queue while it is being written, and
+		// put back with the time of its next rotation
+		item, err := b.popFromRotationQueueByKey(name)
+		if err != nil {
+			return nil, err
+		}
+		if item == nil {
+			item = &queue.Item{
+				Key: name,
+			}
+		}
+
+		switch req.Operation {
+		case logical.CreateOperation:
+			// The password of a new static account is rotated right away so
+			// that only Vault knows it
+			resp, err := b.setStaticAccount(ctx, req.Storage, &setStaticAccountInput{
+				RoleName: name,
+				Role:     role,
+			})
+			if err != nil {
+				if resp != nil && resp.WALID != "" {
+					framework.DeleteWAL(ctx, req.Storage, resp.WALID)
+				}
+				return nil, err
+			}
+			item.Value = ""
+			item.Priority = resp.NextRotationTime.Unix()
+
+		default:
+			b.staticRoleLock.Lock()
+			entry, err := logical.StorageEntryJSON(staticRolePath+name, role)
+			if err == nil {
+				err = req.Storage.Put(ctx, entry)
+			}
+			b.staticRoleLock.Unlock()
+			if err != nil {
+				b.pushItem(item)
+				return nil, err
+			}
+
+			// A pending retry keeps its schedule, otherwise the next rotation
+			// follows the possibly changed rotation period
+			if walID, ok := item.Value.(string); !ok || walID == "" {
+				item.Priority = role.StaticAccount.NextRotationTime().Unix()
+			}
+		}
+
+		if err := b.pushItem(item); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+}
+
+const pathStaticRoleHelpSyn = `
+Manage the static roles that can be created with this backend.
+`
+
+const pathStaticRoleHelpDesc = `
+This path lets you manage the static roles that can be created with this
+backend. Static roles are associated with a single database user, and manage
+the password of that user based on a rotation period, automatically rotating
+the password.
+
+The "db_name" parameter is required and configures the name of the database
+connection to use.
+
+The "username" parameter is required and configures the name of the existing
+database user to manage. It cannot be changed once the role has been created.
+
+The "rotation_period" parameter is required and configures how often, in
+seconds, the password of the user is rotated. It must be at least 5 seconds.
+
+The "rotation_statements" parameter customizes the statements used to rotate
+the password of the user. This can be a sequence of SQL queries, or other
+statement formats for a particular database type. Some substitution will be
+done to the statement strings for certain keys. The names of the variables must
+be surrounded by "{{" and "}}" to be replaced.
+
+  * "name" - The name of the database user.
+
+  * "username" - The name of the database user.
+
+  * "password" - The new password generated by Vault for the user.
+
+Example of a decent rotation_statements for a postgresql database plugin:
+
+	ALTER ROLE "{{name}}" WITH PASSWORD '{{password}}';
+
+The password of the user is rotated as soon as the role is created, so that
+only Vault knows it. The current password can be read at the "static-creds/"
+endpoint.
+`
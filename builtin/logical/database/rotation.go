@@ -0,0 +1,435 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/queue"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"github.com/hashicorp/vault/plugins/helper/database/credsutil"
+)
+
+const (
+	// staticWALKey is the kind of the WAL entries written before the password
+	// of a static account is changed in the database
+	staticWALKey = "staticRotationKey"
+
+	// queueTickInterval is how often the rotation queue is checked for
+	// static accounts that are due for rotation
+	queueTickInterval = 5 * time.Second
+
+	// rotationRetryInterval is how long a failed rotation waits before it is
+	// attempted again
+	rotationRetryInterval = 10 * time.Second
+)
+
+// setCredentialsWAL is the WAL entry written before the password of a static
+// account is changed. If Vault loses track of the rotation half way, the next
+// attempt reuses the password from the WAL so that the database and storage
+// can't end up out of sync.
+type setCredentialsWAL struct {
+	NewPassword       string    `json:"new_password"`
+	RoleName          string    `json:"role_name"`
+	Username          string    `json:"username"`
+	LastVaultRotation time.Time `json:"last_vault_rotation"`
+
+	walID string
+}
+
+// initQueue rebuilds the rotation queue from the static roles in storage and
+// starts processing it. Rotations are only performed where storage can be
+// written to, so nothing is done on performance secondaries (unless the mount
+// is local), DR secondaries, or performance standbys.
+func (b *databaseBackend) initQueue(ctx context.Context, conf *logical.BackendConfig) {
+	replicationState := conf.System.ReplicationState()
+	if (conf.System.LocalMount() || !replicationState.HasState(consts.ReplicationPerformanceSecondary)) &&
+		!replicationState.HasState(consts.ReplicationDRSecondary) &&
+		!replicationState.HasState(consts.ReplicationPerformanceStandby) {
+		b.Logger().Info("initializing database rotation queue")
+
+		if err := b.populateQueue(ctx, conf.StorageView); err != nil {
+			b.Logger().Error("error populating the rotation queue", "error", err)
+		}
+
+		go b.runTicker(ctx, conf.StorageView)
+	}
+}
+
+// populateQueue pushes every static role in storage to the rotation queue,
+// using any pending WAL entry so that interrupted rotations are resumed
+func (b *databaseBackend) populateQueue(ctx context.Context, s logical.Storage) error {
+	walMap, err := b.loadStaticWALs(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	roles, err := s.List(ctx, staticRolePath)
+	if err != nil {
+		return err
+	}
+
+	for _, roleName := range roles {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		role, err := b.StaticRole(ctx, s, roleName)
+		if err != nil {
+			b.Logger().Warn("unable to read static role", "error", err, "role", roleName)
+			continue
+		}
+		if role == nil || role.StaticAccount == nil {
+			continue
+		}
+
+		item := &queue.Item{
+			Key:      roleName,
+			Priority: role.StaticAccount.NextRotationTime().Unix(),
+		}
+
+		// An interrupted rotation is attempted again right away
+		if walEntry, ok := walMap[roleName]; ok {
+			item.Value = walEntry.walID
+			item.Priority = time.Now().Unix()
+		}
+
+		if err := b.pushItem(item); err != nil {
+			b.Logger().Warn("unable to enqueue static role", "error", err, "role", roleName)
+		}
+	}
+
+	return nil
+}
+
+// loadStaticWALs returns the pending static account WAL entries keyed by role
+// name. Entries of roles that no longer exist are deleted.
+func (b *databaseBackend) loadStaticWALs(ctx context.Context, s logical.Storage) (map[string]*setCredentialsWAL, error) {
+	keys, err := framework.ListWAL(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	walMap := make(map[string]*setCredentialsWAL)
+	for _, walID := range keys {
+		walEntry, err := b.findStaticWAL(ctx, s, walID)
+		if err != nil {
+			b.Logger().Error("error loading static WAL", "id", walID, "error", err)
+			continue
+		}
+		if walEntry == nil {
+			continue
+		}
+
+		role, err := b.StaticRole(ctx, s, walEntry.RoleName)
+		if err != nil {
+			b.Logger().Warn("unable to read static role", "error", err, "role", walEntry.RoleName)
+			continue
+		}
+		if role == nil || role.StaticAccount == nil || role.StaticAccount.Username != walEntry.Username {
+			if err := framework.DeleteWAL(ctx, s, walID); err != nil {
+				b.Logger().Warn("unable to delete WAL", "error", err, "id", walID)
+			}
+			continue
+		}
+
+		walMap[walEntry.RoleName] = walEntry
+	}
+
+	return walMap, nil
+}
+
+// findStaticWAL returns the static account WAL entry with the given ID, or nil
+// if the entry does not exist or is of another kind. Malformed entries are
+// deleted, since they could never be used to resume a rotation.
+func (b *databaseBackend) findStaticWAL(ctx context.Context, s logical.Storage, id string) (*setCredentialsWAL, error) {
+	wal, err := framework.GetWAL(ctx, s, id)
+	if err != nil {
+		return nil, err
+	}
+	if wal == nil || wal.Kind != staticWALKey {
+		return nil, nil
+	}
+
+	walEntry, ok := parseStaticWAL(id, wal.Data)
+	if !ok {
+		b.Logger().Warn("discarding malformed static account WAL entry", "id", id)
+		if err := framework.DeleteWAL(ctx, s, id); err != nil {
+			b.Logger().Warn("unable to delete WAL", "error", err, "id", id)
+		}
+		return nil, nil
+	}
+
+	return walEntry, nil
+}
+
+// parseStaticWAL decodes the data of a static account WAL entry, returning
+// false if it is malformed
+func parseStaticWAL(id string, raw interface{}) (*setCredentialsWAL, bool) {
+	data, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	walEntry := &setCredentialsWAL{
+		walID: id,
+	}
+	for field, dest := range map[string]*string{
+		"new_password": &walEntry.NewPassword,
+		"role_name":    &walEntry.RoleName,
+		"username":     &walEntry.Username,
+	} {
+		if *dest, ok = data[field].(string); !ok {
+			return nil, false
+		}
+	}
+
+	lvrRaw, ok := data["last_vault_rotation"].(string)
+	if !ok {
+		return nil, false
+	}
+	lvr, err := time.Parse(time.RFC3339, lvrRaw)
+	if err != nil {
+		return nil, false
+	}
+	walEntry.LastVaultRotation = lvr
+
+	return walEntry, true
+}
+
+// runTicker processes the rotation queue until the backend is cleaned up
+func (b *databaseBackend) runTicker(ctx context.Context, s logical.Storage) {
+	b.logger.Info("starting periodic ticker")
+	tick := time.NewTicker(queueTickInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			b.rotateCredentials(ctx, s)
+
+		case <-ctx.Done():
+			b.logger.Info("stopping periodic ticker")
+			return
+		}
+	}
+}
+
+// rotateCredentials rotates the static accounts that are due for rotation,
+// stopping at the first one that isn't
+func (b *databaseBackend) rotateCredentials(ctx context.Context, s logical.Storage) {
+	for b.rotateCredential(ctx, s) {
+	}
+}
+
+// rotateCredential rotates the static account at the front of the queue if it
+// is due, returning whether the queue should be checked again
+func (b *databaseBackend) rotateCredential(ctx context.Context, s logical.Storage) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	default:
+	}
+
+	item, err := b.popFromRotationQueue()
+	if err != nil {
+		if err != queue.ErrEmpty {
+			b.logger.Error("error popping item from queue", "err", err)
+		}
+		return false
+	}
+
+	// Guard against possible nil item
+	if item == nil {
+		return false
+	}
+
+	if item.Priority > time.Now().Unix() {
+		// The item isn't due yet, so put it back
+		if err := b.pushItem(item); err != nil {
+			b.logger.Error("unable to push item on to queue", "error", err)
+		}
+		return false
+	}
+
+	input := &setStaticAccountInput{
+		RoleName: item.Key,
+	}
+	if walID, ok := item.Value.(string); ok {
+		input.WALID = walID
+	}
+
+	resp, err := b.setStaticAccount(ctx, s, input)
+	if err != nil {
+		b.logger.Error("unable to rotate credentials in periodic function", "name", item.Key, "error", err)
+
+		// Try again later, resuming the WAL if one was written
+		if resp != nil && resp.WALID != "" {
+			item.Value = resp.WALID
+		}
+		item.Priority = time.Now().Add(rotationRetryInterval).Unix()
+		if err := b.pushItem(item); err != nil {
+			b.logger.Error("unable to push item on to queue", "error", err)
+		}
+		return true
+	}
+
+	// The role was deleted in the meantime
+	if resp == nil || resp.RotationTime.IsZero() {
+		return true
+	}
+
+	item.Value = ""
+	item.Priority = resp.NextRotationTime.Unix()
+	if err := b.pushItem(item); err != nil {
+		b.logger.Error("unable to push item on to queue", "error", err)
+	}
+	return true
+}
+
+type setStaticAccountInput struct {
+	RoleName string
+	Role     *roleEntry
+	WALID    string
+}
+
+type setStaticAccountOutput struct {
+	RotationTime     time.Time
+	NextRotationTime time.Time
+
+	// WALID is set when a rotation failed after its WAL entry was written, so
+	// that the next attempt reuses the same password
+	WALID string
+}
+
+// setStaticAccount sets a new password for the static account of a role and
+// stores it. A WAL entry holding the new password is written before the
+// database is changed and removed once the role has been updated.
+func (b *databaseBackend) setStaticAccount(ctx context.Context, s logical.Storage, input *setStaticAccountInput) (*setStaticAccountOutput, error) {
+	b.staticRoleLock.Lock()
+	defer b.staticRoleLock.Unlock()
+
+	var err error
+	role := input.Role
+	if role == nil {
+		role, err = b.StaticRole(ctx, s, input.RoleName)
+		if err != nil {
+			return nil, err
+		}
+		// The role was deleted, there's nothing left to rotate
+		if role == nil || role.StaticAccount == nil {
+			if input.WALID != "" {
+				framework.DeleteWAL(ctx, s, input.WALID)
+			}
+			return nil, nil
+		}
+	}
+
+	output := &setStaticAccountOutput{}
+
+	dbConfig, err := b.DatabaseConfig(ctx, s, role.DBName)
+	if err != nil {
+		return output, err
+	}
+
+	// If role name isn't in the database's allowed roles, the password is left
+	// untouched
+	if !strutil.StrListContains(dbConfig.AllowedRoles, "*") && !strutil.StrListContainsGlob(dbConfig.AllowedRoles, input.RoleName) {
+		return output, fmt.Errorf("%q is not an allowed role", input.RoleName)
+	}
+
+	// Reuse the password of an interrupted rotation, otherwise generate a new
+	// one and record it before the database is changed
+	var newPassword string
+	if input.WALID != "" {
+		wal, err := b.findStaticWAL(ctx, s, input.WALID)
+		if err != nil {
+			return output, errwrap.Wrapf("error retrieving WAL entry: {{err}}", err)
+		}
+		if wal != nil && wal.Username == role.StaticAccount.Username {
+			newPassword = wal.NewPassword
+			output.WALID = input.WALID
+		} else if wal != nil {
+			framework.DeleteWAL(ctx, s, input.WALID)
+		}
+	}
+
+	if newPassword == "" {
+		newPassword, err = credsutil.RandomAlphaNumeric(20, true)
+		if err != nil {
+			return output, err
+		}
+
+		output.WALID, err = framework.PutWAL(ctx, s, staticWALKey, &setCredentialsWAL{
+			RoleName:          input.RoleName,
+			Username:          role.StaticAccount.Username,
+			NewPassword:       newPassword,
+			LastVaultRotation: role.StaticAccount.LastVaultRotation,
+		})
+		if err != nil {
+			return output, errwrap.Wrapf("error writing WAL entry: {{err}}", err)
+		}
+	}
+
+	db, err := b.GetConnection(ctx, s, role.DBName)
+	if err != nil {
+		return output, err
+	}
+
+	db.RLock()
+	defer db.RUnlock()
+
+	config := dbplugin.StaticUserConfig{
+		Username: role.StaticAccount.Username,
+		Password: newPassword,
+	}
+	if _, _, err := db.SetCredentials(ctx, role.Statements, config); err != nil {
+		b.CloseIfShutdown(db, err)
+		return output, errwrap.Wrapf("error setting credentials: {{err}}", err)
+	}
+
+	// Store the new password now that it has been set in the database
+	lvr := time.Now()
+	role.StaticAccount.Password = newPassword
+	role.StaticAccount.LastVaultRotation = lvr
+
+	entry, err := logical.StorageEntryJSON(staticRolePath+input.RoleName, role)
+	if err != nil {
+		return output, err
+	}
+	if err := s.Put(ctx, entry); err != nil {
+		return output, err
+	}
+
+	// The rotation is complete, so the WAL entry is no longer needed
+	if err := framework.DeleteWAL(ctx, s, output.WALID); err != nil {
+		b.Logger().Warn("error deleting WAL", "WAL ID", output.WALID, "error", err)
+	}
+
+	return &setStaticAccountOutput{
+		RotationTime:     lvr,
+		NextRotationTime: role.StaticAccount.NextRotationTime(),
+	}, nil
+}
+
+// pushItem adds an item to the rotation queue
+func (b *databaseBackend) pushItem(item *queue.Item) error {
+	return b.credRotationQueue.Push(item)
+}
+
+// popFromRotationQueue removes the item at the front of the rotation queue
+func (b *databaseBackend) popFromRotationQueue() (*queue.Item, error) {
+	return b.credRotationQueue.Pop()
+}
+
+// popFromRotationQueueByKey removes the item of the given role from the
+// rotation queue
+func (b *databaseBackend) popFromRotationQueueByKey(name string) (*queue.Item, error) {
+	return b.credRotationQueue.PopByKey(name)
+}
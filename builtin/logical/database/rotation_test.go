@@ -0,0 +1,269 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// staticMockDB is a database plugin that records the passwords set on static
+// accounts
+type staticMockDB struct {
+	dbplugin.Database
+
+	passwords map[string]string
+	fail      bool
+}
+
+func (m *staticMockDB) SetCredentials(_ context.Context, _ dbplugin.Statements, config dbplugin.StaticUserConfig) (string, string, error) {
+	if m.fail {
+		return "", "", errors.New("database unavailable")
+	}
+	m.passwords[config.Username] = config.Password
+	return config.Username, config.Password, nil
+}
+
+func (m *staticMockDB) Close() error { return nil }
+
+func testStaticBackend(t *testing.T) (*databaseBackend, logical.Storage, *staticMockDB) {
+	t.Helper()
+
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b := Backend(config)
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := logical.StorageEntryJSON("config/mockdb", &DatabaseConfig{
+		PluginName:   "mock",
+		AllowedRoles: []string{"*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.StorageView.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	db := &staticMockDB{
+		passwords: make(map[string]string),
+	}
+	b.connections["mockdb"] = &dbPluginInstance{
+		Database: db,
+		name:     "mockdb",
+		id:       "mockdb",
+	}
+
+	return b, config.StorageView, db
+}
+
+func TestBackend_StaticRole(t *testing.T) {
+	b, s, db := testStaticBackend(t)
+	ctx := namespace.RootContext(nil)
+
+	handle := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   s,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err: %v, resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	// Rotation periods that are too short are rejected
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "static-roles/svc",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"db_name":         "mockdb",
+			"username":        "svc",
+			"rotation_period": 1,
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got err: %v, resp: %#v", err, resp)
+	}
+
+	handle(logical.CreateOperation, "static-roles/svc", map[string]interface{}{
+		"db_name":         "mockdb",
+		"username":        "svc",
+		"rotation_period": 3600,
+	})
+
+	// The password is set as soon as the role is created
+	password := db.passwords["svc"]
+	if password == "" {
+		t.Fatal("password not set on role creation")
+	}
+	if b.credRotationQueue.Len() != 1 {
+		t.Fatalf("expected role to be queued, got %d items", b.credRotationQueue.Len())
+	}
+
+	resp = handle(logical.ReadOperation, "static-creds/svc", nil)
+	if resp.Data["username"] != "svc" || resp.Data["password"] != password {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if ttl := resp.Data["ttl"].(float64); ttl <= 0 || ttl > 3600 {
+		t.Fatalf("bad ttl: %v", ttl)
+	}
+
+	resp = handle(logical.ReadOperation, "static-roles/svc", nil)
+	if resp.Data["username"] != "svc" || resp.Data["rotation_period"] != float64(3600) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if _, ok := resp.Data["password"]; ok {
+		t.Fatal("password returned when reading the role")
+	}
+
+	resp = handle(logical.ListOperation, "static-roles/", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != "svc" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// The username can't be changed
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "static-roles/svc",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"username": "other",
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got err: %v, resp: %#v", err, resp)
+	}
+
+	// Shortening the rotation period makes the role due for rotation
+	handle(logical.UpdateOperation, "static-roles/svc", map[string]interface{}{
+		"rotation_period": 5,
+	})
+	item := b.credRotationQueue.Peek()
+	if item == nil || item.Key != "svc" || item.Priority > time.Now().Add(5*time.Second).Unix() {
+		t.Fatalf("bad: queue item: %#v", item)
+	}
+	item.Priority = time.Now().Unix()
+
+	b.rotateCredentials(ctx, s)
+	if db.passwords["svc"] == password {
+		t.Fatal("password not rotated")
+	}
+	resp = handle(logical.ReadOperation, "static-creds/svc", nil)
+	if resp.Data["password"] != db.passwords["svc"] {
+		t.Fatalf("stored password does not match the database: %#v", resp.Data)
+	}
+	if b.credRotationQueue.Len() != 1 {
+		t.Fatalf("expected role to be queued again, got %d items", b.credRotationQueue.Len())
+	}
+
+	handle(logical.DeleteOperation, "static-roles/svc", nil)
+	if b.credRotationQueue.Len() != 0 {
+		t.Fatalf("expected empty queue, got %d items", b.credRotationQueue.Len())
+	}
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "static-creds/svc",
+		Storage:   s,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got err: %v, resp: %#v", err, resp)
+	}
+}
+
+func TestBackend_StaticRole_WAL(t *testing.T) {
+	b, s, db := testStaticBackend(t)
+	ctx := namespace.RootContext(nil)
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "static-roles/svc",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"db_name":         "mockdb",
+			"username":        "svc",
+			"rotation_period": 3600,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	// A failed rotation leaves its WAL entry behind
+	db.fail = true
+	output, err := b.setStaticAccount(ctx, s, &setStaticAccountInput{RoleName: "svc"})
+	if err == nil || output == nil || output.WALID == "" {
+		t.Fatalf("expected failed rotation with a WAL entry, got err: %v, output: %#v", err, output)
+	}
+	wal, err := b.findStaticWAL(ctx, s, output.WALID)
+	if err != nil || wal == nil || wal.RoleName != "svc" || wal.NewPassword == "" {
+		t.Fatalf("err: %v, wal: %#v", err, wal)
+	}
+
+	// Rebuilding the queue, as after a leader change, resumes the rotation
+	// with the password from the WAL
+	if _, err := b.popFromRotationQueueByKey("svc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.populateQueue(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	item := b.credRotationQueue.Peek()
+	if item == nil || item.Value != output.WALID || item.Priority > time.Now().Unix() {
+		t.Fatalf("bad: queue item: %#v", item)
+	}
+
+	db.fail = false
+	b.rotateCredentials(ctx, s)
+	if db.passwords["svc"] != wal.NewPassword {
+		t.Fatal("rotation did not reuse the password from the WAL")
+	}
+
+	role, err := b.StaticRole(ctx, s, "svc")
+	if err != nil || role == nil || role.StaticAccount.Password != wal.NewPassword {
+		t.Fatalf("err: %v, role: %#v", err, role)
+	}
+
+	keys, err := framework.ListWAL(ctx, s)
+	if err != nil || len(keys) != 0 {
+		t.Fatalf("expected WAL entry to be deleted, got err: %v, keys: %v", err, keys)
+	}
+
+	// Malformed entries are discarded
+	for _, data := range []interface{}{
+		"bad",
+		map[string]interface{}{
+			"role_name": "svc",
+		},
+		map[string]interface{}{
+			"new_password":        "password",
+			"role_name":           "svc",
+			"username":            "svc",
+			"last_vault_rotation": 42,
+		},
+	} {
+		walID, err := framework.PutWAL(ctx, s, staticWALKey, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wal, err := b.findStaticWAL(ctx, s, walID)
+		if err != nil || wal != nil {
+			t.Fatalf("expected malformed WAL entry to be discarded, got err: %v, wal: %#v", err, wal)
+		}
+		if entry, err := framework.GetWAL(ctx, s, walID); err != nil || entry != nil {
+			t.Fatalf("expected malformed WAL entry to be deleted, got err: %v, entry: %#v", err, entry)
+		}
+	}
+}
@@ -0,0 +1,482 @@
+package pki
+
+import (
+	"container/list"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	cleanhttp "github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/logical"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const (
+	acmeAccountPrefix = "acme/accounts/"
+	acmeOrderPrefix   = "acme/orders/"
+	acmeAuthzPrefix   = "acme/authorizations/"
+	acmeCertPrefix    = "acme/certs/"
+
+	// acmeNonceTTL is how long a nonce handed out by the server can be used
+	acmeNonceTTL = 15 * time.Minute
+
+	// acmeMaxNonces is the number of unused nonces kept in memory, past which
+	// the oldest ones are dropped
+	acmeMaxNonces = 10000
+
+	// acmeOrderTTL is how long an order, and its authorizations, can be
+	// completed for
+	acmeOrderTTL = 24 * time.Hour
+)
+
+const (
+	acmeStatusPending     = "pending"
+	acmeStatusReady       = "ready"
+	acmeStatusValid       = "valid"
+	acmeStatusInvalid     = "invalid"
+	acmeStatusDeactivated = "deactivated"
+)
+
+// acmeAllowedAlgorithms are the JWS algorithms accepted from clients
+var acmeAllowedAlgorithms = []string{
+	string(jose.RS256), string(jose.ES256), string(jose.ES384),
+	string(jose.ES512), string(jose.PS256), string(jose.EdDSA),
+}
+
+// acmeError is an error reported to ACME clients as a problem document, as
+// described in RFC 8555 section 6.7
+type acmeError struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+	Status int    `json:"status"`
+}
+
+func (e *acmeError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Type, e.Detail)
+}
+
+func newACMEError(errType string, status int, format string, args ...interface{}) *acmeError {
+	return &acmeError{
+		Type:   "urn:ietf:params:acme:error:" + errType,
+		Detail: fmt.Sprintf(format, args...),
+		Status: status,
+	}
+}
+
+type acmeAccount struct {
+	ID        string          `json:"id"`
+	Key       json.RawMessage `json:"key"`
+	Status    string          `json:"status"`
+	Contact   []string        `json:"contact"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type acmeIdentifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type acmeOrder struct {
+	ID               string           `json:"id"`
+	AccountID        string           `json:"account_id"`
+	Role             string           `json:"role"`
+	Status           string           `json:"status"`
+	Expires          time.Time        `json:"expires"`
+	Identifiers      []acmeIdentifier `json:"identifiers"`
+	AuthorizationIDs []string         `json:"authorization_ids"`
+	SerialNumber     string           `json:"serial_number"`
+	Certificate      string           `json:"certificate"`
+}
+
+type acmeChallenge struct {
+	Type      string     `json:"type"`
+	Token     string     `json:"token"`
+	Status    string     `json:"status"`
+	Validated time.Time  `json:"validated"`
+	Error     *acmeError `json:"error"`
+}
+
+type acmeAuthorization struct {
+	ID         string           `json:"id"`
+	AccountID  string           `json:"account_id"`
+	Identifier acmeIdentifier   `json:"identifier"`
+	Wildcard   bool             `json:"wildcard"`
+	Status     string           `json:"status"`
+	Expires    time.Time        `json:"expires"`
+	Challenges []*acmeChallenge `json:"challenges"`
+}
+
+func (a *acmeAuthorization) challenge(challengeType string) *acmeChallenge {
+	for _, c := range a.Challenges {
+		if c.Type == challengeType {
+			return c
+		}
+	}
+	return nil
+}
+
+// acmeRandomID returns a random identifier that is safe to use in URLs
+func acmeRandomID() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func getACMEEntry(ctx context.Context, s logical.Storage, key string, out interface{}) (bool, error) {
+	entry, err := s.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if entry == nil {
+		return false, nil
+	}
+	if err := entry.DecodeJSON(out); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func putACMEEntry(ctx context.Context, s logical.Storage, key string, in interface{}) error {
+	entry, err := logical.StorageEntryJSON(key, in)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+func getACMEAccount(ctx context.Context, s logical.Storage, id string) (*acmeAccount, error) {
+	var account acmeAccount
+	ok, err := getACMEEntry(ctx, s, acmeAccountPrefix+id, &account)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func getACMEOrder(ctx context.Context, s logical.Storage, accountID, id string) (*acmeOrder, error) {
+	var order acmeOrder
+	ok, err := getACMEEntry(ctx, s, acmeOrderPrefix+accountID+"/"+id, &order)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func getACMEAuthorization(ctx context.Context, s logical.Storage, accountID, id string) (*acmeAuthorization, error) {
+	var authz acmeAuthorization
+	ok, err := getACMEEntry(ctx, s, acmeAuthzPrefix+accountID+"/"+id, &authz)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	if authz.Status == acmeStatusPending && time.Now().After(authz.Expires) {
+		authz.Status = acmeStatusInvalid
+	}
+
+	return &authz, nil
+}
+
+// updateACMEOrderStatus moves a pending order to ready or invalid based on
+// the status of its authorizations
+func updateACMEOrderStatus(ctx context.Context, s logical.Storage, order *acmeOrder) error {
+	if order.Status != acmeStatusPending {
+		return nil
+	}
+	if time.Now().After(order.Expires) {
+		order.Status = acmeStatusInvalid
+		return nil
+	}
+
+	ready := true
+	for _, id := range order.AuthorizationIDs {
+		authz, err := getACMEAuthorization(ctx, s, order.AccountID, id)
+		if err != nil {
+			return err
+		}
+		if authz == nil {
+			return fmt.Errorf("authorization %q of order %q not found", id, order.ID)
+		}
+
+		switch authz.Status {
+		case acmeStatusValid:
+		case acmeStatusPending:
+			ready = false
+		default:
+			order.Status = acmeStatusInvalid
+			return nil
+		}
+	}
+	if ready {
+		order.Status = acmeStatusReady
+	}
+
+	return nil
+}
+
+// acmeNonces tracks the anti-replay nonces handed out to clients. Nonces are
+// only kept in memory, so a nonce is only good on the node that issued it.
+type acmeNonces struct {
+	l      sync.Mutex
+	nonces map[string]*list.Element
+
+	// order holds the nonces from the oldest to the newest. All nonces have
+	// the same TTL, so this is also the order in which they expire.
+	order *list.List
+}
+
+type acmeNonce struct {
+	value  string
+	expiry time.Time
+}
+
+func newACMENonces() *acmeNonces {
+	return &acmeNonces{
+		nonces: make(map[string]*list.Element),
+		order:  list.New(),
+	}
+}
+
+func (n *acmeNonces) issue() (string, error) {
+	nonce, err := acmeRandomID()
+	if err != nil {
+		return "", err
+	}
+
+	n.l.Lock()
+	defer n.l.Unlock()
+
+	// Drop the expired nonces, then the oldest ones if there are still too
+	// many, as nonces are handed out to unauthenticated clients
+	now := time.Now()
+	for elem := n.order.Front(); elem != nil; elem = n.order.Front() {
+		if now.Before(elem.Value.(*acmeNonce).expiry) && n.order.Len() < acmeMaxNonces {
+			break
+		}
+		n.remove(elem)
+	}
+
+	n.nonces[nonce] = n.order.PushBack(&acmeNonce{
+		value:  nonce,
+		expiry: now.Add(acmeNonceTTL),
+	})
+
+	return nonce, nil
+}
+
+// redeem returns whether the nonce is valid, and makes sure it can't be
+// used again
+func (n *acmeNonces) redeem(nonce string) bool {
+	n.l.Lock()
+	defer n.l.Unlock()
+
+	elem, ok := n.nonces[nonce]
+	if !ok {
+		return false
+	}
+	n.remove(elem)
+
+	return time.Now().Before(elem.Value.(*acmeNonce).expiry)
+}
+
+func (n *acmeNonces) remove(elem *list.Element) {
+	delete(n.nonces, elem.Value.(*acmeNonce).value)
+	n.order.Remove(elem)
+}
+
+// acmeRequest is a verified JWS request from an ACME client
+type acmeRequest struct {
+	payload []byte
+
+	// Exactly one of these is set, depending on whether the request is
+	// signed by an existing account or by a key given in the request
+	account *acmeAccount
+	jwk     *jose.JSONWebKey
+}
+
+// isPostAsGet returns whether the request has an empty payload, which ACME
+// uses to fetch resources
+func (r *acmeRequest) isPostAsGet() bool {
+	return len(r.payload) == 0
+}
+
+func (r *acmeRequest) decodePayload(out interface{}) error {
+	if err := json.Unmarshal(r.payload, out); err != nil {
+		return newACMEError("malformed", http.StatusBadRequest, "failed to parse request payload: %v", err)
+	}
+	return nil
+}
+
+// verifyACMERequest checks the JWS sent in the request body, as described in
+// RFC 8555 section 6.2
+func (b *backend) verifyACMERequest(ctx context.Context, req *logical.Request, acmeCtx *acmeContext) (*acmeRequest, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"protected": req.Data["protected"],
+		"payload":   req.Data["payload"],
+		"signature": req.Data["signature"],
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	jws, err := jose.ParseSigned(string(body))
+	if err != nil {
+		return nil, newACMEError("malformed", http.StatusBadRequest, "failed to parse JWS: %v", err)
+	}
+	if len(jws.Signatures) != 1 {
+		return nil, newACMEError("malformed", http.StatusBadRequest, "JWS must have exactly one signature")
+	}
+	header := jws.Signatures[0].Protected
+
+	allowed := false
+	for _, alg := range acmeAllowedAlgorithms {
+		if header.Algorithm == alg {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, newACMEError("badSignatureAlgorithm", http.StatusBadRequest, "unsupported JWS algorithm %q", header.Algorithm)
+	}
+
+	if url, _ := header.ExtraHeaders["url"].(string); url != acmeCtx.requestURL {
+		return nil, newACMEError("unauthorized", http.StatusUnauthorized, "JWS url %q does not match the request URL", url)
+	}
+
+	if header.Nonce == "" || !b.acmeNonces.redeem(header.Nonce) {
+		return nil, newACMEError("badNonce", http.StatusBadRequest, "invalid or expired nonce")
+	}
+
+	acmeReq := &acmeRequest{}
+	var key *jose.JSONWebKey
+	switch {
+	case header.JSONWebKey != nil && header.KeyID != "":
+		return nil, newACMEError("malformed", http.StatusBadRequest, "JWS must not have both jwk and kid")
+
+	case header.JSONWebKey != nil:
+		if !header.JSONWebKey.Valid() || !header.JSONWebKey.IsPublic() {
+			return nil, newACMEError("badPublicKey", http.StatusBadRequest, "invalid JWK")
+		}
+		key = header.JSONWebKey
+		acmeReq.jwk = key
+
+	case header.KeyID != "":
+		accountID := strings.TrimPrefix(header.KeyID, acmeCtx.prefixURL+"/account/")
+		if accountID == header.KeyID || accountID == "" {
+			return nil, newACMEError("accountDoesNotExist", http.StatusBadRequest, "unknown account %q", header.KeyID)
+		}
+		account, err := getACMEAccount(ctx, req.Storage, accountID)
+		if err != nil {
+			return nil, err
+		}
+		if account == nil {
+			return nil, newACMEError("accountDoesNotExist", http.StatusBadRequest, "unknown account %q", header.KeyID)
+		}
+		if account.Status != acmeStatusValid {
+			return nil, newACMEError("unauthorized", http.StatusUnauthorized, "account is %s", account.Status)
+		}
+
+		key = &jose.JSONWebKey{}
+		if err := key.UnmarshalJSON(account.Key); err != nil {
+			return nil, err
+		}
+		acmeReq.account = account
+
+	default:
+		return nil, newACMEError("malformed", http.StatusBadRequest, "JWS must have either jwk or kid")
+	}
+
+	payload, err := jws.Verify(key)
+	if err != nil {
+		return nil, newACMEError("malformed", http.StatusBadRequest, "JWS signature is invalid")
+	}
+	acmeReq.payload = payload
+
+	return acmeReq, nil
+}
+
+// acmeThumbprint returns the base64url encoded SHA-256 thumbprint of a JWK,
+// as described in RFC 7638
+func acmeThumbprint(key *jose.JSONWebKey) (string, error) {
+	thumbprint, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
+
+// acmeValidator performs challenge validation. Its fields are only changed
+// in tests, to validate against local stand-ins.
+type acmeValidator struct {
+	httpPort  int
+	client    *http.Client
+	lookupTXT func(string) ([]string, error)
+}
+
+func newACMEValidator() *acmeValidator {
+	client := cleanhttp.DefaultClient()
+	client.Timeout = 10 * time.Second
+
+	return &acmeValidator{
+		httpPort:  80,
+		client:    client,
+		lookupTXT: net.LookupTXT,
+	}
+}
+
+// validate checks that the challenge has been fulfilled for the given
+// domain, using the key authorization of the challenge
+func (v *acmeValidator) validate(challengeType, domain, token, keyAuthz string) *acmeError {
+	switch challengeType {
+	case "http-01":
+		url := fmt.Sprintf("http://%s/.well-known/acme-challenge/%s", net.JoinHostPort(domain, fmt.Sprint(v.httpPort)), token)
+		resp, err := v.client.Get(url)
+		if err != nil {
+			return newACMEError("connection", http.StatusBadRequest, "failed to fetch %s: %v", url, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return newACMEError("incorrectResponse", http.StatusBadRequest, "unexpected status %d from %s", resp.StatusCode, url)
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		if err != nil {
+			return newACMEError("connection", http.StatusBadRequest, "failed to read %s: %v", url, err)
+		}
+		if strings.TrimSpace(string(body)) != keyAuthz {
+			return newACMEError("incorrectResponse", http.StatusBadRequest, "incorrect key authorization from %s", url)
+		}
+
+	case "dns-01":
+		name := "_acme-challenge." + domain
+		records, err := v.lookupTXT(name)
+		if err != nil {
+			return newACMEError("dns", http.StatusBadRequest, "failed to look up TXT records of %s: %v", name, err)
+		}
+		digest := sha256.Sum256([]byte(keyAuthz))
+		expected := base64.RawURLEncoding.EncodeToString(digest[:])
+		for _, record := range records {
+			if record == expected {
+				return nil
+			}
+		}
+		return newACMEError("incorrectResponse", http.StatusBadRequest, "no matching TXT record found at %s", name)
+
+	default:
+		return newACMEError("malformed", http.StatusBadRequest, "unsupported challenge type %q", challengeType)
+	}
+
+	return nil
+}
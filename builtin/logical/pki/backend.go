@@ -33,12 +33,16 @@ func Backend(conf *logical.BackendConfig) *backend {
 				"ca",
 				"crl/pem",
 				"crl",
+				"ocsp",
+				"ocsp/*",
+				"acme/*",
 			},
 
 			LocalStorage: []string{
 				"revoked/",
 				"crl",
 				"certs/",
+				"acme/",
 			},
 
 			Root: []string{
@@ -51,7 +55,7 @@ func Backend(conf *logical.BackendConfig) *backend {
 			},
 		},
 
-		Paths: []*framework.Path{
+		Paths: framework.PathAppend([]*framework.Path{
 			pathListRoles(&b),
 			pathRoles(&b),
 			pathGenerateRoot(&b),
@@ -74,8 +78,11 @@ func Backend(conf *logical.BackendConfig) *backend {
 			pathFetchValid(&b),
 			pathFetchListCerts(&b),
 			pathRevoke(&b),
+			pathOCSP(&b),
+			pathOCSPGet(&b),
 			pathTidy(&b),
-		},
+			pathConfigACME(&b),
+		}, pathsACME(&b)),
 
 		Secrets: []*framework.Secret{
 			secretCerts(&b),
@@ -87,6 +94,8 @@ func Backend(conf *logical.BackendConfig) *backend {
 	b.crlLifetime = time.Hour * 72
 	b.tidyCASGuard = new(uint32)
 	b.storage = conf.StorageView
+	b.acmeNonces = newACMENonces()
+	b.acmeValidator = newACMEValidator()
 
 	return &b
 }
@@ -98,6 +107,10 @@ type backend struct {
 	crlLifetime       time.Duration
 	revokeStorageLock sync.RWMutex
 	tidyCASGuard      *uint32
+
+	acmeLock      sync.Mutex
+	acmeNonces    *acmeNonces
+	acmeValidator *acmeValidator
 }
 
 const backendHelp = `
@@ -1218,7 +1218,7 @@ func createCertificate(data *dataBundle) (*certutil.ParsedCertBundle, error) {
 
 	certTemplate.IssuingCertificateURL = data.params.URLs.IssuingCertificates
 	certTemplate.CRLDistributionPoints = data.params.URLs.CRLDistributionPoints
-	certTemplate.OCSPServer = data.params.URLs.OCSPServers
+	certTemplate.OCSPServer = data.params.URLs.ocspServers()
 
 	var certBytes []byte
 	if data.signingBundle != nil {
@@ -1433,7 +1433,7 @@ func signCertificate(data *dataBundle) (*certutil.ParsedCertBundle, error) {
 
 	certTemplate.IssuingCertificateURL = data.params.URLs.IssuingCertificates
 	certTemplate.CRLDistributionPoints = data.params.URLs.CRLDistributionPoints
-	certTemplate.OCSPServer = data.signingBundle.URLs.OCSPServers
+	certTemplate.OCSPServer = data.signingBundle.URLs.ocspServers()
 
 	if data.params.IsCA {
 		certTemplate.BasicConstraintsValid = true
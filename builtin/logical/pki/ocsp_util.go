@@ -0,0 +1,319 @@
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"hash"
+	"math/big"
+	"time"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+// The ASN.1 structures below implement the subset of RFC 6960 needed to
+// answer OCSP requests for certificates issued by this backend
+
+const (
+	ocspSuccessful       asn1.Enumerated = 0
+	ocspMalformedRequest asn1.Enumerated = 1
+	ocspInternalError    asn1.Enumerated = 2
+	ocspUnauthorized     asn1.Enumerated = 6
+)
+
+var (
+	oidOCSPBasicResponse = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+	oidOCSPNonce         = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 2}
+
+	oidSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+type ocspRequest struct {
+	TBSRequest ocspTBSRequest
+}
+
+type ocspTBSRequest struct {
+	Version       int           `asn1:"explicit,tag:0,default:0,optional"`
+	RequestorName asn1.RawValue `asn1:"explicit,tag:1,optional"`
+	RequestList   []ocspSingleRequest
+	Extensions    []pkix.Extension `asn1:"explicit,tag:2,optional"`
+}
+
+type ocspSingleRequest struct {
+	Cert ocspCertID
+}
+
+type ocspCertID struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	IssuerKeyHash []byte
+	SerialNumber  *big.Int
+}
+
+type ocspResponse struct {
+	Status   asn1.Enumerated
+	Response ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type ocspBasicResponse struct {
+	TBSResponseData    asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+}
+
+type ocspResponseData struct {
+	Version     int `asn1:"explicit,tag:0,default:0,optional"`
+	ResponderID asn1.RawValue
+	ProducedAt  time.Time `asn1:"generalized"`
+	Responses   []ocspSingleResponse
+	Extensions  []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspSingleResponse struct {
+	CertID     ocspCertID
+	Good       asn1.Flag       `asn1:"tag:0,optional"`
+	Revoked    ocspRevokedInfo `asn1:"tag:1,optional"`
+	Unknown    asn1.Flag       `asn1:"tag:2,optional"`
+	ThisUpdate time.Time       `asn1:"generalized"`
+}
+
+type ocspRevokedInfo struct {
+	RevocationTime time.Time `asn1:"generalized"`
+}
+
+// ocspHashes maps the hash algorithms accepted in a CertID to their
+// implementations
+var ocspHashes = []struct {
+	oid asn1.ObjectIdentifier
+	new func() hash.Hash
+}{
+	{oidSHA1, sha1.New},
+	{oidSHA256, sha256.New},
+	{oidSHA384, sha512.New384},
+	{oidSHA512, sha512.New},
+}
+
+// ocspErrorResponse returns an OCSP response carrying only an error status
+func ocspErrorResponse(status asn1.Enumerated) []byte {
+	// Marshaling a bare enumerated value cannot fail
+	resp, _ := asn1.Marshal(ocspResponse{Status: status})
+	return resp
+}
+
+// subjectPublicKey returns the contents of the subjectPublicKey field of the
+// certificate, which is what OCSP key hashes are computed over
+func subjectPublicKey(cert *x509.Certificate) ([]byte, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil, err
+	}
+
+	return spki.PublicKey.RightAlign(), nil
+}
+
+// matchesIssuer checks whether the given CertID names the given certificate
+// as its issuer
+func (id *ocspCertID) matchesIssuer(issuer *x509.Certificate) (bool, error) {
+	var newHash func() hash.Hash
+	for _, h := range ocspHashes {
+		if id.HashAlgorithm.Algorithm.Equal(h.oid) {
+			newHash = h.new
+			break
+		}
+	}
+	if newHash == nil {
+		return false, fmt.Errorf("unsupported hash algorithm %s", id.HashAlgorithm.Algorithm)
+	}
+
+	publicKey, err := subjectPublicKey(issuer)
+	if err != nil {
+		return false, err
+	}
+
+	h := newHash()
+	h.Write(issuer.RawSubject)
+	nameHash := h.Sum(nil)
+
+	h = newHash()
+	h.Write(publicKey)
+	keyHash := h.Sum(nil)
+
+	return bytes.Equal(nameHash, id.NameHash) && bytes.Equal(keyHash, id.IssuerKeyHash), nil
+}
+
+// ocspCertStatus looks up the status of the certificate with the given serial
+// number in the revocation and certificate storage
+func ocspCertStatus(ctx context.Context, req *logical.Request, serialNumber *big.Int, resp *ocspSingleResponse) error {
+	serial := certutil.GetHexFormatted(serialNumber.Bytes(), ":")
+
+	revokedEntry, err := fetchCertBySerial(ctx, req, "revoked/", serial)
+	if err != nil {
+		return err
+	}
+	if revokedEntry != nil {
+		var revInfo revocationInfo
+		if err := revokedEntry.DecodeJSON(&revInfo); err != nil {
+			return fmt.Errorf("error decoding revocation entry for serial %s: %s", serial, err)
+		}
+		resp.Revoked.RevocationTime = revInfo.RevocationTimeUTC
+		if revInfo.RevocationTimeUTC.IsZero() {
+			resp.Revoked.RevocationTime = time.Unix(revInfo.RevocationTime, 0).UTC()
+		}
+		return nil
+	}
+
+	certEntry, err := fetchCertBySerial(ctx, req, "certs/", serial)
+	if err != nil {
+		return err
+	}
+	if certEntry != nil {
+		resp.Good = true
+	} else {
+		resp.Unknown = true
+	}
+
+	return nil
+}
+
+// buildOCSPResponse parses a DER encoded OCSP request and returns the DER
+// encoded response to it, signed by the CA of this backend. Problems with the
+// request itself are reported as OCSP error statuses rather than as errors.
+func buildOCSPResponse(ctx context.Context, req *logical.Request, der []byte) ([]byte, error) {
+	var ocspReq ocspRequest
+	rest, err := asn1.Unmarshal(der, &ocspReq)
+	if err != nil || len(rest) > 0 || len(ocspReq.TBSRequest.RequestList) == 0 {
+		return ocspErrorResponse(ocspMalformedRequest), nil
+	}
+
+	caInfo, err := fetchCAInfo(ctx, req)
+	switch err.(type) {
+	case errutil.UserError:
+		// Without a CA this backend is not authoritative for anything
+		return ocspErrorResponse(ocspUnauthorized), nil
+	case errutil.InternalError:
+		return nil, err
+	}
+
+	var sigAlgo pkix.AlgorithmIdentifier
+	switch caInfo.PrivateKeyType {
+	case certutil.RSAPrivateKey:
+		sigAlgo = pkix.AlgorithmIdentifier{
+			Algorithm:  oidSHA256WithRSA,
+			Parameters: asn1.NullRawValue,
+		}
+	case certutil.ECPrivateKey:
+		sigAlgo = pkix.AlgorithmIdentifier{
+			Algorithm: oidECDSAWithSHA256,
+		}
+	default:
+		return nil, fmt.Errorf("unsupported CA key type %q", caInfo.PrivateKeyType)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	respData := ocspResponseData{
+		ProducedAt: now,
+	}
+
+	for _, single := range ocspReq.TBSRequest.RequestList {
+		matches, err := single.Cert.matchesIssuer(caInfo.Certificate)
+		if err != nil {
+			return ocspErrorResponse(ocspMalformedRequest), nil
+		}
+		if !matches || single.Cert.SerialNumber == nil {
+			return ocspErrorResponse(ocspUnauthorized), nil
+		}
+
+		singleResp := ocspSingleResponse{
+			CertID:     single.Cert,
+			ThisUpdate: now,
+		}
+		if err := ocspCertStatus(ctx, req, single.Cert.SerialNumber, &singleResp); err != nil {
+			return nil, err
+		}
+		respData.Responses = append(respData.Responses, singleResp)
+	}
+
+	// Echo the nonce, if any, so that the client can detect replays
+	for _, ext := range ocspReq.TBSRequest.Extensions {
+		if ext.Id.Equal(oidOCSPNonce) {
+			respData.Extensions = append(respData.Extensions, ext)
+		}
+	}
+
+	// Identify the responder by the hash of the CA public key
+	publicKey, err := subjectPublicKey(caInfo.Certificate)
+	if err != nil {
+		return nil, err
+	}
+	keyHash := sha1.Sum(publicKey)
+	keyHashDER, err := asn1.Marshal(keyHash[:])
+	if err != nil {
+		return nil, err
+	}
+	respData.ResponderID = asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        2,
+		IsCompound: true,
+		Bytes:      keyHashDER,
+	}
+
+	tbsResponseData, err := asn1.Marshal(respData)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(tbsResponseData)
+	signature, err := caInfo.PrivateKey.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	basicResp, err := asn1.Marshal(ocspBasicResponse{
+		TBSResponseData:    asn1.RawValue{FullBytes: tbsResponseData},
+		SignatureAlgorithm: sigAlgo,
+		Signature: asn1.BitString{
+			Bytes:     signature,
+			BitLength: 8 * len(signature),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := asn1.Marshal(ocspResponse{
+		Status: ocspSuccessful,
+		Response: ocspResponseBytes{
+			ResponseType: oidOCSPBasicResponse,
+			Response:     basicResp,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
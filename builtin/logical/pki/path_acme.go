@@ -0,0 +1,949 @@
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/certutil"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// acmeContext holds what ACME handlers need to know about the directory a
+// request was sent to
+type acmeContext struct {
+	roleName string
+	role     *roleEntry
+
+	// prefixURL is the URL of the directory, without the trailing
+	// "/directory", under which all ACME resources of the request live
+	prefixURL string
+
+	// requestURL is the URL the request was sent to, which must match the
+	// one signed by the client
+	requestURL string
+}
+
+// acmeResponse is the result of an ACME handler, which is turned into a raw
+// HTTP response
+type acmeResponse struct {
+	status      int
+	body        interface{}
+	raw         []byte
+	contentType string
+	location    string
+}
+
+type acmeOperation func(context.Context, *logical.Request, *framework.FieldData, *acmeContext, *acmeRequest) (*acmeResponse, error)
+
+// acmePattern returns the path pattern of an ACME resource. Resources exist
+// both for the default directory under "acme/", and for the directory of each
+// role under "acme/roles/<role>/".
+func acmePattern(suffix string) string {
+	return "acme/(roles/" + framework.GenericNameRegex("role") + "/)?" + suffix
+}
+
+func acmeFields(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["role"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `The role to issue certificates with`,
+	}
+	for _, name := range []string{"protected", "payload", "signature"} {
+		fields[name] = &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: fmt.Sprintf(`The %s part of the JWS`, name),
+		}
+	}
+	return fields
+}
+
+func pathsACME(b *backend) []*framework.Path {
+	path := func(pattern string, fields map[string]*framework.FieldSchema, op logical.Operation, handler acmeOperation, signed bool) *framework.Path {
+		return &framework.Path{
+			Pattern: acmePattern(pattern),
+			Fields:  acmeFields(fields),
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				op: b.acmeHandler(handler, signed),
+			},
+
+			HelpSynopsis:    pathACMEHelpSyn,
+			HelpDescription: pathACMEHelpDesc,
+		}
+	}
+	idField := func(names ...string) map[string]*framework.FieldSchema {
+		fields := map[string]*framework.FieldSchema{}
+		for _, name := range names {
+			fields[name] = &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `The identifier of the ACME resource`,
+			}
+		}
+		return fields
+	}
+
+	return []*framework.Path{
+		path("directory", idField(), logical.ReadOperation, b.acmeDirectory, false),
+		path("new-nonce", idField(), logical.ReadOperation, b.acmeNewNonce, false),
+		path("new-account", idField(), logical.UpdateOperation, b.acmeNewAccount, true),
+		path("account/(?P<id>[\\w-]+)", idField("id"), logical.UpdateOperation, b.acmeAccountUpdate, true),
+		path("account/(?P<id>[\\w-]+)/orders", idField("id"), logical.UpdateOperation, b.acmeAccountOrders, true),
+		path("new-order", idField(), logical.UpdateOperation, b.acmeNewOrder, true),
+		path("order/(?P<id>[\\w-]+)", idField("id"), logical.UpdateOperation, b.acmeOrderRead, true),
+		path("order/(?P<id>[\\w-]+)/finalize", idField("id"), logical.UpdateOperation, b.acmeOrderFinalize, true),
+		path("order/(?P<id>[\\w-]+)/cert", idField("id"), logical.UpdateOperation, b.acmeOrderCert, true),
+		path("authorization/(?P<id>[\\w-]+)", idField("id"), logical.UpdateOperation, b.acmeAuthorization, true),
+		path("challenge/(?P<id>[\\w-]+)/(?P<type>[\\w-]+)", idField("id", "type"), logical.UpdateOperation, b.acmeChallenge, true),
+		path("revoke-cert", idField(), logical.UpdateOperation, b.acmeRevokeCert, true),
+	}
+}
+
+// acmeHandler wraps an ACME operation with the checks common to all
+// requests, and turns its result into a raw HTTP response
+func (b *backend) acmeHandler(op acmeOperation, signed bool) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		headers := map[string][]string{
+			"Cache-Control": []string{"no-store"},
+		}
+
+		resp, err := func() (*acmeResponse, error) {
+			acmeCtx, err := b.acmeContext(ctx, req, data)
+			if err != nil {
+				return nil, err
+			}
+			headers["Link"] = []string{fmt.Sprintf(`<%s/directory>;rel="index"`, acmeCtx.prefixURL)}
+
+			var acmeReq *acmeRequest
+			if signed {
+				acmeReq, err = b.verifyACMERequest(ctx, req, acmeCtx)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			return op(ctx, req, data, acmeCtx, acmeReq)
+		}()
+		if err != nil {
+			acmeErr, ok := err.(*acmeError)
+			if !ok {
+				b.Logger().Error("error handling ACME request", "path", req.Path, "error", err)
+				acmeErr = newACMEError("serverInternal", http.StatusInternalServerError, "internal error")
+			}
+			resp = &acmeResponse{
+				status:      acmeErr.Status,
+				body:        acmeErr,
+				contentType: "application/problem+json",
+			}
+		}
+
+		nonce, err := b.acmeNonces.issue()
+		if err != nil {
+			return nil, err
+		}
+		headers["Replay-Nonce"] = []string{nonce}
+		if resp.location != "" {
+			headers["Location"] = []string{resp.location}
+		}
+
+		body := resp.raw
+		contentType := resp.contentType
+		if resp.body != nil {
+			body, err = json.Marshal(resp.body)
+			if err != nil {
+				return nil, err
+			}
+			if contentType == "" {
+				contentType = "application/json"
+			}
+		}
+
+		logicalResp := &logical.Response{
+			Data: map[string]interface{}{
+				logical.HTTPStatusCode: resp.status,
+				logical.HTTPHeaders:    headers,
+			},
+		}
+		if resp.status != http.StatusNoContent {
+			logicalResp.Data[logical.HTTPContentType] = contentType
+			logicalResp.Data[logical.HTTPRawBody] = body
+		}
+
+		return logicalResp, nil
+	}
+}
+
+// acmeContext checks that ACME is enabled for the directory the request was
+// sent to, and loads its role
+func (b *backend) acmeContext(ctx context.Context, req *logical.Request, data *framework.FieldData) (*acmeContext, error) {
+	config, err := b.ACMEConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil || !config.Enabled {
+		return nil, newACMEError("unauthorized", http.StatusForbidden, "ACME is not enabled on this mount")
+	}
+
+	urls, err := getURLs(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if urls == nil || urls.BaseURL == "" {
+		return nil, newACMEError("serverInternal", http.StatusInternalServerError, "base_url must be set in config/urls to use ACME")
+	}
+
+	acmeCtx := &acmeContext{
+		roleName:   data.Get("role").(string),
+		prefixURL:  urls.BaseURL + "/acme",
+		requestURL: urls.BaseURL + "/" + req.Path,
+	}
+	if acmeCtx.roleName != "" {
+		acmeCtx.prefixURL += "/roles/" + acmeCtx.roleName
+	} else {
+		acmeCtx.roleName = config.DefaultRole
+		if acmeCtx.roleName == "" {
+			return nil, newACMEError("malformed", http.StatusNotFound, "no default role is configured for ACME")
+		}
+	}
+
+	if !strutil.StrListContainsGlob(config.AllowedRoles, acmeCtx.roleName) {
+		return nil, newACMEError("unauthorized", http.StatusForbidden, "role %q is not allowed to be used with ACME", acmeCtx.roleName)
+	}
+	acmeCtx.role, err = b.getRole(ctx, req.Storage, acmeCtx.roleName)
+	if err != nil {
+		return nil, err
+	}
+	if acmeCtx.role == nil {
+		return nil, newACMEError("malformed", http.StatusNotFound, "unknown role %q", acmeCtx.roleName)
+	}
+
+	return acmeCtx, nil
+}
+
+// requireAccount returns the account that signed the request, failing for
+// requests signed with a bare key
+func requireAccount(acmeReq *acmeRequest) (*acmeAccount, error) {
+	if acmeReq.account == nil {
+		return nil, newACMEError("malformed", http.StatusBadRequest, "request must be signed by an account using kid")
+	}
+	return acmeReq.account, nil
+}
+
+func (b *backend) acmeDirectory(ctx context.Context, req *logical.Request, data *framework.FieldData, acmeCtx *acmeContext, acmeReq *acmeRequest) (*acmeResponse, error) {
+	return &acmeResponse{
+		status: http.StatusOK,
+		body: map[string]interface{}{
+			"newNonce":   acmeCtx.prefixURL + "/new-nonce",
+			"newAccount": acmeCtx.prefixURL + "/new-account",
+			"newOrder":   acmeCtx.prefixURL + "/new-order",
+			"revokeCert": acmeCtx.prefixURL + "/revoke-cert",
+			"meta": map[string]interface{}{
+				"externalAccountRequired": false,
+			},
+		},
+	}, nil
+}
+
+func (b *backend) acmeNewNonce(ctx context.Context, req *logical.Request, data *framework.FieldData, acmeCtx *acmeContext, acmeReq *acmeRequest) (*acmeResponse, error) {
+	// The nonce itself is added to every response
+	return &acmeResponse{
+		status: http.StatusNoContent,
+	}, nil
+}
+
+func (b *backend) acmeAccountResponse(acmeCtx *acmeContext, account *acmeAccount, status int) *acmeResponse {
+	contact := account.Contact
+	if contact == nil {
+		contact = []string{}
+	}
+
+	return &acmeResponse{
+		status:   status,
+		location: acmeCtx.prefixURL + "/account/" + account.ID,
+		body: map[string]interface{}{
+			"status":  account.Status,
+			"contact": contact,
+			"orders":  acmeCtx.prefixURL + "/account/" + account.ID + "/orders",
+		},
+	}
+}
+
+func validateACMEContact(contact []string) error {
+	for _, c := range contact {
+		if !strings.HasPrefix(c, "mailto:") {
+			return newACMEError("unsupportedContact", http.StatusBadRequest, "only mailto: contacts are supported, got %q", c)
+		}
+	}
+	return nil
+}
+
+func (b *backend) acmeNewAccount(ctx context.Context, req *logical.Request, data *framework.FieldData, acmeCtx *acmeContext, acmeReq *acmeRequest) (*acmeResponse, error) {
+	if acmeReq.jwk == nil {
+		return nil, newACMEError("malformed", http.StatusBadRequest, "new accounts must be signed using jwk")
+	}
+
+	var payload struct {
+		Contact            []string `json:"contact"`
+		OnlyReturnExisting bool     `json:"onlyReturnExisting"`
+	}
+	if err := acmeReq.decodePayload(&payload); err != nil {
+		return nil, err
+	}
+
+	// Accounts are identified by their key
+	id, err := acmeThumbprint(acmeReq.jwk)
+	if err != nil {
+		return nil, err
+	}
+
+	b.acmeLock.Lock()
+	defer b.acmeLock.Unlock()
+
+	account, err := getACMEAccount(ctx, req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+	if account != nil {
+		if account.Status != acmeStatusValid {
+			return nil, newACMEError("unauthorized", http.StatusUnauthorized, "account is %s", account.Status)
+		}
+		return b.acmeAccountResponse(acmeCtx, account, http.StatusOK), nil
+	}
+	if payload.OnlyReturnExisting {
+		return nil, newACMEError("accountDoesNotExist", http.StatusBadRequest, "no account exists for this key")
+	}
+
+	if err := validateACMEContact(payload.Contact); err != nil {
+		return nil, err
+	}
+	key, err := acmeReq.jwk.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	account = &acmeAccount{
+		ID:        id,
+		Key:       key,
+		Status:    acmeStatusValid,
+		Contact:   payload.Contact,
+		CreatedAt: time.Now(),
+	}
+	if err := putACMEEntry(ctx, req.Storage, acmeAccountPrefix+id, account); err != nil {
+		return nil, err
+	}
+
+	return b.acmeAccountResponse(acmeCtx, account, http.StatusCreated), nil
+}
+
+func (b *backend) acmeAccountUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData, acmeCtx *acmeContext, acmeReq *acmeRequest) (*acmeResponse, error) {
+	account, err := requireAccount(acmeReq)
+	if err != nil {
+		return nil, err
+	}
+	if account.ID != data.Get("id").(string) {
+		return nil, newACMEError("unauthorized", http.StatusForbidden, "request is not signed by this account")
+	}
+	if acmeReq.isPostAsGet() {
+		return b.acmeAccountResponse(acmeCtx, account, http.StatusOK), nil
+	}
+
+	var payload struct {
+		Contact []string `json:"contact"`
+		Status  string   `json:"status"`
+	}
+	if err := acmeReq.decodePayload(&payload); err != nil {
+		return nil, err
+	}
+
+	b.acmeLock.Lock()
+	defer b.acmeLock.Unlock()
+
+	if payload.Contact != nil {
+		if err := validateACMEContact(payload.Contact); err != nil {
+			return nil, err
+		}
+		account.Contact = payload.Contact
+	}
+	switch payload.Status {
+	case "":
+	case acmeStatusDeactivated:
+		account.Status = acmeStatusDeactivated
+	default:
+		return nil, newACMEError("malformed", http.StatusBadRequest, "accounts can only be deactivated")
+	}
+
+	if err := putACMEEntry(ctx, req.Storage, acmeAccountPrefix+account.ID, account); err != nil {
+		return nil, err
+	}
+
+	return b.acmeAccountResponse(acmeCtx, account, http.StatusOK), nil
+}
+
+func (b *backend) acmeAccountOrders(ctx context.Context, req *logical.Request, data *framework.FieldData, acmeCtx *acmeContext, acmeReq *acmeRequest) (*acmeResponse, error) {
+	account, err := requireAccount(acmeReq)
+	if err != nil {
+		return nil, err
+	}
+	if account.ID != data.Get("id").(string) {
+		return nil, newACMEError("unauthorized", http.StatusForbidden, "request is not signed by this account")
+	}
+
+	ids, err := req.Storage.List(ctx, acmeOrderPrefix+account.ID+"/")
+	if err != nil {
+		return nil, err
+	}
+	orders := []string{}
+	for _, id := range ids {
+		orders = append(orders, acmeCtx.prefixURL+"/order/"+id)
+	}
+
+	return &acmeResponse{
+		status: http.StatusOK,
+		body: map[string]interface{}{
+			"orders": orders,
+		},
+	}, nil
+}
+
+func (b *backend) acmeOrderResponse(acmeCtx *acmeContext, order *acmeOrder, status int) *acmeResponse {
+	authorizations := []string{}
+	for _, id := range order.AuthorizationIDs {
+		authorizations = append(authorizations, acmeCtx.prefixURL+"/authorization/"+id)
+	}
+
+	orderURL := acmeCtx.prefixURL + "/order/" + order.ID
+	body := map[string]interface{}{
+		"status":         order.Status,
+		"expires":        order.Expires.UTC().Format(time.RFC3339),
+		"identifiers":    order.Identifiers,
+		"authorizations": authorizations,
+		"finalize":       orderURL + "/finalize",
+	}
+	if order.Status == acmeStatusValid {
+		body["certificate"] = orderURL + "/cert"
+	}
+
+	return &acmeResponse{
+		status:   status,
+		location: orderURL,
+		body:     body,
+	}
+}
+
+func (b *backend) acmeNewOrder(ctx context.Context, req *logical.Request, data *framework.FieldData, acmeCtx *acmeContext, acmeReq *acmeRequest) (*acmeResponse, error) {
+	account, err := requireAccount(acmeReq)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload struct {
+		Identifiers []acmeIdentifier `json:"identifiers"`
+	}
+	if err := acmeReq.decodePayload(&payload); err != nil {
+		return nil, err
+	}
+	if len(payload.Identifiers) == 0 {
+		return nil, newACMEError("malformed", http.StatusBadRequest, "no identifiers given")
+	}
+
+	// Names are checked against the role up front, rather than failing
+	// after the client has gone through the challenges
+	var names []string
+	for i, identifier := range payload.Identifiers {
+		if identifier.Type != "dns" {
+			return nil, newACMEError("unsupportedIdentifier", http.StatusBadRequest, "unsupported identifier type %q", identifier.Type)
+		}
+		payload.Identifiers[i].Value = strings.ToLower(identifier.Value)
+		names = append(names, payload.Identifiers[i].Value)
+	}
+	if badName := validateNames(&dataBundle{role: acmeCtx.role, req: req}, names); badName != "" {
+		return nil, newACMEError("rejectedIdentifier", http.StatusBadRequest, "name %q is not allowed by role %q", badName, acmeCtx.roleName)
+	}
+
+	orderID, err := acmeRandomID()
+	if err != nil {
+		return nil, err
+	}
+	order := &acmeOrder{
+		ID:          orderID,
+		AccountID:   account.ID,
+		Role:        acmeCtx.roleName,
+		Status:      acmeStatusPending,
+		Expires:     time.Now().Add(acmeOrderTTL),
+		Identifiers: payload.Identifiers,
+	}
+
+	for _, identifier := range payload.Identifiers {
+		authzID, err := acmeRandomID()
+		if err != nil {
+			return nil, err
+		}
+		authz := &acmeAuthorization{
+			ID:        authzID,
+			AccountID: account.ID,
+			Identifier: acmeIdentifier{
+				Type:  identifier.Type,
+				Value: strings.TrimPrefix(identifier.Value, "*."),
+			},
+			Wildcard: strings.HasPrefix(identifier.Value, "*."),
+			Status:   acmeStatusPending,
+			Expires:  order.Expires,
+		}
+
+		// Wildcard names can only be validated through DNS
+		challengeTypes := []string{"http-01", "dns-01"}
+		if authz.Wildcard {
+			challengeTypes = []string{"dns-01"}
+		}
+		for _, challengeType := range challengeTypes {
+			token, err := acmeRandomID()
+			if err != nil {
+				return nil, err
+			}
+			authz.Challenges = append(authz.Challenges, &acmeChallenge{
+				Type:   challengeType,
+				Token:  token,
+				Status: acmeStatusPending,
+			})
+		}
+
+		if err := putACMEEntry(ctx, req.Storage, acmeAuthzPrefix+account.ID+"/"+authzID, authz); err != nil {
+			return nil, err
+		}
+		order.AuthorizationIDs = append(order.AuthorizationIDs, authzID)
+	}
+
+	if err := putACMEEntry(ctx, req.Storage, acmeOrderPrefix+account.ID+"/"+orderID, order); err != nil {
+		return nil, err
+	}
+
+	return b.acmeOrderResponse(acmeCtx, order, http.StatusCreated), nil
+}
+
+// acmeLoadOrder loads an order of the account that signed the request, with
+// its status brought up to date
+func (b *backend) acmeLoadOrder(ctx context.Context, req *logical.Request, data *framework.FieldData, acmeReq *acmeRequest) (*acmeOrder, error) {
+	account, err := requireAccount(acmeReq)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := getACMEOrder(ctx, req.Storage, account.ID, data.Get("id").(string))
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, newACMEError("malformed", http.StatusNotFound, "order not found")
+	}
+	if err := updateACMEOrderStatus(ctx, req.Storage, order); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+func (b *backend) acmeOrderRead(ctx context.Context, req *logical.Request, data *framework.FieldData, acmeCtx *acmeContext, acmeReq *acmeRequest) (*acmeResponse, error) {
+	order, err := b.acmeLoadOrder(ctx, req, data, acmeReq)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.acmeOrderResponse(acmeCtx, order, http.StatusOK), nil
+}
+
+func (b *backend) acmeOrderFinalize(ctx context.Context, req *logical.Request, data *framework.FieldData, acmeCtx *acmeContext, acmeReq *acmeRequest) (*acmeResponse, error) {
+	b.acmeLock.Lock()
+	defer b.acmeLock.Unlock()
+
+	order, err := b.acmeLoadOrder(ctx, req, data, acmeReq)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != acmeStatusReady {
+		return nil, newACMEError("orderNotReady", http.StatusForbidden, "order is %s", order.Status)
+	}
+
+	var payload struct {
+		CSR string `json:"csr"`
+	}
+	if err := acmeReq.decodePayload(&payload); err != nil {
+		return nil, err
+	}
+	der, err := base64.RawURLEncoding.DecodeString(payload.CSR)
+	if err != nil {
+		return nil, newACMEError("badCSR", http.StatusBadRequest, "failed to decode CSR: %v", err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, newACMEError("badCSR", http.StatusBadRequest, "failed to parse CSR: %v", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, newACMEError("badCSR", http.StatusBadRequest, "invalid CSR signature: %v", err)
+	}
+
+	// The CSR must ask for exactly the names of the order
+	requested := map[string]bool{}
+	if csr.Subject.CommonName != "" {
+		requested[strings.ToLower(csr.Subject.CommonName)] = true
+	}
+	for _, name := range csr.DNSNames {
+		requested[strings.ToLower(name)] = true
+	}
+	if len(csr.EmailAddresses) > 0 || len(csr.IPAddresses) > 0 || len(csr.URIs) > 0 {
+		return nil, newACMEError("badCSR", http.StatusBadRequest, "CSR may only contain DNS names")
+	}
+	var names []string
+	for _, identifier := range order.Identifiers {
+		names = append(names, identifier.Value)
+	}
+	if len(requested) != len(names) {
+		return nil, newACMEError("badCSR", http.StatusBadRequest, "CSR names do not match the order identifiers")
+	}
+	for _, name := range names {
+		if !requested[name] {
+			return nil, newACMEError("badCSR", http.StatusBadRequest, "CSR names do not match the order identifiers")
+		}
+	}
+
+	role, err := b.getRole(ctx, req.Storage, order.Role)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, newACMEError("unauthorized", http.StatusForbidden, "role %q no longer exists", order.Role)
+	}
+
+	// Certificates are signed subject to the role, with the names taken from
+	// the CSR since they have been checked against the order
+	signRole := *role
+	signRole.UseCSRCommonName = true
+	signRole.UseCSRSANs = true
+	signRole.GenerateLease = new(bool)
+
+	sort.Strings(names)
+	commonName := strings.ToLower(csr.Subject.CommonName)
+	if commonName == "" {
+		commonName = names[0]
+	}
+
+	// The common name is only added to the SANs if the CSR lacks it
+	var sans []string
+	for _, name := range csr.DNSNames {
+		sans = append(sans, strings.ToLower(name))
+	}
+	signData := &framework.FieldData{
+		Raw: map[string]interface{}{
+			"csr":                  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})),
+			"common_name":          commonName,
+			"exclude_cn_from_sans": strutil.StrListContains(sans, commonName),
+			"format":               "pem",
+		},
+		Schema: pathSign(b).Fields,
+	}
+	resp, err := b.pathIssueSignCert(ctx, req, signData, &signRole, true, false)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return nil, newACMEError("badCSR", http.StatusBadRequest, "%s", err.Error())
+		default:
+			return nil, err
+		}
+	}
+	if resp.IsError() {
+		return nil, newACMEError("badCSR", http.StatusBadRequest, "%s", resp.Error())
+	}
+
+	chain := []string{resp.Data["certificate"].(string)}
+	if caChain, ok := resp.Data["ca_chain"].([]string); ok && len(caChain) > 0 {
+		chain = append(chain, caChain...)
+	} else {
+		chain = append(chain, resp.Data["issuing_ca"].(string))
+	}
+
+	order.Status = acmeStatusValid
+	order.SerialNumber = resp.Data["serial_number"].(string)
+	order.Certificate = strings.Join(chain, "\n") + "\n"
+	if err := putACMEEntry(ctx, req.Storage, acmeOrderPrefix+order.AccountID+"/"+order.ID, order); err != nil {
+		return nil, err
+	}
+
+	// Remember who the certificate was issued to, so that the account can
+	// revoke it
+	if err := putACMEEntry(ctx, req.Storage, acmeCertPrefix+normalizeSerial(order.SerialNumber), map[string]string{
+		"account_id": order.AccountID,
+	}); err != nil {
+		return nil, err
+	}
+
+	return b.acmeOrderResponse(acmeCtx, order, http.StatusOK), nil
+}
+
+func (b *backend) acmeOrderCert(ctx context.Context, req *logical.Request, data *framework.FieldData, acmeCtx *acmeContext, acmeReq *acmeRequest) (*acmeResponse, error) {
+	order, err := b.acmeLoadOrder(ctx, req, data, acmeReq)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != acmeStatusValid {
+		return nil, newACMEError("malformed", http.StatusNotFound, "order has no certificate")
+	}
+
+	return &acmeResponse{
+		status:      http.StatusOK,
+		raw:         []byte(order.Certificate),
+		contentType: "application/pem-certificate-chain",
+	}, nil
+}
+
+func (b *backend) acmeAuthorizationResponse(acmeCtx *acmeContext, authz *acmeAuthorization, status int) *acmeResponse {
+	challenges := []interface{}{}
+	for _, c := range authz.Challenges {
+		challenges = append(challenges, acmeChallengeBody(acmeCtx, authz, c))
+	}
+
+	body := map[string]interface{}{
+		"identifier": authz.Identifier,
+		"status":     authz.Status,
+		"expires":    authz.Expires.UTC().Format(time.RFC3339),
+		"challenges": challenges,
+	}
+	if authz.Wildcard {
+		body["wildcard"] = true
+	}
+
+	return &acmeResponse{
+		status: status,
+		body:   body,
+	}
+}
+
+func acmeChallengeBody(acmeCtx *acmeContext, authz *acmeAuthorization, c *acmeChallenge) map[string]interface{} {
+	body := map[string]interface{}{
+		"type":   c.Type,
+		"url":    acmeCtx.prefixURL + "/challenge/" + authz.ID + "/" + c.Type,
+		"token":  c.Token,
+		"status": c.Status,
+	}
+	if !c.Validated.IsZero() {
+		body["validated"] = c.Validated.UTC().Format(time.RFC3339)
+	}
+	if c.Error != nil {
+		body["error"] = c.Error
+	}
+	return body
+}
+
+func (b *backend) acmeAuthorization(ctx context.Context, req *logical.Request, data *framework.FieldData, acmeCtx *acmeContext, acmeReq *acmeRequest) (*acmeResponse, error) {
+	account, err := requireAccount(acmeReq)
+	if err != nil {
+		return nil, err
+	}
+
+	b.acmeLock.Lock()
+	defer b.acmeLock.Unlock()
+
+	authz, err := getACMEAuthorization(ctx, req.Storage, account.ID, data.Get("id").(string))
+	if err != nil {
+		return nil, err
+	}
+	if authz == nil {
+		return nil, newACMEError("malformed", http.StatusNotFound, "authorization not found")
+	}
+	if acmeReq.isPostAsGet() {
+		return b.acmeAuthorizationResponse(acmeCtx, authz, http.StatusOK), nil
+	}
+
+	var payload struct {
+		Status string `json:"status"`
+	}
+	if err := acmeReq.decodePayload(&payload); err != nil {
+		return nil, err
+	}
+	if payload.Status != acmeStatusDeactivated {
+		return nil, newACMEError("malformed", http.StatusBadRequest, "authorizations can only be deactivated")
+	}
+	if authz.Status != acmeStatusPending && authz.Status != acmeStatusValid {
+		return nil, newACMEError("malformed", http.StatusBadRequest, "authorization is %s", authz.Status)
+	}
+
+	authz.Status = acmeStatusDeactivated
+	if err := putACMEEntry(ctx, req.Storage, acmeAuthzPrefix+account.ID+"/"+authz.ID, authz); err != nil {
+		return nil, err
+	}
+
+	return b.acmeAuthorizationResponse(acmeCtx, authz, http.StatusOK), nil
+}
+
+func (b *backend) acmeChallenge(ctx context.Context, req *logical.Request, data *framework.FieldData, acmeCtx *acmeContext, acmeReq *acmeRequest) (*acmeResponse, error) {
+	account, err := requireAccount(acmeReq)
+	if err != nil {
+		return nil, err
+	}
+	authzID := data.Get("id").(string)
+	challengeType := data.Get("type").(string)
+
+	authz, err := getACMEAuthorization(ctx, req.Storage, account.ID, authzID)
+	if err != nil {
+		return nil, err
+	}
+	if authz == nil || authz.challenge(challengeType) == nil {
+		return nil, newACMEError("malformed", http.StatusNotFound, "challenge not found")
+	}
+	challenge := authz.challenge(challengeType)
+
+	// Only pending challenges are validated, otherwise the challenge is
+	// returned as it is
+	if acmeReq.isPostAsGet() || authz.Status != acmeStatusPending || challenge.Status != acmeStatusPending {
+		return &acmeResponse{
+			status: http.StatusOK,
+			body:   acmeChallengeBody(acmeCtx, authz, challenge),
+		}, nil
+	}
+
+	key := &jose.JSONWebKey{}
+	if err := key.UnmarshalJSON(account.Key); err != nil {
+		return nil, err
+	}
+	thumbprint, err := acmeThumbprint(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validation makes requests to the client, so it is done without
+	// holding the lock
+	validationErr := b.acmeValidator.validate(challengeType, authz.Identifier.Value, challenge.Token, challenge.Token+"."+thumbprint)
+
+	b.acmeLock.Lock()
+	defer b.acmeLock.Unlock()
+
+	authz, err = getACMEAuthorization(ctx, req.Storage, account.ID, authzID)
+	if err != nil {
+		return nil, err
+	}
+	if authz == nil {
+		return nil, newACMEError("malformed", http.StatusNotFound, "challenge not found")
+	}
+	challenge = authz.challenge(challengeType)
+
+	if authz.Status == acmeStatusPending && challenge.Status == acmeStatusPending {
+		if validationErr != nil {
+			challenge.Status = acmeStatusInvalid
+			challenge.Error = validationErr
+			authz.Status = acmeStatusInvalid
+		} else {
+			challenge.Status = acmeStatusValid
+			challenge.Validated = time.Now()
+			authz.Status = acmeStatusValid
+		}
+		if err := putACMEEntry(ctx, req.Storage, acmeAuthzPrefix+account.ID+"/"+authz.ID, authz); err != nil {
+			return nil, err
+		}
+	}
+
+	return &acmeResponse{
+		status: http.StatusOK,
+		body:   acmeChallengeBody(acmeCtx, authz, challenge),
+	}, nil
+}
+
+func (b *backend) acmeRevokeCert(ctx context.Context, req *logical.Request, data *framework.FieldData, acmeCtx *acmeContext, acmeReq *acmeRequest) (*acmeResponse, error) {
+	var payload struct {
+		Certificate string `json:"certificate"`
+	}
+	if err := acmeReq.decodePayload(&payload); err != nil {
+		return nil, err
+	}
+	der, err := base64.RawURLEncoding.DecodeString(payload.Certificate)
+	if err != nil {
+		return nil, newACMEError("malformed", http.StatusBadRequest, "failed to decode certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, newACMEError("malformed", http.StatusBadRequest, "failed to parse certificate: %v", err)
+	}
+	serial := certutil.GetHexFormatted(cert.SerialNumber.Bytes(), ":")
+
+	// Only certificates issued through ACME can be revoked here, either by
+	// the account they were issued to or with their own key
+	var issued struct {
+		AccountID string `json:"account_id"`
+	}
+	ok, err := getACMEEntry(ctx, req.Storage, acmeCertPrefix+normalizeSerial(serial), &issued)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, newACMEError("unauthorized", http.StatusForbidden, "certificate was not issued through ACME")
+	}
+	certEntry, err := fetchCertBySerial(ctx, req, "certs/", serial)
+	if err != nil {
+		return nil, err
+	}
+	if certEntry != nil && !bytes.Equal(certEntry.Value, der) {
+		return nil, newACMEError("unauthorized", http.StatusForbidden, "certificate was not issued by this mount")
+	}
+
+	switch {
+	case acmeReq.account != nil:
+		if acmeReq.account.ID != issued.AccountID {
+			return nil, newACMEError("unauthorized", http.StatusForbidden, "certificate was not issued to this account")
+		}
+	default:
+		certKey, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		requestKey, err := x509.MarshalPKIXPublicKey(acmeReq.jwk.Key)
+		if err != nil || !bytes.Equal(certKey, requestKey) {
+			return nil, newACMEError("unauthorized", http.StatusForbidden, "request is not signed with the certificate key")
+		}
+	}
+
+	b.revokeStorageLock.Lock()
+	defer b.revokeStorageLock.Unlock()
+
+	revokedEntry, err := fetchCertBySerial(ctx, req, "revoked/", serial)
+	if err != nil {
+		return nil, err
+	}
+	if revokedEntry != nil {
+		return nil, newACMEError("alreadyRevoked", http.StatusBadRequest, "certificate is already revoked")
+	}
+
+	resp, err := revokeCert(ctx, b, req, serial, false)
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, newACMEError("malformed", http.StatusBadRequest, "%s", resp.Error())
+	}
+
+	return &acmeResponse{
+		status:      http.StatusOK,
+		contentType: "application/json",
+	}, nil
+}
+
+const pathACMEHelpSyn = `
+ACME (RFC 8555) server for issuing certificates.
+`
+
+const pathACMEHelpDesc = `
+These endpoints implement an ACME server, which issues certificates to ACME
+clients such as certbot after they prove control of the requested names
+using the http-01 or dns-01 challenges. Certificates are issued subject to
+the constraints of a role.
+
+The directory at "acme/directory" issues certificates with the default role
+of "config/acme", and the directory at "acme/roles/<role>/directory" issues
+with the given role. ACME must be enabled in "config/acme" first.
+`
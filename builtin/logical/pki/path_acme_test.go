@@ -0,0 +1,511 @@
+package pki
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const testACMEBaseURL = "https://vault.example.com:8200/v1/pki"
+
+// testACMEClient is a minimal ACME client that talks to the backend directly
+type testACMEClient struct {
+	t       *testing.T
+	b       *backend
+	storage logical.Storage
+	key     *ecdsa.PrivateKey
+	kid     string
+	nonce   string
+}
+
+type testACMEResponse struct {
+	status  int
+	headers map[string][]string
+	body    []byte
+}
+
+func (r *testACMEResponse) decode(t *testing.T, out interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(r.body, out); err != nil {
+		t.Fatalf("failed to decode %q: %v", r.body, err)
+	}
+}
+
+func (r *testACMEResponse) problem(t *testing.T) string {
+	t.Helper()
+	var problem acmeError
+	r.decode(t, &problem)
+	return strings.TrimPrefix(problem.Type, "urn:ietf:params:acme:error:")
+}
+
+func (c *testACMEClient) do(op logical.Operation, path string, data map[string]interface{}) *testACMEResponse {
+	c.t.Helper()
+
+	resp, err := c.b.HandleRequest(context.Background(), &logical.Request{
+		Operation: op,
+		Path:      path,
+		Storage:   c.storage,
+		Data:      data,
+	})
+	if err != nil || resp == nil {
+		c.t.Fatalf("%s: err: %v, resp: %#v", path, err, resp)
+	}
+
+	headers := resp.Data[logical.HTTPHeaders].(map[string][]string)
+	if nonce := headers["Replay-Nonce"]; len(nonce) == 1 {
+		c.nonce = nonce[0]
+	} else {
+		c.t.Fatalf("%s: no nonce returned", path)
+	}
+	body, _ := resp.Data[logical.HTTPRawBody].([]byte)
+
+	return &testACMEResponse{
+		status:  resp.Data[logical.HTTPStatusCode].(int),
+		headers: headers,
+		body:    body,
+	}
+}
+
+// post sends a JWS signed request for the path, with the nonce and URL given
+// in the protected header
+func (c *testACMEClient) postWith(path string, payload interface{}, nonce, url string) *testACMEResponse {
+	c.t.Helper()
+
+	// POST-as-GET requests have an empty, rather than a missing, payload
+	payloadBytes := []byte{}
+	if payload != nil {
+		var err error
+		if payloadBytes, err = json.Marshal(payload); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+
+	opts := &jose.SignerOptions{}
+	opts.WithHeader("nonce", nonce)
+	opts.WithHeader("url", url)
+	if c.kid != "" {
+		opts.WithHeader("kid", c.kid)
+	} else {
+		opts.EmbedJWK = true
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: c.key}, opts)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	jws, err := signer.Sign(payloadBytes)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(jws.FullSerialize()), &data); err != nil {
+		c.t.Fatal(err)
+	}
+
+	return c.do(logical.UpdateOperation, path, data)
+}
+
+func (c *testACMEClient) post(path string, payload interface{}) *testACMEResponse {
+	c.t.Helper()
+	if c.nonce == "" {
+		c.do(logical.ReadOperation, "acme/new-nonce", nil)
+	}
+	return c.postWith(path, payload, c.nonce, testACMEBaseURL+"/"+path)
+}
+
+// path returns the backend path of an ACME URL
+func (c *testACMEClient) path(url string) string {
+	c.t.Helper()
+	if !strings.HasPrefix(url, testACMEBaseURL+"/") {
+		c.t.Fatalf("unexpected URL %q", url)
+	}
+	return strings.TrimPrefix(url, testACMEBaseURL+"/")
+}
+
+func (c *testACMEClient) keyAuthorization(token string) string {
+	c.t.Helper()
+	thumbprint, err := acmeThumbprint(&jose.JSONWebKey{Key: c.key.Public()})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return token + "." + thumbprint
+}
+
+type testACMEOrder struct {
+	Status         string   `json:"status"`
+	Authorizations []string `json:"authorizations"`
+	Finalize       string   `json:"finalize"`
+	Certificate    string   `json:"certificate"`
+}
+
+type testACMEAuthorization struct {
+	Identifier acmeIdentifier `json:"identifier"`
+	Status     string         `json:"status"`
+	Wildcard   bool           `json:"wildcard"`
+	Challenges []struct {
+		Type   string `json:"type"`
+		URL    string `json:"url"`
+		Token  string `json:"token"`
+		Status string `json:"status"`
+	} `json:"challenges"`
+}
+
+func TestBackend_ACME(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	write := func(path string, data map[string]interface{}) {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err: %v, resp: %#v", path, err, resp)
+		}
+	}
+	write("root/generate/internal", map[string]interface{}{
+		"common_name": "Root CA",
+		"ttl":         "40h",
+	})
+	write("roles/example", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"key_type":         "ec",
+		"key_bits":         256,
+		"ttl":              "1h",
+	})
+	write("config/urls", map[string]interface{}{
+		"base_url": testACMEBaseURL,
+	})
+
+	// Local stand-ins for the http-01 and dns-01 challenge responders
+	var responderLock sync.Mutex
+	httpResponses := map[string]string{}
+	txtRecords := map[string][]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		responderLock.Lock()
+		defer responderLock.Unlock()
+		resp, ok := httpResponses[strings.TrimPrefix(r.URL.Path, "/.well-known/acme-challenge/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(resp))
+	}))
+	defer server.Close()
+	_, port, err := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.acmeValidator.httpPort, _ = strconv.Atoi(port)
+	b.acmeValidator.lookupTXT = func(name string) ([]string, error) {
+		responderLock.Lock()
+		defer responderLock.Unlock()
+		return txtRecords[name], nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &testACMEClient{
+		t:       t,
+		b:       b,
+		storage: storage,
+		key:     key,
+	}
+
+	// ACME has to be enabled first
+	resp := client.do(logical.ReadOperation, "acme/directory", nil)
+	if resp.status != http.StatusForbidden || resp.problem(t) != "unauthorized" {
+		t.Fatalf("expected ACME to be disabled, got %d: %s", resp.status, resp.body)
+	}
+	write("config/acme", map[string]interface{}{
+		"enabled": true,
+	})
+
+	// Without a default role, only role directories are served
+	resp = client.do(logical.ReadOperation, "acme/directory", nil)
+	if resp.status != http.StatusNotFound {
+		t.Fatalf("expected no default directory, got %d: %s", resp.status, resp.body)
+	}
+	resp = client.do(logical.ReadOperation, "acme/roles/example/directory", nil)
+	var directory map[string]interface{}
+	resp.decode(t, &directory)
+	if resp.status != http.StatusOK || directory["newAccount"] != testACMEBaseURL+"/acme/roles/example/new-account" {
+		t.Fatalf("bad directory: %d: %s", resp.status, resp.body)
+	}
+	write("config/acme", map[string]interface{}{
+		"default_role": "example",
+	})
+	resp = client.do(logical.ReadOperation, "acme/directory", nil)
+	resp.decode(t, &directory)
+	if resp.status != http.StatusOK || directory["newOrder"] != testACMEBaseURL+"/acme/new-order" {
+		t.Fatalf("bad directory: %d: %s", resp.status, resp.body)
+	}
+
+	// Accounts
+	resp = client.post("acme/new-account", map[string]interface{}{
+		"onlyReturnExisting": true,
+	})
+	if resp.status != http.StatusBadRequest || resp.problem(t) != "accountDoesNotExist" {
+		t.Fatalf("expected no account, got %d: %s", resp.status, resp.body)
+	}
+	resp = client.post("acme/new-account", map[string]interface{}{
+		"contact":              []string{"mailto:admin@example.com"},
+		"termsOfServiceAgreed": true,
+	})
+	if resp.status != http.StatusCreated || len(resp.headers["Location"]) != 1 {
+		t.Fatalf("bad new account response: %d: %s", resp.status, resp.body)
+	}
+	client.kid = resp.headers["Location"][0]
+
+	resp = client.post("acme/new-order", map[string]interface{}{
+		"identifiers": []acmeIdentifier{{Type: "dns", Value: "evil.com"}},
+	})
+	if resp.status != http.StatusBadRequest || resp.problem(t) != "rejectedIdentifier" {
+		t.Fatalf("expected identifier to be rejected, got %d: %s", resp.status, resp.body)
+	}
+
+	// Replayed nonces and mismatched URLs are refused
+	nonce := client.nonce
+	resp = client.post(client.path(client.kid), nil)
+	if resp.status != http.StatusOK {
+		t.Fatalf("bad account response: %d: %s", resp.status, resp.body)
+	}
+	resp = client.postWith(client.path(client.kid), nil, nonce, client.kid)
+	if resp.status != http.StatusBadRequest || resp.problem(t) != "badNonce" {
+		t.Fatalf("expected bad nonce, got %d: %s", resp.status, resp.body)
+	}
+	resp = client.postWith("acme/new-order", nil, client.nonce, client.kid)
+	if resp.status != http.StatusUnauthorized || resp.problem(t) != "unauthorized" {
+		t.Fatalf("expected URL mismatch, got %d: %s", resp.status, resp.body)
+	}
+
+	// Orders
+	resp = client.post("acme/new-order", map[string]interface{}{
+		"identifiers": []acmeIdentifier{
+			// The http-01 stand-in listens on localhost
+			{Type: "dns", Value: "localhost"},
+			{Type: "dns", Value: "*.example.com"},
+		},
+	})
+	if resp.status != http.StatusCreated {
+		t.Fatalf("bad new order response: %d: %s", resp.status, resp.body)
+	}
+	orderURL := resp.headers["Location"][0]
+	var order testACMEOrder
+	resp.decode(t, &order)
+	if order.Status != acmeStatusPending || len(order.Authorizations) != 2 {
+		t.Fatalf("bad order: %s", resp.body)
+	}
+
+	csrKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "localhost"},
+		DNSNames: []string{"localhost", "*.example.com"},
+	}, csrKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	finalize := map[string]interface{}{
+		"csr": base64.RawURLEncoding.EncodeToString(csr),
+	}
+	resp = client.post(client.path(order.Finalize), finalize)
+	if resp.status != http.StatusForbidden || resp.problem(t) != "orderNotReady" {
+		t.Fatalf("expected order not to be ready, got %d: %s", resp.status, resp.body)
+	}
+
+	for _, authzURL := range order.Authorizations {
+		resp = client.post(client.path(authzURL), nil)
+		var authz testACMEAuthorization
+		resp.decode(t, &authz)
+		if authz.Status != acmeStatusPending {
+			t.Fatalf("bad authorization: %s", resp.body)
+		}
+
+		// Wildcards are validated with dns-01, other names with http-01
+		challengeType := "http-01"
+		if authz.Wildcard {
+			challengeType = "dns-01"
+			if len(authz.Challenges) != 1 {
+				t.Fatalf("expected only dns-01 for wildcards: %s", resp.body)
+			}
+		}
+		for _, challenge := range authz.Challenges {
+			if challenge.Type != challengeType {
+				continue
+			}
+			keyAuthz := client.keyAuthorization(challenge.Token)
+			responderLock.Lock()
+			if challengeType == "http-01" {
+				httpResponses[challenge.Token] = keyAuthz
+			} else {
+				digest := sha256.Sum256([]byte(keyAuthz))
+				txtRecords["_acme-challenge."+authz.Identifier.Value] = []string{base64.RawURLEncoding.EncodeToString(digest[:])}
+			}
+			responderLock.Unlock()
+
+			resp = client.post(client.path(challenge.URL), map[string]interface{}{})
+			var result map[string]interface{}
+			resp.decode(t, &result)
+			if resp.status != http.StatusOK || result["status"] != acmeStatusValid {
+				t.Fatalf("bad %s challenge response: %d: %s", challengeType, resp.status, resp.body)
+			}
+		}
+	}
+
+	resp = client.post(client.path(orderURL), nil)
+	resp.decode(t, &order)
+	if order.Status != acmeStatusReady {
+		t.Fatalf("expected order to be ready: %s", resp.body)
+	}
+
+	// The CSR has to match the order
+	badCSR, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		DNSNames: []string{"localhost"},
+	}, csrKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = client.post(client.path(order.Finalize), map[string]interface{}{
+		"csr": base64.RawURLEncoding.EncodeToString(badCSR),
+	})
+	if resp.status != http.StatusBadRequest || resp.problem(t) != "badCSR" {
+		t.Fatalf("expected bad CSR, got %d: %s", resp.status, resp.body)
+	}
+
+	resp = client.post(client.path(order.Finalize), finalize)
+	resp.decode(t, &order)
+	if resp.status != http.StatusOK || order.Status != acmeStatusValid || order.Certificate == "" {
+		t.Fatalf("bad finalize response: %d: %s", resp.status, resp.body)
+	}
+
+	resp = client.post(client.path(order.Certificate), nil)
+	if resp.headers["Location"] != nil || resp.status != http.StatusOK {
+		t.Fatalf("bad certificate response: %d: %s", resp.status, resp.body)
+	}
+	block, rest := pem.Decode(resp.body)
+	if block == nil {
+		t.Fatalf("bad certificate chain: %s", resp.body)
+	}
+	if ca, _ := pem.Decode(rest); ca == nil {
+		t.Fatalf("expected issuing CA in chain: %s", resp.body)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cert.DNSNames) != 2 || cert.PublicKey.(*ecdsa.PublicKey).X.Cmp(csrKey.X) != 0 {
+		t.Fatalf("bad certificate: %v", cert.DNSNames)
+	}
+
+	// The account can revoke the certificate, once
+	revoke := map[string]interface{}{
+		"certificate": base64.RawURLEncoding.EncodeToString(block.Bytes),
+	}
+	resp = client.post("acme/revoke-cert", revoke)
+	if resp.status != http.StatusOK {
+		t.Fatalf("bad revoke response: %d: %s", resp.status, resp.body)
+	}
+	resp = client.post("acme/revoke-cert", revoke)
+	if resp.status != http.StatusBadRequest || resp.problem(t) != "alreadyRevoked" {
+		t.Fatalf("expected already revoked, got %d: %s", resp.status, resp.body)
+	}
+
+	// Failed challenges invalidate the order
+	resp = client.post("acme/new-order", map[string]interface{}{
+		"identifiers": []acmeIdentifier{{Type: "dns", Value: "other.example.com"}},
+	})
+	orderURL = resp.headers["Location"][0]
+	resp.decode(t, &order)
+	resp = client.post(client.path(order.Authorizations[0]), nil)
+	var authz testACMEAuthorization
+	resp.decode(t, &authz)
+	for _, challenge := range authz.Challenges {
+		if challenge.Type != "dns-01" {
+			continue
+		}
+		resp = client.post(client.path(challenge.URL), map[string]interface{}{})
+		var result map[string]interface{}
+		resp.decode(t, &result)
+		if result["status"] != acmeStatusInvalid || result["error"] == nil {
+			t.Fatalf("expected invalid challenge: %s", resp.body)
+		}
+	}
+	resp = client.post(client.path(orderURL), nil)
+	resp.decode(t, &order)
+	if order.Status != acmeStatusInvalid {
+		t.Fatalf("expected invalid order: %s", resp.body)
+	}
+
+	// Deactivated accounts can no longer be used
+	resp = client.post(client.path(client.kid), map[string]interface{}{
+		"status": "deactivated",
+	})
+	if resp.status != http.StatusOK {
+		t.Fatalf("bad deactivate response: %d: %s", resp.status, resp.body)
+	}
+	resp = client.post(client.path(client.kid), nil)
+	if resp.status != http.StatusUnauthorized {
+		t.Fatalf("expected deactivated account, got %d: %s", resp.status, resp.body)
+	}
+}
+
+func TestBackend_ACMENonces(t *testing.T) {
+	nonces := newACMENonces()
+
+	first, err := nonces.issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := nonces.issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nonces.redeem(second) || nonces.redeem(second) {
+		t.Fatal("expected the nonce to be redeemed once")
+	}
+
+	// Expired nonces are dropped when a nonce is issued
+	nonces.order.Front().Value.(*acmeNonce).expiry = time.Now().Add(-time.Second)
+	if _, err := nonces.issue(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := nonces.nonces[first]; ok {
+		t.Fatal("expected the expired nonce to be dropped")
+	}
+
+	// The oldest nonces are dropped past the limit
+	for i := 0; i < acmeMaxNonces+10; i++ {
+		if _, err := nonces.issue(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(nonces.nonces) != acmeMaxNonces || nonces.order.Len() != acmeMaxNonces {
+		t.Fatalf("expected %d nonces, got %d", acmeMaxNonces, len(nonces.nonces))
+	}
+}
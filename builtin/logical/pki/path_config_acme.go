@@ -0,0 +1,140 @@
+package pki
+
+import (
+	"context"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+type acmeConfig struct {
+	Enabled      bool     `json:"enabled"`
+	DefaultRole  string   `json:"default_role"`
+	AllowedRoles []string `json:"allowed_roles"`
+}
+
+func pathConfigACME(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/acme",
+		Fields: map[string]*framework.FieldSchema{
+			"enabled": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set to true, enables the ACME server of this
+mount. Defaults to false.`,
+			},
+			"default_role": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The role used to issue certificates through the
+acme/directory endpoint. If not set, only the per-role directories under
+acme/roles/ can be used.`,
+			},
+			"allowed_roles": &framework.FieldSchema{
+				Type: framework.TypeCommaStringSlice,
+				Description: `Comma-separated list of roles, which may contain
+globs, that certificates can be issued through using ACME. Defaults to all
+roles.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathACMEConfigRead,
+			logical.UpdateOperation: b.pathACMEConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigACMEHelpSyn,
+		HelpDescription: pathConfigACMEHelpDesc,
+	}
+}
+
+func (b *backend) ACMEConfig(ctx context.Context, s logical.Storage) (*acmeConfig, error) {
+	entry, err := s.Get(ctx, "config/acme")
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result acmeConfig
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (b *backend) pathACMEConfigRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.ACMEConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"enabled":       config.Enabled,
+			"default_role":  config.DefaultRole,
+			"allowed_roles": config.AllowedRoles,
+		},
+	}, nil
+}
+
+func (b *backend) pathACMEConfigWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := b.ACMEConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = &acmeConfig{
+			AllowedRoles: []string{"*"},
+		}
+	}
+
+	if enabledRaw, ok := data.GetOk("enabled"); ok {
+		config.Enabled = enabledRaw.(bool)
+	}
+	if defaultRoleRaw, ok := data.GetOk("default_role"); ok {
+		config.DefaultRole = defaultRoleRaw.(string)
+	}
+	if allowedRolesRaw, ok := data.GetOk("allowed_roles"); ok {
+		config.AllowedRoles = allowedRolesRaw.([]string)
+	}
+
+	if config.DefaultRole != "" {
+		role, err := b.getRole(ctx, req.Storage, config.DefaultRole)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return logical.ErrorResponse("default_role does not exist"), nil
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON("config/acme", config)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const pathConfigACMEHelpSyn = `
+Configure the ACME server of this mount.
+`
+
+const pathConfigACMEHelpDesc = `
+This endpoint allows enabling the ACME (RFC 8555) server of this mount and
+selecting the roles that certificates can be issued with.
+
+The ACME directory of a role is served at "acme/roles/<role>/directory", and
+the directory at "acme/directory" issues with the "default_role". ACME
+clients are not authenticated with Vault tokens, but have to prove control of
+the names they request using the http-01 or dns-01 challenges, and names are
+further restricted by the role. The "base_url" of "config/urls" must be set
+so that the URLs of ACME resources can be built.
+`
@@ -3,6 +3,7 @@ package pki
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/fatih/structs"
@@ -31,6 +32,18 @@ for the CRL distribution points attribute`,
 				Description: `Comma-separated list of URLs to be used
 for the OCSP servers attribute`,
 			},
+
+			"base_url": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `URL of this mount as reachable by clients,
+e.g. https://vault.example.com:8200/v1/pki`,
+			},
+
+			"advertise_ocsp": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Whether to add this mount's OCSP endpoint,
+under base_url, to the OCSP servers attribute`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -137,6 +150,21 @@ func (b *backend) pathWriteURL(ctx context.Context, req *logical.Request, data *
 				"invalid URL found in OCSP servers: %s", badURL)), nil
 		}
 	}
+	if baseURL, ok := data.GetOk("base_url"); ok {
+		entries.BaseURL = strings.TrimSuffix(baseURL.(string), "/")
+		if entries.BaseURL != "" {
+			if badURL := validateURLs([]string{entries.BaseURL}); badURL != "" {
+				return logical.ErrorResponse(fmt.Sprintf(
+					"invalid base URL: %s", badURL)), nil
+			}
+		}
+	}
+	if advertiseOCSP, ok := data.GetOk("advertise_ocsp"); ok {
+		entries.AdvertiseOCSP = advertiseOCSP.(bool)
+	}
+	if entries.AdvertiseOCSP && entries.BaseURL == "" {
+		return logical.ErrorResponse("base_url must be set to advertise the OCSP endpoint"), nil
+	}
 
 	return nil, writeURLs(ctx, req, entries)
 }
@@ -145,6 +173,18 @@ type urlEntries struct {
 	IssuingCertificates   []string `json:"issuing_certificates" structs:"issuing_certificates" mapstructure:"issuing_certificates"`
 	CRLDistributionPoints []string `json:"crl_distribution_points" structs:"crl_distribution_points" mapstructure:"crl_distribution_points"`
 	OCSPServers           []string `json:"ocsp_servers" structs:"ocsp_servers" mapstructure:"ocsp_servers"`
+	BaseURL               string   `json:"base_url" structs:"base_url" mapstructure:"base_url"`
+	AdvertiseOCSP         bool     `json:"advertise_ocsp" structs:"advertise_ocsp" mapstructure:"advertise_ocsp"`
+}
+
+// ocspServers returns the OCSP server URLs to encode into issued
+// certificates, including this mount's own OCSP endpoint if it is advertised
+func (u *urlEntries) ocspServers() []string {
+	if !u.AdvertiseOCSP || u.BaseURL == "" {
+		return u.OCSPServers
+	}
+
+	return append(append([]string{}, u.OCSPServers...), u.BaseURL+"/ocsp")
 }
 
 const pathConfigURLsHelpSyn = `
@@ -159,4 +199,8 @@ certificates. To delete URLs, simply re-set the appropriate value with an
 empty string.
 
 Multiple URLs can be specified for each type; use commas to separate them.
+
+If "advertise_ocsp" is set, the "ocsp" endpoint of this mount is added to the
+OCSP servers of issued certificates. This requires "base_url" to be set to
+the URL at which clients reach this mount.
 `
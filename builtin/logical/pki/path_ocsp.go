@@ -0,0 +1,112 @@
+package pki
+
+import (
+	"context"
+	"encoding/base64"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// Answers OCSP requests sent with POST, with the DER encoded request as the
+// body
+func pathOCSP(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `ocsp`,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathOCSPWrite,
+		},
+
+		HelpSynopsis:    pathOCSPHelpSyn,
+		HelpDescription: pathOCSPHelpDesc,
+	}
+}
+
+// Answers OCSP requests sent with GET, with the base64 encoded request as the
+// last path segment
+func pathOCSPGet(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `ocsp/(?P<req>.+)`,
+		Fields: map[string]*framework.FieldSchema{
+			"req": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Base64 encoded OCSP request`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathOCSPRead,
+		},
+
+		HelpSynopsis:    pathOCSPHelpSyn,
+		HelpDescription: pathOCSPHelpDesc,
+	}
+}
+
+func (b *backend) pathOCSPRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	der, err := base64.StdEncoding.DecodeString(data.Get("req").(string))
+	if err != nil {
+		return ocspRawResponse(ocspErrorResponse(ocspMalformedRequest)), nil
+	}
+
+	return b.ocspRespond(ctx, req, der), nil
+}
+
+func (b *backend) pathOCSPWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	var der []byte
+	switch body := req.Data[logical.HTTPRawBody].(type) {
+	case []byte:
+		der = body
+	case string:
+		// Raw bodies are base64 encoded when the request has been through
+		// JSON, e.g. when forwarded
+		var err error
+		der, err = base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return ocspRawResponse(ocspErrorResponse(ocspMalformedRequest)), nil
+		}
+	default:
+		return ocspRawResponse(ocspErrorResponse(ocspMalformedRequest)), nil
+	}
+
+	return b.ocspRespond(ctx, req, der), nil
+}
+
+func (b *backend) ocspRespond(ctx context.Context, req *logical.Request, der []byte) *logical.Response {
+	b.revokeStorageLock.RLock()
+	defer b.revokeStorageLock.RUnlock()
+
+	resp, err := buildOCSPResponse(ctx, req, der)
+	if err != nil {
+		// Errors can only be returned to OCSP clients as a status
+		b.Logger().Error("error building OCSP response", "error", err)
+		resp = ocspErrorResponse(ocspInternalError)
+	}
+
+	return ocspRawResponse(resp)
+}
+
+func ocspRawResponse(body []byte) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/ocsp-response",
+			logical.HTTPRawBody:     body,
+			logical.HTTPStatusCode:  200,
+		},
+	}
+}
+
+const pathOCSPHelpSyn = `
+Query the revocation status of certificates using OCSP.
+`
+
+const pathOCSPHelpDesc = `
+This endpoint answers OCSP (RFC 6960) requests for certificates issued by
+this backend's CA, using the revocation information from the "revoke"
+endpoint. Responses are signed by the CA.
+
+Requests can be sent either with GET, with the base64 encoded request
+appended to the path, or with POST, with the DER encoded request as the body
+and a content type of "application/ocsp-request".
+`
@@ -0,0 +1,279 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/vault"
+	"golang.org/x/crypto/ocsp"
+)
+
+func testOCSPRequest(t *testing.T, issuer *x509.Certificate, serial *big.Int, nonce []byte) []byte {
+	t.Helper()
+
+	publicKey, err := subjectPublicKey(issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nameHash := sha1.Sum(issuer.RawSubject)
+	keyHash := sha1.Sum(publicKey)
+
+	req := ocspRequest{
+		TBSRequest: ocspTBSRequest{
+			RequestList: []ocspSingleRequest{
+				{
+					Cert: ocspCertID{
+						HashAlgorithm: pkix.AlgorithmIdentifier{
+							Algorithm:  oidSHA1,
+							Parameters: asn1.NullRawValue,
+						},
+						NameHash:      nameHash[:],
+						IssuerKeyHash: keyHash[:],
+						SerialNumber:  serial,
+					},
+				},
+			},
+		},
+	}
+	if nonce != nil {
+		nonceDER, err := asn1.Marshal(nonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.TBSRequest.Extensions = []pkix.Extension{
+			{
+				Id:    oidOCSPNonce,
+				Value: nonceDER,
+			},
+		}
+	}
+
+	der, err := asn1.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// testOCSPResponseData holds the fields of an OCSP ResponseData that the ocsp
+// package does not expose, decoded independently of the responder's structs
+type testOCSPResponseData struct {
+	Version     int `asn1:"optional,default:0,explicit,tag:0"`
+	ResponderID asn1.RawValue
+	ProducedAt  time.Time `asn1:"generalized"`
+	Responses   []asn1.RawValue
+	Extensions  []pkix.Extension `asn1:"optional,explicit,tag:1"`
+}
+
+// testOCSPNonce returns the nonce echoed in the extensions of a parsed OCSP
+// response
+func testOCSPNonce(t *testing.T, resp *ocsp.Response) []byte {
+	t.Helper()
+
+	var respData testOCSPResponseData
+	if _, err := asn1.Unmarshal(resp.TBSResponseData, &respData); err != nil {
+		t.Fatal(err)
+	}
+	if len(respData.Extensions) != 1 || !respData.Extensions[0].Id.Equal(oidOCSPNonce) {
+		t.Fatalf("expected nonce to be echoed, got %#v", respData.Extensions)
+	}
+	var nonce []byte
+	if _, err := asn1.Unmarshal(respData.Extensions[0].Value, &nonce); err != nil {
+		t.Fatal(err)
+	}
+	return nonce
+}
+
+func TestBackend_OCSP(t *testing.T) {
+	coreConfig := &vault.CoreConfig{
+		LogicalBackends: map[string]logical.Factory{
+			"pki": Factory,
+		},
+	}
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	client := cluster.Cores[0].Client
+	err := client.Sys().Mount("pki", &api.MountInput{
+		Type: "pki",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Logical().Write("pki/root/generate/internal", map[string]interface{}{
+		"ttl":         "40h",
+		"common_name": "myvault.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode([]byte(resp.Data["certificate"].(string)))
+	caCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// advertise_ocsp needs a base URL
+	_, err = client.Logical().Write("pki/config/urls", map[string]interface{}{
+		"advertise_ocsp": true,
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	_, err = client.Logical().Write("pki/config/urls", map[string]interface{}{
+		"ocsp_servers":   "http://ocsp.example.com",
+		"base_url":       client.Address() + "/v1/pki/",
+		"advertise_ocsp": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Logical().Write("pki/roles/test", map[string]interface{}{
+		"allowed_domains":  "foobar.com",
+		"allow_subdomains": true,
+		"ttl":              "1h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = client.Logical().Write("pki/issue/test", map[string]interface{}{
+		"common_name": "test.foobar.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ = pem.Decode([]byte(resp.Data["certificate"].(string)))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The OCSP endpoint is advertised in issued certificates
+	expected := []string{"http://ocsp.example.com", client.Address() + "/v1/pki/ocsp"}
+	if len(cert.OCSPServer) != 2 || cert.OCSPServer[0] != expected[0] || cert.OCSPServer[1] != expected[1] {
+		t.Fatalf("bad OCSP servers: expected %v, got %v", expected, cert.OCSPServer)
+	}
+
+	// The endpoint does not require a token
+	ocspClient, err := client.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ocspClient.ClearToken()
+
+	get := func(der []byte) []byte {
+		t.Helper()
+		r := ocspClient.NewRequest("GET", "/v1/pki/ocsp/"+base64.StdEncoding.EncodeToString(der))
+		resp, err := ocspClient.RawRequest(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if contentType := resp.Header.Get("Content-Type"); contentType != "application/ocsp-response" {
+			t.Fatalf("bad content type: %q", contentType)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+	post := func(der []byte) []byte {
+		t.Helper()
+		r := ocspClient.NewRequest("POST", "/v1/pki/ocsp")
+		r.Headers = http.Header{
+			"Content-Type": []string{"application/ocsp-request"},
+		}
+		r.BodyBytes = der
+		resp, err := ocspClient.RawRequest(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+
+	nonce := []byte("0123456789abcdef")
+	for _, query := range []func([]byte) []byte{get, post} {
+		ocspResp, err := ocsp.ParseResponseForCert(query(testOCSPRequest(t, caCert, cert.SerialNumber, nonce)), cert, caCert)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ocspResp.Status != ocsp.Good {
+			t.Fatalf("expected good status, got %d", ocspResp.Status)
+		}
+		if ocspResp.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+			t.Fatalf("bad serial number: %s", ocspResp.SerialNumber)
+		}
+		if ocspResp.ThisUpdate.IsZero() || ocspResp.ProducedAt.IsZero() {
+			t.Fatalf("missing update times: %#v", ocspResp)
+		}
+		if echoed := testOCSPNonce(t, ocspResp); !bytes.Equal(echoed, nonce) {
+			t.Fatalf("bad nonce: %q", echoed)
+		}
+	}
+
+	// Serials not issued by this CA are unknown
+	ocspResp, err := ocsp.ParseResponse(get(testOCSPRequest(t, caCert, big.NewInt(42), nil)), caCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ocspResp.Status != ocsp.Unknown {
+		t.Fatalf("expected unknown status, got %d", ocspResp.Status)
+	}
+	if ocspResp.SerialNumber.Cmp(big.NewInt(42)) != 0 {
+		t.Fatalf("bad serial number: %s", ocspResp.SerialNumber)
+	}
+
+	revokedAt := time.Now().Add(-time.Minute)
+	resp, err = client.Logical().Write("pki/revoke", map[string]interface{}{
+		"serial_number": resp.Data["serial_number"],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ocspResp, err = ocsp.ParseResponseForCert(post(testOCSPRequest(t, caCert, cert.SerialNumber, nil)), cert, caCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ocspResp.Status != ocsp.Revoked {
+		t.Fatalf("expected revoked status, got %d", ocspResp.Status)
+	}
+	if ocspResp.RevokedAt.Before(revokedAt) {
+		t.Fatalf("bad revocation time: %s", ocspResp.RevokedAt)
+	}
+
+	// Requests for another issuer are refused
+	_, err = ocsp.ParseResponse(get(testOCSPRequest(t, cert, cert.SerialNumber, nil)), caCert)
+	if respErr, ok := err.(ocsp.ResponseError); !ok || respErr.Status != ocsp.Unauthorized {
+		t.Fatalf("expected unauthorized status, got %v", err)
+	}
+
+	// Garbage is reported as a malformed request
+	_, err = ocsp.ParseResponse(post([]byte("not an OCSP request")), caCert)
+	if respErr, ok := err.(ocsp.ResponseError); !ok || respErr.Status != ocsp.Malformed {
+		t.Fatalf("expected malformed request status, got %v", err)
+	}
+}
@@ -15,6 +15,9 @@ type backend struct {
 	view      logical.Storage
 	salt      *salt.Salt
 	saltMutex sync.RWMutex
+
+	// krlLock serializes revocations and KRL builds
+	krlLock sync.Mutex
 }
 
 func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend, error) {
@@ -38,6 +41,7 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			Unauthenticated: []string{
 				"verify",
 				"public_key",
+				"krl",
 			},
 
 			LocalStorage: []string{
@@ -62,6 +66,11 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			pathConfigCA(&b),
 			pathSign(&b),
 			pathFetchPublicKey(&b),
+			pathFetchKRL(&b),
+			pathFetchListCerts(&b),
+			pathFetchCert(&b),
+			pathRevoke(&b),
+			pathTidy(&b),
 		},
 
 		Secrets: []*framework.Secret{
@@ -0,0 +1,313 @@
+package ssh
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	certStoragePrefix          = "certs/"
+	revokedSerialStoragePrefix = "revoked/"
+	revokedKeyIDStoragePrefix  = "revoked-key-ids/"
+	krlStoragePath             = "krl"
+)
+
+// The constants below are defined in OpenSSH's PROTOCOL.krl
+const (
+	krlMagic         = 0x5353484b524c0a00
+	krlFormatVersion = 1
+
+	krlSectionCertificates = 1
+
+	krlSectionCertSerialList = 0x20
+	krlSectionCertKeyID      = 0x23
+)
+
+// certEntry records a certificate signed by the backend
+type certEntry struct {
+	SerialNumber    string   `json:"serial_number"`
+	KeyID           string   `json:"key_id"`
+	CertType        string   `json:"cert_type"`
+	ValidPrincipals []string `json:"valid_principals"`
+	Role            string   `json:"role"`
+	ValidAfter      int64    `json:"valid_after"`
+	ValidBefore     int64    `json:"valid_before"`
+}
+
+// revokedSerialEntry records a certificate revoked by serial number.
+// Expiration is zero when the certificate was not recorded by the backend.
+type revokedSerialEntry struct {
+	SerialNumber   string `json:"serial_number"`
+	KeyID          string `json:"key_id"`
+	RevocationTime int64  `json:"revocation_time"`
+	Expiration     int64  `json:"expiration"`
+}
+
+// revokedKeyIDEntry records a key ID revoked for all of its certificates
+type revokedKeyIDEntry struct {
+	KeyID          string `json:"key_id"`
+	RevocationTime int64  `json:"revocation_time"`
+}
+
+// krlEntry holds the last KRL built by the backend
+type krlEntry struct {
+	Version uint64 `json:"version"`
+	KRL     []byte `json:"krl"`
+}
+
+// normalizeSerial parses a hex encoded serial number, as returned when
+// signing, and returns the form used in storage keys
+func normalizeSerial(serial string) (string, uint64, error) {
+	serial = strings.Replace(strings.ToLower(serial), ":", "", -1)
+	serial = strings.TrimPrefix(serial, "0x")
+	value, err := strconv.ParseUint(serial, 16, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid serial number %q", serial)
+	}
+	return strconv.FormatUint(value, 16), value, nil
+}
+
+// keyIDStorageKey returns the storage key of a revoked key ID. Key IDs are
+// free-form, so they are hashed.
+func keyIDStorageKey(keyID string) string {
+	sum := sha256.Sum256([]byte(keyID))
+	return revokedKeyIDStoragePrefix + hex.EncodeToString(sum[:])
+}
+
+func storeCert(ctx context.Context, s logical.Storage, cert *certEntry) error {
+	entry, err := logical.StorageEntryJSON(certStoragePrefix+cert.SerialNumber, cert)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+func fetchCert(ctx context.Context, s logical.Storage, serial string) (*certEntry, error) {
+	entry, err := s.Get(ctx, certStoragePrefix+serial)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var cert certEntry
+	if err := entry.DecodeJSON(&cert); err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+func fetchRevokedSerial(ctx context.Context, s logical.Storage, serial string) (*revokedSerialEntry, error) {
+	entry, err := s.Get(ctx, revokedSerialStoragePrefix+serial)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var revoked revokedSerialEntry
+	if err := entry.DecodeJSON(&revoked); err != nil {
+		return nil, err
+	}
+	return &revoked, nil
+}
+
+func fetchRevokedKeyID(ctx context.Context, s logical.Storage, keyID string) (*revokedKeyIDEntry, error) {
+	entry, err := s.Get(ctx, keyIDStorageKey(keyID))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var revoked revokedKeyIDEntry
+	if err := entry.DecodeJSON(&revoked); err != nil {
+		return nil, err
+	}
+	return &revoked, nil
+}
+
+// buildKRL builds the KRL from the revoked serial numbers and key IDs in
+// storage. Revoked certificates that expired are left out.
+func buildKRL(ctx context.Context, s logical.Storage, version uint64) ([]byte, error) {
+	now := time.Now()
+
+	serialKeys, err := s.List(ctx, revokedSerialStoragePrefix)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to list revoked serial numbers: {{err}}", err)
+	}
+	var serials []uint64
+	for _, key := range serialKeys {
+		revoked, err := fetchRevokedSerial(ctx, s, key)
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("failed to read revoked serial number %q: {{err}}", key), err)
+		}
+		if revoked == nil || (revoked.Expiration != 0 && now.Unix() > revoked.Expiration) {
+			continue
+		}
+		_, serial, err := normalizeSerial(revoked.SerialNumber)
+		if err != nil {
+			return nil, err
+		}
+		// OpenSSH does not allow revoking the serial number zero
+		if serial != 0 {
+			serials = append(serials, serial)
+		}
+	}
+
+	keyIDKeys, err := s.List(ctx, revokedKeyIDStoragePrefix)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to list revoked key IDs: {{err}}", err)
+	}
+	var keyIDs []string
+	for _, key := range keyIDKeys {
+		entry, err := s.Get(ctx, revokedKeyIDStoragePrefix+key)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to read revoked key ID: {{err}}", err)
+		}
+		if entry == nil {
+			continue
+		}
+		var revoked revokedKeyIDEntry
+		if err := entry.DecodeJSON(&revoked); err != nil {
+			return nil, err
+		}
+		keyIDs = append(keyIDs, revoked.KeyID)
+	}
+
+	var caPublicKeyBytes []byte
+	if len(serials) > 0 || len(keyIDs) > 0 {
+		publicKeyEntry, err := caKey(ctx, s, caPublicKey)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to read CA public key: {{err}}", err)
+		}
+		if publicKeyEntry == nil || publicKeyEntry.Key == "" {
+			return nil, fmt.Errorf("CA public key is not configured")
+		}
+		publicKey, err := parsePublicSSHKey(publicKeyEntry.Key)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to parse CA public key: {{err}}", err)
+		}
+		caPublicKeyBytes = publicKey.Marshal()
+	}
+
+	return marshalKRL(caPublicKeyBytes, version, now, serials, keyIDs), nil
+}
+
+// rebuildKRL builds the KRL and stores it with the next version number
+func (b *backend) rebuildKRL(ctx context.Context, s logical.Storage) error {
+	var version uint64
+	entry, err := s.Get(ctx, krlStoragePath)
+	if err != nil {
+		return err
+	}
+	if entry != nil {
+		var krl krlEntry
+		if err := entry.DecodeJSON(&krl); err != nil {
+			return err
+		}
+		version = krl.Version
+	}
+	version++
+
+	krl, err := buildKRL(ctx, s, version)
+	if err != nil {
+		return err
+	}
+
+	entry, err = logical.StorageEntryJSON(krlStoragePath, &krlEntry{
+		Version: version,
+		KRL:     krl,
+	})
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// marshalKRL encodes an unsigned KRL in the format of OpenSSH's
+// PROTOCOL.krl, revoking the given serial numbers and key IDs of the
+// certificates signed by the CA key
+func marshalKRL(caPublicKey []byte, version uint64, generated time.Time, serials []uint64, keyIDs []string) []byte {
+	var buf []byte
+	buf = appendU64(buf, krlMagic)
+	buf = appendU32(buf, krlFormatVersion)
+	buf = appendU64(buf, version)
+	buf = appendU64(buf, uint64(generated.Unix()))
+	// Flags
+	buf = appendU64(buf, 0)
+	// Reserved
+	buf = appendString(buf, nil)
+	// Comment
+	buf = appendString(buf, nil)
+
+	if len(serials) == 0 && len(keyIDs) == 0 {
+		return buf
+	}
+
+	var section []byte
+	section = appendString(section, caPublicKey)
+	// Reserved
+	section = appendString(section, nil)
+
+	if len(serials) > 0 {
+		sort.Slice(serials, func(i, j int) bool { return serials[i] < serials[j] })
+		var list []byte
+		for _, serial := range serials {
+			list = appendU64(list, serial)
+		}
+		section = append(section, krlSectionCertSerialList)
+		section = appendString(section, list)
+	}
+
+	if len(keyIDs) > 0 {
+		sort.Strings(keyIDs)
+		var list []byte
+		for _, keyID := range keyIDs {
+			list = appendString(list, []byte(keyID))
+		}
+		section = append(section, krlSectionCertKeyID)
+		section = appendString(section, list)
+	}
+
+	buf = append(buf, krlSectionCertificates)
+	return appendString(buf, section)
+}
+
+func appendU32(buf []byte, n uint32) []byte {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], n)
+	return append(buf, b[:]...)
+}
+
+func appendU64(buf []byte, n uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], n)
+	return append(buf, b[:]...)
+}
+
+func appendString(buf []byte, s []byte) []byte {
+	buf = appendU32(buf, uint32(len(s)))
+	return append(buf, s...)
+}
+
+func certTypeString(certType uint32) string {
+	if certType == ssh.HostCert {
+		return "host"
+	}
+	return "user"
+}
@@ -2,6 +2,7 @@ package ssh
 
 import (
 	"context"
+	"time"
 
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -39,3 +40,135 @@ func (b *backend) pathFetchPublicKey(ctx context.Context, req *logical.Request,
 
 	return response, nil
 }
+
+func pathFetchKRL(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `krl`,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchKRL,
+		},
+
+		HelpSynopsis: `Retrieve the Key Revocation List.`,
+		HelpDescription: `This allows the Key Revocation List of the certificates revoked by this
+backend to be fetched, in the binary format read by the RevokedKeys option
+of OpenSSH.`,
+	}
+}
+
+func pathFetchListCerts(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "certs/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathFetchCertList,
+		},
+
+		HelpSynopsis:    `List the serial numbers of the signed certificates.`,
+		HelpDescription: `This lists the serial numbers, in hex, of the certificates signed by this backend that were not tidied.`,
+	}
+}
+
+func pathFetchCert(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `cert/(?P<serial>[0-9A-Fa-fx:]+)`,
+
+		Fields: map[string]*framework.FieldSchema{
+			"serial": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Serial number of the certificate, in hex.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchCertRead,
+		},
+
+		HelpSynopsis:    `Read a signed certificate.`,
+		HelpDescription: `This returns the key ID, type, principals and validity of a certificate signed by this backend, and its revocation time if it was revoked.`,
+	}
+}
+
+func (b *backend) pathFetchKRL(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	entry, err := req.Storage.Get(ctx, krlStoragePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var krl []byte
+	if entry != nil {
+		var stored krlEntry
+		if err := entry.DecodeJSON(&stored); err != nil {
+			return nil, err
+		}
+		krl = stored.KRL
+	} else {
+		// Nothing was revoked yet
+		krl = marshalKRL(nil, 0, time.Now(), nil, nil)
+	}
+
+	response := &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/octet-stream",
+			logical.HTTPRawBody:     krl,
+			logical.HTTPStatusCode:  200,
+		},
+	}
+
+	return response, nil
+}
+
+func (b *backend) pathFetchCertList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, certStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathFetchCertRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	serial, _, err := normalizeSerial(data.Get("serial").(string))
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	cert, err := fetchCert(ctx, req.Storage, serial)
+	if err != nil {
+		return nil, err
+	}
+	if cert == nil {
+		return nil, nil
+	}
+
+	// The certificate is revoked by its serial number or its key ID
+	var revocationTime int64
+	revokedSerial, err := fetchRevokedSerial(ctx, req.Storage, serial)
+	if err != nil {
+		return nil, err
+	}
+	if revokedSerial != nil {
+		revocationTime = revokedSerial.RevocationTime
+	} else {
+		revokedKeyID, err := fetchRevokedKeyID(ctx, req.Storage, cert.KeyID)
+		if err != nil {
+			return nil, err
+		}
+		if revokedKeyID != nil {
+			revocationTime = revokedKeyID.RevocationTime
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"serial_number":    cert.SerialNumber,
+			"key_id":           cert.KeyID,
+			"cert_type":        cert.CertType,
+			"valid_principals": cert.ValidPrincipals,
+			"role":             cert.Role,
+			"valid_after":      cert.ValidAfter,
+			"valid_before":     cert.ValidBefore,
+			"revocation_time":  revocationTime,
+		},
+	}, nil
+}
@@ -0,0 +1,153 @@
+package ssh
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathRevoke(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "revoke",
+
+		Fields: map[string]*framework.FieldSchema{
+			"serial_number": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Serial number of the certificate to revoke, in hex, as returned when signing.`,
+			},
+			"key_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Key ID to revoke. All the certificates with this key ID are revoked, including those signed later.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRevokeWrite,
+		},
+
+		HelpSynopsis:    pathRevokeHelpSyn,
+		HelpDescription: pathRevokeHelpDesc,
+	}
+}
+
+func (b *backend) pathRevokeWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// If we are a performance standby forward the request to the active node
+	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby) {
+		return nil, logical.ErrReadOnly
+	}
+
+	serialNumber := data.Get("serial_number").(string)
+	keyID := data.Get("key_id").(string)
+	switch {
+	case serialNumber == "" && keyID == "":
+		return logical.ErrorResponse("one of serial_number or key_id must be provided"), nil
+	case serialNumber != "" && keyID != "":
+		return logical.ErrorResponse("only one of serial_number or key_id can be provided"), nil
+	}
+
+	publicKeyEntry, err := caKey(ctx, req.Storage, caPublicKey)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to read CA public key: {{err}}", err)
+	}
+	if publicKeyEntry == nil || publicKeyEntry.Key == "" {
+		return logical.ErrorResponse("the CA is not configured"), nil
+	}
+
+	b.krlLock.Lock()
+	defer b.krlLock.Unlock()
+
+	revocationTime := time.Now().Unix()
+
+	var entry *logical.StorageEntry
+	if serialNumber != "" {
+		serial, _, err := normalizeSerial(serialNumber)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		revoked, err := fetchRevokedSerial(ctx, req.Storage, serial)
+		if err != nil {
+			return nil, err
+		}
+		if revoked != nil {
+			return &logical.Response{
+				Data: map[string]interface{}{
+					"revocation_time": revoked.RevocationTime,
+				},
+			}, nil
+		}
+
+		revoked = &revokedSerialEntry{
+			SerialNumber:   serial,
+			RevocationTime: revocationTime,
+		}
+
+		// Certificates signed before they were recorded can be revoked, but
+		// are kept in the KRL as their expiration is unknown
+		cert, err := fetchCert(ctx, req.Storage, serial)
+		if err != nil {
+			return nil, err
+		}
+		if cert != nil {
+			revoked.KeyID = cert.KeyID
+			revoked.Expiration = cert.ValidBefore
+		}
+
+		entry, err = logical.StorageEntryJSON(revokedSerialStoragePrefix+serial, revoked)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		revoked, err := fetchRevokedKeyID(ctx, req.Storage, keyID)
+		if err != nil {
+			return nil, err
+		}
+		if revoked != nil {
+			return &logical.Response{
+				Data: map[string]interface{}{
+					"revocation_time": revoked.RevocationTime,
+				},
+			}, nil
+		}
+
+		entry, err = logical.StorageEntryJSON(keyIDStorageKey(keyID), &revokedKeyIDEntry{
+			KeyID:          keyID,
+			RevocationTime: revocationTime,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	if err := b.rebuildKRL(ctx, req.Storage); err != nil {
+		return nil, errwrap.Wrapf("failed to build the KRL: {{err}}", err)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"revocation_time": revocationTime,
+		},
+	}, nil
+}
+
+const pathRevokeHelpSyn = `
+Revoke an SSH certificate by serial number or key ID.
+`
+
+const pathRevokeHelpDesc = `
+This endpoint revokes a certificate signed by this backend, given its serial
+number, or all the certificates with a key ID. Revoked certificates are listed
+in the Key Revocation List served at the "krl" endpoint, which OpenSSH reads
+through its RevokedKeys option.
+
+Revoking a key ID also revokes the certificates signed later with the same key
+ID, and signing is refused for revoked key IDs.
+`
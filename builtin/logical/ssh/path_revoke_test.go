@@ -0,0 +1,262 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// parseTestKRL parses a KRL with a single certificates section, returning
+// the CA key, revoked serial numbers and key IDs
+func parseTestKRL(t *testing.T, krl []byte) ([]byte, []uint64, []string) {
+	readU32 := func(buf []byte) (uint32, []byte) {
+		if len(buf) < 4 {
+			t.Fatalf("truncated KRL")
+		}
+		return binary.BigEndian.Uint32(buf), buf[4:]
+	}
+	readU64 := func(buf []byte) (uint64, []byte) {
+		if len(buf) < 8 {
+			t.Fatalf("truncated KRL")
+		}
+		return binary.BigEndian.Uint64(buf), buf[8:]
+	}
+	readString := func(buf []byte) ([]byte, []byte) {
+		n, buf := readU32(buf)
+		if uint32(len(buf)) < n {
+			t.Fatalf("truncated KRL")
+		}
+		return buf[:n], buf[n:]
+	}
+
+	magic, buf := readU64(krl)
+	if magic != krlMagic {
+		t.Fatalf("bad magic: %x", magic)
+	}
+	formatVersion, buf := readU32(buf)
+	if formatVersion != krlFormatVersion {
+		t.Fatalf("bad format version: %d", formatVersion)
+	}
+	// Version, generated date and flags
+	_, buf = readU64(buf)
+	_, buf = readU64(buf)
+	_, buf = readU64(buf)
+	// Reserved and comment
+	_, buf = readString(buf)
+	_, buf = readString(buf)
+
+	if len(buf) == 0 {
+		return nil, nil, nil
+	}
+	if buf[0] != krlSectionCertificates {
+		t.Fatalf("bad section type: %d", buf[0])
+	}
+	section, buf := readString(buf[1:])
+	if len(buf) != 0 {
+		t.Fatalf("unexpected trailing data")
+	}
+
+	caKey, section := readString(section)
+	_, section = readString(section)
+
+	var serials []uint64
+	var keyIDs []string
+	for len(section) > 0 {
+		sectionType := section[0]
+		var data []byte
+		data, section = readString(section[1:])
+		switch sectionType {
+		case krlSectionCertSerialList:
+			for len(data) > 0 {
+				var serial uint64
+				serial, data = readU64(data)
+				serials = append(serials, serial)
+			}
+		case krlSectionCertKeyID:
+			for len(data) > 0 {
+				var keyID []byte
+				keyID, data = readString(data)
+				keyIDs = append(keyIDs, string(keyID))
+			}
+		default:
+			t.Fatalf("bad certificate section type: %d", sectionType)
+		}
+	}
+
+	return caKey, serials, keyIDs
+}
+
+func TestSSH_RevokeAndKRL(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(operation logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: operation,
+			Path:      path,
+			Data:      data,
+			Storage:   config.StorageView,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s %s: bad: resp: %#v, err: %v", operation, path, resp, err)
+		}
+		return resp
+	}
+	readKRL := func() ([]byte, []uint64, []string) {
+		resp := request(logical.ReadOperation, "krl", nil)
+		return parseTestKRL(t, resp.Data[logical.HTTPRawBody].([]byte))
+	}
+
+	// An empty KRL is served before anything is revoked
+	if caKey, serials, keyIDs := readKRL(); caKey != nil || serials != nil || keyIDs != nil {
+		t.Fatalf("expected an empty KRL")
+	}
+
+	request(logical.UpdateOperation, "config/ca", map[string]interface{}{
+		"public_key":  publicKey,
+		"private_key": privateKey,
+	})
+	request(logical.UpdateOperation, "roles/testing", map[string]interface{}{
+		"key_type":                "ca",
+		"allowed_users":           "tuber",
+		"default_user":            "tuber",
+		"allow_user_certificates": true,
+		"allow_user_key_ids":      true,
+	})
+
+	resp := request(logical.UpdateOperation, "sign/testing", map[string]interface{}{
+		"public_key": publicKey2,
+		"key_id":     "first",
+	})
+	firstSerial := resp.Data["serial_number"].(string)
+	_, firstSerialValue, err := normalizeSerial(firstSerial)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp = request(logical.UpdateOperation, "sign/testing", map[string]interface{}{
+		"public_key": publicKey2,
+		"key_id":     "second",
+	})
+	secondSerial := resp.Data["serial_number"].(string)
+
+	resp = request(logical.ListOperation, "certs/", nil)
+	if len(resp.Data["keys"].([]string)) != 2 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	resp = request(logical.ReadOperation, "cert/"+firstSerial, nil)
+	if resp.Data["key_id"] != "first" || resp.Data["cert_type"] != "user" || resp.Data["role"] != "testing" || resp.Data["revocation_time"].(int64) != 0 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Revoke by serial number
+	resp = request(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": firstSerial,
+	})
+	revocationTime := resp.Data["revocation_time"].(int64)
+	if revocationTime == 0 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	resp = request(logical.ReadOperation, "cert/"+firstSerial, nil)
+	if resp.Data["revocation_time"].(int64) != revocationTime {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	parsedKey, err := parsePublicSSHKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caKey, serials, keyIDs := readKRL()
+	if !bytes.Equal(caKey, parsedKey.Marshal()) {
+		t.Fatalf("bad CA key in KRL")
+	}
+	if !reflect.DeepEqual(serials, []uint64{firstSerialValue}) || keyIDs != nil {
+		t.Fatalf("bad: serials %v, key IDs %v", serials, keyIDs)
+	}
+
+	// Revoke by key ID, which refuses signing for it
+	request(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"key_id": "second",
+	})
+
+	resp = request(logical.ReadOperation, "cert/"+secondSerial, nil)
+	if resp.Data["revocation_time"].(int64) == 0 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	_, serials, keyIDs = readKRL()
+	if !reflect.DeepEqual(serials, []uint64{firstSerialValue}) || !reflect.DeepEqual(keyIDs, []string{"second"}) {
+		t.Fatalf("bad: serials %v, key IDs %v", serials, keyIDs)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "sign/testing",
+		Data: map[string]interface{}{
+			"public_key": publicKey2,
+			"key_id":     "second",
+		},
+		Storage: config.StorageView,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected signing with a revoked key ID to fail: resp: %#v, err: %v", resp, err)
+	}
+
+	// Only one of serial_number and key_id can be given
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "revoke",
+		Data: map[string]interface{}{
+			"serial_number": firstSerial,
+			"key_id":        "first",
+		},
+		Storage: config.StorageView,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error: resp: %#v, err: %v", resp, err)
+	}
+
+	// Expired certificates are tidied and left out of the KRL
+	cert, err := fetchCert(context.Background(), config.StorageView, firstSerial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert.ValidBefore = 1
+	if err := storeCert(context.Background(), config.StorageView, cert); err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := fetchRevokedSerial(context.Background(), config.StorageView, firstSerial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked.Expiration = 1
+	entry, err := logical.StorageEntryJSON(revokedSerialStoragePrefix+firstSerial, revoked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.StorageView.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	request(logical.UpdateOperation, "tidy", nil)
+
+	resp = request(logical.ListOperation, "certs/", nil)
+	if !reflect.DeepEqual(resp.Data["keys"], []string{secondSerial}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	_, serials, keyIDs = readKRL()
+	if serials != nil || !reflect.DeepEqual(keyIDs, []string{"second"}) {
+		t.Fatalf("bad: serials %v, key IDs %v", serials, keyIDs)
+	}
+}
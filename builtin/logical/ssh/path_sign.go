@@ -117,6 +117,14 @@ func (b *backend) pathSignCertificate(ctx context.Context, req *logical.Request,
 		return logical.ErrorResponse(err.Error()), nil
 	}
 
+	revokedKeyID, err := fetchRevokedKeyID(ctx, req.Storage, keyId)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to read revoked key ID: {{err}}", err)
+	}
+	if revokedKeyID != nil {
+		return logical.ErrorResponse(fmt.Sprintf("key ID %q has been revoked", keyId)), nil
+	}
+
 	certificateType, err := b.calculateCertificateType(data, role)
 	if err != nil {
 		return logical.ErrorResponse(err.Error()), nil
@@ -185,9 +193,25 @@ func (b *backend) pathSignCertificate(ctx context.Context, req *logical.Request,
 		return nil, fmt.Errorf("error marshaling signed certificate")
 	}
 
+	serialNumber := strconv.FormatUint(certificate.Serial, 16)
+
+	// Record the certificate so that it can be revoked by serial number
+	err = storeCert(ctx, req.Storage, &certEntry{
+		SerialNumber:    serialNumber,
+		KeyID:           certificate.KeyId,
+		CertType:        certTypeString(certificate.CertType),
+		ValidPrincipals: certificate.ValidPrincipals,
+		Role:            data.Get("role").(string),
+		ValidAfter:      int64(certificate.ValidAfter),
+		ValidBefore:     int64(certificate.ValidBefore),
+	})
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to store certificate: {{err}}", err)
+	}
+
 	response := &logical.Response{
 		Data: map[string]interface{}{
-			"serial_number": strconv.FormatUint(certificate.Serial, 16),
+			"serial_number": serialNumber,
 			"signed_key":    string(signedSSHCertificate),
 		},
 	}
@@ -0,0 +1,108 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func pathTidy(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "tidy",
+
+		Fields: map[string]*framework.FieldSchema{
+			"safety_buffer": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `The amount of extra time that must have passed
+beyond certificate expiration before it is removed
+from the backend storage and from the KRL.
+Defaults to 72 hours.`,
+				Default: 259200, //72h, but TypeDurationSecond currently requires defaults to be int
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathTidyWrite,
+		},
+
+		HelpSynopsis:    pathTidyHelpSyn,
+		HelpDescription: pathTidyHelpDesc,
+	}
+}
+
+func (b *backend) pathTidyWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// If we are a performance standby forward the request to the active node
+	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby) {
+		return nil, logical.ErrReadOnly
+	}
+
+	safetyBuffer := data.Get("safety_buffer").(int)
+	if safetyBuffer < 1 {
+		return logical.ErrorResponse("safety_buffer must be greater than zero"), nil
+	}
+	cutoff := time.Now().Add(-time.Duration(safetyBuffer) * time.Second).Unix()
+
+	b.krlLock.Lock()
+	defer b.krlLock.Unlock()
+
+	serials, err := req.Storage.List(ctx, certStoragePrefix)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to list certificates: {{err}}", err)
+	}
+	for _, serial := range serials {
+		cert, err := fetchCert(ctx, req.Storage, serial)
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("failed to read certificate %q: {{err}}", serial), err)
+		}
+		if cert == nil || cert.ValidBefore > cutoff {
+			continue
+		}
+		if err := req.Storage.Delete(ctx, certStoragePrefix+serial); err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("failed to delete certificate %q: {{err}}", serial), err)
+		}
+	}
+
+	var removed bool
+	serials, err = req.Storage.List(ctx, revokedSerialStoragePrefix)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to list revoked serial numbers: {{err}}", err)
+	}
+	for _, serial := range serials {
+		revoked, err := fetchRevokedSerial(ctx, req.Storage, serial)
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("failed to read revoked serial number %q: {{err}}", serial), err)
+		}
+		if revoked == nil || revoked.Expiration == 0 || revoked.Expiration > cutoff {
+			continue
+		}
+		if err := req.Storage.Delete(ctx, revokedSerialStoragePrefix+serial); err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("failed to delete revoked serial number %q: {{err}}", serial), err)
+		}
+		removed = true
+	}
+
+	if removed {
+		if err := b.rebuildKRL(ctx, req.Storage); err != nil {
+			return nil, errwrap.Wrapf("failed to build the KRL: {{err}}", err)
+		}
+	}
+
+	return nil, nil
+}
+
+const pathTidyHelpSyn = `
+Tidy up the backend by removing expired certificates and revocations.
+`
+
+const pathTidyHelpDesc = `
+This endpoint removes the records of the signed certificates, and the
+revocations by serial number, of the certificates that expired more than the
+safety buffer ago. The KRL is rebuilt if revocations were removed.
+
+Revoked key IDs do not expire and are not removed.
+`
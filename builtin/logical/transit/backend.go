@@ -3,6 +3,7 @@ package transit
 import (
 	"context"
 	"strings"
+	"sync"
 
 	"github.com/hashicorp/vault/helper/keysutil"
 	"github.com/hashicorp/vault/logical"
@@ -24,6 +25,7 @@ func Backend(conf *logical.BackendConfig) *backend {
 			SealWrapStorage: []string{
 				"archive/",
 				"policy/",
+				"import/",
 			},
 		},
 
@@ -33,6 +35,9 @@ func Backend(conf *logical.BackendConfig) *backend {
 			b.pathConfig(),
 			b.pathRotate(),
 			b.pathRewrap(),
+			b.pathImport(),
+			b.pathImportVersion(),
+			b.pathWrappingKey(),
 			b.pathKeys(),
 			b.pathListKeys(),
 			b.pathExportKeys(),
@@ -62,6 +67,10 @@ func Backend(conf *logical.BackendConfig) *backend {
 type backend struct {
 	*framework.Backend
 	lm *keysutil.LockManager
+
+	// wrappingKeyLock guards the creation of the wrapping key used to import
+	// keys
+	wrappingKeyLock sync.Mutex
 }
 
 func (b *backend) invalidate(_ context.Context, key string) {
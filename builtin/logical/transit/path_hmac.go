@@ -11,11 +11,30 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/helper/keysutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	"github.com/mitchellh/mapstructure"
 )
 
+// BatchRequestHMACItem represents a request item for batch HMAC generation
+type BatchRequestHMACItem struct {
+	// Input is the base64 encoded input data
+	Input string `json:"input" structs:"input" mapstructure:"input"`
+}
+
+// BatchResponseHMACItem represents a response item for batch HMAC generation
+type BatchResponseHMACItem struct {
+	// HMAC of the input present in the corresponding batch request item
+	HMAC string `json:"hmac,omitempty" structs:"hmac" mapstructure:"hmac"`
+
+	// Error, if set represents a failure encountered while generating the
+	// HMAC of a corresponding batch request item
+	Error string `json:"error,omitempty" structs:"error" mapstructure:"error"`
+}
+
 func (b *backend) pathHMAC() *framework.Path {
 	return &framework.Path{
 		Pattern: "hmac/" + framework.GenericNameRegex("name") + framework.OptionalParamRegex("urlalgorithm"),
@@ -68,15 +87,43 @@ to the min_encryption_version configured on the key.`,
 func (b *backend) pathHMACWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	name := d.Get("name").(string)
 	ver := d.Get("key_version").(int)
-	inputB64 := d.Get("input").(string)
 	algorithm := d.Get("urlalgorithm").(string)
 	if algorithm == "" {
 		algorithm = d.Get("algorithm").(string)
 	}
 
-	input, err := base64.StdEncoding.DecodeString(inputB64)
-	if err != nil {
-		return logical.ErrorResponse(fmt.Sprintf("unable to decode input as base64: %s", err)), logical.ErrInvalidRequest
+	batchInputRaw := d.Raw["batch_input"]
+	var batchInputItems []BatchRequestHMACItem
+	if batchInputRaw != nil {
+		err := mapstructure.Decode(batchInputRaw, &batchInputItems)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to parse batch input: {{err}}", err)
+		}
+
+		if len(batchInputItems) == 0 {
+			return logical.ErrorResponse("missing batch input to process"), logical.ErrInvalidRequest
+		}
+	} else {
+		batchInputItems = make([]BatchRequestHMACItem, 1)
+		batchInputItems[0] = BatchRequestHMACItem{
+			Input: d.Get("input").(string),
+		}
+	}
+
+	hashFunc := hashFuncForAlgorithm(algorithm)
+	if hashFunc == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unsupported algorithm %s", algorithm)), nil
+	}
+
+	batchResponseItems := make([]BatchResponseHMACItem, len(batchInputItems))
+	inputs := make([][]byte, len(batchInputItems))
+	for i, item := range batchInputItems {
+		input, err := base64.StdEncoding.DecodeString(item.Input)
+		if err != nil {
+			batchResponseItems[i].Error = fmt.Sprintf("unable to decode input as base64: %s", err)
+			continue
+		}
+		inputs[i] = input
 	}
 
 	// Get the policy
@@ -116,132 +163,109 @@ func (b *backend) pathHMACWrite(ctx context.Context, req *logical.Request, d *fr
 		return nil, fmt.Errorf("HMAC key value could not be computed")
 	}
 
-	var hf hash.Hash
-	switch algorithm {
-	case "sha2-224":
-		hf = hmac.New(sha256.New224, key)
-	case "sha2-256":
-		hf = hmac.New(sha256.New, key)
-	case "sha2-384":
-		hf = hmac.New(sha512.New384, key)
-	case "sha2-512":
-		hf = hmac.New(sha512.New, key)
-	default:
-		p.Unlock()
-		return logical.ErrorResponse(fmt.Sprintf("unsupported algorithm %s", algorithm)), nil
-	}
-	hf.Write(input)
-	retBytes := hf.Sum(nil)
+	// Process batch request items. If the input of a request item could not
+	// be decoded, its error was already recorded in the response collection.
+	for i := range batchInputItems {
+		if batchResponseItems[i].Error != "" {
+			continue
+		}
 
-	retStr := base64.StdEncoding.EncodeToString(retBytes)
-	retStr = fmt.Sprintf("vault:v%s:%s", strconv.Itoa(ver), retStr)
+		hf := hmac.New(hashFunc, key)
+		hf.Write(inputs[i])
+		retBytes := hf.Sum(nil)
 
-	// Generate the response
-	resp := &logical.Response{
-		Data: map[string]interface{}{
-			"hmac": retStr,
-		},
+		retStr := base64.StdEncoding.EncodeToString(retBytes)
+		batchResponseItems[i].HMAC = fmt.Sprintf("vault:v%s:%s", strconv.Itoa(ver), retStr)
 	}
 
 	p.Unlock()
-	return resp, nil
-}
 
-func (b *backend) pathHMACVerify(ctx context.Context, req *logical.Request, d *framework.FieldData, verificationHMAC string) (*logical.Response, error) {
-	name := d.Get("name").(string)
-	inputB64 := d.Get("input").(string)
-	algorithm := d.Get("urlalgorithm").(string)
-	if algorithm == "" {
-		algorithm = d.Get("algorithm").(string)
+	// Generate the response
+	if batchInputRaw != nil {
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"batch_results": batchResponseItems,
+			},
+		}, nil
 	}
 
-	input, err := base64.StdEncoding.DecodeString(inputB64)
-	if err != nil {
-		return logical.ErrorResponse(fmt.Sprintf("unable to decode input as base64: %s", err)), logical.ErrInvalidRequest
+	if batchResponseItems[0].Error != "" {
+		return logical.ErrorResponse(batchResponseItems[0].Error), logical.ErrInvalidRequest
 	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"hmac": batchResponseItems[0].HMAC,
+		},
+	}, nil
+}
 
+// verifyHMAC verifies an HMAC of the input generated by a key of the policy,
+// which must be locked by the caller. Errors caused by the given HMAC are
+// returned as user errors.
+func verifyHMAC(p *keysutil.Policy, hashFunc func() hash.Hash, input []byte, verificationHMAC string) (bool, error) {
 	// Verify the prefix
 	if !strings.HasPrefix(verificationHMAC, "vault:v") {
-		return logical.ErrorResponse("invalid HMAC to verify: no prefix"), logical.ErrInvalidRequest
+		return false, errutil.UserError{Err: "invalid HMAC to verify: no prefix"}
 	}
 
 	splitVerificationHMAC := strings.SplitN(strings.TrimPrefix(verificationHMAC, "vault:v"), ":", 2)
 	if len(splitVerificationHMAC) != 2 {
-		return logical.ErrorResponse("invalid HMAC: wrong number of fields"), logical.ErrInvalidRequest
+		return false, errutil.UserError{Err: "invalid HMAC: wrong number of fields"}
 	}
 
 	ver, err := strconv.Atoi(splitVerificationHMAC[0])
 	if err != nil {
-		return logical.ErrorResponse("invalid HMAC: version number could not be decoded"), logical.ErrInvalidRequest
+		return false, errutil.UserError{Err: "invalid HMAC: version number could not be decoded"}
 	}
 
 	verBytes, err := base64.StdEncoding.DecodeString(splitVerificationHMAC[1])
 	if err != nil {
-		return logical.ErrorResponse(fmt.Sprintf("unable to decode verification HMAC as base64: %s", err)), logical.ErrInvalidRequest
-	}
-
-	// Get the policy
-	p, _, err := b.lm.GetPolicy(ctx, keysutil.PolicyRequest{
-		Storage: req.Storage,
-		Name:    name,
-	})
-	if err != nil {
-		return nil, err
-	}
-	if p == nil {
-		return logical.ErrorResponse("encryption key not found"), logical.ErrInvalidRequest
-	}
-	if !b.System().CachingDisabled() {
-		p.Lock(false)
+		return false, errutil.UserError{Err: fmt.Sprintf("unable to decode verification HMAC as base64: %s", err)}
 	}
 
 	if ver > p.LatestVersion {
-		p.Unlock()
-		return logical.ErrorResponse("invalid HMAC: version is too new"), logical.ErrInvalidRequest
+		return false, errutil.UserError{Err: "invalid HMAC: version is too new"}
 	}
 
 	if p.MinDecryptionVersion > 0 && ver < p.MinDecryptionVersion {
-		p.Unlock()
-		return logical.ErrorResponse("cannot verify HMAC: version is too old (disallowed by policy)"), logical.ErrInvalidRequest
+		return false, errutil.UserError{Err: "cannot verify HMAC: version is too old (disallowed by policy)"}
 	}
 
 	key, err := p.HMACKey(ver)
 	if err != nil {
-		p.Unlock()
-		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		return false, errutil.UserError{Err: err.Error()}
 	}
 	if key == nil {
-		p.Unlock()
-		return nil, fmt.Errorf("HMAC key value could not be computed")
+		return false, errutil.InternalError{Err: "HMAC key value could not be computed"}
 	}
 
-	var hf hash.Hash
+	hf := hmac.New(hashFunc, key)
+	hf.Write(input)
+	retBytes := hf.Sum(nil)
+
+	return hmac.Equal(retBytes, verBytes), nil
+}
+
+// hashFuncForAlgorithm returns the hash function of a hash algorithm, or nil
+// if the algorithm is not supported
+func hashFuncForAlgorithm(algorithm string) func() hash.Hash {
 	switch algorithm {
 	case "sha2-224":
-		hf = hmac.New(sha256.New224, key)
+		return sha256.New224
 	case "sha2-256":
-		hf = hmac.New(sha256.New, key)
+		return sha256.New
 	case "sha2-384":
-		hf = hmac.New(sha512.New384, key)
+		return sha512.New384
 	case "sha2-512":
-		hf = hmac.New(sha512.New, key)
+		return sha512.New
 	default:
-		p.Unlock()
-		return logical.ErrorResponse(fmt.Sprintf("unsupported algorithm %s", algorithm)), nil
+		return nil
 	}
-	hf.Write(input)
-	retBytes := hf.Sum(nil)
-
-	p.Unlock()
-	return &logical.Response{
-		Data: map[string]interface{}{
-			"valid": hmac.Equal(retBytes, verBytes),
-		},
-	}, nil
 }
 
 const pathHMACHelpSyn = `Generate an HMAC for input data using the named key`
 
 const pathHMACHelpDesc = `
-Generates an HMAC sum of the given algorithm and key against the given input data.
+Generates an HMAC sum of the given algorithm and key against the given input data,
+or against each input of a batch of items.
 `
@@ -180,3 +180,110 @@ func TestTransit_HMAC(t *testing.T) {
 		t.Fatalf("expected invalid request error, got %v", err)
 	}
 }
+
+func TestTransit_BatchHMAC(t *testing.T) {
+	var resp *logical.Response
+	var err error
+
+	b, s := createBackendWithStorage(t)
+
+	policyReq := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/foo",
+		Storage:   s,
+	}
+	resp, err = b.HandleRequest(context.Background(), policyReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	batchReq := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "hmac/foo",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"batch_input": []interface{}{
+				map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA=="},
+				map[string]interface{}{"input": "foobar"},
+				map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveCBqdW1wcw=="},
+			},
+		},
+	}
+	resp, err = b.HandleRequest(context.Background(), batchReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	batchResponseItems := resp.Data["batch_results"].([]BatchResponseHMACItem)
+	if len(batchResponseItems) != 3 {
+		t.Fatalf("bad: %#v", batchResponseItems)
+	}
+	if batchResponseItems[1].Error == "" || batchResponseItems[1].HMAC != "" {
+		t.Fatalf("expected an error for the invalid input, got %#v", batchResponseItems[1])
+	}
+
+	// Each HMAC must match the one of a single request
+	for _, i := range []int{0, 2} {
+		if batchResponseItems[i].Error != "" {
+			t.Fatalf("bad: %#v", batchResponseItems[i])
+		}
+
+		req := &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "hmac/foo",
+			Storage:   s,
+			Data: map[string]interface{}{
+				"input": batchReq.Data["batch_input"].([]interface{})[i].(map[string]interface{})["input"],
+			},
+		}
+		resp, err = b.HandleRequest(context.Background(), req)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		if resp.Data["hmac"] != batchResponseItems[i].HMAC {
+			t.Fatalf("bad: hmac. Expected: %q, Actual: %q", resp.Data["hmac"], batchResponseItems[i].HMAC)
+		}
+	}
+
+	// Verify the HMACs in a batch, swapping two of them
+	verifyReq := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "verify/foo",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"batch_input": []interface{}{
+				map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA==", "hmac": batchResponseItems[0].HMAC},
+				map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA==", "hmac": batchResponseItems[2].HMAC},
+				map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA==", "hmac": "foobar"},
+				map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA=="},
+			},
+		},
+	}
+	resp, err = b.HandleRequest(context.Background(), verifyReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	verifyResponseItems := resp.Data["batch_results"].([]BatchResponseVerifyItem)
+	if len(verifyResponseItems) != 4 {
+		t.Fatalf("bad: %#v", verifyResponseItems)
+	}
+	if !verifyResponseItems[0].Valid || verifyResponseItems[0].Error != "" {
+		t.Fatalf("expected a valid HMAC, got %#v", verifyResponseItems[0])
+	}
+	if verifyResponseItems[1].Valid || verifyResponseItems[1].Error != "" {
+		t.Fatalf("expected an invalid HMAC, got %#v", verifyResponseItems[1])
+	}
+	for _, i := range []int{2, 3} {
+		if verifyResponseItems[i].Valid || verifyResponseItems[i].Error == "" {
+			t.Fatalf("expected an error, got %#v", verifyResponseItems[i])
+		}
+	}
+
+	// An empty batch is rejected
+	batchReq.Data["batch_input"] = []interface{}{}
+	resp, err = b.HandleRequest(context.Background(), batchReq)
+	if err != logical.ErrInvalidRequest {
+		t.Fatalf("expected invalid request error, got err:%v resp:%#v", err, resp)
+	}
+}
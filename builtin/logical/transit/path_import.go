@@ -0,0 +1,446 @@
+package transit
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/errutil"
+	"github.com/hashicorp/vault/helper/keysutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const (
+	// wrappingKeyName is the name of the RSA key used to wrap imported key
+	// material
+	wrappingKeyName = "wrapping-key"
+
+	// wrappingKeyStoragePrefix keeps the wrapping key apart from the keys of
+	// the mount, so that it can't be used or listed through the keys paths
+	wrappingKeyStoragePrefix = "import/"
+)
+
+func (b *backend) pathWrappingKey() *framework.Path {
+	return &framework.Path{
+		Pattern: "wrapping_key",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathWrappingKeyRead,
+		},
+
+		HelpSynopsis:    pathWrappingKeyHelpSyn,
+		HelpDescription: pathWrappingKeyHelpDesc,
+	}
+}
+
+func (b *backend) pathImport() *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("name") + "/import",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "The name of the key",
+			},
+
+			"type": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "aes256-gcm96",
+				Description: `
+The type of key being imported. Currently, "aes256-gcm96" (symmetric),
+"chacha20-poly1305" (symmetric), "ecdsa-p256" (asymmetric), "ed25519"
+(asymmetric), "rsa-2048" (asymmetric), "rsa-4096" (asymmetric) are supported.
+Defaults to "aes256-gcm96".
+`,
+			},
+
+			"ciphertext": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The base64-encoded ciphertext of the key material.
+It is the concatenation of an ephemeral AES-256 key wrapped with the wrapping
+key using RSA-OAEP, followed by the key material wrapped with the ephemeral
+key using AES key wrap with padding (RFC 5649).`,
+			},
+
+			"hash_function": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "SHA256",
+				Description: `The hash function used for the RSA-OAEP step of
+wrapping the key material. Supported hash functions are: "SHA1", "SHA224",
+"SHA256", "SHA384", and "SHA512". Defaults to "SHA256".`,
+			},
+
+			"derived": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Enables key derivation mode. This
+allows for per-transaction unique
+keys for encryption operations.`,
+			},
+
+			"exportable": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Enables keys to be exportable.
+This allows for all the valid keys
+in the key ring to be exported.`,
+			},
+
+			"allow_plaintext_backup": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Enables taking a backup of the named
+key in plaintext format. Once set,
+this cannot be disabled.`,
+			},
+
+			"allow_rotation": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `Allows Vault to rotate the imported key.
+Otherwise new versions of the key can only be
+imported.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathImportWrite,
+		},
+
+		HelpSynopsis:    pathImportHelpSyn,
+		HelpDescription: pathImportHelpDesc,
+	}
+}
+
+func (b *backend) pathImportVersion() *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("name") + "/import_version",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "The name of the key",
+			},
+
+			"ciphertext": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The base64-encoded ciphertext of the key material.
+It is the concatenation of an ephemeral AES-256 key wrapped with the wrapping
+key using RSA-OAEP, followed by the key material wrapped with the ephemeral
+key using AES key wrap with padding (RFC 5649).`,
+			},
+
+			"hash_function": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: "SHA256",
+				Description: `The hash function used for the RSA-OAEP step of
+wrapping the key material. Supported hash functions are: "SHA1", "SHA224",
+"SHA256", "SHA384", and "SHA512". Defaults to "SHA256".`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathImportVersionWrite,
+		},
+
+		HelpSynopsis:    pathImportVersionHelpSyn,
+		HelpDescription: pathImportVersionHelpDesc,
+	}
+}
+
+func (b *backend) pathWrappingKeyRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	p, err := b.getWrappingKey(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappingKey := p.Keys[strconv.Itoa(p.LatestVersion)]
+	derBytes, err := x509.MarshalPKIXPublicKey(&wrappingKey.RSAKey.PublicKey)
+	if err != nil {
+		return nil, errwrap.Wrapf("error marshaling wrapping key: {{err}}", err)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: derBytes,
+	})
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"public_key": string(pemBytes),
+		},
+	}, nil
+}
+
+func (b *backend) pathImportWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	keyType := d.Get("type").(string)
+
+	polReq := keysutil.PolicyRequest{
+		Storage:                  req.Storage,
+		Name:                     name,
+		Derived:                  d.Get("derived").(bool),
+		Exportable:               d.Get("exportable").(bool),
+		AllowPlaintextBackup:     d.Get("allow_plaintext_backup").(bool),
+		AllowImportedKeyRotation: d.Get("allow_rotation").(bool),
+	}
+	switch keyType {
+	case "aes256-gcm96":
+		polReq.KeyType = keysutil.KeyType_AES256_GCM96
+	case "chacha20-poly1305":
+		polReq.KeyType = keysutil.KeyType_ChaCha20_Poly1305
+	case "ecdsa-p256":
+		polReq.KeyType = keysutil.KeyType_ECDSA_P256
+	case "ed25519":
+		polReq.KeyType = keysutil.KeyType_ED25519
+	case "rsa-2048":
+		polReq.KeyType = keysutil.KeyType_RSA2048
+	case "rsa-4096":
+		polReq.KeyType = keysutil.KeyType_RSA4096
+	default:
+		return logical.ErrorResponse(fmt.Sprintf("unknown key type %v", keyType)), logical.ErrInvalidRequest
+	}
+
+	key, err := b.unwrapImportedKey(ctx, req.Storage, d)
+	if err != nil {
+		return importErrorResponse(err)
+	}
+
+	if err := b.lm.ImportPolicy(ctx, polReq, key); err != nil {
+		return importErrorResponse(err)
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathImportVersionWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	p, _, err := b.lm.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: req.Storage,
+		Name:    name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("key not found"), logical.ErrInvalidRequest
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(true)
+	}
+	defer p.Unlock()
+
+	if !p.Imported {
+		return logical.ErrorResponse("new versions can only be imported into keys that were imported"), logical.ErrInvalidRequest
+	}
+
+	key, err := b.unwrapImportedKey(ctx, req.Storage, d)
+	if err != nil {
+		return importErrorResponse(err)
+	}
+
+	if err := p.Import(ctx, req.Storage, key); err != nil {
+		return importErrorResponse(err)
+	}
+
+	return nil, nil
+}
+
+// importErrorResponse turns user errors from importing a key into error
+// responses
+func importErrorResponse(err error) (*logical.Response, error) {
+	if _, ok := err.(errutil.UserError); ok {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	return nil, err
+}
+
+// getWrappingKey returns the RSA key of the mount used to wrap imported key
+// material, creating it on first use
+func (b *backend) getWrappingKey(ctx context.Context, storage logical.Storage) (*keysutil.Policy, error) {
+	b.wrappingKeyLock.Lock()
+	defer b.wrappingKeyLock.Unlock()
+
+	p, err := keysutil.LoadPolicy(ctx, storage, wrappingKeyStoragePrefix+"policy/"+wrappingKeyName)
+	if err != nil {
+		return nil, err
+	}
+	if p != nil {
+		return p, nil
+	}
+
+	p = keysutil.NewPolicy(keysutil.PolicyConfig{
+		Name:          wrappingKeyName,
+		Type:          keysutil.KeyType_RSA4096,
+		StoragePrefix: wrappingKeyStoragePrefix,
+	})
+	if err := p.Rotate(ctx, storage); err != nil {
+		return nil, errwrap.Wrapf("error generating wrapping key: {{err}}", err)
+	}
+
+	return p, nil
+}
+
+// unwrapImportedKey decrypts the key material of an import request. The
+// ciphertext holds an ephemeral AES-256 key encrypted with the wrapping key
+// using RSA-OAEP, followed by the key material wrapped with the ephemeral key
+// using AES key wrap with padding.
+func (b *backend) unwrapImportedKey(ctx context.Context, storage logical.Storage, d *framework.FieldData) ([]byte, error) {
+	ciphertextB64 := d.Get("ciphertext").(string)
+	if ciphertextB64 == "" {
+		return nil, errutil.UserError{Err: "missing ciphertext"}
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(ciphertextB64)
+	if err != nil {
+		return nil, errutil.UserError{Err: "ciphertext is not valid base64"}
+	}
+
+	hashFn, err := parseImportHashFunction(d.Get("hash_function").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := b.getWrappingKey(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+	wrappingKey := p.Keys[strconv.Itoa(p.LatestVersion)].RSAKey
+
+	// The ephemeral key is as long as the wrapping key's modulus
+	ephemeralKeyLen := wrappingKey.Size()
+	if len(ciphertext) <= ephemeralKeyLen {
+		return nil, errutil.UserError{Err: "ciphertext is too short"}
+	}
+
+	ephemeralKey, err := rsa.DecryptOAEP(hashFn, rand.Reader, wrappingKey, ciphertext[:ephemeralKeyLen], nil)
+	if err != nil {
+		return nil, errutil.UserError{Err: "failed to decrypt the ephemeral key with the wrapping key"}
+	}
+	if len(ephemeralKey) != 32 {
+		return nil, errutil.UserError{Err: "ephemeral key must be an AES-256 key"}
+	}
+
+	key, err := aesKWPUnwrap(ephemeralKey, ciphertext[ephemeralKeyLen:])
+	if err != nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("failed to unwrap the key material: %v", err)}
+	}
+
+	return key, nil
+}
+
+func parseImportHashFunction(name string) (hash.Hash, error) {
+	switch strings.ToUpper(name) {
+	case "SHA1":
+		return sha1.New(), nil
+	case "SHA224":
+		return sha256.New224(), nil
+	case "SHA256":
+		return sha256.New(), nil
+	case "SHA384":
+		return sha512.New384(), nil
+	case "SHA512":
+		return sha512.New(), nil
+	default:
+		return nil, errutil.UserError{Err: fmt.Sprintf("unsupported hash function %q", name)}
+	}
+}
+
+// kwpIV is the alternative initial value prefix of AES key wrap with padding,
+// from RFC 5649
+var kwpIV = []byte{0xA6, 0x59, 0x59, 0xA6}
+
+// aesKWPUnwrap unwraps key material wrapped using AES key wrap with padding,
+// as described in RFC 5649
+func aesKWPUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 16 || len(wrapped)%8 != 0 {
+		return nil, errors.New("invalid wrapped key length")
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(wrapped)/8 - 1
+	a := make([]byte, 8)
+	r := make([]byte, 8*n)
+
+	if n == 1 {
+		buf := make([]byte, 16)
+		block.Decrypt(buf, wrapped)
+		copy(a, buf[:8])
+		copy(r, buf[8:])
+	} else {
+		copy(a, wrapped[:8])
+		copy(r, wrapped[8:])
+
+		buf := make([]byte, 16)
+		for j := 5; j >= 0; j-- {
+			for i := n; i >= 1; i-- {
+				t := uint64(n*j + i)
+				copy(buf, a)
+				for k := 0; k < 8; k++ {
+					buf[7-k] ^= byte(t >> (8 * uint(k)))
+				}
+				copy(buf[8:], r[(i-1)*8:i*8])
+				block.Decrypt(buf, buf)
+				copy(a, buf[:8])
+				copy(r[(i-1)*8:i*8], buf[8:])
+			}
+		}
+	}
+
+	if subtle.ConstantTimeCompare(a[:4], kwpIV) != 1 {
+		return nil, errors.New("integrity check failed")
+	}
+
+	mli := int(binary.BigEndian.Uint32(a[4:]))
+	if mli <= 8*(n-1) || mli > 8*n {
+		return nil, errors.New("integrity check failed")
+	}
+	for _, padding := range r[mli:] {
+		if padding != 0 {
+			return nil, errors.New("integrity check failed")
+		}
+	}
+
+	return r[:mli], nil
+}
+
+const pathWrappingKeyHelpSyn = `Returns the public key to use for wrapping imported keys`
+
+const pathWrappingKeyHelpDesc = `
+This path is used to retrieve the RSA-4096 wrapping key of the mount, used to
+wrap the ephemeral AES key that protects key material being imported. The key
+is generated on first use.
+`
+
+const pathImportHelpSyn = `Imports an externally-generated key into a new transit key`
+
+const pathImportHelpDesc = `
+This path is used to import an externally-generated key into Vault. The key
+material must be wrapped as described for the "ciphertext" parameter, using the
+public key returned by the "wrapping_key" path. AES and ChaCha20 keys are
+imported as raw bytes, all other key types as PKCS #8 DER private keys.
+
+Imported keys are not rotated by Vault unless "allow_rotation" is set; new
+versions can be imported at the "import_version" path instead.
+`
+
+const pathImportVersionHelpSyn = `Imports an externally-generated key into an existing imported key`
+
+const pathImportVersionHelpDesc = `
+This path is used to import a new version of an existing imported key. The key
+material must be wrapped the same way as for the "import" path, and becomes the
+latest version of the key.
+`
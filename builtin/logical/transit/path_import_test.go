@@ -0,0 +1,349 @@
+package transit
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// aesKWPWrap wraps key material using AES key wrap with padding, as described
+// in RFC 5649
+func aesKWPWrap(kek, key []byte) []byte {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		panic(err)
+	}
+
+	a := make([]byte, 8)
+	copy(a, kwpIV)
+	binary.BigEndian.PutUint32(a[4:], uint32(len(key)))
+
+	padded := make([]byte, (len(key)+7)/8*8)
+	copy(padded, key)
+	n := len(padded) / 8
+
+	if n == 1 {
+		out := make([]byte, 16)
+		block.Encrypt(out, append(a, padded...))
+		return out
+	}
+
+	buf := make([]byte, 16)
+	for j := 0; j <= 5; j++ {
+		for i := 1; i <= n; i++ {
+			copy(buf, a)
+			copy(buf[8:], padded[(i-1)*8:i*8])
+			block.Encrypt(buf, buf)
+			t := uint64(n*j + i)
+			copy(a, buf[:8])
+			for k := 0; k < 8; k++ {
+				a[7-k] ^= byte(t >> (8 * uint(k)))
+			}
+			copy(padded[(i-1)*8:i*8], buf[8:])
+		}
+	}
+
+	return append(a, padded...)
+}
+
+func testWrapKeyForImport(t *testing.T, b *backend, storage logical.Storage, key []byte) string {
+	t.Helper()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.ReadOperation,
+		Path:      "wrapping_key",
+	})
+	if err != nil || resp == nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	block, _ := pem.Decode([]byte(resp.Data["public_key"].(string)))
+	if block == nil {
+		t.Fatal("failed to decode wrapping key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrappingKey := pub.(*rsa.PublicKey)
+	if wrappingKey.N.BitLen() != 4096 {
+		t.Fatalf("expected 4096 bit wrapping key, got %d", wrappingKey.N.BitLen())
+	}
+
+	ephemeralKey := make([]byte, 32)
+	if _, err := rand.Read(ephemeralKey); err != nil {
+		t.Fatal(err)
+	}
+	wrappedEphemeralKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, wrappingKey, ephemeralKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(append(wrappedEphemeralKey, aesKWPWrap(ephemeralKey, key)...))
+}
+
+func testMarshalPKCS8Ed25519(t *testing.T, key ed25519.PrivateKey) []byte {
+	t.Helper()
+
+	seed, err := asn1.Marshal(key.Seed())
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := asn1.Marshal(struct {
+		Version    int
+		Algo       pkix.AlgorithmIdentifier
+		PrivateKey []byte
+	}{
+		Algo: pkix.AlgorithmIdentifier{
+			Algorithm: asn1.ObjectIdentifier{1, 3, 101, 112},
+		},
+		PrivateKey: seed,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestTransit_Import_KWP(t *testing.T) {
+	kek := make([]byte, 32)
+	if _, err := rand.Read(kek); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range []int{1, 8, 20, 32, 1217} {
+		key := make([]byte, size)
+		if _, err := rand.Read(key); err != nil {
+			t.Fatal(err)
+		}
+
+		unwrapped, err := aesKWPUnwrap(kek, aesKWPWrap(kek, key))
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if string(unwrapped) != string(key) {
+			t.Fatalf("size %d: unwrapped key does not match", size)
+		}
+	}
+
+	// Tampering is detected
+	wrapped := aesKWPWrap(kek, make([]byte, 32))
+	wrapped[3] ^= 1
+	if _, err := aesKWPUnwrap(kek, wrapped); err == nil {
+		t.Fatal("expected integrity check failure")
+	}
+}
+
+func TestTransit_Import(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+	ctx := context.Background()
+
+	handle := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Data:      data,
+		})
+	}
+
+	// Symmetric keys are imported as raw bytes
+	for _, keyType := range []string{"aes256-gcm96", "chacha20-poly1305"} {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := handle("keys/"+keyType+"/import", map[string]interface{}{
+			"type":       keyType,
+			"ciphertext": testWrapKeyForImport(t, b, storage, key),
+			"exportable": true,
+		})
+		if err != nil || resp != nil {
+			t.Fatalf("%s: err: %v, resp: %#v", keyType, err, resp)
+		}
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Storage:   storage,
+			Operation: logical.ReadOperation,
+			Path:      "export/encryption-key/" + keyType + "/1",
+		})
+		if err != nil || resp == nil {
+			t.Fatalf("%s: err: %v, resp: %#v", keyType, err, resp)
+		}
+		if resp.Data["keys"].(map[string]string)["1"] != base64.StdEncoding.EncodeToString(key) {
+			t.Fatalf("%s: exported key does not match the imported key", keyType)
+		}
+	}
+
+	// Asymmetric keys are imported as PKCS #8 and checked by verifying a
+	// signature made by Vault with the original public key
+	input := []byte("hello world")
+	digest := sha256.Sum256(input)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecDER, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaDER, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	asymmetric := []struct {
+		keyType string
+		der     []byte
+		verify  func(sig []byte) bool
+	}{
+		{
+			"ecdsa-p256",
+			ecDER,
+			func(sig []byte) bool {
+				var ecSig struct{ R, S *big.Int }
+				if _, err := asn1.Unmarshal(sig, &ecSig); err != nil {
+					return false
+				}
+				return ecdsa.Verify(&ecKey.PublicKey, digest[:], ecSig.R, ecSig.S)
+			},
+		},
+		{
+			"ed25519",
+			testMarshalPKCS8Ed25519(t, edKey),
+			func(sig []byte) bool {
+				return ed25519.Verify(edPub, input, sig)
+			},
+		},
+		{
+			"rsa-2048",
+			rsaDER,
+			func(sig []byte) bool {
+				return rsa.VerifyPSS(&rsaKey.PublicKey, crypto.SHA256, digest[:], sig, nil) == nil
+			},
+		},
+	}
+
+	for _, tc := range asymmetric {
+		resp, err := handle("keys/"+tc.keyType+"/import", map[string]interface{}{
+			"type":       tc.keyType,
+			"ciphertext": testWrapKeyForImport(t, b, storage, tc.der),
+		})
+		if err != nil || resp != nil {
+			t.Fatalf("%s: err: %v, resp: %#v", tc.keyType, err, resp)
+		}
+
+		resp, err = handle("sign/"+tc.keyType, map[string]interface{}{
+			"input": base64.StdEncoding.EncodeToString(input),
+		})
+		if err != nil || resp == nil || resp.IsError() {
+			t.Fatalf("%s: err: %v, resp: %#v", tc.keyType, err, resp)
+		}
+		sig, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(resp.Data["signature"].(string), "vault:v1:"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !tc.verify(sig) {
+			t.Fatalf("%s: signature made with the imported key does not verify", tc.keyType)
+		}
+	}
+
+	// Key material that doesn't match the key type is rejected
+	resp, err := handle("keys/mismatch/import", map[string]interface{}{
+		"type":       "rsa-4096",
+		"ciphertext": testWrapKeyForImport(t, b, storage, rsaDER),
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got err: %v, resp: %#v", err, resp)
+	}
+
+	// Existing keys can't be imported over
+	resp, err = handle("keys/aes256-gcm96/import", map[string]interface{}{
+		"ciphertext": testWrapKeyForImport(t, b, storage, make([]byte, 32)),
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got err: %v, resp: %#v", err, resp)
+	}
+
+	// Imported keys are not rotated by Vault by default
+	resp, err = handle("keys/aes256-gcm96/rotate", nil)
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got err: %v, resp: %#v", err, resp)
+	}
+
+	// New versions are imported instead
+	newKey := make([]byte, 32)
+	if _, err := rand.Read(newKey); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = handle("keys/aes256-gcm96/import_version", map[string]interface{}{
+		"ciphertext": testWrapKeyForImport(t, b, storage, newKey),
+	})
+	if err != nil || resp != nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Storage:   storage,
+		Operation: logical.ReadOperation,
+		Path:      "keys/aes256-gcm96",
+	})
+	if err != nil || resp == nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	if resp.Data["latest_version"] != 2 || resp.Data["imported_key"] != true || resp.Data["exportable"] != true {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Versions can't be imported into keys generated by Vault
+	resp, err = handle("keys/generated", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = handle("keys/generated/import_version", map[string]interface{}{
+		"ciphertext": testWrapKeyForImport(t, b, storage, newKey),
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got err: %v, resp: %#v", err, resp)
+	}
+
+	// Rotation can be allowed when importing
+	resp, err = handle("keys/rotatable/import", map[string]interface{}{
+		"ciphertext":     testWrapKeyForImport(t, b, storage, newKey),
+		"allow_rotation": true,
+	})
+	if err != nil || resp != nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	resp, err = handle("keys/rotatable/rotate", nil)
+	if err != nil || resp != nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+}
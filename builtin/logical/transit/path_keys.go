@@ -219,6 +219,11 @@ func (b *backend) pathPolicyRead(ctx context.Context, req *logical.Request, d *f
 		},
 	}
 
+	if p.Imported {
+		resp.Data["imported_key"] = true
+		resp.Data["imported_key_allow_rotation"] = p.AllowImportedKeyRotation
+	}
+
 	if p.BackupInfo != nil {
 		resp.Data["backup_info"] = map[string]interface{}{
 			"time":    p.BackupInfo.Time,
@@ -2,18 +2,87 @@ package transit
 
 import (
 	"context"
-	"crypto/sha256"
-	"crypto/sha512"
 	"encoding/base64"
 	"fmt"
 	"hash"
 
+	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/helper/keysutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	"github.com/mitchellh/mapstructure"
 )
 
+// missingContextMessage is the error of the batch items that cannot be
+// signed or verified with a derived key as they carry no context
+const missingContextMessage = "missing 'context' for key derivation; the key was created using a derived key, which means additional, per-request information must be included in order to perform operations with the key"
+
+// BatchRequestSignItem represents a request item for batch signing and
+// verification
+type BatchRequestSignItem struct {
+	// Input is the base64 encoded input data
+	Input string `json:"input" structs:"input" mapstructure:"input"`
+
+	// DecodedInput is the base64 decoded version of Input
+	DecodedInput []byte
+
+	// Context for key derivation. This is required for derived keys.
+	Context string `json:"context" structs:"context" mapstructure:"context"`
+
+	// DecodedContext is the base64 decoded version of Context
+	DecodedContext []byte
+
+	// Signature to verify, including the vault header and key version
+	Signature string `json:"signature" structs:"signature" mapstructure:"signature"`
+
+	// HMAC to verify, including the vault header and key version
+	HMAC string `json:"hmac" structs:"hmac" mapstructure:"hmac"`
+}
+
+// decode decodes the base64 encoded input and context of the item
+func (i *BatchRequestSignItem) decode() error {
+	var err error
+	i.DecodedInput, err = base64.StdEncoding.DecodeString(i.Input)
+	if err != nil {
+		return fmt.Errorf("unable to decode input as base64: %s", err)
+	}
+
+	if len(i.Context) != 0 {
+		i.DecodedContext, err = base64.StdEncoding.DecodeString(i.Context)
+		if err != nil {
+			return fmt.Errorf("failed to base64-decode context")
+		}
+	}
+
+	return nil
+}
+
+// BatchResponseSignItem represents a response item for batch signing
+type BatchResponseSignItem struct {
+	// Signature of the input present in the corresponding batch request item
+	Signature string `json:"signature,omitempty" structs:"signature" mapstructure:"signature"`
+
+	// PublicKey is the public key of the derived key used for signing, if
+	// any
+	PublicKey []byte `json:"public_key,omitempty" structs:"public_key" mapstructure:"public_key"`
+
+	// Error, if set represents a failure encountered while signing a
+	// corresponding batch request item
+	Error string `json:"error,omitempty" structs:"error" mapstructure:"error"`
+}
+
+// BatchResponseVerifyItem represents a response item for batch verification
+type BatchResponseVerifyItem struct {
+	// Valid is set if the signature or HMAC of the corresponding batch
+	// request item is valid
+	Valid bool `json:"valid" structs:"valid" mapstructure:"valid"`
+
+	// Error, if set represents a failure encountered while verifying a
+	// corresponding batch request item
+	Error string `json:"error,omitempty" structs:"error" mapstructure:"error"`
+}
+
 func (b *backend) pathSign() *framework.Path {
 	return &framework.Path{
 		Pattern: "sign/" + framework.GenericNameRegex("name") + framework.OptionalParamRegex("urlalgorithm"),
@@ -162,7 +231,6 @@ Options are 'pss' or 'pkcs1v15'. Defaults to 'pss'`,
 func (b *backend) pathSignWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	name := d.Get("name").(string)
 	ver := d.Get("key_version").(int)
-	inputB64 := d.Get("input").(string)
 	hashAlgorithm := d.Get("urlalgorithm").(string)
 	if hashAlgorithm == "" {
 		hashAlgorithm = d.Get("hash_algorithm").(string)
@@ -173,9 +241,30 @@ func (b *backend) pathSignWrite(ctx context.Context, req *logical.Request, d *fr
 	prehashed := d.Get("prehashed").(bool)
 	sigAlgorithm := d.Get("signature_algorithm").(string)
 
-	input, err := base64.StdEncoding.DecodeString(inputB64)
-	if err != nil {
-		return logical.ErrorResponse(fmt.Sprintf("unable to decode input as base64: %s", err)), logical.ErrInvalidRequest
+	batchInputRaw := d.Raw["batch_input"]
+	var batchInputItems []BatchRequestSignItem
+	if batchInputRaw != nil {
+		err := mapstructure.Decode(batchInputRaw, &batchInputItems)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to parse batch input: {{err}}", err)
+		}
+
+		if len(batchInputItems) == 0 {
+			return logical.ErrorResponse("missing batch input to process"), logical.ErrInvalidRequest
+		}
+	} else {
+		batchInputItems = make([]BatchRequestSignItem, 1)
+		batchInputItems[0] = BatchRequestSignItem{
+			Input:   d.Get("input").(string),
+			Context: d.Get("context").(string),
+		}
+	}
+
+	batchResponseItems := make([]BatchResponseSignItem, len(batchInputItems))
+	for i := range batchInputItems {
+		if err := batchInputItems[i].decode(); err != nil {
+			batchResponseItems[i].Error = err.Error()
+		}
 	}
 
 	// Get the policy
@@ -198,77 +287,82 @@ func (b *backend) pathSignWrite(ctx context.Context, req *logical.Request, d *fr
 		return logical.ErrorResponse(fmt.Sprintf("key type %v does not support signing", p.Type)), logical.ErrInvalidRequest
 	}
 
-	contextRaw := d.Get("context").(string)
-	var context []byte
-	if len(contextRaw) != 0 {
-		context, err = base64.StdEncoding.DecodeString(contextRaw)
-		if err != nil {
+	var hashFunc func() hash.Hash
+	if p.Type.HashSignatureInput() && !prehashed {
+		hashFunc = hashFuncForAlgorithm(hashAlgorithm)
+		if hashFunc == nil {
 			p.Unlock()
-			return logical.ErrorResponse("failed to base64-decode context"), logical.ErrInvalidRequest
+			return logical.ErrorResponse(fmt.Sprintf("unsupported hash algorithm %s", hashAlgorithm)), nil
 		}
 	}
 
-	if p.Type.HashSignatureInput() && !prehashed {
-		var hf hash.Hash
-		switch hashAlgorithm {
-		case "sha2-224":
-			hf = sha256.New224()
-		case "sha2-256":
-			hf = sha256.New()
-		case "sha2-384":
-			hf = sha512.New384()
-		case "sha2-512":
-			hf = sha512.New()
-		default:
+	// Process batch request items. If signing of any request item fails,
+	// respectively mark the error in the response collection and continue
+	// to process other items.
+	for i, item := range batchInputItems {
+		if batchResponseItems[i].Error != "" {
+			continue
+		}
+
+		if p.Derived && len(item.DecodedContext) == 0 {
+			batchResponseItems[i].Error = missingContextMessage
+			continue
+		}
+
+		input := item.DecodedInput
+		if hashFunc != nil {
+			hf := hashFunc()
+			hf.Write(input)
+			input = hf.Sum(nil)
+		}
+
+		sig, err := p.Sign(ver, item.DecodedContext, input, hashAlgorithm, sigAlgorithm)
+		if err != nil {
+			switch err.(type) {
+			case errutil.UserError:
+				batchResponseItems[i].Error = err.Error()
+				continue
+			default:
+				p.Unlock()
+				return nil, err
+			}
+		}
+		if sig == nil {
 			p.Unlock()
-			return logical.ErrorResponse(fmt.Sprintf("unsupported hash algorithm %s", hashAlgorithm)), nil
+			return nil, fmt.Errorf("signature could not be computed for input item %d", i)
 		}
-		hf.Write(input)
-		input = hf.Sum(nil)
-	}
 
-	sig, err := p.Sign(ver, context, input, hashAlgorithm, sigAlgorithm)
-	if err != nil {
-		p.Unlock()
-		return nil, err
-	}
-	if sig == nil {
-		p.Unlock()
-		return nil, fmt.Errorf("signature could not be computed")
+		batchResponseItems[i].Signature = sig.Signature
+		batchResponseItems[i].PublicKey = sig.PublicKey
 	}
 
+	p.Unlock()
+
 	// Generate the response
+	if batchInputRaw != nil {
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"batch_results": batchResponseItems,
+			},
+		}, nil
+	}
+
+	if batchResponseItems[0].Error != "" {
+		return logical.ErrorResponse(batchResponseItems[0].Error), logical.ErrInvalidRequest
+	}
 	resp := &logical.Response{
 		Data: map[string]interface{}{
-			"signature": sig.Signature,
+			"signature": batchResponseItems[0].Signature,
 		},
 	}
-
-	if len(sig.PublicKey) > 0 {
-		resp.Data["public_key"] = sig.PublicKey
+	if len(batchResponseItems[0].PublicKey) > 0 {
+		resp.Data["public_key"] = batchResponseItems[0].PublicKey
 	}
-
-	p.Unlock()
 	return resp, nil
 }
 
 func (b *backend) pathVerifyWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-
-	sig := d.Get("signature").(string)
-	hmac := d.Get("hmac").(string)
-	switch {
-	case sig != "" && hmac != "":
-		return logical.ErrorResponse("provide one of 'signature' or 'hmac'"), logical.ErrInvalidRequest
-
-	case sig == "" && hmac == "":
-		return logical.ErrorResponse("neither a 'signature' nor an 'hmac' were given to verify"), logical.ErrInvalidRequest
-
-	case hmac != "":
-		return b.pathHMACVerify(ctx, req, d, hmac)
-	}
-
 	name := d.Get("name").(string)
-	inputB64 := d.Get("input").(string)
 	hashAlgorithm := d.Get("urlalgorithm").(string)
 	if hashAlgorithm == "" {
 		hashAlgorithm = d.Get("hash_algorithm").(string)
@@ -276,12 +370,49 @@ func (b *backend) pathVerifyWrite(ctx context.Context, req *logical.Request, d *
 			hashAlgorithm = d.Get("algorithm").(string)
 		}
 	}
+	hmacAlgorithm := d.Get("urlalgorithm").(string)
+	if hmacAlgorithm == "" {
+		hmacAlgorithm = d.Get("algorithm").(string)
+	}
 	prehashed := d.Get("prehashed").(bool)
 	sigAlgorithm := d.Get("signature_algorithm").(string)
 
-	input, err := base64.StdEncoding.DecodeString(inputB64)
-	if err != nil {
-		return logical.ErrorResponse(fmt.Sprintf("unable to decode input as base64: %s", err)), logical.ErrInvalidRequest
+	batchInputRaw := d.Raw["batch_input"]
+	var batchInputItems []BatchRequestSignItem
+	if batchInputRaw != nil {
+		err := mapstructure.Decode(batchInputRaw, &batchInputItems)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to parse batch input: {{err}}", err)
+		}
+
+		if len(batchInputItems) == 0 {
+			return logical.ErrorResponse("missing batch input to process"), logical.ErrInvalidRequest
+		}
+	} else {
+		batchInputItems = make([]BatchRequestSignItem, 1)
+		batchInputItems[0] = BatchRequestSignItem{
+			Input:     d.Get("input").(string),
+			Context:   d.Get("context").(string),
+			Signature: d.Get("signature").(string),
+			HMAC:      d.Get("hmac").(string),
+		}
+	}
+
+	batchResponseItems := make([]BatchResponseVerifyItem, len(batchInputItems))
+	for i, item := range batchInputItems {
+		switch {
+		case item.Signature != "" && item.HMAC != "":
+			batchResponseItems[i].Error = "provide one of 'signature' or 'hmac'"
+			continue
+
+		case item.Signature == "" && item.HMAC == "":
+			batchResponseItems[i].Error = "neither a 'signature' nor an 'hmac' were given to verify"
+			continue
+		}
+
+		if err := batchInputItems[i].decode(); err != nil {
+			batchResponseItems[i].Error = err.Error()
+		}
 	}
 
 	// Get the policy
@@ -299,73 +430,91 @@ func (b *backend) pathVerifyWrite(ctx context.Context, req *logical.Request, d *
 		p.Lock(false)
 	}
 
-	if !p.Type.SigningSupported() {
-		p.Unlock()
-		return logical.ErrorResponse(fmt.Sprintf("key type %v does not support verification", p.Type)), logical.ErrInvalidRequest
-	}
+	// Process batch request items. If verification of any request item
+	// fails, respectively mark the error in the response collection and
+	// continue to process other items.
+	for i, item := range batchInputItems {
+		if batchResponseItems[i].Error != "" {
+			continue
+		}
 
-	contextRaw := d.Get("context").(string)
-	var context []byte
-	if len(contextRaw) != 0 {
-		context, err = base64.StdEncoding.DecodeString(contextRaw)
+		var valid bool
+		if item.HMAC != "" {
+			hashFunc := hashFuncForAlgorithm(hmacAlgorithm)
+			if hashFunc == nil {
+				batchResponseItems[i].Error = fmt.Sprintf("unsupported algorithm %s", hmacAlgorithm)
+				continue
+			}
+			valid, err = verifyHMAC(p, hashFunc, item.DecodedInput, item.HMAC)
+		} else {
+			if !p.Type.SigningSupported() {
+				batchResponseItems[i].Error = fmt.Sprintf("key type %v does not support verification", p.Type)
+				continue
+			}
+
+			if p.Derived && len(item.DecodedContext) == 0 {
+				batchResponseItems[i].Error = missingContextMessage
+				continue
+			}
+
+			input := item.DecodedInput
+			if p.Type.HashSignatureInput() && !prehashed {
+				hashFunc := hashFuncForAlgorithm(hashAlgorithm)
+				if hashFunc == nil {
+					batchResponseItems[i].Error = fmt.Sprintf("unsupported hash algorithm %s", hashAlgorithm)
+					continue
+				}
+				hf := hashFunc()
+				hf.Write(input)
+				input = hf.Sum(nil)
+			}
+
+			valid, err = p.VerifySignature(item.DecodedContext, input, item.Signature, hashAlgorithm, sigAlgorithm)
+		}
 		if err != nil {
-			p.Unlock()
-			return logical.ErrorResponse("failed to base64-decode context"), logical.ErrInvalidRequest
+			switch err.(type) {
+			case errutil.UserError:
+				batchResponseItems[i].Error = err.Error()
+				continue
+			default:
+				p.Unlock()
+				return nil, err
+			}
 		}
-	}
 
-	if p.Type.HashSignatureInput() && !prehashed {
-		var hf hash.Hash
-		switch hashAlgorithm {
-		case "sha2-224":
-			hf = sha256.New224()
-		case "sha2-256":
-			hf = sha256.New()
-		case "sha2-384":
-			hf = sha512.New384()
-		case "sha2-512":
-			hf = sha512.New()
-		default:
-			p.Unlock()
-			return logical.ErrorResponse(fmt.Sprintf("unsupported hash algorithm %s", hashAlgorithm)), nil
-		}
-		hf.Write(input)
-		input = hf.Sum(nil)
+		batchResponseItems[i].Valid = valid
 	}
 
-	valid, err := p.VerifySignature(context, input, sig, hashAlgorithm, sigAlgorithm)
-	if err != nil {
-		switch err.(type) {
-		case errutil.UserError:
-			p.Unlock()
-			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
-		case errutil.InternalError:
-			p.Unlock()
-			return nil, err
-		default:
-			p.Unlock()
-			return nil, err
-		}
-	}
+	p.Unlock()
 
 	// Generate the response
-	resp := &logical.Response{
-		Data: map[string]interface{}{
-			"valid": valid,
-		},
+	if batchInputRaw != nil {
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"batch_results": batchResponseItems,
+			},
+		}, nil
 	}
 
-	p.Unlock()
-	return resp, nil
+	if batchResponseItems[0].Error != "" {
+		return logical.ErrorResponse(batchResponseItems[0].Error), logical.ErrInvalidRequest
+	}
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"valid": batchResponseItems[0].Valid,
+		},
+	}, nil
 }
 
 const pathSignHelpSyn = `Generate a signature for input data using the named key`
 
 const pathSignHelpDesc = `
 Generates a signature of the input data using the named key and the given hash algorithm.
+A batch of items may be signed at once with the "batch_input" parameter.
 `
 const pathVerifyHelpSyn = `Verify a signature or HMAC for input data created using the named key`
 
 const pathVerifyHelpDesc = `
 Verifies a signature or HMAC of the input data using the named key and the given hash algorithm.
+A batch of items may be verified at once with the "batch_input" parameter.
 `
@@ -409,3 +409,110 @@ func TestTransit_SignVerify_ED25519(t *testing.T) {
 	verifyRequest(req, false, "bar", sig)
 	verifyRequest(req, true, "bar", v1sig)
 }
+
+func TestTransit_BatchSignVerify(t *testing.T) {
+	var resp *logical.Response
+	var err error
+
+	b, s := createBackendWithSysView(t)
+
+	policyReq := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "keys/foo",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"type":    "ed25519",
+			"derived": true,
+		},
+	}
+	resp, err = b.HandleRequest(context.Background(), policyReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	batchInput := []interface{}{
+		map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA==", "context": "Y29udGV4dDE="},
+		map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA==", "context": "Y29udGV4dDI="},
+		map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA=="},
+		map[string]interface{}{"input": "foobar", "context": "Y29udGV4dDE="},
+	}
+	signReq := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "sign/foo",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"batch_input": batchInput,
+		},
+	}
+	resp, err = b.HandleRequest(context.Background(), signReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	signResponseItems := resp.Data["batch_results"].([]BatchResponseSignItem)
+	if len(signResponseItems) != 4 {
+		t.Fatalf("bad: %#v", signResponseItems)
+	}
+	for _, i := range []int{0, 1} {
+		item := signResponseItems[i]
+		if item.Error != "" || item.Signature == "" || len(item.PublicKey) != ed25519.PublicKeySize {
+			t.Fatalf("bad: %#v", item)
+		}
+	}
+	if string(signResponseItems[0].PublicKey) == string(signResponseItems[1].PublicKey) {
+		t.Fatal("expected different derived keys for different contexts")
+	}
+
+	// The derived key requires a context, and the input must be base64
+	for _, i := range []int{2, 3} {
+		item := signResponseItems[i]
+		if item.Error == "" || item.Signature != "" {
+			t.Fatalf("expected an error, got %#v", item)
+		}
+	}
+
+	verifyReq := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "verify/foo",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"batch_input": []interface{}{
+				map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA==", "context": "Y29udGV4dDE=", "signature": signResponseItems[0].Signature},
+				map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA==", "context": "Y29udGV4dDE=", "signature": signResponseItems[1].Signature},
+				map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA==", "context": "Y29udGV4dDI=", "signature": signResponseItems[1].Signature},
+				map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA==", "context": "Y29udGV4dDE=", "signature": "foobar"},
+			},
+		},
+	}
+	resp, err = b.HandleRequest(context.Background(), verifyReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	verifyResponseItems := resp.Data["batch_results"].([]BatchResponseVerifyItem)
+	expected := []BatchResponseVerifyItem{
+		{Valid: true},
+		{Valid: false},
+		{Valid: true},
+	}
+	if len(verifyResponseItems) != 4 {
+		t.Fatalf("bad: %#v", verifyResponseItems)
+	}
+	for i, item := range expected {
+		if verifyResponseItems[i] != item {
+			t.Fatalf("bad: item %d. Expected: %#v, Actual: %#v", i, item, verifyResponseItems[i])
+		}
+	}
+	if verifyResponseItems[3].Valid || verifyResponseItems[3].Error == "" {
+		t.Fatalf("expected an error, got %#v", verifyResponseItems[3])
+	}
+
+	// Single requests keep returning errors
+	signReq.Data = map[string]interface{}{
+		"input": "foobar",
+	}
+	resp, err = b.HandleRequest(context.Background(), signReq)
+	if err != logical.ErrInvalidRequest || resp == nil || !resp.IsError() {
+		t.Fatalf("expected invalid request error, got err:%v resp:%#v", err, resp)
+	}
+}
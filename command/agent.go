@@ -4,10 +4,13 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
 	"os"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/kr/pretty"
 	"github.com/mitchellh/cli"
@@ -23,9 +26,13 @@ import (
 	"github.com/hashicorp/vault/command/agent/auth/gcp"
 	"github.com/hashicorp/vault/command/agent/auth/jwt"
 	"github.com/hashicorp/vault/command/agent/auth/kubernetes"
+	"github.com/hashicorp/vault/command/agent/cache"
 	"github.com/hashicorp/vault/command/agent/config"
 	"github.com/hashicorp/vault/command/agent/sink"
 	"github.com/hashicorp/vault/command/agent/sink/file"
+	"github.com/hashicorp/vault/command/agent/sink/inmem"
+	"github.com/hashicorp/vault/command/agent/template"
+	"github.com/hashicorp/vault/command/server"
 	"github.com/hashicorp/vault/helper/gated-writer"
 	"github.com/hashicorp/vault/helper/logging"
 	"github.com/hashicorp/vault/version"
@@ -64,7 +71,7 @@ func (c *AgentCommand) Help() string {
 Usage: vault agent [options]
 
   This command starts a Vault agent that can perform automatic authentication
-  in certain environments.
+  in certain environments, and act as a caching proxy in front of Vault.
 
   Start an agent with a configuration file:
 
@@ -195,8 +202,8 @@ func (c *AgentCommand) Run(args []string) int {
 				"-config flag."))
 		return 1
 	}
-	if config.AutoAuth == nil {
-		c.UI.Error("No auto_auth block found in config file")
+	if config.AutoAuth == nil && config.Cache == nil {
+		c.UI.Error("No auto_auth or cache block found in config file")
 		return 1
 	}
 
@@ -218,19 +225,6 @@ func (c *AgentCommand) Run(args []string) int {
 		info["cgo"] = "enabled"
 	}
 
-	// Server configuration output
-	padding := 24
-	sort.Strings(infoKeys)
-	c.UI.Output("==> Vault agent configuration:\n")
-	for _, k := range infoKeys {
-		c.UI.Output(fmt.Sprintf(
-			"%s%s: %s",
-			strings.Repeat(" ", padding-len(k)),
-			strings.Title(k),
-			info[k]))
-	}
-	c.UI.Output("")
-
 	// Tests might not want to start a vault server and just want to verify
 	// the configuration.
 	if c.flagTestVerifyOnly {
@@ -251,62 +245,170 @@ func (c *AgentCommand) Run(args []string) int {
 	}
 
 	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
 
 	var sinks []*sink.SinkConfig
-	for _, sc := range config.AutoAuth.Sinks {
-		switch sc.Type {
-		case "file":
-			config := &sink.SinkConfig{
-				Logger:  c.logger.Named("sink.file"),
-				Config:  sc.Config,
-				Client:  client,
-				WrapTTL: sc.WrapTTL,
-				DHType:  sc.DHType,
-				DHPath:  sc.DHPath,
-				AAD:     sc.AAD,
-			}
-			s, err := file.NewFileSink(config)
-			if err != nil {
-				c.UI.Error(errwrap.Wrapf("Error creating file sink: {{err}}", err).Error())
+	var method auth.AuthMethod
+	if config.AutoAuth != nil {
+		for _, sc := range config.AutoAuth.Sinks {
+			switch sc.Type {
+			case "file":
+				config := &sink.SinkConfig{
+					Logger:  c.logger.Named("sink.file"),
+					Config:  sc.Config,
+					Client:  client,
+					WrapTTL: sc.WrapTTL,
+					DHType:  sc.DHType,
+					DHPath:  sc.DHPath,
+					AAD:     sc.AAD,
+				}
+				s, err := file.NewFileSink(config)
+				if err != nil {
+					c.UI.Error(errwrap.Wrapf("Error creating file sink: {{err}}", err).Error())
+					return 1
+				}
+				config.Sink = s
+				sinks = append(sinks, config)
+			default:
+				c.UI.Error(fmt.Sprintf("Unknown sink type %q", sc.Type))
 				return 1
 			}
-			config.Sink = s
-			sinks = append(sinks, config)
+		}
+
+		authConfig := &auth.AuthConfig{
+			Logger:    c.logger.Named(fmt.Sprintf("auth.%s", config.AutoAuth.Method.Type)),
+			MountPath: config.AutoAuth.Method.MountPath,
+			Config:    config.AutoAuth.Method.Config,
+		}
+		switch config.AutoAuth.Method.Type {
+		case "alicloud":
+			method, err = alicloud.NewAliCloudAuthMethod(authConfig)
+		case "aws":
+			method, err = aws.NewAWSAuthMethod(authConfig)
+		case "azure":
+			method, err = azure.NewAzureAuthMethod(authConfig)
+		case "gcp":
+			method, err = gcp.NewGCPAuthMethod(authConfig)
+		case "jwt":
+			method, err = jwt.NewJWTAuthMethod(authConfig)
+		case "kubernetes":
+			method, err = kubernetes.NewKubernetesAuthMethod(authConfig)
+		case "approle":
+			method, err = approle.NewApproleAuthMethod(authConfig)
 		default:
-			c.UI.Error(fmt.Sprintf("Unknown sink type %q", sc.Type))
+			c.UI.Error(fmt.Sprintf("Unknown auth method %q", config.AutoAuth.Method.Type))
+			return 1
+		}
+		if err != nil {
+			c.UI.Error(errwrap.Wrapf(fmt.Sprintf("Error creating %s auth method: {{err}}", config.AutoAuth.Method.Type), err).Error())
 			return 1
 		}
 	}
 
-	var method auth.AuthMethod
-	authConfig := &auth.AuthConfig{
-		Logger:    c.logger.Named(fmt.Sprintf("auth.%s", config.AutoAuth.Method.Type)),
-		MountPath: config.AutoAuth.Method.MountPath,
-		Config:    config.AutoAuth.Method.Config,
+	// Render templates using the auto-auth token, if configured
+	var ts *template.Server
+	if len(config.Templates) > 0 {
+		ts = template.NewServer(&template.ServerConfig{
+			Logger:        c.logger.Named("template.server"),
+			Client:        client,
+			Templates:     config.Templates,
+			ExitAfterAuth: config.ExitAfterAuth,
+		})
+		sinks = append(sinks, &sink.SinkConfig{
+			Logger: c.logger.Named("sink.template"),
+			Client: client,
+			Sink:   ts,
+		})
 	}
-	switch config.AutoAuth.Method.Type {
-	case "alicloud":
-		method, err = alicloud.NewAliCloudAuthMethod(authConfig)
-	case "aws":
-		method, err = aws.NewAWSAuthMethod(authConfig)
-	case "azure":
-		method, err = azure.NewAzureAuthMethod(authConfig)
-	case "gcp":
-		method, err = gcp.NewGCPAuthMethod(authConfig)
-	case "jwt":
-		method, err = jwt.NewJWTAuthMethod(authConfig)
-	case "kubernetes":
-		method, err = kubernetes.NewKubernetesAuthMethod(authConfig)
-	case "approle":
-		method, err = approle.NewApproleAuthMethod(authConfig)
-	default:
-		c.UI.Error(fmt.Sprintf("Unknown auth method %q", config.AutoAuth.Method.Type))
-		return 1
+
+	// Start the caching proxy, if configured
+	if config.Cache != nil {
+		cacheLogger := c.logger.Named("cache")
+
+		// Create the API proxier
+		apiProxy, err := cache.NewAPIProxy(&cache.APIProxyConfig{
+			Client: client,
+			Logger: cacheLogger.Named("apiproxy"),
+		})
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error creating API proxy: %v", err))
+			return 1
+		}
+
+		// Create the lease cache proxier and set its underlying proxier to
+		// the API proxier.
+		leaseCache, err := cache.NewLeaseCache(&cache.LeaseCacheConfig{
+			Client:      client,
+			BaseContext: ctx,
+			Proxier:     apiProxy,
+			Logger:      cacheLogger.Named("leasecache"),
+		})
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error creating lease cache: %v", err))
+			return 1
+		}
+
+		handlerConfig := &cache.HandlerConfig{
+			Context: ctx,
+			Logger:  cacheLogger,
+			Proxier: leaseCache,
+		}
+
+		// Keep the auto-auth token in memory so that it can be used for
+		// requests that arrive without a token
+		if config.Cache.UseAutoAuthToken {
+			cacheLogger.Debug("auto-auth token is allowed to be used; configuring inmem sink")
+			inmemSinkConfig := &sink.SinkConfig{
+				Logger: cacheLogger,
+			}
+			inmemSink, err := inmem.New(inmemSinkConfig)
+			if err != nil {
+				c.UI.Error(fmt.Sprintf("Error creating inmem sink for cache: %v", err))
+				return 1
+			}
+			inmemSinkConfig.Sink = inmemSink
+			sinks = append(sinks, inmemSinkConfig)
+			handlerConfig.TokenSource = inmemSink.(sink.SinkReader)
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle("/", cache.Handler(handlerConfig))
+
+		for i, lnConfig := range config.Listeners {
+			ln, err := agentListener(lnConfig, c.logWriter, c.UI)
+			if err != nil {
+				c.UI.Error(fmt.Sprintf("Error starting listener: %v", err))
+				return 1
+			}
+			defer ln.Close()
+
+			server := &http.Server{
+				Handler:           mux,
+				ReadHeaderTimeout: 10 * time.Second,
+				ReadTimeout:       30 * time.Second,
+				IdleTimeout:       5 * time.Minute,
+				ErrorLog:          cacheLogger.StandardLogger(nil),
+			}
+			go server.Serve(ln)
+
+			infoKey := fmt.Sprintf("api address %d", i+1)
+			info[infoKey] = ln.Addr().String()
+			infoKeys = append(infoKeys, infoKey)
+		}
 	}
-	if err != nil {
-		c.UI.Error(errwrap.Wrapf(fmt.Sprintf("Error creating %s auth method: {{err}}", config.AutoAuth.Method.Type), err).Error())
-		return 1
+
+	// Server configuration output
+	padding := 24
+	sort.Strings(infoKeys)
+	c.UI.Output("==> Vault agent configuration:\n")
+	for _, k := range infoKeys {
+		c.UI.Output(fmt.Sprintf(
+			"%s%s: %s",
+			strings.Repeat(" ", padding-len(k)),
+			strings.Title(k),
+			info[k]))
 	}
+	c.UI.Output("")
 
 	// Output the header that the server has started
 	if !c.flagCombineLogs {
@@ -319,22 +421,30 @@ func (c *AgentCommand) Run(args []string) int {
 	default:
 	}
 
-	ss := sink.NewSinkServer(&sink.SinkServerConfig{
-		Logger:        c.logger.Named("sink.server"),
-		Client:        client,
-		ExitAfterAuth: config.ExitAfterAuth,
-	})
-
-	ah := auth.NewAuthHandler(&auth.AuthHandlerConfig{
-		Logger:  c.logger.Named("auth.handler"),
-		Client:  c.client,
-		WrapTTL: config.AutoAuth.Method.WrapTTL,
-		EnableReauthOnNewCredentials: config.AutoAuth.EnableReauthOnNewCredentials,
-	})
+	var ssDoneCh, ahDoneCh chan struct{}
+	if config.AutoAuth != nil {
+		ss := sink.NewSinkServer(&sink.SinkServerConfig{
+			Logger:        c.logger.Named("sink.server"),
+			Client:        client,
+			ExitAfterAuth: config.ExitAfterAuth,
+		})
+
+		ah := auth.NewAuthHandler(&auth.AuthHandlerConfig{
+			Logger:                       c.logger.Named("auth.handler"),
+			Client:                       c.client,
+			WrapTTL:                      config.AutoAuth.Method.WrapTTL,
+			EnableReauthOnNewCredentials: config.AutoAuth.EnableReauthOnNewCredentials,
+		})
+
+		// Start things running
+		go ah.Run(ctx, method)
+		go ss.Run(ctx, ah.OutputCh, sinks)
+		if ts != nil {
+			go ts.Run(ctx)
+		}
 
-	// Start things running
-	go ah.Run(ctx, method)
-	go ss.Run(ctx, ah.OutputCh, sinks)
+		ssDoneCh, ahDoneCh = ss.DoneCh, ah.DoneCh
+	}
 
 	// Release the log gate.
 	c.logGate.Flush()
@@ -352,19 +462,62 @@ func (c *AgentCommand) Run(args []string) int {
 	}()
 
 	select {
-	case <-ss.DoneCh:
+	case <-ssDoneCh:
 		// This will happen if we exit-on-auth
+		if ts != nil {
+			select {
+			case <-ts.DoneCh:
+			case <-c.ShutdownCh:
+				cancelFunc()
+				<-ts.DoneCh
+			}
+		}
 		c.logger.Info("sinks finished, exiting")
 	case <-c.ShutdownCh:
 		c.UI.Output("==> Vault agent shutdown triggered")
 		cancelFunc()
-		<-ah.DoneCh
-		<-ss.DoneCh
+		if ahDoneCh != nil {
+			<-ahDoneCh
+		}
+		if ssDoneCh != nil {
+			<-ssDoneCh
+		}
+		if ts != nil {
+			<-ts.DoneCh
+		}
 	}
 
 	return 0
 }
 
+// agentListener creates a listener for the caching proxy
+func agentListener(lnConfig *config.Listener, logger io.Writer, ui cli.Ui) (net.Listener, error) {
+	switch lnConfig.Type {
+	case "tcp":
+		ln, _, _, err := server.NewListener(lnConfig.Type, lnConfig.Config, logger, ui)
+		return ln, err
+
+	case "unix":
+		addrRaw, ok := lnConfig.Config["address"]
+		if !ok {
+			return nil, fmt.Errorf("'address' must be set for unix listeners")
+		}
+		addr, ok := addrRaw.(string)
+		if !ok || addr == "" {
+			return nil, fmt.Errorf("invalid address for unix listener")
+		}
+
+		// Remove a socket left behind by a previous run
+		if err := os.Remove(addr); err != nil && !os.IsNotExist(err) {
+			return nil, errwrap.Wrapf("failed to remove existing socket file: {{err}}", err)
+		}
+		return net.Listen("unix", addr)
+
+	default:
+		return nil, fmt.Errorf("unknown listener type %q", lnConfig.Type)
+	}
+}
+
 // storePidFile is used to write out our PID to a file if necessary
 func (c *AgentCommand) storePidFile(pidPath string) error {
 	// Quit fast if no pidfile
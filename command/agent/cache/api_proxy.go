@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/consts"
+)
+
+// APIProxy is an implementation of the proxier interface that is used to
+// forward the request to Vault and get the response.
+type APIProxy struct {
+	client *api.Client
+	logger hclog.Logger
+}
+
+type APIProxyConfig struct {
+	Client *api.Client
+	Logger hclog.Logger
+}
+
+// NewAPIProxy creates a proxier that forwards requests to the Vault server
+// configured in the given client
+func NewAPIProxy(config *APIProxyConfig) (Proxier, error) {
+	if config.Client == nil {
+		return nil, errors.New("nil API client")
+	}
+	return &APIProxy{
+		client: config.Client,
+		logger: config.Logger,
+	}, nil
+}
+
+func (ap *APIProxy) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
+	client, err := ap.client.Clone()
+	if err != nil {
+		return nil, err
+	}
+	client.SetToken(req.Token)
+	// Wrapping is controlled by the header of the incoming request
+	client.SetWrappingLookupFunc(func(string, string) string { return "" })
+
+	fwReq := client.NewRequest(req.Request.Method, req.Request.URL.Path)
+	fwReq.BodyBytes = req.RequestBody
+
+	// Forward the headers of the incoming request, apart from the token
+	// which was set on the client
+	fwReq.Headers = make(http.Header, len(req.Request.Header))
+	for k, v := range req.Request.Header {
+		if http.CanonicalHeaderKey(k) == consts.AuthHeaderName {
+			continue
+		}
+		fwReq.Headers[k] = append([]string(nil), v...)
+	}
+
+	query := req.Request.URL.Query()
+	if len(query) != 0 {
+		fwReq.Params = query
+	}
+
+	// Make the request to Vault and get the response
+	ap.logger.Debug("forwarding request", "path", req.Request.URL.Path, "method", req.Request.Method)
+
+	resp, err := client.RawRequestWithContext(ctx, fwReq)
+	if resp == nil && err != nil {
+		// We don't want to cache nil responses, so we simply return the error
+		return nil, err
+	}
+
+	// Error responses from Vault are passed back to the client as they are
+	return NewSendResponse(resp, nil)
+}
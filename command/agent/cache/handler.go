@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/hashicorp/errwrap"
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/command/agent/sink"
+	"github.com/hashicorp/vault/helper/consts"
+)
+
+// maxRequestSize is the maximum size of a request body accepted by the
+// proxy, matching the default of the Vault server
+const maxRequestSize = 32 * 1024 * 1024
+
+// HandlerConfig is the configuration for the proxy's HTTP handler
+type HandlerConfig struct {
+	Context context.Context
+	Logger  hclog.Logger
+	Proxier Proxier
+
+	// TokenSource, if set, provides the auto-auth token used for requests
+	// that do not carry a token of their own
+	TokenSource sink.SinkReader
+}
+
+// Handler returns the HTTP handler serving the agent's proxy
+func Handler(conf *HandlerConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conf.Logger.Info("received request", "path", r.URL.Path, "method", r.Method)
+
+		token := r.Header.Get(consts.AuthHeaderName)
+		if token == "" && conf.TokenSource != nil {
+			conf.Logger.Debug("using auto auth token", "path", r.URL.Path, "method", r.Method)
+			token = conf.TokenSource.Token()
+		}
+
+		// Parse and reset body.
+		reqBody, err := ioutil.ReadAll(io.LimitReader(r.Body, maxRequestSize+1))
+		if err != nil {
+			conf.Logger.Error("failed to read request body")
+			respondError(w, http.StatusInternalServerError, errors.New("failed to read request body"))
+			return
+		}
+		if len(reqBody) > maxRequestSize {
+			respondError(w, http.StatusRequestEntityTooLarge, errors.New("request body too large"))
+			return
+		}
+		if r.Body != nil {
+			r.Body.Close()
+		}
+
+		resp, err := conf.Proxier.Send(conf.Context, &SendRequest{
+			Token:       token,
+			Request:     r,
+			RequestBody: reqBody,
+		})
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, errwrap.Wrapf("failed to get the response: {{err}}", err))
+			return
+		}
+		defer resp.Response.Body.Close()
+
+		copyHeader(w.Header(), resp.Response.Header)
+		w.WriteHeader(resp.Response.StatusCode)
+		w.Write(resp.ResponseBody)
+	})
+}
+
+// copyHeader copies all headers from src to dst
+func copyHeader(dst, src http.Header) {
+	for k, vv := range src {
+		for _, v := range vv {
+			dst.Add(k, v)
+		}
+	}
+}
+
+// respondError writes an error response in the same format as the Vault
+// server
+func respondError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	resp := struct {
+		Errors []string `json:"errors"`
+	}{
+		Errors: []string{err.Error()},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.Encode(resp)
+}
@@ -0,0 +1,496 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/locksutil"
+)
+
+const (
+	vaultPathTokenRevoke         = "auth/token/revoke"
+	vaultPathTokenRevokeSelf     = "auth/token/revoke-self"
+	vaultPathTokenRevokeAccessor = "auth/token/revoke-accessor"
+	vaultPathTokenRevokeOrphan   = "auth/token/revoke-orphan"
+	vaultPathLeaseRevoke         = "sys/leases/revoke"
+	vaultPathLeaseRevokeForce    = "sys/leases/revoke-force"
+	vaultPathLeaseRevokePrefix   = "sys/leases/revoke-prefix"
+	vaultPathLegacyRevoke        = "sys/revoke"
+	vaultPathLegacyRevokeForce   = "sys/revoke-force"
+	vaultPathLegacyRevokePrefix  = "sys/revoke-prefix"
+)
+
+// cacheEntry is a cached response along with the information needed to
+// evict it
+type cacheEntry struct {
+	// ID is the hash of the request that produced the response
+	ID string
+
+	// Token is the token used to make the request
+	Token string
+
+	// ClientToken is the token created by the response, if any
+	ClientToken string
+
+	// TokenAccessor is the accessor of ClientToken
+	TokenAccessor string
+
+	// LeaseID is the lease created by the response, if any
+	LeaseID string
+
+	// RequestPath is the path of the request, without the /v1/ prefix
+	RequestPath string
+
+	// Response is the cached response
+	Response *SendResponse
+
+	// cancel stops the renewal of the lease or token
+	cancel context.CancelFunc
+}
+
+// LeaseCache is an implementation of Proxier that handles the caching of
+// responses. It passes the incoming request to an underlying Proxier
+// implementation. Responses that create a lease or a token are cached,
+// and the lease or token is renewed for as long as possible. Entries are
+// evicted when the lease or token expires, or when it is revoked through the
+// agent.
+type LeaseCache struct {
+	client      *api.Client
+	proxier     Proxier
+	logger      hclog.Logger
+	baseCtx     context.Context
+	requestLock []*locksutil.LockEntry
+
+	l             sync.RWMutex
+	entries       map[string]*cacheEntry
+	byLease       map[string]string
+	byClientToken map[string]string
+	byAccessor    map[string]string
+	byToken       map[string]map[string]struct{}
+}
+
+// LeaseCacheConfig is the configuration for initializing a new LeaseCache
+type LeaseCacheConfig struct {
+	Client      *api.Client
+	BaseContext context.Context
+	Proxier     Proxier
+	Logger      hclog.Logger
+}
+
+// NewLeaseCache creates a new instance of a LeaseCache
+func NewLeaseCache(conf *LeaseCacheConfig) (*LeaseCache, error) {
+	if conf == nil {
+		return nil, errors.New("nil configuration provided")
+	}
+
+	if conf.Proxier == nil || conf.Logger == nil {
+		return nil, errors.New("missing configuration required params")
+	}
+
+	if conf.Client == nil {
+		return nil, errors.New("nil API client")
+	}
+
+	baseCtx := conf.BaseContext
+	if baseCtx == nil {
+		baseCtx = context.Background()
+	}
+
+	return &LeaseCache{
+		client:        conf.Client,
+		proxier:       conf.Proxier,
+		logger:        conf.Logger,
+		baseCtx:       baseCtx,
+		requestLock:   locksutil.CreateLocks(),
+		entries:       make(map[string]*cacheEntry),
+		byLease:       make(map[string]string),
+		byClientToken: make(map[string]string),
+		byAccessor:    make(map[string]string),
+		byToken:       make(map[string]map[string]struct{}),
+	}, nil
+}
+
+// Send performs a cache lookup on the incoming request. If it's a cache hit,
+// it will return the cached response, otherwise it will delegate to the
+// underlying Proxier and cache the received response if it created a lease
+// or a token.
+func (c *LeaseCache) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
+	id := computeIndexID(req)
+	requestPath := strings.TrimPrefix(req.Request.URL.Path, "/v1/")
+
+	// Serialize identical requests so that only one of them reaches Vault
+	// and the rest are served from the cache
+	lock := locksutil.LockForKey(c.requestLock, id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if entry := c.get(id); entry != nil {
+		c.logger.Debug("returning cached response", "path", requestPath)
+		return entry.Response.copyResponse(), nil
+	}
+
+	resp, err := c.proxier.Send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only successful responses are considered for caching and eviction
+	if resp.Response.StatusCode < 200 || resp.Response.StatusCode >= 300 {
+		return resp, nil
+	}
+
+	if c.handleRevocation(req, requestPath) {
+		return resp, nil
+	}
+
+	secret, err := api.ParseSecret(bytes.NewReader(resp.ResponseBody))
+	if err != nil || secret == nil {
+		// Not a secret, so there is nothing to cache
+		return resp, nil
+	}
+
+	// Wrapping tokens are single use, so they must never be served twice
+	if secret.WrapInfo != nil {
+		return resp, nil
+	}
+
+	entry := &cacheEntry{
+		ID:          id,
+		Token:       req.Token,
+		RequestPath: requestPath,
+		Response:    resp.copyResponse(),
+	}
+
+	var renewToken string
+	switch {
+	case secret.Auth != nil && secret.Auth.ClientToken != "":
+		entry.ClientToken = secret.Auth.ClientToken
+		entry.TokenAccessor = secret.Auth.Accessor
+		renewToken = secret.Auth.ClientToken
+	case secret.LeaseID != "":
+		entry.LeaseID = secret.LeaseID
+		renewToken = req.Token
+	default:
+		// Neither a token nor a lease was created
+		return resp, nil
+	}
+
+	renewCtx, cancel := context.WithCancel(c.baseCtx)
+	entry.cancel = cancel
+
+	c.set(entry)
+	c.logger.Debug("storing response into the cache", "path", requestPath)
+
+	go c.renew(renewCtx, entry, secret, renewToken)
+
+	return resp, nil
+}
+
+// renew keeps the lease or token of the entry alive for as long as possible
+// and evicts the entry once it can no longer be renewed.
+func (c *LeaseCache) renew(ctx context.Context, entry *cacheEntry, secret *api.Secret, token string) {
+	defer c.evict(entry)
+
+	cachedAt := time.Now()
+
+	client, err := c.client.Clone()
+	if err != nil {
+		c.logger.Error("failed to create API client for renewal", "error", err)
+		return
+	}
+	client.SetToken(token)
+
+	renewer, err := client.NewRenewer(&api.RenewerInput{
+		Secret: secret,
+	})
+	if err != nil {
+		c.logger.Error("failed to create renewer", "error", err)
+		return
+	}
+	go renewer.Renew()
+	defer renewer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case err := <-renewer.DoneCh():
+			if err != api.ErrRenewerNotRenewable {
+				if err != nil {
+					c.logger.Debug("renewal halted, evicting from cache", "path", entry.RequestPath, "error", err)
+				}
+				return
+			}
+
+			// Serve the response until the lease or token expires
+			ttl := secret.LeaseDuration
+			if secret.Auth != nil {
+				ttl = secret.Auth.LeaseDuration
+			}
+			if ttl == 0 {
+				// Tokens without a TTL, like root tokens, never expire
+				<-ctx.Done()
+				return
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Until(cachedAt.Add(time.Duration(ttl) * time.Second))):
+				c.logger.Debug("lease expired, evicting from cache", "path", entry.RequestPath)
+			}
+			return
+
+		case <-renewer.RenewCh():
+			c.logger.Debug("renewal received", "path", entry.RequestPath)
+		}
+	}
+}
+
+// handleRevocation evicts the cache entries affected by a successful
+// revocation request. It returns whether the request was a revocation.
+func (c *LeaseCache) handleRevocation(req *SendRequest, requestPath string) bool {
+	switch {
+	case requestPath == vaultPathTokenRevoke, requestPath == vaultPathTokenRevokeOrphan:
+		token, ok := bodyValue(req, "token")
+		if !ok {
+			return true
+		}
+		c.evictToken(token)
+
+	case requestPath == vaultPathTokenRevokeSelf:
+		c.evictToken(req.Token)
+
+	case requestPath == vaultPathTokenRevokeAccessor:
+		accessor, ok := bodyValue(req, "accessor")
+		if !ok {
+			return true
+		}
+		c.l.RLock()
+		id, ok := c.byAccessor[accessor]
+		var token string
+		if ok {
+			token = c.entries[id].ClientToken
+		}
+		c.l.RUnlock()
+		if token != "" {
+			c.evictToken(token)
+		}
+
+	case requestPath == vaultPathLeaseRevoke, requestPath == vaultPathLegacyRevoke:
+		leaseID, ok := bodyValue(req, "lease_id")
+		if !ok {
+			return true
+		}
+		c.evictLease(leaseID, false)
+
+	case strings.HasPrefix(requestPath, vaultPathLeaseRevoke+"/"):
+		c.evictLease(strings.TrimPrefix(requestPath, vaultPathLeaseRevoke+"/"), false)
+
+	case strings.HasPrefix(requestPath, vaultPathLegacyRevoke+"/"):
+		c.evictLease(strings.TrimPrefix(requestPath, vaultPathLegacyRevoke+"/"), false)
+
+	case strings.HasPrefix(requestPath, vaultPathLeaseRevokePrefix+"/"):
+		c.evictLease(strings.TrimPrefix(requestPath, vaultPathLeaseRevokePrefix+"/"), true)
+
+	case strings.HasPrefix(requestPath, vaultPathLeaseRevokeForce+"/"):
+		c.evictLease(strings.TrimPrefix(requestPath, vaultPathLeaseRevokeForce+"/"), true)
+
+	case strings.HasPrefix(requestPath, vaultPathLegacyRevokePrefix+"/"):
+		c.evictLease(strings.TrimPrefix(requestPath, vaultPathLegacyRevokePrefix+"/"), true)
+
+	case strings.HasPrefix(requestPath, vaultPathLegacyRevokeForce+"/"):
+		c.evictLease(strings.TrimPrefix(requestPath, vaultPathLegacyRevokeForce+"/"), true)
+
+	default:
+		return false
+	}
+
+	return true
+}
+
+// bodyValue returns a string value from the JSON body of the request
+func bodyValue(req *SendRequest, key string) (string, bool) {
+	var body map[string]interface{}
+	if err := jsonutil.DecodeJSON(req.RequestBody, &body); err != nil {
+		return "", false
+	}
+	value, ok := body[key].(string)
+	if !ok || value == "" {
+		return "", false
+	}
+	return value, true
+}
+
+// get returns the cache entry with the given ID, or nil
+func (c *LeaseCache) get(id string) *cacheEntry {
+	c.l.RLock()
+	defer c.l.RUnlock()
+	return c.entries[id]
+}
+
+// set stores the cache entry and indexes it
+func (c *LeaseCache) set(entry *cacheEntry) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	c.entries[entry.ID] = entry
+	if entry.LeaseID != "" {
+		c.byLease[entry.LeaseID] = entry.ID
+	}
+	if entry.ClientToken != "" {
+		c.byClientToken[entry.ClientToken] = entry.ID
+	}
+	if entry.TokenAccessor != "" {
+		c.byAccessor[entry.TokenAccessor] = entry.ID
+	}
+	if entry.Token != "" {
+		ids, ok := c.byToken[entry.Token]
+		if !ok {
+			ids = make(map[string]struct{})
+			c.byToken[entry.Token] = ids
+		}
+		ids[entry.ID] = struct{}{}
+	}
+}
+
+// evict removes the cache entry and stops its renewal, unless it has
+// already been replaced by a newer entry for the same request
+func (c *LeaseCache) evict(entry *cacheEntry) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	if c.entries[entry.ID] == entry {
+		c.evictLocked(entry.ID)
+	}
+}
+
+// evictLocked removes the cache entry with the given ID; the caller must hold
+// the write lock
+func (c *LeaseCache) evictLocked(id string) *cacheEntry {
+	entry, ok := c.entries[id]
+	if !ok {
+		return nil
+	}
+
+	delete(c.entries, id)
+	if entry.LeaseID != "" {
+		delete(c.byLease, entry.LeaseID)
+	}
+	if entry.ClientToken != "" {
+		delete(c.byClientToken, entry.ClientToken)
+	}
+	if entry.TokenAccessor != "" {
+		delete(c.byAccessor, entry.TokenAccessor)
+	}
+	if ids, ok := c.byToken[entry.Token]; ok {
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(c.byToken, entry.Token)
+		}
+	}
+
+	if entry.cancel != nil {
+		entry.cancel()
+	}
+
+	return entry
+}
+
+// evictLease removes the entries holding the given lease, or all leases
+// under the given prefix
+func (c *LeaseCache) evictLease(lease string, prefix bool) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	if !prefix {
+		if id, ok := c.byLease[lease]; ok {
+			c.evictLocked(id)
+		}
+		return
+	}
+
+	for leaseID, id := range c.byLease {
+		if strings.HasPrefix(leaseID, lease) {
+			c.evictLocked(id)
+		}
+	}
+}
+
+// evictToken removes the entry that created the token, along with all
+// entries created using the token. Tokens created using the token are
+// evicted in turn, since revoking a token also revokes its children. Evicting
+// orphan tokens as well is harmless as they will simply be fetched again.
+func (c *LeaseCache) evictToken(token string) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	pending := []string{token}
+	seen := make(map[string]struct{})
+	for len(pending) > 0 {
+		token := pending[0]
+		pending = pending[1:]
+		if _, ok := seen[token]; ok {
+			continue
+		}
+		seen[token] = struct{}{}
+
+		if id, ok := c.byClientToken[token]; ok {
+			c.evictLocked(id)
+		}
+
+		for id := range c.byToken[token] {
+			if entry := c.evictLocked(id); entry != nil && entry.ClientToken != "" {
+				pending = append(pending, entry.ClientToken)
+			}
+		}
+	}
+}
+
+// computeIndexID results in a value that uniquely identifies a request
+// received by the agent. It does so by hashing the request method, path,
+// query, body and the headers that affect the response, along with the
+// token used.
+func computeIndexID(req *SendRequest) string {
+	h := sha256.New()
+	h.Write([]byte(req.Request.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(req.Request.URL.Path))
+	h.Write([]byte{0})
+	h.Write([]byte(req.Request.URL.Query().Encode()))
+	h.Write([]byte{0})
+	h.Write(req.RequestBody)
+	h.Write([]byte{0})
+	h.Write([]byte(req.Token))
+
+	headers := make([]string, 0, len(req.Request.Header))
+	for k := range req.Request.Header {
+		switch http.CanonicalHeaderKey(k) {
+		case consts.AuthHeaderName, "User-Agent", "Accept-Encoding", "Content-Length", "Connection":
+			continue
+		}
+		headers = append(headers, k)
+	}
+	sort.Strings(headers)
+	for _, k := range headers {
+		h.Write([]byte{0})
+		h.Write([]byte(k))
+		for _, v := range req.Request.Header[k] {
+			h.Write([]byte{0})
+			h.Write([]byte(v))
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
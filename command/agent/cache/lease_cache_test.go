@@ -0,0 +1,261 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/logging"
+)
+
+// mockProxier returns the responses it was created with, in order
+type mockProxier struct {
+	responses []*SendResponse
+	calls     int
+}
+
+func newMockProxier(responses []*SendResponse) *mockProxier {
+	return &mockProxier{
+		responses: responses,
+	}
+}
+
+func (p *mockProxier) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
+	if p.calls >= len(p.responses) {
+		return nil, errUnexpectedCall
+	}
+	resp := p.responses[p.calls]
+	p.calls++
+	return resp.copyResponse(), nil
+}
+
+var errUnexpectedCall = errors.New("unexpected call to proxier")
+
+func newTestSendResponse(status int, body string) *SendResponse {
+	return &SendResponse{
+		Response: &api.Response{
+			Response: &http.Response{
+				StatusCode: status,
+				Header:     make(http.Header),
+			},
+		},
+		ResponseBody: []byte(body),
+	}
+}
+
+func testNewLeaseCache(t *testing.T, responses []*SendResponse) (*LeaseCache, *mockProxier) {
+	t.Helper()
+
+	client, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxier := newMockProxier(responses)
+	lc, err := NewLeaseCache(&LeaseCacheConfig{
+		Client:      client,
+		BaseContext: context.Background(),
+		Proxier:     proxier,
+		Logger:      logging.NewVaultLogger(hclog.Trace).Named("cache.leasecache"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return lc, proxier
+}
+
+func testSendRequest(t *testing.T, method, path, token, body string) *SendRequest {
+	t.Helper()
+
+	return &SendRequest{
+		Token:       token,
+		Request:     httptest.NewRequest(method, path, strings.NewReader(body)),
+		RequestBody: []byte(body),
+	}
+}
+
+func testSend(t *testing.T, lc *LeaseCache, req *SendRequest, expectedBody string) {
+	t.Helper()
+
+	resp, err := lc.Send(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.ResponseBody) != expectedBody {
+		t.Fatalf("bad: response body; expected: %q, actual: %q", expectedBody, string(resp.ResponseBody))
+	}
+}
+
+func testNumEntries(lc *LeaseCache) int {
+	lc.l.RLock()
+	defer lc.l.RUnlock()
+	return len(lc.entries)
+}
+
+func testHasLease(lc *LeaseCache, leaseID string) bool {
+	lc.l.RLock()
+	defer lc.l.RUnlock()
+	_, ok := lc.byLease[leaseID]
+	return ok
+}
+
+func TestLeaseCache_EmptyToken(t *testing.T) {
+	responses := []*SendResponse{
+		newTestSendResponse(http.StatusOK, `{"value": "invalid", "auth": {"client_token": "testtoken"}}`),
+	}
+	lc, _ := testNewLeaseCache(t, responses)
+
+	// Even without a token, the token created by the request is cached
+	testSend(t, lc, testSendRequest(t, "POST", "/v1/auth/approle/login", "", `{"role_id": "foo"}`), string(responses[0].ResponseBody))
+	if testNumEntries(lc) != 1 {
+		t.Fatalf("expected one cached entry, got %d", testNumEntries(lc))
+	}
+}
+
+func TestLeaseCache_SendCacheable(t *testing.T) {
+	responses := []*SendResponse{
+		newTestSendResponse(http.StatusCreated, `{"auth": {"client_token": "testtoken", "accessor": "testaccessor", "renewable": false, "lease_duration": 600}}`),
+		newTestSendResponse(http.StatusOK, `{"lease_id": "secret/foo/1", "renewable": false, "lease_duration": 600, "data": {"value": "output"}}`),
+	}
+	lc, proxier := testNewLeaseCache(t, responses)
+
+	// The first request is forwarded and the token it creates is cached
+	loginReq := testSendRequest(t, "POST", "/v1/auth/token/create", "foo", `{"policies": ["default"]}`)
+	testSend(t, lc, loginReq, string(responses[0].ResponseBody))
+
+	// The same request is now served from the cache
+	loginReq = testSendRequest(t, "POST", "/v1/auth/token/create", "foo", `{"policies": ["default"]}`)
+	testSend(t, lc, loginReq, string(responses[0].ResponseBody))
+	if proxier.calls != 1 {
+		t.Fatalf("expected request to be served from the cache, proxier was called %d times", proxier.calls)
+	}
+
+	// A lease is cached as well
+	testSend(t, lc, testSendRequest(t, "GET", "/v1/secret/foo", "testtoken", ""), string(responses[1].ResponseBody))
+	testSend(t, lc, testSendRequest(t, "GET", "/v1/secret/foo", "testtoken", ""), string(responses[1].ResponseBody))
+	if proxier.calls != 2 {
+		t.Fatalf("expected request to be served from the cache, proxier was called %d times", proxier.calls)
+	}
+}
+
+func TestLeaseCache_SendNonCacheable(t *testing.T) {
+	responses := []*SendResponse{
+		newTestSendResponse(http.StatusOK, `{"data": {"value": "output"}}`),
+		newTestSendResponse(http.StatusOK, `{"data": {"value": "output"}}`),
+		newTestSendResponse(http.StatusNotFound, `{"errors": []}`),
+		newTestSendResponse(http.StatusNotFound, `{"errors": []}`),
+		newTestSendResponse(http.StatusOK, `{"wrap_info": {"token": "wrappingtoken", "ttl": 300}}`),
+		newTestSendResponse(http.StatusOK, `{"wrap_info": {"token": "wrappingtoken2", "ttl": 300}}`),
+	}
+	lc, proxier := testNewLeaseCache(t, responses)
+
+	for _, resp := range responses {
+		testSend(t, lc, testSendRequest(t, "GET", "/v1/secret/foo", "foo", ""), string(resp.ResponseBody))
+	}
+	if proxier.calls != len(responses) {
+		t.Fatalf("expected all requests to be forwarded, proxier was called %d times", proxier.calls)
+	}
+	if testNumEntries(lc) != 0 {
+		t.Fatalf("expected no cached entries, got %d", testNumEntries(lc))
+	}
+}
+
+func TestLeaseCache_RevokeToken(t *testing.T) {
+	responses := []*SendResponse{
+		newTestSendResponse(http.StatusOK, `{"auth": {"client_token": "parent", "accessor": "parentaccessor", "renewable": false, "lease_duration": 600}}`),
+		newTestSendResponse(http.StatusOK, `{"auth": {"client_token": "child", "accessor": "childaccessor", "renewable": false, "lease_duration": 600}}`),
+		newTestSendResponse(http.StatusOK, `{"lease_id": "secret/foo/1", "renewable": false, "lease_duration": 600}`),
+		newTestSendResponse(http.StatusOK, `{"lease_id": "secret/bar/1", "renewable": false, "lease_duration": 600}`),
+		newTestSendResponse(http.StatusNoContent, ``),
+	}
+	lc, _ := testNewLeaseCache(t, responses)
+
+	testSend(t, lc, testSendRequest(t, "POST", "/v1/auth/token/create", "root", `{}`), string(responses[0].ResponseBody))
+	testSend(t, lc, testSendRequest(t, "POST", "/v1/auth/token/create", "parent", `{}`), string(responses[1].ResponseBody))
+	testSend(t, lc, testSendRequest(t, "GET", "/v1/secret/foo", "child", ""), string(responses[2].ResponseBody))
+	testSend(t, lc, testSendRequest(t, "GET", "/v1/secret/bar", "root", ""), string(responses[3].ResponseBody))
+	if testNumEntries(lc) != 4 {
+		t.Fatalf("expected 4 cached entries, got %d", testNumEntries(lc))
+	}
+
+	// Revoking the parent token evicts the token itself, its child, and the
+	// lease created using the child
+	testSend(t, lc, testSendRequest(t, "POST", "/v1/auth/token/revoke", "root", `{"token": "parent"}`), "")
+	if testNumEntries(lc) != 1 {
+		t.Fatalf("expected 1 cached entry, got %d", testNumEntries(lc))
+	}
+	if !testHasLease(lc, "secret/bar/1") {
+		t.Fatal("expected unrelated lease to remain cached")
+	}
+}
+
+func TestLeaseCache_RevokeLease(t *testing.T) {
+	responses := []*SendResponse{
+		newTestSendResponse(http.StatusOK, `{"lease_id": "secret/foo/1", "renewable": false, "lease_duration": 600}`),
+		newTestSendResponse(http.StatusOK, `{"lease_id": "secret/foo/2", "renewable": false, "lease_duration": 600}`),
+		newTestSendResponse(http.StatusOK, `{"lease_id": "secret/bar/1", "renewable": false, "lease_duration": 600}`),
+		newTestSendResponse(http.StatusNoContent, ``),
+		newTestSendResponse(http.StatusNoContent, ``),
+	}
+	lc, _ := testNewLeaseCache(t, responses)
+
+	testSend(t, lc, testSendRequest(t, "GET", "/v1/secret/foo", "root", ""), string(responses[0].ResponseBody))
+	testSend(t, lc, testSendRequest(t, "GET", "/v1/secret/foo?version=2", "root", ""), string(responses[1].ResponseBody))
+	testSend(t, lc, testSendRequest(t, "GET", "/v1/secret/bar", "root", ""), string(responses[2].ResponseBody))
+
+	testSend(t, lc, testSendRequest(t, "PUT", "/v1/sys/leases/revoke", "root", `{"lease_id": "secret/bar/1"}`), "")
+	if testHasLease(lc, "secret/bar/1") {
+		t.Fatal("expected lease to be evicted")
+	}
+	if testNumEntries(lc) != 2 {
+		t.Fatalf("expected 2 cached entries, got %d", testNumEntries(lc))
+	}
+
+	testSend(t, lc, testSendRequest(t, "PUT", "/v1/sys/leases/revoke-prefix/secret/foo", "root", ""), "")
+	if testNumEntries(lc) != 0 {
+		t.Fatalf("expected no cached entries, got %d", testNumEntries(lc))
+	}
+}
+
+func TestLeaseCache_RevokeFailed(t *testing.T) {
+	responses := []*SendResponse{
+		newTestSendResponse(http.StatusOK, `{"lease_id": "secret/foo/1", "renewable": false, "lease_duration": 600}`),
+		newTestSendResponse(http.StatusForbidden, `{"errors": ["permission denied"]}`),
+	}
+	lc, _ := testNewLeaseCache(t, responses)
+
+	testSend(t, lc, testSendRequest(t, "GET", "/v1/secret/foo", "root", ""), string(responses[0].ResponseBody))
+
+	// A failed revocation must not evict anything
+	testSend(t, lc, testSendRequest(t, "PUT", "/v1/sys/leases/revoke", "root", `{"lease_id": "secret/foo/1"}`), string(responses[1].ResponseBody))
+	if testNumEntries(lc) != 1 {
+		t.Fatalf("expected 1 cached entry, got %d", testNumEntries(lc))
+	}
+}
+
+func TestLeaseCache_ExpiredEviction(t *testing.T) {
+	responses := []*SendResponse{
+		newTestSendResponse(http.StatusOK, `{"lease_id": "secret/foo/1", "renewable": false, "lease_duration": 1}`),
+	}
+	lc, _ := testNewLeaseCache(t, responses)
+
+	testSend(t, lc, testSendRequest(t, "GET", "/v1/secret/foo", "root", ""), string(responses[0].ResponseBody))
+	if lc.get(computeIndexID(testSendRequest(t, "GET", "/v1/secret/foo", "root", ""))) == nil {
+		t.Fatal("expected response to be cached")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for lc.get(computeIndexID(testSendRequest(t, "GET", "/v1/secret/foo", "root", ""))) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("expected entry to be evicted once the lease expired")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// SendRequest is the input for Proxier.Send.
+type SendRequest struct {
+	Token       string
+	Request     *http.Request
+	RequestBody []byte
+}
+
+// SendResponse is the output from Proxier.Send.
+type SendResponse struct {
+	Response     *api.Response
+	ResponseBody []byte
+}
+
+// Proxier is the interface implemented by different components that are
+// responsible for performing specific tasks, such as caching and proxying. All
+// these tasks combined together would serve the request received by the agent.
+type Proxier interface {
+	Send(ctx context.Context, req *SendRequest) (*SendResponse, error)
+}
+
+// NewSendResponse creates a SendResponse, reading the body of the response
+// into memory so that it can be served more than once. The response body of
+// apiResponse is replaced with a reader over the buffered body.
+func NewSendResponse(apiResponse *api.Response, responseBody []byte) (*SendResponse, error) {
+	resp := &SendResponse{
+		Response: apiResponse,
+	}
+
+	// If a response body is separately provided we set that as the SendResponse.ResponseBody,
+	// otherwise we will do an ioutil.ReadAll to extract the response body from apiResponse.
+	switch {
+	case len(responseBody) > 0:
+		resp.ResponseBody = responseBody
+	case apiResponse.Body != nil:
+		respBody, err := ioutil.ReadAll(apiResponse.Body)
+		if err != nil {
+			return nil, err
+		}
+		// Close the old body
+		apiResponse.Body.Close()
+
+		// Re-set the response body for potential consumption on the way back up the Proxier chain
+		apiResponse.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+		resp.ResponseBody = respBody
+	}
+
+	return resp, nil
+}
+
+// copyResponse returns a copy of the response whose body can be consumed
+// independently of the original
+func (r *SendResponse) copyResponse() *SendResponse {
+	httpResp := *r.Response.Response
+	httpResp.Header = make(http.Header, len(r.Response.Header))
+	for k, v := range r.Response.Header {
+		httpResp.Header[k] = append([]string(nil), v...)
+	}
+	httpResp.Body = ioutil.NopCloser(bytes.NewReader(r.ResponseBody))
+
+	return &SendResponse{
+		Response:     &api.Response{Response: &httpResp},
+		ResponseBody: r.ResponseBody,
+	}
+}
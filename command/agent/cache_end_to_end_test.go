@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/command/agent/cache"
+	"github.com/hashicorp/vault/helper/logging"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/vault"
+)
+
+func TestCache_UsingAgent(t *testing.T) {
+	logger := logging.NewVaultLogger(log.Trace)
+	coreConfig := &vault.CoreConfig{
+		DisableMlock: true,
+		DisableCache: true,
+		Logger:       log.NewNullLogger(),
+	}
+
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	vault.TestWaitActive(t, cluster.Cores[0].Core)
+	client := cluster.Cores[0].Client
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	apiProxy, err := cache.NewAPIProxy(&cache.APIProxyConfig{
+		Client: client,
+		Logger: logger.Named("cache.apiproxy"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaseCache, err := cache.NewLeaseCache(&cache.LeaseCacheConfig{
+		Client:      client,
+		BaseContext: ctx,
+		Proxier:     apiProxy,
+		Logger:      logger.Named("cache.leasecache"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle("/", cache.Handler(&cache.HandlerConfig{
+		Context: ctx,
+		Logger:  logger.Named("cache.handler"),
+		Proxier: leaseCache,
+	}))
+	go http.Serve(listener, mux)
+
+	// Talk to Vault through the agent
+	config := api.DefaultConfig()
+	config.Address = "http://" + listener.Addr().String()
+	agentClient, err := api.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agentClient.SetToken(client.Token())
+
+	createToken := func() string {
+		t.Helper()
+		secret, err := agentClient.Auth().Token().Create(&api.TokenCreateRequest{
+			Policies: []string{"default"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return secret.Auth.ClientToken
+	}
+
+	// Identical token creation requests are served from the cache
+	token := createToken()
+	if cached := createToken(); cached != token {
+		t.Fatalf("expected cached token %q, got %q", token, cached)
+	}
+
+	// Revoking the token through the agent evicts it from the cache
+	if err := agentClient.Auth().Token().RevokeTree(token); err != nil {
+		t.Fatal(err)
+	}
+	newToken := createToken()
+	if newToken == token {
+		t.Fatal("expected a new token after revocation")
+	}
+
+	// Requests that don't create a token or a lease are not cached
+	if _, err := agentClient.Logical().Write("secret/foo", map[string]interface{}{
+		"value": "bar",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := agentClient.Logical().Write("secret/foo", map[string]interface{}{
+		"value": "baz",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := agentClient.Logical().Read("secret/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret == nil || secret.Data["value"] != "baz" {
+		t.Fatalf("bad: %#v", secret)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,9 +20,33 @@ import (
 
 // Config is the configuration for the vault server.
 type Config struct {
-	AutoAuth      *AutoAuth `hcl:"auto_auth"`
-	ExitAfterAuth bool      `hcl:"exit_after_auth"`
-	PidFile       string    `hcl:"pid_file"`
+	AutoAuth      *AutoAuth   `hcl:"auto_auth"`
+	ExitAfterAuth bool        `hcl:"exit_after_auth"`
+	PidFile       string      `hcl:"pid_file"`
+	Listeners     []*Listener `hcl:"-"`
+	Cache         *Cache      `hcl:"cache"`
+	Templates     []*Template `hcl:"templates"`
+}
+
+// Cache contains the configuration of the agent's caching proxy
+type Cache struct {
+	UseAutoAuthToken bool `hcl:"use_auto_auth_token"`
+}
+
+// Listener is a listener the agent serves its proxy on
+type Listener struct {
+	Type   string
+	Config map[string]interface{}
+}
+
+// Template is a template rendered by the agent using secrets read with the
+// auto-auth token
+type Template struct {
+	Source      string      `hcl:"source"`
+	Destination string      `hcl:"destination"`
+	PermsRaw    interface{} `hcl:"perms"`
+	Perms       os.FileMode `hcl:"-"`
+	Command     string      `hcl:"command"`
 }
 
 type AutoAuth struct {
@@ -91,15 +116,153 @@ func LoadConfig(path string, logger log.Logger) (*Config, error) {
 		return nil, errwrap.Wrapf("error parsing 'auto_auth': {{err}}", err)
 	}
 
+	if err := parseCache(&result, list); err != nil {
+		return nil, errwrap.Wrapf("error parsing 'cache': {{err}}", err)
+	}
+
+	if err := parseListeners(&result, list); err != nil {
+		return nil, errwrap.Wrapf("error parsing 'listener' stanzas: {{err}}", err)
+	}
+
+	if err := parseTemplates(&result, list); err != nil {
+		return nil, errwrap.Wrapf("error parsing 'template' stanzas: {{err}}", err)
+	}
+
+	switch {
+	case result.AutoAuth == nil && result.Cache == nil:
+		return nil, errors.New("at least one of 'auto_auth' or 'cache' must be provided")
+	case result.Cache != nil && len(result.Listeners) == 0:
+		return nil, errors.New("at least one 'listener' block must be provided when 'cache' is configured")
+	case result.Cache == nil && len(result.Listeners) != 0:
+		return nil, errors.New("'listener' blocks require a 'cache' block")
+	case result.Cache != nil && result.Cache.UseAutoAuthToken && result.AutoAuth == nil:
+		return nil, errors.New("'use_auto_auth_token' requires an 'auto_auth' block")
+	case result.Cache != nil && result.ExitAfterAuth:
+		return nil, errors.New("'exit_after_auth' cannot be used together with 'cache'")
+	case len(result.Templates) != 0 && result.AutoAuth == nil:
+		return nil, errors.New("'template' blocks require an 'auto_auth' block")
+	}
+
 	return &result, nil
 }
 
+func parseCache(result *Config, list *ast.ObjectList) error {
+	name := "cache"
+
+	cacheList := list.Filter(name)
+	if len(cacheList.Items) == 0 {
+		return nil
+	}
+	if len(cacheList.Items) > 1 {
+		return fmt.Errorf("only one %q block is permitted", name)
+	}
+
+	// Get our item
+	item := cacheList.Items[0]
+
+	var c Cache
+	if err := hcl.DecodeObject(&c, item.Val); err != nil {
+		return err
+	}
+
+	result.Cache = &c
+	return nil
+}
+
+func parseListeners(result *Config, list *ast.ObjectList) error {
+	name := "listener"
+
+	listenerList := list.Filter(name)
+
+	var listeners []*Listener
+	for _, item := range listenerList.Items {
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, item.Val); err != nil {
+			return err
+		}
+
+		var lnType string
+		if len(item.Keys) == 1 {
+			lnType = strings.ToLower(item.Keys[0].Token.Value().(string))
+		}
+		if typeRaw, ok := m["type"]; ok {
+			if t, ok := typeRaw.(string); ok {
+				lnType = strings.ToLower(t)
+			}
+			delete(m, "type")
+		}
+
+		switch lnType {
+		case "tcp", "unix":
+		case "":
+			return errors.New("listener type must be specified")
+		default:
+			return fmt.Errorf("invalid listener type %q", lnType)
+		}
+
+		listeners = append(listeners, &Listener{
+			Type:   lnType,
+			Config: m,
+		})
+	}
+
+	result.Listeners = listeners
+	return nil
+}
+
+func parseTemplates(result *Config, list *ast.ObjectList) error {
+	name := "template"
+
+	templateList := list.Filter(name)
+
+	var templates []*Template
+	for i, item := range templateList.Items {
+		var t Template
+		if err := hcl.DecodeObject(&t, item.Val); err != nil {
+			return err
+		}
+
+		prefix := fmt.Sprintf("template.%d", i)
+		switch {
+		case t.Source == "":
+			return multierror.Prefix(errors.New("'source' must be specified"), prefix)
+		case t.Destination == "":
+			return multierror.Prefix(errors.New("'destination' must be specified"), prefix)
+		}
+
+		// Default to permissions readable by everyone, like most templating
+		// tools
+		t.Perms = 0644
+		if t.PermsRaw != nil {
+			permsStr, ok := t.PermsRaw.(string)
+			if !ok {
+				return multierror.Prefix(errors.New("'perms' must be an octal string, such as \"0640\""), prefix)
+			}
+			perms, err := strconv.ParseUint(permsStr, 8, 32)
+			if err != nil || perms > 0777 {
+				return multierror.Prefix(fmt.Errorf("invalid value %q for 'perms'", permsStr), prefix)
+			}
+			t.Perms = os.FileMode(perms)
+			t.PermsRaw = nil
+		}
+
+		templates = append(templates, &t)
+	}
+
+	result.Templates = templates
+	return nil
+}
+
 func parseAutoAuth(result *Config, list *ast.ObjectList) error {
 	name := "auto_auth"
 
 	autoAuthList := list.Filter(name)
+	if len(autoAuthList.Items) == 0 {
+		// Agents only running the caching proxy don't need to authenticate
+		return nil
+	}
 	if len(autoAuthList.Items) != 1 {
-		return fmt.Errorf("one and only one %q block is required", name)
+		return fmt.Errorf("only one %q block is permitted", name)
 	}
 
 	// Get our item
@@ -69,3 +69,146 @@ func TestLoadConfigFile(t *testing.T) {
 		t.Fatal(diff)
 	}
 }
+
+func TestLoadConfigFile_Cache(t *testing.T) {
+	logger := logging.NewVaultLogger(log.Debug)
+
+	config, err := LoadConfig("./test-fixtures/config-cache.hcl", logger)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &Config{
+		AutoAuth: &AutoAuth{
+			Method: &Method{
+				Type:      "aws",
+				WrapTTL:   300 * time.Second,
+				MountPath: "auth/aws",
+				Config: map[string]interface{}{
+					"role": "foobar",
+				},
+			},
+			Sinks: []*Sink{
+				&Sink{
+					Type:   "file",
+					DHType: "curve25519",
+					DHPath: "/tmp/file-foo-dhpath",
+					AAD:    "foobar",
+					Config: map[string]interface{}{
+						"path": "/tmp/file-foo",
+					},
+				},
+			},
+		},
+		Cache: &Cache{
+			UseAutoAuthToken: true,
+		},
+		Listeners: []*Listener{
+			&Listener{
+				Type: "unix",
+				Config: map[string]interface{}{
+					"address":     "/path/to/socket",
+					"tls_disable": true,
+				},
+			},
+			&Listener{
+				Type: "tcp",
+				Config: map[string]interface{}{
+					"address":     "127.0.0.1:8300",
+					"tls_disable": true,
+				},
+			},
+		},
+		PidFile: "./pidfile",
+	}
+
+	if diff := deep.Equal(config, expected); diff != nil {
+		t.Fatal(diff)
+	}
+
+	config, err = LoadConfig("./test-fixtures/config-cache-no-auto_auth.hcl", logger)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected = &Config{
+		Cache: &Cache{},
+		Listeners: []*Listener{
+			&Listener{
+				Type: "tcp",
+				Config: map[string]interface{}{
+					"address":     "127.0.0.1:8300",
+					"tls_disable": true,
+				},
+			},
+		},
+		PidFile: "./pidfile",
+	}
+
+	if diff := deep.Equal(config, expected); diff != nil {
+		t.Fatal(diff)
+	}
+}
+
+func TestLoadConfigFile_Template(t *testing.T) {
+	logger := logging.NewVaultLogger(log.Debug)
+
+	config, err := LoadConfig("./test-fixtures/config-template.hcl", logger)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &Config{
+		AutoAuth: &AutoAuth{
+			Method: &Method{
+				Type:      "aws",
+				MountPath: "auth/aws",
+				Config: map[string]interface{}{
+					"role": "foobar",
+				},
+			},
+			Sinks: []*Sink{
+				&Sink{
+					Type: "file",
+					Config: map[string]interface{}{
+						"path": "/tmp/file-foo",
+					},
+				},
+			},
+		},
+		Templates: []*Template{
+			&Template{
+				Source:      "/path/to/template.ctmpl",
+				Destination: "/path/to/rendered.conf",
+				Perms:       0600,
+				Command:     "systemctl reload app",
+			},
+			&Template{
+				Source:      "/path/to/other.ctmpl",
+				Destination: "/path/to/other.conf",
+				Perms:       0644,
+			},
+		},
+		PidFile: "./pidfile",
+	}
+
+	if diff := deep.Equal(config, expected); diff != nil {
+		t.Fatal(diff)
+	}
+}
+
+func TestLoadConfigFile_Bad(t *testing.T) {
+	logger := logging.NewVaultLogger(log.Debug)
+
+	for _, path := range []string{
+		"./test-fixtures/bad-config-cache-no-listeners.hcl",
+		"./test-fixtures/bad-config-cache-auto_auth-token.hcl",
+		"./test-fixtures/bad-config-listener-no-cache.hcl",
+		"./test-fixtures/bad-config-template-perms.hcl",
+		"./test-fixtures/bad-config-template-no-auto_auth.hcl",
+	} {
+		if _, err := LoadConfig(path, logger); err == nil {
+			t.Fatalf("expected error loading %s", path)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+pid_file = "./pidfile"
+
+cache {}
@@ -0,0 +1,23 @@
+pid_file = "./pidfile"
+
+auto_auth {
+	method {
+		type = "aws"
+		config = {
+			role = "foobar"
+		}
+	}
+
+	sink {
+		type = "file"
+		config = {
+			path = "/tmp/file-foo"
+		}
+	}
+}
+
+template {
+	source = "/path/to/template.ctmpl"
+	destination = "/path/to/rendered.conf"
+	perms = "0999"
+}
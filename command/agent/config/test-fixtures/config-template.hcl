@@ -0,0 +1,29 @@
+pid_file = "./pidfile"
+
+auto_auth {
+	method {
+		type = "aws"
+		config = {
+			role = "foobar"
+		}
+	}
+
+	sink {
+		type = "file"
+		config = {
+			path = "/tmp/file-foo"
+		}
+	}
+}
+
+template {
+	source = "/path/to/template.ctmpl"
+	destination = "/path/to/rendered.conf"
+	perms = "0600"
+	command = "systemctl reload app"
+}
+
+template {
+	source = "/path/to/other.ctmpl"
+	destination = "/path/to/other.conf"
+}
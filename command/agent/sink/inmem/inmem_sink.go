@@ -0,0 +1,41 @@
+package inmem
+
+import (
+	"errors"
+	"sync/atomic"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/command/agent/sink"
+)
+
+// inmemSink retains the auto-auth token in memory so that it can be used by
+// the agent's caching proxy
+type inmemSink struct {
+	logger hclog.Logger
+	token  atomic.Value
+}
+
+// New creates a new in-memory sink
+func New(conf *sink.SinkConfig) (sink.Sink, error) {
+	if conf.Logger == nil {
+		return nil, errors.New("nil logger provided")
+	}
+
+	s := &inmemSink{
+		logger: conf.Logger,
+	}
+	s.token.Store("")
+
+	return s, nil
+}
+
+// WriteToken implements the Sink interface and stores the token in memory
+func (s *inmemSink) WriteToken(token string) error {
+	s.token.Store(token)
+	return nil
+}
+
+// Token implements the SinkReader interface and returns the latest token
+func (s *inmemSink) Token() string {
+	return s.token.Load().(string)
+}
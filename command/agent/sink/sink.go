@@ -20,6 +20,11 @@ type Sink interface {
 	WriteToken(string) error
 }
 
+// SinkReader is implemented by sinks whose latest token can be read back
+type SinkReader interface {
+	Token() string
+}
+
 type SinkConfig struct {
 	Sink
 	Logger             hclog.Logger
@@ -0,0 +1,498 @@
+package template
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/command/agent/config"
+)
+
+const (
+	// DefaultStaticSecretRenderInterval is how often secrets without a lease,
+	// such as KV secrets, are read again so that changes to them are picked up
+	DefaultStaticSecretRenderInterval = 5 * time.Minute
+
+	// commandTimeout is the maximum time a template's command may run
+	commandTimeout = 30 * time.Second
+)
+
+type ServerConfig struct {
+	Logger        hclog.Logger
+	Client        *api.Client
+	Templates     []*config.Template
+	ExitAfterAuth bool
+
+	// StaticSecretRenderInterval overrides DefaultStaticSecretRenderInterval
+	StaticSecretRenderInterval time.Duration
+}
+
+// Server is responsible for rendering templates using the auto-auth token.
+// It is registered with the sink server as a sink, so that it receives every
+// new token obtained by the auth handler.
+type Server struct {
+	DoneCh         chan struct{}
+	logger         hclog.Logger
+	client         *api.Client
+	templates      []*config.Template
+	exitAfterAuth  bool
+	staticInterval time.Duration
+	tokenCh        chan string
+}
+
+func NewServer(conf *ServerConfig) *Server {
+	staticInterval := conf.StaticSecretRenderInterval
+	if staticInterval == 0 {
+		staticInterval = DefaultStaticSecretRenderInterval
+	}
+
+	return &Server{
+		DoneCh:         make(chan struct{}),
+		logger:         conf.Logger,
+		client:         conf.Client,
+		templates:      conf.Templates,
+		exitAfterAuth:  conf.ExitAfterAuth,
+		staticInterval: staticInterval,
+		tokenCh:        make(chan string, 1),
+	}
+}
+
+// WriteToken implements the sink.Sink interface. A token that has not been
+// picked up by the run loop yet is replaced by the newer one.
+func (ts *Server) WriteToken(token string) error {
+	for {
+		select {
+		case ts.tokenCh <- token:
+			return nil
+		default:
+		}
+
+		select {
+		case <-ts.tokenCh:
+		default:
+		}
+	}
+}
+
+// Run executes the server's run loop. Every time a new token is received, the
+// templates are rendered again from scratch using that token. If the server
+// was configured to exit after auth, the templates are rendered only once.
+func (ts *Server) Run(ctx context.Context) {
+	ts.logger.Info("starting template server")
+	defer func() {
+		ts.logger.Info("template server stopped")
+		close(ts.DoneCh)
+	}()
+
+	var wg sync.WaitGroup
+	var latestToken string
+	runCancel := func() {}
+	defer func() {
+		runCancel()
+		wg.Wait()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case token := <-ts.tokenCh:
+			if token == latestToken {
+				continue
+			}
+			latestToken = token
+
+			// Stop the runners using the previous token
+			runCancel()
+			wg.Wait()
+
+			client, err := ts.client.Clone()
+			if err != nil {
+				ts.logger.Error("error creating client for templates", "error", err)
+				continue
+			}
+			client.SetToken(token)
+
+			if ts.exitAfterAuth {
+				for _, t := range ts.templates {
+					r := newRunner(ts.logger.Named(filepath.Base(t.Destination)), client, t, ts.staticInterval)
+					if err := r.renderOnce(ctx); err != nil {
+						return
+					}
+				}
+				return
+			}
+
+			runCtx, cancel := context.WithCancel(ctx)
+			runCancel = cancel
+			for _, t := range ts.templates {
+				r := newRunner(ts.logger.Named(filepath.Base(t.Destination)), client, t, ts.staticInterval)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					r.run(runCtx)
+				}()
+			}
+		}
+	}
+}
+
+// dependency is a secret read while rendering a template
+type dependency struct {
+	secret *api.Secret
+
+	// used tracks whether the latest render made use of the secret
+	used bool
+
+	// cancel stops watching the secret
+	cancel context.CancelFunc
+}
+
+// runner renders a single template, and renders it again whenever one of the
+// secrets it uses changes
+type runner struct {
+	logger         hclog.Logger
+	client         *api.Client
+	config         *config.Template
+	staticInterval time.Duration
+	random         *rand.Rand
+	triggerCh      chan struct{}
+
+	l    sync.Mutex
+	deps map[string]*dependency
+
+	// contents holds the last contents written to the destination
+	contents []byte
+}
+
+func newRunner(logger hclog.Logger, client *api.Client, conf *config.Template, staticInterval time.Duration) *runner {
+	return &runner{
+		logger:         logger,
+		client:         client,
+		config:         conf,
+		staticInterval: staticInterval,
+		random:         rand.New(rand.NewSource(int64(time.Now().Nanosecond()))),
+		triggerCh:      make(chan struct{}, 1),
+		deps:           make(map[string]*dependency),
+	}
+}
+
+// trigger schedules the template to be rendered again
+func (r *runner) trigger() {
+	select {
+	case r.triggerCh <- struct{}{}:
+	default:
+	}
+}
+
+func (r *runner) backoff() time.Duration {
+	return 2*time.Second + time.Duration(r.random.Int63()%int64(time.Second*2)-int64(time.Second))
+}
+
+// run renders the template and keeps it up to date until the context is
+// canceled
+func (r *runner) run(ctx context.Context) {
+	defer r.stopWatching()
+
+	r.trigger()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-r.triggerCh:
+			if err := r.render(ctx); err != nil {
+				backoff := r.backoff()
+				r.logger.Error("error rendering template, retrying", "error", err, "backoff", backoff.String())
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+					r.trigger()
+				}
+			}
+		}
+	}
+}
+
+// renderOnce renders the template, retrying until it succeeds or the
+// context is canceled
+func (r *runner) renderOnce(ctx context.Context) error {
+	defer r.stopWatching()
+
+	for {
+		err := r.render(ctx)
+		if err == nil {
+			return nil
+		}
+
+		backoff := r.backoff()
+		r.logger.Error("error rendering template, retrying", "error", err, "backoff", backoff.String())
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// render executes the template and writes the result to the destination if
+// it changed, running the template's command afterwards
+func (r *runner) render(ctx context.Context) error {
+	source, err := ioutil.ReadFile(r.config.Source)
+	if err != nil {
+		return errwrap.Wrapf("error reading template source: {{err}}", err)
+	}
+
+	tmpl, err := template.New(filepath.Base(r.config.Source)).Funcs(r.funcMap(ctx)).Parse(string(source))
+	if err != nil {
+		return errwrap.Wrapf("error parsing template: {{err}}", err)
+	}
+
+	r.l.Lock()
+	for _, dep := range r.deps {
+		dep.used = false
+	}
+	r.l.Unlock()
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return errwrap.Wrapf("error executing template: {{err}}", err)
+	}
+
+	// Stop watching the secrets the template no longer uses
+	r.l.Lock()
+	for key, dep := range r.deps {
+		if !dep.used {
+			dep.cancel()
+			delete(r.deps, key)
+		}
+	}
+	r.l.Unlock()
+
+	contents := buf.Bytes()
+	if r.contents != nil && bytes.Equal(contents, r.contents) {
+		return nil
+	}
+
+	if err := r.write(contents); err != nil {
+		return err
+	}
+	r.contents = contents
+	r.logger.Info("rendered template", "destination", r.config.Destination)
+
+	if r.config.Command != "" {
+		if err := r.runCommand(ctx); err != nil {
+			r.logger.Error("error running template command", "command", r.config.Command, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// write atomically replaces the destination with the given contents
+func (r *runner) write(contents []byte) error {
+	dir, file := filepath.Split(r.config.Destination)
+	if dir == "" {
+		dir = "."
+	}
+
+	f, err := ioutil.TempFile(dir, fmt.Sprintf(".%s.tmp", file))
+	if err != nil {
+		return errwrap.Wrapf("error creating temp file: {{err}}", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(contents); err != nil {
+		f.Close()
+		return errwrap.Wrapf("error writing temp file: {{err}}", err)
+	}
+	if err := f.Close(); err != nil {
+		return errwrap.Wrapf("error closing temp file: {{err}}", err)
+	}
+	if err := os.Chmod(f.Name(), r.config.Perms); err != nil {
+		return errwrap.Wrapf("error setting permissions of temp file: {{err}}", err)
+	}
+
+	if err := os.Rename(f.Name(), r.config.Destination); err != nil {
+		return errwrap.Wrapf("error moving temp file into place: {{err}}", err)
+	}
+
+	return nil
+}
+
+func (r *runner) runCommand(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", r.config.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", r.config.Command)
+	}
+
+	out, err := cmd.CombinedOutput()
+	if len(out) != 0 {
+		r.logger.Debug("template command output", "command", r.config.Command, "output", string(out))
+	}
+	return err
+}
+
+func (r *runner) funcMap(ctx context.Context) template.FuncMap {
+	return template.FuncMap{
+		"secret": r.secretFunc(ctx),
+		"env":    os.Getenv,
+		"toJSON": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}
+}
+
+// secretFunc returns the "secret" template function. It reads the secret at
+// the given path or, if "key=value" arguments are given, writes them to the
+// path and returns the response. Secrets are read only once and reused until
+// they change, so that dynamic secrets are not generated on every render.
+func (r *runner) secretFunc(ctx context.Context) func(string, ...string) (*api.Secret, error) {
+	return func(path string, args ...string) (*api.Secret, error) {
+		path = strings.Trim(path, "/")
+		key := strings.Join(append([]string{path}, args...), "\x00")
+
+		r.l.Lock()
+		defer r.l.Unlock()
+
+		if dep, ok := r.deps[key]; ok {
+			dep.used = true
+			return dep.secret, nil
+		}
+
+		var secret *api.Secret
+		var err error
+		if len(args) == 0 {
+			secret, err = r.client.Logical().Read(path)
+		} else {
+			data := make(map[string]interface{}, len(args))
+			for _, arg := range args {
+				parts := strings.SplitN(arg, "=", 2)
+				if len(parts) != 2 {
+					return nil, fmt.Errorf("invalid argument %q, expected key=value", arg)
+				}
+				data[parts[0]] = parts[1]
+			}
+			secret, err = r.client.Logical().Write(path, data)
+		}
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("error reading secret at %q: {{err}}", path), err)
+		}
+		if secret == nil {
+			return nil, fmt.Errorf("no secret exists at %q", path)
+		}
+
+		dep := &dependency{
+			secret: secret,
+			used:   true,
+		}
+		r.deps[key] = dep
+		r.watch(ctx, key, dep)
+
+		return secret, nil
+	}
+}
+
+// watch starts tracking the validity of a secret. Leases are renewed for as
+// long as possible, and once that is no longer possible the secret is read
+// again. Secrets without a lease are read again periodically.
+func (r *runner) watch(ctx context.Context, key string, dep *dependency) {
+	ctx, dep.cancel = context.WithCancel(ctx)
+
+	switch {
+	case dep.secret.LeaseID != "" && dep.secret.Renewable:
+		go r.renewLease(ctx, key, dep)
+
+	case dep.secret.LeaseID != "" && dep.secret.LeaseDuration > 0:
+		// Fetch a new secret before the lease expires
+		ttl := time.Duration(dep.secret.LeaseDuration) * time.Second
+		go r.expire(ctx, key, dep, ttl*2/3)
+
+	default:
+		go r.expire(ctx, key, dep, r.staticInterval)
+	}
+}
+
+func (r *runner) renewLease(ctx context.Context, key string, dep *dependency) {
+	renewer, err := r.client.NewRenewer(&api.RenewerInput{
+		Secret: dep.secret,
+	})
+	if err != nil {
+		r.logger.Error("error creating renewer", "error", err)
+		r.invalidate(key, dep)
+		return
+	}
+	go renewer.Renew()
+	defer renewer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case err := <-renewer.DoneCh():
+			if err != nil {
+				r.logger.Debug("lease renewal halted, reading secret again", "error", err)
+			}
+			r.invalidate(key, dep)
+			return
+
+		case <-renewer.RenewCh():
+			r.logger.Debug("lease renewed", "lease_id", dep.secret.LeaseID)
+			r.trigger()
+		}
+	}
+}
+
+func (r *runner) expire(ctx context.Context, key string, dep *dependency, after time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(after):
+		r.invalidate(key, dep)
+	}
+}
+
+// invalidate discards the secret so that it is read again on the next render
+func (r *runner) invalidate(key string, dep *dependency) {
+	r.l.Lock()
+	if r.deps[key] == dep {
+		dep.cancel()
+		delete(r.deps, key)
+	}
+	r.l.Unlock()
+
+	r.trigger()
+}
+
+// stopWatching stops tracking all secrets
+func (r *runner) stopWatching() {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	for key, dep := range r.deps {
+		dep.cancel()
+		delete(r.deps, key)
+	}
+}
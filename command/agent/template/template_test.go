@@ -0,0 +1,192 @@
+package template
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/command/agent/config"
+	"github.com/hashicorp/vault/helper/logging"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/vault"
+)
+
+func testWaitForContents(t *testing.T, path, expected string) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		contents, err := ioutil.ReadFile(path)
+		if err == nil && string(contents) == expected {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %q to contain %q, last contents: %q, error: %v", path, expected, string(contents), err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestServer_Render(t *testing.T) {
+	logger := logging.NewVaultLogger(log.Trace)
+	cluster := vault.NewTestCluster(t, &vault.CoreConfig{
+		DisableMlock: true,
+		DisableCache: true,
+		Logger:       log.NewNullLogger(),
+	}, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	vault.TestWaitActive(t, cluster.Cores[0].Core)
+	client := cluster.Cores[0].Client
+
+	if _, err := client.Logical().Write("secret/foo", map[string]interface{}{
+		"value": "bar",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "agent-template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "template.ctmpl")
+	if err := ioutil.WriteFile(source, []byte(`{{ with secret "secret/foo" }}value={{ .Data.value }}{{ end }}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	destination := filepath.Join(dir, "rendered.conf")
+	commandOutput := filepath.Join(dir, "command-ran")
+
+	ts := NewServer(&ServerConfig{
+		Logger: logger.Named("template.server"),
+		Client: client,
+		Templates: []*config.Template{
+			&config.Template{
+				Source:      source,
+				Destination: destination,
+				Perms:       0600,
+				Command:     "touch " + commandOutput,
+			},
+		},
+		StaticSecretRenderInterval: 500 * time.Millisecond,
+	})
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	go ts.Run(ctx)
+	defer func() {
+		cancelFunc()
+		<-ts.DoneCh
+	}()
+
+	if err := ts.WriteToken(client.Token()); err != nil {
+		t.Fatal(err)
+	}
+
+	testWaitForContents(t, destination, "value=bar")
+
+	fi, err := os.Stat(destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("bad: permissions: %v", fi.Mode().Perm())
+	}
+
+	// The command runs after the template is written
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(commandOutput); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected command to run after rendering")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// Changes to the secret are picked up
+	if _, err := client.Logical().Write("secret/foo", map[string]interface{}{
+		"value": "baz",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	testWaitForContents(t, destination, "value=baz")
+}
+
+func TestServer_ExitAfterAuth(t *testing.T) {
+	logger := logging.NewVaultLogger(log.Trace)
+	cluster := vault.NewTestCluster(t, &vault.CoreConfig{
+		DisableMlock: true,
+		DisableCache: true,
+		Logger:       log.NewNullLogger(),
+	}, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	vault.TestWaitActive(t, cluster.Cores[0].Core)
+	client := cluster.Cores[0].Client
+
+	if _, err := client.Logical().Write("secret/foo", map[string]interface{}{
+		"value": "bar",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "agent-template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "template.ctmpl")
+	if err := ioutil.WriteFile(source, []byte(`{{ (secret "secret/foo").Data | toJSON }}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	destination := filepath.Join(dir, "rendered.json")
+
+	ts := NewServer(&ServerConfig{
+		Logger: logger.Named("template.server"),
+		Client: client,
+		Templates: []*config.Template{
+			&config.Template{
+				Source:      source,
+				Destination: destination,
+				Perms:       0644,
+			},
+		},
+		ExitAfterAuth: true,
+	})
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+	go ts.Run(ctx)
+
+	if err := ts.WriteToken(client.Token()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-ts.DoneCh:
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected template server to exit after rendering")
+	}
+
+	contents, err := ioutil.ReadFile(destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != `{"value":"bar"}` {
+		t.Fatalf("bad: contents: %q", string(contents))
+	}
+}
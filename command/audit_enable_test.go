@@ -187,6 +187,8 @@ func TestAuditEnableCommand_Run(t *testing.T) {
 			switch b {
 			case "file":
 				args = append(args, "file_path=discard")
+			case "http":
+				args = append(args, "url=http://127.0.0.1:8888")
 			case "socket":
 				args = append(args, "address=127.0.0.1:8888")
 			}
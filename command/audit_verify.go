@@ -0,0 +1,263 @@
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var _ cli.Command = (*AuditVerifyCommand)(nil)
+var _ cli.CommandAutocomplete = (*AuditVerifyCommand)(nil)
+
+type AuditVerifyCommand struct {
+	*BaseCommand
+
+	flagDevice  string
+	flagRotated bool
+}
+
+func (c *AuditVerifyCommand) Synopsis() string {
+	return "Verifies the hash chain of an audit log"
+}
+
+func (c *AuditVerifyCommand) Help() string {
+	helpText := `
+Usage: vault audit verify [options] PATH...
+
+  Verifies the hash chain of a log written by a file audit device with
+  hash_chain enabled. Each entry of the log is checked against the entry
+  before it, and each checkpoint against its signature, using the salt of the
+  audit device. The command reports the first broken link.
+
+  When a single PATH is given, the rotated files of the log, such as
+  PATH.1 or PATH-20180101, are verified with it, oldest first. Rotated
+  files ending in .gz are decompressed.
+
+  Verify the log of the audit device enabled at "file/":
+
+      $ vault audit verify /var/log/vault/audit.log
+
+  Verify the log of the audit device enabled at "secure/":
+
+      $ vault audit verify -device=secure /var/log/vault/secure.log
+
+  Each entry is hashed by the Vault server, so verifying large logs may take
+  a while.
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *AuditVerifyCommand) Flags() *FlagSets {
+	set := c.flagSet(FlagSetHTTP)
+
+	f := set.NewFlagSet("Command Options")
+
+	f.StringVar(&StringVar{
+		Name:       "device",
+		Target:     &c.flagDevice,
+		Default:    "file",
+		EnvVar:     "",
+		Completion: c.PredictVaultAudits(),
+		Usage:      "Path of the audit device that wrote the log.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:    "rotated",
+		Target:  &c.flagRotated,
+		Default: true,
+		EnvVar:  "",
+		Usage: "Include the rotated files of the log when a single path is " +
+			"given.",
+	})
+
+	return set
+}
+
+func (c *AuditVerifyCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictFiles("*")
+}
+
+func (c *AuditVerifyCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *AuditVerifyCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	paths := f.Args()
+	if len(paths) < 1 {
+		c.UI.Error(fmt.Sprintf("Not enough arguments (expected at least 1, got %d)", len(paths)))
+		return 1
+	}
+
+	if len(paths) == 1 && c.flagRotated {
+		rotated, err := rotatedAuditLogs(paths[0])
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error finding rotated audit logs: %s", err))
+			return 1
+		}
+		paths = rotated
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+
+	device := sanitizePath(c.flagDevice)
+	v := &audit.ChainVerifier{
+		HashFunc: func(data string) (string, error) {
+			return client.Sys().AuditHash(device, data)
+		},
+	}
+
+	for _, path := range paths {
+		if err := verifyAuditLog(v, path); err != nil {
+			c.UI.Error(err.Error())
+			return 2
+		}
+	}
+
+	if v.Entries == 0 {
+		c.UI.Error("No chained entries found in the audit log")
+		return 2
+	}
+
+	c.UI.Output(fmt.Sprintf("Success! Verified %d entries and %d checkpoints in %d files",
+		v.Entries, v.Checkpoints, len(paths)))
+	if v.Unchained > 0 {
+		c.UI.Warn(fmt.Sprintf("The first %d entries of the log are not chained and "+
+			"could not be verified", v.Unchained))
+	}
+
+	return 0
+}
+
+// verifyAuditLog feeds the lines of an audit log file to a verifier
+func verifyAuditLog(v *audit.ChainVerifier, path string) error {
+	r, closer, err := openAuditLog(path)
+	if err != nil {
+		return fmt.Errorf("Error opening audit log: %s", err)
+	}
+	defer closer.Close()
+
+	br := bufio.NewReader(r)
+	for lineNum := 1; ; lineNum++ {
+		line, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("Error reading %s: %s", path, err)
+		}
+
+		line = bytes.TrimSuffix(line, []byte("\n"))
+		if len(line) > 0 {
+			if verr := v.Verify(line); verr != nil {
+				return fmt.Errorf("Broken link at %s:%d: %s", path, lineNum, verr)
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// openAuditLog opens an audit log file, decompressing it if it ends in .gz
+func openAuditLog(path string) (io.Reader, io.Closer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return f, f, nil
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return gz, f, nil
+}
+
+// rotatedAuditLogs returns an audit log file along with its rotated files,
+// ordered by the time of their first entry
+func rotatedAuditLogs(path string) ([]string, error) {
+	paths := []string{path}
+	for _, pattern := range []string{path + ".*", path + "-*"} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+
+	times := make(map[string]time.Time, len(paths))
+	for _, p := range paths {
+		t, err := firstAuditLogTime(p)
+		if err != nil {
+			return nil, err
+		}
+		times[p] = t
+	}
+
+	// Files without entries are left at the end
+	sort.SliceStable(paths, func(i, j int) bool {
+		ti, tj := times[paths[i]], times[paths[j]]
+		switch {
+		case ti.IsZero():
+			return false
+		case tj.IsZero():
+			return true
+		default:
+			return ti.Before(tj)
+		}
+	})
+	return paths, nil
+}
+
+// firstAuditLogTime returns the time of the first entry of an audit log file,
+// or the zero time if it has none
+func firstAuditLogTime(path string) (time.Time, error) {
+	r, closer, err := openAuditLog(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer closer.Close()
+
+	line, err := bufio.NewReader(r).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return time.Time{}, err
+	}
+	start := bytes.IndexByte(line, '{')
+	if start < 0 {
+		return time.Time{}, nil
+	}
+
+	var entry struct {
+		Time time.Time `json:"time"`
+	}
+	if err := json.Unmarshal(line[start:], &entry); err != nil {
+		return time.Time{}, nil
+	}
+	return entry.Time, nil
+}
@@ -0,0 +1,130 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/mitchellh/cli"
+)
+
+func testAuditVerifyCommand(tb testing.TB) (*cli.MockUi, *AuditVerifyCommand) {
+	tb.Helper()
+
+	ui := cli.NewMockUi()
+	return ui, &AuditVerifyCommand{
+		BaseCommand: &BaseCommand{
+			UI: ui,
+		},
+	}
+}
+
+func TestAuditVerifyCommand_Run(t *testing.T) {
+	t.Parallel()
+
+	t.Run("not_enough_args", func(t *testing.T) {
+		t.Parallel()
+
+		ui, cmd := testAuditVerifyCommand(t)
+
+		code := cmd.Run(nil)
+		if exp := 1; code != exp {
+			t.Errorf("expected %d to be %d", code, exp)
+		}
+
+		expected := "Not enough arguments"
+		combined := ui.OutputWriter.String() + ui.ErrorWriter.String()
+		if !strings.Contains(combined, expected) {
+			t.Errorf("expected %q to contain %q", combined, expected)
+		}
+	})
+
+	t.Run("integration", func(t *testing.T) {
+		t.Parallel()
+
+		dir, err := ioutil.TempDir("", "vault-test_audit_verify")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "audit.log")
+
+		client, closer := testVaultServer(t)
+		defer closer()
+
+		if err := client.Sys().EnableAuditWithOptions("chained", &api.EnableAuditOptions{
+			Type: "file",
+			Options: map[string]string{
+				"file_path":  path,
+				"hash_chain": "true",
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 2; i++ {
+			if _, err := client.Sys().ListMounts(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Split the log as if it had been rotated. The log is copied, as
+		// verifying it writes more audit entries.
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.SplitAfter(strings.TrimSpace(string(data)), "\n")
+		verifyPath := filepath.Join(dir, "verify.log")
+		writeLog := func(path string, lines []string) {
+			if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "")+"\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		writeLog(verifyPath+".1", lines[:2])
+		writeLog(verifyPath, lines[2:])
+
+		ui, cmd := testAuditVerifyCommand(t)
+		cmd.client = client
+
+		code := cmd.Run([]string{"-device", "chained", verifyPath})
+		if exp := 0; code != exp {
+			t.Fatalf("expected %d to be %d: %s", code, exp, ui.ErrorWriter.String())
+		}
+
+		expected := fmt.Sprintf("Success! Verified %d entries and 0 checkpoints in 2 files", len(lines))
+		combined := ui.OutputWriter.String() + ui.ErrorWriter.String()
+		if !strings.Contains(combined, expected) {
+			t.Errorf("expected %q to contain %q", combined, expected)
+		}
+
+		// Editing an entry breaks the link from the entry after it
+		lines[2] = strings.Replace(lines[2], "sys/mounts", "sys/policy", 1)
+		writeLog(verifyPath, lines[2:])
+
+		ui, cmd = testAuditVerifyCommand(t)
+		cmd.client = client
+
+		code = cmd.Run([]string{"-device", "chained", verifyPath})
+		if exp := 2; code != exp {
+			t.Errorf("expected %d to be %d", code, exp)
+		}
+
+		expected = "Broken link at " + verifyPath + ":2"
+		combined = ui.OutputWriter.String() + ui.ErrorWriter.String()
+		if !strings.Contains(combined, expected) {
+			t.Errorf("expected %q to contain %q", combined, expected)
+		}
+	})
+
+	t.Run("no_tabs", func(t *testing.T) {
+		t.Parallel()
+
+		_, cmd := testAuditVerifyCommand(t)
+		assertNoTabs(t, cmd)
+	})
+}
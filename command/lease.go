@@ -20,8 +20,12 @@ func (c *LeaseCommand) Help() string {
 	helpText := `
 Usage: vault lease <subcommand> [options] [args]
 
-  This command groups subcommands for interacting with leases. Users can revoke
-  or renew leases.
+  This command groups subcommands for interacting with leases. Users can list,
+  count, revoke or renew leases.
+
+  List the leases of a database role:
+
+      $ vault lease list -mount=database -role=readonly
 
   Renew a lease:
 
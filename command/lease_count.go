@@ -0,0 +1,115 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var _ cli.Command = (*LeaseCountCommand)(nil)
+var _ cli.CommandAutocomplete = (*LeaseCountCommand)(nil)
+
+type LeaseCountCommand struct {
+	*BaseCommand
+
+	flagMount         string
+	flagRole          string
+	flagTokenAccessor string
+}
+
+func (c *LeaseCountCommand) Synopsis() string {
+	return "Counts leases by mount"
+}
+
+func (c *LeaseCountCommand) Help() string {
+	helpText := `
+Usage: vault lease count [options] [PREFIX]
+
+  Counts the leases matching all the given filters for each mount. When
+  PREFIX is given, only the leases whose ID starts with it are counted.
+
+  Count all the leases:
+
+      $ vault lease count
+
+  Count the leases of a database role:
+
+      $ vault lease count -mount=database -role=readonly
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *LeaseCountCommand) Flags() *FlagSets {
+	set := c.flagSet(FlagSetHTTP | FlagSetOutputFormat)
+	leaseFilterFlags(set.NewFlagSet("Command Options"), &c.flagMount, &c.flagRole, &c.flagTokenAccessor)
+	return set
+}
+
+func (c *LeaseCountCommand) AutocompleteArgs() complete.Predictor {
+	return c.PredictVaultFiles()
+}
+
+func (c *LeaseCountCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *LeaseCountCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	args = f.Args()
+	if len(args) > 1 {
+		c.UI.Error(fmt.Sprintf("Too many arguments (expected 0 or 1, got %d)", len(args)))
+		return 1
+	}
+
+	filter := &api.LeaseFilter{
+		Mount:         c.flagMount,
+		Role:          c.flagRole,
+		TokenAccessor: c.flagTokenAccessor,
+	}
+	if len(args) == 1 {
+		filter.Prefix = strings.TrimSpace(args[0])
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+
+	counts, err := client.Sys().CountLeases(filter)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error counting leases: %s", err))
+		return 2
+	}
+
+	switch Format(c.UI) {
+	case "table":
+		mounts := make([]string, 0, len(counts.Counts))
+		for mount := range counts.Counts {
+			mounts = append(mounts, mount)
+		}
+		sort.Strings(mounts)
+
+		columns := []string{"Mount | Leases"}
+		for _, mount := range mounts {
+			columns = append(columns, fmt.Sprintf("%s | %d", mount, counts.Counts[mount]))
+		}
+		columns = append(columns, fmt.Sprintf("Total | %d", counts.Total))
+		c.UI.Output(tableOutput(columns, nil))
+		return 0
+	default:
+		return OutputData(c.UI, counts)
+	}
+}
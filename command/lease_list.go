@@ -0,0 +1,158 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var _ cli.Command = (*LeaseListCommand)(nil)
+var _ cli.CommandAutocomplete = (*LeaseListCommand)(nil)
+
+type LeaseListCommand struct {
+	*BaseCommand
+
+	flagMount         string
+	flagRole          string
+	flagTokenAccessor string
+}
+
+func (c *LeaseListCommand) Synopsis() string {
+	return "Lists leases"
+}
+
+func (c *LeaseListCommand) Help() string {
+	helpText := `
+Usage: vault lease list [options] [PREFIX]
+
+  Lists the leases matching all the given filters, along with their issue and
+  expiration times, the accessor of the token owning them, and the mount and
+  role that issued them. When PREFIX is given, only the leases whose ID starts
+  with it are listed.
+
+  List the leases of a database role:
+
+      $ vault lease list -mount=database -role=readonly
+
+  List the leases owned by a token:
+
+      $ vault lease list -token-accessor=8609694a...
+
+  List the leases under a path:
+
+      $ vault lease list aws/creds/
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *LeaseListCommand) Flags() *FlagSets {
+	set := c.flagSet(FlagSetHTTP | FlagSetOutputFormat)
+	leaseFilterFlags(set.NewFlagSet("Command Options"), &c.flagMount, &c.flagRole, &c.flagTokenAccessor)
+	return set
+}
+
+// leaseFilterFlags adds the flags filtering leases to a flag set
+func leaseFilterFlags(f *FlagSet, mount, role, tokenAccessor *string) {
+	f.StringVar(&StringVar{
+		Name:    "mount",
+		Target:  mount,
+		Default: "",
+		EnvVar:  "",
+		Usage:   "Only match the leases issued by the mount at this path.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "role",
+		Target:  role,
+		Default: "",
+		EnvVar:  "",
+		Usage: "Only match the leases issued for this role, such as the role " +
+			"of a database/creds/:role lease.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "token-accessor",
+		Target:  tokenAccessor,
+		Default: "",
+		EnvVar:  "",
+		Usage:   "Only match the leases owned by the token with this accessor.",
+	})
+}
+
+func (c *LeaseListCommand) AutocompleteArgs() complete.Predictor {
+	return c.PredictVaultFiles()
+}
+
+func (c *LeaseListCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *LeaseListCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	args = f.Args()
+	if len(args) > 1 {
+		c.UI.Error(fmt.Sprintf("Too many arguments (expected 0 or 1, got %d)", len(args)))
+		return 1
+	}
+
+	filter := &api.LeaseFilter{
+		Mount:         c.flagMount,
+		Role:          c.flagRole,
+		TokenAccessor: c.flagTokenAccessor,
+	}
+	if len(args) == 1 {
+		filter.Prefix = strings.TrimSpace(args[0])
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+
+	leases, err := client.Sys().ExportLeases(filter)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error listing leases: %s", err))
+		return 2
+	}
+
+	switch Format(c.UI) {
+	case "table":
+		if len(leases) == 0 {
+			c.UI.Error("No leases found")
+			return 2
+		}
+
+		columns := []string{"Lease ID | Issue Time | Expire Time | Token Accessor | Mount | Role"}
+		for _, lease := range leases {
+			expireTime := "n/a"
+			if !lease.ExpireTime.IsZero() {
+				expireTime = lease.ExpireTime.Format(time.RFC3339)
+			}
+			columns = append(columns, fmt.Sprintf("%s | %s | %s | %s | %s | %s",
+				lease.ID,
+				lease.IssueTime.Format(time.RFC3339),
+				expireTime,
+				lease.TokenAccessor,
+				lease.Mount,
+				lease.Role,
+			))
+		}
+		c.UI.Output(tableOutput(columns, nil))
+		return 0
+	default:
+		return OutputData(c.UI, leases)
+	}
+}
@@ -0,0 +1,217 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/mitchellh/cli"
+)
+
+func testLeaseListCommand(tb testing.TB) (*cli.MockUi, *LeaseListCommand) {
+	tb.Helper()
+
+	ui := cli.NewMockUi()
+	return ui, &LeaseListCommand{
+		BaseCommand: &BaseCommand{
+			UI: ui,
+		},
+	}
+}
+
+func testLeaseCountCommand(tb testing.TB) (*cli.MockUi, *LeaseCountCommand) {
+	tb.Helper()
+
+	ui := cli.NewMockUi()
+	return ui, &LeaseCountCommand{
+		BaseCommand: &BaseCommand{
+			UI: ui,
+		},
+	}
+}
+
+// testLeasedSecrets creates a lease for each of the given paths of a
+// generic-leased mount at "secret-leased/"
+func testLeasedSecrets(tb testing.TB, client *api.Client, paths ...string) []string {
+	tb.Helper()
+
+	if err := client.Sys().Mount("secret-leased", &api.MountInput{
+		Type: "generic-leased",
+	}); err != nil {
+		tb.Fatal(err)
+	}
+
+	var leaseIDs []string
+	for _, path := range paths {
+		path = "secret-leased/" + path
+		data := map[string]interface{}{
+			"key":   "value",
+			"lease": "1m",
+		}
+		if _, err := client.Logical().Write(path, data); err != nil {
+			tb.Fatal(err)
+		}
+		secret, err := client.Logical().Read(path)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		leaseIDs = append(leaseIDs, secret.LeaseID)
+	}
+	return leaseIDs
+}
+
+func TestLeaseListCommand_Run(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		args []string
+		out  string
+		code int
+	}{
+		{
+			"too_many_args",
+			[]string{"foo", "bar"},
+			"Too many arguments",
+			1,
+		},
+		{
+			"role",
+			[]string{"-role", "a"},
+			"secret-leased/creds/a/",
+			0,
+		},
+		{
+			"mount",
+			[]string{"-mount", "secret-leased"},
+			"secret-leased/creds/b/",
+			0,
+		},
+		{
+			"prefix",
+			[]string{"secret-leased/creds/b"},
+			"secret-leased/creds/b/",
+			0,
+		},
+		{
+			"no_match",
+			[]string{"-role", "c"},
+			"No leases found",
+			2,
+		},
+	}
+
+	t.Run("validations", func(t *testing.T) {
+		t.Parallel()
+
+		for _, tc := range cases {
+			tc := tc
+
+			t.Run(tc.name, func(t *testing.T) {
+				t.Parallel()
+
+				client, closer := testVaultServer(t)
+				defer closer()
+
+				testLeasedSecrets(t, client, "creds/a", "creds/b")
+
+				ui, cmd := testLeaseListCommand(t)
+				cmd.client = client
+
+				code := cmd.Run(tc.args)
+				if code != tc.code {
+					t.Errorf("expected %d to be %d", code, tc.code)
+				}
+
+				combined := ui.OutputWriter.String() + ui.ErrorWriter.String()
+				if !strings.Contains(combined, tc.out) {
+					t.Errorf("expected %q to contain %q", combined, tc.out)
+				}
+			})
+		}
+	})
+
+	t.Run("communication_failure", func(t *testing.T) {
+		t.Parallel()
+
+		client, closer := testVaultServerBad(t)
+		defer closer()
+
+		ui, cmd := testLeaseListCommand(t)
+		cmd.client = client
+
+		code := cmd.Run([]string{})
+		if exp := 2; code != exp {
+			t.Errorf("expected %d to be %d", code, exp)
+		}
+
+		expected := "Error listing leases: "
+		combined := ui.OutputWriter.String() + ui.ErrorWriter.String()
+		if !strings.Contains(combined, expected) {
+			t.Errorf("expected %q to contain %q", combined, expected)
+		}
+	})
+
+	t.Run("no_tabs", func(t *testing.T) {
+		t.Parallel()
+
+		_, cmd := testLeaseListCommand(t)
+		assertNoTabs(t, cmd)
+	})
+}
+
+func TestLeaseCountCommand_Run(t *testing.T) {
+	t.Parallel()
+
+	t.Run("default", func(t *testing.T) {
+		t.Parallel()
+
+		client, closer := testVaultServer(t)
+		defer closer()
+
+		testLeasedSecrets(t, client, "creds/a", "creds/a", "creds/b")
+
+		ui, cmd := testLeaseCountCommand(t)
+		cmd.client = client
+
+		code := cmd.Run([]string{"-role", "a"})
+		if exp := 0; code != exp {
+			t.Errorf("expected %d to be %d", code, exp)
+		}
+
+		combined := ui.OutputWriter.String() + ui.ErrorWriter.String()
+		for _, expected := range []string{"secret-leased/    2", "Total             2"} {
+			if !strings.Contains(combined, expected) {
+				t.Errorf("expected %q to contain %q", combined, expected)
+			}
+		}
+	})
+
+	t.Run("communication_failure", func(t *testing.T) {
+		t.Parallel()
+
+		client, closer := testVaultServerBad(t)
+		defer closer()
+
+		ui, cmd := testLeaseCountCommand(t)
+		cmd.client = client
+
+		code := cmd.Run([]string{})
+		if exp := 2; code != exp {
+			t.Errorf("expected %d to be %d", code, exp)
+		}
+
+		expected := "Error counting leases: "
+		combined := ui.OutputWriter.String() + ui.ErrorWriter.String()
+		if !strings.Contains(combined, expected) {
+			t.Errorf("expected %q to contain %q", combined, expected)
+		}
+	})
+
+	t.Run("no_tabs", func(t *testing.T) {
+		t.Parallel()
+
+		_, cmd := testLeaseCountCommand(t)
+		assertNoTabs(t, cmd)
+	})
+}
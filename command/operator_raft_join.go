@@ -0,0 +1,148 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var _ cli.Command = (*OperatorRaftJoinCommand)(nil)
+var _ cli.CommandAutocomplete = (*OperatorRaftJoinCommand)(nil)
+
+type OperatorRaftJoinCommand struct {
+	flagLeaderCACert     string
+	flagLeaderClientCert string
+	flagLeaderClientKey  string
+	*BaseCommand
+}
+
+func (c *OperatorRaftJoinCommand) Synopsis() string {
+	return "Joins a node to the raft cluster"
+}
+
+func (c *OperatorRaftJoinCommand) Help() string {
+	helpText := `
+Usage: vault operator raft join [options] <leader-api-addr>
+
+  Join the current node as a peer to the raft cluster by providing the address
+  of the raft leader node. The node must not be initialized. If the cluster
+  uses Shamir seals, the join completes once the node is unsealed.
+
+      $ vault operator raft join "http://127.0.0.2:8200"
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorRaftJoinCommand) Flags() *FlagSets {
+	set := c.flagSet(FlagSetHTTP | FlagSetOutputFormat)
+
+	f := set.NewFlagSet("Command Options")
+
+	f.StringVar(&StringVar{
+		Name:       "leader-ca-cert",
+		Target:     &c.flagLeaderCACert,
+		Completion: complete.PredictFiles("*"),
+		Usage:      "Path to the CA certificate used to verify the leader's API address.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "leader-client-cert",
+		Target:     &c.flagLeaderClientCert,
+		Completion: complete.PredictFiles("*"),
+		Usage:      "Path to the client certificate presented to the leader.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "leader-client-key",
+		Target:     &c.flagLeaderClientKey,
+		Completion: complete.PredictFiles("*"),
+		Usage:      "Path to the key of the client certificate presented to the leader.",
+	})
+
+	return set
+}
+
+func (c *OperatorRaftJoinCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictAnything
+}
+
+func (c *OperatorRaftJoinCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *OperatorRaftJoinCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	args = f.Args()
+	switch len(args) {
+	case 1:
+	default:
+		c.UI.Error(fmt.Sprintf("Incorrect arguments (expected 1, got %d)", len(args)))
+		return 1
+	}
+
+	leaderAPIAddr := strings.TrimSpace(args[0])
+	if len(leaderAPIAddr) == 0 {
+		c.UI.Error("leader api address is required")
+		return 1
+	}
+
+	req := &api.RaftJoinRequest{
+		LeaderAPIAddr: leaderAPIAddr,
+	}
+	for _, file := range []struct {
+		path   string
+		target *string
+	}{
+		{c.flagLeaderCACert, &req.LeaderCACert},
+		{c.flagLeaderClientCert, &req.LeaderClientCert},
+		{c.flagLeaderClientKey, &req.LeaderClientKey},
+	} {
+		if file.path == "" {
+			continue
+		}
+		contents, err := ioutil.ReadFile(file.path)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error reading %q: %s", file.path, err))
+			return 1
+		}
+		*file.target = string(contents)
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+
+	resp, err := client.Sys().RaftJoin(req)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error joining the node to the raft cluster: %s", err))
+		return 2
+	}
+
+	switch Format(c.UI) {
+	case "table":
+	default:
+		return OutputData(c.UI, resp)
+	}
+
+	out := []string{
+		"Key | Value",
+		fmt.Sprintf("Joined | %t", resp.Joined),
+	}
+	c.UI.Output(tableOutput(out, nil))
+
+	return 0
+}
@@ -0,0 +1,91 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var _ cli.Command = (*OperatorRaftListPeersCommand)(nil)
+var _ cli.CommandAutocomplete = (*OperatorRaftListPeersCommand)(nil)
+
+type OperatorRaftListPeersCommand struct {
+	*BaseCommand
+}
+
+func (c *OperatorRaftListPeersCommand) Synopsis() string {
+	return "Returns the raft peer set"
+}
+
+func (c *OperatorRaftListPeersCommand) Help() string {
+	helpText := `
+Usage: vault operator raft list-peers
+
+  Provides the details of all the peers in the raft cluster.
+
+      $ vault operator raft list-peers
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorRaftListPeersCommand) Flags() *FlagSets {
+	return c.flagSet(FlagSetHTTP | FlagSetOutputFormat)
+}
+
+func (c *OperatorRaftListPeersCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictAnything
+}
+
+func (c *OperatorRaftListPeersCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *OperatorRaftListPeersCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	args = f.Args()
+	if len(args) > 0 {
+		c.UI.Error(fmt.Sprintf("Too many arguments (expected 0, got %d)", len(args)))
+		return 1
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+
+	config, err := client.Sys().RaftConfiguration()
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error reading the raft cluster configuration: %s", err))
+		return 2
+	}
+
+	switch Format(c.UI) {
+	case "table":
+	default:
+		return OutputData(c.UI, config)
+	}
+
+	out := []string{"Node | Address | State | Voter"}
+	for _, server := range config.Servers {
+		state := "follower"
+		if server.Leader {
+			state = "leader"
+		}
+
+		out = append(out, fmt.Sprintf("%s | %s | %s | %t", server.NodeID, server.Address, state, server.Voter))
+	}
+
+	c.UI.Output(tableOutput(out, nil))
+	return 0
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/armon/go-metrics"
 	"github.com/armon/go-metrics/circonus"
 	"github.com/armon/go-metrics/datadog"
+	metricsprometheus "github.com/armon/go-metrics/prometheus"
 	"github.com/hashicorp/errwrap"
 	log "github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-multierror"
@@ -34,6 +35,7 @@ import (
 	"github.com/hashicorp/vault/helper/gated-writer"
 	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/helper/logging"
+	"github.com/hashicorp/vault/helper/metricsutil"
 	"github.com/hashicorp/vault/helper/mlock"
 	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/parseutil"
@@ -47,6 +49,7 @@ import (
 	"github.com/mitchellh/cli"
 	testing "github.com/mitchellh/go-testing-interface"
 	"github.com/posener/complete"
+	promclient "github.com/prometheus/client_golang/prometheus"
 	"go.opencensus.io/exporter/jaeger"
 	"go.opencensus.io/exporter/prometheus"
 	"go.opencensus.io/stats/view"
@@ -505,7 +508,8 @@ func (c *ServerCommand) Run(args []string) int {
 				"in a Docker container, provide the IPC_LOCK cap to the container."))
 	}
 
-	if err := c.setupTelemetry(config); err != nil {
+	metricsHelper, err := c.setupTelemetry(config)
+	if err != nil {
 		c.UI.Error(fmt.Sprintf("Error initializing telemetry: %s", err))
 		return 1
 	}
@@ -589,6 +593,7 @@ func (c *ServerCommand) Run(args []string) int {
 		DisableMlock:              config.DisableMlock,
 		MaxLeaseTTL:               config.MaxLeaseTTL,
 		DefaultLeaseTTL:           config.DefaultLeaseTTL,
+		MaxLeaseRevokeAttempts:    config.MaxLeaseRevokeAttempts,
 		ClusterName:               config.ClusterName,
 		CacheSize:                 config.CacheSize,
 		PluginDirectory:           config.PluginDirectory,
@@ -600,6 +605,16 @@ func (c *ServerCommand) Run(args []string) int {
 		AllLoggers:                allLoggers,
 		BuiltinRegistry:           builtinplugins.Registry,
 		DisableKeyEncodingChecks:  config.DisablePrintableCheck,
+		MetricsHelper:             metricsHelper,
+		SealFactory: func(sealType string, sealConfig map[string]string) (vault.Seal, error) {
+			config := &server.Config{
+				Seal: &server.Seal{
+					Type:   sealType,
+					Config: sealConfig,
+				},
+			}
+			return serverseal.ConfigureSeal(config, &[]string{}, &map[string]string{}, c.logger.Named(sealType), vault.NewDefaultSeal())
+		},
 	}
 	if c.flagDev {
 		coreConfig.DevToken = c.flagDevRootTokenID
@@ -1707,7 +1722,8 @@ func (c *ServerCommand) detectRedirect(detect physical.RedirectDetect,
 }
 
 // setupTelemetry is used to setup the telemetry sub-systems
-func (c *ServerCommand) setupTelemetry(config *server.Config) error {
+// and returns the helper serving the in-memory metrics
+func (c *ServerCommand) setupTelemetry(config *server.Config) (*metricsutil.MetricsHelper, error) {
 	/* Setup telemetry
 	Aggregate on 10 second intervals for 1 minute. Expose the
 	metrics over stderr when there is a SIGUSR1 received.
@@ -1717,7 +1733,9 @@ func (c *ServerCommand) setupTelemetry(config *server.Config) error {
 
 	var telConfig *server.Telemetry
 	if config.Telemetry == nil {
-		telConfig = &server.Telemetry{}
+		telConfig = &server.Telemetry{
+			PrometheusRetentionTime: server.PrometheusDefaultRetentionTime,
+		}
 	} else {
 		telConfig = config.Telemetry
 	}
@@ -1730,7 +1748,7 @@ func (c *ServerCommand) setupTelemetry(config *server.Config) error {
 	if telConfig.StatsiteAddr != "" {
 		sink, err := metrics.NewStatsiteSink(telConfig.StatsiteAddr)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		fanout = append(fanout, sink)
 	}
@@ -1739,7 +1757,7 @@ func (c *ServerCommand) setupTelemetry(config *server.Config) error {
 	if telConfig.StatsdAddr != "" {
 		sink, err := metrics.NewStatsdSink(telConfig.StatsdAddr)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		fanout = append(fanout, sink)
 	}
@@ -1775,7 +1793,7 @@ func (c *ServerCommand) setupTelemetry(config *server.Config) error {
 
 		sink, err := circonus.NewCirconusSink(cfg)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		sink.Start()
 		fanout = append(fanout, sink)
@@ -1790,21 +1808,45 @@ func (c *ServerCommand) setupTelemetry(config *server.Config) error {
 
 		sink, err := datadog.NewDogStatsdSink(telConfig.DogStatsDAddr, metricsConf.HostName)
 		if err != nil {
-			return errwrap.Wrapf("failed to start DogStatsD sink: {{err}}", err)
+			return nil, errwrap.Wrapf("failed to start DogStatsD sink: {{err}}", err)
 		}
 		sink.SetTags(tags)
 		fanout = append(fanout, sink)
 	}
 
-	// Initialize the global sink
-	if len(fanout) > 0 {
-		fanout = append(fanout, inm)
-		metrics.NewGlobal(metricsConf, fanout)
-	} else {
+	// Hostnames only make sense for the sinks pushing to a shared
+	// aggregator, so they are left out of metrics that are scraped
+	if len(fanout) == 0 {
 		metricsConf.EnableHostname = false
-		metrics.NewGlobal(metricsConf, inm)
 	}
-	return nil
+
+	// Configure the Prometheus sink, served on sys/metrics
+	if telConfig.PrometheusRetentionTime != 0 {
+		sink, err := metricsprometheus.NewPrometheusSinkFrom(metricsprometheus.PrometheusOpts{
+			Expiration: telConfig.PrometheusRetentionTime,
+		})
+		if err != nil {
+			// The sink registers with the default Prometheus registry, which
+			// outlives a server started again in the same process, so reuse
+			// the sink registered first
+			are, ok := err.(promclient.AlreadyRegisteredError)
+			if !ok {
+				return nil, errwrap.Wrapf("failed to start Prometheus sink: {{err}}", err)
+			}
+			existing, ok := are.ExistingCollector.(*metricsprometheus.PrometheusSink)
+			if !ok {
+				return nil, errwrap.Wrapf("failed to start Prometheus sink: {{err}}", err)
+			}
+			sink = existing
+		}
+		fanout = append(fanout, sink)
+	}
+
+	// Initialize the global sink
+	fanout = append(fanout, inm)
+	metrics.NewGlobal(metricsConf, fanout)
+
+	return metricsutil.NewMetricsHelper(inm, telConfig.PrometheusRetentionTime != 0), nil
 }
 
 func (c *ServerCommand) Reload(lock *sync.RWMutex, reloadFuncs *map[string][]reload.ReloadFunc, configPath []string) error {
@@ -48,6 +48,8 @@ type Config struct {
 	DefaultMaxRequestDuration    time.Duration `hcl:"-"`
 	DefaultMaxRequestDurationRaw interface{}   `hcl:"default_max_request_duration"`
 
+	MaxLeaseRevokeAttempts int `hcl:"max_lease_revoke_attempts"`
+
 	ClusterName         string `hcl:"cluster_name"`
 	ClusterCipherSuites string `hcl:"cluster_cipher_suites"`
 
@@ -98,7 +100,9 @@ func DevConfig(ha, transactional bool) *Config {
 
 		EnableUI: true,
 
-		Telemetry: &Telemetry{},
+		Telemetry: &Telemetry{
+			PrometheusRetentionTime: PrometheusDefaultRetentionTime,
+		},
 	}
 
 	switch {
@@ -233,8 +237,19 @@ type Telemetry struct {
 	// DogStatsdTags are the global tags that should be sent with each packet to dogstatsd
 	// It is a list of strings, where each string looks like "my_tag_name:my_tag_value"
 	DogStatsDTags []string `hcl:"dogstatsd_tags"`
+
+	// Prometheus:
+	// PrometheusRetentionTime is the retention time for prometheus metrics served
+	// by sys/metrics. Setting it to 0 disables the prometheus format.
+	// Default: 24h
+	PrometheusRetentionTime    time.Duration `hcl:"-"`
+	PrometheusRetentionTimeRaw interface{}   `hcl:"prometheus_retention_time"`
 }
 
+// PrometheusDefaultRetentionTime is the retention time of prometheus metrics
+// when none is configured
+const PrometheusDefaultRetentionTime = 24 * time.Hour
+
 func (s *Telemetry) GoString() string {
 	return fmt.Sprintf("*%#v", *s)
 }
@@ -305,6 +320,11 @@ func (c *Config) Merge(c2 *Config) *Config {
 		result.DefaultMaxRequestDuration = c2.DefaultMaxRequestDuration
 	}
 
+	result.MaxLeaseRevokeAttempts = c.MaxLeaseRevokeAttempts
+	if c2.MaxLeaseRevokeAttempts != 0 {
+		result.MaxLeaseRevokeAttempts = c2.MaxLeaseRevokeAttempts
+	}
+
 	result.LogLevel = c.LogLevel
 	if c2.LogLevel != "" {
 		result.LogLevel = c2.LogLevel
@@ -816,5 +836,16 @@ func parseTelemetry(result *Config, list *ast.ObjectList) error {
 	if err := hcl.DecodeObject(&result.Telemetry, item.Val); err != nil {
 		return multierror.Prefix(err, "telemetry:")
 	}
+
+	if result.Telemetry.PrometheusRetentionTimeRaw != nil {
+		var err error
+		if result.Telemetry.PrometheusRetentionTime, err = parseutil.ParseDurationSecond(result.Telemetry.PrometheusRetentionTimeRaw); err != nil {
+			return multierror.Prefix(err, "telemetry:")
+		}
+		result.Telemetry.PrometheusRetentionTimeRaw = nil
+	} else {
+		result.Telemetry.PrometheusRetentionTime = PrometheusDefaultRetentionTime
+	}
+
 	return nil
 }
@@ -48,11 +48,12 @@ func TestLoadConfigFile(t *testing.T) {
 		},
 
 		Telemetry: &Telemetry{
-			StatsdAddr:      "bar",
-			StatsiteAddr:    "foo",
-			DisableHostname: false,
-			DogStatsDAddr:   "127.0.0.1:7254",
-			DogStatsDTags:   []string{"tag_1:val_1", "tag_2:val_2"},
+			StatsdAddr:              "bar",
+			StatsiteAddr:            "foo",
+			DisableHostname:         false,
+			DogStatsDAddr:           "127.0.0.1:7254",
+			DogStatsDTags:           []string{"tag_1:val_1", "tag_2:val_2"},
+			PrometheusRetentionTime: 30 * time.Second,
 		},
 
 		DisableCache:             true,
@@ -121,11 +122,12 @@ func TestLoadConfigFile_topLevel(t *testing.T) {
 		},
 
 		Telemetry: &Telemetry{
-			StatsdAddr:      "bar",
-			StatsiteAddr:    "foo",
-			DisableHostname: false,
-			DogStatsDAddr:   "127.0.0.1:7254",
-			DogStatsDTags:   []string{"tag_1:val_1", "tag_2:val_2"},
+			StatsdAddr:              "bar",
+			StatsiteAddr:            "foo",
+			DisableHostname:         false,
+			DogStatsDAddr:           "127.0.0.1:7254",
+			DogStatsDTags:           []string{"tag_1:val_1", "tag_2:val_2"},
+			PrometheusRetentionTime: PrometheusDefaultRetentionTime,
 		},
 
 		DisableCache:    true,
@@ -202,6 +204,7 @@ func TestLoadConfigFile_json(t *testing.T) {
 			CirconusCheckTags:                  "",
 			CirconusBrokerID:                   "",
 			CirconusBrokerSelectTag:            "",
+			PrometheusRetentionTime:            PrometheusDefaultRetentionTime,
 		},
 
 		MaxLeaseTTL:          10 * time.Hour,
@@ -288,6 +291,7 @@ func TestLoadConfigFile_json2(t *testing.T) {
 			CirconusCheckTags:                  "cat1:tag1,cat2:tag2",
 			CirconusBrokerID:                   "0",
 			CirconusBrokerSelectTag:            "dc:sfo",
+			PrometheusRetentionTime:            PrometheusDefaultRetentionTime,
 		},
 	}
 	if !reflect.DeepEqual(config, expected) {
@@ -328,9 +332,10 @@ func TestLoadConfigDir(t *testing.T) {
 		EnableRawEndpoint: true,
 
 		Telemetry: &Telemetry{
-			StatsiteAddr:    "qux",
-			StatsdAddr:      "baz",
-			DisableHostname: true,
+			StatsiteAddr:            "qux",
+			StatsdAddr:              "baz",
+			DisableHostname:         true,
+			PrometheusRetentionTime: PrometheusDefaultRetentionTime,
 		},
 
 		MaxLeaseTTL:     10 * time.Hour,
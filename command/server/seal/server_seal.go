@@ -34,8 +34,11 @@ func configureSeal(config *server.Config, infoKeys *[]string, info *map[string]s
 		case seal.AzureKeyVault:
 			return configureAzureKeyVaultSeal(config, infoKeys, info, logger, inseal)
 
+		case seal.Transit:
+			return configureTransitSeal(config, infoKeys, info, logger, inseal)
+
 		case seal.PKCS11:
-			return nil, fmt.Errorf("Seal type 'pkcs11' requires the Vault Enterprise HSM binary")
+			return configurePKCS11Seal(config, infoKeys, info, logger, inseal)
 
 		default:
 			return nil, fmt.Errorf("Unknown seal type %q", config.Seal.Type)
@@ -0,0 +1,32 @@
+// +build cgo
+
+package seal
+
+import (
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/command/server"
+	"github.com/hashicorp/vault/vault"
+	"github.com/hashicorp/vault/vault/seal/pkcs11"
+)
+
+func configurePKCS11Seal(config *server.Config, infoKeys *[]string, info *map[string]string, logger log.Logger, inseal vault.Seal) (vault.Seal, error) {
+	pkcs11Seal := pkcs11.NewSeal(logger)
+	sealInfo, err := pkcs11Seal.SetConfig(config.Seal.Config)
+	if err != nil {
+		return nil, err
+	}
+	autoseal := vault.NewAutoSeal(pkcs11Seal)
+	if sealInfo != nil {
+		*infoKeys = append(*infoKeys, "Seal Type", "PKCS#11 Library", "PKCS#11 Slot", "PKCS#11 Key Label", "PKCS#11 Mechanism")
+		(*info)["Seal Type"] = config.Seal.Type
+		(*info)["PKCS#11 Library"] = sealInfo["lib"]
+		(*info)["PKCS#11 Slot"] = sealInfo["slot"]
+		(*info)["PKCS#11 Key Label"] = sealInfo["key_label"]
+		(*info)["PKCS#11 Mechanism"] = sealInfo["mechanism"]
+		if tokenLabel, ok := sealInfo["token_label"]; ok {
+			*infoKeys = append(*infoKeys, "PKCS#11 Token Label")
+			(*info)["PKCS#11 Token Label"] = tokenLabel
+		}
+	}
+	return autoseal, nil
+}
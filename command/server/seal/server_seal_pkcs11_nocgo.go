@@ -0,0 +1,15 @@
+// +build !cgo
+
+package seal
+
+import (
+	"fmt"
+
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/command/server"
+	"github.com/hashicorp/vault/vault"
+)
+
+func configurePKCS11Seal(config *server.Config, infoKeys *[]string, info *map[string]string, logger log.Logger, inseal vault.Seal) (vault.Seal, error) {
+	return nil, fmt.Errorf("Seal type 'pkcs11' requires a Vault binary built with cgo enabled")
+}
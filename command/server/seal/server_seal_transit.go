@@ -0,0 +1,29 @@
+package seal
+
+import (
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/command/server"
+	"github.com/hashicorp/vault/vault"
+	"github.com/hashicorp/vault/vault/seal/transit"
+)
+
+func configureTransitSeal(config *server.Config, infoKeys *[]string, info *map[string]string, logger log.Logger, inseal vault.Seal) (vault.Seal, error) {
+	transitSeal := transit.NewSeal(logger)
+	sealInfo, err := transitSeal.SetConfig(config.Seal.Config)
+	if err != nil {
+		return nil, err
+	}
+	autoseal := vault.NewAutoSeal(transitSeal)
+	if sealInfo != nil {
+		*infoKeys = append(*infoKeys, "Seal Type", "Transit Address", "Transit Mount Path", "Transit Key Name")
+		(*info)["Seal Type"] = config.Seal.Type
+		(*info)["Transit Address"] = sealInfo["address"]
+		(*info)["Transit Mount Path"] = sealInfo["mount_path"]
+		(*info)["Transit Key Name"] = sealInfo["key_name"]
+		if namespace, ok := sealInfo["namespace"]; ok {
+			*infoKeys = append(*infoKeys, "Transit Namespace")
+			(*info)["Transit Namespace"] = namespace
+		}
+	}
+	return autoseal, nil
+}
@@ -21,10 +21,17 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+// Secret represents the MFA secret of a particular MFA method that is held by
+// an entity. Each MFA type that requires a per-entity secret adds a field for
+// it to this message.
 type Secret struct {
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	// MethodName is the name of the MFA method that generated the secret
+	MethodName string `protobuf:"bytes,1,opt,name=method_name,json=methodName,proto3" json:"method_name,omitempty"`
+	// TotpSecret is the secret generated by a TOTP MFA method
+	TotpSecret           *TOTPSecret `protobuf:"bytes,2,opt,name=totp_secret,json=totpSecret,proto3" json:"totp_secret,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
 }
 
 func (m *Secret) Reset()         { *m = Secret{} }
@@ -52,19 +59,143 @@ func (m *Secret) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_Secret proto.InternalMessageInfo
 
+func (m *Secret) GetMethodName() string {
+	if m != nil {
+		return m.MethodName
+	}
+	return ""
+}
+
+func (m *Secret) GetTotpSecret() *TOTPSecret {
+	if m != nil {
+		return m.TotpSecret
+	}
+	return nil
+}
+
+// TOTPSecret holds the key and the parameters that are used to validate the
+// TOTP passcodes supplied over the API at request time.
+type TOTPSecret struct {
+	Issuer               string   `protobuf:"bytes,1,opt,name=issuer,proto3" json:"issuer,omitempty"`
+	Period               uint32   `protobuf:"varint,2,opt,name=period,proto3" json:"period,omitempty"`
+	Algorithm            int32    `protobuf:"varint,3,opt,name=algorithm,proto3" json:"algorithm,omitempty"`
+	Digits               int32    `protobuf:"varint,4,opt,name=digits,proto3" json:"digits,omitempty"`
+	Skew                 uint32   `protobuf:"varint,5,opt,name=skew,proto3" json:"skew,omitempty"`
+	KeySize              uint32   `protobuf:"varint,6,opt,name=key_size,json=keySize,proto3" json:"key_size,omitempty"`
+	AccountName          string   `protobuf:"bytes,7,opt,name=account_name,json=accountName,proto3" json:"account_name,omitempty"`
+	Key                  string   `protobuf:"bytes,8,opt,name=key,proto3" json:"key,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TOTPSecret) Reset()         { *m = TOTPSecret{} }
+func (m *TOTPSecret) String() string { return proto.CompactTextString(m) }
+func (*TOTPSecret) ProtoMessage()    {}
+func (*TOTPSecret) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2eb73493aac0ba29, []int{1}
+}
+
+func (m *TOTPSecret) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TOTPSecret.Unmarshal(m, b)
+}
+func (m *TOTPSecret) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TOTPSecret.Marshal(b, m, deterministic)
+}
+func (m *TOTPSecret) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TOTPSecret.Merge(m, src)
+}
+func (m *TOTPSecret) XXX_Size() int {
+	return xxx_messageInfo_TOTPSecret.Size(m)
+}
+func (m *TOTPSecret) XXX_DiscardUnknown() {
+	xxx_messageInfo_TOTPSecret.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TOTPSecret proto.InternalMessageInfo
+
+func (m *TOTPSecret) GetIssuer() string {
+	if m != nil {
+		return m.Issuer
+	}
+	return ""
+}
+
+func (m *TOTPSecret) GetPeriod() uint32 {
+	if m != nil {
+		return m.Period
+	}
+	return 0
+}
+
+func (m *TOTPSecret) GetAlgorithm() int32 {
+	if m != nil {
+		return m.Algorithm
+	}
+	return 0
+}
+
+func (m *TOTPSecret) GetDigits() int32 {
+	if m != nil {
+		return m.Digits
+	}
+	return 0
+}
+
+func (m *TOTPSecret) GetSkew() uint32 {
+	if m != nil {
+		return m.Skew
+	}
+	return 0
+}
+
+func (m *TOTPSecret) GetKeySize() uint32 {
+	if m != nil {
+		return m.KeySize
+	}
+	return 0
+}
+
+func (m *TOTPSecret) GetAccountName() string {
+	if m != nil {
+		return m.AccountName
+	}
+	return ""
+}
+
+func (m *TOTPSecret) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*Secret)(nil), "mfa.Secret")
+	proto.RegisterType((*TOTPSecret)(nil), "mfa.TOTPSecret")
 }
 
 func init() { proto.RegisterFile("helper/identity/mfa/types.proto", fileDescriptor_2eb73493aac0ba29) }
 
 var fileDescriptor_2eb73493aac0ba29 = []byte{
-	// 111 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x92, 0xcf, 0x48, 0xcd, 0x29,
-	0x48, 0x2d, 0xd2, 0xcf, 0x4c, 0x49, 0xcd, 0x2b, 0xc9, 0x2c, 0xa9, 0xd4, 0xcf, 0x4d, 0x4b, 0xd4,
-	0x2f, 0xa9, 0x2c, 0x48, 0x2d, 0xd6, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0xce, 0x4d, 0x4b,
-	0x54, 0xe2, 0xe0, 0x62, 0x0b, 0x4e, 0x4d, 0x2e, 0x4a, 0x2d, 0x71, 0x32, 0x88, 0xd2, 0x4b, 0xcf,
-	0x2c, 0xc9, 0x28, 0x4d, 0xd2, 0x4b, 0xce, 0xcf, 0xd5, 0xcf, 0x48, 0x2c, 0xce, 0xc8, 0x4c, 0xce,
-	0x2f, 0x2a, 0xd0, 0x2f, 0x4b, 0x2c, 0xcd, 0x29, 0xd1, 0xc7, 0x62, 0x58, 0x12, 0x1b, 0xd8, 0x1c,
-	0x63, 0x40, 0x00, 0x00, 0x00, 0xff, 0xff, 0xa9, 0xc9, 0x73, 0x5e, 0x6a, 0x00, 0x00, 0x00,
+	// 290 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x90, 0x41, 0x4e, 0xc3, 0x30,
+	0x10, 0x45, 0x15, 0xda, 0xa6, 0xed, 0x04, 0x04, 0xf2, 0x02, 0x19, 0x09, 0xa9, 0xa5, 0xab, 0xae,
+	0x92, 0x0a, 0x6e, 0xc0, 0x01, 0x00, 0xa5, 0x5d, 0xc1, 0xa2, 0x72, 0x93, 0x69, 0x6d, 0xa5, 0xae,
+	0x2d, 0x7b, 0x02, 0x4a, 0x0f, 0xca, 0x79, 0x50, 0x9c, 0x48, 0xdd, 0xb0, 0xfb, 0xff, 0xfd, 0xf1,
+	0x97, 0x67, 0x60, 0x26, 0xf1, 0x68, 0xd1, 0x65, 0xaa, 0xc4, 0x13, 0x29, 0x6a, 0x32, 0xbd, 0x17,
+	0x19, 0x35, 0x16, 0x7d, 0x6a, 0x9d, 0x21, 0xc3, 0x06, 0x7a, 0x2f, 0x16, 0x5f, 0x10, 0xaf, 0xb1,
+	0x70, 0x48, 0x6c, 0x06, 0x89, 0x46, 0x92, 0xa6, 0xdc, 0x9e, 0x84, 0x46, 0x1e, 0xcd, 0xa3, 0xe5,
+	0x34, 0x87, 0x0e, 0xbd, 0x09, 0x8d, 0x6c, 0x05, 0x09, 0x19, 0xb2, 0x5b, 0x1f, 0xe6, 0xf9, 0xd5,
+	0x3c, 0x5a, 0x26, 0xcf, 0xb7, 0xa9, 0xde, 0x8b, 0x74, 0xf3, 0xbe, 0xf9, 0xe8, 0x6a, 0x72, 0x68,
+	0x67, 0x3a, 0xbd, 0xf8, 0x8d, 0x00, 0x2e, 0x11, 0xbb, 0x87, 0x58, 0x79, 0x5f, 0xa3, 0xeb, 0xcb,
+	0x7b, 0xd7, 0x72, 0x8b, 0x4e, 0x99, 0x32, 0x74, 0xde, 0xe4, 0xbd, 0x63, 0x8f, 0x30, 0x15, 0xc7,
+	0x83, 0x71, 0x8a, 0xa4, 0xe6, 0x83, 0x79, 0xb4, 0x1c, 0xe5, 0x17, 0xd0, 0xbe, 0x2a, 0xd5, 0x41,
+	0x91, 0xe7, 0xc3, 0x10, 0xf5, 0x8e, 0x31, 0x18, 0xfa, 0x0a, 0x7f, 0xf8, 0x28, 0x74, 0x05, 0xcd,
+	0x1e, 0x60, 0x52, 0x61, 0xb3, 0xf5, 0xea, 0x8c, 0x3c, 0x0e, 0x7c, 0x5c, 0x61, 0xb3, 0x56, 0x67,
+	0x64, 0x4f, 0x70, 0x2d, 0x8a, 0xc2, 0xd4, 0x27, 0xea, 0xf6, 0x1e, 0x87, 0xaf, 0x25, 0x3d, 0x0b,
+	0x8b, 0xdf, 0xc1, 0xa0, 0xc2, 0x86, 0x4f, 0x42, 0xd2, 0xca, 0xd7, 0xd5, 0x67, 0x7a, 0x50, 0x24,
+	0xeb, 0x5d, 0x5a, 0x18, 0x9d, 0x49, 0xe1, 0xa5, 0x2a, 0x8c, 0xb3, 0xd9, 0xb7, 0xa8, 0x8f, 0x94,
+	0xfd, 0x73, 0xf8, 0x5d, 0x1c, 0x6e, 0xfe, 0xf2, 0x37, 0x00, 0x05, 0x20, 0xe0, 0x70, 0x96, 0x01,
+	0x00, 0x00,
 }
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/errutil"
 	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/helper/locksutil"
 	"github.com/hashicorp/vault/logical"
@@ -51,6 +52,9 @@ type PolicyRequest struct {
 
 	// Whether to allow plaintext backup
 	AllowPlaintextBackup bool
+
+	// Whether to allow Vault to rotate an imported key
+	AllowImportedKeyRotation bool
 }
 
 type LockManager struct {
@@ -283,34 +287,9 @@ func (lm *LockManager) GetPolicy(ctx context.Context, req PolicyRequest) (retP *
 		// to the user to let them know that their request can't be satisfied
 		// because we don't know if the parameters match.
 
-		switch req.KeyType {
-		case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
-			if req.Convergent && !req.Derived {
-				cleanup()
-				return nil, false, fmt.Errorf("convergent encryption requires derivation to be enabled")
-			}
-
-		case KeyType_ECDSA_P256:
-			if req.Derived || req.Convergent {
-				cleanup()
-				return nil, false, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
-			}
-
-		case KeyType_ED25519:
-			if req.Convergent {
-				cleanup()
-				return nil, false, fmt.Errorf("convergent encryption not supported for keys of type %v", req.KeyType)
-			}
-
-		case KeyType_RSA2048, KeyType_RSA4096:
-			if req.Derived || req.Convergent {
-				cleanup()
-				return nil, false, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
-			}
-
-		default:
+		if err := validatePolicyRequest(req); err != nil {
 			cleanup()
-			return nil, false, fmt.Errorf("unsupported key type %v", req.KeyType)
+			return nil, false, err
 		}
 
 		p = &Policy{
@@ -375,6 +354,90 @@ func (lm *LockManager) GetPolicy(ctx context.Context, req PolicyRequest) (retP *
 	return
 }
 
+// ImportPolicy creates a new policy with the given key material as its first
+// version. It is an error for the policy to already exist.
+func (lm *LockManager) ImportPolicy(ctx context.Context, req PolicyRequest, key []byte) error {
+	if err := validatePolicyRequest(req); err != nil {
+		return errutil.UserError{Err: err.Error()}
+	}
+
+	lock := locksutil.LockForKey(lm.keyLocks, req.Name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if pRaw, ok := lm.cache.Load(req.Name); ok && atomic.LoadUint32(&pRaw.(*Policy).deleted) == 0 {
+		return errutil.UserError{Err: fmt.Sprintf("key %q already exists", req.Name)}
+	}
+
+	p, err := lm.getPolicyFromStorage(ctx, req.Storage, req.Name)
+	if err != nil {
+		return err
+	}
+	if p != nil {
+		return errutil.UserError{Err: fmt.Sprintf("key %q already exists", req.Name)}
+	}
+
+	p = &Policy{
+		l:                        new(sync.RWMutex),
+		Name:                     req.Name,
+		Type:                     req.KeyType,
+		Derived:                  req.Derived,
+		Exportable:               req.Exportable,
+		AllowPlaintextBackup:     req.AllowPlaintextBackup,
+		Imported:                 true,
+		AllowImportedKeyRotation: req.AllowImportedKeyRotation,
+	}
+
+	if req.Derived {
+		p.KDF = Kdf_hkdf_sha256
+		if req.Convergent {
+			p.ConvergentEncryption = true
+			p.ConvergentVersion = -1
+		}
+	}
+
+	if err := p.Import(ctx, req.Storage, key); err != nil {
+		return err
+	}
+
+	if lm.useCache {
+		lm.cache.Store(req.Name, p)
+	}
+
+	return nil
+}
+
+// validatePolicyRequest checks that the options of a new policy are supported
+// by its key type
+func validatePolicyRequest(req PolicyRequest) error {
+	switch req.KeyType {
+	case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
+		if req.Convergent && !req.Derived {
+			return fmt.Errorf("convergent encryption requires derivation to be enabled")
+		}
+
+	case KeyType_ECDSA_P256:
+		if req.Derived || req.Convergent {
+			return fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
+		}
+
+	case KeyType_ED25519:
+		if req.Convergent {
+			return fmt.Errorf("convergent encryption not supported for keys of type %v", req.KeyType)
+		}
+
+	case KeyType_RSA2048, KeyType_RSA4096:
+		if req.Derived || req.Convergent {
+			return fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
+		}
+
+	default:
+		return fmt.Errorf("unsupported key type %v", req.KeyType)
+	}
+
+	return nil
+}
+
 func (lm *LockManager) DeletePolicy(ctx context.Context, storage logical.Storage, name string) error {
 	var p *Policy
 	var err error
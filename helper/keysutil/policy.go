@@ -13,6 +13,7 @@ import (
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/asn1"
 	"encoding/base64"
 	"encoding/json"
@@ -353,6 +354,14 @@ type Policy struct {
 	// AllowPlaintextBackup allows taking backup of the policy in plaintext
 	AllowPlaintextBackup bool `json:"allow_plaintext_backup"`
 
+	// Imported indicates that the key material of the policy was imported
+	// rather than generated by Vault
+	Imported bool `json:"imported"`
+
+	// AllowImportedKeyRotation allows Vault to rotate an imported key. New
+	// versions are otherwise only added by importing key material.
+	AllowImportedKeyRotation bool `json:"allow_imported_key_rotation"`
+
 	// VersionTemplate is used to prefix the ciphertext with information about
 	// the key version. It must inclide {{version}} and a delimiter between the
 	// version prefix and the ciphertext.
@@ -1270,6 +1279,10 @@ func (p *Policy) VerifySignature(context, input []byte, sig, hashAlgorithm strin
 }
 
 func (p *Policy) Rotate(ctx context.Context, storage logical.Storage) (retErr error) {
+	if p.Imported && !p.AllowImportedKeyRotation {
+		return errutil.UserError{Err: "imported key does not allow rotation within Vault"}
+	}
+
 	priorLatestVersion := p.LatestVersion
 	priorMinDecryptionVersion := p.MinDecryptionVersion
 	var priorKeys keyEntryMap
@@ -1378,6 +1391,157 @@ func (p *Policy) Rotate(ctx context.Context, storage logical.Storage) (retErr er
 	return p.Persist(ctx, storage)
 }
 
+// Import adds a new version of the key using the given key material. AES and
+// ChaCha20 keys are given as raw bytes, all other key types as PKCS #8 DER.
+func (p *Policy) Import(ctx context.Context, storage logical.Storage, key []byte) (retErr error) {
+	priorLatestVersion := p.LatestVersion
+	priorMinDecryptionVersion := p.MinDecryptionVersion
+	var priorKeys keyEntryMap
+
+	if p.Keys != nil {
+		priorKeys = keyEntryMap{}
+		for k, v := range p.Keys {
+			priorKeys[k] = v
+		}
+	}
+
+	defer func() {
+		if retErr != nil {
+			p.LatestVersion = priorLatestVersion
+			p.MinDecryptionVersion = priorMinDecryptionVersion
+			p.Keys = priorKeys
+		}
+	}()
+
+	if p.Keys == nil {
+		p.Keys = keyEntryMap{}
+	}
+
+	now := time.Now()
+	entry := KeyEntry{
+		CreationTime:           now,
+		DeprecatedCreationTime: now.Unix(),
+	}
+
+	hmacKey, err := uuid.GenerateRandomBytes(32)
+	if err != nil {
+		return err
+	}
+	entry.HMACKey = hmacKey
+
+	switch p.Type {
+	case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
+		if len(key) != 32 {
+			return errutil.UserError{Err: fmt.Sprintf("invalid key size %d bytes for key type %s", len(key), p.Type)}
+		}
+		entry.Key = key
+
+	case KeyType_ECDSA_P256:
+		parsedKey, err := x509.ParsePKCS8PrivateKey(key)
+		if err != nil {
+			return errutil.UserError{Err: fmt.Sprintf("error parsing PKCS #8 private key: %v", err)}
+		}
+		privKey, ok := parsedKey.(*ecdsa.PrivateKey)
+		if !ok || privKey.Curve != elliptic.P256() {
+			return errutil.UserError{Err: fmt.Sprintf("key is not a valid private key for key type %s", p.Type)}
+		}
+		entry.EC_D = privKey.D
+		entry.EC_X = privKey.X
+		entry.EC_Y = privKey.Y
+		derBytes, err := x509.MarshalPKIXPublicKey(privKey.Public())
+		if err != nil {
+			return errwrap.Wrapf("error marshaling public key: {{err}}", err)
+		}
+		pemBlock := &pem.Block{
+			Type:  "PUBLIC KEY",
+			Bytes: derBytes,
+		}
+		pemBytes := pem.EncodeToMemory(pemBlock)
+		if pemBytes == nil || len(pemBytes) == 0 {
+			return fmt.Errorf("error PEM-encoding public key")
+		}
+		entry.FormattedPublicKey = string(pemBytes)
+
+	case KeyType_ED25519:
+		privKey, err := parsePKCS8Ed25519PrivateKey(key)
+		if err != nil {
+			return errutil.UserError{Err: fmt.Sprintf("error parsing PKCS #8 private key: %v", err)}
+		}
+		entry.Key = privKey
+		entry.FormattedPublicKey = base64.StdEncoding.EncodeToString(privKey.Public().(ed25519.PublicKey))
+
+	case KeyType_RSA2048, KeyType_RSA4096:
+		bitSize := 2048
+		if p.Type == KeyType_RSA4096 {
+			bitSize = 4096
+		}
+
+		parsedKey, err := x509.ParsePKCS8PrivateKey(key)
+		if err != nil {
+			return errutil.UserError{Err: fmt.Sprintf("error parsing PKCS #8 private key: %v", err)}
+		}
+		privKey, ok := parsedKey.(*rsa.PrivateKey)
+		if !ok || privKey.N.BitLen() != bitSize {
+			return errutil.UserError{Err: fmt.Sprintf("key is not a valid private key for key type %s", p.Type)}
+		}
+		entry.RSAKey = privKey
+
+	default:
+		return fmt.Errorf("unsupported key type %v", p.Type)
+	}
+
+	if p.ConvergentEncryption {
+		if p.ConvergentVersion == -1 || p.ConvergentVersion > 1 {
+			entry.ConvergentVersion = currentConvergentVersion
+		}
+	}
+
+	p.LatestVersion += 1
+	p.Keys[strconv.Itoa(p.LatestVersion)] = entry
+
+	if p.MinDecryptionVersion == 0 {
+		p.MinDecryptionVersion = 1
+	}
+
+	return p.Persist(ctx, storage)
+}
+
+// oidEd25519 is the algorithm identifier of Ed25519 keys, from RFC 8410
+var oidEd25519 = asn1.ObjectIdentifier{1, 3, 101, 112}
+
+// pkcs8 is the PKCS #8 private key structure, from RFC 5208 and RFC 5958
+type pkcs8 struct {
+	Version    int
+	Algo       pkix.AlgorithmIdentifier
+	PrivateKey []byte
+	Attributes asn1.RawValue `asn1:"optional,tag:0"`
+	PublicKey  asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+// parsePKCS8Ed25519PrivateKey parses an Ed25519 private key in PKCS #8 DER
+// form, as described in RFC 8410
+func parsePKCS8Ed25519PrivateKey(der []byte) (ed25519.PrivateKey, error) {
+	var privKey pkcs8
+	if rest, err := asn1.Unmarshal(der, &privKey); err != nil {
+		return nil, err
+	} else if len(rest) > 0 {
+		return nil, errors.New("trailing data after private key")
+	}
+	if !privKey.Algo.Algorithm.Equal(oidEd25519) {
+		return nil, fmt.Errorf("unexpected key algorithm %v", privKey.Algo.Algorithm)
+	}
+
+	var seed []byte
+	if _, err := asn1.Unmarshal(privKey.PrivateKey, &seed); err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid Ed25519 seed size %d", len(seed))
+	}
+
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
 func (p *Policy) MigrateKeyToKeysMap() {
 	now := time.Now()
 	p.Keys = keyEntryMap{
@@ -0,0 +1,115 @@
+package metricsutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/vault/logical"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+const (
+	PrometheusMetricFormat = "prometheus"
+	JSONMetricFormat       = "json"
+
+	// PrometheusContentType is the content type of the Prometheus text
+	// exposition format
+	PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	// ErrorContentType is the content type of error responses
+	ErrorContentType = "text/plain"
+)
+
+// MetricsHelper serves the metrics collected by the in-memory sinks set up
+// by the server
+type MetricsHelper struct {
+	inMemSink         *metrics.InmemSink
+	PrometheusEnabled bool
+}
+
+// NewMetricsHelper creates a MetricsHelper. If enablePrometheus is set, a
+// Prometheus sink is expected to be registered with the default Prometheus
+// registry.
+func NewMetricsHelper(inMem *metrics.InmemSink, enablePrometheus bool) *MetricsHelper {
+	return &MetricsHelper{
+		inMemSink:         inMem,
+		PrometheusEnabled: enablePrometheus,
+	}
+}
+
+// ResponseForFormat returns the metrics in the requested format. The
+// Prometheus format is used if no format is given.
+func (m *MetricsHelper) ResponseForFormat(format string) *logical.Response {
+	switch format {
+	case PrometheusMetricFormat, "":
+		return m.PrometheusResponse()
+	case JSONMetricFormat:
+		return m.GenericResponse()
+	default:
+		return errorResponse(http.StatusBadRequest, fmt.Sprintf("metric response format %q unknown", format))
+	}
+}
+
+// PrometheusResponse returns the metrics registered with the default
+// Prometheus registry, in the Prometheus text exposition format
+func (m *MetricsHelper) PrometheusResponse() *logical.Response {
+	if !m.PrometheusEnabled {
+		return errorResponse(http.StatusBadRequest, "prometheus is not enabled")
+	}
+
+	metricsFamilies, err := prometheus.DefaultGatherer.Gather()
+	if err != nil && len(metricsFamilies) == 0 {
+		return errorResponse(http.StatusInternalServerError, fmt.Sprintf("no prometheus metrics could be decoded: %s", err))
+	}
+
+	buf := &bytes.Buffer{}
+	for _, mf := range metricsFamilies {
+		if _, err := expfmt.MetricFamilyToText(buf, mf); err != nil {
+			return errorResponse(http.StatusInternalServerError, fmt.Sprintf("error encoding prometheus metrics: %s", err))
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: PrometheusContentType,
+			logical.HTTPRawBody:     buf.Bytes(),
+			logical.HTTPStatusCode:  http.StatusOK,
+		},
+	}
+}
+
+// GenericResponse returns a summary of the metrics of the most recent
+// finished interval of the in-memory sink, encoded as JSON
+func (m *MetricsHelper) GenericResponse() *logical.Response {
+	summary, err := m.inMemSink.DisplayMetrics(nil, nil)
+	if err != nil {
+		return errorResponse(http.StatusInternalServerError, fmt.Sprintf("error while fetching the in-memory metrics: %s", err))
+	}
+
+	content, err := json.Marshal(summary)
+	if err != nil {
+		return errorResponse(http.StatusInternalServerError, fmt.Sprintf("error while marshaling the in-memory metrics: %s", err))
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/json",
+			logical.HTTPRawBody:     content,
+			logical.HTTPStatusCode:  http.StatusOK,
+		},
+	}
+}
+
+func errorResponse(status int, msg string) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: ErrorContentType,
+			logical.HTTPRawBody:     []byte(msg),
+			logical.HTTPStatusCode:  status,
+		},
+	}
+}
@@ -0,0 +1,81 @@
+package metricsutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	metricsprometheus "github.com/armon/go-metrics/prometheus"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestMetricsHelper_Formats(t *testing.T) {
+	inm := metrics.NewInmemSink(10*time.Second, time.Minute)
+	promSink, err := metricsprometheus.NewPrometheusSinkFrom(metricsprometheus.PrometheusOpts{
+		Expiration: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := metrics.DefaultConfig("vault")
+	conf.EnableHostname = false
+	m, err := metrics.New(conf, metrics.FanoutSink{inm, promSink})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.IncrCounter([]string{"core", "test_counter"}, 1)
+
+	helper := NewMetricsHelper(inm, true)
+
+	// Prometheus is the default format
+	for _, format := range []string{"", PrometheusMetricFormat} {
+		resp := helper.ResponseForFormat(format)
+		if resp.Data[logical.HTTPStatusCode] != http.StatusOK {
+			t.Fatalf("bad: %#v", resp)
+		}
+		if resp.Data[logical.HTTPContentType] != PrometheusContentType {
+			t.Fatalf("bad: content type: %v", resp.Data[logical.HTTPContentType])
+		}
+		body := string(resp.Data[logical.HTTPRawBody].([]byte))
+		if !strings.Contains(body, "vault_core_test_counter") {
+			t.Fatalf("expected counter in prometheus output, got: %s", body)
+		}
+	}
+
+	resp := helper.ResponseForFormat(JSONMetricFormat)
+	if resp.Data[logical.HTTPStatusCode] != http.StatusOK {
+		t.Fatalf("bad: %#v", resp)
+	}
+	var summary metrics.MetricsSummary
+	if err := json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Counters) != 1 || summary.Counters[0].Name != "vault.core.test_counter" {
+		t.Fatalf("bad: counters: %#v", summary.Counters)
+	}
+
+	resp = helper.ResponseForFormat("xml")
+	if resp.Data[logical.HTTPStatusCode] != http.StatusBadRequest {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
+
+func TestMetricsHelper_PrometheusDisabled(t *testing.T) {
+	inm := metrics.NewInmemSink(10*time.Second, time.Minute)
+	helper := NewMetricsHelper(inm, false)
+
+	resp := helper.ResponseForFormat(PrometheusMetricFormat)
+	if resp.Data[logical.HTTPStatusCode] != http.StatusBadRequest {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// The in-memory metrics are still available
+	resp = helper.ResponseForFormat(JSONMetricFormat)
+	if resp.Data[logical.HTTPStatusCode] != http.StatusOK {
+		t.Fatalf("bad: %#v", resp)
+	}
+}
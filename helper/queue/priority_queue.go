@@ -0,0 +1,148 @@
+// Package queue provides a priority queue of keyed items, ordered by their
+// priority with the lowest value first. Items can be removed by key.
+package queue
+
+import (
+	"container/heap"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrEmpty is returned when popping from an empty queue
+	ErrEmpty = errors.New("queue is empty")
+
+	// ErrDuplicateItem is returned when pushing an item whose key is already
+	// in the queue
+	ErrDuplicateItem = errors.New("duplicate item")
+)
+
+// Item is an entry of the queue
+type Item struct {
+	// Key is the unique identifier of the item in the queue
+	Key string
+
+	// Value is the data held by the item
+	Value interface{}
+
+	// Priority orders the items in the queue. Items with a lower priority are
+	// popped first. It is typically a Unix timestamp.
+	Priority int64
+
+	// index is maintained by the heap interface
+	index int
+}
+
+// PriorityQueue is a priority queue of items that is safe for concurrent use
+type PriorityQueue struct {
+	data     queue
+	dataMap  map[string]*Item
+	dataLock sync.RWMutex
+}
+
+// New returns an empty priority queue
+func New() *PriorityQueue {
+	return &PriorityQueue{
+		data:    make(queue, 0),
+		dataMap: make(map[string]*Item),
+	}
+}
+
+// Len returns the number of items in the queue
+func (pq *PriorityQueue) Len() int {
+	pq.dataLock.RLock()
+	defer pq.dataLock.RUnlock()
+	return pq.data.Len()
+}
+
+// Push adds an item to the queue. Pushing an item with a key that is already
+// in the queue returns ErrDuplicateItem.
+func (pq *PriorityQueue) Push(i *Item) error {
+	if i == nil || i.Key == "" {
+		return errors.New("error adding item: item key is required")
+	}
+
+	pq.dataLock.Lock()
+	defer pq.dataLock.Unlock()
+
+	if _, ok := pq.dataMap[i.Key]; ok {
+		return ErrDuplicateItem
+	}
+
+	heap.Push(&pq.data, i)
+	pq.dataMap[i.Key] = i
+	return nil
+}
+
+// Pop removes and returns the item with the lowest priority
+func (pq *PriorityQueue) Pop() (*Item, error) {
+	pq.dataLock.Lock()
+	defer pq.dataLock.Unlock()
+
+	if pq.data.Len() == 0 {
+		return nil, ErrEmpty
+	}
+
+	item := heap.Pop(&pq.data).(*Item)
+	delete(pq.dataMap, item.Key)
+	return item, nil
+}
+
+// Peek returns the item with the lowest priority without removing it from the
+// queue. Nil is returned if the queue is empty.
+func (pq *PriorityQueue) Peek() *Item {
+	pq.dataLock.RLock()
+	defer pq.dataLock.RUnlock()
+
+	if pq.data.Len() == 0 {
+		return nil
+	}
+	return pq.data[0]
+}
+
+// PopByKey removes and returns the item with the given key. Nil is returned if
+// no such item is in the queue.
+func (pq *PriorityQueue) PopByKey(key string) (*Item, error) {
+	pq.dataLock.Lock()
+	defer pq.dataLock.Unlock()
+
+	item, ok := pq.dataMap[key]
+	if !ok {
+		return nil, nil
+	}
+
+	heap.Remove(&pq.data, item.index)
+	delete(pq.dataMap, key)
+	return item, nil
+}
+
+// queue implements heap.Interface
+type queue []*Item
+
+func (q queue) Len() int { return len(q) }
+
+func (q queue) Less(i, j int) bool {
+	return q[i].Priority < q[j].Priority
+}
+
+func (q queue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *queue) Push(x interface{}) {
+	item := x.(*Item)
+	item.index = len(*q)
+	*q = append(*q, item)
+}
+
+func (q *queue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*q = old[0 : n-1]
+	return item
+}
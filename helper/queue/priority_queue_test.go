@@ -0,0 +1,56 @@
+package queue
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	pq := New()
+
+	if _, err := pq.Pop(); err != ErrEmpty {
+		t.Fatalf("expected ErrEmpty, got: %v", err)
+	}
+	if pq.Peek() != nil {
+		t.Fatal("expected nil peeking an empty queue")
+	}
+
+	priorities := []int64{5, 1, 4, 2, 3}
+	for _, p := range priorities {
+		if err := pq.Push(&Item{Key: fmt.Sprintf("item-%d", p), Priority: p}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pq.Push(&Item{Key: "item-1", Priority: 10}); err != ErrDuplicateItem {
+		t.Fatalf("expected ErrDuplicateItem, got: %v", err)
+	}
+	if pq.Len() != len(priorities) {
+		t.Fatalf("expected %d items, got %d", len(priorities), pq.Len())
+	}
+
+	item, err := pq.PopByKey("item-3")
+	if err != nil || item == nil || item.Priority != 3 {
+		t.Fatalf("err: %v, item: %#v", err, item)
+	}
+	item, err = pq.PopByKey("item-3")
+	if err != nil || item != nil {
+		t.Fatalf("expected no item, got err: %v, item: %#v", err, item)
+	}
+
+	if peek := pq.Peek(); peek == nil || peek.Key != "item-1" {
+		t.Fatalf("bad: %#v", peek)
+	}
+
+	for _, expected := range []int64{1, 2, 4, 5} {
+		item, err := pq.Pop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.Priority != expected {
+			t.Fatalf("expected priority %d, got %d", expected, item.Priority)
+		}
+	}
+	if pq.Len() != 0 {
+		t.Fatalf("expected empty queue, got %d items", pq.Len())
+	}
+}
@@ -0,0 +1,83 @@
+package totputil
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image/png"
+
+	"github.com/hashicorp/errwrap"
+	otplib "github.com/pquerna/otp"
+	totplib "github.com/pquerna/otp/totp"
+)
+
+// GeneratedKey is a TOTP key generated by GenerateKey
+type GeneratedKey struct {
+	// Key is the base32 encoded secret of the key
+	Key string
+
+	// URL is the otpauth URL that can be used to load the key into a TOTP
+	// application
+	URL string
+
+	// Barcode is the base64 encoded PNG image of the QR code of URL. It is
+	// empty if no QR code was requested.
+	Barcode string
+}
+
+// GenerateKey generates a new TOTP key using the given options. If qrSize is
+// greater than zero, a square QR code of that pixel size is rendered as well.
+func GenerateKey(opts totplib.GenerateOpts, qrSize int) (*GeneratedKey, error) {
+	keyObject, err := totplib.Generate(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	generated := &GeneratedKey{
+		Key: keyObject.Secret(),
+		URL: keyObject.String(),
+	}
+
+	if qrSize > 0 {
+		barcode, err := keyObject.Image(qrSize, qrSize)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to generate QR code image: {{err}}", err)
+		}
+
+		var buff bytes.Buffer
+		if err := png.Encode(&buff, barcode); err != nil {
+			return nil, errwrap.Wrapf("failed to encode QR code image: {{err}}", err)
+		}
+		generated.Barcode = base64.StdEncoding.EncodeToString(buff.Bytes())
+	}
+
+	return generated, nil
+}
+
+// ParseDigits translates the number of digits of a TOTP code to the format
+// the TOTP library understands
+func ParseDigits(digits int) (otplib.Digits, error) {
+	switch digits {
+	case 6:
+		return otplib.DigitsSix, nil
+	case 8:
+		return otplib.DigitsEight, nil
+	default:
+		return 0, errors.New("the digits value can only be 6 or 8")
+	}
+}
+
+// ParseAlgorithm translates the name of a hashing algorithm to the format the
+// TOTP library understands
+func ParseAlgorithm(algorithm string) (otplib.Algorithm, error) {
+	switch algorithm {
+	case "SHA1":
+		return otplib.AlgorithmSHA1, nil
+	case "SHA256":
+		return otplib.AlgorithmSHA256, nil
+	case "SHA512":
+		return otplib.AlgorithmSHA512, nil
+	default:
+		return 0, errors.New("the algorithm value is not valid")
+	}
+}
@@ -0,0 +1,71 @@
+package totputil
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	otplib "github.com/pquerna/otp"
+	totplib "github.com/pquerna/otp/totp"
+)
+
+func TestGenerateKey(t *testing.T) {
+	generated, err := GenerateKey(totplib.GenerateOpts{
+		Issuer:      "vault",
+		AccountName: "test",
+		Period:      30,
+		Digits:      otplib.DigitsSix,
+		Algorithm:   otplib.AlgorithmSHA1,
+		SecretSize:  20,
+	}, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := otplib.NewKeyFromURL(generated.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Secret() != generated.Key || key.Issuer() != "vault" || key.AccountName() != "test" {
+		t.Fatalf("bad: %#v", generated)
+	}
+	if _, err := base64.StdEncoding.DecodeString(generated.Barcode); err != nil || generated.Barcode == "" {
+		t.Fatalf("bad barcode: %v", err)
+	}
+
+	code, err := totplib.GenerateCode(generated.Key, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !totplib.Validate(code, generated.Key) {
+		t.Fatal("expected the generated key to validate codes")
+	}
+
+	// No QR code is rendered if the size is zero
+	generated, err = GenerateKey(totplib.GenerateOpts{
+		Issuer:      "vault",
+		AccountName: "test",
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if generated.Barcode != "" {
+		t.Fatal("expected no barcode")
+	}
+}
+
+func TestParseDigitsAndAlgorithm(t *testing.T) {
+	if digits, err := ParseDigits(8); err != nil || digits != otplib.DigitsEight {
+		t.Fatalf("bad: %v, %v", digits, err)
+	}
+	if _, err := ParseDigits(7); err == nil {
+		t.Fatal("expected an error for 7 digits")
+	}
+
+	if algorithm, err := ParseAlgorithm("SHA512"); err != nil || algorithm != otplib.AlgorithmSHA512 {
+		t.Fatalf("bad: %v, %v", algorithm, err)
+	}
+	if _, err := ParseAlgorithm("MD5"); err == nil {
+		t.Fatal("expected an error for MD5")
+	}
+}
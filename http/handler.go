@@ -6,11 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math"
 	"net"
 	"net/http"
 	"net/textproto"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -116,6 +119,7 @@ func Handler(props *vault.HandlerProperties) http.Handler {
 	mux.Handle("/v1/sys/rekey-recovery-key/init", handleRequestForwarding(core, handleSysRekeyInit(core, true)))
 	mux.Handle("/v1/sys/rekey-recovery-key/update", handleRequestForwarding(core, handleSysRekeyUpdate(core, true)))
 	mux.Handle("/v1/sys/rekey-recovery-key/verify", handleRequestForwarding(core, handleSysRekeyVerify(core, true)))
+	mux.Handle("/v1/sys/storage/raft/join", handleSysRaftJoin(core))
 	for _, path := range injectDataIntoTopRoutes {
 		mux.Handle(path, handleRequestForwarding(core, handleLogicalWithInjector(core)))
 	}
@@ -472,6 +476,28 @@ func parseRequest(r *http.Request, w http.ResponseWriter, out interface{}) error
 	return err
 }
 
+// readRawRequest reads the body of the request without interpreting it,
+// subject to the same size limit as parseRequest
+func readRawRequest(r *http.Request, w http.ResponseWriter) ([]byte, error) {
+	reader := r.Body
+	ctx := r.Context()
+	maxRequestSize := ctx.Value("max_request_size")
+	if maxRequestSize != nil {
+		max, ok := maxRequestSize.(int64)
+		if !ok {
+			return nil, errors.New("could not parse max_request_size from request context")
+		}
+		if max > 0 {
+			reader = http.MaxBytesReader(w, r.Body, max)
+		}
+	}
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to read request body: {{err}}", err)
+	}
+	return body, nil
+}
+
 // handleRequestForwarding determines whether to forward a request or not,
 // falling back on the older behavior of redirecting the client
 func handleRequestForwarding(core *vault.Core, handler http.Handler) http.Handler {
@@ -657,8 +683,17 @@ func getTokenFromReq(r *http.Request) (string, error) {
 
 // requestAuth adds the token to the logical.Request if it exists.
 func requestAuth(core *vault.Core, r *http.Request, req *logical.Request) (*logical.Request, error) {
+	getToken := getTokenFromReq
+	if vault.IsOIDCProviderClientPath(req.Path) {
+		// OIDC clients use the Authorization header for their own
+		// credentials, which are checked by the identity store
+		getToken = func(r *http.Request) (string, error) {
+			return r.Header.Get(consts.AuthHeaderName), nil
+		}
+	}
+
 	// Attach the header value if we have it
-	if token, err := getTokenFromReq(r); err != nil {
+	if token, err := getToken(r); err != nil {
 		return req, err
 	} else if token != "" {
 		req.ClientToken = token
@@ -775,6 +810,11 @@ func parseMFAHeader(req *logical.Request) error {
 func respondError(w http.ResponseWriter, status int, err error) {
 	logical.AdjustErrorStatusCode(&status, err)
 
+	// Tell rate limited clients when to retry
+	if quotaErr, ok := errwrap.GetType(err, new(logical.RateLimitQuotaError)).(*logical.RateLimitQuotaError); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(quotaErr.RetryAfter.Seconds()))))
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 
@@ -10,6 +10,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-cleanhttp"
 	"github.com/hashicorp/vault/helper/consts"
@@ -512,6 +513,21 @@ func TestHandler_error(t *testing.T) {
 	if w3.Code != 503 {
 		t.Fatalf("expected 503, got %d", w3.Code)
 	}
+
+	// Rate limit quota errors tell the client when to retry
+	w4 := httptest.NewRecorder()
+
+	respondError(w4, 400, &logical.RateLimitQuotaError{
+		Quota:      "test",
+		RetryAfter: 1500 * time.Millisecond,
+	})
+
+	if w4.Code != 429 {
+		t.Fatalf("expected 429, got %d", w4.Code)
+	}
+	if retryAfter := w4.Header().Get("Retry-After"); retryAfter != "2" {
+		t.Fatalf("expected Retry-After of 2, got %q", retryAfter)
+	}
 }
 
 func TestHandler_requestAuth(t *testing.T) {
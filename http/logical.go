@@ -1,23 +1,33 @@
 package http
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/vault"
 )
 
+// headPathRe matches the paths that can be read with HEAD requests. These are
+// the ACME directory and nonce endpoints of the PKI backend, whose clients
+// only need the response headers. Other reads may have side effects, such as
+// generating credentials, so HEAD is refused on them.
+var headPathRe = regexp.MustCompile(`(^|/)acme/(roles/[^/]+/)?(directory|new-nonce)$`)
+
 func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Request) (*logical.Request, int, error) {
 	ns, err := namespace.FromContext(r.Context())
 	if err != nil {
@@ -33,7 +43,10 @@ func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Reques
 	case "DELETE":
 		op = logical.DeleteOperation
 
-	case "GET":
+	case "GET", "HEAD":
+		if r.Method == "HEAD" && !headPathRe.MatchString(path) {
+			return nil, http.StatusMethodNotAllowed, nil
+		}
 		op = logical.ReadOperation
 		queryVals := r.URL.Query()
 		var list bool
@@ -53,23 +66,7 @@ func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Reques
 		}
 
 		if !list {
-			getData := map[string]interface{}{}
-
-			for k, v := range r.URL.Query() {
-				// Skip the help key as this is a reserved parameter
-				if k == "help" {
-					continue
-				}
-
-				switch {
-				case len(v) == 0:
-				case len(v) == 1:
-					getData[k] = v[0]
-				default:
-					getData[k] = v
-				}
-			}
-
+			getData := parseFormValues(r.URL.Query())
 			if len(getData) > 0 {
 				data = getData
 			}
@@ -78,7 +75,37 @@ func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Reques
 	case "POST", "PUT":
 		op = logical.UpdateOperation
 		// Parse the request if we can
-		if op == logical.UpdateOperation {
+		switch {
+		case r.Header.Get("Content-Type") == "application/ocsp-request":
+			// OCSP requests are DER encoded, so they are handed to the
+			// backend as-is rather than parsed as JSON
+			body, err := readRawRequest(r, w)
+			if err != nil {
+				return nil, http.StatusBadRequest, err
+			}
+			data = map[string]interface{}{
+				logical.HTTPRawBody: body,
+			}
+		case strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") && vault.IsOIDCProviderClientPath(path):
+			// Form bodies are sent by clients of the OIDC provider. Clients
+			// such as curl send JSON with the same content type, so bodies
+			// that parse as JSON are still decoded as JSON.
+			body, err := readRawRequest(r, w)
+			if err != nil {
+				return nil, http.StatusBadRequest, err
+			}
+			if len(body) == 0 {
+				break
+			}
+			if err := jsonutil.DecodeJSONFromReader(bytes.NewReader(body), &data); err == nil {
+				break
+			}
+			values, err := url.ParseQuery(string(body))
+			if err != nil {
+				return nil, http.StatusBadRequest, errwrap.Wrapf("failed to parse form input: {{err}}", err)
+			}
+			data = parseFormValues(values)
+		case op == logical.UpdateOperation:
 			err := parseRequest(r, w, &data)
 			if err == io.EOF {
 				data = nil
@@ -138,6 +165,28 @@ func buildLogicalRequest(core *vault.Core, w http.ResponseWriter, r *http.Reques
 	return req, 0, nil
 }
 
+// parseFormValues converts query or form values to request data. Keys that are
+// given more than once become lists.
+func parseFormValues(values url.Values) map[string]interface{} {
+	data := map[string]interface{}{}
+	for k, v := range values {
+		// Skip the help key as this is a reserved parameter
+		if k == "help" {
+			continue
+		}
+
+		switch {
+		case len(v) == 0:
+		case len(v) == 1:
+			data[k] = v[0]
+		default:
+			data[k] = v
+		}
+	}
+
+	return data
+}
+
 func handleLogical(core *vault.Core) http.Handler {
 	return handleLogicalInternal(core, false)
 }
@@ -358,6 +407,34 @@ WRITE_RESPONSE:
 		w.Header().Set("Content-Type", contentType)
 	}
 
+	if headersRaw, ok := resp.Data[logical.HTTPHeaders]; ok {
+		switch headers := headersRaw.(type) {
+		case map[string][]string:
+			for k, v := range headers {
+				w.Header()[http.CanonicalHeaderKey(k)] = v
+			}
+		case http.Header:
+			for k, v := range headers {
+				w.Header()[http.CanonicalHeaderKey(k)] = v
+			}
+		case map[string]interface{}:
+			// Headers that have been through JSON
+			for k, v := range headers {
+				vals, ok := v.([]interface{})
+				if !ok {
+					retErr(w, "cannot decode headers")
+					return
+				}
+				for _, val := range vals {
+					w.Header().Add(k, fmt.Sprint(val))
+				}
+			}
+		default:
+			retErr(w, "cannot decode headers")
+			return
+		}
+	}
+
 	w.WriteHeader(status)
 	w.Write(body)
 }
@@ -316,6 +316,75 @@ func TestLogical_ListSuffix(t *testing.T) {
 	}
 }
 
+func TestLogical_HeadRequest(t *testing.T) {
+	core, _, rootToken := vault.TestCoreUnsealed(t)
+
+	for path, expected := range map[string]int{
+		"secret/foo":                         http.StatusMethodNotAllowed,
+		"database/creds/readonly":            http.StatusMethodNotAllowed,
+		"pki/acme/new-nonce":                 0,
+		"pki/acme/directory":                 0,
+		"pki/acme/roles/example/new-nonce":   0,
+		"pki/acme/roles/example/new-nonce/x": http.StatusMethodNotAllowed,
+		"pki/acme/roles/example/new-order":   http.StatusMethodNotAllowed,
+	} {
+		req, _ := http.NewRequest("HEAD", "http://127.0.0.1:8200/v1/"+path, nil)
+		req = req.WithContext(namespace.RootContext(nil))
+		req.Header.Add(consts.AuthHeaderName, rootToken)
+		lreq, status, err := buildLogicalRequest(core, nil, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != expected {
+			t.Fatalf("%s: got status %d, expected %d", path, status, expected)
+		}
+		if status == 0 && lreq.Operation != logical.ReadOperation {
+			t.Fatalf("%s: bad operation %q", path, lreq.Operation)
+		}
+	}
+}
+
+func TestLogical_FormBody(t *testing.T) {
+	core, _, rootToken := vault.TestCoreUnsealed(t)
+
+	buildRequest := func(path, body string) *logical.Request {
+		req, _ := http.NewRequest("POST", "http://127.0.0.1:8200/v1/"+path, strings.NewReader(body))
+		req = req.WithContext(namespace.RootContext(nil))
+		req.Header.Add(consts.AuthHeaderName, rootToken)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		lreq, status, err := buildLogicalRequest(core, nil, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != 0 {
+			t.Fatalf("got status %d", status)
+		}
+		return lreq
+	}
+
+	// Form bodies are parsed on the OIDC provider endpoints
+	lreq := buildRequest("identity/oidc/provider/default/token", "grant_type=authorization_code&scope=openid&scope=email")
+	expected := map[string]interface{}{
+		"grant_type": "authorization_code",
+		"scope":      []string{"openid", "email"},
+	}
+	if diff := deep.Equal(lreq.Data, expected); diff != nil {
+		t.Fatal(diff)
+	}
+
+	// JSON sent with the form content type, as curl does by default, is
+	// decoded as JSON on every path
+	for _, path := range []string{"secret/foo", "identity/oidc/provider/default/token"} {
+		lreq = buildRequest(path, `{"grant_type": "authorization_code"}`)
+		expected = map[string]interface{}{
+			"grant_type": "authorization_code",
+		}
+		if diff := deep.Equal(lreq.Data, expected); diff != nil {
+			t.Fatalf("%s: %v", path, diff)
+		}
+	}
+}
+
 func TestLogical_RespondWithStatusCode(t *testing.T) {
 	resp := &logical.Response{
 		Data: map[string]interface{}{
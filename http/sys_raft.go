@@ -0,0 +1,67 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+
+	"github.com/hashicorp/vault/helper/tlsutil"
+	"github.com/hashicorp/vault/vault"
+)
+
+func handleSysRaftJoin(core *vault.Core) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST", "PUT":
+			handleSysRaftJoinPost(core, w, r)
+		default:
+			respondError(w, http.StatusMethodNotAllowed, nil)
+		}
+	})
+}
+
+func handleSysRaftJoinPost(core *vault.Core, w http.ResponseWriter, r *http.Request) {
+	// Parse the request
+	var req JoinRequest
+	if err := parseRequest(r, w, &req); err != nil {
+		respondError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.LeaderAPIAddr == "" {
+		respondError(w, http.StatusBadRequest, errors.New("leader_api_addr is required"))
+		return
+	}
+
+	var tlsConfig *tls.Config
+	var err error
+	if len(req.LeaderCACert) != 0 || len(req.LeaderClientCert) != 0 {
+		tlsConfig, err = tlsutil.ClientTLSConfig([]byte(req.LeaderCACert), []byte(req.LeaderClientCert), []byte(req.LeaderClientKey))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	joined, err := core.JoinRaftCluster(context.Background(), req.LeaderAPIAddr, tlsConfig)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	respondOk(w, &JoinResponse{
+		Joined: joined,
+	})
+}
+
+type JoinResponse struct {
+	Joined bool `json:"joined"`
+}
+
+type JoinRequest struct {
+	LeaderAPIAddr    string `json:"leader_api_addr"`
+	LeaderCACert     string `json:"leader_ca_cert"`
+	LeaderClientCert string `json:"leader_client_cert"`
+	LeaderClientKey  string `json:"leader_client_key"`
+}
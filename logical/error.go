@@ -1,6 +1,11 @@
 package logical
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
 
 var (
 	// ErrUnsupportedOperation is returned if the operation is not supported
@@ -24,6 +29,10 @@ var (
 	// ErrUpstreamRateLimited is returned when Vault receives a rate limited
 	// response from an upstream
 	ErrUpstreamRateLimited = errors.New("upstream rate limited")
+
+	// ErrLeaseCountQuotaExceeded is returned when a lease cannot be created
+	// because a lease count quota has been reached
+	ErrLeaseCountQuotaExceeded = errors.New("lease count quota exceeded")
 )
 
 type HTTPCodedError interface {
@@ -88,3 +97,20 @@ func (e *KeyNotFoundError) WrappedErrors() []error {
 func (e *KeyNotFoundError) Error() string {
 	return e.Err.Error()
 }
+
+// RateLimitQuotaError is returned when a request is rejected by a rate limit
+// quota. RetryAfter is how long the client should wait before retrying.
+type RateLimitQuotaError struct {
+	Quota      string
+	RetryAfter time.Duration
+}
+
+var _ HTTPCodedError = (*RateLimitQuotaError)(nil)
+
+func (e *RateLimitQuotaError) Error() string {
+	return fmt.Sprintf("rate limit quota %q exceeded", e.Quota)
+}
+
+func (e *RateLimitQuotaError) Code() int {
+	return http.StatusTooManyRequests
+}
@@ -0,0 +1,235 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/armon/go-radix"
+	"github.com/hashicorp/errwrap"
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/physical"
+)
+
+const (
+	putOp uint32 = 1 << iota
+	deleteOp
+	lockPutOp
+	lockDeleteOp
+)
+
+// Verify FSM satisfies the correct interfaces
+var _ raft.FSM = (*FSM)(nil)
+
+// LogOperation is a single storage operation carried in a raft log entry.
+type LogOperation struct {
+	// OpType is the operation type
+	OpType uint32 `json:"op_type"`
+
+	// Key is the key the operation acts upon
+	Key string `json:"key"`
+
+	// Value is the value written for put operations
+	Value []byte `json:"value,omitempty"`
+}
+
+// LogData is the payload of a raft log entry. All operations in a single
+// entry are applied atomically.
+type LogData struct {
+	Operations []*LogOperation `json:"operations"`
+}
+
+// fsmState is the serialized form of the FSM used for snapshots.
+type fsmState struct {
+	Entries map[string][]byte `json:"entries"`
+	Locks   map[string]string `json:"locks"`
+}
+
+// FSM is the finite state machine replicated by raft. It keeps the storage
+// entries in an in-memory radix tree; durability is provided by the raft log
+// and snapshot stores.
+type FSM struct {
+	l      sync.RWMutex
+	root   *radix.Tree
+	locks  map[string]string
+	logger log.Logger
+
+	// latestIndex is the index of the last log applied to the FSM
+	latestIndex *uint64
+}
+
+// NewFSM constructs an empty FSM
+func NewFSM(logger log.Logger) *FSM {
+	return &FSM{
+		root:        radix.New(),
+		locks:       make(map[string]string),
+		logger:      logger,
+		latestIndex: new(uint64),
+	}
+}
+
+// LatestIndex returns the index of the last log applied to the FSM
+func (f *FSM) LatestIndex() uint64 {
+	return atomic.LoadUint64(f.latestIndex)
+}
+
+// Get returns the entry stored at the given key, or nil if none exists
+func (f *FSM) Get(ctx context.Context, key string) (*physical.Entry, error) {
+	f.l.RLock()
+	defer f.l.RUnlock()
+
+	raw, ok := f.root.Get(key)
+	if !ok {
+		return nil, nil
+	}
+
+	return &physical.Entry{
+		Key:   key,
+		Value: raw.([]byte),
+	}, nil
+}
+
+// List is used to list all the keys under a given prefix, up to the next
+// prefix.
+func (f *FSM) List(ctx context.Context, prefix string) ([]string, error) {
+	f.l.RLock()
+	defer f.l.RUnlock()
+
+	var out []string
+	seen := make(map[string]interface{})
+	walkFn := func(s string, v interface{}) bool {
+		trimmed := strings.TrimPrefix(s, prefix)
+		sep := strings.Index(trimmed, "/")
+		if sep == -1 {
+			out = append(out, trimmed)
+		} else {
+			trimmed = trimmed[:sep+1]
+			if _, ok := seen[trimmed]; !ok {
+				out = append(out, trimmed)
+				seen[trimmed] = struct{}{}
+			}
+		}
+		return false
+	}
+	f.root.WalkPrefix(prefix, walkFn)
+
+	return out, nil
+}
+
+// lockValue returns the value of the named HA lock and whether it is held
+func (f *FSM) lockValue(key string) (bool, string) {
+	f.l.RLock()
+	defer f.l.RUnlock()
+
+	value, ok := f.locks[key]
+	return ok, value
+}
+
+// Apply is called by raft once a log entry is committed
+func (f *FSM) Apply(l *raft.Log) interface{} {
+	var data LogData
+	if err := jsonutil.DecodeJSON(l.Data, &data); err != nil {
+		f.logger.Error("failed to decode log entry", "index", l.Index, "error", err)
+		return errwrap.Wrapf("failed to decode log entry: {{err}}", err)
+	}
+
+	f.l.Lock()
+	defer f.l.Unlock()
+
+	var err error
+	for _, op := range data.Operations {
+		switch op.OpType {
+		case putOp:
+			f.root.Insert(op.Key, op.Value)
+		case deleteOp:
+			f.root.Delete(op.Key)
+		case lockPutOp:
+			f.locks[op.Key] = string(op.Value)
+		case lockDeleteOp:
+			delete(f.locks, op.Key)
+		default:
+			err = fmt.Errorf("%d is not a supported operation type", op.OpType)
+		}
+	}
+
+	atomic.StoreUint64(f.latestIndex, l.Index)
+
+	return err
+}
+
+// Snapshot returns a point-in-time copy of the FSM state
+func (f *FSM) Snapshot() (raft.FSMSnapshot, error) {
+	f.l.RLock()
+	defer f.l.RUnlock()
+
+	state := &fsmState{
+		Entries: make(map[string][]byte, f.root.Len()),
+		Locks:   make(map[string]string, len(f.locks)),
+	}
+	f.root.Walk(func(s string, v interface{}) bool {
+		state.Entries[s] = v.([]byte)
+		return false
+	})
+	for k, v := range f.locks {
+		state.Locks[k] = v
+	}
+
+	return &fsmSnapshot{state: state}, nil
+}
+
+// Restore replaces the FSM state with the contents of the snapshot
+func (f *FSM) Restore(rc io.ReadCloser) error {
+	defer rc.Close()
+
+	var state fsmState
+	if err := jsonutil.DecodeJSONFromReader(rc, &state); err != nil {
+		return errwrap.Wrapf("failed to decode snapshot: {{err}}", err)
+	}
+	if state.Entries == nil {
+		return errors.New("snapshot contained no entries map")
+	}
+
+	root := radix.New()
+	for k, v := range state.Entries {
+		root.Insert(k, v)
+	}
+	locks := state.Locks
+	if locks == nil {
+		locks = make(map[string]string)
+	}
+
+	f.l.Lock()
+	f.root = root
+	f.locks = locks
+	f.l.Unlock()
+
+	return nil
+}
+
+// fsmSnapshot implements raft.FSMSnapshot
+type fsmSnapshot struct {
+	state *fsmState
+}
+
+// Persist writes the snapshot to the given sink
+func (s *fsmSnapshot) Persist(sink raft.SnapshotSink) error {
+	buf, err := jsonutil.EncodeJSON(s.state)
+	if err != nil {
+		sink.Cancel()
+		return err
+	}
+	if _, err := sink.Write(buf); err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+// Release is a no-op as the snapshot holds a private copy of the state
+func (s *fsmSnapshot) Release() {}
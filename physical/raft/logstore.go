@@ -0,0 +1,413 @@
+package raft
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/raft"
+)
+
+const (
+	// journalFileName is the name of the file holding the journal
+	journalFileName = "raft.journal"
+
+	// compactThreshold is the minimum number of journal records beyond the
+	// live state at which the journal gets rewritten
+	compactThreshold = 1024
+)
+
+const (
+	recordStoreLog    = "log"
+	recordDeleteRange = "delete-range"
+	recordSet         = "set"
+	recordSetUint64   = "set-uint64"
+)
+
+var (
+	// ErrLogNotFound is returned when a requested log does not exist
+	ErrLogNotFound = raft.ErrLogNotFound
+
+	// Verify LogStore satisfies the correct interfaces
+	_ raft.LogStore    = (*LogStore)(nil)
+	_ raft.StableStore = (*LogStore)(nil)
+)
+
+// journalRecord is a single mutation persisted to the journal.
+type journalRecord struct {
+	Op     string    `json:"op"`
+	Log    *raft.Log `json:"log,omitempty"`
+	Min    uint64    `json:"min,omitempty"`
+	Max    uint64    `json:"max,omitempty"`
+	Key    []byte    `json:"key,omitempty"`
+	Value  []byte    `json:"value,omitempty"`
+	Uint64 uint64    `json:"uint64,omitempty"`
+}
+
+// LogStore implements raft.LogStore and raft.StableStore. All state is kept
+// in memory and every mutation is appended to a journal file which is synced
+// before the call returns. On startup the journal is replayed to rebuild the
+// state. Once the journal holds enough records that are no longer relevant,
+// which happens after raft compacts its log following a snapshot, the journal
+// is rewritten with only the live state.
+type LogStore struct {
+	l sync.RWMutex
+
+	path    string
+	file    *os.File
+	records int
+
+	logs      map[uint64]*raft.Log
+	lowIndex  uint64
+	highIndex uint64
+
+	kv       map[string][]byte
+	kvUint64 map[string]uint64
+}
+
+// NewLogStore opens, or creates, the journal in the given directory and
+// replays it.
+func NewLogStore(dir string) (*LogStore, error) {
+	s := &LogStore{
+		path:     filepath.Join(dir, journalFileName),
+		logs:     make(map[uint64]*raft.Log),
+		kv:       make(map[string][]byte),
+		kvUint64: make(map[string]uint64),
+	}
+
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to open raft journal: {{err}}", err)
+	}
+	s.file = f
+
+	return s, nil
+}
+
+// replay reads the journal and applies every intact record. A trailing,
+// partially-written record is discarded.
+func (s *LogStore) replay() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errwrap.Wrapf("failed to open raft journal: {{err}}", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		rec, n, err := readRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF {
+			// A crash happened in the middle of writing the last record; drop
+			// it since the caller was never told it succeeded.
+			if err := os.Truncate(s.path, offset); err != nil {
+				return errwrap.Wrapf("failed to truncate torn raft journal record: {{err}}", err)
+			}
+			break
+		}
+		if err != nil {
+			return errwrap.Wrapf("failed to read raft journal: {{err}}", err)
+		}
+		offset += int64(n)
+		s.apply(rec)
+		s.records++
+	}
+
+	return nil
+}
+
+// readRecord reads a single length-prefixed record
+func readRecord(r io.Reader) (*journalRecord, int, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, 0, err
+	}
+
+	buf := make([]byte, binary.BigEndian.Uint32(header[:]))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, 0, err
+	}
+
+	rec := new(journalRecord)
+	if err := json.Unmarshal(buf, rec); err != nil {
+		return nil, 0, err
+	}
+
+	return rec, len(header) + len(buf), nil
+}
+
+// encodeRecord returns the length-prefixed encoding of a record
+func encodeRecord(rec *journalRecord) ([]byte, error) {
+	buf, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 4, 4+len(buf))
+	binary.BigEndian.PutUint32(out, uint32(len(buf)))
+	return append(out, buf...), nil
+}
+
+// apply mutates the in-memory state; the caller must hold the lock
+func (s *LogStore) apply(rec *journalRecord) {
+	switch rec.Op {
+	case recordStoreLog:
+		s.logs[rec.Log.Index] = rec.Log
+		if s.lowIndex == 0 || rec.Log.Index < s.lowIndex {
+			s.lowIndex = rec.Log.Index
+		}
+		if rec.Log.Index > s.highIndex {
+			s.highIndex = rec.Log.Index
+		}
+	case recordDeleteRange:
+		for j := rec.Min; j <= rec.Max; j++ {
+			delete(s.logs, j)
+		}
+		if rec.Min <= s.lowIndex {
+			s.lowIndex = rec.Max + 1
+		}
+		if rec.Max >= s.highIndex {
+			s.highIndex = rec.Min - 1
+		}
+		if s.lowIndex > s.highIndex {
+			s.lowIndex = 0
+			s.highIndex = 0
+		}
+	case recordSet:
+		s.kv[string(rec.Key)] = rec.Value
+	case recordSetUint64:
+		s.kvUint64[string(rec.Key)] = rec.Uint64
+	}
+}
+
+// write durably appends the given records to the journal and applies them;
+// the caller must hold the write lock
+func (s *LogStore) write(recs ...*journalRecord) error {
+	if s.file == nil {
+		return errors.New("raft journal is closed")
+	}
+
+	var buf []byte
+	for _, rec := range recs {
+		encoded, err := encodeRecord(rec)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, encoded...)
+	}
+
+	if _, err := s.file.Write(buf); err != nil {
+		return errwrap.Wrapf("failed to write raft journal: {{err}}", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return errwrap.Wrapf("failed to sync raft journal: {{err}}", err)
+	}
+
+	for _, rec := range recs {
+		s.apply(rec)
+	}
+	s.records += len(recs)
+
+	return nil
+}
+
+// compact rewrites the journal so that it only contains the live state; the
+// caller must hold the write lock
+func (s *LogStore) compact() error {
+	tmpPath := s.path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	records := 0
+	writeRec := func(rec *journalRecord) error {
+		encoded, err := encodeRecord(rec)
+		if err != nil {
+			return err
+		}
+		records++
+		_, err = w.Write(encoded)
+		return err
+	}
+
+	var retErr error
+	for k, v := range s.kv {
+		if retErr = writeRec(&journalRecord{Op: recordSet, Key: []byte(k), Value: v}); retErr != nil {
+			break
+		}
+	}
+	for k, v := range s.kvUint64 {
+		if retErr != nil {
+			break
+		}
+		retErr = writeRec(&journalRecord{Op: recordSetUint64, Key: []byte(k), Uint64: v})
+	}
+	if retErr == nil && s.lowIndex != 0 {
+		for j := s.lowIndex; j <= s.highIndex; j++ {
+			if retErr = writeRec(&journalRecord{Op: recordStoreLog, Log: s.logs[j]}); retErr != nil {
+				break
+			}
+		}
+	}
+	if retErr == nil {
+		retErr = w.Flush()
+	}
+	if retErr == nil {
+		retErr = f.Sync()
+	}
+	f.Close()
+	if retErr != nil {
+		os.Remove(tmpPath)
+		return retErr
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return err
+	}
+
+	s.file.Close()
+	s.file, err = os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		s.file = nil
+		return err
+	}
+	s.records = records
+
+	return nil
+}
+
+// FirstIndex returns the first index written. 0 for no entries.
+func (s *LogStore) FirstIndex() (uint64, error) {
+	s.l.RLock()
+	defer s.l.RUnlock()
+	return s.lowIndex, nil
+}
+
+// LastIndex returns the last index written. 0 for no entries.
+func (s *LogStore) LastIndex() (uint64, error) {
+	s.l.RLock()
+	defer s.l.RUnlock()
+	return s.highIndex, nil
+}
+
+// GetLog gets a log entry at a given index.
+func (s *LogStore) GetLog(index uint64, log *raft.Log) error {
+	s.l.RLock()
+	defer s.l.RUnlock()
+
+	l, ok := s.logs[index]
+	if !ok {
+		return ErrLogNotFound
+	}
+	*log = *l
+	return nil
+}
+
+// StoreLog stores a log entry.
+func (s *LogStore) StoreLog(log *raft.Log) error {
+	return s.StoreLogs([]*raft.Log{log})
+}
+
+// StoreLogs stores multiple log entries.
+func (s *LogStore) StoreLogs(logs []*raft.Log) error {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	recs := make([]*journalRecord, 0, len(logs))
+	for _, l := range logs {
+		// Copy the log as raft may reuse the struct
+		logCopy := *l
+		recs = append(recs, &journalRecord{Op: recordStoreLog, Log: &logCopy})
+	}
+
+	return s.write(recs...)
+}
+
+// DeleteRange deletes a range of log entries. The range is inclusive.
+func (s *LogStore) DeleteRange(min, max uint64) error {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if err := s.write(&journalRecord{Op: recordDeleteRange, Min: min, Max: max}); err != nil {
+		return err
+	}
+
+	live := len(s.logs) + len(s.kv) + len(s.kvUint64)
+	if s.records > compactThreshold+2*live {
+		if err := s.compact(); err != nil {
+			return errwrap.Wrapf("failed to compact raft journal: {{err}}", err)
+		}
+	}
+
+	return nil
+}
+
+// Set stores a key/value pair
+func (s *LogStore) Set(key []byte, val []byte) error {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	return s.write(&journalRecord{Op: recordSet, Key: key, Value: val})
+}
+
+// Get returns the value for key, or an empty byte slice if key was not found.
+func (s *LogStore) Get(key []byte) ([]byte, error) {
+	s.l.RLock()
+	defer s.l.RUnlock()
+
+	val, ok := s.kv[string(key)]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return val, nil
+}
+
+// SetUint64 stores a uint64 value for the given key
+func (s *LogStore) SetUint64(key []byte, val uint64) error {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	return s.write(&journalRecord{Op: recordSetUint64, Key: key, Uint64: val})
+}
+
+// GetUint64 returns the uint64 value for key, or 0 if key was not found.
+func (s *LogStore) GetUint64(key []byte) (uint64, error) {
+	s.l.RLock()
+	defer s.l.RUnlock()
+
+	return s.kvUint64[string(key)], nil
+}
+
+// Close closes the journal
+func (s *LogStore) Close() error {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,668 @@
+package raft
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/errwrap"
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/raft"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/physical"
+)
+
+const (
+	// nodeIDFileName is the file in the data directory holding the node ID
+	// when one is not explicitly configured
+	nodeIDFileName = "node-id"
+
+	// defaultAddress is the default address the raft transport binds to
+	defaultAddress = "127.0.0.1:8202"
+
+	// applyTimeout is the maximum amount of time to wait for a log to be
+	// committed
+	applyTimeout = 10 * time.Second
+
+	// lockRetryInterval is the interval at which lock acquisition re-checks
+	// for leadership
+	lockRetryInterval = 250 * time.Millisecond
+)
+
+var (
+	// ErrNotInitialized is returned when an operation requiring a running
+	// raft cluster is attempted before the node is bootstrapped or joined.
+	ErrNotInitialized = errors.New("raft storage is not initialized")
+
+	// ErrAlreadyInitialized is returned when attempting to bootstrap or join
+	// a node that already has raft state.
+	ErrAlreadyInitialized = errors.New("raft storage is already initialized")
+)
+
+// Verify RaftBackend satisfies the correct interfaces
+var _ physical.Backend = (*RaftBackend)(nil)
+var _ physical.HABackend = (*RaftBackend)(nil)
+var _ physical.Transactional = (*RaftBackend)(nil)
+var _ physical.Lock = (*RaftLock)(nil)
+
+// Peer describes a member of the raft cluster.
+type Peer struct {
+	ID      string `json:"node_id"`
+	Address string `json:"address"`
+	Leader  bool   `json:"leader"`
+	Voter   bool   `json:"voter"`
+}
+
+// RaftBackend is a physical backend that stores data in an FSM replicated
+// with the raft consensus protocol across the nodes of a Vault cluster. It
+// provides HA by tying the Vault active node to the raft leader.
+type RaftBackend struct {
+	logger log.Logger
+
+	// l protects the raft instance, which is nil until the node has been
+	// bootstrapped or has joined a cluster
+	l    sync.RWMutex
+	raft *raft.Raft
+
+	fsm       *FSM
+	logStore  *LogStore
+	snapStore raft.SnapshotStore
+	transport *raft.NetworkTransport
+
+	dataDir string
+	localID string
+
+	permitPool *physical.PermitPool
+}
+
+// NewRaftBackend constructs a RaftBackend using the given directory. If the
+// directory holds existing raft state the node rejoins its cluster
+// immediately; otherwise it waits to be bootstrapped or to join a cluster.
+func NewRaftBackend(conf map[string]string, logger log.Logger) (physical.Backend, error) {
+	path := os.Getenv("VAULT_RAFT_PATH")
+	if path == "" {
+		pathFromConfig, ok := conf["path"]
+		if !ok {
+			return nil, fmt.Errorf("'path' must be set")
+		}
+		path = pathFromConfig
+	}
+
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, errwrap.Wrapf("failed to create raft data directory: {{err}}", err)
+	}
+
+	localID, err := nodeID(conf, path)
+	if err != nil {
+		return nil, err
+	}
+
+	address := conf["address"]
+	if address == "" {
+		address = defaultAddress
+	}
+
+	var advertise net.Addr
+	if advertiseAddr := conf["advertise_address"]; advertiseAddr != "" {
+		advertise, err = net.ResolveTCPAddr("tcp", advertiseAddr)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to parse 'advertise_address': {{err}}", err)
+		}
+	}
+
+	stdLogger := logger.StandardLogger(&log.StandardLoggerOptions{InferLevels: true})
+
+	logStore, err := NewLogStore(path)
+	if err != nil {
+		return nil, err
+	}
+
+	snapStore, err := raft.NewFileSnapshotStoreWithLogger(path, 2, stdLogger)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to create raft snapshot store: {{err}}", err)
+	}
+
+	transport, err := raft.NewTCPTransportWithLogger(address, advertise, 3, 10*time.Second, stdLogger)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to create raft transport: {{err}}", err)
+	}
+
+	b := &RaftBackend{
+		logger:     logger,
+		fsm:        NewFSM(logger.Named("fsm")),
+		logStore:   logStore,
+		snapStore:  snapStore,
+		transport:  transport,
+		dataDir:    path,
+		localID:    localID,
+		permitPool: physical.NewPermitPool(physical.DefaultParallelOperations),
+	}
+
+	existing, err := raft.HasExistingState(logStore, logStore, snapStore)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to check for existing raft state: {{err}}", err)
+	}
+	if existing {
+		if err := b.SetupCluster(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// nodeID returns the configured node ID, or loads or generates one persisted
+// in the data directory
+func nodeID(conf map[string]string, path string) (string, error) {
+	if id := conf["node_id"]; id != "" {
+		return id, nil
+	}
+
+	idPath := filepath.Join(path, nodeIDFileName)
+	raw, err := ioutil.ReadFile(idPath)
+	switch {
+	case err == nil:
+		return strings.TrimSpace(string(raw)), nil
+	case !os.IsNotExist(err):
+		return "", errwrap.Wrapf("failed to read raft node ID: {{err}}", err)
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(idPath, []byte(id), 0600); err != nil {
+		return "", errwrap.Wrapf("failed to persist raft node ID: {{err}}", err)
+	}
+	return id, nil
+}
+
+// NodeID returns the ID of this node
+func (b *RaftBackend) NodeID() string {
+	return b.localID
+}
+
+// Addr returns the address other nodes use to reach this node
+func (b *RaftBackend) Addr() string {
+	return string(b.transport.LocalAddr())
+}
+
+// Initialized returns whether the node has been bootstrapped or has joined a
+// cluster.
+func (b *RaftBackend) Initialized() bool {
+	b.l.RLock()
+	defer b.l.RUnlock()
+	return b.raft != nil
+}
+
+func (b *RaftBackend) raftConfig() *raft.Config {
+	config := raft.DefaultConfig()
+	config.LocalID = raft.ServerID(b.localID)
+	config.Logger = b.logger
+	return config
+}
+
+// Bootstrap creates a new single-node cluster with this node as its only
+// member and waits for it to become leader.
+func (b *RaftBackend) Bootstrap(ctx context.Context) error {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	if b.raft != nil {
+		return ErrAlreadyInitialized
+	}
+
+	configuration := raft.Configuration{
+		Servers: []raft.Server{
+			{
+				Suffrage: raft.Voter,
+				ID:       raft.ServerID(b.localID),
+				Address:  b.transport.LocalAddr(),
+			},
+		},
+	}
+	if err := raft.BootstrapCluster(b.raftConfig(), b.logStore, b.logStore, b.snapStore, b.transport, configuration); err != nil {
+		return errwrap.Wrapf("failed to bootstrap raft cluster: {{err}}", err)
+	}
+
+	if err := b.setupClusterLocked(); err != nil {
+		return err
+	}
+
+	// Wait for the node to elect itself so that writes can proceed
+	for b.raft.State() != raft.Leader {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	return nil
+}
+
+// SetupCluster starts the raft instance without bootstrapping. It is used
+// when the node has existing state or is about to be added to an existing
+// cluster.
+func (b *RaftBackend) SetupCluster(ctx context.Context) error {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	if b.raft != nil {
+		return ErrAlreadyInitialized
+	}
+
+	return b.setupClusterLocked()
+}
+
+func (b *RaftBackend) setupClusterLocked() error {
+	r, err := raft.NewRaft(b.raftConfig(), b.fsm, b.logStore, b.logStore, b.snapStore, b.transport)
+	if err != nil {
+		return errwrap.Wrapf("failed to start raft: {{err}}", err)
+	}
+	b.raft = r
+	return nil
+}
+
+// TeardownCluster shuts down the raft instance and closes the underlying
+// stores. The backend cannot be used afterwards.
+func (b *RaftBackend) TeardownCluster() error {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	var retErr error
+	if b.raft != nil {
+		if err := b.raft.Shutdown().Error(); err != nil {
+			retErr = err
+		}
+	}
+	b.transport.Close()
+	if err := b.logStore.Close(); err != nil && retErr == nil {
+		retErr = err
+	}
+	return retErr
+}
+
+// IsLeader returns whether this node is the raft leader
+func (b *RaftBackend) IsLeader() bool {
+	b.l.RLock()
+	defer b.l.RUnlock()
+
+	return b.raft != nil && b.raft.State() == raft.Leader
+}
+
+// AddPeer adds a new voting member to the cluster. Must be called on the
+// leader.
+func (b *RaftBackend) AddPeer(ctx context.Context, peerID, clusterAddr string) error {
+	b.l.RLock()
+	defer b.l.RUnlock()
+
+	if b.raft == nil {
+		return ErrNotInitialized
+	}
+
+	b.logger.Debug("adding raft peer", "node_id", peerID, "cluster_addr", clusterAddr)
+	return b.raft.AddVoter(raft.ServerID(peerID), raft.ServerAddress(clusterAddr), 0, 0).Error()
+}
+
+// RemovePeer removes a member from the cluster. Must be called on the leader.
+func (b *RaftBackend) RemovePeer(ctx context.Context, peerID string) error {
+	b.l.RLock()
+	defer b.l.RUnlock()
+
+	if b.raft == nil {
+		return ErrNotInitialized
+	}
+
+	b.logger.Debug("removing raft peer", "node_id", peerID)
+	return b.raft.RemoveServer(raft.ServerID(peerID), 0, 0).Error()
+}
+
+// Peers returns the current members of the cluster
+func (b *RaftBackend) Peers(ctx context.Context) ([]Peer, error) {
+	b.l.RLock()
+	defer b.l.RUnlock()
+
+	if b.raft == nil {
+		return nil, ErrNotInitialized
+	}
+
+	future := b.raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return nil, err
+	}
+
+	leader := b.raft.Leader()
+	var ret []Peer
+	for _, server := range future.Configuration().Servers {
+		ret = append(ret, Peer{
+			ID:      string(server.ID),
+			Address: string(server.Address),
+			Leader:  server.Address == leader,
+			Voter:   server.Suffrage == raft.Voter,
+		})
+	}
+
+	return ret, nil
+}
+
+// Snapshot writes a point-in-time copy of the stored data to out
+func (b *RaftBackend) Snapshot(out io.Writer) error {
+	snap, err := b.fsm.Snapshot()
+	if err != nil {
+		return err
+	}
+	defer snap.Release()
+
+	sink := &bufferSink{}
+	if err := snap.Persist(sink); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, &sink.buf)
+	return err
+}
+
+// RestoreSnapshot replaces the contents of the cluster with the given
+// snapshot, as produced by Snapshot. Must be called on the leader.
+func (b *RaftBackend) RestoreSnapshot(ctx context.Context, snapshot []byte) error {
+	b.l.RLock()
+	defer b.l.RUnlock()
+
+	if b.raft == nil {
+		return ErrNotInitialized
+	}
+
+	// Validate the snapshot before handing it to raft, since a restore that
+	// fails to decode in the FSM would leave the cluster in a bad state
+	var state fsmState
+	if err := jsonutil.DecodeJSON(snapshot, &state); err != nil {
+		return errwrap.Wrapf("invalid snapshot: {{err}}", err)
+	}
+	if state.Entries == nil {
+		return errors.New("invalid snapshot: no entries found")
+	}
+
+	meta := &raft.SnapshotMeta{
+		Version: raft.SnapshotVersionMax,
+		Size:    int64(len(snapshot)),
+	}
+	return b.raft.Restore(meta, bytes.NewReader(snapshot), 0)
+}
+
+// Put is used to insert or update an entry
+func (b *RaftBackend) Put(ctx context.Context, entry *physical.Entry) error {
+	defer metrics.MeasureSince([]string{"raft", "put"}, time.Now())
+
+	return b.applyLog(ctx, &LogData{
+		Operations: []*LogOperation{
+			{
+				OpType: putOp,
+				Key:    entry.Key,
+				Value:  entry.Value,
+			},
+		},
+	})
+}
+
+// Get is used to fetch an entry
+func (b *RaftBackend) Get(ctx context.Context, key string) (*physical.Entry, error) {
+	defer metrics.MeasureSince([]string{"raft", "get"}, time.Now())
+
+	b.permitPool.Acquire()
+	defer b.permitPool.Release()
+
+	return b.fsm.Get(ctx, key)
+}
+
+// Delete is used to permanently delete an entry
+func (b *RaftBackend) Delete(ctx context.Context, key string) error {
+	defer metrics.MeasureSince([]string{"raft", "delete"}, time.Now())
+
+	return b.applyLog(ctx, &LogData{
+		Operations: []*LogOperation{
+			{
+				OpType: deleteOp,
+				Key:    key,
+			},
+		},
+	})
+}
+
+// List is used to list all the keys under a given prefix, up to the next
+// prefix.
+func (b *RaftBackend) List(ctx context.Context, prefix string) ([]string, error) {
+	defer metrics.MeasureSince([]string{"raft", "list"}, time.Now())
+
+	b.permitPool.Acquire()
+	defer b.permitPool.Release()
+
+	return b.fsm.List(ctx, prefix)
+}
+
+// Transaction applies all the given operations in a single raft log
+func (b *RaftBackend) Transaction(ctx context.Context, txns []*physical.TxnEntry) error {
+	defer metrics.MeasureSince([]string{"raft", "transaction"}, time.Now())
+
+	data := &LogData{
+		Operations: make([]*LogOperation, 0, len(txns)),
+	}
+	for _, txn := range txns {
+		op := &LogOperation{
+			Key: txn.Entry.Key,
+		}
+		switch txn.Operation {
+		case physical.PutOperation:
+			op.OpType = putOp
+			op.Value = txn.Entry.Value
+		case physical.DeleteOperation:
+			op.OpType = deleteOp
+		default:
+			return fmt.Errorf("%q is not a supported transaction operation", txn.Operation)
+		}
+		data.Operations = append(data.Operations, op)
+	}
+
+	return b.applyLog(ctx, data)
+}
+
+// applyLog commits the given operations through raft and waits for them to
+// be applied to the local FSM
+func (b *RaftBackend) applyLog(ctx context.Context, data *LogData) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	cmd, err := jsonutil.EncodeJSON(data)
+	if err != nil {
+		return err
+	}
+
+	b.permitPool.Acquire()
+	defer b.permitPool.Release()
+
+	b.l.RLock()
+	if b.raft == nil {
+		b.l.RUnlock()
+		return ErrNotInitialized
+	}
+	future := b.raft.Apply(cmd, applyTimeout)
+	b.l.RUnlock()
+
+	if err := future.Error(); err != nil {
+		return err
+	}
+	if resp := future.Response(); resp != nil {
+		if err, ok := resp.(error); ok {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// HAEnabled indicates whether the HA functionality should be exposed.
+// Always returns true.
+func (b *RaftBackend) HAEnabled() bool {
+	return true
+}
+
+// LockWith is used for mutual exclusion based on the given key.
+func (b *RaftBackend) LockWith(key, value string) (physical.Lock, error) {
+	return &RaftLock{
+		key:   key,
+		value: []byte(value),
+		b:     b,
+	}, nil
+}
+
+// RaftLock implements physical.Lock. The lock is held by whichever node is
+// the raft leader; acquiring it records the value in the replicated state so
+// that other nodes can discover the active node.
+type RaftLock struct {
+	key   string
+	value []byte
+	b     *RaftBackend
+
+	l      sync.Mutex
+	stopCh chan struct{}
+}
+
+// Lock blocks until this node is the raft leader, then records the lock
+// value. The returned channel is closed when leadership is lost.
+func (l *RaftLock) Lock(stopCh <-chan struct{}) (<-chan struct{}, error) {
+	for !l.b.IsLeader() {
+		select {
+		case <-stopCh:
+			return nil, nil
+		case <-time.After(lockRetryInterval):
+		}
+	}
+
+	// Make sure all logs committed by a previous leader are applied before
+	// the caller starts reading from storage as the active node
+	l.b.l.RLock()
+	err := l.b.raft.Barrier(0).Error()
+	l.b.l.RUnlock()
+	if err != nil {
+		return nil, errwrap.Wrapf("failed waiting for raft barrier: {{err}}", err)
+	}
+
+	err = l.b.applyLog(context.Background(), &LogData{
+		Operations: []*LogOperation{
+			{
+				OpType: lockPutOp,
+				Key:    l.key,
+				Value:  l.value,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	l.l.Lock()
+	l.stopCh = make(chan struct{})
+	monitorStopCh := l.stopCh
+	l.l.Unlock()
+
+	leaderLost := make(chan struct{})
+	go l.monitorLeadership(monitorStopCh, leaderLost)
+
+	return leaderLost, nil
+}
+
+// monitorLeadership closes leaderLost once this node is no longer the raft
+// leader or the lock is released
+func (l *RaftLock) monitorLeadership(stopCh <-chan struct{}, leaderLost chan struct{}) {
+	defer close(leaderLost)
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(lockRetryInterval):
+			if !l.b.IsLeader() {
+				return
+			}
+		}
+	}
+}
+
+// Unlock releases the lock. Since the lock follows raft leadership, this
+// node also hands raft leadership over to another node, which would
+// otherwise never be able to acquire the lock.
+func (l *RaftLock) Unlock() error {
+	l.l.Lock()
+	if l.stopCh != nil {
+		close(l.stopCh)
+		l.stopCh = nil
+	}
+	l.l.Unlock()
+
+	if !l.b.IsLeader() {
+		return nil
+	}
+
+	err := l.b.applyLog(context.Background(), &LogData{
+		Operations: []*LogOperation{
+			{
+				OpType: lockDeleteOp,
+				Key:    l.key,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// A single node cluster has no one to transfer leadership to, in which
+	// case this node simply acquires the lock again
+	l.b.l.RLock()
+	err = l.b.raft.LeadershipTransfer().Error()
+	l.b.l.RUnlock()
+	if err != nil {
+		l.b.logger.Warn("failed to transfer raft leadership", "error", err)
+	}
+
+	return nil
+}
+
+// Value returns the value of the lock and if it is held
+func (l *RaftLock) Value() (bool, string, error) {
+	held, value := l.b.fsm.lockValue(l.key)
+	return held, value, nil
+}
+
+// bufferSink is an in-memory raft.SnapshotSink
+type bufferSink struct {
+	buf bytes.Buffer
+}
+
+func (s *bufferSink) Write(p []byte) (int, error) {
+	return s.buf.Write(p)
+}
+
+func (s *bufferSink) Close() error {
+	return nil
+}
+
+func (s *bufferSink) ID() string {
+	return "buffer"
+}
+
+func (s *bufferSink) Cancel() error {
+	return nil
+}
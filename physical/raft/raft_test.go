@@ -0,0 +1,304 @@
+package raft
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/helper/logging"
+	"github.com/hashicorp/vault/physical"
+)
+
+func getRaft(t testing.TB, bootstrap bool) (*RaftBackend, string) {
+	raftDir, err := ioutil.TempDir("", "vault-raft-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := logging.NewVaultLogger(log.Debug)
+
+	conf := map[string]string{
+		"path":    raftDir,
+		"address": "127.0.0.1:0",
+	}
+
+	backendRaw, err := NewRaftBackend(conf, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := backendRaw.(*RaftBackend)
+
+	if bootstrap {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := backend.Bootstrap(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return backend, raftDir
+}
+
+func waitForApplied(t testing.TB, leader, follower *RaftBackend) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for follower.fsm.LatestIndex() < leader.fsm.LatestIndex() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for follower to catch up")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestRaft_Backend(t *testing.T) {
+	b, dir := getRaft(t, true)
+	defer os.RemoveAll(dir)
+	defer b.TeardownCluster()
+
+	physical.ExerciseBackend(t, b)
+	physical.ExerciseBackend_ListPrefix(t, b)
+}
+
+func TestRaft_TransactionalBackend(t *testing.T) {
+	b, dir := getRaft(t, true)
+	defer os.RemoveAll(dir)
+	defer b.TeardownCluster()
+
+	physical.ExerciseTransactionalBackend(t, b)
+}
+
+func TestRaft_NotInitialized(t *testing.T) {
+	b, dir := getRaft(t, false)
+	defer os.RemoveAll(dir)
+	defer b.TeardownCluster()
+
+	err := b.Put(context.Background(), &physical.Entry{Key: "foo", Value: []byte("bar")})
+	if err != ErrNotInitialized {
+		t.Fatalf("expected not initialized error, got: %v", err)
+	}
+}
+
+func TestRaft_Recovery(t *testing.T) {
+	b, dir := getRaft(t, true)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		if err := b.Put(ctx, &physical.Entry{Key: fmt.Sprintf("key-%d", i), Value: []byte("value")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Delete(ctx, "key-0"); err != nil {
+		t.Fatal(err)
+	}
+	nodeID := b.NodeID()
+	if err := b.TeardownCluster(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopen the same directory; the state should be replayed from the
+	// journal
+	backendRaw, err := NewRaftBackend(map[string]string{"path": dir, "address": "127.0.0.1:0"}, logging.NewVaultLogger(log.Debug))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b2 := backendRaw.(*RaftBackend)
+	defer b2.TeardownCluster()
+
+	if !b2.Initialized() {
+		t.Fatal("expected existing state to be detected")
+	}
+	if b2.NodeID() != nodeID {
+		t.Fatalf("node ID mismatch: %q vs %q", b2.NodeID(), nodeID)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		keys, err := b2.List(ctx, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) == 9 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 9 keys after recovery, got %d", len(keys))
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestRaft_Cluster(t *testing.T) {
+	ctx := context.Background()
+
+	leader, dir := getRaft(t, true)
+	defer os.RemoveAll(dir)
+	defer leader.TeardownCluster()
+
+	var followers []*RaftBackend
+	for i := 0; i < 2; i++ {
+		follower, dir := getRaft(t, false)
+		defer os.RemoveAll(dir)
+		defer follower.TeardownCluster()
+
+		if err := follower.SetupCluster(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := leader.AddPeer(ctx, follower.NodeID(), follower.Addr()); err != nil {
+			t.Fatal(err)
+		}
+		followers = append(followers, follower)
+	}
+
+	peers, err := leader.Peers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 3 {
+		t.Fatalf("expected 3 peers, got %#v", peers)
+	}
+
+	if err := leader.Put(ctx, &physical.Entry{Key: "foo", Value: []byte("bar")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writes on followers must fail, reads must see replicated data
+	for _, follower := range followers {
+		if err := follower.Put(ctx, &physical.Entry{Key: "foo", Value: []byte("baz")}); err == nil {
+			t.Fatal("expected error writing to follower")
+		}
+
+		waitForApplied(t, leader, follower)
+		entry, err := follower.Get(ctx, "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry == nil || string(entry.Value) != "bar" {
+			t.Fatalf("bad entry on follower: %#v", entry)
+		}
+	}
+
+	// The lock is only available on the leader
+	lock, err := leader.LockWith("core/lock", "leader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaderCh, err := lock.Lock(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaderCh == nil {
+		t.Fatal("expected leader channel")
+	}
+
+	followerLock, err := followers[0].LockWith("core/lock", "follower")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopCh := make(chan struct{})
+	time.AfterFunc(500*time.Millisecond, func() { close(stopCh) })
+	followerCh, err := followerLock.Lock(stopCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if followerCh != nil {
+		t.Fatal("follower should not acquire the lock")
+	}
+
+	waitForApplied(t, leader, followers[0])
+	held, value, err := followerLock.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !held || value != "leader" {
+		t.Fatalf("bad lock value: %t %q", held, value)
+	}
+
+	// Remove a follower
+	if err := leader.RemovePeer(ctx, followers[1].NodeID()); err != nil {
+		t.Fatal(err)
+	}
+	peers, err = leader.Peers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 2 {
+		t.Fatalf("expected 2 peers, got %#v", peers)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-leaderCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("leader channel not closed after unlock")
+	}
+
+	// Unlocking hands leadership, and so the lock, over to the follower
+	stopCh = make(chan struct{})
+	timer := time.AfterFunc(10*time.Second, func() { close(stopCh) })
+	defer timer.Stop()
+	followerCh, err = followerLock.Lock(stopCh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if followerCh == nil {
+		t.Fatal("follower should acquire the lock after the leader unlocks")
+	}
+	if leader.IsLeader() {
+		t.Fatal("expected leadership to be transferred")
+	}
+}
+
+func TestRaft_Snapshot(t *testing.T) {
+	ctx := context.Background()
+
+	b, dir := getRaft(t, true)
+	defer os.RemoveAll(dir)
+	defer b.TeardownCluster()
+
+	if err := b.Put(ctx, &physical.Entry{Key: "foo", Value: []byte("bar")}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := b.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Put(ctx, &physical.Entry{Key: "foo", Value: []byte("baz")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Put(ctx, &physical.Entry{Key: "other", Value: []byte("value")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.RestoreSnapshot(ctx, buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := b.Get(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry == nil || string(entry.Value) != "bar" {
+		t.Fatalf("bad entry after restore: %#v", entry)
+	}
+	entry, err = b.Get(ctx, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry != nil {
+		t.Fatalf("expected no entry after restore, got %#v", entry)
+	}
+
+	if err := b.RestoreSnapshot(ctx, []byte("not a snapshot")); err == nil {
+		t.Fatal("expected error restoring invalid snapshot")
+	}
+}
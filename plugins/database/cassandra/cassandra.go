@@ -195,6 +195,11 @@ func (c *Cassandra) RevokeUser(ctx context.Context, statements dbplugin.Statemen
 	return result.ErrorOrNil()
 }
 
+// SetCredentials is not currently supported on Cassandra
+func (c *Cassandra) SetCredentials(ctx context.Context, statements dbplugin.Statements, staticUser dbplugin.StaticUserConfig) (username, password string, err error) {
+	return "", "", dbplugin.ErrPluginStaticUnsupported
+}
+
 func (c *Cassandra) RotateRootCredentials(ctx context.Context, statements []string) (map[string]interface{}, error) {
 	// Grab the lock
 	c.Lock()
@@ -28,6 +28,10 @@ const (
 		ALTER USER '{{username}}'@'%' IDENTIFIED BY '{{password}}';
 	`
 
+	defaultMySQLRotateCredentialsSQL = `
+		ALTER USER '{{username}}'@'%' IDENTIFIED BY '{{password}}';
+	`
+
 	mySQLTypeName = "mysql"
 )
 
@@ -256,6 +260,57 @@ func (m *MySQL) RevokeUser(ctx context.Context, statements dbplugin.Statements,
 	return nil
 }
 
+// SetCredentials sets the password of an existing database user. It is used
+// to rotate the credentials of static roles.
+func (m *MySQL) SetCredentials(ctx context.Context, statements dbplugin.Statements, staticUser dbplugin.StaticUserConfig) (username, password string, err error) {
+	if len(staticUser.Username) == 0 || len(staticUser.Password) == 0 {
+		return "", "", errors.New("username and password are required to set credentials")
+	}
+
+	rotateStmts := statements.Rotation
+	if len(rotateStmts) == 0 {
+		rotateStmts = []string{defaultMySQLRotateCredentialsSQL}
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	db, err := m.getConnection(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range rotateStmts {
+		for _, query := range strutil.ParseArbitraryStringSlice(stmt, ";") {
+			query = strings.TrimSpace(query)
+			if len(query) == 0 {
+				continue
+			}
+
+			vars := map[string]string{
+				"name":     staticUser.Username,
+				"username": staticUser.Username,
+				"password": staticUser.Password,
+			}
+			if err := dbtxn.ExecuteTxQuery(ctx, tx, vars, query); err != nil {
+				return "", "", err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", "", err
+	}
+
+	return staticUser.Username, staticUser.Password, nil
+}
+
 func (m *MySQL) RotateRootCredentials(ctx context.Context, statements []string) (map[string]interface{}, error) {
 	m.Lock()
 	defer m.Unlock()
@@ -244,6 +244,55 @@ func TestMySQL_RotateRootCredentials(t *testing.T) {
 	}
 }
 
+func TestMySQL_SetCredentials(t *testing.T) {
+	cleanup, connURL := prepareMySQLTestContainer(t, false)
+	defer cleanup()
+
+	connectionDetails := map[string]interface{}{
+		"connection_url": connURL,
+	}
+
+	db := new(MetadataLen, MetadataLen, UsernameLen)
+	_, err := db.Init(context.Background(), connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	usernameConfig := dbplugin.UsernameConfig{
+		DisplayName: "test",
+		RoleName:    "test",
+	}
+
+	statements := dbplugin.Statements{
+		Creation: []string{testMySQLRoleWildCard},
+	}
+
+	// Create the user that will be managed as a static account
+	username, password, err := db.CreateUser(context.Background(), statements, usernameConfig, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := testCredsExist(t, connURL, username, password); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+
+	staticUser := dbplugin.StaticUserConfig{
+		Username: username,
+		Password: "new-password",
+	}
+
+	_, newPassword, err := db.SetCredentials(context.Background(), dbplugin.Statements{}, staticUser)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := testCredsExist(t, connURL, username, newPassword); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+	if err := testCredsExist(t, connURL, username, password); err == nil {
+		t.Fatal("Should not be able to connect with the old credentials")
+	}
+}
+
 func TestMySQL_RevokeUser(t *testing.T) {
 	cleanup, connURL := prepareMySQLTestContainer(t, false)
 	defer cleanup()
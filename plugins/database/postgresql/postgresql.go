@@ -27,6 +27,9 @@ ALTER ROLE "{{name}}" VALID UNTIL '{{expiration}}';
 `
 	defaultPostgresRotateRootCredentialsSQL = `
 ALTER ROLE "{{username}}" WITH PASSWORD '{{password}}';
+`
+	defaultPostgresRotateCredentialsSQL = `
+ALTER ROLE "{{name}}" WITH PASSWORD '{{password}}';
 `
 )
 
@@ -366,6 +369,59 @@ func (p *PostgreSQL) defaultRevokeUser(ctx context.Context, username string) err
 	return nil
 }
 
+// SetCredentials sets the password of an existing database user. It is used
+// to rotate the credentials of static roles.
+func (p *PostgreSQL) SetCredentials(ctx context.Context, statements dbplugin.Statements, staticUser dbplugin.StaticUserConfig) (username, password string, err error) {
+	if len(staticUser.Username) == 0 || len(staticUser.Password) == 0 {
+		return "", "", errors.New("username and password are required to set credentials")
+	}
+
+	rotateStmts := statements.Rotation
+	if len(rotateStmts) == 0 {
+		rotateStmts = []string{defaultPostgresRotateCredentialsSQL}
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	db, err := p.getConnection(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer func() {
+		tx.Rollback()
+	}()
+
+	for _, stmt := range rotateStmts {
+		for _, query := range strutil.ParseArbitraryStringSlice(stmt, ";") {
+			query = strings.TrimSpace(query)
+			if len(query) == 0 {
+				continue
+			}
+
+			m := map[string]string{
+				"name":     staticUser.Username,
+				"username": staticUser.Username,
+				"password": staticUser.Password,
+			}
+			if err := dbtxn.ExecuteTxQuery(ctx, tx, m, query); err != nil {
+				return "", "", err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", "", err
+	}
+
+	return staticUser.Username, staticUser.Password, nil
+}
+
 func (p *PostgreSQL) RotateRootCredentials(ctx context.Context, statements []string) (map[string]interface{}, error) {
 	p.Lock()
 	defer p.Unlock()
@@ -256,6 +256,67 @@ func TestPostgreSQL_RotateRootCredentials(t *testing.T) {
 	}
 }
 
+func TestPostgreSQL_SetCredentials(t *testing.T) {
+	cleanup, connURL := preparePostgresTestContainer(t)
+	defer cleanup()
+
+	connectionDetails := map[string]interface{}{
+		"connection_url": connURL,
+	}
+
+	db := new()
+	_, err := db.Init(context.Background(), connectionDetails, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	usernameConfig := dbplugin.UsernameConfig{
+		DisplayName: "test",
+		RoleName:    "test",
+	}
+
+	statements := dbplugin.Statements{
+		Creation: []string{testPostgresRole},
+	}
+
+	// Create the user that will be managed as a static account
+	username, password, err := db.CreateUser(context.Background(), statements, usernameConfig, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err = testCredsExist(t, connURL, username, password); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+
+	staticUser := dbplugin.StaticUserConfig{
+		Username: username,
+		Password: "new-password",
+	}
+
+	// Test with the default rotation statements
+	_, newPassword, err := db.SetCredentials(context.Background(), dbplugin.Statements{}, staticUser)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err = testCredsExist(t, connURL, username, newPassword); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+	if err = testCredsExist(t, connURL, username, password); err == nil {
+		t.Fatal("Should not be able to connect with the old credentials")
+	}
+
+	// Test with custom rotation statements
+	staticUser.Password = "other-password"
+	statements.Rotation = []string{`ALTER ROLE "{{name}}" WITH PASSWORD '{{password}}';`}
+	_, newPassword, err = db.SetCredentials(context.Background(), statements, staticUser)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err = testCredsExist(t, connURL, username, newPassword); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+}
+
 func TestPostgreSQL_RevokeUser(t *testing.T) {
 	cleanup, connURL := preparePostgresTestContainer(t)
 	defer cleanup()
@@ -169,12 +169,15 @@ export default Component.extend(DEFAULTS, {
 
   authenticate: task(function*(backendType, data) {
     let clusterId = this.cluster.id;
-    let targetRoute = this.redirectTo || 'vault.cluster';
     try {
       let authResponse = yield this.auth.authenticate({ clusterId, backend: backendType, data });
 
       let { isRoot, namespace } = authResponse;
-      let transition = this.router.transitionTo(targetRoute, { queryParams: { namespace } });
+      // redirectTo is the URL of the page that required logging in, which
+      // already holds its namespace
+      let transition = this.redirectTo
+        ? this.router.transitionTo(this.redirectTo)
+        : this.router.transitionTo('vault.cluster', { queryParams: { namespace } });
       // returning this w/then because if we keep it
       // in the task, it will get cancelled when the component in un-rendered
       return transition.followRedirects().then(() => {
@@ -0,0 +1,16 @@
+import Controller from '@ember/controller';
+
+export const OIDC_PARAMS = [
+  'client_id',
+  'scope',
+  'redirect_uri',
+  'response_type',
+  'state',
+  'nonce',
+  'code_challenge',
+  'code_challenge_method',
+];
+
+export default Controller.extend({
+  queryParams: OIDC_PARAMS,
+});
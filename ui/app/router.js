@@ -13,6 +13,7 @@ Router.map(function() {
       this.route('init');
       this.route('logout');
       this.route('license');
+      this.route('oidc-provider', { path: '/identity/oidc/provider/:provider_name/authorize' });
       this.route('settings', function() {
         this.route('index', { path: '/' });
         this.route('seal');
@@ -22,6 +22,7 @@ export default ClusterRouteBase.extend({
   resetController(controller) {
     controller.set('wrappedToken', '');
     controller.set('authMethod', '');
+    controller.set('redirectTo', null);
   },
 
   afterModel() {
@@ -0,0 +1,62 @@
+import { inject as service } from '@ember/service';
+import Route from '@ember/routing/route';
+import fetch from 'fetch';
+import ClusterRoute, { AUTH } from 'vault/mixins/cluster-route';
+import { OIDC_PARAMS } from 'vault/controllers/vault/cluster/oidc-provider';
+
+// The authorization endpoint of the identity OIDC providers. Users log in to
+// Vault, and the authorization request is sent to the API with their token.
+// The result is passed on to the client's redirect URI.
+export default Route.extend(ClusterRoute, {
+  router: service(),
+
+  beforeModel(transition) {
+    if (this.targetRouteName() === AUTH) {
+      let url =
+        transition.intent.url ||
+        this.router.urlFor(this.routeName, transition.params[this.routeName].provider_name, {
+          queryParams: transition.queryParams,
+        });
+      return this.transitionTo(AUTH, { queryParams: { redirect_to: url } });
+    }
+    return this.transitionToTargetRoute();
+  },
+
+  model(params) {
+    let query = OIDC_PARAMS.filter(key => params[key])
+      .map(key => `${encodeURIComponent(key)}=${encodeURIComponent(params[key])}`)
+      .join('&');
+    let url = `/v1/identity/oidc/provider/${encodeURIComponent(params.provider_name)}/authorize?${query}`;
+    let options = {};
+    this.store.adapterFor('application').addHeaders(url, options);
+    return fetch(url, { method: 'GET', headers: options.headers }).then(response => {
+      return response.json().then(body => ({
+        status: response.status,
+        body,
+        redirectURI: params.redirect_uri,
+      }));
+    });
+  },
+
+  afterModel(model) {
+    let { body, redirectURI } = model;
+    // Errors in the body of Vault's responses are about the client or its
+    // redirect URI, so they cannot be sent to the client
+    if (body.errors) {
+      return;
+    }
+    let result = body.error
+      ? { error: body.error, error_description: body.error_description, state: body.state }
+      : { code: body.code, state: body.state };
+    let query = Object.keys(result)
+      .filter(key => result[key])
+      .map(key => `${encodeURIComponent(key)}=${encodeURIComponent(result[key])}`)
+      .join('&');
+    let separator = redirectURI.includes('?') ? '&' : '?';
+    this.redirectToClient(`${redirectURI}${separator}${query}`);
+  },
+
+  redirectToClient(url) {
+    window.location.replace(url);
+  },
+});
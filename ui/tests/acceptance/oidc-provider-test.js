@@ -0,0 +1,101 @@
+import { module, test } from 'qunit';
+import { setupApplicationTest } from 'ember-qunit';
+import { currentURL, visit, settled } from '@ember/test-helpers';
+import sinon from 'sinon';
+import authPage from 'vault/tests/pages/auth';
+import logout from 'vault/tests/pages/logout';
+
+const REDIRECT_URI = 'https://app.example.com/callback';
+
+// api calls the Vault API with the root token of the test server
+const api = async function(path, method = 'GET', data) {
+  let response = await fetch(`/v1/${path}`, {
+    method,
+    headers: { 'X-Vault-Token': 'root' },
+    body: data ? JSON.stringify(data) : undefined,
+  });
+  return response.status === 204 ? null : response.json();
+};
+
+module('Acceptance | oidc provider', function(hooks) {
+  setupApplicationTest(hooks);
+
+  hooks.beforeEach(async function() {
+    this.suffix = `${Date.now()}`;
+    let userpass = `userpass-${this.suffix}`;
+    await api(`sys/auth/${userpass}`, 'POST', { type: 'userpass' });
+    await api(`auth/${userpass}/users/alice`, 'POST', { password: 'password' });
+    let login = await api(`auth/${userpass}/login/alice`, 'POST', { password: 'password' });
+    this.token = login.auth.client_token;
+
+    await api(`identity/oidc/key/key-${this.suffix}`, 'POST', { algorithm: 'RS256' });
+    await api(`identity/oidc/assignment/assignment-${this.suffix}`, 'POST', {
+      entity_ids: login.auth.entity_id,
+    });
+    await api(`identity/oidc/client/client-${this.suffix}`, 'POST', {
+      key: `key-${this.suffix}`,
+      redirect_uris: REDIRECT_URI,
+      assignments: `assignment-${this.suffix}`,
+    });
+    let client = await api(`identity/oidc/client/client-${this.suffix}`);
+    this.clientID = client.data.client_id;
+    await api(`identity/oidc/provider/provider-${this.suffix}`, 'POST', {
+      allowed_client_ids: this.clientID,
+    });
+
+    this.redirect = sinon.stub();
+    this.owner.lookup('route:vault.cluster.oidc-provider').redirectToClient = this.redirect;
+    return logout.visit();
+  });
+
+  hooks.afterEach(function() {
+    return logout.visit();
+  });
+
+  test('it follows the authorization endpoint of the discovery document', async function(assert) {
+    let discovery = await api(
+      `identity/oidc/provider/provider-${this.suffix}/.well-known/openid-configuration`
+    );
+    let endpoint = new URL(discovery.authorization_endpoint);
+    assert.ok(endpoint.pathname.startsWith('/ui/'), 'the authorization endpoint is served by the UI');
+
+    let query = `client_id=${this.clientID}&scope=openid&redirect_uri=${encodeURIComponent(
+      REDIRECT_URI
+    )}&response_type=code&state=abc`;
+    let url = `${endpoint.pathname.replace(/^\/ui/, '')}?${query}`;
+    await visit(url);
+    assert.ok(currentURL().startsWith('/vault/auth'), 'users log in first');
+
+    await authPage.login(this.token);
+    await settled();
+    assert.ok(this.redirect.calledOnce, 'the user is redirected to the client');
+    let redirect = new URL(this.redirect.firstCall.args[0]);
+    assert.equal(`${redirect.origin}${redirect.pathname}`, REDIRECT_URI, 'the redirect URI is used');
+    assert.ok(redirect.searchParams.get('code'), 'an authorization code is sent');
+    assert.equal(redirect.searchParams.get('state'), 'abc', 'the state is sent');
+  });
+
+  test('it sends authorization errors to the client', async function(assert) {
+    await authPage.login(this.token);
+    await visit(
+      `/vault/identity/oidc/provider/provider-${this.suffix}/authorize?client_id=${
+        this.clientID
+      }&scope=email&redirect_uri=${encodeURIComponent(REDIRECT_URI)}&response_type=code&state=abc`
+    );
+    assert.ok(this.redirect.calledOnce, 'the user is redirected to the client');
+    let redirect = new URL(this.redirect.firstCall.args[0]);
+    assert.equal(redirect.searchParams.get('error'), 'invalid_scope', 'the error is sent');
+    assert.equal(redirect.searchParams.get('state'), 'abc', 'the state is sent');
+  });
+
+  test('it does not redirect to unknown redirect URIs', async function(assert) {
+    await authPage.login(this.token);
+    await visit(
+      `/vault/identity/oidc/provider/provider-${this.suffix}/authorize?client_id=${
+        this.clientID
+      }&scope=openid&redirect_uri=${encodeURIComponent('https://evil.example.com')}&response_type=code`
+    );
+    assert.ok(this.redirect.notCalled, 'the user is not redirected');
+    assert.dom('[data-test-oidc-provider-error]').exists('the error is shown');
+  });
+});
@@ -5,10 +5,32 @@ package vault
 import (
 	"context"
 
+	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/helper/identity"
 	"github.com/hashicorp/vault/logical"
 )
 
 func (c *Core) performEntPolicyChecks(ctx context.Context, acl *ACL, te *logical.TokenEntry, req *logical.Request, inEntity *identity.Entity, opts *PolicyCheckOpts, ret *AuthResults) {
 	ret.Allowed = true
+
+	// Enforce the MFA methods that the policies require on the path. Requests
+	// replayed by a control group were validated when they were first made.
+	if ret.ACLResults != nil && len(ret.ACLResults.MFAMethods) > 0 && !isControlGroupRun(req) {
+		if err := c.systemBackend.validateMFA(ctx, ret.ACLResults.MFAMethods, req, inEntity); err != nil {
+			ret.Allowed = false
+			ret.DeniedError = true
+			ret.Error = multierror.Append(ret.Error, err)
+			return
+		}
+	}
+
+	// Hold back requests to paths requiring a control group until they have
+	// been approved. The approved request is replayed as a control group run.
+	if ret.ACLResults != nil && ret.ACLResults.ControlGroup != nil &&
+		len(ret.ACLResults.ControlGroup.Factors) > 0 && !isControlGroupRun(req) {
+		ret.Allowed = false
+		ret.Error = multierror.Append(ret.Error, &errControlGroupRequired{
+			ControlGroup: ret.ACLResults.ControlGroup,
+		})
+	}
 }
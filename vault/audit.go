@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/hashicorp/go-uuid"
@@ -55,6 +56,11 @@ func (c *Core) enableAudit(ctx context.Context, entry *MountEntry, updateStorage
 	c.auditLock.Lock()
 	defer c.auditLock.Unlock()
 
+	filter, fallback, err := auditEntryFilter(entry)
+	if err != nil {
+		return err
+	}
+
 	// Look for matching name
 	for _, ent := range c.audit.Entries {
 		switch {
@@ -65,6 +71,10 @@ func (c *Core) enableAudit(ctx context.Context, entry *MountEntry, updateStorage
 		case strings.HasPrefix(entry.Path, ent.Path):
 			return fmt.Errorf("path already in use")
 		}
+
+		if _, entFallback, _ := auditEntryFilter(ent); fallback && entFallback {
+			return fmt.Errorf("a fallback audit backend is already enabled at %q", ent.Path)
+		}
 	}
 
 	// Generate a new UUID and view
@@ -121,7 +131,7 @@ func (c *Core) enableAudit(ctx context.Context, entry *MountEntry, updateStorage
 	c.audit = newTable
 
 	// Register the backend
-	c.auditBroker.Register(entry.Path, backend, view, entry.Local)
+	c.auditBroker.Register(entry.Path, backend, view, entry.Local, filter, fallback)
 	if c.logger.IsInfo() {
 		c.logger.Info("enabled audit backend", "path", entry.Path, "type", entry.Type)
 	}
@@ -346,6 +356,7 @@ func (c *Core) setupAudits(ctx context.Context) error {
 	brokerLogger := c.baseLogger.Named("audit")
 	c.AddLogger(brokerLogger)
 	broker := NewAuditBroker(brokerLogger)
+	broker.matchingMountEntry = c.router.MatchingMountEntry
 
 	c.auditLock.Lock()
 	defer c.auditLock.Unlock()
@@ -367,6 +378,12 @@ func (c *Core) setupAudits(ctx context.Context) error {
 			view.setReadOnlyErr(origViewReadOnlyErr)
 		})
 
+		filter, fallback, err := auditEntryFilter(entry)
+		if err != nil {
+			c.logger.Error("failed to parse audit entry filter", "path", entry.Path, "error", err)
+			continue
+		}
+
 		// Initialize the backend
 		backend, err := c.newAuditBackend(ctx, entry, view, entry.Options)
 		if err != nil {
@@ -379,7 +396,7 @@ func (c *Core) setupAudits(ctx context.Context) error {
 		}
 
 		// Mount the backend
-		broker.Register(entry.Path, backend, view, entry.Local)
+		broker.Register(entry.Path, backend, view, entry.Local, filter, fallback)
 
 		successCount++
 	}
@@ -405,6 +422,10 @@ func (c *Core) teardownAudits() error {
 		}
 	}
 
+	if c.auditBroker != nil {
+		c.auditBroker.DeregisterAll()
+	}
+
 	c.audit = nil
 	c.auditBroker = nil
 	return nil
@@ -414,8 +435,8 @@ func (c *Core) teardownAudits() error {
 // audit lock needs to be held before calling this.
 func (c *Core) removeAuditReloadFunc(entry *MountEntry) {
 	switch entry.Type {
-	case "file":
-		key := "audit_file|" + entry.Path
+	case "file", "http":
+		key := "audit_" + entry.Type + "|" + entry.Path
 		c.reloadFuncsLock.Lock()
 
 		if c.logger.IsDebug() {
@@ -428,6 +449,34 @@ func (c *Core) removeAuditReloadFunc(entry *MountEntry) {
 	}
 }
 
+// auditEntryFilter returns the filter of an audit entry, set with the filter
+// option, and whether it is the fallback entry, set with the fallback option.
+func auditEntryFilter(entry *MountEntry) (*audit.Filter, bool, error) {
+	var filter *audit.Filter
+	if expr := entry.Options["filter"]; expr != "" {
+		var err error
+		filter, err = audit.NewFilter(expr)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid filter: %v", err)
+		}
+	}
+
+	var fallback bool
+	if raw, ok := entry.Options["fallback"]; ok {
+		var err error
+		fallback, err = strconv.ParseBool(raw)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid fallback value %q", raw)
+		}
+	}
+
+	if filter != nil && fallback {
+		return nil, false, errors.New("the fallback audit backend cannot have a filter")
+	}
+
+	return filter, fallback, nil
+}
+
 // newAuditBackend is used to create and configure a new audit backend by name
 func (c *Core) newAuditBackend(ctx context.Context, entry *MountEntry, view logical.Storage, conf map[string]string) (audit.Backend, error) {
 	f, ok := c.auditBackends[entry.Type]
@@ -475,6 +524,26 @@ func (c *Core) newAuditBackend(ctx context.Context, entry *MountEntry, view logi
 			return be.Reload(ctx)
 		})
 
+		c.reloadFuncsLock.Unlock()
+	case "http":
+		key := "audit_http|" + entry.Path
+
+		c.reloadFuncsLock.Lock()
+
+		if auditLogger.IsDebug() {
+			auditLogger.Debug("adding reload function", "path", entry.Path)
+			if entry.Options != nil {
+				auditLogger.Debug("http backend options", "path", entry.Path, "url", entry.Options["url"], "blocking", entry.Options["blocking"])
+			}
+		}
+
+		c.reloadFuncs[key] = append(c.reloadFuncs[key], func(map[string]interface{}) error {
+			if auditLogger.IsInfo() {
+				auditLogger.Info("reloading http audit backend", "path", entry.Path)
+			}
+			return be.Reload(ctx)
+		})
+
 		c.reloadFuncsLock.Unlock()
 	case "socket":
 		if auditLogger.IsDebug() {
@@ -3,6 +3,9 @@ package vault
 import (
 	"context"
 	"fmt"
+	"io"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,12 +13,16 @@ import (
 	log "github.com/hashicorp/go-hclog"
 	multierror "github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/logical"
 )
 
 type backendEntry struct {
-	backend audit.Backend
-	view    *BarrierView
-	local   bool
+	backend  audit.Backend
+	view     *BarrierView
+	local    bool
+	filter   *audit.Filter
+	fallback bool
 }
 
 // AuditBroker is used to provide a single ingest interface to auditable
@@ -24,6 +31,10 @@ type AuditBroker struct {
 	sync.RWMutex
 	backends map[string]backendEntry
 	logger   log.Logger
+
+	// matchingMountEntry resolves the mounts of requests for filters, as
+	// requests are audited before they are routed
+	matchingMountEntry func(context.Context, string) *MountEntry
 }
 
 // NewAuditBroker creates a new audit broker
@@ -35,24 +46,52 @@ func NewAuditBroker(log log.Logger) *AuditBroker {
 	return b
 }
 
-// Register is used to add new audit backend to the broker
-func (a *AuditBroker) Register(name string, b audit.Backend, v *BarrierView, local bool) {
+// Register is used to add new audit backend to the broker. A backend with a
+// filter only logs the entries matching it, and the fallback backend only logs
+// the entries that no other backend logs due to their filters.
+func (a *AuditBroker) Register(name string, b audit.Backend, v *BarrierView, local bool, filter *audit.Filter, fallback bool) {
 	a.Lock()
 	defer a.Unlock()
 	a.backends[name] = backendEntry{
-		backend: b,
-		view:    v,
-		local:   local,
+		backend:  b,
+		view:     v,
+		local:    local,
+		filter:   filter,
+		fallback: fallback,
 	}
 }
 
-// Deregister is used to remove an audit backend from the broker
+// Deregister is used to remove an audit backend from the broker. Backends
+// that run in the background are closed.
 func (a *AuditBroker) Deregister(name string) {
 	a.Lock()
 	defer a.Unlock()
+	if be, ok := a.backends[name]; ok {
+		a.closeBackend(name, be)
+	}
 	delete(a.backends, name)
 }
 
+// DeregisterAll is used to remove all audit backends from the broker
+func (a *AuditBroker) DeregisterAll() {
+	a.Lock()
+	defer a.Unlock()
+	for name, be := range a.backends {
+		a.closeBackend(name, be)
+	}
+	a.backends = make(map[string]backendEntry)
+}
+
+func (a *AuditBroker) closeBackend(name string, be backendEntry) {
+	closer, ok := be.backend.(io.Closer)
+	if !ok {
+		return
+	}
+	if err := closer.Close(); err != nil {
+		a.logger.Error("failed to close audit backend", "backend", name, "error", err)
+	}
+}
+
 // IsRegistered is used to check if a given audit backend is registered
 func (a *AuditBroker) IsRegistered(name string) bool {
 	a.RLock()
@@ -84,6 +123,21 @@ func (a *AuditBroker) GetHash(ctx context.Context, name string, input string) (s
 	return be.backend.GetHash(ctx, input)
 }
 
+// auditRoutingKey is the context key of the audit backends that logged a
+// request, so that its response is logged by the same backends
+type auditRoutingKey struct{}
+
+type auditRouting struct {
+	logged   bool
+	backends []string
+}
+
+// contextWithAuditRouting returns a context in which the audit backends that
+// log a request are recorded for its response
+func contextWithAuditRouting(ctx context.Context) context.Context {
+	return context.WithValue(ctx, auditRoutingKey{}, &auditRouting{})
+}
+
 // LogRequest is used to ensure all the audit backends have an opportunity to
 // log the given request and that *at least one* succeeds.
 func (a *AuditBroker) LogRequest(ctx context.Context, in *audit.LogInput, headersConfig *AuditedHeadersConfig) (ret error) {
@@ -121,7 +175,15 @@ func (a *AuditBroker) LogRequest(ctx context.Context, in *audit.LogInput, header
 
 	// Ensure at least one backend logs
 	anyLogged := false
-	for name, be := range a.backends {
+	backends := a.filteredBackends(ctx, in, in.OuterErr != nil)
+	if routing, ok := ctx.Value(auditRoutingKey{}).(*auditRouting); ok && len(backends) > 0 {
+		routing.logged = true
+		routing.backends = make([]string, 0, len(backends))
+		for name := range backends {
+			routing.backends = append(routing.backends, name)
+		}
+	}
+	for name, be := range backends {
 		in.Request.Headers = nil
 		transHeaders, thErr := headersConfig.ApplyConfig(ctx, headers, be.backend.GetHash)
 		if thErr != nil {
@@ -139,7 +201,7 @@ func (a *AuditBroker) LogRequest(ctx context.Context, in *audit.LogInput, header
 			anyLogged = true
 		}
 	}
-	if !anyLogged && len(a.backends) > 0 {
+	if !anyLogged && len(backends) > 0 {
 		retErr = multierror.Append(retErr, fmt.Errorf("no audit backend succeeded in logging the request"))
 	}
 
@@ -177,7 +239,8 @@ func (a *AuditBroker) LogResponse(ctx context.Context, in *audit.LogInput, heade
 
 	// Ensure at least one backend logs
 	anyLogged := false
-	for name, be := range a.backends {
+	backends := a.responseBackends(ctx, in)
+	for name, be := range backends {
 		in.Request.Headers = nil
 		transHeaders, thErr := headersConfig.ApplyConfig(ctx, headers, be.backend.GetHash)
 		if thErr != nil {
@@ -195,13 +258,100 @@ func (a *AuditBroker) LogResponse(ctx context.Context, in *audit.LogInput, heade
 			anyLogged = true
 		}
 	}
-	if !anyLogged && len(a.backends) > 0 {
+	if !anyLogged && len(backends) > 0 {
 		retErr = multierror.Append(retErr, fmt.Errorf("no audit backend succeeded in logging the response"))
 	}
 
 	return retErr.ErrorOrNil()
 }
 
+// filteredBackends returns the backends that should log the entry: those
+// without a filter and those with a matching filter, or, if there are none, the
+// fallback backend. The caller must hold the read lock.
+func (a *AuditBroker) filteredBackends(ctx context.Context, in *audit.LogInput, isError bool) map[string]backendEntry {
+	var filtered bool
+	for _, be := range a.backends {
+		if be.filter != nil || be.fallback {
+			filtered = true
+			break
+		}
+	}
+	if !filtered {
+		return a.backends
+	}
+
+	fields := a.filterFields(ctx, in.Request, isError)
+	backends := make(map[string]backendEntry, len(a.backends))
+	for name, be := range a.backends {
+		if !be.fallback && (be.filter == nil || be.filter.Matches(fields)) {
+			backends[name] = be
+		}
+	}
+	if len(backends) > 0 {
+		return backends
+	}
+
+	for name, be := range a.backends {
+		if be.fallback {
+			backends[name] = be
+			metrics.IncrCounter([]string{"audit", "fallback"}, 1)
+		}
+	}
+	return backends
+}
+
+// responseBackends returns the backends that should log a response: those
+// that logged its request, so that both are routed alike, or, if the request
+// was not logged, the filtered backends. The caller must hold the read lock.
+func (a *AuditBroker) responseBackends(ctx context.Context, in *audit.LogInput) map[string]backendEntry {
+	routing, ok := ctx.Value(auditRoutingKey{}).(*auditRouting)
+	if !ok || !routing.logged {
+		return a.filteredBackends(ctx, in, in.OuterErr != nil || (in.Response != nil && in.Response.IsError()))
+	}
+
+	backends := make(map[string]backendEntry, len(routing.backends))
+	for _, name := range routing.backends {
+		if be, ok := a.backends[name]; ok {
+			backends[name] = be
+		}
+	}
+	return backends
+}
+
+// filterFields returns the fields of the request that filters match on
+func (a *AuditBroker) filterFields(ctx context.Context, req *logical.Request, isError bool) map[string]string {
+	fields := map[string]string{
+		"operation":   string(req.Operation),
+		"path":        req.Path,
+		"mount_point": req.MountPoint,
+		"mount_type":  req.MountType,
+		"error":       strconv.FormatBool(isError),
+	}
+
+	if ns, err := namespace.FromContext(ctx); err == nil {
+		fields["namespace"] = ns.Path
+	}
+
+	if a.matchingMountEntry == nil {
+		return fields
+	}
+	if entry := a.matchingMountEntry(ctx, req.Path); entry != nil {
+		fields["mount_point"] = entry.Path
+		fields["mount_type"] = entry.Type
+	}
+
+	switch te := req.TokenEntry(); {
+	case te != nil && te.Path != "":
+		if entry := a.matchingMountEntry(ctx, te.Path); entry != nil {
+			fields["auth_method"] = entry.Type
+		}
+	case strings.HasPrefix(fields["mount_point"], credentialRoutePrefix):
+		fields["auth_method"] = fields["mount_type"]
+	}
+
+	return fields
+}
+
 func (a *AuditBroker) Invalidate(ctx context.Context, key string) {
 	// For now we ignore the key as this would only apply to salts. We just
 	// sort of brute force it on each one.
@@ -441,8 +441,8 @@ func TestAuditBroker_LogRequest(t *testing.T) {
 	b := NewAuditBroker(l)
 	a1 := &NoopAudit{}
 	a2 := &NoopAudit{}
-	b.Register("foo", a1, nil, false)
-	b.Register("bar", a2, nil, false)
+	b.Register("foo", a1, nil, false, nil, false)
+	b.Register("bar", a2, nil, false, nil, false)
 
 	auth := &logical.Auth{
 		ClientToken: "foo",
@@ -527,8 +527,8 @@ func TestAuditBroker_LogResponse(t *testing.T) {
 	b := NewAuditBroker(l)
 	a1 := &NoopAudit{}
 	a2 := &NoopAudit{}
-	b.Register("foo", a1, nil, false)
-	b.Register("bar", a2, nil, false)
+	b.Register("foo", a1, nil, false, nil, false)
+	b.Register("bar", a2, nil, false, nil, false)
 
 	auth := &logical.Auth{
 		NumUses:     10,
@@ -633,8 +633,8 @@ func TestAuditBroker_AuditHeaders(t *testing.T) {
 	view := NewBarrierView(barrier, "headers/")
 	a1 := &NoopAudit{}
 	a2 := &NoopAudit{}
-	b.Register("foo", a1, nil, false)
-	b.Register("bar", a2, nil, false)
+	b.Register("foo", a1, nil, false, nil, false)
+	b.Register("bar", a2, nil, false, nil, false)
 
 	auth := &logical.Auth{
 		ClientToken: "foo",
@@ -708,3 +708,120 @@ func TestAuditBroker_AuditHeaders(t *testing.T) {
 		t.Fatalf("err: %v", err)
 	}
 }
+
+func TestAuditBroker_Filter(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+
+	var backends []*NoopAudit
+	c.auditBackends["noop"] = func(ctx context.Context, config *audit.BackendConfig) (audit.Backend, error) {
+		b := &NoopAudit{
+			Config: config,
+		}
+		backends = append(backends, b)
+		return b, nil
+	}
+	enable := func(path string, options map[string]string) error {
+		return c.enableAudit(ctx, &MountEntry{
+			Table:   auditTableType,
+			Path:    path,
+			Type:    "noop",
+			Options: options,
+		}, true)
+	}
+
+	if err := enable("kv", map[string]string{"filter": "mount_type == kv and operation != read"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enable("errors", map[string]string{"filter": "error == true"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enable("bad", map[string]string{"filter": "mount_type =="}); err == nil {
+		t.Fatal("expected error for invalid filter")
+	}
+	if err := enable("bad", map[string]string{"filter": "error == true", "fallback": "true"}); err == nil {
+		t.Fatal("expected error for fallback with filter")
+	}
+	if err := enable("fallback", map[string]string{"fallback": "true"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enable("fallback2", map[string]string{"fallback": "true"}); err == nil {
+		t.Fatal("expected error for second fallback")
+	}
+	kv, errs, fallback := backends[0], backends[1], backends[2]
+
+	headersConf := &AuditedHeadersConfig{
+		Headers: make(map[string]*auditedHeaderSettings),
+	}
+	logRequest := func(op logical.Operation, path string, outerErr error) {
+		t.Helper()
+		err := c.auditBroker.LogRequest(ctx, &audit.LogInput{
+			Request: &logical.Request{
+				Operation: op,
+				Path:      path,
+			},
+			OuterErr: outerErr,
+		}, headersConf)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logRequest(logical.UpdateOperation, "secret/foo", nil)
+	logRequest(logical.ReadOperation, "secret/foo", errors.New("permission denied"))
+	logRequest(logical.ReadOperation, "secret/foo", nil)
+	logRequest(logical.ReadOperation, "sys/health", nil)
+
+	paths := func(b *NoopAudit) []string {
+		var paths []string
+		for _, req := range b.Req {
+			paths = append(paths, string(req.Operation)+" "+req.Path)
+		}
+		return paths
+	}
+	expected := map[*NoopAudit][]string{
+		kv:       []string{"update secret/foo"},
+		errs:     []string{"read secret/foo"},
+		fallback: []string{"read secret/foo", "read sys/health"},
+	}
+	for b, expectedPaths := range expected {
+		if actual := paths(b); !reflect.DeepEqual(actual, expectedPaths) {
+			t.Fatalf("expected %v, got %v", expectedPaths, actual)
+		}
+	}
+
+	// Responses are filtered on their errors
+	err := c.auditBroker.LogResponse(ctx, &audit.LogInput{
+		Request: &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "sys/health",
+		},
+		Response: logical.ErrorResponse("failed"),
+	}, headersConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs.Resp) != 1 || len(fallback.Resp) != 0 {
+		t.Fatalf("expected error response to be logged by the error backend only")
+	}
+
+	// Responses are logged by the backends that logged their request
+	req := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "sys/health",
+	}
+	ctx = contextWithAuditRouting(ctx)
+	if err := c.auditBroker.LogRequest(ctx, &audit.LogInput{Request: req}, headersConf); err != nil {
+		t.Fatal(err)
+	}
+	err = c.auditBroker.LogResponse(ctx, &audit.LogInput{
+		Request:  req,
+		Response: logical.ErrorResponse("failed"),
+	}, headersConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs.Resp) != 1 || len(fallback.Resp) != 1 {
+		t.Fatalf("expected error response to be logged by the fallback backend only")
+	}
+}
@@ -0,0 +1,357 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/helper/wrapping"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// controlGroupCubbyholePath is the path in the cubbyhole of a control
+	// group token where the pending request is stored
+	controlGroupCubbyholePath = "cubbyhole/control-group"
+
+	// controlGroupDefaultTTL is the TTL of a control group token when the
+	// policy does not specify one
+	controlGroupDefaultTTL = 24 * time.Hour
+)
+
+// errControlGroupRequired is returned by the policy checks when the request
+// can only be performed once it has been approved through a control group
+type errControlGroupRequired struct {
+	ControlGroup *ControlGroup
+}
+
+func (e *errControlGroupRequired) Error() string {
+	return "request requires control group authorization"
+}
+
+// controlGroupRequest is a request held back by a control group, along with
+// the authorizations it has collected so far
+type controlGroupRequest struct {
+	ID             string                       `json:"id"`
+	Path           string                       `json:"path"`
+	Operation      logical.Operation            `json:"operation"`
+	Data           map[string]interface{}       `json:"data"`
+	Headers        map[string][]string          `json:"headers"`
+	ClientToken    string                       `json:"client_token"`
+	EntityID       string                       `json:"entity_id"`
+	Factors        []*ControlGroupFactor        `json:"factors"`
+	Authorizations []*controlGroupAuthorization `json:"authorizations"`
+	CreationTime   time.Time                    `json:"creation_time"`
+}
+
+// controlGroupAuthorization records the approval of a control group request
+// by an entity, along with the factors the approval counts towards
+type controlGroupAuthorization struct {
+	EntityID   string    `json:"entity_id"`
+	EntityName string    `json:"entity_name"`
+	Factors    []string  `json:"factors"`
+	Time       time.Time `json:"time"`
+}
+
+// Approved returns whether every factor of the control group has collected
+// the number of approvals it requires
+func (cg *controlGroupRequest) Approved() bool {
+	for _, factor := range cg.Factors {
+		if factor.Identity == nil {
+			continue
+		}
+		var approvals int
+		for _, authz := range cg.Authorizations {
+			if strutil.StrListContains(authz.Factors, factor.Name) {
+				approvals++
+			}
+		}
+		if approvals < factor.Identity.ApprovalsRequired {
+			return false
+		}
+	}
+	return true
+}
+
+// checkNeedsControlGroup holds back a request that requires control group
+// authorization. Instead of routing it, the request is stored in the cubbyhole
+// of a new control group token which is returned to the caller as a wrapping
+// token.
+func (c *Core) checkNeedsControlGroup(ctx context.Context, req *logical.Request, auth *logical.Auth, ctErr error, nonHMACReqDataKeys []string) (error, *logical.Response, *logical.Auth, error) {
+	cgErr, ok := errwrap.GetType(ctErr, new(errControlGroupRequired)).(*errControlGroupRequired)
+	if !ok || cgErr == nil || auth == nil {
+		return nil, nil, nil, nil
+	}
+
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ttl := cgErr.ControlGroup.TTL
+	if ttl == 0 {
+		ttl = controlGroupDefaultTTL
+	}
+
+	creationTime := time.Now()
+	cgReq := &controlGroupRequest{
+		ID:           req.ID,
+		Path:         req.Path,
+		Operation:    req.Operation,
+		Data:         req.Data,
+		Headers:      req.Headers,
+		ClientToken:  req.ClientToken,
+		EntityID:     auth.EntityID,
+		Factors:      cgErr.ControlGroup.Factors,
+		CreationTime: creationTime,
+	}
+
+	te := logical.TokenEntry{
+		Path:           req.Path,
+		Policies:       []string{controlGroupPolicyName},
+		CreationTime:   creationTime.Unix(),
+		TTL:            ttl,
+		NumUses:        1,
+		ExplicitMaxTTL: ttl,
+		NamespaceID:    ns.ID,
+	}
+	if err := c.tokenStore.create(ctx, &te); err != nil {
+		c.logger.Error("failed to create control group token", "error", err)
+		return ErrInternalError, nil, nil, nil
+	}
+
+	if err := c.writeControlGroupRequest(ctx, &te, cgReq); err != nil {
+		c.tokenStore.revokeOrphan(ctx, te.ID)
+		c.logger.Error("failed to store control group request", "error", err)
+		return ErrInternalError, nil, nil, nil
+	}
+
+	// Store info for lookup, the same as for response wrapping tokens
+	cubbyReq := &logical.Request{
+		Operation:   logical.CreateOperation,
+		Path:        "cubbyhole/wrapinfo",
+		ClientToken: te.ID,
+		Data: map[string]interface{}{
+			"creation_ttl":  ttl,
+			"creation_time": creationTime,
+			"creation_path": req.Path,
+		},
+	}
+	cubbyReq.SetTokenEntry(&te)
+	cubbyResp, err := c.router.Route(ctx, cubbyReq)
+	if err == nil && cubbyResp != nil && cubbyResp.IsError() {
+		err = cubbyResp.Error()
+	}
+	if err != nil {
+		c.tokenStore.revokeOrphan(ctx, te.ID)
+		c.logger.Error("failed to store control group wrapping information", "error", err)
+		return ErrInternalError, nil, nil, nil
+	}
+
+	cgAuth := &logical.Auth{
+		ClientToken: te.ID,
+		Policies:    []string{controlGroupPolicyName},
+		LeaseOptions: logical.LeaseOptions{
+			TTL:       te.TTL,
+			Renewable: false,
+		},
+	}
+	if err := c.expiration.RegisterAuth(ctx, &te, cgAuth); err != nil {
+		c.tokenStore.revokeOrphan(ctx, te.ID)
+		c.logger.Error("failed to register control group token lease", "request_path", req.Path, "error", err)
+		return ErrInternalError, nil, nil, nil
+	}
+
+	// The request is audited here since it is never routed
+	req.DisplayName = auth.DisplayName
+	logInput := &audit.LogInput{
+		Auth:               auth,
+		Request:            req,
+		NonHMACReqDataKeys: nonHMACReqDataKeys,
+	}
+	if err := c.auditBroker.LogRequest(ctx, logInput, c.auditedHeaders); err != nil {
+		c.logger.Error("failed to audit request", "path", req.Path, "error", err)
+		return ErrInternalError, nil, nil, nil
+	}
+
+	resp := &logical.Response{
+		WrapInfo: &wrapping.ResponseWrapInfo{
+			TTL:             ttl,
+			Token:           te.ID,
+			Accessor:        te.Accessor,
+			CreationTime:    creationTime,
+			CreationPath:    req.Path,
+			WrappedEntityID: auth.EntityID,
+		},
+	}
+
+	return nil, resp, auth, nil
+}
+
+// readControlGroupRequest reads the request held by the control group token
+func (c *Core) readControlGroupRequest(ctx context.Context, te *logical.TokenEntry) (*controlGroupRequest, error) {
+	cubbyReq := &logical.Request{
+		Operation:   logical.ReadOperation,
+		Path:        controlGroupCubbyholePath,
+		ClientToken: te.ID,
+	}
+	cubbyReq.SetTokenEntry(te)
+	cubbyResp, err := c.router.Route(ctx, cubbyReq)
+	if err != nil {
+		return nil, err
+	}
+	if cubbyResp != nil && cubbyResp.IsError() {
+		return nil, cubbyResp.Error()
+	}
+	if cubbyResp == nil || cubbyResp.Data == nil {
+		return nil, errors.New("no control group request found")
+	}
+
+	raw, ok := cubbyResp.Data["request"].(string)
+	if !ok {
+		return nil, errors.New("could not decode the control group request")
+	}
+
+	var cgReq controlGroupRequest
+	if err := jsonutil.DecodeJSON([]byte(raw), &cgReq); err != nil {
+		return nil, err
+	}
+
+	return &cgReq, nil
+}
+
+// writeControlGroupRequest stores the request in the cubbyhole of the control
+// group token
+func (c *Core) writeControlGroupRequest(ctx context.Context, te *logical.TokenEntry, cgReq *controlGroupRequest) error {
+	// The request is marshaled first so that the values in its data survive
+	// the round trip through the cubbyhole unchanged
+	raw, err := json.Marshal(cgReq)
+	if err != nil {
+		return err
+	}
+
+	cubbyReq := &logical.Request{
+		Operation:   logical.UpdateOperation,
+		Path:        controlGroupCubbyholePath,
+		ClientToken: te.ID,
+		Data: map[string]interface{}{
+			"request": string(raw),
+		},
+	}
+	cubbyReq.SetTokenEntry(te)
+	cubbyResp, err := c.router.Route(ctx, cubbyReq)
+	if err != nil {
+		return err
+	}
+	if cubbyResp != nil && cubbyResp.IsError() {
+		return cubbyResp.Error()
+	}
+
+	return nil
+}
+
+// lookupControlGroupToken returns the control group token with the given
+// accessor
+func (c *Core) lookupControlGroupToken(ctx context.Context, accessor string) (*logical.TokenEntry, error) {
+	aEntry, err := c.tokenStore.lookupByAccessor(ctx, accessor, false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	te, err := c.tokenStore.Lookup(ctx, aEntry.TokenID)
+	if err != nil {
+		return nil, err
+	}
+	if te == nil || len(te.Policies) != 1 || te.Policies[0] != controlGroupPolicyName {
+		return nil, &logical.StatusBadRequest{Err: "accessor is not for a control group token"}
+	}
+
+	return te, nil
+}
+
+// controlGroupUnwrap performs a request held by a control group once it has
+// been approved, returning the marshaled HTTP response. The control group
+// token is revoked once the request has been performed.
+func (b *SystemBackend) controlGroupUnwrap(ctx context.Context, token string) (string, error) {
+	te, err := b.Core.tokenStore.Lookup(ctx, token)
+	if err != nil {
+		return "", err
+	}
+	if te == nil {
+		return "", logical.ErrPermissionDenied
+	}
+
+	cgReq, err := b.consumeControlGroupRequest(ctx, te)
+	if err != nil {
+		return "", err
+	}
+	if cgReq == nil {
+		return "request has not been approved by the control group", logical.ErrPermissionDenied
+	}
+
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	req := &logical.Request{
+		ID:          cgReq.ID,
+		Operation:   cgReq.Operation,
+		Path:        cgReq.Path,
+		Data:        cgReq.Data,
+		Headers:     cgReq.Headers,
+		ClientToken: cgReq.ClientToken,
+	}
+	req.ControlGroup = cgReq
+
+	resp, err := b.Core.handleCancelableRequest(ctx, ns, req)
+	if err != nil {
+		if resp != nil && resp.IsError() {
+			return resp.Error().Error(), err
+		}
+		return "", errwrap.Wrapf("error performing control group request: {{err}}", err)
+	}
+
+	// Requests such as deletes have no response, in which case an empty
+	// response is returned so that the unwrap still gets the request ID
+	httpResponse := &logical.HTTPResponse{}
+	if resp != nil {
+		httpResponse = logical.LogicalResponseToHTTPResponse(resp)
+	}
+	httpResponse.RequestID = cgReq.ID
+
+	marshaledResponse, err := json.Marshal(httpResponse)
+	if err != nil {
+		return "", errwrap.Wrapf("failed to marshal control group response: {{err}}", err)
+	}
+
+	return string(marshaledResponse), nil
+}
+
+// consumeControlGroupRequest returns the request held by the control group
+// token if it has been approved, revoking the token so that the request can
+// only be performed once. Nil is returned if the request is not approved yet.
+func (b *SystemBackend) consumeControlGroupRequest(ctx context.Context, te *logical.TokenEntry) (*controlGroupRequest, error) {
+	b.controlGroupLock.Lock()
+	defer b.controlGroupLock.Unlock()
+
+	cgReq, err := b.Core.readControlGroupRequest(ctx, te)
+	if err != nil {
+		return nil, errwrap.Wrapf("error reading control group request: {{err}}", err)
+	}
+	if !cgReq.Approved() {
+		return nil, nil
+	}
+
+	if err := b.Core.tokenStore.revokeOrphan(ctx, te.ID); err != nil {
+		return nil, errwrap.Wrapf("error revoking control group token: {{err}}", err)
+	}
+
+	return cgReq, nil
+}
@@ -0,0 +1,370 @@
+package vault
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	credUserpass "github.com/hashicorp/vault/builtin/credential/userpass"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/logical"
+)
+
+// testControlGroupLogin creates a userpass user with the given policies and
+// logs in, returning the token and the entity ID of the user
+func testControlGroupLogin(t *testing.T, c *Core, root, username, policies string) (string, string) {
+	t.Helper()
+
+	ctx := namespace.RootContext(nil)
+	resp, err := c.HandleRequest(ctx, &logical.Request{
+		Path:        "auth/userpass/users/" + username,
+		Operation:   logical.UpdateOperation,
+		ClientToken: root,
+		Data: map[string]interface{}{
+			"password": "foo",
+			"policies": policies,
+		},
+	})
+	if err != nil || resp != nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	resp, err = c.HandleRequest(ctx, &logical.Request{
+		Path:      "auth/userpass/login/" + username,
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"password": "foo",
+		},
+		Connection: &logical.Connection{},
+	})
+	if err != nil || resp == nil || resp.Auth == nil || resp.Auth.EntityID == "" {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	return resp.Auth.ClientToken, resp.Auth.EntityID
+}
+
+func TestControlGroup(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+	c.credentialBackends["userpass"] = credUserpass.Factory
+
+	requests := []*logical.Request{
+		{
+			Path:      "sys/auth/userpass",
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"type": "userpass",
+			},
+		},
+		{
+			Path:      "sys/policy/requester",
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"policy": `path "secret/foo" {
+	capabilities = ["read"]
+	control_group = {
+		factor "managers" {
+			identity {
+				group_names = ["managers"]
+				approvals = 2
+			}
+		}
+	}
+}`,
+			},
+		},
+		{
+			Path:      "sys/policy/approver",
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"policy": `path "sys/control-group/*" {
+	capabilities = ["update"]
+}`,
+			},
+		},
+		{
+			Path:      "secret/foo",
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"value": "bar",
+			},
+		},
+	}
+	for _, req := range requests {
+		req.ClientToken = root
+		resp, err := c.HandleRequest(ctx, req)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err: %v, resp: %#v", req.Path, err, resp)
+		}
+	}
+
+	requesterToken, requesterEntityID := testControlGroupLogin(t, c, root, "bob", "requester")
+	aliceToken, aliceEntityID := testControlGroupLogin(t, c, root, "alice", "approver")
+	carolToken, carolEntityID := testControlGroupLogin(t, c, root, "carol", "approver")
+	outsiderToken, _ := testControlGroupLogin(t, c, root, "dave", "approver")
+
+	resp, err := c.HandleRequest(ctx, &logical.Request{
+		Path:        "identity/group",
+		Operation:   logical.UpdateOperation,
+		ClientToken: root,
+		Data: map[string]interface{}{
+			"name":              "managers",
+			"member_entity_ids": []string{aliceEntityID, carolEntityID},
+		},
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	// The request is held back and a control group token is returned instead
+	resp, err = c.HandleRequest(ctx, &logical.Request{
+		Path:        "secret/foo",
+		Operation:   logical.ReadOperation,
+		ClientToken: requesterToken,
+	})
+	if err != nil || resp == nil || resp.WrapInfo == nil || resp.WrapInfo.Token == "" {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	if resp.Data["value"] != nil {
+		t.Fatalf("secret returned without approval: %#v", resp.Data)
+	}
+	if resp.WrapInfo.CreationPath != "secret/foo" || resp.WrapInfo.WrappedEntityID != requesterEntityID {
+		t.Fatalf("bad: wrap info: %#v", resp.WrapInfo)
+	}
+	cgToken := resp.WrapInfo.Token
+	accessor := resp.WrapInfo.Accessor
+
+	unwrap := func() (*logical.Response, error) {
+		return c.HandleRequest(ctx, &logical.Request{
+			Path:        "sys/wrapping/unwrap",
+			Operation:   logical.UpdateOperation,
+			ClientToken: cgToken,
+		})
+	}
+	authorize := func(token string) (*logical.Response, error) {
+		return c.HandleRequest(ctx, &logical.Request{
+			Path:        "sys/control-group/authorize",
+			Operation:   logical.UpdateOperation,
+			ClientToken: token,
+			Data: map[string]interface{}{
+				"accessor": accessor,
+			},
+		})
+	}
+
+	// The control group token can be looked up like a wrapping token
+	resp, err = c.HandleRequest(ctx, &logical.Request{
+		Path:      "sys/wrapping/lookup",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"token": cgToken,
+		},
+	})
+	if err != nil || resp == nil || resp.Data["creation_path"] != "secret/foo" {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	// The control group token can't tamper with the held request
+	resp, err = c.HandleRequest(ctx, &logical.Request{
+		Path:        "cubbyhole/control-group",
+		Operation:   logical.UpdateOperation,
+		ClientToken: cgToken,
+		Data: map[string]interface{}{
+			"request": "{}",
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), logical.ErrPermissionDenied.Error()) {
+		t.Fatalf("expected permission denied, got err: %v, resp: %#v", err, resp)
+	}
+
+	resp, err = unwrap()
+	if err == nil || !strings.Contains(err.Error(), logical.ErrPermissionDenied.Error()) {
+		t.Fatalf("expected permission denied before approval, got err: %v, resp: %#v", err, resp)
+	}
+
+	// Only members of the factor groups can authorize
+	resp, err = authorize(outsiderToken)
+	if err == nil || resp == nil || !strings.Contains(resp.Error().Error(), "not an authorizer") {
+		t.Fatalf("expected authorizer error, got err: %v, resp: %#v", err, resp)
+	}
+
+	resp, err = authorize(aliceToken)
+	if err != nil || resp == nil || resp.Data["approved"] != false {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	// Authorizing twice doesn't count twice
+	resp, err = authorize(aliceToken)
+	if err != nil || resp == nil || resp.Data["approved"] != false {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	resp, err = c.HandleRequest(ctx, &logical.Request{
+		Path:        "sys/control-group/request",
+		Operation:   logical.UpdateOperation,
+		ClientToken: carolToken,
+		Data: map[string]interface{}{
+			"accessor": accessor,
+		},
+	})
+	if err != nil || resp == nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	if resp.Data["approved"] != false || resp.Data["request_path"] != "secret/foo" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if resp.Data["request_entity"].(map[string]interface{})["id"] != requesterEntityID {
+		t.Fatalf("bad: request entity: %#v", resp.Data["request_entity"])
+	}
+	authorizations := resp.Data["authorizations"].([]map[string]interface{})
+	if len(authorizations) != 1 || authorizations[0]["entity_id"] != aliceEntityID {
+		t.Fatalf("bad: authorizations: %#v", authorizations)
+	}
+
+	resp, err = authorize(carolToken)
+	if err != nil || resp == nil || resp.Data["approved"] != true {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	// Once approved, unwrapping performs the original request
+	resp, err = unwrap()
+	if err != nil || resp == nil || resp.Data[logical.HTTPRawBody] == nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	httpResp := &logical.HTTPResponse{}
+	if err := json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), httpResp); err != nil {
+		t.Fatal(err)
+	}
+	if httpResp.Data["value"] != "bar" {
+		t.Fatalf("bad: %#v", httpResp)
+	}
+
+	// The control group token can only be used once
+	resp, err = unwrap()
+	if err == nil {
+		t.Fatalf("expected error unwrapping twice, got resp: %#v", resp)
+	}
+}
+
+func TestControlGroup_NoResponse(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+	c.credentialBackends["userpass"] = credUserpass.Factory
+
+	requests := []*logical.Request{
+		{
+			Path:      "sys/auth/userpass",
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"type": "userpass",
+			},
+		},
+		{
+			Path:      "sys/policy/requester",
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"policy": `path "secret/foo" {
+	capabilities = ["delete"]
+	control_group = {
+		factor "managers" {
+			identity {
+				group_names = ["managers"]
+				approvals = 1
+			}
+		}
+	}
+}`,
+			},
+		},
+		{
+			Path:      "sys/policy/approver",
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"policy": `path "sys/control-group/*" {
+	capabilities = ["update"]
+}`,
+			},
+		},
+		{
+			Path:      "secret/foo",
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"value": "bar",
+			},
+		},
+	}
+	for _, req := range requests {
+		req.ClientToken = root
+		resp, err := c.HandleRequest(ctx, req)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err: %v, resp: %#v", req.Path, err, resp)
+		}
+	}
+
+	requesterToken, _ := testControlGroupLogin(t, c, root, "bob", "requester")
+	aliceToken, aliceEntityID := testControlGroupLogin(t, c, root, "alice", "approver")
+
+	resp, err := c.HandleRequest(ctx, &logical.Request{
+		Path:        "identity/group",
+		Operation:   logical.UpdateOperation,
+		ClientToken: root,
+		Data: map[string]interface{}{
+			"name":              "managers",
+			"member_entity_ids": []string{aliceEntityID},
+		},
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	resp, err = c.HandleRequest(ctx, &logical.Request{
+		ID:          "delete-request",
+		Path:        "secret/foo",
+		Operation:   logical.DeleteOperation,
+		ClientToken: requesterToken,
+	})
+	if err != nil || resp == nil || resp.WrapInfo == nil || resp.WrapInfo.Token == "" {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	cgToken := resp.WrapInfo.Token
+
+	resp, err = c.HandleRequest(ctx, &logical.Request{
+		Path:        "sys/control-group/authorize",
+		Operation:   logical.UpdateOperation,
+		ClientToken: aliceToken,
+		Data: map[string]interface{}{
+			"accessor": resp.WrapInfo.Accessor,
+		},
+	})
+	if err != nil || resp == nil || resp.Data["approved"] != true {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	// The delete has no response, so an empty one is returned with the ID of
+	// the held request
+	resp, err = c.HandleRequest(ctx, &logical.Request{
+		Path:        "sys/wrapping/unwrap",
+		Operation:   logical.UpdateOperation,
+		ClientToken: cgToken,
+	})
+	if err != nil || resp == nil || resp.Data[logical.HTTPRawBody] == nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	httpResp := &logical.HTTPResponse{}
+	if err := json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), httpResp); err != nil {
+		t.Fatal(err)
+	}
+	if httpResp.RequestID != "delete-request" || httpResp.Data != nil {
+		t.Fatalf("bad: %#v", httpResp)
+	}
+
+	resp, err = c.HandleRequest(ctx, &logical.Request{
+		Path:        "secret/foo",
+		Operation:   logical.ReadOperation,
+		ClientToken: root,
+	})
+	if err != nil || resp != nil {
+		t.Fatalf("expected the secret to be deleted, got err: %v, resp: %#v", err, resp)
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/hashicorp/vault/helper/consts"
 	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/helper/logging"
+	"github.com/hashicorp/vault/helper/metricsutil"
 	"github.com/hashicorp/vault/helper/mlock"
 	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/reload"
@@ -159,6 +160,10 @@ type Core struct {
 	// physical backend is the un-trusted backend with durable data
 	physical physical.Backend
 
+	// underlyingPhysical is the physical backend as configured, before it is
+	// wrapped with caching and encoding checks
+	underlyingPhysical physical.Backend
+
 	// seal is our seal, for seal configuration information
 	seal Seal
 
@@ -254,6 +259,9 @@ type Core struct {
 	// renewal, expiration and revocation
 	expiration *ExpirationManager
 
+	// rateLimitQuotas enforces the rate limit quotas on the active node
+	rateLimitQuotas *rateLimitQuotaManager
+
 	// rollback manager is used to run rollbacks periodically
 	rollback *RollbackManager
 
@@ -276,6 +284,10 @@ type Core struct {
 	defaultLeaseTTL time.Duration
 	maxLeaseTTL     time.Duration
 
+	// maxLeaseRevokeAttempts is the number of attempts to revoke an expired
+	// lease before it is marked irrevocable
+	maxLeaseRevokeAttempts int
+
 	// baseLogger is used to avoid ResetNamed as it strips useful prefixes in
 	// e.g. testing
 	baseLogger log.Logger
@@ -383,9 +395,35 @@ type Core struct {
 	activeContext           context.Context
 	activeContextCancelFunc *atomic.Value
 
-	// Stores the sealunwrapper for downgrade needs
+	// Stores the sealunwrapper for downgrade needs, it also seal wraps
+	// storage entries
 	sealUnwrapper physical.Backend
 
+	// disableSealWrap disables seal wrapping of storage entries other than
+	// the master key and the keyring
+	disableSealWrap bool
+
+	// sealFactory creates the seals migrated to through sys/seal/migrate
+	sealFactory SealFactory
+
+	// sealMigrationLock protects the online seal migration state below
+	sealMigrationLock sync.Mutex
+
+	// sealMigrationConfig is the online seal migration attempt in progress
+	sealMigrationConfig *SealMigrationConfig
+
+	// sealMigrationStatus is the state of the last online seal migration
+	// performed by this node
+	sealMigrationStatus *SealMigrationStatus
+
+	// sealMigrationID is the ID of the last online seal migration the seal
+	// of this node is up to date with
+	sealMigrationID string
+
+	// previousSeal is the seal migrated from online. It is kept to unwrap
+	// the storage entries it seal wrapped until they are rewrapped.
+	previousSeal Seal
+
 	// Stores any funcs that should be run on successful postUnseal
 	postUnsealFuncs []func()
 
@@ -402,6 +440,17 @@ type Core struct {
 	// Stores loggers so we can reset the level
 	allLoggers     []log.Logger
 	allLoggersLock sync.RWMutex
+
+	// raftInfo holds the state of a raft join waiting for unseal keys
+	raftInfo *raftInformation
+
+	// metricsHelper serves the metrics collected by the server's in-memory
+	// sinks
+	metricsHelper *metricsutil.MetricsHelper
+
+	// raftPendingPeers holds the challenges issued to nodes joining the raft
+	// cluster
+	raftPendingPeers raftPendingPeers
 }
 
 // CoreConfig is used to parameterize a core
@@ -444,6 +493,10 @@ type CoreConfig struct {
 
 	MaxLeaseTTL time.Duration `json:"max_lease_ttl" structs:"max_lease_ttl" mapstructure:"max_lease_ttl"`
 
+	// Number of attempts to revoke an expired lease before it is marked
+	// irrevocable, or zero for default
+	MaxLeaseRevokeAttempts int `json:"max_lease_revoke_attempts" structs:"max_lease_revoke_attempts" mapstructure:"max_lease_revoke_attempts"`
+
 	ClusterName string `json:"cluster_name" structs:"cluster_name" mapstructure:"cluster_name"`
 
 	ClusterCipherSuites string `json:"cluster_cipher_suites" structs:"cluster_cipher_suites" mapstructure:"cluster_cipher_suites"`
@@ -470,6 +523,12 @@ type CoreConfig struct {
 	DisableKeyEncodingChecks  bool
 
 	AllLoggers []log.Logger
+
+	// MetricsHelper serves the metrics collected by the server on sys/metrics
+	MetricsHelper *metricsutil.MetricsHelper
+
+	// SealFactory creates the seals migrated to through sys/seal/migrate
+	SealFactory SealFactory
 }
 
 func (c *CoreConfig) Clone() *CoreConfig {
@@ -489,12 +548,14 @@ func (c *CoreConfig) Clone() *CoreConfig {
 		ClusterAddr:               c.ClusterAddr,
 		DefaultLeaseTTL:           c.DefaultLeaseTTL,
 		MaxLeaseTTL:               c.MaxLeaseTTL,
+		MaxLeaseRevokeAttempts:    c.MaxLeaseRevokeAttempts,
 		ClusterName:               c.ClusterName,
 		ClusterCipherSuites:       c.ClusterCipherSuites,
 		EnableUI:                  c.EnableUI,
 		EnableRaw:                 c.EnableRaw,
 		PluginDirectory:           c.PluginDirectory,
 		DisableSealWrap:           c.DisableSealWrap,
+		SealFactory:               c.SealFactory,
 		ReloadFuncs:               c.ReloadFuncs,
 		ReloadFuncsLock:           c.ReloadFuncsLock,
 		LicensingConfig:           c.LicensingConfig,
@@ -502,6 +563,7 @@ func (c *CoreConfig) Clone() *CoreConfig {
 		DisablePerformanceStandby: c.DisablePerformanceStandby,
 		DisableIndexing:           c.DisableIndexing,
 		AllLoggers:                c.AllLoggers,
+		MetricsHelper:             c.MetricsHelper,
 	}
 }
 
@@ -522,6 +584,12 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 	if conf.DefaultLeaseTTL > conf.MaxLeaseTTL {
 		return nil, fmt.Errorf("cannot have DefaultLeaseTTL larger than MaxLeaseTTL")
 	}
+	if conf.MaxLeaseRevokeAttempts == 0 {
+		conf.MaxLeaseRevokeAttempts = maxRevokeAttempts
+	}
+	if conf.MaxLeaseRevokeAttempts < 0 {
+		return nil, fmt.Errorf("MaxLeaseRevokeAttempts cannot be negative")
+	}
 
 	// Validate the advertise addr if its given to us
 	if conf.RedirectAddr != "" {
@@ -545,6 +613,7 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 		entCore:                          entCore{},
 		devToken:                         conf.DevToken,
 		physical:                         conf.Physical,
+		underlyingPhysical:               conf.Physical,
 		redirectAddr:                     conf.RedirectAddr,
 		clusterAddr:                      conf.ClusterAddr,
 		seal:                             conf.Seal,
@@ -555,6 +624,9 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 		logger:                           conf.Logger.Named("core"),
 		defaultLeaseTTL:                  conf.DefaultLeaseTTL,
 		maxLeaseTTL:                      conf.MaxLeaseTTL,
+		maxLeaseRevokeAttempts:           conf.MaxLeaseRevokeAttempts,
+		disableSealWrap:                  conf.DisableSealWrap,
+		sealFactory:                      conf.SealFactory,
 		cachingDisabled:                  conf.DisableCache,
 		clusterName:                      conf.ClusterName,
 		clusterListenerShutdownCh:        make(chan struct{}),
@@ -576,6 +648,7 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 		activeContextCancelFunc:          new(atomic.Value),
 		allLoggers:                       conf.AllLoggers,
 		builtinRegistry:                  conf.BuiltinRegistry,
+		metricsHelper:                    conf.MetricsHelper,
 	}
 
 	atomic.StoreUint32(c.sealed, 1)
@@ -610,6 +683,7 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 	if err := coreInit(c, conf); err != nil {
 		return nil, err
 	}
+	setSealWrap(c)
 
 	if !conf.DisableMlock {
 		// Ensure our memory usage is locked into physical RAM
@@ -775,13 +849,16 @@ func (c *Core) unseal(key []byte, useRecoveryKeys bool) (bool, error) {
 	ctx := context.Background()
 
 	// Explicitly check for init status. This also checks if the seal
-	// configuration is valid (i.e. non-nil).
-	init, err := c.Initialized(ctx)
-	if err != nil {
-		return false, err
-	}
-	if !init {
-		return false, ErrNotInit
+	// configuration is valid (i.e. non-nil). A node joining a raft cluster
+	// has no data yet and uses the leader's seal configuration instead.
+	if !c.isRaftUnseal() {
+		init, err := c.Initialized(ctx)
+		if err != nil {
+			return false, err
+		}
+		if !init {
+			return false, ErrNotInit
+		}
 	}
 
 	// Verify the key length
@@ -809,6 +886,11 @@ func (c *Core) unseal(key []byte, useRecoveryKeys bool) (bool, error) {
 		return false, err
 	}
 	if masterKey != nil {
+		if c.isRaftUnseal() {
+			if err := c.joinRaftSendAnswer(ctx, masterKey); err != nil {
+				return false, err
+			}
+		}
 		return c.unsealInternal(ctx, masterKey)
 	}
 
@@ -840,9 +922,12 @@ func (c *Core) unsealPart(ctx context.Context, seal Seal, key []byte, useRecover
 
 	var config *SealConfig
 	var err error
-	if seal.RecoveryKeySupported() && (useRecoveryKeys || c.migrationSeal != nil) {
+	switch {
+	case c.isRaftUnseal():
+		config = c.raftInfo.leaderBarrierConfig
+	case seal.RecoveryKeySupported() && (useRecoveryKeys || c.migrationSeal != nil):
 		config, err = seal.RecoveryConfig(ctx)
-	} else {
+	default:
 		config, err = seal.BarrierConfig(ctx)
 	}
 	if err != nil {
@@ -972,6 +1057,7 @@ func (c *Core) unsealPart(ctx context.Context, seal Seal, key []byte, useRecover
 		// At this point we've swapped things around and need to ensure we
 		// don't migrate again
 		c.migrationSeal = nil
+		setSealWrap(c)
 
 		// Ensure we populate the new values
 		bc, err := c.seal.BarrierConfig(ctx)
@@ -1009,6 +1095,13 @@ func (c *Core) unsealInternal(ctx context.Context, masterKey []byte) (bool, erro
 		c.logger.Info("vault is unsealed")
 	}
 
+	if err := c.loadSealMigrationID(ctx); err != nil {
+		c.logger.Error("failed to load seal migration", "error", err)
+		c.barrier.Seal()
+		c.logger.Warn("vault is sealed")
+		return false, err
+	}
+
 	if err := preUnsealInternal(ctx, c); err != nil {
 		return false, err
 	}
@@ -1428,9 +1521,13 @@ func (s standardUnsealStrategy) unseal(ctx context.Context, logger log.Logger, c
 		if err := loadMFAConfigs(ctx, c); err != nil {
 			return err
 		}
+		if err := c.setupRateLimitQuotas(ctx); err != nil {
+			return err
+		}
 		if err := c.setupAuditedHeadersConfig(ctx); err != nil {
 			return err
 		}
+		c.setupSealRewrap(ctx)
 	} else {
 		c.auditBroker = NewAuditBroker(c.logger)
 	}
@@ -1515,6 +1612,11 @@ func (c *Core) preSeal() error {
 	c.barrierRekeyConfig = nil
 	c.recoveryRekeyConfig = nil
 
+	// Clear any seal migration progress
+	c.sealMigrationLock.Lock()
+	c.clearSealMigrationConfig(context.Background())
+	c.sealMigrationLock.Unlock()
+
 	if c.metricsCh != nil {
 		close(c.metricsCh)
 		c.metricsCh = nil
@@ -1529,6 +1631,8 @@ func (c *Core) preSeal() error {
 
 	c.stopClusterListener()
 
+	c.teardownRateLimitQuotas()
+
 	if err := c.teardownAudits(); err != nil {
 		result = multierror.Append(result, errwrap.Wrapf("error tearing down audits: {{err}}", err))
 	}
@@ -1680,6 +1784,7 @@ func (c *Core) SetSealsForMigration(migrationSeal, newSeal Seal) {
 	defer c.stateLock.Unlock()
 	c.migrationSeal = migrationSeal
 	c.seal = newSeal
+	setSealWrap(c)
 	c.logger.Warn("entering seal migration mode; Vault will not automatically unseal even if using an autoseal")
 }
 
@@ -1689,6 +1794,22 @@ func (c *Core) IsInSealMigration() bool {
 	return c.migrationSeal != nil
 }
 
+// sealWrapPath returns whether the storage entry at the given key must be
+// seal wrapped when the seal supports it. The master key and the keyring are
+// always seal wrapped, other entries are seal wrapped unless seal wrapping is
+// disabled, when they ask for it or when their backend lists them in its
+// SealWrapStorage paths.
+func (c *Core) sealWrapPath(key string, requested bool) bool {
+	switch key {
+	case keyringPath, masterKeyPath:
+		return true
+	}
+	if c.disableSealWrap {
+		return false
+	}
+	return requested || c.router.SealWrapStoragePath(key)
+}
+
 func (c *Core) BarrierEncryptorAccess() *BarrierEncryptorAccess {
 	return NewBarrierEncryptorAccess(c.barrier)
 }
@@ -71,7 +71,51 @@ func postUnsealPhysical(c *Core) error {
 	return nil
 }
 
-func loadMFAConfigs(context.Context, *Core) error { return nil }
+// setSealWrap updates the seals used by the physical layer to seal wrap
+// storage entries. It must be called whenever c.seal, c.migrationSeal or
+// c.previousSeal change.
+func setSealWrap(c *Core) {
+	config := &sealWrapConfig{
+		wrapPath: c.sealWrapPath,
+	}
+	if autoSeal, ok := c.seal.(*autoSeal); ok {
+		config.access = autoSeal.Access
+	}
+	if autoSeal, ok := c.migrationSeal.(*autoSeal); ok {
+		config.unwrapAccesses = append(config.unwrapAccesses, autoSeal.Access)
+	}
+	if autoSeal, ok := c.previousSeal.(*autoSeal); ok {
+		config.unwrapAccesses = append(config.unwrapAccesses, autoSeal.Access)
+	}
+
+	switch c.sealUnwrapper.(type) {
+	case *sealUnwrapper:
+		c.sealUnwrapper.(*sealUnwrapper).setSealWrap(config)
+	case *transactionalSealUnwrapper:
+		c.sealUnwrapper.(*transactionalSealUnwrapper).setSealWrap(config)
+	}
+}
+
+// rewrapStorageEntry stores the entry at the given key again if it is not
+// stored the way it would be stored now, such as when it was seal wrapped by
+// c.previousSeal, and returns whether it did.
+func rewrapStorageEntry(ctx context.Context, c *Core, key string) (bool, error) {
+	var err error
+	var updated bool
+	switch c.sealUnwrapper.(type) {
+	case *sealUnwrapper:
+		_, updated, err = c.sealUnwrapper.(*sealUnwrapper).get(ctx, key)
+	case *transactionalSealUnwrapper:
+		_, updated, err = c.sealUnwrapper.(*transactionalSealUnwrapper).get(ctx, key)
+	default:
+		_, err = c.sealUnwrapper.Get(ctx, key)
+	}
+	return updated, err
+}
+
+func loadMFAConfigs(ctx context.Context, c *Core) error {
+	return c.systemBackend.loadMFAConfigs(ctx)
+}
 
 func shouldStartClusterListener(*Core) bool { return true }
 
@@ -36,7 +36,8 @@ const (
 	// tokenViewPrefix is the prefix used for the token based lookup of leases.
 	tokenViewPrefix = "token/"
 
-	// maxRevokeAttempts limits how many revoke attempts are made
+	// maxRevokeAttempts is the default number of attempts to revoke an
+	// expired lease before it is marked irrevocable
 	maxRevokeAttempts = 6
 
 	// revokeRetryBase is a baseline retry time
@@ -72,6 +73,15 @@ type ExpirationManager struct {
 	pending     map[string]pendingInfo
 	pendingLock sync.RWMutex
 
+	// leaseCounts enforces the lease count quotas
+	leaseCounts *leaseCountTracker
+
+	// irrevocable holds the leases that could not be revoked after
+	// maxRevokeAttempts attempts, indexed by lease ID
+	irrevocable       map[string]*leaseEntry
+	irrevocableLock   sync.RWMutex
+	maxRevokeAttempts int
+
 	tidyLock *int32
 
 	restoreMode        *int32
@@ -93,7 +103,8 @@ type ExpireLeaseStrategy func(context.Context, *ExpirationManager, *leaseEntry)
 
 // revokeIDFunc is invoked when a given ID is expired
 func expireLeaseStrategyRevoke(ctx context.Context, m *ExpirationManager, le *leaseEntry) {
-	for attempt := uint(0); attempt < maxRevokeAttempts; attempt++ {
+	var revokeErr error
+	for attempt := uint(0); attempt < uint(m.maxRevokeAttempts); attempt++ {
 		revokeCtx, cancel := context.WithTimeout(ctx, DefaultMaxRequestDuration)
 		revokeCtx = namespace.ContextWithNamespace(revokeCtx, le.namespace)
 
@@ -127,9 +138,25 @@ func expireLeaseStrategyRevoke(ctx context.Context, m *ExpirationManager, le *le
 		}
 
 		m.logger.Error("failed to revoke lease", "lease_id", le.LeaseID, "error", err)
-		time.Sleep((1 << attempt) * revokeRetryBase)
+		revokeErr = err
+		if attempt+1 < uint(m.maxRevokeAttempts) {
+			time.Sleep((1 << attempt) * revokeRetryBase)
+		}
+	}
+
+	select {
+	case <-m.quitCh:
+		return
+	default:
+	}
+
+	m.logger.Error("maximum revoke attempts reached, marking lease irrevocable", "lease_id", le.LeaseID)
+	m.coreStateLock.RLock()
+	err := m.markIrrevocable(ctx, le.LeaseID, revokeErr)
+	m.coreStateLock.RUnlock()
+	if err != nil {
+		m.logger.Error("failed to mark lease irrevocable", "lease_id", le.LeaseID, "error", err)
 	}
-	m.logger.Error("maximum revoke attempts reached", "lease_id", le.LeaseID)
 }
 
 // NewExpirationManager creates a new ExpirationManager that is backed
@@ -145,6 +172,10 @@ func NewExpirationManager(c *Core, view *BarrierView, e ExpireLeaseStrategy, log
 		pending:    make(map[string]pendingInfo),
 		tidyLock:   new(int32),
 
+		leaseCounts:       newLeaseCountTracker(),
+		irrevocable:       make(map[string]*leaseEntry),
+		maxRevokeAttempts: c.maxLeaseRevokeAttempts,
+
 		// new instances of the expiration manager will go immediately into
 		// restore mode
 		restoreMode:  new(int32),
@@ -160,6 +191,10 @@ func NewExpirationManager(c *Core, view *BarrierView, e ExpireLeaseStrategy, log
 	}
 	*exp.restoreMode = 1
 
+	if exp.maxRevokeAttempts <= 0 {
+		exp.maxRevokeAttempts = maxRevokeAttempts
+	}
+
 	if exp.logger == nil {
 		opts := log.LoggerOptions{Name: "expiration_manager"}
 		exp.logger = log.New(&opts)
@@ -185,6 +220,10 @@ func (c *Core) setupExpiration(e ExpireLeaseStrategy) error {
 	// Link the token store to this
 	c.tokenStore.SetExpirationManager(mgr)
 
+	if err := mgr.loadLeaseCountQuotas(c.activeContext); err != nil {
+		return err
+	}
+
 	// Restore the existing state
 	c.logger.Info("restoring leases")
 	errorFunc := func() {
@@ -590,7 +629,10 @@ func (m *ExpirationManager) LazyRevoke(ctx context.Context, leaseID string) erro
 		return nil
 	}
 
+	// Revocation of an irrevocable lease is retried in the background
 	le.ExpireTime = time.Now()
+	le.RevokeErr = ""
+	m.removeIrrevocable(le.LeaseID)
 	{
 		m.pendingLock.Lock()
 		if err := m.persistEntry(ctx, le); err != nil {
@@ -629,7 +671,7 @@ func (m *ExpirationManager) revokeCommon(ctx context.Context, leaseID string, fo
 			}
 
 			if m.logger.IsWarn() {
-				m.logger.Warn("revocation from the backend failed, but in force mode so ignoring", "error", err)
+				m.logger.Warn("revocation from the backend failed, but in force mode so ignoring", "lease_id", leaseID, "error", err)
 			}
 		}
 	}
@@ -1115,6 +1157,12 @@ func (m *ExpirationManager) Register(ctx context.Context, req *logical.Request,
 		}
 	}
 
+	// Count the lease against the lease count quotas; it is uncounted when
+	// the entry is deleted on error
+	if err := m.leaseCounts.add(leaseCountKey(le)); err != nil {
+		return "", err
+	}
+
 	// Encode the entry
 	if err := m.persistEntry(ctx, le); err != nil {
 		return "", err
@@ -1189,8 +1237,13 @@ func (m *ExpirationManager) RegisterAuth(ctx context.Context, te *logical.TokenE
 		namespace:   tokenNS,
 	}
 
+	if err := m.leaseCounts.add(leaseCountKey(&le)); err != nil {
+		return err
+	}
+
 	// Encode the entry
 	if err := m.persistEntry(ctx, &le); err != nil {
+		m.leaseCounts.remove(leaseCountKey(&le))
 		return err
 	}
 
@@ -1304,8 +1357,9 @@ func (m *ExpirationManager) updatePendingInternal(le *leaseEntry, leaseTotal tim
 	// Check for an existing timer
 	pending, ok := m.pending[le.LeaseID]
 
-	// If there is no expiry time, don't do anything
-	if le.ExpireTime.IsZero() {
+	// If there is no expiry time, or the lease is irrevocable, don't do
+	// anything
+	if le.ExpireTime.IsZero() || le.RevokeErr != "" {
 		// if the timer happened to exist, stop the time and delete it from the
 		// pending timers.
 		if ok {
@@ -1478,6 +1532,10 @@ func (m *ExpirationManager) loadEntryInternal(ctx context.Context, leaseID strin
 		// Update the cache of restored leases, either synchronously or through
 		// the lazy loaded restore process
 		m.restoreLoaded.Store(le.LeaseID, struct{}{})
+		m.leaseCounts.track(leaseCountKey(le))
+		if le.RevokeErr != "" {
+			m.addIrrevocable(le)
+		}
 
 		// Setup revocation timer
 		m.updatePending(le, le.ExpireTime.Sub(time.Now()))
@@ -1515,6 +1573,8 @@ func (m *ExpirationManager) deleteEntry(ctx context.Context, le *leaseEntry) err
 	if err := view.Delete(ctx, le.LeaseID); err != nil {
 		return errwrap.Wrapf("failed to delete lease entry: {{err}}", err)
 	}
+	m.leaseCounts.remove(leaseCountKey(le))
+	m.removeIrrevocable(le.LeaseID)
 	return nil
 }
 
@@ -1731,6 +1791,11 @@ func (m *ExpirationManager) emitMetrics() {
 	num := len(m.pending)
 	m.pendingLock.RUnlock()
 	metrics.SetGauge([]string{"expire", "num_leases"}, float32(num))
+
+	m.irrevocableLock.RLock()
+	numIrrevocable := len(m.irrevocable)
+	m.irrevocableLock.RUnlock()
+	metrics.SetGauge([]string{"expire", "num_irrevocable_leases"}, float32(numIrrevocable))
 	// Check if lease count is greater than the threshold
 	if num > maxLeaseThreshold {
 		if atomic.LoadUint32(m.leaseCheckCounter) > 59 {
@@ -1756,6 +1821,10 @@ type leaseEntry struct {
 	ExpireTime      time.Time              `json:"expire_time"`
 	LastRenewalTime time.Time              `json:"last_renewal_time"`
 
+	// RevokeErr is the last error returned by the backend when revoking the
+	// lease. It is set once the lease is marked irrevocable.
+	RevokeErr string `json:"revoke_err,omitempty"`
+
 	namespace *namespace.Namespace
 }
 
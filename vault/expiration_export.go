@@ -0,0 +1,167 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/logical"
+)
+
+// leaseFilter selects leases of the expiration manager. Empty fields match
+// all the leases.
+type leaseFilter struct {
+	// Prefix is a prefix of the lease IDs, such as "database/creds/"
+	Prefix string
+
+	// Mount is the path of the mount that issued the leases, such as
+	// "database/" or "auth/userpass/"
+	Mount string
+
+	// Role is the role the leases were issued for, see leaseRole
+	Role string
+
+	// TokenAccessor is the accessor of the token owning the leases
+	TokenAccessor string
+}
+
+func (f *leaseFilter) empty() bool {
+	return f.Prefix == "" && f.Mount == "" && f.Role == "" && f.TokenAccessor == ""
+}
+
+// leaseInfo describes a lease for export
+type leaseInfo struct {
+	LeaseID       string
+	IssueTime     time.Time
+	ExpireTime    time.Time
+	LastRenewal   time.Time
+	Renewable     bool
+	TokenAccessor string
+	Mount         string
+	Role          string
+
+	// Auth is set for token leases
+	Auth bool
+}
+
+// exportLeases returns the leases of the namespace of the context matching
+// a filter, ordered by lease ID
+func (m *ExpirationManager) exportLeases(ctx context.Context, filter *leaseFilter) ([]*leaseInfo, error) {
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mount := filter.Mount
+	if mount != "" && !strings.HasSuffix(mount, "/") {
+		mount += "/"
+	}
+
+	prefix := filter.Prefix
+	if mount != "" && !strings.HasPrefix(prefix, mount) {
+		// Only the leases of the mount need to be scanned, unless the prefix
+		// is outside of the mount and nothing can match
+		if prefix != "" && !strings.HasPrefix(mount, prefix) {
+			return nil, nil
+		}
+		prefix = mount
+	}
+
+	// Scan the leases under the last directory of the prefix, as the prefix
+	// may end in the middle of a lease ID
+	dir := prefix[:strings.LastIndex(prefix, "/")+1]
+	keys, err := logical.CollectKeys(ctx, m.leaseView(ns).SubView(dir))
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to scan for leases: {{err}}", err)
+	}
+	sort.Strings(keys)
+
+	// Cache the accessors of the tokens owning the leases, as a token
+	// usually owns many of them
+	accessors := make(map[string]string)
+
+	var leases []*leaseInfo
+	for _, key := range keys {
+		leaseID := dir + key
+		if !strings.HasPrefix(leaseID, prefix) {
+			continue
+		}
+
+		le, err := m.loadEntry(ctx, leaseID)
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("failed to load lease %q: {{err}}", leaseID), err)
+		}
+		if le == nil {
+			continue
+		}
+
+		info := &leaseInfo{
+			LeaseID:     le.LeaseID,
+			IssueTime:   le.IssueTime,
+			ExpireTime:  le.ExpireTime,
+			LastRenewal: le.LastRenewalTime,
+			Auth:        le.Auth != nil,
+		}
+		info.Renewable, _ = le.renewable()
+		info.Mount = m.leaseMount(ctx, le)
+		if mount != "" && info.Mount != mount {
+			continue
+		}
+
+		accessor, ok := accessors[le.ClientToken]
+		if !ok && le.ClientToken != "" {
+			lock := locksutil.LockForKey(m.tokenStore.tokenLocks, le.ClientToken)
+			lock.RLock()
+			te, err := m.tokenStore.lookupInternal(ctx, le.ClientToken, false, true)
+			lock.RUnlock()
+			if err != nil {
+				return nil, errwrap.Wrapf(fmt.Sprintf("failed to look up the token of lease %q: {{err}}", leaseID), err)
+			}
+			if te != nil {
+				accessor = te.Accessor
+			}
+			accessors[le.ClientToken] = accessor
+		}
+		info.TokenAccessor = accessor
+		if filter.TokenAccessor != "" && info.TokenAccessor != filter.TokenAccessor {
+			continue
+		}
+
+		info.Role = leaseRole(le, info.Mount)
+		if filter.Role != "" && info.Role != filter.Role {
+			continue
+		}
+
+		leases = append(leases, info)
+	}
+
+	return leases, nil
+}
+
+// leaseMount returns the path of the mount that issued a lease, relative to
+// the namespace of the lease
+func (m *ExpirationManager) leaseMount(ctx context.Context, le *leaseEntry) string {
+	mount := m.router.MatchingMount(namespace.ContextWithNamespace(ctx, le.namespace), le.Path)
+	return strings.TrimPrefix(mount, le.namespace.Path)
+}
+
+// leaseRole returns the role a lease was issued for. Secret engines issue
+// credentials at paths such as "creds/:role" or "issue/:role", so the role of
+// a secret lease is the second segment of its path within the mount. The role
+// of a token lease is taken from the "role" metadata set by the auth method.
+func leaseRole(le *leaseEntry, mount string) string {
+	if le.Auth != nil {
+		return le.Auth.Metadata["role"]
+	}
+
+	parts := strings.Split(strings.TrimPrefix(le.Path, mount), "/")
+	if len(parts) != 2 {
+		return ""
+	}
+	return parts[1]
+}
@@ -0,0 +1,109 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+// errLeaseNotIrrevocable is returned when retrying or force revoking a lease
+// that is not marked irrevocable
+var errLeaseNotIrrevocable = errors.New("lease is not irrevocable")
+
+// markIrrevocable records the last error returned when revoking a lease and
+// stops the lease from being retried, until its revocation is retried or
+// forced by an operator
+func (m *ExpirationManager) markIrrevocable(ctx context.Context, leaseID string, revokeErr error) error {
+	le, err := m.loadEntry(ctx, leaseID)
+	if err != nil {
+		return err
+	}
+	if le == nil {
+		return nil
+	}
+
+	le.RevokeErr = revokeErr.Error()
+	{
+		m.pendingLock.Lock()
+		if err := m.persistEntry(ctx, le); err != nil {
+			m.pendingLock.Unlock()
+			return err
+		}
+
+		m.updatePendingInternal(le, 0)
+		m.pendingLock.Unlock()
+	}
+
+	m.addIrrevocable(le)
+	return nil
+}
+
+func (m *ExpirationManager) addIrrevocable(le *leaseEntry) {
+	m.irrevocableLock.Lock()
+	m.irrevocable[le.LeaseID] = le
+	m.irrevocableLock.Unlock()
+}
+
+func (m *ExpirationManager) removeIrrevocable(leaseID string) {
+	m.irrevocableLock.Lock()
+	delete(m.irrevocable, leaseID)
+	m.irrevocableLock.Unlock()
+}
+
+// irrevocableLeases returns the leases marked irrevocable, ordered by lease
+// ID
+func (m *ExpirationManager) irrevocableLeases() []*leaseEntry {
+	m.irrevocableLock.RLock()
+	leases := make([]*leaseEntry, 0, len(m.irrevocable))
+	for _, le := range m.irrevocable {
+		leases = append(leases, le)
+	}
+	m.irrevocableLock.RUnlock()
+
+	sort.Slice(leases, func(i, j int) bool {
+		return leases[i].LeaseID < leases[j].LeaseID
+	})
+	return leases
+}
+
+// loadIrrevocable loads a lease, failing if it is not marked irrevocable
+func (m *ExpirationManager) loadIrrevocable(ctx context.Context, leaseID string) (*leaseEntry, error) {
+	le, err := m.loadEntry(ctx, leaseID)
+	if err != nil {
+		return nil, err
+	}
+	if le == nil || le.RevokeErr == "" {
+		return nil, errLeaseNotIrrevocable
+	}
+	return le, nil
+}
+
+// RetryIrrevocable attempts once more to revoke an irrevocable lease. If the
+// revocation fails again, the lease stays irrevocable with the new error.
+func (m *ExpirationManager) RetryIrrevocable(ctx context.Context, leaseID string) error {
+	if _, err := m.loadIrrevocable(ctx, leaseID); err != nil {
+		return err
+	}
+
+	revokeErr := m.revokeCommon(ctx, leaseID, false, false)
+	if revokeErr == nil {
+		return nil
+	}
+
+	if err := m.markIrrevocable(ctx, leaseID, revokeErr); err != nil {
+		m.logger.Error("failed to mark lease irrevocable", "lease_id", leaseID, "error", err)
+	}
+	return revokeErr
+}
+
+// ForceRevokeIrrevocable removes an irrevocable lease, ignoring the errors of
+// the backend. Whatever the lease was for must be cleaned up manually.
+func (m *ExpirationManager) ForceRevokeIrrevocable(ctx context.Context, leaseID string) error {
+	le, err := m.loadIrrevocable(ctx, leaseID)
+	if err != nil {
+		return err
+	}
+
+	m.logger.Warn("force revoking irrevocable lease", "lease_id", leaseID, "revoke_error", le.RevokeErr)
+	return m.revokeCommon(ctx, leaseID, true, false)
+}
@@ -1953,3 +1953,111 @@ func badRenewFactory(ctx context.Context, conf *logical.BackendConfig) (logical.
 
 	return be, nil
 }
+
+func TestExpiration_Irrevocable(t *testing.T) {
+	exp := mockExpiration(t)
+	exp.maxRevokeAttempts = 1
+
+	var failRevoke uint32 = 1
+	noop := &NoopBackend{
+		RequestHandler: func(ctx context.Context, req *logical.Request) (*logical.Response, error) {
+			if req.Operation == logical.RevokeOperation && atomic.LoadUint32(&failRevoke) == 1 {
+				return nil, errors.New("database unreachable")
+			}
+			return nil, nil
+		},
+	}
+	_, barrier, _ := mockBarrier(t)
+	view := NewBarrierView(barrier, "logical/")
+	meUUID, err := uuid.GenerateUUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = exp.router.Mount(noop, "prod/aws/", &MountEntry{Path: "prod/aws/", Type: "noop", UUID: meUUID, Accessor: "noop-accessor", namespace: namespace.RootNamespace}, view)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, path := range []string{"prod/aws/foo", "prod/aws/bar"} {
+		req := &logical.Request{
+			Operation:   logical.ReadOperation,
+			Path:        path,
+			ClientToken: "foobar",
+		}
+		req.SetTokenEntry(&logical.TokenEntry{ID: "foobar", NamespaceID: "root"})
+		resp := &logical.Response{
+			Secret: &logical.Secret{
+				LeaseOptions: logical.LeaseOptions{
+					TTL: 20 * time.Millisecond,
+				},
+			},
+			Data: map[string]interface{}{
+				"access_key": "xyz",
+				"secret_key": "abcd",
+			},
+		}
+
+		id, err := exp.Register(namespace.RootContext(nil), req, resp)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	// Both leases fail to be revoked once expired
+	start := time.Now()
+	for len(exp.irrevocableLeases()) != 2 {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("leases were not marked irrevocable: %#v", exp.irrevocableLeases())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	le, err := exp.loadEntry(namespace.RootContext(nil), ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(le.RevokeErr, "database unreachable") {
+		t.Fatalf("bad revoke error: %q", le.RevokeErr)
+	}
+	exp.pendingLock.RLock()
+	numPending := len(exp.pending)
+	exp.pendingLock.RUnlock()
+	if numPending != 0 {
+		t.Fatalf("expected no pending leases, got %d", numPending)
+	}
+
+	// A failed retry keeps the lease irrevocable
+	if err := exp.RetryIrrevocable(namespace.RootContext(nil), ids[0]); err == nil {
+		t.Fatal("expected error")
+	}
+	if len(exp.irrevocableLeases()) != 2 {
+		t.Fatalf("bad: %#v", exp.irrevocableLeases())
+	}
+
+	atomic.StoreUint32(&failRevoke, 0)
+	if err := exp.RetryIrrevocable(namespace.RootContext(nil), ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.RetryIrrevocable(namespace.RootContext(nil), ids[0]); err != errLeaseNotIrrevocable {
+		t.Fatalf("expected errLeaseNotIrrevocable, got %v", err)
+	}
+
+	atomic.StoreUint32(&failRevoke, 1)
+	if err := exp.ForceRevokeIrrevocable(namespace.RootContext(nil), ids[1]); err != nil {
+		t.Fatal(err)
+	}
+	if leases := exp.irrevocableLeases(); len(leases) != 0 {
+		t.Fatalf("bad: %#v", leases)
+	}
+	for _, id := range ids {
+		le, err := exp.loadEntry(namespace.RootContext(nil), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if le != nil {
+			t.Fatalf("lease %q was not removed", id)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package raft
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/physical"
+	"github.com/hashicorp/vault/physical/raft"
+	"github.com/hashicorp/vault/vault"
+)
+
+func raftCluster(t *testing.T) (*vault.TestCluster, func()) {
+	var dirs []string
+	var backends []*raft.RaftBackend
+
+	cluster := vault.NewTestCluster(t, &vault.CoreConfig{
+		DisableMlock: true,
+	}, &vault.TestClusterOptions{
+		HandlerFunc:        vaulthttp.Handler,
+		KeepStandbysSealed: true,
+		PhysicalFactory: func(logger log.Logger) (physical.Backend, error) {
+			dir, err := ioutil.TempDir("", "vault-raft-")
+			if err != nil {
+				return nil, err
+			}
+			dirs = append(dirs, dir)
+
+			backend, err := raft.NewRaftBackend(map[string]string{
+				"path":    dir,
+				"address": "127.0.0.1:0",
+			}, logger)
+			if err != nil {
+				return nil, err
+			}
+			backends = append(backends, backend.(*raft.RaftBackend))
+			return backend, nil
+		},
+	})
+	cluster.Start()
+
+	cleanup := func() {
+		cluster.Cleanup()
+		for _, backend := range backends {
+			backend.TeardownCluster()
+		}
+		for _, dir := range dirs {
+			os.RemoveAll(dir)
+		}
+	}
+
+	leaderAddr := fmt.Sprintf("https://127.0.0.1:%d", cluster.Cores[0].Listeners[0].Address.Port)
+	for _, core := range cluster.Cores[1:] {
+		resp, err := core.Client.Sys().RaftJoin(&api.RaftJoinRequest{
+			LeaderAPIAddr: leaderAddr,
+			LeaderCACert:  string(cluster.CACertPEM),
+		})
+		if err != nil {
+			cleanup()
+			t.Fatal(err)
+		}
+		if !resp.Joined {
+			cleanup()
+			t.Fatal("expected node to join")
+		}
+
+		cluster.UnsealCore(t, core)
+	}
+
+	return cluster, cleanup
+}
+
+func TestRaft_Join(t *testing.T) {
+	cluster, cleanup := raftCluster(t)
+	defer cleanup()
+
+	client := cluster.Cores[0].Client
+
+	config, err := client.Sys().RaftConfiguration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Servers) != 3 {
+		t.Fatalf("expected 3 servers, got %#v", config.Servers)
+	}
+	var leaders int
+	for _, server := range config.Servers {
+		if server.Leader {
+			leaders++
+		}
+	}
+	if leaders != 1 {
+		t.Fatalf("expected exactly one leader, got %d", leaders)
+	}
+
+	// Data written on the active node is served by the standbys
+	if _, err := client.Logical().Write("secret/foo", map[string]interface{}{"value": "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, core := range cluster.Cores[1:] {
+		if core.Sealed() {
+			t.Fatal("expected joined core to be unsealed")
+		}
+
+		var secret *api.Secret
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			secret, err = core.Client.Logical().Read("secret/foo")
+			if err == nil && secret != nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("failed to read replicated data: %v", err)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		if secret.Data["value"] != "bar" {
+			t.Fatalf("bad secret: %#v", secret)
+		}
+	}
+
+	// Joining an already joined node fails
+	_, err = cluster.Cores[1].Client.Sys().RaftJoin(&api.RaftJoinRequest{
+		LeaderAPIAddr: fmt.Sprintf("https://127.0.0.1:%d", cluster.Cores[0].Listeners[0].Address.Port),
+		LeaderCACert:  string(cluster.CACertPEM),
+	})
+	if err == nil {
+		t.Fatal("expected error joining an already joined node")
+	}
+}
+
+func TestRaft_RemovePeer(t *testing.T) {
+	cluster, cleanup := raftCluster(t)
+	defer cleanup()
+
+	client := cluster.Cores[0].Client
+
+	config, err := client.Sys().RaftConfiguration()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var leaderID, followerID string
+	for _, server := range config.Servers {
+		if server.Leader {
+			leaderID = server.NodeID
+		} else {
+			followerID = server.NodeID
+		}
+	}
+
+	if err := client.Sys().RaftRemovePeer(leaderID); err == nil {
+		t.Fatal("expected error removing the active node")
+	}
+	if err := client.Sys().RaftRemovePeer(followerID); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err = client.Sys().RaftConfiguration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Servers) != 2 {
+		t.Fatalf("expected 2 servers, got %#v", config.Servers)
+	}
+	for _, server := range config.Servers {
+		if server.NodeID == followerID {
+			t.Fatal("removed peer is still part of the configuration")
+		}
+	}
+}
+
+func TestRaft_SnapshotAPI(t *testing.T) {
+	cluster, cleanup := raftCluster(t)
+	defer cleanup()
+
+	client := cluster.Cores[0].Client
+
+	if _, err := client.Logical().Write("secret/foo", map[string]interface{}{"value": "bar"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var snap bytes.Buffer
+	if err := client.Sys().RaftSnapshot(&snap); err != nil {
+		t.Fatal(err)
+	}
+	if snap.Len() == 0 {
+		t.Fatal("empty snapshot")
+	}
+
+	if _, err := client.Logical().Write("secret/foo", map[string]interface{}{"value": "baz"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Sys().RaftSnapshotRestore(bytes.NewReader(snap.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+
+	secret, err := client.Logical().Read("secret/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret == nil || secret.Data["value"] != "bar" {
+		t.Fatalf("bad secret after restore: %#v", secret)
+	}
+
+	if err := client.Sys().RaftSnapshotRestore(bytes.NewReader([]byte("not a snapshot"))); err == nil {
+		t.Fatal("expected error restoring an invalid snapshot")
+	}
+}
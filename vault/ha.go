@@ -443,6 +443,19 @@ func (c *Core) waitForLeadership(newLeaderCh chan func(), manualStepDownCh, stop
 		// everything is sane. If we have no sanity in the barrier, we actually
 		// seal, as there's little we can do.
 		{
+			// Switch to the seal migrated to while we were standby, as the
+			// master key and keyring are now seal wrapped with it
+			if err := c.adoptSealMigration(activeCtx); err != nil {
+				c.logger.Error("error switching to migrated seal", "error", err)
+				go c.Shutdown()
+				c.heldHALock = nil
+				lock.Unlock()
+				close(continueCh)
+				c.stateLock.Unlock()
+				metrics.MeasureSince([]string{"core", "leadership_setup_failed"}, activeTime)
+				return
+			}
+
 			c.seal.SetBarrierConfig(activeCtx, nil)
 			if c.seal.RecoveryKeySupported() {
 				c.seal.SetRecoveryConfig(activeCtx, nil)
@@ -74,6 +74,20 @@ func NewIdentityStore(ctx context.Context, core *Core, config *logical.BackendCo
 		BackendType: logical.TypeLogical,
 		Paths:       iStore.paths(),
 		Invalidate:  iStore.Invalidate,
+		PathsSpecial: &logical.Paths{
+			Unauthenticated: []string{
+				"oidc/.well-known/*",
+				"oidc/provider/+/.well-known/*",
+				"oidc/provider/+/token",
+				"oidc/provider/+/userinfo",
+			},
+		},
+		PeriodicFunc: func(ctx context.Context, req *logical.Request) error {
+			if err := iStore.oidcPeriodicFunc(ctx, req.Storage); err != nil {
+				return err
+			}
+			return iStore.oidcProviderPeriodicFunc(ctx, req.Storage)
+		},
 	}
 
 	err = iStore.Setup(ctx, config)
@@ -92,6 +106,8 @@ func (i *IdentityStore) paths() []*framework.Path {
 		groupPaths(i),
 		lookupPaths(i),
 		upgradePaths(i),
+		oidcPaths(i),
+		oidcProviderPaths(i),
 	)
 }
 
@@ -0,0 +1,1154 @@
+package vault
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/identity"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"golang.org/x/crypto/ed25519"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const (
+	// Storage prefixes of the OIDC token issuer
+	oidcTokensPrefix     = "oidc_tokens/"
+	oidcConfigStorageKey = oidcTokensPrefix + "config"
+	namedKeyConfigPath   = oidcTokensPrefix + "named_keys/"
+	publicKeysConfigPath = oidcTokensPrefix + "public_keys/"
+	roleConfigPath       = oidcTokensPrefix + "roles/"
+)
+
+var (
+	// supportedOIDCAlgs are the algorithms named keys can sign tokens with
+	supportedOIDCAlgs = []string{
+		string(jose.RS256),
+		string(jose.RS384),
+		string(jose.RS512),
+		string(jose.ES256),
+		string(jose.ES384),
+		string(jose.ES512),
+		string(jose.EdDSA),
+	}
+
+	// reservedOIDCClaims are set by Vault and cannot be overridden by a role's
+	// template
+	reservedOIDCClaims = []string{
+		"iat",
+		"aud",
+		"exp",
+		"iss",
+		"sub",
+		"namespace",
+	}
+)
+
+type oidcConfig struct {
+	Issuer string `json:"issuer"`
+}
+
+// namedKey is a key that tokens are signed with. Keys that have been rotated
+// out remain in the key ring, and are published, until they expire.
+type namedKey struct {
+	SigningAlgorithm string           `json:"signing_algorithm"`
+	VerificationTTL  time.Duration    `json:"verification_ttl"`
+	RotationPeriod   time.Duration    `json:"rotation_period"`
+	AllowedClientIDs []string         `json:"allowed_client_ids"`
+	KeyRing          []*expireableKey `json:"key_ring"`
+	SigningKey       *jose.JSONWebKey `json:"signing_key"`
+	NextRotation     time.Time        `json:"next_rotation"`
+}
+
+type expireableKey struct {
+	KeyID    string    `json:"key_id"`
+	ExpireAt time.Time `json:"expire_at"`
+}
+
+type oidcRole struct {
+	TokenTTL time.Duration `json:"token_ttl"`
+	Key      string        `json:"key"`
+	Template string        `json:"template"`
+	ClientID string        `json:"client_id"`
+}
+
+type discovery struct {
+	Issuer        string   `json:"issuer"`
+	Keys          string   `json:"jwks_uri"`
+	ResponseTypes []string `json:"response_types_supported"`
+	Subjects      []string `json:"subject_types_supported"`
+	IDTokenAlgs   []string `json:"id_token_signing_alg_values_supported"`
+}
+
+func oidcPaths(i *IdentityStore) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "oidc/config/?$",
+			Fields: map[string]*framework.FieldSchema{
+				"issuer": {
+					Type:        framework.TypeString,
+					Description: "Issuer URL to be used in the iss claim of the token. If not set, Vault's api_addr will be used. The issuer is a case sensitive URL using the https scheme that contains scheme, host, and optionally, port number and path components, but no query or fragment components.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation:   i.pathOIDCReadConfig,
+				logical.UpdateOperation: i.pathOIDCUpdateConfig,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["oidc-config"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["oidc-config"][1]),
+		},
+		{
+			Pattern: "oidc/key/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the key",
+				},
+				"rotation_period": {
+					Type:        framework.TypeDurationSecond,
+					Description: "How often to generate a new keypair.",
+					Default:     "24h",
+				},
+				"verification_ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Controls how long the public portion of a key will be available for verification after being rotated.",
+					Default:     "24h",
+				},
+				"algorithm": {
+					Type:        framework.TypeString,
+					Description: "Signing algorithm to use. This will default to RS256.",
+					Default:     "RS256",
+				},
+				"allowed_client_ids": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma-separated list of role client IDs allowed to use this key for signing. If set to '*', all roles are allowed.",
+					Default:     []string{"*"},
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.CreateOperation: i.pathOIDCCreateUpdateKey,
+				logical.UpdateOperation: i.pathOIDCCreateUpdateKey,
+				logical.ReadOperation:   i.pathOIDCReadKey,
+				logical.DeleteOperation: i.pathOIDCDeleteKey,
+			},
+			ExistenceCheck: i.pathOIDCKeyExistenceCheck,
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["oidc-key"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["oidc-key"][1]),
+		},
+		{
+			Pattern: "oidc/key/" + framework.GenericNameRegex("name") + "/rotate/?$",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the key",
+				},
+				"verification_ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Controls how long the public portion of a key will be available for verification after being rotated. Setting verification_ttl here will override the verification_ttl set on the key.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathOIDCRotateKey,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["oidc-rotate-key"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["oidc-rotate-key"][1]),
+		},
+		{
+			Pattern: "oidc/key/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: i.pathOIDCListKey,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["oidc-key-list"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["oidc-key-list"][1]),
+		},
+		{
+			Pattern: "oidc/role/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the role",
+				},
+				"key": {
+					Type:        framework.TypeString,
+					Description: "The OIDC key to use for generating tokens. The specified key must already exist.",
+				},
+				"template": {
+					Type:        framework.TypeString,
+					Description: "The template string to use for generating tokens. This must be a JSON object, and may contain identity templates.",
+				},
+				"ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "TTL of the tokens generated against the role.",
+					Default:     "24h",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.CreateOperation: i.pathOIDCCreateUpdateRole,
+				logical.UpdateOperation: i.pathOIDCCreateUpdateRole,
+				logical.ReadOperation:   i.pathOIDCReadRole,
+				logical.DeleteOperation: i.pathOIDCDeleteRole,
+			},
+			ExistenceCheck: i.pathOIDCRoleExistenceCheck,
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["oidc-role"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["oidc-role"][1]),
+		},
+		{
+			Pattern: "oidc/role/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: i.pathOIDCListRole,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["oidc-role-list"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["oidc-role-list"][1]),
+		},
+		{
+			Pattern: "oidc/token/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the role",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation: i.pathOIDCGenerateToken,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["oidc-token"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["oidc-token"][1]),
+		},
+		{
+			Pattern: "oidc/.well-known/openid-configuration/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation: i.pathOIDCDiscovery,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["oidc-discovery"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["oidc-discovery"][1]),
+		},
+		{
+			Pattern: "oidc/.well-known/keys/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation: i.pathOIDCReadPublicKeys,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcHelp["oidc-public-keys"][0]),
+			HelpDescription: strings.TrimSpace(oidcHelp["oidc-public-keys"][1]),
+		},
+	}
+}
+
+func (i *IdentityStore) getOIDCConfig(ctx context.Context, s logical.Storage) (*oidcConfig, error) {
+	var config oidcConfig
+
+	entry, err := s.Get(ctx, oidcConfigStorageKey)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil {
+		if err := entry.DecodeJSON(&config); err != nil {
+			return nil, err
+		}
+	}
+
+	return &config, nil
+}
+
+// oidcIssuer returns the issuer of tokens minted in the namespace of the
+// request. The well-known endpoints are served relative to it.
+func (i *IdentityStore) oidcIssuer(ctx context.Context, s logical.Storage) (string, error) {
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	config, err := i.getOIDCConfig(ctx, s)
+	if err != nil {
+		return "", err
+	}
+
+	issuer := config.Issuer
+	if issuer == "" {
+		issuer = i.core.redirectAddr
+	}
+
+	return strings.TrimSuffix(issuer, "/") + "/v1/" + ns.Path + "identity/oidc", nil
+}
+
+func (i *IdentityStore) pathOIDCReadConfig(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := i.getOIDCConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"issuer": config.Issuer,
+		},
+	}, nil
+}
+
+func (i *IdentityStore) pathOIDCUpdateConfig(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	config, err := i.getOIDCConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if issuerRaw, ok := d.GetOk("issuer"); ok {
+		issuer := issuerRaw.(string)
+		if issuer != "" {
+			if !strings.HasPrefix(issuer, "http://") && !strings.HasPrefix(issuer, "https://") {
+				return logical.ErrorResponse("issuer must be an http or https URL"), nil
+			}
+			if strings.ContainsAny(issuer, "?#") {
+				return logical.ErrorResponse("issuer must not contain query or fragment components"), nil
+			}
+		}
+		config.Issuer = issuer
+	}
+
+	entry, err := logical.StorageEntryJSON(oidcConfigStorageKey, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (i *IdentityStore) getNamedKey(ctx context.Context, s logical.Storage, name string) (*namedKey, error) {
+	entry, err := s.Get(ctx, namedKeyConfigPath+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var key namedKey
+	if err := entry.DecodeJSON(&key); err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+func (i *IdentityStore) putNamedKey(ctx context.Context, s logical.Storage, name string, key *namedKey) error {
+	entry, err := logical.StorageEntryJSON(namedKeyConfigPath+name, key)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+func (i *IdentityStore) pathOIDCKeyExistenceCheck(ctx context.Context, req *logical.Request, d *framework.FieldData) (bool, error) {
+	key, err := i.getNamedKey(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		return false, err
+	}
+
+	return key != nil, nil
+}
+
+func (i *IdentityStore) pathOIDCCreateUpdateKey(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	key, err := i.getNamedKey(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		if req.Operation == logical.UpdateOperation {
+			return logical.ErrorResponse(fmt.Sprintf("key %q does not exist", name)), nil
+		}
+		key = &namedKey{}
+	}
+
+	if rotationPeriodRaw, ok := d.GetOk("rotation_period"); ok || req.Operation == logical.CreateOperation {
+		if !ok {
+			rotationPeriodRaw = d.Get("rotation_period")
+		}
+		key.RotationPeriod = time.Duration(rotationPeriodRaw.(int)) * time.Second
+	}
+	if key.RotationPeriod < time.Minute {
+		return logical.ErrorResponse("rotation_period must be at least one minute"), nil
+	}
+
+	if verificationTTLRaw, ok := d.GetOk("verification_ttl"); ok || req.Operation == logical.CreateOperation {
+		if !ok {
+			verificationTTLRaw = d.Get("verification_ttl")
+		}
+		key.VerificationTTL = time.Duration(verificationTTLRaw.(int)) * time.Second
+	}
+
+	if allowedClientIDsRaw, ok := d.GetOk("allowed_client_ids"); ok || req.Operation == logical.CreateOperation {
+		if !ok {
+			allowedClientIDsRaw = d.Get("allowed_client_ids")
+		}
+		key.AllowedClientIDs = allowedClientIDsRaw.([]string)
+	}
+
+	prevAlgorithm := key.SigningAlgorithm
+	if algorithmRaw, ok := d.GetOk("algorithm"); ok || req.Operation == logical.CreateOperation {
+		if !ok {
+			algorithmRaw = d.Get("algorithm")
+		}
+		key.SigningAlgorithm = algorithmRaw.(string)
+	}
+	if !strutil.StrListContains(supportedOIDCAlgs, key.SigningAlgorithm) {
+		return logical.ErrorResponse(fmt.Sprintf("unknown signing algorithm %q", key.SigningAlgorithm)), nil
+	}
+
+	// Tokens must remain verifiable for as long as they are valid
+	roles, err := i.rolesReferencingKey(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	for roleName, role := range roles {
+		if role.TokenTTL > key.VerificationTTL {
+			return logical.ErrorResponse(fmt.Sprintf("verification_ttl must not be shorter than the ttl of role %q", roleName)), nil
+		}
+	}
+	clients, err := i.clientsReferencingKey(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	for clientName, c := range clients {
+		if c.IDTokenTTL > key.VerificationTTL {
+			return logical.ErrorResponse(fmt.Sprintf("verification_ttl must not be shorter than the id_token_ttl of client %q", clientName)), nil
+		}
+	}
+
+	// A new key, or a key with a different algorithm, gets a new keypair
+	if key.SigningKey == nil || prevAlgorithm != key.SigningAlgorithm {
+		if err := i.rotateNamedKey(ctx, req.Storage, key, key.VerificationTTL); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := i.putNamedKey(ctx, req.Storage, name, key); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (i *IdentityStore) pathOIDCReadKey(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.oidcLock.RLock()
+	defer i.oidcLock.RUnlock()
+
+	key, err := i.getNamedKey(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"rotation_period":    int64(key.RotationPeriod.Seconds()),
+			"verification_ttl":   int64(key.VerificationTTL.Seconds()),
+			"algorithm":          key.SigningAlgorithm,
+			"allowed_client_ids": key.AllowedClientIDs,
+		},
+	}, nil
+}
+
+func (i *IdentityStore) pathOIDCDeleteKey(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	roles, err := i.rolesReferencingKey(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) > 0 {
+		var roleNames []string
+		for roleName := range roles {
+			roleNames = append(roleNames, roleName)
+		}
+		sort.Strings(roleNames)
+		return logical.ErrorResponse(fmt.Sprintf("unable to delete key %q because it is currently referenced by these roles: %s", name, strings.Join(roleNames, ", "))), nil
+	}
+	clients, err := i.clientsReferencingKey(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(clients) > 0 {
+		var clientNames []string
+		for clientName := range clients {
+			clientNames = append(clientNames, clientName)
+		}
+		sort.Strings(clientNames)
+		return logical.ErrorResponse(fmt.Sprintf("unable to delete key %q because it is currently referenced by these clients: %s", name, strings.Join(clientNames, ", "))), nil
+	}
+
+	key, err := i.getNamedKey(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, nil
+	}
+
+	for _, k := range key.KeyRing {
+		if err := req.Storage.Delete(ctx, publicKeysConfigPath+k.KeyID); err != nil {
+			return nil, err
+		}
+	}
+	if err := req.Storage.Delete(ctx, namedKeyConfigPath+name); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (i *IdentityStore) pathOIDCListKey(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	keys, err := req.Storage.List(ctx, namedKeyConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(keys), nil
+}
+
+func (i *IdentityStore) pathOIDCRotateKey(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	key, err := i.getNamedKey(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return logical.ErrorResponse(fmt.Sprintf("key %q does not exist", name)), nil
+	}
+
+	verificationTTL := key.VerificationTTL
+	if verificationTTLRaw, ok := d.GetOk("verification_ttl"); ok {
+		verificationTTL = time.Duration(verificationTTLRaw.(int)) * time.Second
+	}
+
+	if err := i.rotateNamedKey(ctx, req.Storage, key, verificationTTL); err != nil {
+		return nil, err
+	}
+	if err := i.putNamedKey(ctx, req.Storage, name, key); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// rotateNamedKey generates a new signing key for the named key, and keeps the
+// previous key available for verification for the given TTL. The caller must
+// persist the named key.
+func (i *IdentityStore) rotateNamedKey(ctx context.Context, s logical.Storage, key *namedKey, verificationTTL time.Duration) error {
+	now := time.Now()
+
+	if key.SigningKey != nil {
+		for _, k := range key.KeyRing {
+			if k.KeyID == key.SigningKey.KeyID {
+				k.ExpireAt = now.Add(verificationTTL)
+			}
+		}
+	}
+
+	signingKey, err := generateOIDCKey(key.SigningAlgorithm)
+	if err != nil {
+		return err
+	}
+
+	publicKey := signingKey.Public()
+	entry, err := logical.StorageEntryJSON(publicKeysConfigPath+signingKey.KeyID, publicKey)
+	if err != nil {
+		return err
+	}
+	if err := s.Put(ctx, entry); err != nil {
+		return err
+	}
+
+	key.SigningKey = signingKey
+	key.KeyRing = append(key.KeyRing, &expireableKey{KeyID: signingKey.KeyID})
+	key.NextRotation = now.Add(key.RotationPeriod)
+
+	return i.expireNamedKeys(ctx, s, key, now)
+}
+
+// expireNamedKeys removes keys whose verification TTL has passed from the key
+// ring of the named key, and stops publishing them
+func (i *IdentityStore) expireNamedKeys(ctx context.Context, s logical.Storage, key *namedKey, now time.Time) error {
+	var keyRing []*expireableKey
+	for _, k := range key.KeyRing {
+		if !k.ExpireAt.IsZero() && now.After(k.ExpireAt) {
+			if err := s.Delete(ctx, publicKeysConfigPath+k.KeyID); err != nil {
+				return err
+			}
+			continue
+		}
+		keyRing = append(keyRing, k)
+	}
+	key.KeyRing = keyRing
+
+	return nil
+}
+
+// signClaims returns a JWT of the claims, signed with the current signing key
+func (k *namedKey) signClaims(claims map[string]interface{}) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{
+		Algorithm: jose.SignatureAlgorithm(k.SigningAlgorithm),
+		Key:       k.SigningKey,
+	}, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return "", err
+	}
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		return "", err
+	}
+
+	return jws.CompactSerialize()
+}
+
+func generateOIDCKey(algorithm string) (*jose.JSONWebKey, error) {
+	var privateKey interface{}
+	var err error
+
+	switch jose.SignatureAlgorithm(algorithm) {
+	case jose.RS256, jose.RS384, jose.RS512:
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case jose.ES256:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jose.ES384:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case jose.ES512:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case jose.EdDSA:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unknown signing algorithm %q", algorithm)
+	}
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to generate signing key: {{err}}", err)
+	}
+
+	keyID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	return &jose.JSONWebKey{
+		Key:       privateKey,
+		KeyID:     keyID,
+		Algorithm: algorithm,
+		Use:       "sig",
+	}, nil
+}
+
+// oidcPeriodicFunc rotates the named keys that are due and removes expired
+// public keys
+func (i *IdentityStore) oidcPeriodicFunc(ctx context.Context, s logical.Storage) error {
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	names, err := s.List(ctx, namedKeyConfigPath)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, name := range names {
+		key, err := i.getNamedKey(ctx, s, name)
+		if err != nil {
+			return err
+		}
+		if key == nil {
+			continue
+		}
+
+		// The key is only written back when it was rotated or some of its
+		// previous keys expired
+		if now.After(key.NextRotation) {
+			i.logger.Debug("rotating OIDC key", "name", name)
+			if err := i.rotateNamedKey(ctx, s, key, key.VerificationTTL); err != nil {
+				return err
+			}
+		} else {
+			keyCount := len(key.KeyRing)
+			if err := i.expireNamedKeys(ctx, s, key, now); err != nil {
+				return err
+			}
+			if len(key.KeyRing) == keyCount {
+				continue
+			}
+		}
+
+		if err := i.putNamedKey(ctx, s, name, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (i *IdentityStore) getOIDCRole(ctx context.Context, s logical.Storage, name string) (*oidcRole, error) {
+	entry, err := s.Get(ctx, roleConfigPath+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var role oidcRole
+	if err := entry.DecodeJSON(&role); err != nil {
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+// rolesReferencingKey returns the roles that sign tokens with the named key
+func (i *IdentityStore) rolesReferencingKey(ctx context.Context, s logical.Storage, keyName string) (map[string]*oidcRole, error) {
+	names, err := s.List(ctx, roleConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make(map[string]*oidcRole)
+	for _, name := range names {
+		role, err := i.getOIDCRole(ctx, s, name)
+		if err != nil {
+			return nil, err
+		}
+		if role != nil && role.Key == keyName {
+			roles[name] = role
+		}
+	}
+
+	return roles, nil
+}
+
+func (i *IdentityStore) pathOIDCRoleExistenceCheck(ctx context.Context, req *logical.Request, d *framework.FieldData) (bool, error) {
+	role, err := i.getOIDCRole(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		return false, err
+	}
+
+	return role != nil, nil
+}
+
+func (i *IdentityStore) pathOIDCCreateUpdateRole(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	role, err := i.getOIDCRole(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		if req.Operation == logical.UpdateOperation {
+			return logical.ErrorResponse(fmt.Sprintf("role %q does not exist", name)), nil
+		}
+		role = &oidcRole{}
+	}
+
+	if keyRaw, ok := d.GetOk("key"); ok {
+		role.Key = keyRaw.(string)
+	}
+	if role.Key == "" {
+		return logical.ErrorResponse("the key parameter is required"), nil
+	}
+
+	if templateRaw, ok := d.GetOk("template"); ok {
+		role.Template = templateRaw.(string)
+	}
+	if _, err := parseOIDCTemplate(role.Template, reservedOIDCClaims); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if ttlRaw, ok := d.GetOk("ttl"); ok || req.Operation == logical.CreateOperation {
+		if !ok {
+			ttlRaw = d.Get("ttl")
+		}
+		role.TokenTTL = time.Duration(ttlRaw.(int)) * time.Second
+	}
+
+	key, err := i.getNamedKey(ctx, req.Storage, role.Key)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return logical.ErrorResponse(fmt.Sprintf("key %q does not exist", role.Key)), nil
+	}
+	if role.TokenTTL > key.VerificationTTL {
+		return logical.ErrorResponse(fmt.Sprintf("ttl must not be longer than the verification_ttl of key %q", role.Key)), nil
+	}
+
+	if role.ClientID == "" {
+		if role.ClientID, err = uuid.GenerateUUID(); err != nil {
+			return nil, err
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON(roleConfigPath+name, role)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (i *IdentityStore) pathOIDCReadRole(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	role, err := i.getOIDCRole(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"key":       role.Key,
+			"template":  role.Template,
+			"ttl":       int64(role.TokenTTL.Seconds()),
+			"client_id": role.ClientID,
+		},
+	}, nil
+}
+
+func (i *IdentityStore) pathOIDCDeleteRole(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	if err := req.Storage.Delete(ctx, roleConfigPath+d.Get("name").(string)); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (i *IdentityStore) pathOIDCListRole(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roles, err := req.Storage.List(ctx, roleConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(roles), nil
+}
+
+// parseOIDCTemplate checks that a template is a JSON object that does not set
+// any of the reserved claims and only uses valid identity templates
+func parseOIDCTemplate(template string, reserved []string) (map[string]interface{}, error) {
+	claims := make(map[string]interface{})
+	if strings.TrimSpace(template) == "" {
+		return claims, nil
+	}
+
+	if err := json.Unmarshal([]byte(template), &claims); err != nil {
+		return nil, errors.New("template must be a JSON object")
+	}
+
+	for _, claim := range reserved {
+		if _, ok := claims[claim]; ok {
+			return nil, fmt.Errorf("top level key %q is reserved and cannot be set in the template", claim)
+		}
+	}
+
+	var check func(interface{}) error
+	check = func(value interface{}) error {
+		switch v := value.(type) {
+		case string:
+			_, _, err := identity.PopulateString(&identity.PopulateStringInput{
+				ValidityCheckOnly: true,
+				String:            v,
+			})
+			return err
+		case map[string]interface{}:
+			for _, elem := range v {
+				if err := check(elem); err != nil {
+					return err
+				}
+			}
+		case []interface{}:
+			for _, elem := range v {
+				if err := check(elem); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := check(claims); err != nil {
+		return nil, errwrap.Wrapf("error parsing template: {{err}}", err)
+	}
+
+	return claims, nil
+}
+
+// populateOIDCTemplate fills in the identity templates in the string values
+// of the claims. Values that cannot be populated for the entity are left out.
+func populateOIDCTemplate(value interface{}, input identity.PopulateStringInput) (interface{}, bool) {
+	switch v := value.(type) {
+	case string:
+		input.String = v
+		_, populated, err := identity.PopulateString(&input)
+		if err != nil {
+			return nil, false
+		}
+		return populated, true
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			if populated, ok := populateOIDCTemplate(elem, input); ok {
+				result[k] = populated
+			}
+		}
+		return result, true
+	case []interface{}:
+		result := make([]interface{}, 0, len(v))
+		for _, elem := range v {
+			if populated, ok := populateOIDCTemplate(elem, input); ok {
+				result = append(result, populated)
+			}
+		}
+		return result, true
+	}
+	return value, true
+}
+
+func (i *IdentityStore) pathOIDCGenerateToken(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	roleName := d.Get("name").(string)
+
+	i.oidcLock.RLock()
+	defer i.oidcLock.RUnlock()
+
+	role, err := i.getOIDCRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("role %q not found", roleName)), nil
+	}
+
+	key, err := i.getNamedKey(ctx, req.Storage, role.Key)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return logical.ErrorResponse(fmt.Sprintf("key %q not found", role.Key)), nil
+	}
+	if !strutil.StrListContains(key.AllowedClientIDs, "*") && !strutil.StrListContains(key.AllowedClientIDs, role.ClientID) {
+		return logical.ErrorResponse(fmt.Sprintf("the key %q does not list the client ID of the role %q as an allowed client ID", role.Key, roleName)), nil
+	}
+
+	if req.EntityID == "" {
+		return logical.ErrorResponse("no entity associated with the request's token"), nil
+	}
+	entity, err := i.MemDBEntityByID(req.EntityID, true)
+	if err != nil {
+		return nil, err
+	}
+	if entity == nil {
+		return logical.ErrorResponse(fmt.Sprintf("entity %q not found", req.EntityID)), nil
+	}
+	if entity.Disabled {
+		return logical.ErrorResponse(fmt.Sprintf("entity %q is disabled", req.EntityID)), nil
+	}
+
+	groups, inheritedGroups, err := i.groupsByEntityID(entity.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	issuer, err := i.oidcIssuer(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := parseOIDCTemplate(role.Template, reservedOIDCClaims)
+	if err != nil {
+		return nil, err
+	}
+	populated, _ := populateOIDCTemplate(template, identity.PopulateStringInput{
+		Entity:    entity,
+		Groups:    append(groups, inheritedGroups...),
+		Namespace: ns,
+	})
+	claims := populated.(map[string]interface{})
+
+	now := time.Now()
+	claims["iss"] = issuer
+	claims["sub"] = entity.ID
+	claims["aud"] = role.ClientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(role.TokenTTL).Unix()
+	claims["namespace"] = ns.ID
+
+	token, err := key.signClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"token":     token,
+			"client_id": role.ClientID,
+			"ttl":       int64(role.TokenTTL.Seconds()),
+		},
+	}, nil
+}
+
+func (i *IdentityStore) pathOIDCDiscovery(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	issuer, err := i.oidcIssuer(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(&discovery{
+		Issuer:        issuer,
+		Keys:          issuer + "/.well-known/keys",
+		ResponseTypes: []string{"id_token"},
+		Subjects:      []string{"public"},
+		IDTokenAlgs:   supportedOIDCAlgs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return oidcRawResponse(body), nil
+}
+
+func (i *IdentityStore) pathOIDCReadPublicKeys(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.oidcLock.RLock()
+	defer i.oidcLock.RUnlock()
+
+	keyIDs, err := req.Storage.List(ctx, publicKeysConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	jwks := &jose.JSONWebKeySet{
+		Keys: make([]jose.JSONWebKey, 0, len(keyIDs)),
+	}
+	for _, keyID := range keyIDs {
+		entry, err := req.Storage.Get(ctx, publicKeysConfigPath+keyID)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+
+		var key jose.JSONWebKey
+		if err := entry.DecodeJSON(&key); err != nil {
+			return nil, err
+		}
+		jwks.Keys = append(jwks.Keys, key)
+	}
+
+	body, err := json.Marshal(jwks)
+	if err != nil {
+		return nil, err
+	}
+
+	return oidcRawResponse(body), nil
+}
+
+// oidcRawResponse returns the well-known documents as is, since OIDC clients
+// expect them at the top level of the response body
+func oidcRawResponse(body []byte) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/json",
+			logical.HTTPRawBody:     body,
+			logical.HTTPStatusCode:  http.StatusOK,
+		},
+	}
+}
+
+var oidcHelp = map[string][2]string{
+	"oidc-config": {
+		"OIDC configuration",
+		"Update OIDC configuration in the identity backend",
+	},
+	"oidc-key": {
+		"CRUD operations for OIDC keys.",
+		"Create, Read, Update or Delete a named OIDC key used by roles to sign tokens.",
+	},
+	"oidc-key-list": {
+		"List OIDC keys",
+		"List all named OIDC keys",
+	},
+	"oidc-rotate-key": {
+		"Rotate a named OIDC key.",
+		"Manually rotate a named OIDC key. Rotating a named key will cause a new underlying signing key to be generated. The public portion of the underlying rotated signing key will continue to live for the verification_ttl duration.",
+	},
+	"oidc-role": {
+		"CRUD operations on OIDC Roles",
+		"Create a role which can be used to generate tokens. The claims of the tokens are rendered from the role's template using the entity and groups of the requesting token.",
+	},
+	"oidc-role-list": {
+		"List configured OIDC roles",
+		"List all configured OIDC roles in the identity backend.",
+	},
+	"oidc-token": {
+		"Generate an OIDC token",
+		"Generate an OIDC token against a configured role. The vault token used to call this path must have a corresponding entity.",
+	},
+	"oidc-discovery": {
+		"Query OIDC configurations",
+		"Query this path to retrieve the configured OIDC Issuer and Keys endpoints, response types, subject types, and signing algorithms used by the OIDC backend.",
+	},
+	"oidc-public-keys": {
+		"Retrieve public keys",
+		"Query this path to retrieve the public portion of keys used to sign OIDC tokens. Clients can use this to validate the authenticity of the OIDC token claims.",
+	},
+}
@@ -0,0 +1,1651 @@
+package vault
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/base62"
+	"github.com/hashicorp/vault/helper/identity"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const (
+	// Storage prefixes of the OIDC provider
+	oidcProviderPrefix    = "oidc_provider/"
+	assignmentPath        = oidcProviderPrefix + "assignment/"
+	scopePath             = oidcProviderPrefix + "scope/"
+	clientPath            = oidcProviderPrefix + "client/"
+	providerPath          = oidcProviderPrefix + "provider/"
+	authCodeCachePath     = oidcProviderPrefix + "auth_code/"
+	accessTokenCachePath  = oidcProviderPrefix + "access_token/"
+	openIDScope           = "openid"
+	clientTypeConfidental = "confidential"
+	clientTypePublic      = "public"
+	codeChallengePlain    = "plain"
+	codeChallengeS256     = "S256"
+
+	// authCodeTTL is how long authorization codes can be exchanged for
+	// tokens
+	authCodeTTL = 5 * time.Minute
+)
+
+var (
+	// reservedIDTokenClaims are set by Vault and cannot be set by a scope's
+	// template
+	reservedIDTokenClaims = []string{
+		"iat",
+		"aud",
+		"exp",
+		"iss",
+		"sub",
+		"nonce",
+		"auth_time",
+		"at_hash",
+		"c_hash",
+		"azp",
+	}
+
+	// oidcProviderClientPathRe matches the OIDC provider endpoints that
+	// clients authenticate to with the Authorization header, rather than with
+	// a Vault token
+	oidcProviderClientPathRe = regexp.MustCompile(`^identity/oidc/provider/[^/]+/(token|userinfo)/?$`)
+)
+
+// IsOIDCProviderClientPath returns whether the request path, relative to its
+// namespace, is one that OIDC clients authenticate to with the Authorization
+// header
+func IsOIDCProviderClientPath(path string) bool {
+	return oidcProviderClientPathRe.MatchString(path)
+}
+
+type assignment struct {
+	EntityIDs []string `json:"entity_ids"`
+	GroupIDs  []string `json:"group_ids"`
+}
+
+type scope struct {
+	Template    string `json:"template"`
+	Description string `json:"description"`
+}
+
+type client struct {
+	RedirectURIs   []string      `json:"redirect_uris"`
+	Assignments    []string      `json:"assignments"`
+	Key            string        `json:"key"`
+	IDTokenTTL     time.Duration `json:"id_token_ttl"`
+	AccessTokenTTL time.Duration `json:"access_token_ttl"`
+	Type           string        `json:"type"`
+	ClientID       string        `json:"client_id"`
+	ClientSecret   string        `json:"client_secret"`
+}
+
+type provider struct {
+	Issuer           string   `json:"issuer"`
+	AllowedClientIDs []string `json:"allowed_client_ids"`
+	ScopesSupported  []string `json:"scopes_supported"`
+}
+
+// authCodeCacheEntry is stored for an authorization code until it is
+// exchanged for tokens
+type authCodeCacheEntry struct {
+	Provider            string    `json:"provider"`
+	ClientID            string    `json:"client_id"`
+	EntityID            string    `json:"entity_id"`
+	RedirectURI         string    `json:"redirect_uri"`
+	Scopes              []string  `json:"scopes"`
+	Nonce               string    `json:"nonce"`
+	CodeChallenge       string    `json:"code_challenge"`
+	CodeChallengeMethod string    `json:"code_challenge_method"`
+	AuthTime            time.Time `json:"auth_time"`
+	ExpireAt            time.Time `json:"expire_at"`
+}
+
+// accessTokenCacheEntry is stored for an access token until it expires
+type accessTokenCacheEntry struct {
+	Provider string    `json:"provider"`
+	ClientID string    `json:"client_id"`
+	EntityID string    `json:"entity_id"`
+	Scopes   []string  `json:"scopes"`
+	ExpireAt time.Time `json:"expire_at"`
+}
+
+type providerDiscovery struct {
+	Issuer                string   `json:"issuer"`
+	Keys                  string   `json:"jwks_uri"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+	RequestURIParameter   bool     `json:"request_uri_parameter_supported"`
+	IDTokenAlgs           []string `json:"id_token_signing_alg_values_supported"`
+	ResponseTypes         []string `json:"response_types_supported"`
+	Scopes                []string `json:"scopes_supported"`
+	Subjects              []string `json:"subject_types_supported"`
+	GrantTypes            []string `json:"grant_types_supported"`
+	AuthMethods           []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethods  []string `json:"code_challenge_methods_supported"`
+}
+
+func oidcProviderPaths(i *IdentityStore) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "oidc/assignment/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the assignment",
+				},
+				"entity_ids": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma separated string or array of identity entity IDs",
+				},
+				"group_ids": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma separated string or array of identity group IDs",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.CreateOperation: i.pathOIDCCreateUpdateAssignment,
+				logical.UpdateOperation: i.pathOIDCCreateUpdateAssignment,
+				logical.ReadOperation:   i.pathOIDCReadAssignment,
+				logical.DeleteOperation: i.pathOIDCDeleteAssignment,
+			},
+			ExistenceCheck: i.pathOIDCProviderExistenceCheck(assignmentPath),
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-assignment"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-assignment"][1]),
+		},
+		{
+			Pattern: "oidc/assignment/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: i.pathOIDCProviderList(assignmentPath),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-assignment-list"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-assignment-list"][1]),
+		},
+		{
+			Pattern: "oidc/scope/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the scope",
+				},
+				"template": {
+					Type:        framework.TypeString,
+					Description: "The template string to use for the scope. This must be a JSON object, and may contain identity templates.",
+				},
+				"description": {
+					Type:        framework.TypeString,
+					Description: "The description of the scope",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.CreateOperation: i.pathOIDCCreateUpdateScope,
+				logical.UpdateOperation: i.pathOIDCCreateUpdateScope,
+				logical.ReadOperation:   i.pathOIDCReadScope,
+				logical.DeleteOperation: i.pathOIDCDeleteScope,
+			},
+			ExistenceCheck: i.pathOIDCProviderExistenceCheck(scopePath),
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-scope"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-scope"][1]),
+		},
+		{
+			Pattern: "oidc/scope/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: i.pathOIDCProviderList(scopePath),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-scope-list"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-scope-list"][1]),
+		},
+		{
+			Pattern: "oidc/client/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the client.",
+				},
+				"redirect_uris": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma separated string or array of redirect URIs used by the client. One of these values must exactly match the redirect_uri parameter value used in each authentication request.",
+				},
+				"assignments": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma separated string or array of assignment resources.",
+				},
+				"key": {
+					Type:        framework.TypeString,
+					Description: "A reference to a named key resource. Cannot be modified after creation.",
+				},
+				"id_token_ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "The time-to-live for ID tokens obtained by the client.",
+					Default:     "24h",
+				},
+				"access_token_ttl": {
+					Type:        framework.TypeDurationSecond,
+					Description: "The time-to-live for access tokens obtained by the client.",
+					Default:     "24h",
+				},
+				"client_type": {
+					Type:        framework.TypeString,
+					Description: "The client type based on its ability to maintain confidentiality of credentials. The following client types are supported: 'confidential', 'public'. Defaults to 'confidential'. Cannot be modified after creation.",
+					Default:     clientTypeConfidental,
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.CreateOperation: i.pathOIDCCreateUpdateClient,
+				logical.UpdateOperation: i.pathOIDCCreateUpdateClient,
+				logical.ReadOperation:   i.pathOIDCReadClient,
+				logical.DeleteOperation: i.pathOIDCDeleteClient,
+			},
+			ExistenceCheck: i.pathOIDCProviderExistenceCheck(clientPath),
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-client"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-client"][1]),
+		},
+		{
+			Pattern: "oidc/client/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: i.pathOIDCProviderList(clientPath),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-client-list"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-client-list"][1]),
+		},
+		{
+			Pattern: "oidc/provider/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the provider",
+				},
+				"issuer": {
+					Type:        framework.TypeString,
+					Description: "Specifies what will be used for the iss claim of ID tokens. If not set, Vault's api_addr will be used.",
+				},
+				"allowed_client_ids": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "The client IDs that are permitted to use the provider. If '*', all clients are allowed.",
+					Default:     []string{"*"},
+				},
+				"scopes_supported": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "The scopes supported for requesting on the provider",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.CreateOperation: i.pathOIDCCreateUpdateProvider,
+				logical.UpdateOperation: i.pathOIDCCreateUpdateProvider,
+				logical.ReadOperation:   i.pathOIDCReadProvider,
+				logical.DeleteOperation: i.pathOIDCDeleteProvider,
+			},
+			ExistenceCheck: i.pathOIDCProviderExistenceCheck(providerPath),
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-provider"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-provider"][1]),
+		},
+		{
+			Pattern: "oidc/provider/?$",
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ListOperation: i.pathOIDCProviderList(providerPath),
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-provider-list"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-provider-list"][1]),
+		},
+		{
+			Pattern: "oidc/provider/" + framework.GenericNameRegex("name") + "/.well-known/openid-configuration",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the provider",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation: i.pathOIDCProviderDiscovery,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-provider-discovery"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-provider-discovery"][1]),
+		},
+		{
+			Pattern: "oidc/provider/" + framework.GenericNameRegex("name") + "/.well-known/keys",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the provider",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation: i.pathOIDCProviderReadPublicKeys,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-provider-public-keys"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-provider-public-keys"][1]),
+		},
+		{
+			Pattern: "oidc/provider/" + framework.GenericNameRegex("name") + "/authorize",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the provider",
+				},
+				"client_id": {
+					Type:        framework.TypeString,
+					Description: "The ID of the requesting client.",
+				},
+				"scope": {
+					Type:        framework.TypeString,
+					Description: "A space-delimited, case-sensitive list of scopes to be requested. The 'openid' scope is required.",
+				},
+				"redirect_uri": {
+					Type:        framework.TypeString,
+					Description: "The redirection URI to which the response will be sent.",
+				},
+				"response_type": {
+					Type:        framework.TypeString,
+					Description: "The OIDC authentication flow to be used. The following response types are supported: 'code'",
+				},
+				"state": {
+					Type:        framework.TypeString,
+					Description: "The value used to maintain state between the authentication request and client callback.",
+				},
+				"nonce": {
+					Type:        framework.TypeString,
+					Description: "The value that will be returned in the ID token nonce claim after a token exchange.",
+				},
+				"code_challenge": {
+					Type:        framework.TypeString,
+					Description: "The code challenge derived from the code verifier, as described in RFC 7636. Required for public clients.",
+				},
+				"code_challenge_method": {
+					Type:        framework.TypeString,
+					Description: "The method that was used to derive the code challenge. The following methods are supported: 'S256', 'plain'. Defaults to 'plain'.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation:   i.pathOIDCAuthorize,
+				logical.UpdateOperation: i.pathOIDCAuthorize,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-provider-authorize"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-provider-authorize"][1]),
+		},
+		{
+			Pattern: "oidc/provider/" + framework.GenericNameRegex("name") + "/token",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the provider",
+				},
+				"grant_type": {
+					Type:        framework.TypeString,
+					Description: "The authorization grant type. The following grant types are supported: 'authorization_code'.",
+				},
+				"code": {
+					Type:        framework.TypeString,
+					Description: "The authorization code received from the provider's authorization endpoint.",
+				},
+				"redirect_uri": {
+					Type:        framework.TypeString,
+					Description: "The callback location where the authentication response was sent.",
+				},
+				"code_verifier": {
+					Type:        framework.TypeString,
+					Description: "The code verifier associated with the authorization code.",
+				},
+				"client_id": {
+					Type:        framework.TypeString,
+					Description: "The ID of the requesting client, if it does not authenticate with the Authorization header.",
+				},
+				"client_secret": {
+					Type:        framework.TypeString,
+					Description: "The secret of the requesting client, if it does not authenticate with the Authorization header.",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: i.pathOIDCToken,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-provider-token"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-provider-token"][1]),
+		},
+		{
+			Pattern: "oidc/provider/" + framework.GenericNameRegex("name") + "/userinfo",
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the provider",
+				},
+			},
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.ReadOperation:   i.pathOIDCUserInfo,
+				logical.UpdateOperation: i.pathOIDCUserInfo,
+			},
+
+			HelpSynopsis:    strings.TrimSpace(oidcProviderHelp["oidc-provider-userinfo"][0]),
+			HelpDescription: strings.TrimSpace(oidcProviderHelp["oidc-provider-userinfo"][1]),
+		},
+	}
+}
+
+func getOIDCProviderEntry(ctx context.Context, s logical.Storage, key string, out interface{}) (bool, error) {
+	entry, err := s.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if entry == nil {
+		return false, nil
+	}
+
+	if err := entry.DecodeJSON(out); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func putOIDCProviderEntry(ctx context.Context, s logical.Storage, key string, value interface{}) error {
+	entry, err := logical.StorageEntryJSON(key, value)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+func (i *IdentityStore) pathOIDCProviderExistenceCheck(prefix string) framework.ExistenceFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (bool, error) {
+		entry, err := req.Storage.Get(ctx, prefix+d.Get("name").(string))
+		if err != nil {
+			return false, err
+		}
+
+		return entry != nil, nil
+	}
+}
+
+func (i *IdentityStore) pathOIDCProviderList(prefix string) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		keys, err := req.Storage.List(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		return logical.ListResponse(keys), nil
+	}
+}
+
+// namesReferencing returns the names of the entries under the prefix for which
+// the references function returns true
+func namesReferencing(ctx context.Context, s logical.Storage, prefix string, newEntry func() interface{}, references func(interface{}) bool) ([]string, error) {
+	names, err := s.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var referencing []string
+	for _, name := range names {
+		entry := newEntry()
+		ok, err := getOIDCProviderEntry(ctx, s, prefix+name, entry)
+		if err != nil {
+			return nil, err
+		}
+		if ok && references(entry) {
+			referencing = append(referencing, name)
+		}
+	}
+	sort.Strings(referencing)
+
+	return referencing, nil
+}
+
+// clientsReferencingKey returns the clients that sign ID tokens with the named
+// key
+func (i *IdentityStore) clientsReferencingKey(ctx context.Context, s logical.Storage, keyName string) (map[string]*client, error) {
+	names, err := s.List(ctx, clientPath)
+	if err != nil {
+		return nil, err
+	}
+
+	clients := make(map[string]*client)
+	for _, name := range names {
+		var c client
+		ok, err := getOIDCProviderEntry(ctx, s, clientPath+name, &c)
+		if err != nil {
+			return nil, err
+		}
+		if ok && c.Key == keyName {
+			clients[name] = &c
+		}
+	}
+
+	return clients, nil
+}
+
+func (i *IdentityStore) pathOIDCCreateUpdateAssignment(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	var a assignment
+	if _, err := getOIDCProviderEntry(ctx, req.Storage, assignmentPath+name, &a); err != nil {
+		return nil, err
+	}
+
+	if entityIDsRaw, ok := d.GetOk("entity_ids"); ok {
+		a.EntityIDs = entityIDsRaw.([]string)
+	}
+	if groupIDsRaw, ok := d.GetOk("group_ids"); ok {
+		a.GroupIDs = groupIDsRaw.([]string)
+	}
+
+	for _, entityID := range a.EntityIDs {
+		entity, err := i.MemDBEntityByID(entityID, false)
+		if err != nil {
+			return nil, err
+		}
+		if entity == nil {
+			return logical.ErrorResponse(fmt.Sprintf("entity %q does not exist", entityID)), nil
+		}
+	}
+	for _, groupID := range a.GroupIDs {
+		group, err := i.MemDBGroupByID(groupID, false)
+		if err != nil {
+			return nil, err
+		}
+		if group == nil {
+			return logical.ErrorResponse(fmt.Sprintf("group %q does not exist", groupID)), nil
+		}
+	}
+
+	if err := putOIDCProviderEntry(ctx, req.Storage, assignmentPath+name, &a); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (i *IdentityStore) pathOIDCReadAssignment(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	var a assignment
+	ok, err := getOIDCProviderEntry(ctx, req.Storage, assignmentPath+d.Get("name").(string), &a)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"entity_ids": a.EntityIDs,
+			"group_ids":  a.GroupIDs,
+		},
+	}, nil
+}
+
+func (i *IdentityStore) pathOIDCDeleteAssignment(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	clientNames, err := namesReferencing(ctx, req.Storage, clientPath, func() interface{} { return &client{} }, func(entry interface{}) bool {
+		return strutil.StrListContains(entry.(*client).Assignments, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(clientNames) > 0 {
+		return logical.ErrorResponse(fmt.Sprintf("unable to delete assignment %q because it is currently referenced by these clients: %s", name, strings.Join(clientNames, ", "))), nil
+	}
+
+	if err := req.Storage.Delete(ctx, assignmentPath+name); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (i *IdentityStore) pathOIDCCreateUpdateScope(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if name == openIDScope {
+		return logical.ErrorResponse(fmt.Sprintf("the %q scope name is reserved", openIDScope)), nil
+	}
+
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	var sc scope
+	if _, err := getOIDCProviderEntry(ctx, req.Storage, scopePath+name, &sc); err != nil {
+		return nil, err
+	}
+
+	if templateRaw, ok := d.GetOk("template"); ok {
+		sc.Template = templateRaw.(string)
+	}
+	if descriptionRaw, ok := d.GetOk("description"); ok {
+		sc.Description = descriptionRaw.(string)
+	}
+
+	if _, err := parseOIDCTemplate(sc.Template, reservedIDTokenClaims); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if err := putOIDCProviderEntry(ctx, req.Storage, scopePath+name, &sc); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (i *IdentityStore) pathOIDCReadScope(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	var sc scope
+	ok, err := getOIDCProviderEntry(ctx, req.Storage, scopePath+d.Get("name").(string), &sc)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"template":    sc.Template,
+			"description": sc.Description,
+		},
+	}, nil
+}
+
+func (i *IdentityStore) pathOIDCDeleteScope(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	providerNames, err := namesReferencing(ctx, req.Storage, providerPath, func() interface{} { return &provider{} }, func(entry interface{}) bool {
+		return strutil.StrListContains(entry.(*provider).ScopesSupported, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(providerNames) > 0 {
+		return logical.ErrorResponse(fmt.Sprintf("unable to delete scope %q because it is currently referenced by these providers: %s", name, strings.Join(providerNames, ", "))), nil
+	}
+
+	if err := req.Storage.Delete(ctx, scopePath+name); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (i *IdentityStore) pathOIDCCreateUpdateClient(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	var c client
+	exists, err := getOIDCProviderEntry(ctx, req.Storage, clientPath+name, &c)
+	if err != nil {
+		return nil, err
+	}
+	if !exists && req.Operation == logical.UpdateOperation {
+		return logical.ErrorResponse(fmt.Sprintf("client %q does not exist", name)), nil
+	}
+
+	if keyRaw, ok := d.GetOk("key"); ok {
+		if exists && keyRaw.(string) != c.Key {
+			return logical.ErrorResponse("key modification is not allowed"), nil
+		}
+		c.Key = keyRaw.(string)
+	}
+	if c.Key == "" {
+		return logical.ErrorResponse("the key parameter is required"), nil
+	}
+
+	if clientTypeRaw, ok := d.GetOk("client_type"); ok || !exists {
+		if !ok {
+			clientTypeRaw = d.Get("client_type")
+		}
+		if exists && clientTypeRaw.(string) != c.Type {
+			return logical.ErrorResponse("client_type modification is not allowed"), nil
+		}
+		c.Type = clientTypeRaw.(string)
+	}
+	if c.Type != clientTypeConfidental && c.Type != clientTypePublic {
+		return logical.ErrorResponse(fmt.Sprintf("invalid client_type %q", c.Type)), nil
+	}
+
+	if redirectURIsRaw, ok := d.GetOk("redirect_uris"); ok {
+		c.RedirectURIs = redirectURIsRaw.([]string)
+	}
+	for _, redirectURI := range c.RedirectURIs {
+		parsed, err := url.Parse(redirectURI)
+		if err != nil || !parsed.IsAbs() || parsed.Fragment != "" {
+			return logical.ErrorResponse(fmt.Sprintf("redirect URI %q must be an absolute URI without a fragment", redirectURI)), nil
+		}
+	}
+
+	if assignmentsRaw, ok := d.GetOk("assignments"); ok {
+		c.Assignments = assignmentsRaw.([]string)
+	}
+	for _, assignmentName := range c.Assignments {
+		entry, err := req.Storage.Get(ctx, assignmentPath+assignmentName)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			return logical.ErrorResponse(fmt.Sprintf("assignment %q does not exist", assignmentName)), nil
+		}
+	}
+
+	if idTokenTTLRaw, ok := d.GetOk("id_token_ttl"); ok || !exists {
+		if !ok {
+			idTokenTTLRaw = d.Get("id_token_ttl")
+		}
+		c.IDTokenTTL = time.Duration(idTokenTTLRaw.(int)) * time.Second
+	}
+	if accessTokenTTLRaw, ok := d.GetOk("access_token_ttl"); ok || !exists {
+		if !ok {
+			accessTokenTTLRaw = d.Get("access_token_ttl")
+		}
+		c.AccessTokenTTL = time.Duration(accessTokenTTLRaw.(int)) * time.Second
+	}
+
+	key, err := i.getNamedKey(ctx, req.Storage, c.Key)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return logical.ErrorResponse(fmt.Sprintf("key %q does not exist", c.Key)), nil
+	}
+	if c.IDTokenTTL > key.VerificationTTL {
+		return logical.ErrorResponse(fmt.Sprintf("id_token_ttl must not be longer than the verification_ttl of key %q", c.Key)), nil
+	}
+
+	if c.ClientID == "" {
+		if c.ClientID, err = base62.Random(32); err != nil {
+			return nil, err
+		}
+		if c.Type == clientTypeConfidental {
+			secret, err := base62.Random(64)
+			if err != nil {
+				return nil, err
+			}
+			c.ClientSecret = "hvo_secret_" + secret
+		}
+	}
+
+	if err := putOIDCProviderEntry(ctx, req.Storage, clientPath+name, &c); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (i *IdentityStore) pathOIDCReadClient(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	var c client
+	ok, err := getOIDCProviderEntry(ctx, req.Storage, clientPath+d.Get("name").(string), &c)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	data := map[string]interface{}{
+		"redirect_uris":    c.RedirectURIs,
+		"assignments":      c.Assignments,
+		"key":              c.Key,
+		"id_token_ttl":     int64(c.IDTokenTTL.Seconds()),
+		"access_token_ttl": int64(c.AccessTokenTTL.Seconds()),
+		"client_type":      c.Type,
+		"client_id":        c.ClientID,
+	}
+	if c.Type == clientTypeConfidental {
+		data["client_secret"] = c.ClientSecret
+	}
+
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
+
+func (i *IdentityStore) pathOIDCDeleteClient(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	if err := req.Storage.Delete(ctx, clientPath+d.Get("name").(string)); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// clientByClientID returns the client with the given client ID
+func (i *IdentityStore) clientByClientID(ctx context.Context, s logical.Storage, clientID string) (*client, error) {
+	if clientID == "" {
+		return nil, nil
+	}
+
+	names, err := s.List(ctx, clientPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		var c client
+		ok, err := getOIDCProviderEntry(ctx, s, clientPath+name, &c)
+		if err != nil {
+			return nil, err
+		}
+		if ok && c.ClientID == clientID {
+			return &c, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (i *IdentityStore) pathOIDCCreateUpdateProvider(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	var p provider
+	exists, err := getOIDCProviderEntry(ctx, req.Storage, providerPath+name, &p)
+	if err != nil {
+		return nil, err
+	}
+
+	if issuerRaw, ok := d.GetOk("issuer"); ok {
+		issuer := issuerRaw.(string)
+		if issuer != "" {
+			parsed, err := url.Parse(issuer)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return logical.ErrorResponse("issuer must be an http or https URL"), nil
+			}
+			if parsed.RawQuery != "" || parsed.Fragment != "" || (parsed.Path != "" && parsed.Path != "/") {
+				return logical.ErrorResponse("issuer must only contain scheme, host and port"), nil
+			}
+		}
+		p.Issuer = strings.TrimSuffix(issuer, "/")
+	}
+
+	if allowedClientIDsRaw, ok := d.GetOk("allowed_client_ids"); ok || !exists {
+		if !ok {
+			allowedClientIDsRaw = d.Get("allowed_client_ids")
+		}
+		p.AllowedClientIDs = allowedClientIDsRaw.([]string)
+	}
+
+	if scopesRaw, ok := d.GetOk("scopes_supported"); ok {
+		p.ScopesSupported = scopesRaw.([]string)
+	}
+	for _, scopeName := range p.ScopesSupported {
+		if scopeName == openIDScope {
+			return logical.ErrorResponse(fmt.Sprintf("the %q scope is always supported", openIDScope)), nil
+		}
+		entry, err := req.Storage.Get(ctx, scopePath+scopeName)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			return logical.ErrorResponse(fmt.Sprintf("scope %q does not exist", scopeName)), nil
+		}
+	}
+
+	if err := putOIDCProviderEntry(ctx, req.Storage, providerPath+name, &p); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (i *IdentityStore) pathOIDCReadProvider(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	var p provider
+	ok, err := getOIDCProviderEntry(ctx, req.Storage, providerPath+name, &p)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	issuer, err := i.providerIssuer(ctx, name, &p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"issuer":             issuer,
+			"allowed_client_ids": p.AllowedClientIDs,
+			"scopes_supported":   p.ScopesSupported,
+		},
+	}, nil
+}
+
+func (i *IdentityStore) pathOIDCDeleteProvider(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	if err := req.Storage.Delete(ctx, providerPath+d.Get("name").(string)); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// providerBaseURL returns the URL at which the provider's Vault is reached
+func (i *IdentityStore) providerBaseURL(p *provider) string {
+	if p.Issuer != "" {
+		return p.Issuer
+	}
+	return strings.TrimSuffix(i.core.redirectAddr, "/")
+}
+
+func (i *IdentityStore) providerIssuer(ctx context.Context, name string, p *provider) (string, error) {
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return i.providerBaseURL(p) + "/v1/" + ns.Path + "identity/oidc/provider/" + name, nil
+}
+
+func (i *IdentityStore) getProvider(ctx context.Context, s logical.Storage, name string) (*provider, error) {
+	var p provider
+	ok, err := getOIDCProviderEntry(ctx, s, providerPath+name, &p)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (p *provider) allowsClient(clientID string) bool {
+	return strutil.StrListContains(p.AllowedClientIDs, "*") || strutil.StrListContains(p.AllowedClientIDs, clientID)
+}
+
+func (i *IdentityStore) pathOIDCProviderDiscovery(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := i.getProvider(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, nil
+	}
+
+	issuer, err := i.providerIssuer(ctx, name, p)
+	if err != nil {
+		return nil, err
+	}
+
+	// Users are sent to the UI to log in, which then calls the authorize
+	// endpoint of the API with their Vault token
+	authorizationEndpoint := i.providerBaseURL(p) + "/ui/vault/identity/oidc/provider/" + name + "/authorize"
+	if ns.Path != "" {
+		authorizationEndpoint += "?namespace=" + url.QueryEscape(strings.TrimSuffix(ns.Path, "/"))
+	}
+
+	body, err := json.Marshal(&providerDiscovery{
+		Issuer:                issuer,
+		Keys:                  issuer + "/.well-known/keys",
+		AuthorizationEndpoint: authorizationEndpoint,
+		TokenEndpoint:         issuer + "/token",
+		UserinfoEndpoint:      issuer + "/userinfo",
+		IDTokenAlgs:           supportedOIDCAlgs,
+		ResponseTypes:         []string{"code"},
+		Scopes:                append([]string{openIDScope}, p.ScopesSupported...),
+		Subjects:              []string{"public"},
+		GrantTypes:            []string{"authorization_code"},
+		AuthMethods:           []string{"none", "client_secret_basic", "client_secret_post"},
+		CodeChallengeMethods:  []string{codeChallengePlain, codeChallengeS256},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return oidcRawResponse(body), nil
+}
+
+// pathOIDCProviderReadPublicKeys publishes the keys of the clients that can
+// use the provider
+func (i *IdentityStore) pathOIDCProviderReadPublicKeys(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	i.oidcLock.RLock()
+	defer i.oidcLock.RUnlock()
+
+	p, err := i.getProvider(ctx, req.Storage, d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, nil
+	}
+
+	clientNames, err := req.Storage.List(ctx, clientPath)
+	if err != nil {
+		return nil, err
+	}
+	keyNames := map[string]bool{}
+	for _, clientName := range clientNames {
+		var c client
+		ok, err := getOIDCProviderEntry(ctx, req.Storage, clientPath+clientName, &c)
+		if err != nil {
+			return nil, err
+		}
+		if ok && p.allowsClient(c.ClientID) {
+			keyNames[c.Key] = true
+		}
+	}
+
+	jwks := &jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{},
+	}
+	for keyName := range keyNames {
+		key, err := i.getNamedKey(ctx, req.Storage, keyName)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			continue
+		}
+		for _, k := range key.KeyRing {
+			var publicKey jose.JSONWebKey
+			ok, err := getOIDCProviderEntry(ctx, req.Storage, publicKeysConfigPath+k.KeyID, &publicKey)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				jwks.Keys = append(jwks.Keys, publicKey)
+			}
+		}
+	}
+
+	body, err := json.Marshal(jwks)
+	if err != nil {
+		return nil, err
+	}
+
+	return oidcRawResponse(body), nil
+}
+
+// entityAssignedToClient returns whether an assignment of the client lists
+// the entity or one of its groups
+func (i *IdentityStore) entityAssignedToClient(ctx context.Context, s logical.Storage, entity *identity.Entity, c *client) (bool, error) {
+	groups, inheritedGroups, err := i.groupsByEntityID(entity.ID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, assignmentName := range c.Assignments {
+		var a assignment
+		ok, err := getOIDCProviderEntry(ctx, s, assignmentPath+assignmentName, &a)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			continue
+		}
+
+		if strutil.StrListContains(a.EntityIDs, entity.ID) {
+			return true, nil
+		}
+		for _, group := range append(groups, inheritedGroups...) {
+			if strutil.StrListContains(a.GroupIDs, group.ID) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// scopeClaims renders the templates of the requested scopes for the entity
+func (i *IdentityStore) scopeClaims(ctx context.Context, s logical.Storage, entity *identity.Entity, scopes []string) (map[string]interface{}, error) {
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, inheritedGroups, err := i.groupsByEntityID(entity.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := make(map[string]interface{})
+	for _, scopeName := range scopes {
+		if scopeName == openIDScope {
+			continue
+		}
+
+		var sc scope
+		ok, err := getOIDCProviderEntry(ctx, s, scopePath+scopeName, &sc)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		template, err := parseOIDCTemplate(sc.Template, reservedIDTokenClaims)
+		if err != nil {
+			return nil, err
+		}
+		populated, _ := populateOIDCTemplate(template, identity.PopulateStringInput{
+			Entity:    entity,
+			Groups:    append(groups, inheritedGroups...),
+			Namespace: ns,
+		})
+		for claim, value := range populated.(map[string]interface{}) {
+			claims[claim] = value
+		}
+	}
+
+	return claims, nil
+}
+
+// authResponse returns the result of an authorization request, for the caller
+// to pass on to the client's redirect URI
+func authResponse(status int, data map[string]interface{}) (*logical.Response, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := oidcRawResponse(body)
+	resp.Data[logical.HTTPStatusCode] = status
+	return resp, nil
+}
+
+func authErrorResponse(state, errorCode, description string) (*logical.Response, error) {
+	return authResponse(http.StatusBadRequest, map[string]interface{}{
+		"error":             errorCode,
+		"error_description": description,
+		"state":             state,
+	})
+}
+
+// oidcCacheKey returns the storage key of an authorization code or access
+// token, which are only stored hashed
+func oidcCacheKey(prefix, secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return prefix + hex.EncodeToString(sum[:])
+}
+
+func (i *IdentityStore) pathOIDCAuthorize(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	p, err := i.getProvider(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse(fmt.Sprintf("provider %q not found", name)), nil
+	}
+
+	// Errors with the client or redirect URI cannot be sent to the client
+	clientID := d.Get("client_id").(string)
+	c, err := i.clientByClientID(ctx, req.Storage, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return logical.ErrorResponse(fmt.Sprintf("client with client_id %q not found", clientID)), nil
+	}
+	if !p.allowsClient(c.ClientID) {
+		return logical.ErrorResponse(fmt.Sprintf("client %q is not allowed by the provider", clientID)), nil
+	}
+	redirectURI := d.Get("redirect_uri").(string)
+	if !strutil.StrListContains(c.RedirectURIs, redirectURI) {
+		return logical.ErrorResponse("redirect_uri is not allowed for the client"), nil
+	}
+
+	state := d.Get("state").(string)
+	if responseType := d.Get("response_type").(string); responseType != "code" {
+		return authErrorResponse(state, "unsupported_response_type", fmt.Sprintf("unsupported response_type %q", responseType))
+	}
+
+	// Scopes that the provider does not support are ignored
+	requested := strings.Fields(d.Get("scope").(string))
+	if !strutil.StrListContains(requested, openIDScope) {
+		return authErrorResponse(state, "invalid_scope", fmt.Sprintf("the %q scope is required", openIDScope))
+	}
+	scopes := []string{openIDScope}
+	for _, scopeName := range requested {
+		if strutil.StrListContains(p.ScopesSupported, scopeName) && !strutil.StrListContains(scopes, scopeName) {
+			scopes = append(scopes, scopeName)
+		}
+	}
+
+	codeChallenge := d.Get("code_challenge").(string)
+	codeChallengeMethod := d.Get("code_challenge_method").(string)
+	switch {
+	case codeChallenge == "" && c.Type == clientTypePublic:
+		return authErrorResponse(state, "invalid_request", "PKCE is required for public clients")
+	case codeChallenge == "" && codeChallengeMethod != "":
+		return authErrorResponse(state, "invalid_request", "code_challenge_method requires a code_challenge")
+	case codeChallenge != "" && codeChallengeMethod == "":
+		codeChallengeMethod = codeChallengePlain
+	}
+	if codeChallenge != "" {
+		if codeChallengeMethod != codeChallengePlain && codeChallengeMethod != codeChallengeS256 {
+			return authErrorResponse(state, "invalid_request", fmt.Sprintf("unsupported code_challenge_method %q", codeChallengeMethod))
+		}
+		if len(codeChallenge) < 43 || len(codeChallenge) > 128 {
+			return authErrorResponse(state, "invalid_request", "code_challenge must be between 43 and 128 characters")
+		}
+	}
+
+	if req.EntityID == "" {
+		return authErrorResponse(state, "access_denied", "no entity associated with the request's token")
+	}
+	entity, err := i.MemDBEntityByID(req.EntityID, true)
+	if err != nil {
+		return nil, err
+	}
+	if entity == nil || entity.Disabled {
+		return authErrorResponse(state, "access_denied", "the entity of the request's token is not found or disabled")
+	}
+	assigned, err := i.entityAssignedToClient(ctx, req.Storage, entity, c)
+	if err != nil {
+		return nil, err
+	}
+	if !assigned {
+		return authErrorResponse(state, "access_denied", "the entity is not authorized by any assignment of the client")
+	}
+
+	code, err := base62.Random(32)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := putOIDCProviderEntry(ctx, req.Storage, oidcCacheKey(authCodeCachePath, code), &authCodeCacheEntry{
+		Provider:            name,
+		ClientID:            c.ClientID,
+		EntityID:            entity.ID,
+		RedirectURI:         redirectURI,
+		Scopes:              scopes,
+		Nonce:               d.Get("nonce").(string),
+		CodeChallenge:       codeChallenge,
+		CodeChallengeMethod: codeChallengeMethod,
+		AuthTime:            now,
+		ExpireAt:            now.Add(authCodeTTL),
+	}); err != nil {
+		return nil, err
+	}
+
+	return authResponse(http.StatusOK, map[string]interface{}{
+		"code":  code,
+		"state": state,
+	})
+}
+
+// tokenResponse returns a response of the token or userinfo endpoints, which
+// must not be cached
+func tokenResponse(status int, data map[string]interface{}, headers map[string][]string) (*logical.Response, error) {
+	resp, err := authResponse(status, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if headers == nil {
+		headers = map[string][]string{}
+	}
+	headers["Cache-Control"] = []string{"no-store"}
+	headers["Pragma"] = []string{"no-cache"}
+	resp.Data[logical.HTTPHeaders] = headers
+	return resp, nil
+}
+
+func tokenErrorResponse(status int, errorCode, description string) (*logical.Response, error) {
+	var headers map[string][]string
+	if status == http.StatusUnauthorized {
+		headers = map[string][]string{
+			"WWW-Authenticate": []string{`Basic realm="vault"`},
+		}
+	}
+	return tokenResponse(status, map[string]interface{}{
+		"error":             errorCode,
+		"error_description": description,
+	}, headers)
+}
+
+// authorizationHeader returns the value of the Authorization header with the
+// given scheme, if set
+func authorizationHeader(req *logical.Request, scheme string) (string, bool) {
+	for key, values := range req.Headers {
+		if !strings.EqualFold(key, "Authorization") || len(values) == 0 {
+			continue
+		}
+		split := strings.SplitN(values[0], " ", 2)
+		if len(split) == 2 && strings.EqualFold(split[0], scheme) {
+			return strings.TrimSpace(split[1]), true
+		}
+	}
+	return "", false
+}
+
+// clientCredentials returns the client ID and secret sent with the
+// client_secret_basic or client_secret_post methods. Public clients only send
+// their ID.
+func clientCredentials(req *logical.Request, d *framework.FieldData) (string, string, error) {
+	if basic, ok := authorizationHeader(req, "Basic"); ok {
+		decoded, err := base64.StdEncoding.DecodeString(basic)
+		if err != nil {
+			return "", "", err
+		}
+		split := strings.SplitN(string(decoded), ":", 2)
+		if len(split) != 2 {
+			return "", "", fmt.Errorf("malformed basic credentials")
+		}
+		clientID, err := url.QueryUnescape(split[0])
+		if err != nil {
+			return "", "", err
+		}
+		clientSecret, err := url.QueryUnescape(split[1])
+		if err != nil {
+			return "", "", err
+		}
+		return clientID, clientSecret, nil
+	}
+
+	return d.Get("client_id").(string), d.Get("client_secret").(string), nil
+}
+
+func (i *IdentityStore) pathOIDCToken(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	p, err := i.getProvider(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return tokenErrorResponse(http.StatusBadRequest, "invalid_request", fmt.Sprintf("provider %q not found", name))
+	}
+
+	clientID, clientSecret, err := clientCredentials(req, d)
+	if err != nil {
+		return tokenErrorResponse(http.StatusUnauthorized, "invalid_client", "malformed client credentials")
+	}
+	c, err := i.clientByClientID(ctx, req.Storage, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return tokenErrorResponse(http.StatusUnauthorized, "invalid_client", "client failed to authenticate")
+	}
+	if c.Type == clientTypeConfidental && subtle.ConstantTimeCompare([]byte(c.ClientSecret), []byte(clientSecret)) != 1 {
+		return tokenErrorResponse(http.StatusUnauthorized, "invalid_client", "client failed to authenticate")
+	}
+	if !p.allowsClient(c.ClientID) {
+		return tokenErrorResponse(http.StatusBadRequest, "unauthorized_client", "client is not allowed by the provider")
+	}
+
+	if grantType := d.Get("grant_type").(string); grantType != "authorization_code" {
+		return tokenErrorResponse(http.StatusBadRequest, "unsupported_grant_type", fmt.Sprintf("unsupported grant_type %q", grantType))
+	}
+	code := d.Get("code").(string)
+	if code == "" {
+		return tokenErrorResponse(http.StatusBadRequest, "invalid_request", "the code parameter is required")
+	}
+
+	// Codes can only be used once
+	i.oidcLock.Lock()
+	var authCode authCodeCacheEntry
+	codeKey := oidcCacheKey(authCodeCachePath, code)
+	found, err := getOIDCProviderEntry(ctx, req.Storage, codeKey, &authCode)
+	if err == nil && found {
+		err = req.Storage.Delete(ctx, codeKey)
+	}
+	i.oidcLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case !found, time.Now().After(authCode.ExpireAt), authCode.Provider != name, authCode.ClientID != c.ClientID:
+		return tokenErrorResponse(http.StatusBadRequest, "invalid_grant", "authorization grant is invalid or expired")
+	case authCode.RedirectURI != d.Get("redirect_uri").(string):
+		return tokenErrorResponse(http.StatusBadRequest, "invalid_grant", "redirect_uri does not match the authorization request")
+	}
+
+	if authCode.CodeChallenge != "" {
+		codeVerifier := d.Get("code_verifier").(string)
+		if codeVerifier == "" {
+			return tokenErrorResponse(http.StatusBadRequest, "invalid_request", "the code_verifier parameter is required")
+		}
+		challenge := codeVerifier
+		if authCode.CodeChallengeMethod == codeChallengeS256 {
+			sum := sha256.Sum256([]byte(codeVerifier))
+			challenge = base64.RawURLEncoding.EncodeToString(sum[:])
+		}
+		if subtle.ConstantTimeCompare([]byte(challenge), []byte(authCode.CodeChallenge)) != 1 {
+			return tokenErrorResponse(http.StatusBadRequest, "invalid_grant", "invalid code_verifier for the code_challenge")
+		}
+	}
+
+	entity, err := i.MemDBEntityByID(authCode.EntityID, true)
+	if err != nil {
+		return nil, err
+	}
+	if entity == nil || entity.Disabled {
+		return tokenErrorResponse(http.StatusBadRequest, "invalid_grant", "the entity of the authorization grant is not found or disabled")
+	}
+
+	key, err := i.getNamedKey(ctx, req.Storage, c.Key)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return tokenErrorResponse(http.StatusInternalServerError, "server_error", fmt.Sprintf("key %q of the client not found", c.Key))
+	}
+	if !strutil.StrListContains(key.AllowedClientIDs, "*") && !strutil.StrListContains(key.AllowedClientIDs, c.ClientID) {
+		return tokenErrorResponse(http.StatusBadRequest, "unauthorized_client", "the key of the client does not allow the client ID")
+	}
+
+	issuer, err := i.providerIssuer(ctx, name, p)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := i.scopeClaims(ctx, req.Storage, entity, authCode.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	claims["iss"] = issuer
+	claims["sub"] = entity.ID
+	claims["aud"] = c.ClientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(c.IDTokenTTL).Unix()
+	claims["auth_time"] = authCode.AuthTime.Unix()
+	if authCode.Nonce != "" {
+		claims["nonce"] = authCode.Nonce
+	}
+
+	idToken, err := key.signClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := base62.Random(64)
+	if err != nil {
+		return nil, err
+	}
+	if err := putOIDCProviderEntry(ctx, req.Storage, oidcCacheKey(accessTokenCachePath, accessToken), &accessTokenCacheEntry{
+		Provider: name,
+		ClientID: c.ClientID,
+		EntityID: entity.ID,
+		Scopes:   authCode.Scopes,
+		ExpireAt: now.Add(c.AccessTokenTTL),
+	}); err != nil {
+		return nil, err
+	}
+
+	return tokenResponse(http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int64(c.AccessTokenTTL.Seconds()),
+		"id_token":     idToken,
+	}, nil)
+}
+
+func (i *IdentityStore) pathOIDCUserInfo(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	invalidToken := func(description string) (*logical.Response, error) {
+		return tokenResponse(http.StatusUnauthorized, map[string]interface{}{
+			"error":             "invalid_token",
+			"error_description": description,
+		}, map[string][]string{
+			"WWW-Authenticate": []string{`Bearer error="invalid_token"`},
+		})
+	}
+
+	accessToken, ok := authorizationHeader(req, "Bearer")
+	if !ok || accessToken == "" {
+		return invalidToken("no access token given in the Authorization header")
+	}
+
+	var entry accessTokenCacheEntry
+	found, err := getOIDCProviderEntry(ctx, req.Storage, oidcCacheKey(accessTokenCachePath, accessToken), &entry)
+	if err != nil {
+		return nil, err
+	}
+	if !found || time.Now().After(entry.ExpireAt) || entry.Provider != name {
+		return invalidToken("access token is invalid or expired")
+	}
+
+	entity, err := i.MemDBEntityByID(entry.EntityID, true)
+	if err != nil {
+		return nil, err
+	}
+	if entity == nil || entity.Disabled {
+		return invalidToken("the entity of the access token is not found or disabled")
+	}
+
+	claims, err := i.scopeClaims(ctx, req.Storage, entity, entry.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	claims["sub"] = entity.ID
+
+	return tokenResponse(http.StatusOK, claims, nil)
+}
+
+// oidcProviderPeriodicFunc removes expired authorization codes and access
+// tokens
+func (i *IdentityStore) oidcProviderPeriodicFunc(ctx context.Context, s logical.Storage) error {
+	i.oidcLock.Lock()
+	defer i.oidcLock.Unlock()
+
+	now := time.Now()
+	for _, prefix := range []string{authCodeCachePath, accessTokenCachePath} {
+		keys, err := s.List(ctx, prefix)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			var entry struct {
+				ExpireAt time.Time `json:"expire_at"`
+			}
+			ok, err := getOIDCProviderEntry(ctx, s, prefix+key, &entry)
+			if err != nil {
+				return err
+			}
+			if ok && now.After(entry.ExpireAt) {
+				if err := s.Delete(ctx, prefix+key); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+var oidcProviderHelp = map[string][2]string{
+	"oidc-assignment": {
+		"CRUD operations for OIDC assignments.",
+		"Create, Read, Update, and Delete OIDC assignments. Assignments list the entities and groups that are allowed to use the clients that reference them.",
+	},
+	"oidc-assignment-list": {
+		"List OIDC assignments",
+		"List all configured OIDC assignments in the identity backend.",
+	},
+	"oidc-scope": {
+		"CRUD operations for OIDC scopes.",
+		"Create, Read, Update, and Delete OIDC scopes. The template of a scope is rendered into the claims of ID tokens and userinfo responses when the scope is requested.",
+	},
+	"oidc-scope-list": {
+		"List OIDC scopes",
+		"List all configured OIDC scopes in the identity backend.",
+	},
+	"oidc-client": {
+		"CRUD operations for OIDC clients.",
+		"Create, Read, Update, and Delete OIDC clients. A client is a relying party that requests ID tokens for the users that log in to it through an OIDC provider.",
+	},
+	"oidc-client-list": {
+		"List OIDC clients",
+		"List all configured OIDC clients in the identity backend.",
+	},
+	"oidc-provider": {
+		"CRUD operations for OIDC providers.",
+		"Create, Read, Update, and Delete OIDC named providers.",
+	},
+	"oidc-provider-list": {
+		"List OIDC providers",
+		"List all configured OIDC providers in the identity backend.",
+	},
+	"oidc-provider-discovery": {
+		"Query OIDC configurations",
+		"Query this path to retrieve the configured OIDC Issuer and Keys endpoints, response types, subject types, and signing algorithms used by the OIDC provider.",
+	},
+	"oidc-provider-public-keys": {
+		"Retrieve public keys",
+		"Query this path to retrieve the public portion of keys used by the OIDC provider. Clients can use this to validate the authenticity of an identity token.",
+	},
+	"oidc-provider-authorize": {
+		"Provides the OIDC Authorization Endpoint.",
+		"Authenticates the entity of the request's token for a client, and returns an authorization code that the client can exchange for tokens.",
+	},
+	"oidc-provider-token": {
+		"Provides the OIDC Token Endpoint.",
+		"Exchanges an authorization code for an ID token and an access token. Clients authenticate with their client ID and secret.",
+	},
+	"oidc-provider-userinfo": {
+		"Provides the OIDC UserInfo Endpoint.",
+		"Returns the claims of the entity that an access token was issued to.",
+	},
+}
@@ -0,0 +1,309 @@
+package vault
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/logical"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+func TestIdentityStore_OIDCProvider(t *testing.T) {
+	ctx := namespace.RootContext(nil)
+	c, _, _ := TestCoreUnsealed(t)
+	is := c.identityStore
+
+	handle := func(req *logical.Request) *logical.Response {
+		t.Helper()
+		req.Storage = is.view
+		resp, err := is.HandleRequest(ctx, req)
+		if err != nil {
+			t.Fatalf("%s: err: %v", req.Path, err)
+		}
+		return resp
+	}
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp := handle(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Data:      data,
+		})
+		if resp != nil && resp.IsError() {
+			t.Fatalf("%s: resp: %#v", path, resp)
+		}
+		return resp
+	}
+	requestErr := func(op logical.Operation, path string, data map[string]interface{}) {
+		t.Helper()
+		resp := handle(&logical.Request{
+			Operation: op,
+			Path:      path,
+			Data:      data,
+		})
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("%s: expected error, got %#v", path, resp)
+		}
+	}
+	rawBody := func(resp *logical.Response, status int) map[string]interface{} {
+		t.Helper()
+		if resp == nil || resp.Data[logical.HTTPStatusCode] != status {
+			t.Fatalf("expected status %d, got %#v", status, resp)
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &body); err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+
+	resp := request(logical.UpdateOperation, "entity", map[string]interface{}{
+		"name": "testentity",
+		"metadata": map[string]string{
+			"email": "test@example.com",
+		},
+	})
+	entityID := resp.Data["id"].(string)
+	resp = request(logical.UpdateOperation, "entity", map[string]interface{}{
+		"name": "otherentity",
+	})
+	otherEntityID := resp.Data["id"].(string)
+	resp = request(logical.UpdateOperation, "group", map[string]interface{}{
+		"name":              "engineering",
+		"member_entity_ids": []string{entityID},
+	})
+	groupID := resp.Data["id"].(string)
+
+	request(logical.CreateOperation, "oidc/key/test-key", map[string]interface{}{
+		"algorithm": "RS256",
+	})
+	requestErr(logical.CreateOperation, "oidc/assignment/test-assignment", map[string]interface{}{
+		"entity_ids": "missing-entity",
+	})
+	request(logical.CreateOperation, "oidc/assignment/test-assignment", map[string]interface{}{
+		"group_ids": groupID,
+	})
+	requestErr(logical.CreateOperation, "oidc/scope/openid", map[string]interface{}{
+		"template": `{"email": "{{identity.entity.metadata.email}}"}`,
+	})
+	requestErr(logical.CreateOperation, "oidc/scope/email", map[string]interface{}{
+		"template": `{"nonce": "{{identity.entity.metadata.email}}"}`,
+	})
+	request(logical.CreateOperation, "oidc/scope/email", map[string]interface{}{
+		"template": `{"email": "{{identity.entity.metadata.email}}"}`,
+	})
+	requestErr(logical.CreateOperation, "oidc/client/test-client", map[string]interface{}{
+		"key":           "test-key",
+		"redirect_uris": "https://app.example.com/callback",
+		"assignments":   "missing-assignment",
+	})
+	requestErr(logical.CreateOperation, "oidc/client/test-client", map[string]interface{}{
+		"key":           "test-key",
+		"redirect_uris": "https://app.example.com/callback",
+		"id_token_ttl":  "48h",
+	})
+	request(logical.CreateOperation, "oidc/client/test-client", map[string]interface{}{
+		"key":           "test-key",
+		"redirect_uris": "https://app.example.com/callback",
+		"assignments":   "test-assignment",
+		"id_token_ttl":  "1h",
+	})
+	resp = request(logical.ReadOperation, "oidc/client/test-client", nil)
+	clientID := resp.Data["client_id"].(string)
+	clientSecret := resp.Data["client_secret"].(string)
+	requestErr(logical.UpdateOperation, "oidc/client/test-client", map[string]interface{}{
+		"client_type": "public",
+	})
+	request(logical.CreateOperation, "oidc/provider/test-provider", map[string]interface{}{
+		"issuer":           "https://vault.example.com:8200",
+		"scopes_supported": "email",
+	})
+
+	// Referenced resources cannot be deleted
+	requestErr(logical.DeleteOperation, "oidc/key/test-key", nil)
+	requestErr(logical.DeleteOperation, "oidc/assignment/test-assignment", nil)
+	requestErr(logical.DeleteOperation, "oidc/scope/email", nil)
+
+	issuer := "https://vault.example.com:8200/v1/identity/oidc/provider/test-provider"
+	discovery := rawBody(request(logical.ReadOperation, "oidc/provider/test-provider/.well-known/openid-configuration", nil), http.StatusOK)
+	if discovery["issuer"] != issuer || discovery["token_endpoint"] != issuer+"/token" {
+		t.Fatalf("bad discovery document: %#v", discovery)
+	}
+	// Users are sent to the oidc-provider route of the UI, in ui/app/router.js
+	if discovery["authorization_endpoint"] != "https://vault.example.com:8200/ui/vault/identity/oidc/provider/test-provider/authorize" {
+		t.Fatalf("bad authorization endpoint: %v", discovery["authorization_endpoint"])
+	}
+	resp = request(logical.ReadOperation, "oidc/provider/test-provider/.well-known/keys", nil)
+	var keys jose.JSONWebKeySet
+	if err := json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &keys); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys.Keys) != 1 {
+		t.Fatalf("bad keys: %#v", keys)
+	}
+
+	authorize := func(entityID string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		params := map[string]interface{}{
+			"client_id":     clientID,
+			"redirect_uri":  "https://app.example.com/callback",
+			"response_type": "code",
+			"scope":         "openid email",
+			"state":         "abc",
+		}
+		for k, v := range data {
+			params[k] = v
+		}
+		return handle(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "oidc/provider/test-provider/authorize",
+			Data:      params,
+			EntityID:  entityID,
+		})
+	}
+
+	// Bad redirect URIs are not sent back to the client, other errors are
+	if resp := authorize(entityID, map[string]interface{}{"redirect_uri": "https://evil.example.com"}); resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got %#v", resp)
+	}
+	if body := rawBody(authorize(entityID, map[string]interface{}{"scope": "email"}), http.StatusBadRequest); body["error"] != "invalid_scope" || body["state"] != "abc" {
+		t.Fatalf("bad error: %#v", body)
+	}
+	if body := rawBody(authorize(otherEntityID, nil), http.StatusBadRequest); body["error"] != "access_denied" {
+		t.Fatalf("bad error: %#v", body)
+	}
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk0123456789"
+	sum := sha256.Sum256([]byte(verifier))
+	body := rawBody(authorize(entityID, map[string]interface{}{
+		"nonce":                 "n-0S6_WzA2Mj",
+		"code_challenge":        base64.RawURLEncoding.EncodeToString(sum[:]),
+		"code_challenge_method": "S256",
+	}), http.StatusOK)
+	code := body["code"].(string)
+	if code == "" || body["state"] != "abc" {
+		t.Fatalf("bad authorize response: %#v", body)
+	}
+
+	token := func(headers map[string][]string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		params := map[string]interface{}{
+			"grant_type":    "authorization_code",
+			"code":          code,
+			"redirect_uri":  "https://app.example.com/callback",
+			"code_verifier": verifier,
+		}
+		for k, v := range data {
+			params[k] = v
+		}
+		return handle(&logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "oidc/provider/test-provider/token",
+			Data:      params,
+			Headers:   headers,
+		})
+	}
+	basic := map[string][]string{
+		"Authorization": []string{"Basic " + base64.StdEncoding.EncodeToString([]byte(clientID+":"+clientSecret))},
+	}
+
+	if body := rawBody(token(nil, map[string]interface{}{"client_id": clientID, "client_secret": "wrong"}), http.StatusUnauthorized); body["error"] != "invalid_client" {
+		t.Fatalf("bad error: %#v", body)
+	}
+	body = rawBody(token(basic, nil), http.StatusOK)
+	if body["token_type"] != "Bearer" || body["expires_in"].(float64) != 86400 {
+		t.Fatalf("bad token response: %#v", body)
+	}
+	accessToken := body["access_token"].(string)
+
+	jws, err := jose.ParseSigned(body["id_token"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := jws.Verify(&keys.Keys[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"iss":   issuer,
+		"sub":   entityID,
+		"aud":   clientID,
+		"nonce": "n-0S6_WzA2Mj",
+		"email": "test@example.com",
+	}
+	for claim, value := range expected {
+		if claims[claim] != value {
+			t.Fatalf("bad claim %q: expected %v, got %v", claim, value, claims[claim])
+		}
+	}
+	if exp, iat := claims["exp"].(float64), claims["iat"].(float64); exp-iat != 3600 {
+		t.Fatalf("bad token lifetime: %v", exp-iat)
+	}
+
+	// Codes can only be used once
+	if body := rawBody(token(basic, nil), http.StatusBadRequest); body["error"] != "invalid_grant" {
+		t.Fatalf("bad error: %#v", body)
+	}
+
+	userinfo := func(accessToken string) *logical.Response {
+		t.Helper()
+		return handle(&logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "oidc/provider/test-provider/userinfo",
+			Headers: map[string][]string{
+				"Authorization": []string{"Bearer " + accessToken},
+			},
+		})
+	}
+	body = rawBody(userinfo(accessToken), http.StatusOK)
+	if body["sub"] != entityID || body["email"] != "test@example.com" {
+		t.Fatalf("bad userinfo response: %#v", body)
+	}
+	if body := rawBody(userinfo("bogus"), http.StatusUnauthorized); body["error"] != "invalid_token" {
+		t.Fatalf("bad error: %#v", body)
+	}
+
+	// Public clients must use PKCE
+	request(logical.CreateOperation, "oidc/client/public-client", map[string]interface{}{
+		"key":           "test-key",
+		"redirect_uris": "https://app.example.com/callback",
+		"assignments":   "test-assignment",
+		"id_token_ttl":  "1h",
+		"client_type":   "public",
+	})
+	resp = request(logical.ReadOperation, "oidc/client/public-client", nil)
+	if _, ok := resp.Data["client_secret"]; ok {
+		t.Fatalf("expected public client to have no secret: %#v", resp.Data)
+	}
+	publicClientID := resp.Data["client_id"].(string)
+	if body := rawBody(authorize(entityID, map[string]interface{}{"client_id": publicClientID}), http.StatusBadRequest); body["error"] != "invalid_request" {
+		t.Fatalf("bad error: %#v", body)
+	}
+
+	// Providers can be restricted to clients
+	request(logical.UpdateOperation, "oidc/provider/test-provider", map[string]interface{}{
+		"allowed_client_ids": publicClientID,
+	})
+	if resp := authorize(entityID, nil); resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got %#v", resp)
+	}
+
+	if err := is.oidcProviderPeriodicFunc(ctx, is.view); err != nil {
+		t.Fatal(err)
+	}
+
+	request(logical.DeleteOperation, "oidc/provider/test-provider", nil)
+	request(logical.DeleteOperation, "oidc/client/test-client", nil)
+	request(logical.DeleteOperation, "oidc/client/public-client", nil)
+	request(logical.DeleteOperation, "oidc/scope/email", nil)
+	request(logical.DeleteOperation, "oidc/assignment/test-assignment", nil)
+	request(logical.DeleteOperation, "oidc/key/test-key", nil)
+}
@@ -0,0 +1,256 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/logical"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// testPutRecordingStorage records the keys written to the storage
+type testPutRecordingStorage struct {
+	logical.Storage
+	puts []string
+}
+
+func (s *testPutRecordingStorage) Put(ctx context.Context, entry *logical.StorageEntry) error {
+	s.puts = append(s.puts, entry.Key)
+	return s.Storage.Put(ctx, entry)
+}
+
+func TestIdentityStore_OIDCTokens(t *testing.T) {
+	ctx := namespace.RootContext(nil)
+	c, _, _ := TestCoreUnsealed(t)
+	is := c.identityStore
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := is.HandleRequest(ctx, &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   is.view,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err: %v, resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+	requestErr := func(op logical.Operation, path string, data map[string]interface{}) {
+		t.Helper()
+		resp, err := is.HandleRequest(ctx, &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   is.view,
+			Data:      data,
+		})
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("%s: expected error, got %#v", path, resp)
+		}
+	}
+
+	resp := request(logical.UpdateOperation, "entity", map[string]interface{}{
+		"name": "testentity",
+		"metadata": map[string]string{
+			"color": "green",
+		},
+	})
+	entityID := resp.Data["id"].(string)
+	resp = request(logical.UpdateOperation, "group", map[string]interface{}{
+		"name":              "engineering",
+		"member_entity_ids": []string{entityID},
+	})
+	groupID := resp.Data["id"].(string)
+
+	request(logical.UpdateOperation, "oidc/config", map[string]interface{}{
+		"issuer": "https://vault.example.com:8200",
+	})
+	request(logical.CreateOperation, "oidc/key/test-key", map[string]interface{}{
+		"algorithm":        "ES256",
+		"verification_ttl": "2h",
+	})
+	requestErr(logical.CreateOperation, "oidc/key/bad-key", map[string]interface{}{
+		"algorithm": "HS256",
+	})
+
+	// Roles need an existing key, a TTL within the key's verification TTL and
+	// a template that leaves the reserved claims alone
+	requestErr(logical.CreateOperation, "oidc/role/test-role", map[string]interface{}{
+		"key": "missing-key",
+	})
+	requestErr(logical.CreateOperation, "oidc/role/test-role", map[string]interface{}{
+		"key": "test-key",
+		"ttl": "3h",
+	})
+	requestErr(logical.CreateOperation, "oidc/role/test-role", map[string]interface{}{
+		"key":      "test-key",
+		"template": `{"sub": "somebody"}`,
+	})
+	requestErr(logical.CreateOperation, "oidc/role/test-role", map[string]interface{}{
+		"key":      "test-key",
+		"template": `{"name": "{{identity.entity.name"}`,
+	})
+	request(logical.CreateOperation, "oidc/role/test-role", map[string]interface{}{
+		"key": "test-key",
+		"ttl": "1h",
+		"template": `{
+			"name": "{{identity.entity.name}}",
+			"color": "{{identity.entity.metadata.color}}",
+			"missing": "{{identity.entity.metadata.missing}}",
+			"groups": ["{{identity.groups.names.engineering.id}}"]
+		}`,
+	})
+	resp = request(logical.ReadOperation, "oidc/role/test-role", nil)
+	clientID := resp.Data["client_id"].(string)
+	if clientID == "" || resp.Data["ttl"].(int64) != 3600 {
+		t.Fatalf("bad role: %#v", resp.Data)
+	}
+
+	// The key cannot go away while a role uses it
+	requestErr(logical.DeleteOperation, "oidc/key/test-key", nil)
+
+	// Tokens are only issued to entities
+	requestErr(logical.ReadOperation, "oidc/token/test-role", nil)
+
+	resp, err := is.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "oidc/token/test-role",
+		Storage:   is.view,
+		EntityID:  entityID,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	if resp.Data["client_id"] != clientID {
+		t.Fatalf("bad client ID: %#v", resp.Data)
+	}
+	token := resp.Data["token"].(string)
+
+	readKeys := func() *jose.JSONWebKeySet {
+		t.Helper()
+		resp := request(logical.ReadOperation, "oidc/.well-known/keys", nil)
+		var keys jose.JSONWebKeySet
+		if err := json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &keys); err != nil {
+			t.Fatal(err)
+		}
+		return &keys
+	}
+	keys := readKeys()
+	if len(keys.Keys) != 1 || !keys.Keys[0].IsPublic() {
+		t.Fatalf("bad keys: %#v", keys)
+	}
+
+	jws, err := jose.ParseSigned(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jws.Signatures[0].Header.KeyID != keys.Keys[0].KeyID {
+		t.Fatalf("bad key ID: %q", jws.Signatures[0].Header.KeyID)
+	}
+	payload, err := jws.Verify(&keys.Keys[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := "https://vault.example.com:8200/v1/identity/oidc"
+	expected := map[string]interface{}{
+		"iss":       issuer,
+		"sub":       entityID,
+		"aud":       clientID,
+		"namespace": namespace.RootNamespaceID,
+		"name":      "testentity",
+		"color":     "green",
+	}
+	for claim, value := range expected {
+		if claims[claim] != value {
+			t.Fatalf("bad claim %q: expected %v, got %v", claim, value, claims[claim])
+		}
+	}
+	if groups, ok := claims["groups"].([]interface{}); !ok || len(groups) != 1 || groups[0] != groupID {
+		t.Fatalf("bad groups claim: %#v", claims["groups"])
+	}
+	if _, ok := claims["missing"]; ok {
+		t.Fatalf("expected unresolved claim to be left out: %#v", claims)
+	}
+	if exp, iat := claims["exp"].(float64), claims["iat"].(float64); exp-iat != 3600 {
+		t.Fatalf("bad token lifetime: %v", exp-iat)
+	}
+
+	resp = request(logical.ReadOperation, "oidc/.well-known/openid-configuration", nil)
+	var discovery map[string]interface{}
+	if err := json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &discovery); err != nil {
+		t.Fatal(err)
+	}
+	if discovery["issuer"] != issuer || discovery["jwks_uri"] != issuer+"/.well-known/keys" {
+		t.Fatalf("bad discovery document: %#v", discovery)
+	}
+
+	// Rotated keys stay published until their verification TTL passes
+	request(logical.UpdateOperation, "oidc/key/test-key/rotate", map[string]interface{}{
+		"verification_ttl": 0,
+	})
+	if keys := readKeys(); len(keys.Keys) != 2 {
+		t.Fatalf("expected previous key to be published, got %d keys", len(keys.Keys))
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := is.oidcPeriodicFunc(ctx, is.view); err != nil {
+		t.Fatal(err)
+	}
+	keys = readKeys()
+	if len(keys.Keys) != 1 || keys.Keys[0].KeyID == jws.Signatures[0].Header.KeyID {
+		t.Fatalf("expected previous key to expire: %#v", keys)
+	}
+
+	// Keys are rotated once their rotation period passes
+	key, err := is.getNamedKey(ctx, is.view, "test-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.NextRotation = time.Now().Add(-time.Second)
+	if err := is.putNamedKey(ctx, is.view, "test-key", key); err != nil {
+		t.Fatal(err)
+	}
+	if err := is.oidcPeriodicFunc(ctx, is.view); err != nil {
+		t.Fatal(err)
+	}
+	if keys := readKeys(); len(keys.Keys) != 2 {
+		t.Fatalf("expected key to be rotated, got %d keys", len(keys.Keys))
+	}
+
+	// Nothing is written when no key was rotated or expired
+	recorder := &testPutRecordingStorage{Storage: is.view}
+	if err := is.oidcPeriodicFunc(ctx, recorder); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.puts) != 0 {
+		t.Fatalf("expected no writes, got %v", recorder.puts)
+	}
+
+	// Keys can be restricted to roles
+	request(logical.UpdateOperation, "oidc/key/test-key", map[string]interface{}{
+		"allowed_client_ids": "someone-else",
+	})
+	resp, err = is.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "oidc/token/test-role",
+		Storage:   is.view,
+		EntityID:  entityID,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got err: %v, resp: %#v", err, resp)
+	}
+
+	request(logical.DeleteOperation, "oidc/role/test-role", nil)
+	request(logical.DeleteOperation, "oidc/key/test-key", nil)
+	if keys := readKeys(); len(keys.Keys) != 0 {
+		t.Fatalf("expected no keys after deletion, got %d", len(keys.Keys))
+	}
+}
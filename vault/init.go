@@ -134,6 +134,13 @@ func (c *Core) Initialize(ctx context.Context, initParams *InitParams) (*InitRes
 		return nil, ErrAlreadyInit
 	}
 
+	// If raft storage is in use it must be bootstrapped before anything can
+	// be written
+	if err := c.raftInitialize(ctx); err != nil {
+		c.logger.Error("failed to bootstrap raft storage", "error", err)
+		return nil, errwrap.Wrapf("failed to bootstrap raft storage: {{err}}", err)
+	}
+
 	err = c.seal.Init(ctx)
 	if err != nil {
 		c.logger.Error("failed to initialize seal", "error", err)
@@ -0,0 +1,226 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	radix "github.com/armon/go-radix"
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/logical"
+)
+
+const (
+	// leaseCountQuotaSubPath is the sub-path of the system view in which the
+	// lease count quotas are stored, indexed by name
+	leaseCountQuotaSubPath = "quotas/lease-count/"
+)
+
+// LeaseCountQuota limits the number of leases, including token leases, that
+// can exist under a path. The path is relative to the root namespace and may
+// be a namespace, a mount or any path prefix within a mount; an empty path
+// applies the quota to all the leases.
+type LeaseCountQuota struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	MaxLeases int    `json:"max_leases"`
+}
+
+// leaseCountTracker counts the leases of the expiration manager under the
+// path of each lease count quota. Leases are identified by the path of their
+// namespace followed by their lease ID.
+type leaseCountTracker struct {
+	l sync.Mutex
+
+	// leases holds every lease known to the expiration manager, so that the
+	// usage of a quota can be computed when it is created
+	leases *radix.Tree
+
+	quotas map[string]*LeaseCountQuota
+	usage  map[string]int
+}
+
+func newLeaseCountTracker() *leaseCountTracker {
+	return &leaseCountTracker{
+		leases: radix.New(),
+		quotas: make(map[string]*LeaseCountQuota),
+		usage:  make(map[string]int),
+	}
+}
+
+// add records a new lease, failing if it would exceed a quota
+func (t *leaseCountTracker) add(key string) error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	if _, ok := t.leases.Get(key); ok {
+		return nil
+	}
+
+	var matching []*LeaseCountQuota
+	for _, quota := range t.quotas {
+		if !strings.HasPrefix(key, quota.Path) {
+			continue
+		}
+		if t.usage[quota.Name] >= quota.MaxLeases {
+			return quota.exceededError()
+		}
+		matching = append(matching, quota)
+	}
+
+	t.leases.Insert(key, struct{}{})
+	for _, quota := range matching {
+		t.usage[quota.Name]++
+	}
+	return nil
+}
+
+// checkHeadroom fails if a quota that would count a lease created under the
+// path is full
+func (t *leaseCountTracker) checkHeadroom(path string) error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	// Lease IDs are the path of the request followed by an ID
+	path += "/"
+	for _, quota := range t.quotas {
+		if strings.HasPrefix(path, quota.Path) && t.usage[quota.Name] >= quota.MaxLeases {
+			return quota.exceededError()
+		}
+	}
+	return nil
+}
+
+// track records an existing lease, such as one being restored, regardless
+// of the quotas
+func (t *leaseCountTracker) track(key string) {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	if _, updated := t.leases.Insert(key, struct{}{}); updated {
+		return
+	}
+	for _, quota := range t.quotas {
+		if strings.HasPrefix(key, quota.Path) {
+			t.usage[quota.Name]++
+		}
+	}
+}
+
+// remove forgets a lease that has been deleted
+func (t *leaseCountTracker) remove(key string) {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	if _, deleted := t.leases.Delete(key); !deleted {
+		return
+	}
+	for _, quota := range t.quotas {
+		if strings.HasPrefix(key, quota.Path) {
+			t.usage[quota.Name]--
+		}
+	}
+}
+
+// setQuota adds or replaces a quota and computes its usage
+func (t *leaseCountTracker) setQuota(quota *LeaseCountQuota) {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	usage := 0
+	t.leases.WalkPrefix(quota.Path, func(string, interface{}) bool {
+		usage++
+		return false
+	})
+
+	t.quotas[quota.Name] = quota
+	t.usage[quota.Name] = usage
+}
+
+func (t *leaseCountTracker) deleteQuota(name string) {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	delete(t.quotas, name)
+	delete(t.usage, name)
+}
+
+// quotaUsage returns the number of leases counted against a quota
+func (t *leaseCountTracker) quotaUsage(name string) int {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	return t.usage[name]
+}
+
+func (q *LeaseCountQuota) exceededError() error {
+	return errwrap.Wrapf(fmt.Sprintf("{{err}}: quota %q allows at most %d leases under %q", q.Name, q.MaxLeases, q.Path), logical.ErrLeaseCountQuotaExceeded)
+}
+
+// leaseCountKey returns the key identifying a lease in the tracker
+func leaseCountKey(le *leaseEntry) string {
+	return le.namespace.Path + le.LeaseID
+}
+
+// checkLeaseCountQuotas rejects a request that could create a lease under the
+// path of a full lease count quota before it is routed, so that the backend
+// does not create a credential whose lease cannot be registered. Registering
+// the lease remains the final check, since the quota may fill up while the
+// request is handled.
+func (c *Core) checkLeaseCountQuotas(ctx context.Context, ns *namespace.Namespace, req *logical.Request) error {
+	if c.expiration == nil {
+		return nil
+	}
+	switch req.Operation {
+	case logical.ReadOperation, logical.CreateOperation, logical.UpdateOperation:
+	default:
+		return nil
+	}
+	// The system backend remains available to revoke leases and fix quotas
+	if strings.HasPrefix(req.Path, "sys/") {
+		return nil
+	}
+
+	return c.expiration.leaseCounts.checkHeadroom(ns.Path + req.Path)
+}
+
+// loadLeaseCountQuotas loads the lease count quotas into the tracker of the
+// expiration manager
+func (m *ExpirationManager) loadLeaseCountQuotas(ctx context.Context) error {
+	view := m.core.systemBarrierView.SubView(leaseCountQuotaSubPath)
+	names, err := view.List(ctx, "")
+	if err != nil {
+		return errwrap.Wrapf("failed to list lease count quotas: {{err}}", err)
+	}
+
+	for _, name := range names {
+		quota, err := getLeaseCountQuota(ctx, view, name)
+		if err != nil {
+			return err
+		}
+		if quota != nil {
+			m.leaseCounts.setQuota(quota)
+		}
+	}
+
+	return nil
+}
+
+func getLeaseCountQuota(ctx context.Context, view logical.Storage, name string) (*LeaseCountQuota, error) {
+	entry, err := view.Get(ctx, name)
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("failed to read lease count quota %q: {{err}}", name), err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var quota LeaseCountQuota
+	if err := jsonutil.DecodeJSON(entry.Value, &quota); err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("failed to decode lease count quota %q: {{err}}", name), err)
+	}
+	return &quota, nil
+}
@@ -0,0 +1,277 @@
+package vault
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestLeaseCountTracker(t *testing.T) {
+	tracker := newLeaseCountTracker()
+	tracker.track("database/creds/a/1")
+	tracker.track("database/creds/b/2")
+	tracker.track("auth/token/create/3")
+
+	// Usage includes the existing leases
+	tracker.setQuota(&LeaseCountQuota{Name: "db", Path: "database/", MaxLeases: 3})
+	if usage := tracker.quotaUsage("db"); usage != 2 {
+		t.Fatalf("expected usage of 2, got %d", usage)
+	}
+
+	if err := tracker.add("database/creds/a/4"); err != nil {
+		t.Fatal(err)
+	}
+	err := tracker.add("database/creds/a/5")
+	if !errwrap.Contains(err, logical.ErrLeaseCountQuotaExceeded.Error()) {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+
+	// Leases outside of the path are not limited
+	if err := tracker.add("auth/token/create/6"); err != nil {
+		t.Fatal(err)
+	}
+
+	tracker.remove("database/creds/a/1")
+	tracker.remove("database/creds/a/1")
+	if usage := tracker.quotaUsage("db"); usage != 2 {
+		t.Fatalf("expected usage of 2, got %d", usage)
+	}
+	if err := tracker.add("database/creds/a/5"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSystemBackend_LeaseCountQuota(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/quotas/lease-count/tokens")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{
+		"path":       "auth/token/create",
+		"max_leases": 2,
+	}
+	resp, err := c.HandleRequest(ctx, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	createToken := func() (*logical.Response, error) {
+		req := logical.TestRequest(t, logical.UpdateOperation, "auth/token/create")
+		req.ClientToken = root
+		req.Data = map[string]interface{}{
+			"ttl": "1h",
+		}
+		return c.HandleRequest(ctx, req)
+	}
+
+	var tokens []string
+	for i := 0; i < 2; i++ {
+		resp, err := createToken()
+		if err != nil || resp.IsError() {
+			t.Fatalf("err: %v, resp: %#v", err, resp)
+		}
+		tokens = append(tokens, resp.Auth.ClientToken)
+	}
+
+	resp, err = createToken()
+	if err != logical.ErrLeaseCountQuotaExceeded || resp == nil || !resp.IsError() {
+		t.Fatalf("expected quota error, got err: %v, resp: %#v", err, resp)
+	}
+
+	readUsage := func() int {
+		req := logical.TestRequest(t, logical.ReadOperation, "sys/quotas/lease-count/tokens")
+		req.ClientToken = root
+		resp, err := c.HandleRequest(ctx, req)
+		if err != nil || resp == nil {
+			t.Fatalf("err: %v, resp: %#v", err, resp)
+		}
+		return resp.Data["leases"].(int)
+	}
+	if usage := readUsage(); usage != 2 {
+		t.Fatalf("expected usage of 2, got %d", usage)
+	}
+
+	// Revoking a token frees up its lease
+	req = logical.TestRequest(t, logical.UpdateOperation, "auth/token/revoke")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{
+		"token": tokens[0],
+	}
+	if resp, err := c.HandleRequest(ctx, req); err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	if usage := readUsage(); usage != 1 {
+		t.Fatalf("expected usage of 1, got %d", usage)
+	}
+	if resp, err := createToken(); err != nil || resp.IsError() {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	req = logical.TestRequest(t, logical.ListOperation, "sys/quotas/lease-count")
+	req.ClientToken = root
+	resp, err = c.HandleRequest(ctx, req)
+	if err != nil || resp == nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != "tokens" {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	req = logical.TestRequest(t, logical.DeleteOperation, "sys/quotas/lease-count/tokens")
+	req.ClientToken = root
+	if resp, err := c.HandleRequest(ctx, req); err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	for i := 0; i < 2; i++ {
+		if resp, err := createToken(); err != nil || resp.IsError() {
+			t.Fatalf("err: %v, resp: %#v", err, resp)
+		}
+	}
+}
+
+func TestCore_LeaseCountQuota_BeforeRouting(t *testing.T) {
+	noop := &NoopBackend{
+		RequestHandler: func(ctx context.Context, req *logical.Request) (*logical.Response, error) {
+			if req.Operation != logical.ReadOperation {
+				return nil, nil
+			}
+			return &logical.Response{
+				Secret: &logical.Secret{
+					LeaseOptions: logical.LeaseOptions{
+						TTL: time.Hour,
+					},
+				},
+				Data: map[string]interface{}{
+					"foo": "bar",
+				},
+			}, nil
+		},
+	}
+
+	c, _, root := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+	c.logicalBackends["noop"] = func(context.Context, *logical.BackendConfig) (logical.Backend, error) {
+		return noop, nil
+	}
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/mounts/foo")
+	req.Data["type"] = "noop"
+	req.ClientToken = root
+	if _, err := c.HandleRequest(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	req = logical.TestRequest(t, logical.UpdateOperation, "sys/quotas/lease-count/foo")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{
+		"path":       "foo/",
+		"max_leases": 1,
+	}
+	if resp, err := c.HandleRequest(ctx, req); err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	read := func() (*logical.Response, error) {
+		req := logical.TestRequest(t, logical.ReadOperation, "foo/creds")
+		req.ClientToken = root
+		return c.HandleRequest(ctx, req)
+	}
+	if resp, err := read(); err != nil || resp.IsError() || resp.Secret.LeaseID == "" {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	// The full quota rejects the request before the backend handles it
+	resp, err := read()
+	if err != logical.ErrLeaseCountQuotaExceeded || resp == nil || !resp.IsError() {
+		t.Fatalf("expected quota error, got err: %v, resp: %#v", err, resp)
+	}
+	if len(noop.Requests) != 1 {
+		t.Fatalf("expected the backend to handle 1 request, got %d", len(noop.Requests))
+	}
+
+	// Requests that cannot create leases are still handled
+	req = logical.TestRequest(t, logical.ListOperation, "foo/")
+	req.ClientToken = root
+	if _, err := c.HandleRequest(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if len(noop.Requests) != 2 {
+		t.Fatalf("expected the backend to handle 2 requests, got %d", len(noop.Requests))
+	}
+}
+
+func TestCore_LeaseCountQuota_SiblingMount(t *testing.T) {
+	noop := &NoopBackend{
+		RequestHandler: func(ctx context.Context, req *logical.Request) (*logical.Response, error) {
+			return &logical.Response{
+				Secret: &logical.Secret{
+					LeaseOptions: logical.LeaseOptions{
+						TTL: time.Hour,
+					},
+				},
+				Data: map[string]interface{}{
+					"foo": "bar",
+				},
+			}, nil
+		},
+	}
+
+	c, _, root := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+	c.logicalBackends["noop"] = func(context.Context, *logical.BackendConfig) (logical.Backend, error) {
+		return noop, nil
+	}
+
+	for _, path := range []string{"secret-db", "secret-dbstore"} {
+		req := logical.TestRequest(t, logical.UpdateOperation, "sys/mounts/"+path)
+		req.Data["type"] = "noop"
+		req.ClientToken = root
+		if _, err := c.HandleRequest(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/quotas/lease-count/db")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{
+		"path":       "secret-db",
+		"max_leases": 1,
+	}
+	if resp, err := c.HandleRequest(ctx, req); err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "sys/quotas/lease-count/db")
+	req.ClientToken = root
+	resp, err := c.HandleRequest(ctx, req)
+	if err != nil || resp == nil || resp.Data["path"] != "secret-db/" {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	read := func(path string) (*logical.Response, error) {
+		req := logical.TestRequest(t, logical.ReadOperation, path)
+		req.ClientToken = root
+		return c.HandleRequest(ctx, req)
+	}
+	if resp, err := read("secret-db/creds"); err != nil || resp.IsError() {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	if _, err := read("secret-db/creds"); err != logical.ErrLeaseCountQuotaExceeded {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+
+	// The quota does not apply to a mount sharing its prefix
+	for i := 0; i < 2; i++ {
+		if resp, err := read("secret-dbstore/creds"); err != nil || resp.IsError() {
+			t.Fatalf("err: %v, resp: %#v", err, resp)
+		}
+	}
+	if usage := c.expiration.leaseCounts.quotaUsage("db"); usage != 1 {
+		t.Fatalf("expected usage of 1, got %d", usage)
+	}
+}
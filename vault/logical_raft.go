@@ -0,0 +1,298 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// raftStoragePaths returns paths for use when raft is the storage mechanism.
+func (b *SystemBackend) raftStoragePaths() []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "storage/raft/bootstrap/challenge",
+
+			Fields: map[string]*framework.FieldSchema{
+				"server_id": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: "The raft node ID of the server that wants to join.",
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleRaftBootstrapChallengeWrite,
+					Summary:  "Creates a challenge for a node that wants to join the raft cluster.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["raft-bootstrap-challenge"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["raft-bootstrap-challenge"][1]),
+		},
+
+		{
+			Pattern: "storage/raft/bootstrap/answer",
+
+			Fields: map[string]*framework.FieldSchema{
+				"server_id": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: "The raft node ID of the server that wants to join.",
+				},
+				"answer": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: "The base64-encoded answer to the challenge.",
+				},
+				"cluster_addr": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: "The raft address of the server that wants to join.",
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleRaftBootstrapAnswerWrite,
+					Summary:  "Adds a node that answered its challenge to the raft cluster.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["raft-bootstrap-answer"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["raft-bootstrap-answer"][1]),
+		},
+
+		{
+			Pattern: "storage/raft/configuration",
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleRaftConfigurationGet,
+					Summary:  "Returns the members of the raft cluster.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["raft-configuration"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["raft-configuration"][1]),
+		},
+
+		{
+			Pattern: "storage/raft/remove-peer",
+
+			Fields: map[string]*framework.FieldSchema{
+				"server_id": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: "The raft node ID of the server to remove.",
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleRaftRemovePeerUpdate,
+					Summary:  "Removes a node from the raft cluster.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["raft-remove-peer"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["raft-remove-peer"][1]),
+		},
+
+		{
+			Pattern: "storage/raft/snapshot",
+
+			Fields: map[string]*framework.FieldSchema{
+				"snapshot": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: "The base64-encoded snapshot to restore.",
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleRaftSnapshotRead,
+					Summary:  "Returns a snapshot of the current state of vault.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleRaftSnapshotWrite,
+					Summary:  "Installs the provided snapshot, returning the cluster to the state defined in it.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["raft-snapshot"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["raft-snapshot"][1]),
+		},
+	}
+}
+
+func (b *SystemBackend) handleRaftBootstrapChallengeWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if _, ok := b.Core.raftStorage(); !ok {
+		return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+	}
+
+	serverID := d.Get("server_id").(string)
+	if serverID == "" {
+		return logical.ErrorResponse("no server id provided"), logical.ErrInvalidRequest
+	}
+
+	sealConfig, err := b.Core.seal.BarrierConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := b.Core.raftCreateChallenge(ctx, serverID)
+	if err == errTooManyRaftChallenges {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"challenge":   base64.StdEncoding.EncodeToString(challenge),
+			"seal_config": sealConfig,
+		},
+	}, nil
+}
+
+func (b *SystemBackend) handleRaftBootstrapAnswerWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	raftStorage, ok := b.Core.raftStorage()
+	if !ok {
+		return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+	}
+
+	serverID := d.Get("server_id").(string)
+	if serverID == "" {
+		return logical.ErrorResponse("no server_id provided"), logical.ErrInvalidRequest
+	}
+	answerRaw := d.Get("answer").(string)
+	if answerRaw == "" {
+		return logical.ErrorResponse("no answer provided"), logical.ErrInvalidRequest
+	}
+	clusterAddr := d.Get("cluster_addr").(string)
+	if clusterAddr == "" {
+		return logical.ErrorResponse("no cluster_addr provided"), logical.ErrInvalidRequest
+	}
+
+	answer, err := base64.StdEncoding.DecodeString(answerRaw)
+	if err != nil {
+		return logical.ErrorResponse("could not base64 decode answer"), logical.ErrInvalidRequest
+	}
+
+	if err := b.Core.raftVerifyAnswer(serverID, answer); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrPermissionDenied
+	}
+
+	if err := raftStorage.AddPeer(ctx, serverID, clusterAddr); err != nil {
+		return nil, err
+	}
+
+	b.logger.Info("follower node answered the raft bootstrap challenge", "follower_server_id", serverID)
+
+	return nil, nil
+}
+
+func (b *SystemBackend) handleRaftConfigurationGet(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	raftStorage, ok := b.Core.raftStorage()
+	if !ok {
+		return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+	}
+
+	peers, err := raftStorage.Peers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	servers := make([]map[string]interface{}, 0, len(peers))
+	for _, peer := range peers {
+		servers = append(servers, map[string]interface{}{
+			"node_id": peer.ID,
+			"address": peer.Address,
+			"leader":  peer.Leader,
+			"voter":   peer.Voter,
+		})
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"servers": servers,
+		},
+	}, nil
+}
+
+func (b *SystemBackend) handleRaftRemovePeerUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	raftStorage, ok := b.Core.raftStorage()
+	if !ok {
+		return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+	}
+
+	serverID := d.Get("server_id").(string)
+	if serverID == "" {
+		return logical.ErrorResponse("no server id provided"), logical.ErrInvalidRequest
+	}
+	if serverID == raftStorage.NodeID() {
+		return logical.ErrorResponse("cannot remove the active node"), logical.ErrInvalidRequest
+	}
+
+	if err := raftStorage.RemovePeer(ctx, serverID); err != nil {
+		return nil, err
+	}
+
+	b.logger.Info("removed raft peer", "server_id", serverID)
+
+	return nil, nil
+}
+
+func (b *SystemBackend) handleRaftSnapshotRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	raftStorage, ok := b.Core.raftStorage()
+	if !ok {
+		return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+	}
+
+	var buf bytes.Buffer
+	if err := raftStorage.Snapshot(&buf); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/octet-stream",
+			logical.HTTPRawBody:     buf.Bytes(),
+			logical.HTTPStatusCode:  http.StatusOK,
+		},
+	}, nil
+}
+
+func (b *SystemBackend) handleRaftSnapshotWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	raftStorage, ok := b.Core.raftStorage()
+	if !ok {
+		return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+	}
+
+	snapshotRaw := d.Get("snapshot").(string)
+	if snapshotRaw == "" {
+		return logical.ErrorResponse("no snapshot provided"), logical.ErrInvalidRequest
+	}
+	snapshot, err := base64.StdEncoding.DecodeString(snapshotRaw)
+	if err != nil {
+		return logical.ErrorResponse("could not base64 decode snapshot"), logical.ErrInvalidRequest
+	}
+
+	if err := raftStorage.RestoreSnapshot(ctx, snapshot); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	// The restored state may contain different mounts, policies and tokens,
+	// so drop anything cached from before the restore
+	b.Core.physicalCache.Purge(ctx)
+	if err := b.Core.barrier.ReloadKeyring(ctx); err != nil {
+		return nil, errors.New("snapshot restored but the keyring could not be reloaded; the node must be restarted")
+	}
+
+	b.logger.Warn("raft snapshot restored; restart or step down the active node to reload all state")
+
+	return nil, nil
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 	"github.com/mitchellh/mapstructure"
+	cache "github.com/patrickmn/go-cache"
 )
 
 var (
@@ -67,11 +68,14 @@ func NewSystemBackend(core *Core, logger log.Logger) *SystemBackend {
 	db, _ := memdb.NewMemDB(systemBackendMemDBSchema())
 
 	b := &SystemBackend{
-		Core:      core,
-		db:        db,
-		logger:    logger,
-		mfaLogger: core.baseLogger.Named("mfa"),
-		mfaLock:   &sync.RWMutex{},
+		Core:         core,
+		db:           db,
+		logger:       logger,
+		mfaLogger:    core.baseLogger.Named("mfa"),
+		mfaLock:      &sync.RWMutex{},
+		mfaUsedCodes: cache.New(0, 30*time.Second),
+
+		controlGroupLock: &sync.Mutex{},
 	}
 
 	core.AddLogger(b.mfaLogger)
@@ -103,6 +107,18 @@ func NewSystemBackend(core *Core, logger log.Logger) *SystemBackend {
 				"leases/revoke-prefix/*",
 				"leases/revoke-force/*",
 				"leases/lookup/*",
+				"leases/export",
+				"leases/count",
+				"leases/bulk-renew",
+				"leases/bulk-revoke",
+				"leases/irrevocable",
+				"leases/irrevocable/*",
+				"quotas/*",
+				"storage/raft/configuration",
+				"storage/raft/remove-peer",
+				"storage/raft/snapshot",
+				"seal/migrate",
+				"seal/migrate/update",
 			},
 
 			Unauthenticated: []string{
@@ -120,6 +136,8 @@ func NewSystemBackend(core *Core, logger log.Logger) *SystemBackend {
 				"replication/dr/secondary/operation-token/delete",
 				"replication/dr/secondary/license",
 				"replication/dr/secondary/reindex",
+				"storage/raft/bootstrap/challenge",
+				"storage/raft/bootstrap/answer",
 			},
 
 			LocalStorage: []string{
@@ -132,6 +150,7 @@ func NewSystemBackend(core *Core, logger log.Logger) *SystemBackend {
 	b.Backend.Paths = append(b.Backend.Paths, b.configPaths()...)
 	b.Backend.Paths = append(b.Backend.Paths, b.rekeyPaths()...)
 	b.Backend.Paths = append(b.Backend.Paths, b.sealPaths()...)
+	b.Backend.Paths = append(b.Backend.Paths, b.sealMigrationPaths()...)
 	b.Backend.Paths = append(b.Backend.Paths, b.pluginsCatalogListPaths()...)
 	b.Backend.Paths = append(b.Backend.Paths, b.pluginsCatalogCRUDPath())
 	b.Backend.Paths = append(b.Backend.Paths, b.pluginsReloadPath())
@@ -145,6 +164,14 @@ func NewSystemBackend(core *Core, logger log.Logger) *SystemBackend {
 	b.Backend.Paths = append(b.Backend.Paths, b.capabilitiesPaths()...)
 	b.Backend.Paths = append(b.Backend.Paths, b.internalPaths()...)
 	b.Backend.Paths = append(b.Backend.Paths, b.remountPath())
+	b.Backend.Paths = append(b.Backend.Paths, b.metricsPath())
+	b.Backend.Paths = append(b.Backend.Paths, b.mfaPaths()...)
+	b.Backend.Paths = append(b.Backend.Paths, b.controlGroupPaths()...)
+	b.Backend.Paths = append(b.Backend.Paths, b.quotaPaths()...)
+
+	if _, ok := core.raftStorage(); ok {
+		b.Backend.Paths = append(b.Backend.Paths, b.raftStoragePaths()...)
+	}
 
 	if core.rawEnabled {
 		b.Backend.Paths = append(b.Backend.Paths, &framework.Path{
@@ -197,6 +224,14 @@ type SystemBackend struct {
 	mfaLock   *sync.RWMutex
 	mfaLogger log.Logger
 	logger    log.Logger
+
+	// mfaUsedCodes holds the TOTP passcodes that were recently used to
+	// satisfy an MFA method, to prevent them from being replayed
+	mfaUsedCodes *cache.Cache
+
+	// controlGroupLock serializes the updates to the requests held by
+	// control group tokens
+	controlGroupLock *sync.Mutex
 }
 
 // handleCORSRead returns the current CORS configuration
@@ -954,6 +989,17 @@ func (b *SystemBackend) handleUnmount(ctx context.Context, req *logical.Request,
 	return nil, nil
 }
 
+// handleMetricsRead returns the metrics collected by the server's in-memory
+// sinks in the requested format
+func (b *SystemBackend) handleMetricsRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if b.Core.metricsHelper == nil {
+		return logical.ErrorResponse("metrics are not enabled on this node"), logical.ErrInvalidRequest
+	}
+
+	format := data.Get("format").(string)
+	return b.Core.metricsHelper.ResponseForFormat(format), nil
+}
+
 // handleRemount is used to remount a path
 func (b *SystemBackend) handleRemount(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	repState := b.Core.ReplicationState()
@@ -3312,6 +3358,29 @@ This path responds to the following HTTP methods.
         Seals the Vault.
 		`,
 	},
+	"seal-migrate": {
+		"Migrates the seal of a running Vault.",
+		`
+This path migrates the master key and the recovery key to an auto seal,
+either from the Shamir seal or from another auto seal, without taking the
+cluster down. Only a single seal migration can take place at a time.
+
+    PUT /seal/migrate
+        Starts a seal migration to the given seal type and configuration.
+
+    PUT /seal/migrate/update
+        Provides an unseal key share, or a recovery key share when using an
+        auto seal. Once the threshold is met, the seal is migrated in the
+        background. Any failure rolls the seal back.
+
+    GET /seal/migrate
+        Returns the key share progress and the state of the last migration,
+        including how many seal wrapped storage entries were rewrapped.
+
+    DELETE /seal/migrate
+        Cancels the seal migration waiting for key shares.
+		`,
+	},
 	"unseal": {
 		"Unseals the Vault.",
 		`
@@ -3421,6 +3490,144 @@ This path responds to the following HTTP methods.
 		`,
 	},
 
+	"metrics": {
+		"Export the metrics aggregated for telemetry purposes.",
+		`
+This path responds to the following HTTP methods.
+
+    GET /sys/metrics
+        Returns the metrics collected by this node, in the Prometheus text
+        format by default.
+		`,
+	},
+
+	"metrics-format": {
+		`The format of the metrics: "prometheus" (the default) or "json".`,
+		"",
+	},
+
+	"quotas-lease-count-list": {
+		"Lists the lease count quotas.",
+		`
+This path responds to the following HTTP methods.
+
+    LIST /
+        List the names of the lease count quotas.
+		`,
+	},
+
+	"quotas-lease-count": {
+		"Configures a lease count quota.",
+		`
+A lease count quota limits the number of leases, including token leases,
+that can exist under a path: a namespace, a mount or a path prefix within a
+mount. Once the limit is reached, requests that would create a new lease
+under the path fail with a 429 status code. Reading a quota returns the
+number of leases currently counted against it.
+		`,
+	},
+
+	"quotas-rate-limit-list": {
+		"Lists the rate limit quotas.",
+		`
+This path responds to the following HTTP methods.
+
+    LIST /
+        List the names of the rate limit quotas.
+		`,
+	},
+
+	"quotas-rate-limit": {
+		"Configures a rate limit quota.",
+		`
+A rate limit quota limits the rate of the requests under a path: a
+namespace, a mount or a path prefix within a mount. Each client, identified
+by its address or the accessor of its token, gets a token bucket refilled at
+the configured rate. Requests that find the bucket empty fail with a 429
+status code and a Retry-After header. Only the quota with the longest
+matching path applies to a request.
+		`,
+	},
+
+	"mfa-method-list": {
+		"Lists all the configured MFA methods.",
+		`
+This path responds to the following HTTP methods.
+
+    LIST /
+        List the names of the configured MFA methods, along with their IDs
+        and types.
+		`,
+	},
+
+	"mfa-method-totp": {
+		"Configures a TOTP MFA method.",
+		`
+TOTP MFA methods can be referenced by name from the "mfa_methods" of a
+policy path. Requests to such a path must supply a valid TOTP passcode in
+the X-Vault-MFA header, in the form "method_name:passcode". The passcode is
+validated against the secret generated on the entity of the calling token.
+		`,
+	},
+
+	"mfa-method-totp-generate": {
+		"Generates a TOTP secret on the entity of the calling token.",
+		`
+Generates a TOTP secret using the configuration of the method and stores it
+on the entity of the calling token, if it doesn't have one already. The
+returned URL or QR code can be loaded into a TOTP application.
+		`,
+	},
+
+	"mfa-method-totp-admin-generate": {
+		"Generates a TOTP secret on the given entity.",
+		`
+Generates a TOTP secret using the configuration of the method and stores it
+on the given entity, if it doesn't have one already.
+		`,
+	},
+
+	"mfa-method-totp-admin-destroy": {
+		"Deletes the TOTP secret of the given entity.",
+		`
+Deletes the TOTP secret of the method from the given entity. A new secret
+can then be generated using the "generate" or "admin-generate" endpoints.
+		`,
+	},
+
+	"mfa-method-duo": {
+		"Configures a Duo MFA method.",
+		`
+Duo MFA methods can be referenced by name from the "mfa_methods" of a
+policy path. Requests to such a path send a push notification to the Duo
+user mapped to the entity of the calling token, unless a passcode is
+supplied in the X-Vault-MFA header in the form "method_name:passcode=code".
+The Duo username is derived from the name of the entity's alias on the
+configured mount accessor, using the username format if one is set.
+		`,
+	},
+
+	"control-group-authorize": {
+		"Authorizes a control group request.",
+		`
+Records the approval of the request held by the control group token with the
+given accessor. The entity of the calling token must be a member of the
+identity groups of at least one of the factors of the control group; its
+approval counts towards every factor it is a member of. Requesters cannot
+approve their own requests.
+		`,
+	},
+
+	"control-group-request": {
+		"Checks the status of a control group request.",
+		`
+Returns the path and the requesting entity of the request held by the control
+group token with the given accessor, along with the authorizations it has
+collected and whether it has been approved. Once approved, the control group
+token can be unwrapped to perform the original request.
+		`,
+	},
+
 	"auth_tune": {
 		"Tune the configuration parameters for an auth path.",
 		`Read and write the 'default-lease-ttl' and 'max-lease-ttl' values of
@@ -3706,6 +3913,75 @@ necessary, and is only required if upgrade notes or support personnel suggest
 it.`,
 	},
 
+	"leases-export": {
+		"Returns the details of the leases matching a filter.",
+		`
+Returns the ID, issue and expiration times, owning token accessor, mount and
+role of the leases matching all the given filters: a lease ID prefix, a mount
+path, a role or a token accessor.
+
+The role of a secret lease is the last segment of its path within the mount
+when the path has two segments, such as "creds/:role". The role of a token
+lease is the "role" metadata set by the auth method.
+		`,
+	},
+
+	"leases-count": {
+		"Counts the leases matching a filter by mount.",
+		`
+Returns the number of leases matching all the given filters for each mount,
+along with the total number of matching leases.
+		`,
+	},
+
+	"leases-bulk-renew": {
+		"Renews the secret leases matching a filter.",
+		`
+Renews the secret leases matching all the given filters, of which at least
+one must be specified. Tokens cannot be renewed through this endpoint. The
+IDs of the renewed leases are returned along with the errors of the leases
+that could not be renewed. With dry_run, only the IDs of the matching leases
+are returned.
+		`,
+	},
+
+	"leases-bulk-revoke": {
+		"Revokes the leases matching a filter.",
+		`
+Revokes the leases matching all the given filters, of which at least one must
+be specified. The IDs of the revoked leases are returned along with the errors
+of the leases that could not be revoked. With dry_run, only the IDs of the
+matching leases are returned.
+		`,
+	},
+
+	"leases-irrevocable": {
+		"Lists the leases that could not be revoked.",
+		`
+Returns the leases that failed to be revoked after the maximum number of
+attempts, set by max_lease_revoke_attempts in the server configuration, along
+with the last error returned by the backend. These leases are no longer
+retried: their revocation can be retried, or forced once whatever they were
+for has been cleaned up manually.
+		`,
+	},
+
+	"leases-irrevocable-retry": {
+		"Retries the revocation of an irrevocable lease.",
+		`
+Attempts once more to revoke an irrevocable lease. If the revocation fails
+again, the lease stays irrevocable with the new error.
+		`,
+	},
+
+	"leases-irrevocable-force-revoke": {
+		"Removes an irrevocable lease, ignoring the errors of the backend.",
+		`
+Removes an irrevocable lease without revoking it from the backend. Whatever
+the lease was for, such as a database user, must be cleaned up manually.
+		`,
+	},
+
 	"wrap": {
 		"Response-wraps an arbitrary JSON object.",
 		`Round trips the given input data into a response-wrapped token.`,
@@ -3875,4 +4151,29 @@ This path responds to the following HTTP methods.
 		"Information about a token's resultant ACL. Internal API; its location, inputs, and outputs may change.",
 		"",
 	},
+	"raft-bootstrap-challenge": {
+		"Creates a challenge for a new peer to join the raft cluster.",
+		`A node that wants to join the raft cluster provides its server ID and
+receives a challenge encrypted with the cluster's master key or auto seal. Only
+a node that can be unsealed the same way can answer it.`,
+	},
+	"raft-bootstrap-answer": {
+		"Accepts an answer from a peer to be joined to the raft cluster.",
+		`If the answer matches the challenge issued to the given server ID, the
+server is added to the raft cluster as a voter at the given cluster address.`,
+	},
+	"raft-configuration": {
+		"Returns the configuration of the raft cluster.",
+		"",
+	},
+	"raft-remove-peer": {
+		"Removes a peer from the raft cluster.",
+		"",
+	},
+	"raft-snapshot": {
+		"Returns a snapshot of the current state of vault, or restores one.",
+		`Reading returns a snapshot of the raft storage as a binary body. Writing a
+base64-encoded snapshot restores the storage to the state it contains; the
+active node should be restarted afterwards so all state is reloaded.`,
+	},
 }
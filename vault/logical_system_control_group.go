@@ -0,0 +1,201 @@
+package vault
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func (b *SystemBackend) controlGroupPaths() []*framework.Path {
+	accessorField := &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "The accessor of the control group wrapping token.",
+	}
+
+	return []*framework.Path{
+		{
+			Pattern: "control-group/authorize$",
+
+			Fields: map[string]*framework.FieldSchema{
+				"accessor": accessorField,
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleControlGroupAuthorize,
+					Summary:  "Authorizes a control group request.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["control-group-authorize"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["control-group-authorize"][1]),
+		},
+
+		{
+			Pattern: "control-group/request$",
+
+			Fields: map[string]*framework.FieldSchema{
+				"accessor": accessorField,
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleControlGroupRequest,
+					Summary:  "Checks the status of a control group request.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["control-group-request"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["control-group-request"][1]),
+		},
+	}
+}
+
+// handleControlGroupAuthorize records the approval of a control group request
+// by the entity of the calling token
+func (b *SystemBackend) handleControlGroupAuthorize(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	accessor := d.Get("accessor").(string)
+	if accessor == "" {
+		return logical.ErrorResponse("missing accessor"), nil
+	}
+	if req.EntityID == "" {
+		return logical.ErrorResponse("authorizing a control group request requires the token to be associated with an entity"), nil
+	}
+
+	entity, err := b.Core.identityStore.MemDBEntityByID(req.EntityID, false)
+	if err != nil {
+		return nil, err
+	}
+	if entity == nil {
+		return logical.ErrorResponse("entity of the token not found"), nil
+	}
+
+	directGroups, inheritedGroups, err := b.Core.identityStore.groupsByEntityID(entity.ID)
+	if err != nil {
+		return nil, err
+	}
+	groupIDs := make(map[string]bool)
+	groupNames := make(map[string]bool)
+	for _, group := range append(directGroups, inheritedGroups...) {
+		groupIDs[group.ID] = true
+		groupNames[group.Name] = true
+	}
+
+	te, err := b.Core.lookupControlGroupToken(ctx, accessor)
+	if err != nil {
+		return handleError(err)
+	}
+
+	b.controlGroupLock.Lock()
+	defer b.controlGroupLock.Unlock()
+
+	cgReq, err := b.Core.readControlGroupRequest(ctx, te)
+	if err != nil {
+		return nil, err
+	}
+
+	if cgReq.EntityID == entity.ID {
+		return logical.ErrorResponse("requesters cannot authorize their own control group request"), logical.ErrPermissionDenied
+	}
+
+	// An authorizer that is a member of the groups of several factors counts
+	// towards each of them
+	var factors []string
+	for _, factor := range cgReq.Factors {
+		if factor.Identity == nil {
+			continue
+		}
+		var member bool
+		for _, groupID := range factor.Identity.GroupIDs {
+			member = member || groupIDs[groupID]
+		}
+		for _, groupName := range factor.Identity.GroupNames {
+			member = member || groupNames[groupName]
+		}
+		if member {
+			factors = append(factors, factor.Name)
+		}
+	}
+	if len(factors) == 0 {
+		return logical.ErrorResponse("entity is not an authorizer of the control group request"), logical.ErrPermissionDenied
+	}
+
+	var authorized bool
+	for _, authz := range cgReq.Authorizations {
+		if authz.EntityID == entity.ID {
+			authorized = true
+			break
+		}
+	}
+	if !authorized {
+		cgReq.Authorizations = append(cgReq.Authorizations, &controlGroupAuthorization{
+			EntityID:   entity.ID,
+			EntityName: entity.Name,
+			Factors:    factors,
+			Time:       time.Now(),
+		})
+		if err := b.Core.writeControlGroupRequest(ctx, te, cgReq); err != nil {
+			return nil, err
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"approved": cgReq.Approved(),
+		},
+	}, nil
+}
+
+// handleControlGroupRequest returns the status of a control group request
+func (b *SystemBackend) handleControlGroupRequest(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	accessor := d.Get("accessor").(string)
+	if accessor == "" {
+		return logical.ErrorResponse("missing accessor"), nil
+	}
+
+	te, err := b.Core.lookupControlGroupToken(ctx, accessor)
+	if err != nil {
+		return handleError(err)
+	}
+
+	b.controlGroupLock.Lock()
+	cgReq, err := b.Core.readControlGroupRequest(ctx, te)
+	b.controlGroupLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	requestEntity := map[string]interface{}{
+		"id":   cgReq.EntityID,
+		"name": "",
+	}
+	if cgReq.EntityID != "" {
+		entity, err := b.Core.identityStore.MemDBEntityByID(cgReq.EntityID, false)
+		if err != nil {
+			return nil, err
+		}
+		if entity != nil {
+			requestEntity["name"] = entity.Name
+		}
+	}
+
+	authorizations := make([]map[string]interface{}, 0, len(cgReq.Authorizations))
+	for _, authz := range cgReq.Authorizations {
+		authorizations = append(authorizations, map[string]interface{}{
+			"entity_id":   authz.EntityID,
+			"entity_name": authz.EntityName,
+		})
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"approved":       cgReq.Approved(),
+			"request_path":   cgReq.Path,
+			"request_entity": requestEntity,
+			"authorizations": authorizations,
+		},
+	}, nil
+}
@@ -2,7 +2,6 @@ package vault
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -14,20 +13,30 @@ import (
 )
 
 var (
-	invalidateMFAConfig = func(context.Context, *SystemBackend, string) {}
+	invalidateMFAConfig = func(ctx context.Context, b *SystemBackend, name string) {
+		b.invalidateMFAConfigByName(ctx, name)
+	}
 
 	sysInvalidate = func(b *SystemBackend) func(context.Context, string) {
-		return nil
+		return func(ctx context.Context, key string) {
+			if strings.HasPrefix(key, mfaMethodSubPath) {
+				invalidateMFAConfig(ctx, b, strings.TrimPrefix(key, mfaMethodSubPath))
+			}
+		}
 	}
 
-	getSystemSchemas = func() []func() *memdb.TableSchema { return nil }
+	getSystemSchemas = func() []func() *memdb.TableSchema {
+		return []func() *memdb.TableSchema{
+			mfaConfigsTableSchema,
+		}
+	}
 
 	getEGPListResponseKeyInfo = func(*SystemBackend, *namespace.Namespace) map[string]interface{} { return nil }
 	addSentinelPolicyData     = func(map[string]interface{}, *Policy) {}
 	inputSentinelPolicyData   = func(*framework.FieldData, *Policy) *logical.Response { return nil }
 
-	controlGroupUnwrap = func(context.Context, *SystemBackend, string, bool) (string, error) {
-		return "", errors.New("control groups unavailable")
+	controlGroupUnwrap = func(ctx context.Context, b *SystemBackend, token string, _ bool) (string, error) {
+		return b.controlGroupUnwrap(ctx, token)
 	}
 
 	pathInternalUINamespacesRead = func(b *SystemBackend) framework.OperationFunc {
@@ -0,0 +1,262 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// leaseFilterFields are the fields of the paths operating on the leases
+// matching a filter
+func leaseFilterFields() map[string]*framework.FieldSchema {
+	return map[string]*framework.FieldSchema{
+		"prefix": &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: "Only match the leases whose ID starts with this prefix.",
+		},
+		"mount": &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: "Only match the leases issued by the mount at this path.",
+		},
+		"role": &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: "Only match the leases issued for this role.",
+		},
+		"token_accessor": &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: "Only match the leases owned by the token with this accessor.",
+		},
+	}
+}
+
+// leaseBulkFields are the fields of the paths operating on many leases at
+// once
+func leaseBulkFields() map[string]*framework.FieldSchema {
+	fields := leaseFilterFields()
+	fields["dry_run"] = &framework.FieldSchema{
+		Type:        framework.TypeBool,
+		Description: "Only return the leases that would be affected.",
+	}
+	return fields
+}
+
+func leaseFilterFromData(data *framework.FieldData) *leaseFilter {
+	return &leaseFilter{
+		Prefix:        data.Get("prefix").(string),
+		Mount:         data.Get("mount").(string),
+		Role:          data.Get("role").(string),
+		TokenAccessor: data.Get("token_accessor").(string),
+	}
+}
+
+// handleLeaseExport returns the details of the leases matching a filter
+func (b *SystemBackend) handleLeaseExport(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	leases, err := b.Core.expiration.exportLeases(ctx, leaseFilterFromData(data))
+	if err != nil {
+		b.Backend.Logger().Error("error exporting leases", "error", err)
+		return handleErrorNoReadOnlyForward(err)
+	}
+
+	exported := make([]map[string]interface{}, 0, len(leases))
+	for _, lease := range leases {
+		entry := map[string]interface{}{
+			"id":             lease.LeaseID,
+			"issue_time":     lease.IssueTime,
+			"expire_time":    nil,
+			"last_renewal":   nil,
+			"ttl":            int64(0),
+			"renewable":      lease.Renewable,
+			"token_accessor": lease.TokenAccessor,
+			"mount":          lease.Mount,
+			"role":           lease.Role,
+		}
+		if !lease.LastRenewal.IsZero() {
+			entry["last_renewal"] = lease.LastRenewal
+		}
+		if !lease.ExpireTime.IsZero() {
+			entry["expire_time"] = lease.ExpireTime
+			entry["ttl"] = int64(lease.ExpireTime.Sub(time.Now().Round(time.Second)).Seconds())
+		}
+		exported = append(exported, entry)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"leases": exported,
+		},
+	}, nil
+}
+
+// handleLeaseCount returns the number of leases matching a filter, grouped by
+// mount
+func (b *SystemBackend) handleLeaseCount(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	leases, err := b.Core.expiration.exportLeases(ctx, leaseFilterFromData(data))
+	if err != nil {
+		b.Backend.Logger().Error("error counting leases", "error", err)
+		return handleErrorNoReadOnlyForward(err)
+	}
+
+	counts := make(map[string]int)
+	for _, lease := range leases {
+		counts[lease.Mount]++
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"counts": counts,
+			"total":  len(leases),
+		},
+	}, nil
+}
+
+// handleLeaseBulkRenew renews the secret leases matching a filter
+func (b *SystemBackend) handleLeaseBulkRenew(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	increment := time.Duration(data.Get("increment").(int)) * time.Second
+
+	return b.handleLeaseBulkCommon(ctx, data, "renew", func(ctx context.Context, lease *leaseInfo) error {
+		if lease.Auth {
+			return fmt.Errorf("tokens cannot be renewed through this endpoint")
+		}
+		resp, err := b.Core.expiration.Renew(ctx, lease.LeaseID, increment)
+		if err == nil && resp != nil && resp.IsError() {
+			err = resp.Error()
+		}
+		return err
+	})
+}
+
+// handleLeaseBulkRevoke revokes the leases matching a filter
+func (b *SystemBackend) handleLeaseBulkRevoke(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	revokeCtx := namespace.ContextWithNamespace(b.Core.activeContext, ns)
+
+	sync := data.Get("sync").(bool)
+	return b.handleLeaseBulkCommon(ctx, data, "revoke", func(_ context.Context, lease *leaseInfo) error {
+		if sync {
+			return b.Core.expiration.Revoke(revokeCtx, lease.LeaseID)
+		}
+		return b.Core.expiration.LazyRevoke(revokeCtx, lease.LeaseID)
+	})
+}
+
+// handleLeaseBulkCommon applies an operation to the leases matching a
+// filter, returning the leases it succeeded for along with the errors of the
+// others
+func (b *SystemBackend) handleLeaseBulkCommon(ctx context.Context, data *framework.FieldData, op string, fn func(context.Context, *leaseInfo) error) (*logical.Response, error) {
+	filter := leaseFilterFromData(data)
+	if filter.empty() {
+		return logical.ErrorResponse("at least one of prefix, mount, role or token_accessor must be specified"), logical.ErrInvalidRequest
+	}
+
+	leases, err := b.Core.expiration.exportLeases(ctx, filter)
+	if err != nil {
+		b.Backend.Logger().Error("error listing leases", "error", err)
+		return handleErrorNoReadOnlyForward(err)
+	}
+
+	leaseIDs := make([]string, 0, len(leases))
+	if data.Get("dry_run").(bool) {
+		for _, lease := range leases {
+			leaseIDs = append(leaseIDs, lease.LeaseID)
+		}
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"lease_ids": leaseIDs,
+				"dry_run":   true,
+			},
+		}, nil
+	}
+
+	errs := make(map[string]string)
+	for _, lease := range leases {
+		if err := fn(ctx, lease); err != nil {
+			b.Backend.Logger().Error(fmt.Sprintf("bulk lease %s failed", op), "lease_id", lease.LeaseID, "error", err)
+			errs[lease.LeaseID] = err.Error()
+			continue
+		}
+		leaseIDs = append(leaseIDs, lease.LeaseID)
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"lease_ids": leaseIDs,
+			"dry_run":   false,
+		},
+	}
+	if len(errs) > 0 {
+		resp.Data["errors"] = errs
+		resp.AddWarning(fmt.Sprintf("failed to %s %d of %d leases", op, len(errs), len(leases)))
+	}
+	return resp, nil
+}
+
+// handleLeaseIrrevocableList returns the leases marked irrevocable along with
+// the last error returned when revoking them
+func (b *SystemBackend) handleLeaseIrrevocableList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	leases := make([]map[string]interface{}, 0)
+	for _, le := range b.Core.expiration.irrevocableLeases() {
+		if le.namespace.ID != ns.ID {
+			continue
+		}
+		leases = append(leases, map[string]interface{}{
+			"id":          le.LeaseID,
+			"mount":       b.Core.expiration.leaseMount(ctx, le),
+			"expire_time": le.ExpireTime,
+			"error":       le.RevokeErr,
+		})
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"leases": leases,
+		},
+	}, nil
+}
+
+// handleLeaseIrrevocableRetry attempts once more to revoke an irrevocable
+// lease
+func (b *SystemBackend) handleLeaseIrrevocableRetry(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return b.handleLeaseIrrevocableCommon(ctx, data, b.Core.expiration.RetryIrrevocable)
+}
+
+// handleLeaseIrrevocableForceRevoke removes an irrevocable lease, ignoring the
+// errors of the backend
+func (b *SystemBackend) handleLeaseIrrevocableForceRevoke(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return b.handleLeaseIrrevocableCommon(ctx, data, b.Core.expiration.ForceRevokeIrrevocable)
+}
+
+func (b *SystemBackend) handleLeaseIrrevocableCommon(ctx context.Context, data *framework.FieldData, fn func(context.Context, string) error) (*logical.Response, error) {
+	leaseID := data.Get("lease_id").(string)
+	if leaseID == "" {
+		return logical.ErrorResponse("lease_id must be specified"),
+			logical.ErrInvalidRequest
+	}
+
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	revokeCtx := namespace.ContextWithNamespace(b.Core.activeContext, ns)
+
+	switch err := fn(revokeCtx, leaseID); err {
+	case nil:
+		return nil, nil
+	case errLeaseNotIrrevocable:
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	default:
+		b.Backend.Logger().Error("irrevocable lease revocation failed", "lease_id", leaseID, "error", err)
+		return handleErrorNoReadOnlyForward(err)
+	}
+}
@@ -0,0 +1,802 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	memdb "github.com/hashicorp/go-memdb"
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/identity/mfa"
+	"github.com/hashicorp/vault/helper/totputil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"github.com/mitchellh/copystructure"
+	totplib "github.com/pquerna/otp/totp"
+)
+
+const (
+	// mfaMethodSubPath is the sub-path of the system view in which the MFA
+	// method configurations are stored, indexed by method name
+	mfaMethodSubPath = "mfa/method/"
+
+	// memDBMFAConfigsTable is the table of the system backend's MemDB holding
+	// the MFA method configurations
+	memDBMFAConfigsTable = "mfa_configs"
+
+	mfaMethodTypeTOTP = "totp"
+	mfaMethodTypeDuo  = "duo"
+)
+
+// MFAConfig is the configuration of an MFA method that can be referenced by
+// name from the mfa_methods of a policy path
+type MFAConfig struct {
+	ID             string      `json:"id"`
+	Name           string      `json:"name"`
+	Type           string      `json:"type"`
+	MountAccessor  string      `json:"mount_accessor,omitempty"`
+	UsernameFormat string      `json:"username_format,omitempty"`
+	TOTPConfig     *TOTPConfig `json:"totp_config,omitempty"`
+	DuoConfig      *DuoConfig  `json:"duo_config,omitempty"`
+}
+
+// TOTPConfig holds the parameters used to generate the TOTP secrets of the
+// entities
+type TOTPConfig struct {
+	Issuer    string `json:"issuer"`
+	Period    int    `json:"period"`
+	Algorithm string `json:"algorithm"`
+	Digits    int    `json:"digits"`
+	Skew      int    `json:"skew"`
+	KeySize   int    `json:"key_size"`
+	QRSize    int    `json:"qr_size"`
+}
+
+// DuoConfig holds the credentials used to talk to the Duo Auth API
+type DuoConfig struct {
+	IntegrationKey string `json:"integration_key"`
+	SecretKey      string `json:"secret_key"`
+	APIHostname    string `json:"api_hostname"`
+	PushInfo       string `json:"push_info"`
+}
+
+func (m *MFAConfig) Clone() (*MFAConfig, error) {
+	cloned, err := copystructure.Copy(m)
+	if err != nil {
+		return nil, err
+	}
+	return cloned.(*MFAConfig), nil
+}
+
+func mfaConfigsTableSchema() *memdb.TableSchema {
+	return &memdb.TableSchema{
+		Name: memDBMFAConfigsTable,
+		Indexes: map[string]*memdb.IndexSchema{
+			"id": &memdb.IndexSchema{
+				Name:   "id",
+				Unique: true,
+				Indexer: &memdb.StringFieldIndex{
+					Field: "ID",
+				},
+			},
+			"name": &memdb.IndexSchema{
+				Name:   "name",
+				Unique: true,
+				Indexer: &memdb.StringFieldIndex{
+					Field: "Name",
+				},
+			},
+		},
+	}
+}
+
+func mfaMethodFields(methodType string) map[string]*framework.FieldSchema {
+	fields := map[string]*framework.FieldSchema{
+		"name": &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: "Name of the MFA method.",
+		},
+	}
+
+	switch methodType {
+	case mfaMethodTypeTOTP:
+		fields["issuer"] = &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: "The name of the key's issuing organization.",
+		}
+		fields["period"] = &framework.FieldSchema{
+			Type:        framework.TypeDurationSecond,
+			Default:     30,
+			Description: "The length of time used to generate a counter for the TOTP token calculation.",
+		}
+		fields["key_size"] = &framework.FieldSchema{
+			Type:        framework.TypeInt,
+			Default:     20,
+			Description: "Determines the size in bytes of the generated key.",
+		}
+		fields["qr_size"] = &framework.FieldSchema{
+			Type:        framework.TypeInt,
+			Default:     200,
+			Description: "The pixel size of the generated square QR code.",
+		}
+		fields["algorithm"] = &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Default:     "SHA1",
+			Description: `The hashing algorithm used to generate the TOTP code. Options include "SHA1", "SHA256" and "SHA512".`,
+		}
+		fields["digits"] = &framework.FieldSchema{
+			Type:        framework.TypeInt,
+			Default:     6,
+			Description: "The number of digits in the generated TOTP code. This value can either be 6 or 8.",
+		}
+		fields["skew"] = &framework.FieldSchema{
+			Type:        framework.TypeInt,
+			Default:     1,
+			Description: "The number of delay periods that are allowed when validating a TOTP code. This value can either be 0 or 1.",
+		}
+	case mfaMethodTypeDuo:
+		fields["mount_accessor"] = &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: "The accessor of the auth mount whose aliases are used to derive the Duo username.",
+		}
+		fields["username_format"] = &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: `A format string for mapping identity names to Duo usernames, e.g. "{{alias.name}}@example.com". If blank, the name of the alias is used as-is.`,
+		}
+		fields["secret_key"] = &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: "Secret key for Duo.",
+		}
+		fields["integration_key"] = &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: "Integration key for Duo.",
+		}
+		fields["api_hostname"] = &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: "API hostname for Duo.",
+		}
+		fields["push_info"] = &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: "Push information for Duo.",
+		}
+	}
+
+	return fields
+}
+
+// mfaPaths returns the paths used to manage the MFA methods and the TOTP
+// secrets of the entities
+func (b *SystemBackend) mfaPaths() []*framework.Path {
+	entityIDField := &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: "Entity ID on which the MFA secret is managed.",
+	}
+
+	return []*framework.Path{
+		{
+			Pattern: "mfa/method/?$",
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.handleMFAMethodList,
+					Summary:  "Lists all the configured MFA methods.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["mfa-method-list"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["mfa-method-list"][1]),
+		},
+
+		{
+			Pattern: "mfa/method/totp/" + framework.GenericNameRegex("name") + "$",
+
+			Fields: mfaMethodFields(mfaMethodTypeTOTP),
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleMFAMethodRead(mfaMethodTypeTOTP),
+					Summary:  "Reads the configuration of a TOTP MFA method.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleMFAMethodUpdate(mfaMethodTypeTOTP),
+					Summary:  "Configures a TOTP MFA method.",
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.handleMFAMethodDelete(mfaMethodTypeTOTP),
+					Summary:  "Deletes a TOTP MFA method.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["mfa-method-totp"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["mfa-method-totp"][1]),
+		},
+
+		{
+			Pattern: "mfa/method/totp/" + framework.GenericNameRegex("name") + "/generate$",
+
+			Fields: map[string]*framework.FieldSchema{
+				"name": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: "Name of the MFA method.",
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleTOTPGenerate,
+					Summary:  "Generates a TOTP secret on the entity of the calling token.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["mfa-method-totp-generate"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["mfa-method-totp-generate"][1]),
+		},
+
+		{
+			Pattern: "mfa/method/totp/" + framework.GenericNameRegex("name") + "/admin-generate$",
+
+			Fields: map[string]*framework.FieldSchema{
+				"name": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: "Name of the MFA method.",
+				},
+				"entity_id": entityIDField,
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleTOTPAdminGenerate,
+					Summary:  "Generates a TOTP secret on the given entity.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["mfa-method-totp-admin-generate"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["mfa-method-totp-admin-generate"][1]),
+		},
+
+		{
+			Pattern: "mfa/method/totp/" + framework.GenericNameRegex("name") + "/admin-destroy$",
+
+			Fields: map[string]*framework.FieldSchema{
+				"name": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: "Name of the MFA method.",
+				},
+				"entity_id": entityIDField,
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleTOTPAdminDestroy,
+					Summary:  "Deletes the TOTP secret of the given entity.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["mfa-method-totp-admin-destroy"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["mfa-method-totp-admin-destroy"][1]),
+		},
+
+		{
+			Pattern: "mfa/method/duo/" + framework.GenericNameRegex("name") + "$",
+
+			Fields: mfaMethodFields(mfaMethodTypeDuo),
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleMFAMethodRead(mfaMethodTypeDuo),
+					Summary:  "Reads the configuration of a Duo MFA method.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleMFAMethodUpdate(mfaMethodTypeDuo),
+					Summary:  "Configures a Duo MFA method.",
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.handleMFAMethodDelete(mfaMethodTypeDuo),
+					Summary:  "Deletes a Duo MFA method.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["mfa-method-duo"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["mfa-method-duo"][1]),
+		},
+	}
+}
+
+// loadMFAConfigs reads all the MFA method configurations from storage into
+// the MemDB of the system backend
+func (b *SystemBackend) loadMFAConfigs(ctx context.Context) error {
+	b.mfaLock.Lock()
+	defer b.mfaLock.Unlock()
+
+	view := b.Core.systemBarrierView.SubView(mfaMethodSubPath)
+	names, err := view.List(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to list MFA methods: %v", err)
+	}
+
+	txn := b.db.Txn(true)
+	defer txn.Abort()
+
+	if _, err := txn.DeleteAll(memDBMFAConfigsTable, "id"); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		config, err := b.mfaConfigFromStorage(ctx, view, name)
+		if err != nil {
+			return err
+		}
+		if config == nil {
+			continue
+		}
+		if err := txn.Insert(memDBMFAConfigsTable, config); err != nil {
+			return fmt.Errorf("failed to load MFA method %q: %v", name, err)
+		}
+	}
+
+	txn.Commit()
+
+	if len(names) > 0 {
+		b.mfaLogger.Info("loaded MFA methods", "count", len(names))
+	}
+
+	return nil
+}
+
+func (b *SystemBackend) mfaConfigFromStorage(ctx context.Context, view logical.Storage, name string) (*MFAConfig, error) {
+	entry, err := view.Get(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MFA method %q: %v", name, err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var config MFAConfig
+	if err := entry.DecodeJSON(&config); err != nil {
+		return nil, fmt.Errorf("failed to decode MFA method %q: %v", name, err)
+	}
+
+	return &config, nil
+}
+
+// invalidateMFAConfigByName refreshes the MemDB copy of the configuration of
+// the given MFA method from storage
+func (b *SystemBackend) invalidateMFAConfigByName(ctx context.Context, name string) {
+	b.mfaLock.Lock()
+	defer b.mfaLock.Unlock()
+
+	config, err := b.mfaConfigFromStorage(ctx, b.Core.systemBarrierView.SubView(mfaMethodSubPath), name)
+	if err != nil {
+		b.mfaLogger.Error("failed to invalidate MFA method", "name", name, "error", err)
+		return
+	}
+
+	txn := b.db.Txn(true)
+	defer txn.Abort()
+
+	existing, err := txn.First(memDBMFAConfigsTable, "name", name)
+	if err != nil {
+		b.mfaLogger.Error("failed to invalidate MFA method", "name", name, "error", err)
+		return
+	}
+	if existing != nil {
+		if err := txn.Delete(memDBMFAConfigsTable, existing); err != nil {
+			b.mfaLogger.Error("failed to invalidate MFA method", "name", name, "error", err)
+			return
+		}
+	}
+	if config != nil {
+		if err := txn.Insert(memDBMFAConfigsTable, config); err != nil {
+			b.mfaLogger.Error("failed to invalidate MFA method", "name", name, "error", err)
+			return
+		}
+	}
+
+	txn.Commit()
+}
+
+// mfaConfigByName returns a copy of the configuration of the named MFA method,
+// or nil if there is none
+func (b *SystemBackend) mfaConfigByName(name string) (*MFAConfig, error) {
+	txn := b.db.Txn(false)
+
+	raw, err := txn.First(memDBMFAConfigsTable, "name", name)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, nil
+	}
+
+	return raw.(*MFAConfig).Clone()
+}
+
+func (b *SystemBackend) handleMFAMethodList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	txn := b.db.Txn(false)
+
+	iter, err := txn.Get(memDBMFAConfigsTable, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	keyInfo := make(map[string]interface{})
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		config := raw.(*MFAConfig)
+		keys = append(keys, config.Name)
+		keyInfo[config.Name] = map[string]interface{}{
+			"id":   config.ID,
+			"type": config.Type,
+		}
+	}
+
+	return logical.ListResponseWithInfo(keys, keyInfo), nil
+}
+
+func (b *SystemBackend) handleMFAMethodRead(methodType string) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		config, err := b.mfaConfigByName(d.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		if config == nil || config.Type != methodType {
+			return nil, nil
+		}
+
+		respData := map[string]interface{}{
+			"id":   config.ID,
+			"name": config.Name,
+			"type": config.Type,
+		}
+
+		switch config.Type {
+		case mfaMethodTypeTOTP:
+			respData["issuer"] = config.TOTPConfig.Issuer
+			respData["period"] = config.TOTPConfig.Period
+			respData["algorithm"] = config.TOTPConfig.Algorithm
+			respData["digits"] = config.TOTPConfig.Digits
+			respData["skew"] = config.TOTPConfig.Skew
+			respData["key_size"] = config.TOTPConfig.KeySize
+			respData["qr_size"] = config.TOTPConfig.QRSize
+		case mfaMethodTypeDuo:
+			respData["mount_accessor"] = config.MountAccessor
+			respData["username_format"] = config.UsernameFormat
+			respData["integration_key"] = config.DuoConfig.IntegrationKey
+			respData["api_hostname"] = config.DuoConfig.APIHostname
+			respData["push_info"] = config.DuoConfig.PushInfo
+		}
+
+		return &logical.Response{
+			Data: respData,
+		}, nil
+	}
+}
+
+func (b *SystemBackend) handleMFAMethodUpdate(methodType string) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		name := d.Get("name").(string)
+		if name == "" {
+			return logical.ErrorResponse("missing method name"), nil
+		}
+
+		b.mfaLock.Lock()
+		defer b.mfaLock.Unlock()
+
+		config, err := b.mfaConfigByName(name)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case config == nil:
+			methodID, err := uuid.GenerateUUID()
+			if err != nil {
+				return nil, err
+			}
+			config = &MFAConfig{
+				ID:   methodID,
+				Name: name,
+				Type: methodType,
+			}
+		case config.Type != methodType:
+			return logical.ErrorResponse(fmt.Sprintf("MFA method %q already exists with type %q", name, config.Type)), nil
+		}
+
+		var resp *logical.Response
+		switch methodType {
+		case mfaMethodTypeTOTP:
+			resp = parseTOTPConfig(config, d)
+		case mfaMethodTypeDuo:
+			resp = b.parseDuoConfig(config, d)
+		}
+		if resp != nil {
+			return resp, nil
+		}
+
+		entry, err := logical.StorageEntryJSON(mfaMethodSubPath+name, config)
+		if err != nil {
+			return nil, err
+		}
+		if err := req.Storage.Put(ctx, entry); err != nil {
+			return nil, err
+		}
+
+		txn := b.db.Txn(true)
+		defer txn.Abort()
+
+		if err := txn.Insert(memDBMFAConfigsTable, config); err != nil {
+			return nil, err
+		}
+
+		txn.Commit()
+
+		return nil, nil
+	}
+}
+
+func (b *SystemBackend) handleMFAMethodDelete(methodType string) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		name := d.Get("name").(string)
+
+		b.mfaLock.Lock()
+		defer b.mfaLock.Unlock()
+
+		txn := b.db.Txn(true)
+		defer txn.Abort()
+
+		raw, err := txn.First(memDBMFAConfigsTable, "name", name)
+		if err != nil {
+			return nil, err
+		}
+		if raw == nil {
+			return nil, nil
+		}
+		if raw.(*MFAConfig).Type != methodType {
+			return logical.ErrorResponse(fmt.Sprintf("MFA method %q is not of type %q", name, methodType)), nil
+		}
+
+		if err := req.Storage.Delete(ctx, mfaMethodSubPath+name); err != nil {
+			return nil, err
+		}
+		if err := txn.Delete(memDBMFAConfigsTable, raw); err != nil {
+			return nil, err
+		}
+
+		txn.Commit()
+
+		return nil, nil
+	}
+}
+
+func parseTOTPConfig(config *MFAConfig, d *framework.FieldData) *logical.Response {
+	totpConfig := &TOTPConfig{
+		Issuer:    d.Get("issuer").(string),
+		Period:    d.Get("period").(int),
+		Algorithm: d.Get("algorithm").(string),
+		Digits:    d.Get("digits").(int),
+		Skew:      d.Get("skew").(int),
+		KeySize:   d.Get("key_size").(int),
+		QRSize:    d.Get("qr_size").(int),
+	}
+
+	if totpConfig.Issuer == "" {
+		return logical.ErrorResponse("issuer must be set")
+	}
+	if _, err := totputil.ParseDigits(totpConfig.Digits); err != nil {
+		return logical.ErrorResponse(err.Error())
+	}
+	if _, err := totputil.ParseAlgorithm(totpConfig.Algorithm); err != nil {
+		return logical.ErrorResponse(err.Error())
+	}
+	if totpConfig.Period <= 0 {
+		return logical.ErrorResponse("the period value must be greater than zero")
+	}
+	if totpConfig.Skew != 0 && totpConfig.Skew != 1 {
+		return logical.ErrorResponse("the skew value must be 0 or 1")
+	}
+	if totpConfig.KeySize <= 0 {
+		return logical.ErrorResponse("the key_size value must be greater than zero")
+	}
+	if totpConfig.QRSize < 0 {
+		return logical.ErrorResponse("the qr_size value must be greater than or equal to zero")
+	}
+
+	config.TOTPConfig = totpConfig
+	return nil
+}
+
+func (b *SystemBackend) parseDuoConfig(config *MFAConfig, d *framework.FieldData) *logical.Response {
+	duoConfig := &DuoConfig{
+		IntegrationKey: d.Get("integration_key").(string),
+		SecretKey:      d.Get("secret_key").(string),
+		APIHostname:    d.Get("api_hostname").(string),
+		PushInfo:       d.Get("push_info").(string),
+	}
+
+	mountAccessor := d.Get("mount_accessor").(string)
+	if mountAccessor == "" {
+		return logical.ErrorResponse("mount_accessor must be set")
+	}
+	if b.Core.router.MatchingMountByAccessor(mountAccessor) == nil {
+		return logical.ErrorResponse(fmt.Sprintf("invalid mount accessor %q", mountAccessor))
+	}
+
+	usernameFormat := d.Get("username_format").(string)
+	if err := validateMFAUsernameFormat(usernameFormat); err != nil {
+		return logical.ErrorResponse(err.Error())
+	}
+
+	switch {
+	case duoConfig.IntegrationKey == "":
+		return logical.ErrorResponse("integration_key must be set")
+	case duoConfig.SecretKey == "":
+		return logical.ErrorResponse("secret_key must be set")
+	case duoConfig.APIHostname == "":
+		return logical.ErrorResponse("api_hostname must be set")
+	}
+
+	config.MountAccessor = mountAccessor
+	config.UsernameFormat = usernameFormat
+	config.DuoConfig = duoConfig
+	return nil
+}
+
+func (b *SystemBackend) handleTOTPGenerate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if req.EntityID == "" {
+		return logical.ErrorResponse("token is not associated with an entity"), nil
+	}
+
+	return b.handleTOTPGenerateCommon(ctx, d.Get("name").(string), req.EntityID)
+}
+
+func (b *SystemBackend) handleTOTPAdminGenerate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	entityID := d.Get("entity_id").(string)
+	if entityID == "" {
+		return logical.ErrorResponse("missing entity_id"), nil
+	}
+
+	return b.handleTOTPGenerateCommon(ctx, d.Get("name").(string), entityID)
+}
+
+// handleTOTPGenerateCommon generates a TOTP secret for the given entity and
+// stores it in the entity. Existing secrets are never overwritten; they have
+// to be destroyed explicitly first.
+func (b *SystemBackend) handleTOTPGenerateCommon(ctx context.Context, name, entityID string) (*logical.Response, error) {
+	config, err := b.mfaConfigByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil || config.Type != mfaMethodTypeTOTP {
+		return logical.ErrorResponse(fmt.Sprintf("TOTP MFA method %q not found", name)), nil
+	}
+
+	keyDigits, err := totputil.ParseDigits(config.TOTPConfig.Digits)
+	if err != nil {
+		return nil, err
+	}
+	keyAlgorithm, err := totputil.ParseAlgorithm(config.TOTPConfig.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	i := b.Core.identityStore
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	entity, err := i.MemDBEntityByID(entityID, true)
+	if err != nil {
+		return nil, err
+	}
+	if entity == nil {
+		return logical.ErrorResponse(fmt.Sprintf("invalid entity ID %q", entityID)), nil
+	}
+
+	if entity.MFASecrets == nil {
+		entity.MFASecrets = make(map[string]*mfa.Secret)
+	}
+	if _, ok := entity.MFASecrets[config.ID]; ok {
+		resp := &logical.Response{}
+		resp.AddWarning(fmt.Sprintf("entity already has a secret for MFA method %q", name))
+		return resp, nil
+	}
+
+	generated, err := totputil.GenerateKey(totplib.GenerateOpts{
+		Issuer:      config.TOTPConfig.Issuer,
+		AccountName: entity.ID,
+		Period:      uint(config.TOTPConfig.Period),
+		Digits:      keyDigits,
+		Algorithm:   keyAlgorithm,
+		SecretSize:  uint(config.TOTPConfig.KeySize),
+	}, config.TOTPConfig.QRSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate TOTP key: %v", err)
+	}
+
+	entity.MFASecrets[config.ID] = &mfa.Secret{
+		MethodName: config.Name,
+		TotpSecret: &mfa.TOTPSecret{
+			Issuer:      config.TOTPConfig.Issuer,
+			Period:      uint32(config.TOTPConfig.Period),
+			Algorithm:   int32(keyAlgorithm),
+			Digits:      int32(keyDigits),
+			Skew:        uint32(config.TOTPConfig.Skew),
+			KeySize:     uint32(config.TOTPConfig.KeySize),
+			AccountName: entity.ID,
+			Key:         generated.Key,
+		},
+	}
+
+	if err := i.upsertEntity(ctx, entity, nil, true); err != nil {
+		return nil, err
+	}
+
+	respData := map[string]interface{}{
+		"url": generated.URL,
+	}
+	if generated.Barcode != "" {
+		respData["barcode"] = generated.Barcode
+	}
+
+	return &logical.Response{
+		Data: respData,
+	}, nil
+}
+
+func (b *SystemBackend) handleTOTPAdminDestroy(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	entityID := d.Get("entity_id").(string)
+	if entityID == "" {
+		return logical.ErrorResponse("missing entity_id"), nil
+	}
+
+	config, err := b.mfaConfigByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil || config.Type != mfaMethodTypeTOTP {
+		return logical.ErrorResponse(fmt.Sprintf("TOTP MFA method %q not found", name)), nil
+	}
+
+	i := b.Core.identityStore
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	entity, err := i.MemDBEntityByID(entityID, true)
+	if err != nil {
+		return nil, err
+	}
+	if entity == nil {
+		return logical.ErrorResponse(fmt.Sprintf("invalid entity ID %q", entityID)), nil
+	}
+
+	if _, ok := entity.MFASecrets[config.ID]; !ok {
+		return nil, nil
+	}
+	delete(entity.MFASecrets, config.ID)
+
+	if err := i.upsertEntity(ctx, entity, nil, true); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// validateMFAUsernameFormat checks that the format only references the
+// supported template directives
+func validateMFAUsernameFormat(format string) error {
+	for _, match := range mfaUsernameDirectiveRe.FindAllStringSubmatch(format, -1) {
+		switch directive := match[1]; {
+		case directive == "alias.name", directive == "entity.name":
+		case strings.HasPrefix(directive, "alias.metadata."), strings.HasPrefix(directive, "entity.metadata."):
+		default:
+			return fmt.Errorf("unsupported username_format directive %q", directive)
+		}
+	}
+
+	if strings.Count(format, "{{") != strings.Count(format, "}}") {
+		return errors.New("unbalanced templating characters in username_format")
+	}
+
+	return nil
+}
@@ -1063,6 +1063,149 @@ func (b *SystemBackend) leasePaths() []*framework.Path {
 			HelpDescription: strings.TrimSpace(sysHelp["revoke-prefix"][1]),
 		},
 
+		{
+			Pattern: "leases/export$",
+
+			Fields: leaseFilterFields(),
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleLeaseExport,
+					Summary:  "Returns the details of the leases matching a filter.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleLeaseExport,
+					Summary:  "Returns the details of the leases matching a filter.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["leases-export"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["leases-export"][1]),
+		},
+
+		{
+			Pattern: "leases/count$",
+
+			Fields: leaseFilterFields(),
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleLeaseCount,
+					Summary:  "Counts the leases matching a filter by mount.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleLeaseCount,
+					Summary:  "Counts the leases matching a filter by mount.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["leases-count"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["leases-count"][1]),
+		},
+
+		{
+			Pattern: "leases/bulk-renew$",
+
+			Fields: func() map[string]*framework.FieldSchema {
+				fields := leaseBulkFields()
+				fields["increment"] = &framework.FieldSchema{
+					Type:        framework.TypeDurationSecond,
+					Description: strings.TrimSpace(sysHelp["increment"][0]),
+				}
+				return fields
+			}(),
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleLeaseBulkRenew,
+					Summary:  "Renews the leases matching a filter.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["leases-bulk-renew"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["leases-bulk-renew"][1]),
+		},
+
+		{
+			Pattern: "leases/bulk-revoke$",
+
+			Fields: func() map[string]*framework.FieldSchema {
+				fields := leaseBulkFields()
+				fields["sync"] = &framework.FieldSchema{
+					Type:        framework.TypeBool,
+					Default:     true,
+					Description: strings.TrimSpace(sysHelp["revoke-sync"][0]),
+				}
+				return fields
+			}(),
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleLeaseBulkRevoke,
+					Summary:  "Revokes the leases matching a filter.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["leases-bulk-revoke"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["leases-bulk-revoke"][1]),
+		},
+
+		{
+			Pattern: "leases/irrevocable$",
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleLeaseIrrevocableList,
+					Summary:  "Lists the leases that could not be revoked.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["leases-irrevocable"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["leases-irrevocable"][1]),
+		},
+
+		{
+			Pattern: "leases/irrevocable/retry$",
+
+			Fields: map[string]*framework.FieldSchema{
+				"lease_id": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: strings.TrimSpace(sysHelp["lease_id"][0]),
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleLeaseIrrevocableRetry,
+					Summary:  "Retries the revocation of an irrevocable lease.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["leases-irrevocable-retry"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["leases-irrevocable-retry"][1]),
+		},
+
+		{
+			Pattern: "leases/irrevocable/force-revoke$",
+
+			Fields: map[string]*framework.FieldSchema{
+				"lease_id": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: strings.TrimSpace(sysHelp["lease_id"][0]),
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleLeaseIrrevocableForceRevoke,
+					Summary:  "Removes an irrevocable lease, ignoring the errors of the backend.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["leases-irrevocable-force-revoke"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["leases-irrevocable-force-revoke"][1]),
+		},
+
 		{
 			Pattern: "leases/tidy$",
 
@@ -1076,6 +1219,26 @@ func (b *SystemBackend) leasePaths() []*framework.Path {
 	}
 }
 
+func (b *SystemBackend) metricsPath() *framework.Path {
+	return &framework.Path{
+		Pattern: "metrics",
+
+		Fields: map[string]*framework.FieldSchema{
+			"format": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: strings.TrimSpace(sysHelp["metrics-format"][0]),
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.handleMetricsRead,
+		},
+
+		HelpSynopsis:    strings.TrimSpace(sysHelp["metrics"][0]),
+		HelpDescription: strings.TrimSpace(sysHelp["metrics"][1]),
+	}
+}
+
 func (b *SystemBackend) remountPath() *framework.Path {
 	return &framework.Path{
 		Pattern: "remount",
@@ -0,0 +1,361 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func (b *SystemBackend) quotaPaths() []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "quotas/lease-count/?$",
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.handleLeaseCountQuotaList,
+					Summary:  "Lists the lease count quotas.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["quotas-lease-count-list"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["quotas-lease-count-list"][1]),
+		},
+
+		{
+			Pattern: "quotas/lease-count/" + framework.GenericNameRegex("name") + "$",
+
+			Fields: map[string]*framework.FieldSchema{
+				"name": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: "Name of the quota.",
+				},
+				"path": &framework.FieldSchema{
+					Type: framework.TypeString,
+					Description: `Path the quota applies to: a namespace, a mount or a
+path prefix within a mount. Empty applies the quota to all the leases.`,
+				},
+				"max_leases": &framework.FieldSchema{
+					Type:        framework.TypeInt,
+					Description: "Maximum number of leases under the path.",
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleLeaseCountQuotaRead,
+					Summary:  "Reads a lease count quota and its current usage.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleLeaseCountQuotaUpdate,
+					Summary:  "Creates or updates a lease count quota.",
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.handleLeaseCountQuotaDelete,
+					Summary:  "Deletes a lease count quota.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["quotas-lease-count"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["quotas-lease-count"][1]),
+		},
+
+		{
+			Pattern: "quotas/rate-limit/?$",
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.handleRateLimitQuotaList,
+					Summary:  "Lists the rate limit quotas.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["quotas-rate-limit-list"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["quotas-rate-limit-list"][1]),
+		},
+
+		{
+			Pattern: "quotas/rate-limit/" + framework.GenericNameRegex("name") + "$",
+
+			Fields: map[string]*framework.FieldSchema{
+				"name": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: "Name of the quota.",
+				},
+				"path": &framework.FieldSchema{
+					Type: framework.TypeString,
+					Description: `Path the quota applies to: a namespace, a mount or a
+path prefix within a mount. Empty applies the quota to all the requests.`,
+				},
+				"rate": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: "Number of requests per second allowed for each client.",
+				},
+				"burst": &framework.FieldSchema{
+					Type: framework.TypeInt,
+					Description: `Number of requests a client can make at once. Defaults
+to the rate, rounded up.`,
+				},
+				"key_type": &framework.FieldSchema{
+					Type:    framework.TypeString,
+					Default: rateLimitKeyClientIP,
+					Description: `What identifies a client: "client_ip" or
+"token_accessor".`,
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleRateLimitQuotaRead,
+					Summary:  "Reads a rate limit quota.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleRateLimitQuotaUpdate,
+					Summary:  "Creates or updates a rate limit quota.",
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.handleRateLimitQuotaDelete,
+					Summary:  "Deletes a rate limit quota.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["quotas-rate-limit"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["quotas-rate-limit"][1]),
+		},
+	}
+}
+
+// quotaPath normalizes the path of a quota so that it ends with a slash, so
+// that a quota on a mount or a path prefix does not also apply to its
+// siblings sharing the same prefix, such as "secret-other/" for "secret"
+func quotaPath(path string) string {
+	path = strings.TrimPrefix(path, "/")
+	if path != "" && !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	return path
+}
+
+func (b *SystemBackend) leaseCountQuotaView() *BarrierView {
+	return b.Core.systemBarrierView.SubView(leaseCountQuotaSubPath)
+}
+
+func (b *SystemBackend) handleLeaseCountQuotaList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	names, err := b.leaseCountQuotaView().List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return logical.ListResponse(names), nil
+}
+
+func (b *SystemBackend) handleLeaseCountQuotaRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	quota, err := getLeaseCountQuota(ctx, b.leaseCountQuotaView(), d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if quota == nil {
+		return nil, nil
+	}
+
+	leases := 0
+	if b.Core.expiration != nil {
+		leases = b.Core.expiration.leaseCounts.quotaUsage(quota.Name)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"name":       quota.Name,
+			"path":       quota.Path,
+			"max_leases": quota.MaxLeases,
+			"leases":     leases,
+		},
+	}, nil
+}
+
+func (b *SystemBackend) handleLeaseCountQuotaUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	view := b.leaseCountQuotaView()
+
+	quota, err := getLeaseCountQuota(ctx, view, name)
+	if err != nil {
+		return nil, err
+	}
+	if quota == nil {
+		if _, ok := d.GetOk("max_leases"); !ok {
+			return logical.ErrorResponse("max_leases is required"), nil
+		}
+		quota = &LeaseCountQuota{
+			Name: name,
+		}
+	}
+
+	if raw, ok := d.GetOk("path"); ok {
+		quota.Path = quotaPath(raw.(string))
+	}
+	if raw, ok := d.GetOk("max_leases"); ok {
+		quota.MaxLeases = raw.(int)
+	}
+	if quota.MaxLeases <= 0 {
+		return logical.ErrorResponse("max_leases must be positive"), nil
+	}
+	if strings.Contains(quota.Path, "..") {
+		return logical.ErrorResponse(fmt.Sprintf("invalid path %q", quota.Path)), nil
+	}
+
+	entry, err := logical.StorageEntryJSON(name, quota)
+	if err != nil {
+		return nil, err
+	}
+	if err := view.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	if b.Core.expiration != nil {
+		b.Core.expiration.leaseCounts.setQuota(quota)
+	}
+
+	return nil, nil
+}
+
+func (b *SystemBackend) handleLeaseCountQuotaDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if err := b.leaseCountQuotaView().Delete(ctx, name); err != nil {
+		return nil, err
+	}
+
+	if b.Core.expiration != nil {
+		b.Core.expiration.leaseCounts.deleteQuota(name)
+	}
+
+	return nil, nil
+}
+
+func (b *SystemBackend) rateLimitQuotaView() *BarrierView {
+	return b.Core.systemBarrierView.SubView(rateLimitQuotaSubPath)
+}
+
+func (b *SystemBackend) handleRateLimitQuotaList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	names, err := b.rateLimitQuotaView().List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return logical.ListResponse(names), nil
+}
+
+func (b *SystemBackend) handleRateLimitQuotaRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	quota, err := getRateLimitQuota(ctx, b.rateLimitQuotaView(), d.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if quota == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"name":     quota.Name,
+			"path":     quota.Path,
+			"rate":     quota.Rate,
+			"burst":    quota.Burst,
+			"key_type": quota.KeyType,
+		},
+	}, nil
+}
+
+func (b *SystemBackend) handleRateLimitQuotaUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	view := b.rateLimitQuotaView()
+
+	quota, err := getRateLimitQuota(ctx, view, name)
+	if err != nil {
+		return nil, err
+	}
+	if quota == nil {
+		if _, ok := d.GetOk("rate"); !ok {
+			return logical.ErrorResponse("rate is required"), nil
+		}
+		quota = &RateLimitQuota{
+			Name:    name,
+			KeyType: d.Get("key_type").(string),
+		}
+	}
+
+	if raw, ok := d.GetOk("path"); ok {
+		quota.Path = quotaPath(raw.(string))
+	}
+	if raw, ok := d.GetOk("rate"); ok {
+		rate, err := strconv.ParseFloat(raw.(string), 64)
+		if err != nil || rate <= 0 {
+			return logical.ErrorResponse("rate must be a positive number"), nil
+		}
+		quota.Rate = rate
+		if _, ok := d.GetOk("burst"); !ok {
+			quota.Burst = int(math.Ceil(rate))
+		}
+	}
+	if raw, ok := d.GetOk("burst"); ok {
+		quota.Burst = raw.(int)
+	}
+	if raw, ok := d.GetOk("key_type"); ok {
+		quota.KeyType = raw.(string)
+	}
+
+	if quota.Burst <= 0 {
+		return logical.ErrorResponse("burst must be positive"), nil
+	}
+	switch quota.KeyType {
+	case rateLimitKeyClientIP, rateLimitKeyTokenAccessor:
+	default:
+		return logical.ErrorResponse(fmt.Sprintf("invalid key_type %q", quota.KeyType)), nil
+	}
+
+	// Only one quota applies to a path
+	names, err := view.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range names {
+		if other == name {
+			continue
+		}
+		otherQuota, err := getRateLimitQuota(ctx, view, other)
+		if err != nil {
+			return nil, err
+		}
+		if otherQuota != nil && otherQuota.Path == quota.Path {
+			return logical.ErrorResponse(fmt.Sprintf("quota %q already applies to path %q", other, quota.Path)), nil
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON(name, quota)
+	if err != nil {
+		return nil, err
+	}
+	if err := view.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	if b.Core.rateLimitQuotas != nil {
+		b.Core.rateLimitQuotas.setQuota(quota)
+	}
+
+	return nil, nil
+}
+
+func (b *SystemBackend) handleRateLimitQuotaDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	if err := b.rateLimitQuotaView().Delete(ctx, name); err != nil {
+		return nil, err
+	}
+
+	if b.Core.rateLimitQuotas != nil {
+		b.Core.rateLimitQuotas.deleteQuota(name)
+	}
+
+	return nil, nil
+}
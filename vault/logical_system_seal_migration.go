@@ -0,0 +1,203 @@
+package vault
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func (b *SystemBackend) sealMigrationPaths() []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "seal/migrate$",
+
+			Fields: map[string]*framework.FieldSchema{
+				"type": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: "Type of the seal to migrate to, as in the seal stanza of the server configuration.",
+				},
+				"config": &framework.FieldSchema{
+					Type:        framework.TypeKVPairs,
+					Description: "Configuration of the seal to migrate to, as in the seal stanza of the server configuration.",
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleSealMigrationStatus,
+					Summary:  "Reads the progress of the current seal migration.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback:    b.handleSealMigrationInit,
+					Summary:     "Initializes a new seal migration.",
+					Description: "Only a single seal migration attempt can take place at a time. The seal is migrated once the threshold number of unseal key shares, or recovery key shares when using an auto seal, are provided to sys/seal/migrate/update.",
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.handleSealMigrationCancel,
+					Summary:  "Cancels the seal migration attempt waiting for key shares.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["seal-migrate"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["seal-migrate"][1]),
+		},
+
+		{
+			Pattern: "seal/migrate/update$",
+
+			Fields: map[string]*framework.FieldSchema{
+				"key": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: "Specifies a single unseal key share, or recovery key share when using an auto seal.",
+				},
+				"nonce": &framework.FieldSchema{
+					Type:        framework.TypeString,
+					Description: "Specifies the nonce of the seal migration attempt.",
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback:    b.handleSealMigrationUpdate,
+					Summary:     "Enter a single key share to progress the seal migration.",
+					Description: "Once the threshold number of key shares is reached, Vault migrates the seal in the background; its progress is read from sys/seal/migrate.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["seal-migrate"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["seal-migrate"][1]),
+		},
+	}
+}
+
+// handleSealMigrationStatus returns the progress of the seal migration
+// attempt waiting for key shares, and the state of the last seal migration
+func (b *SystemBackend) handleSealMigrationStatus(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	threshold, err := b.Core.SealMigrationThreshold(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"started":  false,
+			"nonce":    "",
+			"type":     "",
+			"progress": 0,
+			"required": threshold,
+		},
+	}
+
+	if config := b.Core.SealMigrationConfig(); config != nil {
+		resp.Data["started"] = true
+		resp.Data["nonce"] = config.Nonce
+		resp.Data["type"] = config.Type
+		resp.Data["progress"] = len(config.Progress)
+	}
+
+	if status := b.Core.SealMigrationStatus(); status != nil {
+		resp.Data["migration"] = sealMigrationStatusData(status)
+	}
+
+	return resp, nil
+}
+
+// handleSealMigrationInit starts a new seal migration attempt
+func (b *SystemBackend) handleSealMigrationInit(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, codedErr := b.Core.SealMigrationInit(ctx, d.Get("type").(string), d.Get("config").(map[string]string))
+	if codedErr != nil {
+		return handleError(codedErr)
+	}
+
+	threshold, err := b.Core.SealMigrationThreshold(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"started":  true,
+			"nonce":    config.Nonce,
+			"type":     config.Type,
+			"progress": 0,
+			"required": threshold,
+		},
+	}, nil
+}
+
+// handleSealMigrationUpdate provides a key share to the seal migration
+// attempt
+func (b *SystemBackend) handleSealMigrationUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	encodedKey := d.Get("key").(string)
+	if encodedKey == "" {
+		return logical.ErrorResponse("'key' must be specified"), logical.ErrInvalidRequest
+	}
+
+	// Decode the key, which is base64 or hex encoded
+	min, max := b.Core.BarrierKeyLength()
+	key, err := hex.DecodeString(encodedKey)
+	// We check min and max here to ensure that a string that is base64
+	// encoded but also valid hex will not be valid and we instead base64
+	// decode it
+	if err != nil || len(key) < min || len(key) > max {
+		key, err = base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil {
+			return logical.ErrorResponse("'key' must be a valid hex or base64 string"), logical.ErrInvalidRequest
+		}
+	}
+
+	status, codedErr := b.Core.SealMigrationUpdate(ctx, key, d.Get("nonce").(string))
+	if codedErr != nil {
+		return handleError(codedErr)
+	}
+
+	if status != nil {
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"complete":  true,
+				"migration": sealMigrationStatusData(status),
+			},
+		}, nil
+	}
+
+	threshold, err := b.Core.SealMigrationThreshold(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"complete": false,
+			"required": threshold,
+		},
+	}
+	if config := b.Core.SealMigrationConfig(); config != nil {
+		resp.Data["nonce"] = config.Nonce
+		resp.Data["progress"] = len(config.Progress)
+	}
+	return resp, nil
+}
+
+// handleSealMigrationCancel cancels the seal migration attempt waiting for
+// key shares
+func (b *SystemBackend) handleSealMigrationCancel(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if codedErr := b.Core.SealMigrationCancel(ctx); codedErr != nil {
+		return handleError(codedErr)
+	}
+	return nil, nil
+}
+
+func sealMigrationStatusData(status *SealMigrationStatus) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                status.ID,
+		"type":              status.Type,
+		"state":             status.State,
+		"error":             status.Error,
+		"entries_scanned":   status.EntriesScanned,
+		"entries_rewrapped": status.EntriesRewrapped,
+		"entries_failed":    status.EntriesFailed,
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"testing"
 	"time"
 
+	metrics "github.com/armon/go-metrics"
 	"github.com/fatih/structs"
 	"github.com/go-test/deep"
 	hclog "github.com/hashicorp/go-hclog"
@@ -21,6 +22,7 @@ import (
 	"github.com/hashicorp/vault/helper/builtinplugins"
 	"github.com/hashicorp/vault/helper/consts"
 	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/metricsutil"
 	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/salt"
 	"github.com/hashicorp/vault/logical"
@@ -53,6 +55,18 @@ func TestSystemBackend_RootPaths(t *testing.T) {
 		"leases/revoke-prefix/*",
 		"leases/revoke-force/*",
 		"leases/lookup/*",
+		"leases/export",
+		"leases/count",
+		"leases/bulk-renew",
+		"leases/bulk-revoke",
+		"leases/irrevocable",
+		"leases/irrevocable/*",
+		"quotas/*",
+		"storage/raft/configuration",
+		"storage/raft/remove-peer",
+		"storage/raft/snapshot",
+		"seal/migrate",
+		"seal/migrate/update",
 	}
 
 	b := testSystemBackend(t)
@@ -869,6 +883,128 @@ func TestSystemBackend_leases_list(t *testing.T) {
 	}
 }
 
+func TestSystemBackend_leases_export(t *testing.T) {
+	core, b, root := testCoreSystemBackend(t)
+	ctx := namespace.RootContext(nil)
+
+	// Create leases for two roles
+	var leaseIDs []string
+	for _, path := range []string{"secret/creds/a", "secret/creds/a", "secret/creds/b"} {
+		req := logical.TestRequest(t, logical.UpdateOperation, path)
+		req.Data["foo"] = "bar"
+		req.Data["ttl"] = "180s"
+		req.ClientToken = root
+		if _, err := core.HandleRequest(ctx, req); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		req = logical.TestRequest(t, logical.ReadOperation, path)
+		req.ClientToken = root
+		resp, err := core.HandleRequest(ctx, req)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if resp == nil || resp.Secret == nil || resp.Secret.LeaseID == "" {
+			t.Fatalf("bad: %#v", resp)
+		}
+		leaseIDs = append(leaseIDs, resp.Secret.LeaseID)
+	}
+
+	te, err := core.tokenStore.Lookup(ctx, root)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	req := logical.TestRequest(t, logical.ReadOperation, "leases/export")
+	req.Data["mount"] = "secret"
+	req.Data["role"] = "a"
+	resp, err := b.HandleRequest(ctx, req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	leases := resp.Data["leases"].([]map[string]interface{})
+	if len(leases) != 2 {
+		t.Fatalf("expected 2 leases, got %#v", leases)
+	}
+	for _, lease := range leases {
+		if lease["mount"] != "secret/" || lease["role"] != "a" || lease["token_accessor"] != te.Accessor {
+			t.Fatalf("bad: %#v", lease)
+		}
+		if lease["expire_time"] == nil || lease["issue_time"].(time.Time).IsZero() {
+			t.Fatalf("bad: %#v", lease)
+		}
+	}
+
+	// Prefixes may end in the middle of a lease ID
+	req = logical.TestRequest(t, logical.ReadOperation, "leases/export")
+	req.Data["prefix"] = leaseIDs[2][:len(leaseIDs[2])-3]
+	resp, err = b.HandleRequest(ctx, req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	leases = resp.Data["leases"].([]map[string]interface{})
+	if len(leases) != 1 || leases[0]["id"] != leaseIDs[2] {
+		t.Fatalf("bad: %#v", leases)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "leases/count")
+	resp, err = b.HandleRequest(ctx, req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["total"] != 3 || resp.Data["counts"].(map[string]int)["secret/"] != 3 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Bulk operations require a filter
+	req = logical.TestRequest(t, logical.UpdateOperation, "leases/bulk-revoke")
+	resp, err = b.HandleRequest(ctx, req)
+	if err != logical.ErrInvalidRequest || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got err: %v, resp: %#v", err, resp)
+	}
+
+	req = logical.TestRequest(t, logical.UpdateOperation, "leases/bulk-revoke")
+	req.Data["role"] = "a"
+	req.Data["dry_run"] = true
+	resp, err = b.HandleRequest(ctx, req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if ids := resp.Data["lease_ids"].([]string); len(ids) != 2 {
+		t.Fatalf("bad: %#v", ids)
+	}
+
+	req = logical.TestRequest(t, logical.UpdateOperation, "leases/bulk-renew")
+	req.Data["role"] = "a"
+	req.Data["increment"] = "1h"
+	resp, err = b.HandleRequest(ctx, req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if ids := resp.Data["lease_ids"].([]string); len(ids) != 2 || resp.Data["errors"] != nil {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	req = logical.TestRequest(t, logical.UpdateOperation, "leases/bulk-revoke")
+	req.Data["role"] = "a"
+	resp, err = b.HandleRequest(ctx, req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if ids := resp.Data["lease_ids"].([]string); len(ids) != 2 || resp.Data["errors"] != nil {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "leases/count")
+	resp, err = b.HandleRequest(ctx, req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp.Data["total"] != 1 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+}
+
 func TestSystemBackend_renew(t *testing.T) {
 	core, b, root := testCoreSystemBackend(t)
 
@@ -2555,3 +2691,31 @@ func TestSystemBackend_OpenAPI(t *testing.T) {
 		t.Fatalf("expected to find path '/rotate'")
 	}
 }
+
+func TestSystemBackend_Metrics(t *testing.T) {
+	c, b, _ := testCoreSystemBackend(t)
+
+	// Metrics are unavailable unless the server set up telemetry
+	req := logical.TestRequest(t, logical.ReadOperation, "metrics")
+	resp, err := b.HandleRequest(namespace.RootContext(nil), req)
+	if err != logical.ErrInvalidRequest || !resp.IsError() {
+		t.Fatalf("expected invalid request, got: %v %#v", err, resp)
+	}
+
+	inm := metrics.NewInmemSink(10*time.Second, time.Minute)
+	inm.IncrCounter([]string{"core", "test_counter"}, 1)
+	c.metricsHelper = metricsutil.NewMetricsHelper(inm, false)
+
+	req = logical.TestRequest(t, logical.ReadOperation, "metrics")
+	req.Data["format"] = "json"
+	resp, err = b.HandleRequest(namespace.RootContext(nil), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data[logical.HTTPStatusCode] != 200 {
+		t.Fatalf("bad: %#v", resp)
+	}
+	if !strings.Contains(string(resp.Data[logical.HTTPRawBody].([]byte)), "core.test_counter") {
+		t.Fatalf("bad: %s", resp.Data[logical.HTTPRawBody])
+	}
+}
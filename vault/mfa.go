@@ -0,0 +1,249 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/duosecurity/duo_api_golang"
+	"github.com/duosecurity/duo_api_golang/authapi"
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/identity"
+	"github.com/hashicorp/vault/helper/mfa/duo"
+	"github.com/hashicorp/vault/logical"
+	otplib "github.com/pquerna/otp"
+	totplib "github.com/pquerna/otp/totp"
+)
+
+// mfaUsernameDirectiveRe matches the template directives of a username
+// format, e.g. "{{alias.name}}"
+var mfaUsernameDirectiveRe = regexp.MustCompile(`{{\s*([^{}\s]+)\s*}}`)
+
+// newDuoAuthClient creates the client used to talk to the Duo Auth API. It is
+// a variable so that tests can point it at a local Duo stand-in.
+var newDuoAuthClient = func(config *DuoConfig) duo.AuthClient {
+	client := duoapi.NewDuoApi(
+		config.IntegrationKey,
+		config.SecretKey,
+		config.APIHostname,
+		"HashiCorp Vault",
+		duoapi.SetTimeout(30*time.Second),
+	)
+	return authapi.NewAuthApi(*client)
+}
+
+// validateMFA checks the credentials supplied in the X-Vault-MFA header of
+// the request against each of the given MFA methods. All the methods must be
+// satisfied for the request to be allowed.
+func (b *SystemBackend) validateMFA(ctx context.Context, methodNames []string, req *logical.Request, entity *identity.Entity) error {
+	if entity == nil {
+		return errors.New("MFA validation requires the token to be associated with an entity")
+	}
+
+	for _, name := range methodNames {
+		config, err := b.mfaConfigByName(name)
+		if err != nil {
+			return errwrap.Wrapf("failed to read MFA method: {{err}}", err)
+		}
+		if config == nil {
+			return fmt.Errorf("MFA method %q is not configured", name)
+		}
+
+		creds := req.MFACreds[name]
+
+		switch config.Type {
+		case mfaMethodTypeTOTP:
+			if len(creds) == 0 || creds[0] == "" {
+				return fmt.Errorf("MFA credentials not supplied for method %q", name)
+			}
+			err = b.validateTOTP(config, entity, creds[0])
+		case mfaMethodTypeDuo:
+			var remoteAddr string
+			if req.Connection != nil {
+				remoteAddr = req.Connection.RemoteAddr
+			}
+			err = b.validateDuo(config, entity, creds, remoteAddr)
+		default:
+			err = fmt.Errorf("unsupported MFA method type %q", config.Type)
+		}
+		if err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("MFA validation failed for method %q: {{err}}", name), err)
+		}
+	}
+
+	return nil
+}
+
+func (b *SystemBackend) validateTOTP(config *MFAConfig, entity *identity.Entity, passcode string) error {
+	secret := entity.MFASecrets[config.ID]
+	if secret == nil || secret.TotpSecret == nil {
+		return errors.New("entity does not have a TOTP secret for the method")
+	}
+	totpSecret := secret.TotpSecret
+
+	// Passcodes can't be reused within the window in which they are valid
+	usedName := fmt.Sprintf("%s_%s_%s", config.ID, entity.ID, passcode)
+	if _, ok := b.mfaUsedCodes.Get(usedName); ok {
+		return errors.New("code already used; wait until the next time period")
+	}
+
+	valid, err := totplib.ValidateCustom(passcode, totpSecret.Key, time.Now(), totplib.ValidateOpts{
+		Period:    uint(totpSecret.Period),
+		Skew:      uint(totpSecret.Skew),
+		Digits:    otplib.Digits(totpSecret.Digits),
+		Algorithm: otplib.Algorithm(totpSecret.Algorithm),
+	})
+	if err != nil && err != otplib.ErrValidateInputInvalidLength {
+		return errwrap.Wrapf("failed to validate the passcode: {{err}}", err)
+	}
+	if !valid {
+		return errors.New("invalid passcode")
+	}
+
+	// Take the skew, add two for behind and in front, and multiply that by
+	// the period to cover the full validity of the passcode
+	validity := time.Duration(int64(time.Second) * int64(totpSecret.Period) * int64(2+totpSecret.Skew))
+	if err := b.mfaUsedCodes.Add(usedName, nil, validity); err != nil {
+		return errwrap.Wrapf("error adding code to used cache: {{err}}", err)
+	}
+
+	return nil
+}
+
+// validateDuo asks Duo to authenticate the user mapped to the entity. A
+// "passcode=<code>" credential is verified directly; otherwise a push
+// notification is sent to the user's device.
+func (b *SystemBackend) validateDuo(config *MFAConfig, entity *identity.Entity, creds []string, remoteAddr string) error {
+	username, err := mfaUsername(config, entity)
+	if err != nil {
+		return err
+	}
+
+	var passcode string
+	for _, cred := range creds {
+		if strings.HasPrefix(cred, "passcode=") {
+			passcode = strings.TrimPrefix(cred, "passcode=")
+		}
+	}
+
+	client := newDuoAuthClient(config.DuoConfig)
+
+	preauthOptions := []func(*url.Values){authapi.PreauthUsername(username)}
+	if remoteAddr != "" {
+		preauthOptions = append(preauthOptions, authapi.PreauthIpAddr(remoteAddr))
+	}
+	preauth, err := client.Preauth(preauthOptions...)
+	if err != nil {
+		return errwrap.Wrapf("failed to call Duo preauth: {{err}}", err)
+	}
+	if preauth == nil {
+		return errors.New("failed to call Duo preauth")
+	}
+	if preauth.StatResult.Stat != "OK" {
+		return fmt.Errorf("could not look up Duo user information: %s", duoStatMessage(preauth.StatResult))
+	}
+
+	switch preauth.Response.Result {
+	case "allow":
+		return nil
+	case "deny":
+		return fmt.Errorf("denied by Duo: %s", preauth.Response.Status_Msg)
+	case "enroll":
+		return fmt.Errorf("user is not enrolled in Duo: %s", preauth.Response.Status_Msg)
+	case "auth":
+	default:
+		return fmt.Errorf("invalid Duo preauth response %q", preauth.Response.Result)
+	}
+
+	factor := "push"
+	options := []func(*url.Values){authapi.AuthUsername(username)}
+	if remoteAddr != "" {
+		options = append(options, authapi.AuthIpAddr(remoteAddr))
+	}
+	if passcode != "" {
+		factor = "passcode"
+		options = append(options, authapi.AuthPasscode(passcode))
+	} else {
+		options = append(options, authapi.AuthDevice("auto"))
+		if config.DuoConfig.PushInfo != "" {
+			options = append(options, authapi.AuthPushinfo(config.DuoConfig.PushInfo))
+		}
+	}
+
+	result, err := client.Auth(factor, options...)
+	if err != nil {
+		return errwrap.Wrapf("failed to call Duo auth: {{err}}", err)
+	}
+	if result == nil {
+		return errors.New("failed to call Duo auth")
+	}
+	if result.StatResult.Stat != "OK" {
+		return fmt.Errorf("could not authenticate Duo user: %s", duoStatMessage(result.StatResult))
+	}
+	if result.Response.Result != "allow" {
+		return fmt.Errorf("denied by Duo: %s", result.Response.Status_Msg)
+	}
+
+	return nil
+}
+
+func duoStatMessage(stat authapi.StatResult) string {
+	var msg string
+	if stat.Message != nil {
+		msg = *stat.Message
+	}
+	if stat.Message_Detail != nil {
+		msg = fmt.Sprintf("%s (%s)", msg, *stat.Message_Detail)
+	}
+	return msg
+}
+
+// mfaUsername derives the username used by the MFA provider from the alias of
+// the entity on the mount configured on the method
+func mfaUsername(config *MFAConfig, entity *identity.Entity) (string, error) {
+	var alias *identity.Alias
+	for _, entityAlias := range entity.Aliases {
+		if entityAlias.MountAccessor == config.MountAccessor {
+			alias = entityAlias
+			break
+		}
+	}
+	if alias == nil {
+		return "", fmt.Errorf("entity does not have an alias on mount accessor %q", config.MountAccessor)
+	}
+
+	if config.UsernameFormat == "" {
+		return alias.Name, nil
+	}
+
+	var formatErr error
+	username := mfaUsernameDirectiveRe.ReplaceAllStringFunc(config.UsernameFormat, func(directive string) string {
+		selector := mfaUsernameDirectiveRe.FindStringSubmatch(directive)[1]
+
+		var value string
+		var ok bool
+		switch {
+		case selector == "alias.name":
+			value, ok = alias.Name, true
+		case selector == "entity.name":
+			value, ok = entity.Name, true
+		case strings.HasPrefix(selector, "alias.metadata."):
+			value, ok = alias.Metadata[strings.TrimPrefix(selector, "alias.metadata.")]
+		case strings.HasPrefix(selector, "entity.metadata."):
+			value, ok = entity.Metadata[strings.TrimPrefix(selector, "entity.metadata.")]
+		}
+		if !ok && formatErr == nil {
+			formatErr = fmt.Errorf("no value found for %q in username format", selector)
+		}
+		return value
+	})
+	if formatErr != nil {
+		return "", formatErr
+	}
+
+	return username, nil
+}
@@ -0,0 +1,469 @@
+package vault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duosecurity/duo_api_golang"
+	"github.com/duosecurity/duo_api_golang/authapi"
+	credUserpass "github.com/hashicorp/vault/builtin/credential/userpass"
+	"github.com/hashicorp/vault/helper/mfa/duo"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/logical"
+	otplib "github.com/pquerna/otp"
+	totplib "github.com/pquerna/otp/totp"
+)
+
+// testMFASetup mounts userpass, writes secret/foo, creates a policy that
+// requires the given MFA method on secret/foo and logs in a user holding it.
+// It returns the user's token, entity ID and the userpass mount accessor.
+func testMFASetup(t *testing.T, c *Core, root, methodName string) (string, string, string) {
+	t.Helper()
+
+	ctx := namespace.RootContext(nil)
+	c.credentialBackends["userpass"] = credUserpass.Factory
+
+	requests := []*logical.Request{
+		{
+			Path:      "sys/auth/userpass",
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"type": "userpass",
+			},
+		},
+		{
+			Path:      "sys/policy/mfa",
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"policy": `path "secret/foo" {
+	capabilities = ["read"]
+	mfa_methods  = ["` + methodName + `"]
+}
+path "sys/mfa/method/totp/` + methodName + `/generate" {
+	capabilities = ["read"]
+}`,
+			},
+		},
+		{
+			Path:      "auth/userpass/users/test",
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"password": "foo",
+				"policies": "mfa",
+			},
+		},
+		{
+			Path:      "secret/foo",
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"value": "bar",
+			},
+		},
+	}
+	for _, req := range requests {
+		req.ClientToken = root
+		req.Connection = &logical.Connection{}
+		resp, err := c.HandleRequest(ctx, req)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err: %v, resp: %#v", req.Path, err, resp)
+		}
+	}
+
+	resp, err := c.HandleRequest(ctx, &logical.Request{
+		Path:      "auth/userpass/login/test",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"password": "foo",
+		},
+		Connection: &logical.Connection{},
+	})
+	if err != nil || resp == nil || resp.Auth == nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	if resp.Auth.EntityID == "" {
+		t.Fatal("expected an entity ID on the login")
+	}
+
+	mountEntry := c.router.MatchingMountEntry(ctx, "auth/userpass/")
+	if mountEntry == nil {
+		t.Fatal("userpass mount not found")
+	}
+
+	return resp.Auth.ClientToken, resp.Auth.EntityID, mountEntry.Accessor
+}
+
+func testMFAReadSecret(t *testing.T, c *Core, token string, creds logical.MFACreds) error {
+	t.Helper()
+
+	resp, err := c.HandleRequest(namespace.RootContext(nil), &logical.Request{
+		Path:        "secret/foo",
+		Operation:   logical.ReadOperation,
+		ClientToken: token,
+		MFACreds:    creds,
+		Connection:  &logical.Connection{},
+	})
+	if err != nil {
+		return err
+	}
+	if resp == nil || resp.Data["value"] != "bar" {
+		t.Fatalf("bad: %#v", resp)
+	}
+	return nil
+}
+
+func TestMFA_TOTP(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+
+	resp, err := c.HandleRequest(ctx, &logical.Request{
+		Path:        "sys/mfa/method/totp/my_totp",
+		Operation:   logical.UpdateOperation,
+		ClientToken: root,
+		Data: map[string]interface{}{
+			"issuer": "vault",
+		},
+	})
+	if err != nil || resp != nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	token, entityID, _ := testMFASetup(t, c, root, "my_totp")
+
+	// The entity has no TOTP secret yet
+	err = testMFAReadSecret(t, c, token, logical.MFACreds{"my_totp": []string{"123456"}})
+	if err == nil || !strings.Contains(err.Error(), "does not have a TOTP secret") {
+		t.Fatalf("expected missing secret error, got: %v", err)
+	}
+
+	// Self-enroll using the user's own token
+	resp, err = c.HandleRequest(ctx, &logical.Request{
+		Path:        "sys/mfa/method/totp/my_totp/generate",
+		Operation:   logical.ReadOperation,
+		ClientToken: token,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	if resp.Data["barcode"] == "" {
+		t.Fatal("expected a barcode")
+	}
+	key, err := otplib.NewKeyFromURL(resp.Data["url"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.AccountName() != entityID {
+		t.Fatalf("bad: account name: %q", key.AccountName())
+	}
+
+	// Generating again doesn't overwrite the secret
+	resp, err = c.HandleRequest(ctx, &logical.Request{
+		Path:        "sys/mfa/method/totp/my_totp/admin-generate",
+		Operation:   logical.UpdateOperation,
+		ClientToken: root,
+		Data: map[string]interface{}{
+			"entity_id": entityID,
+		},
+	})
+	if err != nil || resp == nil || len(resp.Warnings) != 1 || resp.Data["url"] != nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	// No credentials
+	err = testMFAReadSecret(t, c, token, nil)
+	if err == nil || !strings.Contains(err.Error(), logical.ErrPermissionDenied.Error()) {
+		t.Fatalf("expected permission denied, got: %v", err)
+	}
+
+	// Invalid passcode
+	err = testMFAReadSecret(t, c, token, logical.MFACreds{"my_totp": []string{"000000"}})
+	if err == nil || !strings.Contains(err.Error(), "invalid passcode") {
+		t.Fatalf("expected invalid passcode error, got: %v", err)
+	}
+
+	code, err := totplib.GenerateCode(key.Secret(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testMFAReadSecret(t, c, token, logical.MFACreds{"my_totp": []string{code}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Passcodes can't be replayed
+	err = testMFAReadSecret(t, c, token, logical.MFACreds{"my_totp": []string{code}})
+	if err == nil || !strings.Contains(err.Error(), "code already used") {
+		t.Fatalf("expected replay error, got: %v", err)
+	}
+
+	// Root tokens are not subject to MFA
+	if err := testMFAReadSecret(t, c, root, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = c.HandleRequest(ctx, &logical.Request{
+		Path:        "sys/mfa/method/totp/my_totp/admin-destroy",
+		Operation:   logical.UpdateOperation,
+		ClientToken: root,
+		Data: map[string]interface{}{
+			"entity_id": entityID,
+		},
+	})
+	if err != nil || resp != nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	entity, err := c.identityStore.MemDBEntityByID(entityID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entity.MFASecrets) != 0 {
+		t.Fatalf("expected the secret to be destroyed, got: %#v", entity.MFASecrets)
+	}
+}
+
+func TestMFA_MethodNotConfigured(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	token, _, _ := testMFASetup(t, c, root, "missing")
+
+	err := testMFAReadSecret(t, c, token, logical.MFACreds{"missing": []string{"123456"}})
+	if err == nil || !strings.Contains(err.Error(), `MFA method "missing" is not configured`) {
+		t.Fatalf("expected unconfigured method error, got: %v", err)
+	}
+}
+
+// testDuoServer is a stand-in for the Duo Auth API. Preauth always requires
+// a second factor; pushes are approved for allowedUser and passcodes are
+// accepted if they equal passcode.
+func testDuoServer(t *testing.T, allowedUser, passcode string) *httptest.Server {
+	writeResult := func(w http.ResponseWriter, response interface{}) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"stat":     "OK",
+			"response": response,
+		})
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth/v2/preauth", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeResult(w, map[string]interface{}{
+			"result":     "auth",
+			"status_msg": "Account is active",
+		})
+	})
+	mux.HandleFunc("/auth/v2/auth", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+
+		allowed := r.Form.Get("username") == allowedUser
+		switch r.Form.Get("factor") {
+		case "push":
+			allowed = allowed && r.Form.Get("device") == "auto"
+		case "passcode":
+			allowed = allowed && r.Form.Get("passcode") == passcode
+		default:
+			allowed = false
+		}
+
+		if allowed {
+			writeResult(w, map[string]interface{}{
+				"result":     "allow",
+				"status":     "allow",
+				"status_msg": "Success. Logging you in...",
+			})
+			return
+		}
+		writeResult(w, map[string]interface{}{
+			"result":     "deny",
+			"status":     "deny",
+			"status_msg": "Login request denied.",
+		})
+	})
+
+	return httptest.NewTLSServer(mux)
+}
+
+func TestMFA_Duo(t *testing.T) {
+	server := testDuoServer(t, "test@example.com", "424242")
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	origNewDuoAuthClient := newDuoAuthClient
+	defer func() {
+		newDuoAuthClient = origNewDuoAuthClient
+	}()
+	newDuoAuthClient = func(config *DuoConfig) duo.AuthClient {
+		if config.APIHostname != serverURL.Host {
+			t.Errorf("bad: api hostname: %q", config.APIHostname)
+		}
+		client := duoapi.NewDuoApi(config.IntegrationKey, config.SecretKey, config.APIHostname, "vault-test", duoapi.SetInsecure())
+		return authapi.NewAuthApi(*client)
+	}
+
+	c, _, root := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+	token, _, accessor := testMFASetup(t, c, root, "my_duo")
+
+	resp, err := c.HandleRequest(ctx, &logical.Request{
+		Path:        "sys/mfa/method/duo/my_duo",
+		Operation:   logical.UpdateOperation,
+		ClientToken: root,
+		Data: map[string]interface{}{
+			"mount_accessor":  accessor,
+			"username_format": "{{alias.name}}@example.com",
+			"integration_key": "ikey",
+			"secret_key":      "skey",
+			"api_hostname":    serverURL.Host,
+		},
+	})
+	if err != nil || resp != nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	// A push is sent if no passcode is given
+	if err := testMFAReadSecret(t, c, token, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := testMFAReadSecret(t, c, token, logical.MFACreds{"my_duo": []string{"passcode=424242"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	err = testMFAReadSecret(t, c, token, logical.MFACreds{"my_duo": []string{"passcode=000000"}})
+	if err == nil || !strings.Contains(err.Error(), "Login request denied") {
+		t.Fatalf("expected denial, got: %v", err)
+	}
+
+	// Map the entity to a Duo user that isn't allowed
+	resp, err = c.HandleRequest(ctx, &logical.Request{
+		Path:        "sys/mfa/method/duo/my_duo",
+		Operation:   logical.UpdateOperation,
+		ClientToken: root,
+		Data: map[string]interface{}{
+			"mount_accessor":  accessor,
+			"username_format": "{{alias.name}}@example.org",
+			"integration_key": "ikey",
+			"secret_key":      "skey",
+			"api_hostname":    serverURL.Host,
+		},
+	})
+	if err != nil || resp != nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	err = testMFAReadSecret(t, c, token, nil)
+	if err == nil || !strings.Contains(err.Error(), "Login request denied") {
+		t.Fatalf("expected denial, got: %v", err)
+	}
+}
+
+func TestSystemBackend_MFAMethods(t *testing.T) {
+	c, keys, root := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+
+	req := &logical.Request{
+		Path:        "sys/mfa/method/totp/my_totp",
+		Operation:   logical.UpdateOperation,
+		ClientToken: root,
+		Data: map[string]interface{}{
+			"issuer": "vault",
+			"digits": 7,
+		},
+	}
+	resp, err := c.HandleRequest(ctx, req)
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected invalid digits error, err: %v, resp: %#v", err, resp)
+	}
+
+	req.Data = map[string]interface{}{
+		"issuer":    "vault",
+		"period":    "1m",
+		"algorithm": "SHA256",
+	}
+	resp, err = c.HandleRequest(ctx, req)
+	if err != nil || resp != nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	// Names are unique across method types
+	resp, err = c.HandleRequest(ctx, &logical.Request{
+		Path:        "sys/mfa/method/duo/my_totp",
+		Operation:   logical.UpdateOperation,
+		ClientToken: root,
+		Data: map[string]interface{}{
+			"mount_accessor":  "auth_token_12345",
+			"integration_key": "ikey",
+			"secret_key":      "skey",
+			"api_hostname":    "api.example.com",
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected type mismatch error, err: %v, resp: %#v", err, resp)
+	}
+
+	// The configuration survives a seal/unseal cycle
+	if err := c.Seal(root); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if _, err := TestCoreUnseal(c, TestKeyCopy(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp, err = c.HandleRequest(ctx, &logical.Request{
+		Path:        "sys/mfa/method/totp/my_totp",
+		Operation:   logical.ReadOperation,
+		ClientToken: root,
+	})
+	if err != nil || resp == nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	if resp.Data["period"] != 60 || resp.Data["algorithm"] != "SHA256" || resp.Data["digits"] != 6 || resp.Data["type"] != "totp" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	methodID := resp.Data["id"]
+
+	resp, err = c.HandleRequest(ctx, &logical.Request{
+		Path:        "sys/mfa/method",
+		Operation:   logical.ListOperation,
+		ClientToken: root,
+	})
+	if err != nil || resp == nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != "my_totp" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	info := resp.Data["key_info"].(map[string]interface{})["my_totp"].(map[string]interface{})
+	if info["id"] != methodID || info["type"] != "totp" {
+		t.Fatalf("bad: %#v", info)
+	}
+
+	resp, err = c.HandleRequest(ctx, &logical.Request{
+		Path:        "sys/mfa/method/totp/my_totp",
+		Operation:   logical.DeleteOperation,
+		ClientToken: root,
+	})
+	if err != nil || resp != nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	config, err := c.systemBackend.mfaConfigByName("my_totp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config != nil {
+		t.Fatalf("expected method to be deleted, got: %#v", config)
+	}
+}
@@ -185,7 +185,8 @@ func (c *Core) reloadBackendCommon(ctx context.Context, entry *MountEntry, isAut
 		paths := backend.SpecialPaths()
 		if paths != nil {
 			re.rootPaths.Store(pathsToRadix(paths.Root))
-			re.loginPaths.Store(pathsToRadix(paths.Unauthenticated))
+			re.storeLoginPaths(paths.Unauthenticated)
+			re.sealWrapPaths.Store(sealWrapPathsToRadix(paths.SealWrapStorage))
 		}
 	}
 
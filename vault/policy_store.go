@@ -51,13 +51,10 @@ path "sys/wrapping/unwrap" {
     capabilities = ["update"]
 }
 `
-	// controlGroupPolicy is the policy that ensures control group requests can
-	// commit themselves
+	// controlGroupPolicy is the policy that ensures control group tokens can
+	// be unwrapped. The held request is only accessed by the system backend so
+	// that requesters cannot tamper with its authorizations.
 	controlGroupPolicy = `
-path "cubbyhole/control-group" {
-    capabilities = ["update", "create", "read"]
-}
-
 path "sys/wrapping/unwrap" {
     capabilities = ["update"]
 }
@@ -0,0 +1,374 @@
+package vault
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	proto "github.com/golang/protobuf/proto"
+	"github.com/hashicorp/errwrap"
+	cleanhttp "github.com/hashicorp/go-cleanhttp"
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/physical"
+	"github.com/hashicorp/vault/physical/raft"
+	"github.com/hashicorp/vault/vault/seal"
+)
+
+const (
+	// raftBootstrapTimeout is how long to wait for a freshly bootstrapped
+	// raft cluster to elect the local node
+	raftBootstrapTimeout = 30 * time.Second
+
+	// raftJoinReplicationTimeout is how long a joining node waits for the
+	// barrier keyring to be replicated to it
+	raftJoinReplicationTimeout = 60 * time.Second
+
+	// raftChallengeTTL is how long a bootstrap challenge remains valid
+	raftChallengeTTL = 5 * time.Minute
+
+	// raftMaxPendingChallenges is how many bootstrap challenges can be
+	// pending at once. Challenges are requested without authentication, so
+	// this bounds the memory held for them.
+	raftMaxPendingChallenges = 100
+)
+
+// errTooManyRaftChallenges is returned when a bootstrap challenge is requested
+// while too many others are pending
+var errTooManyRaftChallenges = errors.New("too many pending raft bootstrap challenges, retry later")
+
+// raftInformation holds the state of a raft join that is waiting for the
+// node to be unsealed before the leader's challenge can be answered.
+type raftInformation struct {
+	challenge           []byte
+	leaderClient        *api.Client
+	leaderBarrierConfig *SealConfig
+}
+
+// raftChallenge is an outstanding bootstrap challenge issued by the leader
+type raftChallenge struct {
+	answer  []byte
+	expires time.Time
+}
+
+// raftPendingPeers tracks the bootstrap challenges issued to nodes that want
+// to join the cluster
+type raftPendingPeers struct {
+	l     sync.Mutex
+	peers map[string]*raftChallenge
+}
+
+// raftStorage returns the raft backend if raft is the configured storage
+func (c *Core) raftStorage() (*raft.RaftBackend, bool) {
+	raftBackend, ok := c.underlyingPhysical.(*raft.RaftBackend)
+	return raftBackend, ok
+}
+
+// isRaftUnseal returns whether a raft join is waiting for unseal keys. The
+// caller must hold the state lock.
+func (c *Core) isRaftUnseal() bool {
+	return c.raftInfo != nil
+}
+
+// raftInitialize bootstraps a new raft cluster with this node as its only
+// member, if raft storage is in use and has no state yet.
+func (c *Core) raftInitialize(ctx context.Context) error {
+	raftBackend, ok := c.raftStorage()
+	if !ok {
+		return nil
+	}
+	if c.raftInfo != nil {
+		return errors.New("cannot initialize a node that is joining a raft cluster")
+	}
+	if raftBackend.Initialized() {
+		return nil
+	}
+
+	c.logger.Info("bootstrapping raft cluster", "node_id", raftBackend.NodeID())
+
+	bootstrapCtx, cancel := context.WithTimeout(ctx, raftBootstrapTimeout)
+	defer cancel()
+	return raftBackend.Bootstrap(bootstrapCtx)
+}
+
+// JoinRaftCluster starts the process of adding this node to the raft cluster
+// led by the node at leaderAddr. With Shamir seals the join completes when
+// the node is unsealed; with auto seals it completes before returning.
+func (c *Core) JoinRaftCluster(ctx context.Context, leaderAddr string, tlsConfig *tls.Config) (bool, error) {
+	raftBackend, ok := c.raftStorage()
+	if !ok {
+		return false, errors.New("raft storage is not in use")
+	}
+	if raftBackend.Initialized() {
+		return false, errors.New("join can't be invoked on a node that is already part of a raft cluster")
+	}
+
+	init, err := c.Initialized(ctx)
+	if err != nil {
+		return false, errwrap.Wrapf("failed to check if core is initialized: {{err}}", err)
+	}
+	if init {
+		return false, errors.New("join can't be invoked on an initialized cluster")
+	}
+
+	if leaderAddr == "" {
+		return false, errors.New("leader API address is required")
+	}
+
+	transport := cleanhttp.DefaultPooledTransport()
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig.Clone()
+	}
+	config := api.DefaultConfig()
+	if config.Error != nil {
+		return false, config.Error
+	}
+	config.Address = leaderAddr
+	config.HttpClient = &http.Client{
+		Transport: transport,
+	}
+	config.MaxRetries = 0
+	client, err := api.NewClient(config)
+	if err != nil {
+		return false, errwrap.Wrapf("failed to create API client for the leader: {{err}}", err)
+	}
+	// No token is needed; the bootstrap endpoints are unauthenticated
+	client.ClearToken()
+
+	secret, err := client.Logical().Write("sys/storage/raft/bootstrap/challenge", map[string]interface{}{
+		"server_id": raftBackend.NodeID(),
+	})
+	if err != nil {
+		return false, errwrap.Wrapf("error during raft bootstrap init call: {{err}}", err)
+	}
+	if secret == nil || secret.Data == nil {
+		return false, errors.New("empty response from leader for raft bootstrap challenge")
+	}
+
+	challengeB64, ok := secret.Data["challenge"].(string)
+	if !ok || challengeB64 == "" {
+		return false, errors.New("no challenge returned by the leader")
+	}
+	challenge, err := base64.StdEncoding.DecodeString(challengeB64)
+	if err != nil {
+		return false, errwrap.Wrapf("error decoding raft bootstrap challenge: {{err}}", err)
+	}
+
+	sealConfigRaw, ok := secret.Data["seal_config"]
+	if !ok || sealConfigRaw == nil {
+		return false, errors.New("no seal config returned by the leader")
+	}
+	sealConfigJSON, err := jsonutil.EncodeJSON(sealConfigRaw)
+	if err != nil {
+		return false, err
+	}
+	var sealConfig SealConfig
+	if err := jsonutil.DecodeJSON(sealConfigJSON, &sealConfig); err != nil {
+		return false, errwrap.Wrapf("error decoding leader seal config: {{err}}", err)
+	}
+	if sealConfig.Type != c.seal.BarrierType() {
+		return false, fmt.Errorf("mismatching seal types between leader (%s) and follower (%s)", sealConfig.Type, c.seal.BarrierType())
+	}
+
+	c.stateLock.Lock()
+	c.raftInfo = &raftInformation{
+		challenge:           challenge,
+		leaderClient:        client,
+		leaderBarrierConfig: &sealConfig,
+	}
+
+	// Shamir-sealed nodes need the unseal keys to answer the challenge, so
+	// the join is completed during unseal
+	if sealConfig.Type == seal.Shamir {
+		c.stateLock.Unlock()
+		return true, nil
+	}
+
+	err = c.joinRaftSendAnswer(ctx, nil)
+	c.stateLock.Unlock()
+	if err != nil {
+		return false, err
+	}
+
+	// The stored keys have been replicated, so auto seals can unseal now
+	if err := c.UnsealWithStoredKeys(ctx); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// joinRaftSendAnswer decrypts the leader's challenge, sends the answer back
+// to the leader and waits for the cluster data to be replicated. The caller
+// must hold the state lock.
+func (c *Core) joinRaftSendAnswer(ctx context.Context, masterKey []byte) error {
+	raftBackend, ok := c.raftStorage()
+	if !ok {
+		return errors.New("raft storage is not in use")
+	}
+	if c.raftInfo == nil {
+		return errors.New("no raft join is in progress")
+	}
+
+	answer, err := c.raftChallengeDecrypt(ctx, c.raftInfo.challenge, masterKey)
+	if err != nil {
+		return errwrap.Wrapf("error decrypting raft bootstrap challenge: {{err}}", err)
+	}
+
+	// Start raft so that the leader can replicate to this node as soon as
+	// it has been added
+	if err := raftBackend.SetupCluster(ctx); err != nil {
+		return errwrap.Wrapf("failed to set up raft: {{err}}", err)
+	}
+
+	_, err = c.raftInfo.leaderClient.Logical().Write("sys/storage/raft/bootstrap/answer", map[string]interface{}{
+		"server_id":    raftBackend.NodeID(),
+		"answer":       base64.StdEncoding.EncodeToString(answer),
+		"cluster_addr": raftBackend.Addr(),
+	})
+	if err != nil {
+		return errwrap.Wrapf("error answering raft bootstrap challenge: {{err}}", err)
+	}
+
+	// Wait for the keyring to arrive; once it has, the node has enough of the
+	// cluster state to unseal
+	deadline := time.Now().Add(raftJoinReplicationTimeout)
+	for {
+		entry, err := raftBackend.Get(ctx, keyringPath)
+		if err != nil {
+			return err
+		}
+		if entry != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return errors.New("timed out waiting for raft data to be replicated")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	c.raftInfo = nil
+	c.logger.Info("joined raft cluster", "node_id", raftBackend.NodeID())
+
+	return nil
+}
+
+// raftCreateChallenge generates a challenge for a node that wants to join the
+// cluster. Only a node that can unseal, and thus holds the master key or
+// access to the same auto seal, can decrypt it.
+func (c *Core) raftCreateChallenge(ctx context.Context, serverID string) ([]byte, error) {
+	answerRaw, err := uuid.GenerateRandomBytes(16)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := c.raftChallengeEncrypt(ctx, answerRaw)
+	if err != nil {
+		return nil, err
+	}
+
+	c.raftPendingPeers.l.Lock()
+	defer c.raftPendingPeers.l.Unlock()
+	if c.raftPendingPeers.peers == nil {
+		c.raftPendingPeers.peers = make(map[string]*raftChallenge)
+	}
+	now := time.Now()
+	for id, pending := range c.raftPendingPeers.peers {
+		if now.After(pending.expires) {
+			delete(c.raftPendingPeers.peers, id)
+		}
+	}
+	if _, ok := c.raftPendingPeers.peers[serverID]; !ok && len(c.raftPendingPeers.peers) >= raftMaxPendingChallenges {
+		return nil, errTooManyRaftChallenges
+	}
+	c.raftPendingPeers.peers[serverID] = &raftChallenge{
+		answer:  answerRaw,
+		expires: now.Add(raftChallengeTTL),
+	}
+
+	return challenge, nil
+}
+
+// raftVerifyAnswer checks the answer to a previously issued challenge. A
+// challenge can only be answered once.
+func (c *Core) raftVerifyAnswer(serverID string, answer []byte) error {
+	c.raftPendingPeers.l.Lock()
+	defer c.raftPendingPeers.l.Unlock()
+
+	pending, ok := c.raftPendingPeers.peers[serverID]
+	if !ok {
+		return errors.New("no expected answer for the server id provided")
+	}
+	delete(c.raftPendingPeers.peers, serverID)
+
+	if time.Now().After(pending.expires) {
+		return errors.New("challenge has expired")
+	}
+	if subtle.ConstantTimeCompare(pending.answer, answer) != 1 {
+		return errors.New("invalid answer given")
+	}
+
+	return nil
+}
+
+// raftChallengeEncrypt encrypts a challenge either with the master key, for
+// Shamir seals, or through the auto seal.
+func (c *Core) raftChallengeEncrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	if autoSeal, ok := c.seal.(*autoSeal); ok {
+		blobInfo, err := autoSeal.Encrypt(ctx, plaintext)
+		if err != nil {
+			return nil, err
+		}
+		return proto.Marshal(blobInfo)
+	}
+
+	barrier, ok := c.barrier.(*AESGCMBarrier)
+	if !ok {
+		return nil, errors.New("unsupported barrier type for raft challenge")
+	}
+	keyring, err := barrier.Keyring()
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := barrier.aeadFromKey(keyring.MasterKey())
+	if err != nil {
+		return nil, err
+	}
+	return barrier.encrypt("", 0, gcm, plaintext)
+}
+
+// raftChallengeDecrypt reverses raftChallengeEncrypt on the joining node
+func (c *Core) raftChallengeDecrypt(ctx context.Context, ciphertext, masterKey []byte) ([]byte, error) {
+	if autoSeal, ok := c.seal.(*autoSeal); ok {
+		blobInfo := &physical.EncryptedBlobInfo{}
+		if err := proto.Unmarshal(ciphertext, blobInfo); err != nil {
+			return nil, err
+		}
+		return autoSeal.Decrypt(ctx, blobInfo)
+	}
+
+	if masterKey == nil {
+		return nil, errors.New("master key is required to decrypt the challenge")
+	}
+	barrier, ok := c.barrier.(*AESGCMBarrier)
+	if !ok {
+		return nil, errors.New("unsupported barrier type for raft challenge")
+	}
+	gcm, err := barrier.aeadFromKey(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return barrier.decrypt("", gcm, ciphertext)
+}
@@ -0,0 +1,263 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	radix "github.com/armon/go-radix"
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/logical"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// rateLimitQuotaSubPath is the sub-path of the system view in which the
+	// rate limit quotas are stored, indexed by name
+	rateLimitQuotaSubPath = "quotas/rate-limit/"
+
+	// rateLimitKeyClientIP keys the rate limiters of a quota by the address
+	// of the client
+	rateLimitKeyClientIP = "client_ip"
+
+	// rateLimitKeyTokenAccessor keys the rate limiters of a quota by the
+	// accessor of the client token, falling back to the address of the
+	// client for requests without a token
+	rateLimitKeyTokenAccessor = "token_accessor"
+
+	// rateLimitPurgeInterval is how often the idle rate limiters of a quota
+	// are removed
+	rateLimitPurgeInterval = time.Minute
+)
+
+// rateLimitExemptPaths are never rate limited, so that quotas can always be
+// fixed
+var rateLimitExemptPaths = []string{
+	"sys/quotas/",
+}
+
+// RateLimitQuota limits the rate of the requests under a path with a token
+// bucket per client. The path is relative to the root namespace and may be a
+// namespace, a mount or any path prefix within a mount; an empty path applies
+// the quota to all the requests. Only the quota with the longest matching
+// path applies to a request.
+type RateLimitQuota struct {
+	Name    string  `json:"name"`
+	Path    string  `json:"path"`
+	Rate    float64 `json:"rate"`
+	Burst   int     `json:"burst"`
+	KeyType string  `json:"key_type"`
+}
+
+type rateLimiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimitQuotaState holds the rate limiters of a quota, one per client
+type rateLimitQuotaState struct {
+	quota     *RateLimitQuota
+	limiters  map[string]*rateLimiterEntry
+	lastPurge time.Time
+}
+
+// rateLimitQuotaManager enforces the rate limit quotas. Requests received by
+// standbys are forwarded to the active node, so the quotas are only enforced
+// there.
+type rateLimitQuotaManager struct {
+	l sync.Mutex
+
+	// quotas holds the state of each quota, indexed by path
+	quotas *radix.Tree
+}
+
+func newRateLimitQuotaManager() *rateLimitQuotaManager {
+	return &rateLimitQuotaManager{
+		quotas: radix.New(),
+	}
+}
+
+// setQuota adds or replaces a quota, resetting its rate limiters
+func (m *rateLimitQuotaManager) setQuota(quota *RateLimitQuota) {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	m.deleteQuotaLocked(quota.Name)
+	m.quotas.Insert(quota.Path, &rateLimitQuotaState{
+		quota:     quota,
+		limiters:  make(map[string]*rateLimiterEntry),
+		lastPurge: time.Now(),
+	})
+}
+
+func (m *rateLimitQuotaManager) deleteQuota(name string) {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	m.deleteQuotaLocked(name)
+}
+
+func (m *rateLimitQuotaManager) deleteQuotaLocked(name string) {
+	var path string
+	var found bool
+	m.quotas.Walk(func(p string, v interface{}) bool {
+		if v.(*rateLimitQuotaState).quota.Name == name {
+			path, found = p, true
+			return true
+		}
+		return false
+	})
+	if found {
+		m.quotas.Delete(path)
+	}
+}
+
+// quotaForPath returns the quota with the longest path matching the given
+// path, if any. Quota paths end with a slash, so the slash is added to the
+// path for a quota on a mount to apply to the request for the mount itself.
+func (m *rateLimitQuotaManager) quotaForPath(path string) *RateLimitQuota {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	_, raw, ok := m.quotas.LongestPrefix(path + "/")
+	if !ok {
+		return nil
+	}
+	return raw.(*rateLimitQuotaState).quota
+}
+
+// allow takes a token from the bucket of a client, returning a
+// RateLimitQuotaError if the bucket is empty
+func (m *rateLimitQuotaManager) allow(quota *RateLimitQuota, key string) error {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	raw, ok := m.quotas.Get(quota.Path)
+	if !ok || raw.(*rateLimitQuotaState).quota != quota {
+		// The quota was replaced in the meantime
+		return nil
+	}
+	state := raw.(*rateLimitQuotaState)
+
+	now := time.Now()
+	if now.Sub(state.lastPurge) >= rateLimitPurgeInterval {
+		state.purge(now)
+	}
+
+	entry, ok := state.limiters[key]
+	if !ok {
+		entry = &rateLimiterEntry{
+			limiter: rate.NewLimiter(rate.Limit(quota.Rate), quota.Burst),
+		}
+		state.limiters[key] = entry
+	}
+	entry.lastSeen = now
+
+	reservation := entry.limiter.ReserveN(now, 1)
+	delay := reservation.DelayFrom(now)
+	if delay == 0 {
+		return nil
+	}
+	reservation.CancelAt(now)
+
+	return &logical.RateLimitQuotaError{
+		Quota:      quota.Name,
+		RetryAfter: delay,
+	}
+}
+
+// purge removes the rate limiters that have been idle long enough for their
+// bucket to be full again, as they are no different from new ones
+func (s *rateLimitQuotaState) purge(now time.Time) {
+	refill := time.Duration(float64(s.quota.Burst) / s.quota.Rate * float64(time.Second))
+	for key, entry := range s.limiters {
+		if now.Sub(entry.lastSeen) >= refill {
+			delete(s.limiters, key)
+		}
+	}
+	s.lastPurge = now
+}
+
+// setupRateLimitQuotas loads the rate limit quotas
+func (c *Core) setupRateLimitQuotas(ctx context.Context) error {
+	manager := newRateLimitQuotaManager()
+
+	view := c.systemBarrierView.SubView(rateLimitQuotaSubPath)
+	names, err := view.List(ctx, "")
+	if err != nil {
+		return errwrap.Wrapf("failed to list rate limit quotas: {{err}}", err)
+	}
+	for _, name := range names {
+		quota, err := getRateLimitQuota(ctx, view, name)
+		if err != nil {
+			return err
+		}
+		if quota != nil {
+			manager.setQuota(quota)
+		}
+	}
+
+	c.rateLimitQuotas = manager
+	return nil
+}
+
+// teardownRateLimitQuotas stops enforcing the rate limit quotas
+func (c *Core) teardownRateLimitQuotas() {
+	c.rateLimitQuotas = nil
+}
+
+// checkRateLimitQuotas applies the rate limit quota matching the path of a
+// request, if any
+func (c *Core) checkRateLimitQuotas(ctx context.Context, ns *namespace.Namespace, req *logical.Request) error {
+	if c.rateLimitQuotas == nil {
+		return nil
+	}
+	for _, exempt := range rateLimitExemptPaths {
+		if ns.ID == namespace.RootNamespaceID && strings.HasPrefix(req.Path, exempt) {
+			return nil
+		}
+	}
+
+	quota := c.rateLimitQuotas.quotaForPath(ns.Path + req.Path)
+	if quota == nil {
+		return nil
+	}
+
+	var key string
+	if req.Connection != nil {
+		key = req.Connection.RemoteAddr
+	}
+	if quota.KeyType == rateLimitKeyTokenAccessor && req.ClientToken != "" {
+		te, err := c.tokenStore.Lookup(ctx, req.ClientToken)
+		if err == nil && te != nil && te.Accessor != "" {
+			key = "accessor:" + te.Accessor
+		}
+	}
+
+	err := c.rateLimitQuotas.allow(quota, key)
+	if err != nil {
+		metrics.IncrCounterWithLabels([]string{"quota", "rate_limit", "violation"}, 1, []metrics.Label{{Name: "name", Value: quota.Name}})
+	}
+	return err
+}
+
+func getRateLimitQuota(ctx context.Context, view logical.Storage, name string) (*RateLimitQuota, error) {
+	entry, err := view.Get(ctx, name)
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("failed to read rate limit quota %q: {{err}}", name), err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var quota RateLimitQuota
+	if err := jsonutil.DecodeJSON(entry.Value, &quota); err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("failed to decode rate limit quota %q: {{err}}", name), err)
+	}
+	return &quota, nil
+}
@@ -0,0 +1,180 @@
+package vault
+
+import (
+	"testing"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestRateLimitQuotaManager(t *testing.T) {
+	m := newRateLimitQuotaManager()
+	m.setQuota(&RateLimitQuota{Name: "all", Path: "", Rate: 100, Burst: 100})
+	m.setQuota(&RateLimitQuota{Name: "kv", Path: "secret/", Rate: 0.5, Burst: 2})
+
+	// The most specific quota applies
+	if quota := m.quotaForPath("secret/foo"); quota == nil || quota.Name != "kv" {
+		t.Fatalf("bad quota: %#v", quota)
+	}
+	if quota := m.quotaForPath("sys/mounts"); quota == nil || quota.Name != "all" {
+		t.Fatalf("bad quota: %#v", quota)
+	}
+
+	quota := m.quotaForPath("secret/foo")
+	for i := 0; i < 2; i++ {
+		if err := m.allow(quota, "127.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := m.allow(quota, "127.0.0.1")
+	quotaErr, ok := errwrap.GetType(err, &logical.RateLimitQuotaError{}).(*logical.RateLimitQuotaError)
+	if !ok {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+	if quotaErr.Quota != "kv" || quotaErr.RetryAfter <= 0 {
+		t.Fatalf("bad error: %#v", quotaErr)
+	}
+
+	// Each client has its own bucket
+	if err := m.allow(quota, "127.0.0.2"); err != nil {
+		t.Fatal(err)
+	}
+
+	m.deleteQuota("kv")
+	if quota := m.quotaForPath("secret/foo"); quota == nil || quota.Name != "all" {
+		t.Fatalf("bad quota: %#v", quota)
+	}
+}
+
+func TestSystemBackend_RateLimitQuota(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/quotas/rate-limit/all")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{
+		"rate":  "0.1",
+		"burst": 2,
+	}
+	resp, err := c.HandleRequest(ctx, req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	// Another quota cannot apply to the same path
+	req = logical.TestRequest(t, logical.UpdateOperation, "sys/quotas/rate-limit/dup")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{
+		"rate": "10",
+	}
+	resp, err = c.HandleRequest(ctx, req)
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got err: %v, resp: %#v", err, resp)
+	}
+
+	lookupSelf := func() (*logical.Response, error) {
+		req := logical.TestRequest(t, logical.ReadOperation, "auth/token/lookup-self")
+		req.ClientToken = root
+		req.Connection = &logical.Connection{RemoteAddr: "127.0.0.1"}
+		return c.HandleRequest(ctx, req)
+	}
+	for i := 0; i < 2; i++ {
+		if resp, err := lookupSelf(); err != nil || resp.IsError() {
+			t.Fatalf("err: %v, resp: %#v", err, resp)
+		}
+	}
+	_, err = lookupSelf()
+	if _, ok := errwrap.GetType(err, &logical.RateLimitQuotaError{}).(*logical.RateLimitQuotaError); !ok {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+
+	// The quota endpoints are never limited
+	req = logical.TestRequest(t, logical.ReadOperation, "sys/quotas/rate-limit/all")
+	req.ClientToken = root
+	req.Connection = &logical.Connection{RemoteAddr: "127.0.0.1"}
+	resp, err = c.HandleRequest(ctx, req)
+	if err != nil || resp == nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	if resp.Data["rate"].(float64) != 0.1 || resp.Data["burst"].(int) != 2 || resp.Data["key_type"].(string) != "client_ip" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	req = logical.TestRequest(t, logical.ListOperation, "sys/quotas/rate-limit")
+	req.ClientToken = root
+	resp, err = c.HandleRequest(ctx, req)
+	if err != nil || resp == nil {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != "all" {
+		t.Fatalf("bad keys: %v", keys)
+	}
+
+	req = logical.TestRequest(t, logical.DeleteOperation, "sys/quotas/rate-limit/all")
+	req.ClientToken = root
+	if resp, err := c.HandleRequest(ctx, req); err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	if resp, err := lookupSelf(); err != nil || resp.IsError() {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+}
+
+func TestSystemBackend_RateLimitQuota_SiblingMount(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/mounts/secret2")
+	req.Data["type"] = "kv"
+	req.ClientToken = root
+	if resp, err := c.HandleRequest(ctx, req); err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	req = logical.TestRequest(t, logical.UpdateOperation, "sys/quotas/rate-limit/kv")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{
+		"path":  "secret",
+		"rate":  "0.1",
+		"burst": 1,
+	}
+	if resp, err := c.HandleRequest(ctx, req); err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	// The path is normalized, so the same mount cannot get another quota
+	req = logical.TestRequest(t, logical.UpdateOperation, "sys/quotas/rate-limit/dup")
+	req.ClientToken = root
+	req.Data = map[string]interface{}{
+		"path": "secret/",
+		"rate": "10",
+	}
+	resp, err := c.HandleRequest(ctx, req)
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got err: %v, resp: %#v", err, resp)
+	}
+
+	read := func(path string) error {
+		req := logical.TestRequest(t, logical.ReadOperation, path)
+		req.ClientToken = root
+		req.Connection = &logical.Connection{RemoteAddr: "127.0.0.1"}
+		_, err := c.HandleRequest(ctx, req)
+		return err
+	}
+
+	// The quota does not apply to a mount sharing its prefix
+	for i := 0; i < 3; i++ {
+		if err := read("secret2/foo"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := read("secret/foo"); err != nil {
+		t.Fatal(err)
+	}
+	err = read("secret/foo")
+	if _, ok := errwrap.GetType(err, &logical.RateLimitQuotaError{}).(*logical.RateLimitQuotaError); !ok {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+}
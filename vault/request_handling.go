@@ -370,6 +370,7 @@ func (c *Core) HandleRequest(httpCtx context.Context, req *logical.Request) (res
 		return nil, errwrap.Wrapf("could not parse namespace from http context: {{err}}", err)
 	}
 	ctx = namespace.ContextWithNamespace(ctx, ns)
+	ctx = contextWithAuditRouting(ctx)
 
 	resp, err = c.handleCancelableRequest(ctx, ns, req)
 
@@ -401,6 +402,13 @@ func (c *Core) handleCancelableRequest(ctx context.Context, ns *namespace.Namesp
 		return nil, logical.CodedError(403, "namespaces feature not enabled")
 	}
 
+	if err := c.checkRateLimitQuotas(ctx, ns, req); err != nil {
+		return nil, err
+	}
+	if err := c.checkLeaseCountQuotas(ctx, ns, req); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrLeaseCountQuotaExceeded
+	}
+
 	var auth *logical.Auth
 	if c.router.LoginPath(ctx, req.Path) {
 		resp, auth, err = c.handleLoginRequest(ctx, req)
@@ -577,7 +585,7 @@ func (c *Core) handleRequest(ctx context.Context, req *logical.Request) (retResp
 		newCtErr, cgResp, cgAuth, cgRetErr := checkNeedsCG(ctx, c, req, auth, ctErr, nonHMACReqDataKeys)
 		switch {
 		case newCtErr != nil:
-			ctErr = err
+			ctErr = newCtErr
 		case cgResp != nil || cgAuth != nil:
 			if cgRetErr != nil {
 				retErr = multierror.Append(retErr, cgRetErr)
@@ -754,6 +762,9 @@ func (c *Core) handleRequest(ctx context.Context, req *logical.Request) (retResp
 			}
 
 			leaseID, err := registerFunc(ctx, req, resp)
+			if errwrap.Contains(err, logical.ErrLeaseCountQuotaExceeded.Error()) {
+				return logical.ErrorResponse(err.Error()), auth, logical.ErrLeaseCountQuotaExceeded
+			}
 			if err != nil {
 				c.logger.Error("failed to register lease", "request_path", req.Path, "error", err)
 				retErr = multierror.Append(retErr, ErrInternalError)
@@ -813,6 +824,10 @@ func (c *Core) handleRequest(ctx context.Context, req *logical.Request) (retResp
 				Path:        resp.Auth.CreationPath,
 				NamespaceID: ns.ID,
 			}, resp.Auth); err != nil {
+				if errwrap.Contains(err, logical.ErrLeaseCountQuotaExceeded.Error()) {
+					c.tokenStore.revokeOrphan(ctx, resp.Auth.ClientToken)
+					return logical.ErrorResponse(err.Error()), auth, logical.ErrLeaseCountQuotaExceeded
+				}
 				c.tokenStore.revokeOrphan(ctx, te.ID)
 				c.logger.Error("failed to register token lease", "request_path", req.Path, "error", err)
 				retErr = multierror.Append(retErr, ErrInternalError)
@@ -1082,6 +1097,8 @@ func (c *Core) handleLoginRequest(ctx context.Context, req *logical.Request) (re
 		case err == nil:
 		case err == ErrInternalError:
 			return nil, auth, err
+		case errwrap.Contains(err, logical.ErrLeaseCountQuotaExceeded.Error()):
+			return logical.ErrorResponse(err.Error()), auth, logical.ErrLeaseCountQuotaExceeded
 		default:
 			return logical.ErrorResponse(err.Error()), auth, logical.ErrInvalidRequest
 		}
@@ -1144,6 +1161,9 @@ func (c *Core) RegisterAuth(ctx context.Context, tokenTTL time.Duration, path st
 		// Register with the expiration manager
 		if err := c.expiration.RegisterAuth(ctx, &te, auth); err != nil {
 			c.tokenStore.revokeOrphan(ctx, te.ID)
+			if errwrap.Contains(err, logical.ErrLeaseCountQuotaExceeded.Error()) {
+				return err
+			}
 			c.logger.Error("failed to register token lease", "request_path", path, "error", err)
 			return ErrInternalError
 		}
@@ -11,8 +11,8 @@ import (
 
 func waitForReplicationState(context.Context, *Core, *logical.Request) error { return nil }
 
-func checkNeedsCG(context.Context, *Core, *logical.Request, *logical.Auth, error, []string) (error, *logical.Response, *logical.Auth, error) {
-	return nil, nil, nil, nil
+func checkNeedsCG(ctx context.Context, c *Core, req *logical.Request, auth *logical.Auth, ctErr error, nonHMACReqDataKeys []string) (error, *logical.Response, *logical.Auth, error) {
+	return c.checkNeedsControlGroup(ctx, req, auth, ctErr, nonHMACReqDataKeys)
 }
 
 func possiblyForward(ctx context.Context, c *Core, req *logical.Request, resp *logical.Response, routeErr error) (*logical.Response, error) {
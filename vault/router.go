@@ -35,6 +35,13 @@ type Router struct {
 	// to the backend. This is used to map a key back into the backend that owns it.
 	// For example, logical/uuid1/foobar -> secrets/ (kv backend) + foobar
 	storagePrefix *radix.Tree
+
+	// sealWrapStorage maps the prefix used for storage to the backend, like
+	// storagePrefix. It has its own lock as it is used by the physical layer
+	// to find out whether entries must be seal wrapped, which may happen
+	// while l is held.
+	sealWrapStorage *radix.Tree
+	sealWrapLock    sync.RWMutex
 }
 
 // NewRouter returns a new router
@@ -42,6 +49,7 @@ func NewRouter() *Router {
 	r := &Router{
 		root:               radix.New(),
 		storagePrefix:      radix.New(),
+		sealWrapStorage:    radix.New(),
 		mountUUIDCache:     radix.New(),
 		mountAccessorCache: radix.New(),
 	}
@@ -57,7 +65,74 @@ type routeEntry struct {
 	storagePrefix string
 	rootPaths     atomic.Value
 	loginPaths    atomic.Value
+	sealWrapPaths atomic.Value
 	l             sync.RWMutex
+
+	// loginPathsWildcards holds the unauthenticated paths that contain "+"
+	// wildcard segments, which cannot be matched using the radix tree
+	loginPathsWildcards atomic.Value
+}
+
+// wildcardPath is a special path in which "+" segments match any single
+// segment of the request path
+type wildcardPath struct {
+	segments    []string
+	prefixMatch bool
+}
+
+func newWildcardPath(path string) wildcardPath {
+	prefixMatch := strings.HasSuffix(path, "*")
+	return wildcardPath{
+		segments:    strings.Split(strings.TrimSuffix(path, "*"), "/"),
+		prefixMatch: prefixMatch,
+	}
+}
+
+// matches returns whether the path matches the wildcard path. With a prefix
+// match, the last segment of the wildcard path only needs to be a prefix of
+// the corresponding segment of the path, and any segments after it match.
+func (w wildcardPath) matches(path string) bool {
+	segments := strings.Split(path, "/")
+	switch {
+	case w.prefixMatch && len(segments) < len(w.segments):
+		return false
+	case !w.prefixMatch && len(segments) != len(w.segments):
+		return false
+	}
+
+	last := len(w.segments) - 1
+	for i, segment := range w.segments {
+		switch {
+		case segment == "+":
+			if segments[i] == "" {
+				return false
+			}
+		case i == last && w.prefixMatch:
+			if !strings.HasPrefix(segments[i], segment) {
+				return false
+			}
+		case segment != segments[i]:
+			return false
+		}
+	}
+
+	return true
+}
+
+// storeLoginPaths sets the unauthenticated paths of the route entry
+func (re *routeEntry) storeLoginPaths(paths []string) {
+	var radixPaths []string
+	var wildcards []wildcardPath
+	for _, path := range paths {
+		if strings.Contains(path, "+") {
+			wildcards = append(wildcards, newWildcardPath(path))
+			continue
+		}
+		radixPaths = append(radixPaths, path)
+	}
+
+	re.loginPaths.Store(pathsToRadix(radixPaths))
+	re.loginPathsWildcards.Store(wildcards)
 }
 
 type validateMountResponse struct {
@@ -129,7 +204,8 @@ func (r *Router) Mount(backend logical.Backend, prefix string, mountEntry *Mount
 		storageView:   storageView,
 	}
 	re.rootPaths.Store(pathsToRadix(paths.Root))
-	re.loginPaths.Store(pathsToRadix(paths.Unauthenticated))
+	re.storeLoginPaths(paths.Unauthenticated)
+	re.sealWrapPaths.Store(sealWrapPathsToRadix(paths.SealWrapStorage))
 
 	switch {
 	case prefix == "":
@@ -147,6 +223,10 @@ func (r *Router) Mount(backend logical.Backend, prefix string, mountEntry *Mount
 	r.mountUUIDCache.Insert(re.mountEntry.UUID, re.mountEntry)
 	r.mountAccessorCache.Insert(re.mountEntry.Accessor, re.mountEntry)
 
+	r.sealWrapLock.Lock()
+	r.sealWrapStorage.Insert(re.storagePrefix, re)
+	r.sealWrapLock.Unlock()
+
 	return nil
 }
 
@@ -179,6 +259,10 @@ func (r *Router) Unmount(ctx context.Context, prefix string) error {
 	r.mountUUIDCache.Delete(re.mountEntry.UUID)
 	r.mountAccessorCache.Delete(re.mountEntry.Accessor)
 
+	r.sealWrapLock.Lock()
+	r.sealWrapStorage.Delete(re.storagePrefix)
+	r.sealWrapLock.Unlock()
+
 	return nil
 }
 
@@ -601,6 +685,16 @@ func (r *Router) routeCommon(ctx context.Context, req *logical.Request, existenc
 	}
 	req.Headers = filteredPassthroughHeaders(headers, passthroughRequestHeaders)
 
+	// OIDC clients authenticate to the provider endpoints of the identity
+	// store with the Authorization header
+	if IsOIDCProviderClientPath(originalPath) {
+		for key, value := range headers {
+			if strings.EqualFold(key, "Authorization") {
+				req.Headers["Authorization"] = value
+			}
+		}
+	}
+
 	// Cache the wrap info of the request
 	var wrapInfo *logical.RequestWrapInfo
 	if req.WrapInfo != nil {
@@ -756,18 +850,28 @@ func (r *Router) LoginPath(ctx context.Context, path string) bool {
 	// Check the loginPaths of this backend
 	loginPaths := re.loginPaths.Load().(*radix.Tree)
 	match, raw, ok := loginPaths.LongestPrefix(remain)
-	if !ok {
-		return false
+	if ok {
+		prefixMatch := raw.(bool)
+
+		// Handle the prefix match case
+		if prefixMatch && strings.HasPrefix(remain, match) {
+			return true
+		}
+
+		// Handle the exact match case
+		if match == remain {
+			return true
+		}
 	}
-	prefixMatch := raw.(bool)
 
-	// Handle the prefix match case
-	if prefixMatch {
-		return strings.HasPrefix(remain, match)
+	// Check the paths with wildcard segments
+	for _, wildcard := range re.loginPathsWildcards.Load().([]wildcardPath) {
+		if wildcard.matches(remain) {
+			return true
+		}
 	}
 
-	// Handle the exact match case
-	return match == remain
+	return false
 }
 
 // pathsToRadix converts a the mapping of special paths to a mapping
@@ -787,6 +891,44 @@ func pathsToRadix(paths []string) *radix.Tree {
 	return tree
 }
 
+// sealWrapPathsToRadix converts the seal wrapped storage paths of a backend
+// to a radix tree. Paths ending in "/" or "*" are prefixes, other paths are
+// exact matches.
+func sealWrapPathsToRadix(paths []string) *radix.Tree {
+	tree := radix.New()
+	for _, path := range paths {
+		prefixMatch := strings.HasSuffix(path, "/") || strings.HasSuffix(path, "*")
+		tree.Insert(strings.TrimSuffix(path, "*"), prefixMatch)
+	}
+
+	return tree
+}
+
+// SealWrapStoragePath returns whether the entry stored at the given storage
+// path must be seal wrapped, either because its mount is seal wrapped or
+// because the backend lists the path in its SealWrapStorage paths.
+func (r *Router) SealWrapStoragePath(path string) bool {
+	r.sealWrapLock.RLock()
+	prefix, raw, ok := r.sealWrapStorage.LongestPrefix(path)
+	r.sealWrapLock.RUnlock()
+	if !ok {
+		return false
+	}
+
+	re := raw.(*routeEntry)
+	if re.mountEntry.SealWrap {
+		return true
+	}
+
+	sealWrapPaths := re.sealWrapPaths.Load().(*radix.Tree)
+	remain := strings.TrimPrefix(path, prefix)
+	match, raw, ok := sealWrapPaths.LongestPrefix(remain)
+	if !ok {
+		return false
+	}
+	return match == remain || raw.(bool)
+}
+
 // filteredPassthroughHeaders returns a headers map[string][]string that
 // contains the filtered values contained in passthroughHeaders. Filtering of
 // passthroughHeaders from the origHeaders is done is a case-insensitive manner.
@@ -22,6 +22,7 @@ type NoopBackend struct {
 
 	Root            []string
 	Login           []string
+	SealWrap        []string
 	Paths           []string
 	Requests        []*logical.Request
 	Response        *logical.Response
@@ -64,6 +65,7 @@ func (n *NoopBackend) SpecialPaths() *logical.Paths {
 	return &logical.Paths{
 		Root:            n.Root,
 		Unauthenticated: n.Login,
+		SealWrapStorage: n.SealWrap,
 	}
 }
 
@@ -436,6 +438,63 @@ func TestRouter_RootPath(t *testing.T) {
 	}
 }
 
+func TestRouter_SealWrapStoragePath(t *testing.T) {
+	r := NewRouter()
+	_, barrier, _ := mockBarrier(t)
+
+	n := &NoopBackend{
+		SealWrap: []string{
+			"config",
+			"keys/",
+			"policy/*",
+		},
+	}
+	view := NewBarrierView(barrier, "logical/foo/")
+	err := r.Mount(n, "prod/aws/", &MountEntry{UUID: "foo", Accessor: "awsaccessor", NamespaceID: namespace.RootNamespaceID, namespace: namespace.RootNamespace}, view)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// All the entries of a seal wrapped mount are seal wrapped
+	view = NewBarrierView(barrier, "logical/bar/")
+	err = r.Mount(&NoopBackend{}, "prod/kv/", &MountEntry{UUID: "bar", Accessor: "kvaccessor", SealWrap: true, NamespaceID: namespace.RootNamespaceID, namespace: namespace.RootNamespace}, view)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	type tcase struct {
+		path   string
+		expect bool
+	}
+	tcases := []tcase{
+		{"random", false},
+		{"logical/foo/random", false},
+		{"logical/foo/config", true},
+		{"logical/foo/config/more", false},
+		{"logical/foo/keys", false},
+		{"logical/foo/keys/", true},
+		{"logical/foo/keys/ops", true},
+		{"logical/foo/policy/ops", true},
+		{"logical/bar/random", true},
+		{"logical/baz/config", false},
+	}
+
+	for _, tc := range tcases {
+		out := r.SealWrapStoragePath(tc.path)
+		if out != tc.expect {
+			t.Fatalf("bad: path: %s expect: %v got %v", tc.path, tc.expect, out)
+		}
+	}
+
+	// Unmounted entries are no longer seal wrapped
+	if err := r.Unmount(namespace.RootContext(nil), "prod/aws/"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if r.SealWrapStoragePath("logical/foo/config") {
+		t.Fatal("expected path of unmounted backend not to be seal wrapped")
+	}
+}
+
 func TestRouter_LoginPath(t *testing.T) {
 	r := NewRouter()
 	_, barrier, _ := mockBarrier(t)
@@ -449,6 +508,9 @@ func TestRouter_LoginPath(t *testing.T) {
 		Login: []string{
 			"login",
 			"oauth/*",
+			"glob1/+",
+			"glob2/+/end",
+			"glob3/+/.well-known/*",
 		},
 	}
 	err = r.Mount(n, "auth/foo/", &MountEntry{UUID: meUUID, Accessor: "authfooaccessor", NamespaceID: namespace.RootNamespaceID, namespace: namespace.RootNamespace}, view)
@@ -466,6 +528,17 @@ func TestRouter_LoginPath(t *testing.T) {
 		{"auth/foo/login", true},
 		{"auth/foo/oauth", false},
 		{"auth/foo/oauth/redirect", true},
+		{"auth/foo/glob1", false},
+		{"auth/foo/glob1/", false},
+		{"auth/foo/glob1/foo", true},
+		{"auth/foo/glob1/foo/bar", false},
+		{"auth/foo/glob2/foo/end", true},
+		{"auth/foo/glob2/foo/bar", false},
+		{"auth/foo/glob2/foo/end/bar", false},
+		{"auth/foo/glob3/foo/.well-known/keys", true},
+		{"auth/foo/glob3/foo/.well-known/", true},
+		{"auth/foo/glob3/foo/bar/.well-known/keys", false},
+		{"auth/foo/glob3/foo/keys", false},
 	}
 
 	for _, tc := range tcases {
@@ -0,0 +1,720 @@
+// +build cgo
+
+package pkcs11
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hashicorp/errwrap"
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/physical"
+	"github.com/hashicorp/vault/vault/seal"
+	"github.com/miekg/pkcs11"
+)
+
+const (
+	// EnvHSMLib is the path to the PKCS#11 library
+	EnvHSMLib = "VAULT_HSM_LIB"
+
+	// EnvHSMSlot is the number of the slot holding the key
+	EnvHSMSlot = "VAULT_HSM_SLOT"
+
+	// EnvHSMTokenLabel is the label of the token holding the key, used to
+	// find the slot
+	EnvHSMTokenLabel = "VAULT_HSM_TOKEN_LABEL"
+
+	// EnvHSMPin is the PIN of the user of the token
+	EnvHSMPin = "VAULT_HSM_PIN"
+
+	// EnvHSMKeyLabel is the label of the key to use
+	EnvHSMKeyLabel = "VAULT_HSM_KEY_LABEL"
+
+	// EnvHSMMechanism is the mechanism used to encrypt with the key
+	EnvHSMMechanism = "VAULT_HSM_MECHANISM"
+
+	// EnvHSMGenerateKey enables the generation of the key if it does not exist
+	EnvHSMGenerateKey = "VAULT_HSM_GENERATE_KEY"
+
+	// EnvHSMRSAOAEPHash is the hash algorithm used with RSA OAEP
+	EnvHSMRSAOAEPHash = "VAULT_HSM_RSA_OAEP_HASH"
+
+	// gcmIVSize is the size of the IVs used with AES GCM
+	gcmIVSize = 12
+
+	// gcmTagBits is the size of the authentication tags of AES GCM
+	gcmTagBits = 128
+)
+
+// pkcs11Context is the part of the PKCS#11 API used by the seal, implemented
+// by *pkcs11.Ctx
+type pkcs11Context interface {
+	Initialize() error
+	Finalize() error
+	Destroy()
+
+	GetSlotList(tokenPresent bool) ([]uint, error)
+	GetTokenInfo(slotID uint) (pkcs11.TokenInfo, error)
+
+	OpenSession(slotID uint, flags uint) (pkcs11.SessionHandle, error)
+	CloseSession(sh pkcs11.SessionHandle) error
+	Login(sh pkcs11.SessionHandle, userType uint, pin string) error
+	Logout(sh pkcs11.SessionHandle) error
+
+	FindObjectsInit(sh pkcs11.SessionHandle, temp []*pkcs11.Attribute) error
+	FindObjects(sh pkcs11.SessionHandle, max int) ([]pkcs11.ObjectHandle, bool, error)
+	FindObjectsFinal(sh pkcs11.SessionHandle) error
+
+	GenerateKey(sh pkcs11.SessionHandle, m []*pkcs11.Mechanism, temp []*pkcs11.Attribute) (pkcs11.ObjectHandle, error)
+	GenerateKeyPair(sh pkcs11.SessionHandle, m []*pkcs11.Mechanism, public, private []*pkcs11.Attribute) (pkcs11.ObjectHandle, pkcs11.ObjectHandle, error)
+
+	EncryptInit(sh pkcs11.SessionHandle, m []*pkcs11.Mechanism, o pkcs11.ObjectHandle) error
+	Encrypt(sh pkcs11.SessionHandle, message []byte) ([]byte, error)
+	DecryptInit(sh pkcs11.SessionHandle, m []*pkcs11.Mechanism, o pkcs11.ObjectHandle) error
+	Decrypt(sh pkcs11.SessionHandle, cipher []byte) ([]byte, error)
+}
+
+// Ensure the PKCS#11 library implements pkcs11Context
+var _ pkcs11Context = (*pkcs11.Ctx)(nil)
+
+// PKCS11Seal is a seal that encrypts and decrypts data with an AES or RSA
+// key of a PKCS#11 module, such as an HSM
+type PKCS11Seal struct {
+	lib         string
+	slot        uint
+	tokenLabel  string
+	pin         string
+	keyLabel    string
+	mechanism   uint
+	oaepHash    string
+	generateKey bool
+
+	// l serializes the operations of the session, which PKCS#11 does not
+	// allow to be used concurrently
+	l       sync.Mutex
+	ctx     pkcs11Context
+	session pkcs11.SessionHandle
+
+	currentKeyID *atomic.Value
+
+	logger log.Logger
+}
+
+// Ensure that we are implementing AutoSealAccess
+var _ seal.Access = (*PKCS11Seal)(nil)
+
+// NewSeal creates a new PKCS#11 seal with the provided logger
+func NewSeal(logger log.Logger) *PKCS11Seal {
+	s := &PKCS11Seal{
+		logger:       logger,
+		currentKeyID: new(atomic.Value),
+	}
+	s.currentKeyID.Store("")
+	return s
+}
+
+// configValue returns the value of an option, taken from the environment
+// variable first and from the configuration otherwise
+func configValue(config map[string]string, env, key string) string {
+	if v := os.Getenv(env); v != "" {
+		return v
+	}
+	return config[key]
+}
+
+// SetConfig sets the fields on the PKCS11Seal object based on values from
+// the config parameter, opens a session on the token and looks up the key.
+//
+// Environment variables take precedence over the configuration file.
+func (s *PKCS11Seal) SetConfig(config map[string]string) (map[string]string, error) {
+	if config == nil {
+		config = map[string]string{}
+	}
+
+	s.lib = configValue(config, EnvHSMLib, "lib")
+	if s.lib == "" && s.ctx == nil {
+		return nil, fmt.Errorf("'lib' not found for PKCS#11 seal configuration")
+	}
+
+	s.tokenLabel = configValue(config, EnvHSMTokenLabel, "token_label")
+	slot := configValue(config, EnvHSMSlot, "slot")
+	if slot == "" && s.tokenLabel == "" {
+		return nil, fmt.Errorf("'slot' or 'token_label' not found for PKCS#11 seal configuration")
+	}
+	if slot != "" {
+		slotID, err := strconv.ParseUint(slot, 10, 32)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to parse 'slot': {{err}}", err)
+		}
+		s.slot = uint(slotID)
+	}
+
+	s.pin = configValue(config, EnvHSMPin, "pin")
+	if s.pin == "" {
+		return nil, fmt.Errorf("'pin' not found for PKCS#11 seal configuration")
+	}
+
+	s.keyLabel = configValue(config, EnvHSMKeyLabel, "key_label")
+	if s.keyLabel == "" {
+		return nil, fmt.Errorf("'key_label' not found for PKCS#11 seal configuration")
+	}
+
+	if mechanism := configValue(config, EnvHSMMechanism, "mechanism"); mechanism != "" {
+		m, err := strconv.ParseUint(mechanism, 0, 32)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to parse 'mechanism': {{err}}", err)
+		}
+		switch m {
+		case pkcs11.CKM_AES_GCM, pkcs11.CKM_RSA_PKCS_OAEP:
+		default:
+			return nil, fmt.Errorf("unsupported mechanism %q", mechanism)
+		}
+		s.mechanism = uint(m)
+	}
+
+	s.oaepHash = configValue(config, EnvHSMRSAOAEPHash, "rsa_oaep_hash")
+	if s.oaepHash == "" {
+		s.oaepHash = "sha256"
+	}
+	if _, err := oaepHashMechanism(s.oaepHash); err != nil {
+		return nil, err
+	}
+
+	if generateKey := configValue(config, EnvHSMGenerateKey, "generate_key"); generateKey != "" {
+		var err error
+		s.generateKey, err = strconv.ParseBool(generateKey)
+		if err != nil {
+			return nil, errwrap.Wrapf("failed to parse 'generate_key': {{err}}", err)
+		}
+	}
+
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if err := s.openSession(); err != nil {
+		return nil, err
+	}
+
+	// Look up the key, which is generated by Init if it does not exist yet
+	found, err := s.findKey()
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if !s.generateKey {
+			return nil, fmt.Errorf("key %q not found and key generation is disabled", s.keyLabel)
+		}
+		s.logger.Info("key not found, it will be generated on initialization", "key_label", s.keyLabel)
+	}
+
+	// Map that holds non-sensitive configuration info
+	sealInfo := make(map[string]string)
+	sealInfo["lib"] = s.lib
+	sealInfo["slot"] = strconv.FormatUint(uint64(s.slot), 10)
+	if s.tokenLabel != "" {
+		sealInfo["token_label"] = s.tokenLabel
+	}
+	sealInfo["key_label"] = s.keyLabel
+	sealInfo["mechanism"] = fmt.Sprintf("0x%04x", s.mechanism)
+
+	return sealInfo, nil
+}
+
+// openSession loads the library if needed, finds the slot and logs into a
+// session
+func (s *PKCS11Seal) openSession() error {
+	if s.ctx == nil {
+		ctx := pkcs11.New(s.lib)
+		if ctx == nil {
+			return fmt.Errorf("failed to load PKCS#11 library %q", s.lib)
+		}
+		s.ctx = ctx
+	}
+
+	if err := s.ctx.Initialize(); err != nil && !isPKCS11Error(err, pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED) {
+		return errwrap.Wrapf("failed to initialize PKCS#11 library: {{err}}", err)
+	}
+
+	if s.tokenLabel != "" {
+		slots, err := s.ctx.GetSlotList(true)
+		if err != nil {
+			return errwrap.Wrapf("failed to list PKCS#11 slots: {{err}}", err)
+		}
+		found := false
+		for _, slot := range slots {
+			info, err := s.ctx.GetTokenInfo(slot)
+			if err != nil {
+				return errwrap.Wrapf(fmt.Sprintf("failed to get the token info of slot %d: {{err}}", slot), err)
+			}
+			if strings.TrimRight(info.Label, " \x00") == s.tokenLabel {
+				s.slot = slot
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("no slot found with token label %q", s.tokenLabel)
+		}
+	}
+
+	session, err := s.ctx.OpenSession(s.slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("failed to open a session on slot %d: {{err}}", s.slot), err)
+	}
+
+	if err := s.ctx.Login(session, pkcs11.CKU_USER, s.pin); err != nil && !isPKCS11Error(err, pkcs11.CKR_USER_ALREADY_LOGGED_IN) {
+		s.ctx.CloseSession(session)
+		return errwrap.Wrapf("failed to log in: {{err}}", err)
+	}
+
+	s.session = session
+	return nil
+}
+
+// findKey looks up the key and sets the mechanism if not configured. AES
+// keys are used with AES GCM, RSA keys with RSA OAEP.
+func (s *PKCS11Seal) findKey() (bool, error) {
+	_, aesFound, err := s.findObject(pkcs11.CKO_SECRET_KEY, pkcs11.CKK_AES, s.keyLabel)
+	if err != nil {
+		return false, err
+	}
+	_, rsaFound, err := s.findObject(pkcs11.CKO_PRIVATE_KEY, pkcs11.CKK_RSA, s.keyLabel)
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case aesFound && rsaFound:
+		return false, fmt.Errorf("both an AES and an RSA key are labeled %q", s.keyLabel)
+
+	case aesFound:
+		if s.mechanism == 0 {
+			s.mechanism = pkcs11.CKM_AES_GCM
+		}
+		if s.mechanism != pkcs11.CKM_AES_GCM {
+			return false, fmt.Errorf("mechanism 0x%04x cannot be used with AES key %q", s.mechanism, s.keyLabel)
+		}
+
+	case rsaFound:
+		if s.mechanism == 0 {
+			s.mechanism = pkcs11.CKM_RSA_PKCS_OAEP
+		}
+		if s.mechanism != pkcs11.CKM_RSA_PKCS_OAEP {
+			return false, fmt.Errorf("mechanism 0x%04x cannot be used with RSA key %q", s.mechanism, s.keyLabel)
+		}
+
+	default:
+		if s.mechanism == 0 {
+			s.mechanism = pkcs11.CKM_AES_GCM
+		}
+		return false, nil
+	}
+
+	s.currentKeyID.Store(s.keyLabel)
+	return true, nil
+}
+
+// findObject looks up a key object by class, type and label
+func (s *PKCS11Seal) findObject(class, keyType uint, label string) (pkcs11.ObjectHandle, bool, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, keyType),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	if err := s.ctx.FindObjectsInit(s.session, template); err != nil {
+		return 0, false, errwrap.Wrapf("failed to look up key: {{err}}", err)
+	}
+	objects, _, err := s.ctx.FindObjects(s.session, 1)
+	if finalErr := s.ctx.FindObjectsFinal(s.session); err == nil {
+		err = finalErr
+	}
+	if err != nil {
+		return 0, false, errwrap.Wrapf("failed to look up key: {{err}}", err)
+	}
+	if len(objects) == 0 {
+		return 0, false, nil
+	}
+	return objects[0], true, nil
+}
+
+// Init is called during core.Initialize and generates the key if it does
+// not exist and key generation is enabled.
+func (s *PKCS11Seal) Init(_ context.Context) error {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	found, err := s.findKey()
+	if err != nil {
+		return err
+	}
+	if found {
+		return nil
+	}
+	if !s.generateKey {
+		return fmt.Errorf("key %q not found and key generation is disabled", s.keyLabel)
+	}
+
+	if err := s.generate(); err != nil {
+		return err
+	}
+	s.logger.Info("generated key", "key_label", s.keyLabel, "mechanism", fmt.Sprintf("0x%04x", s.mechanism))
+
+	found, err = s.findKey()
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("generated key %q not found", s.keyLabel)
+	}
+	return nil
+}
+
+// generate generates a non-extractable key suited to the mechanism
+func (s *PKCS11Seal) generate() error {
+	idBytes := make([]byte, 4)
+	if _, err := rand.Read(idBytes); err != nil {
+		return errwrap.Wrapf("failed to generate key ID: {{err}}", err)
+	}
+	id := binary.BigEndian.Uint32(idBytes)
+
+	switch s.mechanism {
+	case pkcs11.CKM_AES_GCM:
+		template := []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_AES),
+			pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, 32),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, s.keyLabel),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_ENCRYPT, true),
+			pkcs11.NewAttribute(pkcs11.CKA_DECRYPT, true),
+			pkcs11.NewAttribute(pkcs11.CKA_WRAP, true),
+			pkcs11.NewAttribute(pkcs11.CKA_UNWRAP, true),
+			pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		}
+		mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_KEY_GEN, nil)}
+		if _, err := s.ctx.GenerateKey(s.session, mechanism, template); err != nil {
+			return errwrap.Wrapf("failed to generate AES key: {{err}}", err)
+		}
+
+	case pkcs11.CKM_RSA_PKCS_OAEP:
+		public := []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, s.keyLabel),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+			pkcs11.NewAttribute(pkcs11.CKA_ENCRYPT, true),
+			pkcs11.NewAttribute(pkcs11.CKA_WRAP, true),
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS_BITS, 2048),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, []byte{1, 0, 1}),
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		}
+		private := []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, s.keyLabel),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+			pkcs11.NewAttribute(pkcs11.CKA_DECRYPT, true),
+			pkcs11.NewAttribute(pkcs11.CKA_UNWRAP, true),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		}
+		mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN, nil)}
+		if _, _, err := s.ctx.GenerateKeyPair(s.session, mechanism, public, private); err != nil {
+			return errwrap.Wrapf("failed to generate RSA key pair: {{err}}", err)
+		}
+
+	default:
+		return fmt.Errorf("cannot generate a key for mechanism 0x%04x", s.mechanism)
+	}
+
+	return nil
+}
+
+// Finalize is called during shutdown and closes the session.
+func (s *PKCS11Seal) Finalize(_ context.Context) error {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if s.ctx == nil {
+		return nil
+	}
+
+	s.ctx.Logout(s.session)
+	s.ctx.CloseSession(s.session)
+	err := s.ctx.Finalize()
+	s.ctx.Destroy()
+	s.ctx = nil
+	if err != nil {
+		return errwrap.Wrapf("failed to finalize PKCS#11 library: {{err}}", err)
+	}
+	return nil
+}
+
+// SealType returns the seal type for this particular seal implementation.
+func (s *PKCS11Seal) SealType() string {
+	return seal.PKCS11
+}
+
+// KeyID returns the label of the key.
+func (s *PKCS11Seal) KeyID() string {
+	return s.currentKeyID.Load().(string)
+}
+
+// Encrypt is used to encrypt the master key. The data is encrypted with a
+// data encryption key, which is encrypted with the key of the PKCS#11 module.
+func (s *PKCS11Seal) Encrypt(_ context.Context, plaintext []byte) (*physical.EncryptedBlobInfo, error) {
+	if plaintext == nil {
+		return nil, fmt.Errorf("given plaintext for encryption is nil")
+	}
+
+	env, err := seal.NewEnvelope().Encrypt(plaintext)
+	if err != nil {
+		return nil, errwrap.Wrapf("error wrapping data: {{err}}", err)
+	}
+
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if s.ctx == nil {
+		return nil, fmt.Errorf("nil PKCS#11 context")
+	}
+
+	wrappedKey, flags, err := s.encryptKey(env.Key)
+	if err != nil {
+		return nil, errwrap.Wrapf("error encrypting data encryption key: {{err}}", err)
+	}
+
+	ret := &physical.EncryptedBlobInfo{
+		Ciphertext: env.Ciphertext,
+		IV:         env.IV,
+		KeyInfo: &physical.SealKeyInfo{
+			Mechanism: uint64(s.mechanism),
+			// Store the key label to be able to decrypt with the right key
+			// once the key label is changed to rotate the key
+			KeyID:      s.keyLabel,
+			WrappedKey: wrappedKey,
+			Flags:      flags,
+		},
+	}
+
+	return ret, nil
+}
+
+// Decrypt is used to decrypt the ciphertext. This should be called after Init.
+func (s *PKCS11Seal) Decrypt(_ context.Context, in *physical.EncryptedBlobInfo) ([]byte, error) {
+	if in == nil {
+		return nil, fmt.Errorf("given input for decryption is nil")
+	}
+	if in.KeyInfo == nil {
+		return nil, fmt.Errorf("key info is missing")
+	}
+
+	keyLabel := in.KeyInfo.KeyID
+	if keyLabel == "" {
+		keyLabel = s.keyLabel
+	}
+
+	s.l.Lock()
+	key, err := s.decryptKey(uint(in.KeyInfo.Mechanism), in.KeyInfo.Flags, keyLabel, in.KeyInfo.WrappedKey)
+	s.l.Unlock()
+	if err != nil {
+		return nil, errwrap.Wrapf("error decrypting data encryption key: {{err}}", err)
+	}
+
+	envInfo := &seal.EnvelopeInfo{
+		Key:        key,
+		IV:         in.IV,
+		Ciphertext: in.Ciphertext,
+	}
+	plaintext, err := seal.NewEnvelope().Decrypt(envInfo)
+	if err != nil {
+		return nil, errwrap.Wrapf("error decrypting data: {{err}}", err)
+	}
+
+	return plaintext, nil
+}
+
+// encryptKey encrypts a data encryption key with the key of the module,
+// returning it along with the flags of the mechanism. For AES GCM, the IV is
+// prepended to the ciphertext. For RSA OAEP, the flags are the hash
+// mechanism, so that the key can still be decrypted once the configured hash
+// is changed.
+func (s *PKCS11Seal) encryptKey(key []byte) ([]byte, uint64, error) {
+	switch s.mechanism {
+	case pkcs11.CKM_AES_GCM:
+		object, found, err := s.findObject(pkcs11.CKO_SECRET_KEY, pkcs11.CKK_AES, s.keyLabel)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !found {
+			return nil, 0, fmt.Errorf("key %q not found", s.keyLabel)
+		}
+
+		iv := make([]byte, gcmIVSize)
+		if _, err := rand.Read(iv); err != nil {
+			return nil, 0, err
+		}
+		params := pkcs11.NewGCMParams(iv, nil, gcmTagBits)
+		defer params.Free()
+
+		if err := s.ctx.EncryptInit(s.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_GCM, params)}, object); err != nil {
+			return nil, 0, err
+		}
+		ciphertext, err := s.ctx.Encrypt(s.session, key)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		// Some modules ignore the given IV and generate their own
+		if actualIV := params.IV(); len(actualIV) != 0 {
+			iv = actualIV
+		}
+		return append(iv, ciphertext...), 0, nil
+
+	case pkcs11.CKM_RSA_PKCS_OAEP:
+		object, found, err := s.findObject(pkcs11.CKO_PUBLIC_KEY, pkcs11.CKK_RSA, s.keyLabel)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !found {
+			return nil, 0, fmt.Errorf("key %q not found", s.keyLabel)
+		}
+
+		hashAlg, err := oaepHashMechanism(s.oaepHash)
+		if err != nil {
+			return nil, 0, err
+		}
+		mechanism, err := oaepMechanism(hashAlg)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := s.ctx.EncryptInit(s.session, []*pkcs11.Mechanism{mechanism}, object); err != nil {
+			return nil, 0, err
+		}
+		ciphertext, err := s.ctx.Encrypt(s.session, key)
+		if err != nil {
+			return nil, 0, err
+		}
+		return ciphertext, uint64(hashAlg), nil
+
+	default:
+		return nil, 0, fmt.Errorf("invalid mechanism: 0x%04x", s.mechanism)
+	}
+}
+
+// decryptKey decrypts a data encryption key encrypted by encryptKey
+func (s *PKCS11Seal) decryptKey(mechanism uint, flags uint64, keyLabel string, wrappedKey []byte) ([]byte, error) {
+	if s.ctx == nil {
+		return nil, fmt.Errorf("nil PKCS#11 context")
+	}
+
+	switch mechanism {
+	case pkcs11.CKM_AES_GCM:
+		object, found, err := s.findObject(pkcs11.CKO_SECRET_KEY, pkcs11.CKK_AES, keyLabel)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, fmt.Errorf("key %q not found", keyLabel)
+		}
+
+		if len(wrappedKey) < gcmIVSize {
+			return nil, errors.New("invalid wrapped key")
+		}
+		params := pkcs11.NewGCMParams(wrappedKey[:gcmIVSize], nil, gcmTagBits)
+		defer params.Free()
+
+		if err := s.ctx.DecryptInit(s.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_GCM, params)}, object); err != nil {
+			return nil, err
+		}
+		return s.ctx.Decrypt(s.session, wrappedKey[gcmIVSize:])
+
+	case pkcs11.CKM_RSA_PKCS_OAEP:
+		object, found, err := s.findObject(pkcs11.CKO_PRIVATE_KEY, pkcs11.CKK_RSA, keyLabel)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, fmt.Errorf("key %q not found", keyLabel)
+		}
+
+		// Keys encrypted before the hash was recorded use the configured one
+		hashAlg := uint(flags)
+		if hashAlg == 0 {
+			hashAlg, err = oaepHashMechanism(s.oaepHash)
+			if err != nil {
+				return nil, err
+			}
+		}
+		m, err := oaepMechanism(hashAlg)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.ctx.DecryptInit(s.session, []*pkcs11.Mechanism{m}, object); err != nil {
+			return nil, err
+		}
+		return s.ctx.Decrypt(s.session, wrappedKey)
+
+	default:
+		return nil, fmt.Errorf("invalid mechanism: 0x%04x", mechanism)
+	}
+}
+
+// oaepMechanism returns the RSA OAEP mechanism for a hash mechanism
+func oaepMechanism(hashAlg uint) (*pkcs11.Mechanism, error) {
+	var mgf uint
+	switch hashAlg {
+	case pkcs11.CKM_SHA_1:
+		mgf = pkcs11.CKG_MGF1_SHA1
+	case pkcs11.CKM_SHA224:
+		mgf = pkcs11.CKG_MGF1_SHA224
+	case pkcs11.CKM_SHA256:
+		mgf = pkcs11.CKG_MGF1_SHA256
+	case pkcs11.CKM_SHA384:
+		mgf = pkcs11.CKG_MGF1_SHA384
+	case pkcs11.CKM_SHA512:
+		mgf = pkcs11.CKG_MGF1_SHA512
+	default:
+		return nil, fmt.Errorf("unsupported RSA OAEP hash mechanism 0x%04x", hashAlg)
+	}
+	params := pkcs11.NewOAEPParams(hashAlg, mgf, pkcs11.CKZ_DATA_SPECIFIED, nil)
+	return pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_OAEP, params), nil
+}
+
+// oaepHashMechanism returns the hash mechanism of a hash algorithm used with
+// RSA OAEP
+func oaepHashMechanism(hash string) (uint, error) {
+	switch hash {
+	case "sha1":
+		return pkcs11.CKM_SHA_1, nil
+	case "sha224":
+		return pkcs11.CKM_SHA224, nil
+	case "sha256":
+		return pkcs11.CKM_SHA256, nil
+	case "sha384":
+		return pkcs11.CKM_SHA384, nil
+	case "sha512":
+		return pkcs11.CKM_SHA512, nil
+	default:
+		return 0, fmt.Errorf("unsupported RSA OAEP hash algorithm %q", hash)
+	}
+}
+
+// isPKCS11Error returns whether an error is a given PKCS#11 return value
+func isPKCS11Error(err error, code uint) bool {
+	pkcs11Err, ok := err.(pkcs11.Error)
+	return ok && uint(pkcs11Err) == code
+}
@@ -0,0 +1,408 @@
+// +build cgo
+
+package pkcs11
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"hash"
+	"os"
+	"reflect"
+	"testing"
+
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/helper/logging"
+	"github.com/miekg/pkcs11"
+)
+
+type testObject struct {
+	attrs  []*pkcs11.Attribute
+	aesKey []byte
+	rsaKey *rsa.PrivateKey
+}
+
+// testContext is an in-memory PKCS#11 module holding AES and RSA keys
+type testContext struct {
+	objects  []*testObject
+	found    []pkcs11.ObjectHandle
+	loggedIn bool
+	op       *testObject
+	opHash   uint
+	final    bool
+}
+
+// testOAEPHash returns the hash of the RSA OAEP parameters of a mechanism.
+// The parameters are unexported, as they are only read by the C library.
+func testOAEPHash(m []*pkcs11.Mechanism) uint {
+	generator := reflect.ValueOf(m[0]).Elem().FieldByName("generator")
+	if generator.IsNil() {
+		return 0
+	}
+	params := generator.Elem().Elem()
+	if params.Type() != reflect.TypeOf(pkcs11.OAEPParams{}) {
+		return 0
+	}
+	return uint(params.FieldByName("HashAlg").Uint())
+}
+
+func testNewHash(hashAlg uint) hash.Hash {
+	switch hashAlg {
+	case pkcs11.CKM_SHA_1:
+		return sha1.New()
+	case pkcs11.CKM_SHA224:
+		return sha256.New224()
+	case pkcs11.CKM_SHA384:
+		return sha512.New384()
+	case pkcs11.CKM_SHA512:
+		return sha512.New()
+	default:
+		return sha256.New()
+	}
+}
+
+func (c *testContext) Initialize() error { return nil }
+func (c *testContext) Finalize() error   { c.final = true; return nil }
+func (c *testContext) Destroy()          {}
+
+func (c *testContext) GetSlotList(bool) ([]uint, error) { return []uint{0, 1}, nil }
+
+func (c *testContext) GetTokenInfo(slot uint) (pkcs11.TokenInfo, error) {
+	if slot == 1 {
+		return pkcs11.TokenInfo{Label: "vault   "}, nil
+	}
+	return pkcs11.TokenInfo{Label: "other"}, nil
+}
+
+func (c *testContext) OpenSession(uint, uint) (pkcs11.SessionHandle, error) { return 1, nil }
+func (c *testContext) CloseSession(pkcs11.SessionHandle) error              { return nil }
+
+func (c *testContext) Login(_ pkcs11.SessionHandle, _ uint, pin string) error {
+	if pin != "1234" {
+		return pkcs11.Error(pkcs11.CKR_PIN_INCORRECT)
+	}
+	c.loggedIn = true
+	return nil
+}
+
+func (c *testContext) Logout(pkcs11.SessionHandle) error { c.loggedIn = false; return nil }
+
+func (c *testContext) FindObjectsInit(_ pkcs11.SessionHandle, temp []*pkcs11.Attribute) error {
+	c.found = nil
+	for i, o := range c.objects {
+		if matchAttributes(o.attrs, temp) {
+			c.found = append(c.found, pkcs11.ObjectHandle(i))
+		}
+	}
+	return nil
+}
+
+func (c *testContext) FindObjects(_ pkcs11.SessionHandle, max int) ([]pkcs11.ObjectHandle, bool, error) {
+	if len(c.found) > max {
+		return c.found[:max], false, nil
+	}
+	return c.found, false, nil
+}
+
+func (c *testContext) FindObjectsFinal(pkcs11.SessionHandle) error { c.found = nil; return nil }
+
+func (c *testContext) GenerateKey(_ pkcs11.SessionHandle, _ []*pkcs11.Mechanism, temp []*pkcs11.Attribute) (pkcs11.ObjectHandle, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return 0, err
+	}
+	c.objects = append(c.objects, &testObject{attrs: temp, aesKey: key})
+	return pkcs11.ObjectHandle(len(c.objects) - 1), nil
+}
+
+func (c *testContext) GenerateKeyPair(_ pkcs11.SessionHandle, _ []*pkcs11.Mechanism, public, private []*pkcs11.Attribute) (pkcs11.ObjectHandle, pkcs11.ObjectHandle, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return 0, 0, err
+	}
+	c.objects = append(c.objects,
+		&testObject{attrs: public, rsaKey: key},
+		&testObject{attrs: private, rsaKey: key})
+	return pkcs11.ObjectHandle(len(c.objects) - 2), pkcs11.ObjectHandle(len(c.objects) - 1), nil
+}
+
+func (c *testContext) EncryptInit(_ pkcs11.SessionHandle, m []*pkcs11.Mechanism, o pkcs11.ObjectHandle) error {
+	c.op = c.objects[o]
+	c.opHash = testOAEPHash(m)
+	return nil
+}
+
+// Encrypt uses its own nonce for AES GCM as the parameters cannot be read
+// back without the C library
+func (c *testContext) Encrypt(_ pkcs11.SessionHandle, message []byte) ([]byte, error) {
+	o := c.op
+	c.op = nil
+	if o.rsaKey != nil {
+		return rsa.EncryptOAEP(testNewHash(c.opHash), rand.Reader, &o.rsaKey.PublicKey, message, nil)
+	}
+	aead, err := testGCM(o.aesKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, message, nil), nil
+}
+
+func (c *testContext) DecryptInit(_ pkcs11.SessionHandle, m []*pkcs11.Mechanism, o pkcs11.ObjectHandle) error {
+	c.op = c.objects[o]
+	c.opHash = testOAEPHash(m)
+	return nil
+}
+
+func (c *testContext) Decrypt(_ pkcs11.SessionHandle, ciphertext []byte) ([]byte, error) {
+	o := c.op
+	c.op = nil
+	if o.rsaKey != nil {
+		return rsa.DecryptOAEP(testNewHash(c.opHash), rand.Reader, o.rsaKey, ciphertext, nil)
+	}
+	aead, err := testGCM(o.aesKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("invalid ciphertext")
+	}
+	return aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], nil)
+}
+
+func testGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func matchAttributes(attrs, temp []*pkcs11.Attribute) bool {
+	for _, t := range temp {
+		found := false
+		for _, a := range attrs {
+			if a.Type == t.Type && bytes.Equal(a.Value, t.Value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func testSeal(t *testing.T, ctx *testContext, config map[string]string) *PKCS11Seal {
+	s := NewSeal(logging.NewVaultLogger(log.Trace))
+	s.ctx = ctx
+	if _, err := s.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func testEncryptDecrypt(t *testing.T, s *PKCS11Seal) {
+	input := []byte("foo")
+	swi, err := s.Encrypt(context.Background(), input)
+	if err != nil {
+		t.Fatalf("err: %s", err.Error())
+	}
+	if swi.KeyInfo.KeyID != s.KeyID() || uint(swi.KeyInfo.Mechanism) != s.mechanism {
+		t.Fatalf("bad: %#v", swi.KeyInfo)
+	}
+
+	pt, err := s.Decrypt(context.Background(), swi)
+	if err != nil {
+		t.Fatalf("err: %s", err.Error())
+	}
+	if !reflect.DeepEqual(input, pt) {
+		t.Fatalf("expected %s, got %s", input, pt)
+	}
+}
+
+func TestPKCS11Seal_Lifecycle(t *testing.T) {
+	config := map[string]string{
+		"slot":      "0",
+		"pin":       "1234",
+		"key_label": "vault-key",
+	}
+
+	ctx := &testContext{}
+
+	// The key must exist unless key generation is enabled
+	s := NewSeal(logging.NewVaultLogger(log.Trace))
+	s.ctx = ctx
+	if _, err := s.SetConfig(config); err == nil {
+		t.Fatal("expected error for missing key")
+	}
+
+	config["generate_key"] = "true"
+	s = testSeal(t, ctx, config)
+	if !ctx.loggedIn {
+		t.Fatal("expected to be logged in")
+	}
+	if s.KeyID() != "" {
+		t.Fatalf("bad: key ID %q", s.KeyID())
+	}
+	if err := s.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if s.mechanism != pkcs11.CKM_AES_GCM || s.KeyID() != "vault-key" {
+		t.Fatalf("bad: mechanism 0x%04x, key ID %q", s.mechanism, s.KeyID())
+	}
+	testEncryptDecrypt(t, s)
+
+	// Initializing again uses the existing key
+	if err := s.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(ctx.objects) != 1 {
+		t.Fatalf("expected 1 key, got %d", len(ctx.objects))
+	}
+
+	if err := s.Finalize(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if ctx.loggedIn || !ctx.final {
+		t.Fatal("expected the session to be closed")
+	}
+}
+
+func TestPKCS11Seal_RSA(t *testing.T) {
+	ctx := &testContext{}
+	s := testSeal(t, ctx, map[string]string{
+		"token_label":  "vault",
+		"pin":          "1234",
+		"key_label":    "vault-rsa",
+		"mechanism":    "0x0009",
+		"generate_key": "true",
+	})
+	if s.slot != 1 {
+		t.Fatalf("bad: slot %d", s.slot)
+	}
+	if err := s.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(ctx.objects) != 2 {
+		t.Fatalf("expected a key pair, got %d objects", len(ctx.objects))
+	}
+	testEncryptDecrypt(t, s)
+
+	// The mechanism of an existing key is found from its type
+	s = testSeal(t, ctx, map[string]string{
+		"slot":      "0",
+		"pin":       "1234",
+		"key_label": "vault-rsa",
+	})
+	if s.mechanism != pkcs11.CKM_RSA_PKCS_OAEP {
+		t.Fatalf("bad: mechanism 0x%04x", s.mechanism)
+	}
+	testEncryptDecrypt(t, s)
+
+	// Ciphertext remains readable once the hash is changed, as the hash it
+	// was encrypted with is recorded
+	s = testSeal(t, ctx, map[string]string{
+		"slot":          "0",
+		"pin":           "1234",
+		"key_label":     "vault-rsa",
+		"rsa_oaep_hash": "sha1",
+	})
+	swi, err := s.Encrypt(context.Background(), []byte("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if swi.KeyInfo.Flags != pkcs11.CKM_SHA_1 {
+		t.Fatalf("bad: flags 0x%04x", swi.KeyInfo.Flags)
+	}
+	s = testSeal(t, ctx, map[string]string{
+		"slot":          "0",
+		"pin":           "1234",
+		"key_label":     "vault-rsa",
+		"rsa_oaep_hash": "sha512",
+	})
+	pt, err := s.Decrypt(context.Background(), swi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(pt) != "foo" {
+		t.Fatalf("bad: %s", pt)
+	}
+
+	// Ciphertext without a recorded hash uses the configured one
+	swi.KeyInfo.Flags = 0
+	if _, err := s.Decrypt(context.Background(), swi); err == nil {
+		t.Fatal("expected error decrypting with the wrong hash")
+	}
+
+	// The mechanism must match the key type
+	s = NewSeal(logging.NewVaultLogger(log.Trace))
+	s.ctx = ctx
+	if _, err := s.SetConfig(map[string]string{
+		"slot":      "0",
+		"pin":       "1234",
+		"key_label": "vault-rsa",
+		"mechanism": "0x1087",
+	}); err == nil {
+		t.Fatal("expected error for mismatched mechanism")
+	}
+}
+
+func TestPKCS11Seal_SetConfig(t *testing.T) {
+	for name, config := range map[string]map[string]string{
+		"missing slot":  {"pin": "1234", "key_label": "key"},
+		"missing pin":   {"slot": "0", "key_label": "key"},
+		"missing label": {"slot": "0", "pin": "1234"},
+		"bad pin":       {"slot": "0", "pin": "0000", "key_label": "key", "generate_key": "true"},
+		"bad mechanism": {"slot": "0", "pin": "1234", "key_label": "key", "mechanism": "0x1082"},
+		"bad hash":      {"slot": "0", "pin": "1234", "key_label": "key", "rsa_oaep_hash": "md5"},
+		"bad token":     {"token_label": "missing", "pin": "1234", "key_label": "key", "generate_key": "true"},
+	} {
+		s := NewSeal(logging.NewVaultLogger(log.Trace))
+		s.ctx = &testContext{}
+		if _, err := s.SetConfig(config); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+// TestAccPKCS11Seal_Lifecycle runs against a real PKCS#11 module, such as
+// SoftHSM:
+//
+//	softhsm2-util --init-token --free --label vault --pin 1234 --so-pin 1234
+//	VAULT_HSM_LIB=/usr/lib/softhsm/libsofthsm2.so VAULT_HSM_TOKEN_LABEL=vault \
+//	  VAULT_HSM_PIN=1234 go test -run TestAccPKCS11Seal ./vault/seal/pkcs11
+func TestAccPKCS11Seal_Lifecycle(t *testing.T) {
+	if os.Getenv(EnvHSMLib) == "" {
+		t.SkipNow()
+	}
+
+	for _, mechanism := range []string{"0x1087", "0x0009"} {
+		s := NewSeal(logging.NewVaultLogger(log.Trace))
+		if _, err := s.SetConfig(map[string]string{
+			"key_label":    "vault-test-" + mechanism,
+			"mechanism":    mechanism,
+			"generate_key": "true",
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Init(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		testEncryptDecrypt(t, s)
+		if err := s.Finalize(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
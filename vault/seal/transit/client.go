@@ -0,0 +1,292 @@
+package transit
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	// EnvTransitSealMountPath is the path of the transit secrets engine to use
+	EnvTransitSealMountPath = "VAULT_TRANSIT_SEAL_MOUNT_PATH"
+
+	// EnvTransitSealKeyName is the name of the transit key to use
+	EnvTransitSealKeyName = "VAULT_TRANSIT_SEAL_KEY_NAME"
+
+	// loginRetryInterval is the time to wait between two AppRole logins when
+	// logging in again fails
+	loginRetryInterval = 10 * time.Second
+)
+
+// transitClientEncryptor is the interface of the client encrypting and
+// decrypting data with the transit secrets engine of another Vault cluster
+type transitClientEncryptor interface {
+	Close()
+	Encrypt(plaintext []byte) (ciphertext []byte, err error)
+	Decrypt(ciphertext []byte) (plaintext []byte, err error)
+}
+
+// transitClient talks to the transit secrets engine of another Vault cluster
+type transitClient struct {
+	client *api.Client
+	logger log.Logger
+
+	mountPath string
+	keyName   string
+
+	// AppRole credentials, used to log in again once the token cannot be
+	// renewed anymore
+	roleID           string
+	secretID         string
+	approleMountPath string
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// newTransitClient creates a transit client from the seal configuration. It
+// also returns the non-sensitive configuration info.
+func newTransitClient(logger log.Logger, config map[string]string) (*transitClient, map[string]string, error) {
+	if config == nil {
+		config = map[string]string{}
+	}
+
+	c := &transitClient{
+		logger:           logger,
+		roleID:           config["role_id"],
+		secretID:         config["secret_id"],
+		approleMountPath: config["approle_mount_path"],
+		stopCh:           make(chan struct{}),
+	}
+
+	switch {
+	case os.Getenv(EnvTransitSealMountPath) != "":
+		c.mountPath = os.Getenv(EnvTransitSealMountPath)
+	case config["mount_path"] != "":
+		c.mountPath = config["mount_path"]
+	default:
+		return nil, nil, fmt.Errorf("'mount_path' not found for transit seal configuration")
+	}
+
+	switch {
+	case os.Getenv(EnvTransitSealKeyName) != "":
+		c.keyName = os.Getenv(EnvTransitSealKeyName)
+	case config["key_name"] != "":
+		c.keyName = config["key_name"]
+	default:
+		return nil, nil, fmt.Errorf("'key_name' not found for transit seal configuration")
+	}
+
+	if c.approleMountPath == "" {
+		c.approleMountPath = "approle"
+	}
+
+	var disableRenewal bool
+	if raw := config["disable_renewal"]; raw != "" {
+		var err error
+		disableRenewal, err = strconv.ParseBool(raw)
+		if err != nil {
+			return nil, nil, errwrap.Wrapf("failed to parse 'disable_renewal': {{err}}", err)
+		}
+	}
+
+	apiConfig := api.DefaultConfig()
+	if apiConfig.Error != nil {
+		return nil, nil, apiConfig.Error
+	}
+	if config["address"] != "" {
+		apiConfig.Address = config["address"]
+	}
+
+	tlsConfig := &api.TLSConfig{
+		CACert:        config["tls_ca_cert"],
+		ClientCert:    config["tls_client_cert"],
+		ClientKey:     config["tls_client_key"],
+		TLSServerName: config["tls_server_name"],
+	}
+	if raw := config["tls_skip_verify"]; raw != "" {
+		var err error
+		tlsConfig.Insecure, err = strconv.ParseBool(raw)
+		if err != nil {
+			return nil, nil, errwrap.Wrapf("failed to parse 'tls_skip_verify': {{err}}", err)
+		}
+	}
+	if err := apiConfig.ConfigureTLS(tlsConfig); err != nil {
+		return nil, nil, errwrap.Wrapf("failed to configure TLS for the transit seal: {{err}}", err)
+	}
+
+	client, err := api.NewClient(apiConfig)
+	if err != nil {
+		return nil, nil, errwrap.Wrapf("failed to create the transit seal client: {{err}}", err)
+	}
+	if config["namespace"] != "" {
+		client.SetNamespace(config["namespace"])
+	}
+	c.client = client
+
+	// Authenticate, either with the given token or with AppRole
+	var secret *api.Secret
+	switch {
+	case config["token"] != "":
+		client.SetToken(config["token"])
+	case c.roleID != "":
+		secret, err = c.login()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if client.Token() == "" {
+		return nil, nil, fmt.Errorf("missing 'token' or 'role_id' for transit seal configuration")
+	}
+
+	if !disableRenewal {
+		if secret == nil {
+			// Renew the token right away to find out whether it can be
+			// renewed, and to get the secret the renewer works with
+			secret, err = client.Auth().Token().RenewSelf(0)
+			if err != nil {
+				logger.Info("unable to renew token, disabling renewal", "error", err)
+			}
+		}
+		if secret != nil {
+			go c.renew(secret)
+		}
+	}
+
+	// Map that holds non-sensitive configuration info
+	sealInfo := make(map[string]string)
+	sealInfo["address"] = client.Address()
+	sealInfo["mount_path"] = c.mountPath
+	sealInfo["key_name"] = c.keyName
+	if config["namespace"] != "" {
+		sealInfo["namespace"] = config["namespace"]
+	}
+
+	return c, sealInfo, nil
+}
+
+// login logs in with AppRole and sets the token of the client
+func (c *transitClient) login() (*api.Secret, error) {
+	secret, err := c.client.Logical().Write(path.Join("auth", c.approleMountPath, "login"), map[string]interface{}{
+		"role_id":   c.roleID,
+		"secret_id": c.secretID,
+	})
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to log in with AppRole: {{err}}", err)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return nil, errors.New("no token returned by the AppRole login")
+	}
+
+	c.client.SetToken(secret.Auth.ClientToken)
+	return secret, nil
+}
+
+// renew keeps renewing the token until the client is closed. Once the token
+// cannot be renewed anymore, a new one is obtained with AppRole if it is
+// configured.
+func (c *transitClient) renew(secret *api.Secret) {
+	for {
+		renewer, err := c.client.NewRenewer(&api.RenewerInput{
+			Secret: secret,
+		})
+		if err != nil {
+			c.logger.Error("failed to create token renewer", "error", err)
+			return
+		}
+		go renewer.Renew()
+
+	RENEW:
+		for {
+			select {
+			case err := <-renewer.DoneCh():
+				if err != nil {
+					c.logger.Error("error renewing token", "error", err)
+				}
+				break RENEW
+			case <-renewer.RenewCh():
+				c.logger.Trace("successfully renewed token")
+			case <-c.stopCh:
+				renewer.Stop()
+				return
+			}
+		}
+
+		if c.roleID == "" {
+			c.logger.Warn("token can no longer be renewed, transit seal requests will fail once it expires")
+			return
+		}
+
+		for {
+			secret, err = c.login()
+			if err == nil {
+				c.logger.Info("logged in again with AppRole")
+				break
+			}
+			c.logger.Error("error logging in again", "error", err)
+
+			select {
+			case <-time.After(loginRetryInterval):
+			case <-c.stopCh:
+				return
+			}
+		}
+	}
+}
+
+// Close stops the token renewal
+func (c *transitClient) Close() {
+	c.stopOnce.Do(func() {
+		close(c.stopCh)
+	})
+}
+
+// Encrypt encrypts the plaintext with the transit key. The ciphertext holds
+// the version of the key, such as "vault:v1:...".
+func (c *transitClient) Encrypt(plaintext []byte) ([]byte, error) {
+	secret, err := c.client.Logical().Write(path.Join(c.mountPath, "encrypt", c.keyName), map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString(plaintext),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, errors.New("no data returned by the transit encrypt operation")
+	}
+
+	ciphertext, ok := secret.Data["ciphertext"].(string)
+	if !ok || ciphertext == "" {
+		return nil, errors.New("no ciphertext returned by the transit encrypt operation")
+	}
+
+	return []byte(ciphertext), nil
+}
+
+// Decrypt decrypts the ciphertext with the transit key
+func (c *transitClient) Decrypt(ciphertext []byte) ([]byte, error) {
+	secret, err := c.client.Logical().Write(path.Join(c.mountPath, "decrypt", c.keyName), map[string]interface{}{
+		"ciphertext": string(ciphertext),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, errors.New("no data returned by the transit decrypt operation")
+	}
+
+	plaintextB64, ok := secret.Data["plaintext"].(string)
+	if !ok {
+		return nil, errors.New("no plaintext returned by the transit decrypt operation")
+	}
+
+	return base64.StdEncoding.DecodeString(plaintextB64)
+}
@@ -0,0 +1,133 @@
+package transit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/hashicorp/errwrap"
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/physical"
+	"github.com/hashicorp/vault/vault/seal"
+)
+
+// TransitSeal is a seal that encrypts and decrypts data with a key of the
+// transit secrets engine of another Vault cluster
+type TransitSeal struct {
+	logger log.Logger
+	client transitClientEncryptor
+
+	currentKeyID *atomic.Value
+}
+
+// Ensure that we are implementing AutoSealAccess
+var _ seal.Access = (*TransitSeal)(nil)
+
+// NewSeal creates a new transit seal with the provided logger
+func NewSeal(logger log.Logger) *TransitSeal {
+	s := &TransitSeal{
+		logger:       logger,
+		currentKeyID: new(atomic.Value),
+	}
+	s.currentKeyID.Store("")
+	return s
+}
+
+// SetConfig sets the fields on the TransitSeal object based on values from
+// the config parameter. Environment variables take precedence over the
+// configuration for the mount path and key name.
+func (s *TransitSeal) SetConfig(config map[string]string) (map[string]string, error) {
+	client, sealInfo, err := newTransitClient(s.logger, config)
+	if err != nil {
+		return nil, err
+	}
+	s.client = client
+
+	// Encrypt a value to test the client and to set the current key ID
+	if _, err := s.Encrypt(context.Background(), []byte("a")); err != nil {
+		client.Close()
+		return nil, errwrap.Wrapf("error testing the transit seal key: {{err}}", err)
+	}
+
+	return sealInfo, nil
+}
+
+// Init is called during core.Initialize. No-op at the moment.
+func (s *TransitSeal) Init(_ context.Context) error {
+	return nil
+}
+
+// Finalize is called during shutdown and stops the token renewal.
+func (s *TransitSeal) Finalize(_ context.Context) error {
+	if s.client != nil {
+		s.client.Close()
+	}
+	return nil
+}
+
+// SealType returns the seal type for this particular seal implementation.
+func (s *TransitSeal) SealType() string {
+	return seal.Transit
+}
+
+// KeyID returns the version of the transit key used for the last
+// encryption, such as "v1".
+func (s *TransitSeal) KeyID() string {
+	return s.currentKeyID.Load().(string)
+}
+
+// Encrypt is used to encrypt the master key using the transit key.
+func (s *TransitSeal) Encrypt(_ context.Context, plaintext []byte) (*physical.EncryptedBlobInfo, error) {
+	if plaintext == nil {
+		return nil, fmt.Errorf("given plaintext for encryption is nil")
+	}
+
+	if s.client == nil {
+		return nil, fmt.Errorf("nil client")
+	}
+
+	ciphertext, err := s.client.Encrypt(plaintext)
+	if err != nil {
+		return nil, errwrap.Wrapf("error encrypting data: {{err}}", err)
+	}
+
+	// Store the version of the transit key, which is part of the ciphertext
+	splitKey := strings.SplitN(string(ciphertext), ":", 3)
+	if len(splitKey) != 3 {
+		return nil, fmt.Errorf("invalid ciphertext returned by the transit encrypt operation")
+	}
+	keyID := splitKey[1]
+	s.currentKeyID.Store(keyID)
+
+	ret := &physical.EncryptedBlobInfo{
+		Ciphertext: ciphertext,
+		KeyInfo: &physical.SealKeyInfo{
+			// The key version is also part of the ciphertext and used by
+			// transit for decryption, store it to know the specific key
+			// version used in encryption in case we want to rewrap older
+			// entries
+			KeyID: keyID,
+		},
+	}
+
+	return ret, nil
+}
+
+// Decrypt is used to decrypt the ciphertext. This should be called after Init.
+func (s *TransitSeal) Decrypt(_ context.Context, in *physical.EncryptedBlobInfo) ([]byte, error) {
+	if in == nil {
+		return nil, fmt.Errorf("given input for decryption is nil")
+	}
+
+	if s.client == nil {
+		return nil, fmt.Errorf("nil client")
+	}
+
+	plaintext, err := s.client.Decrypt(in.Ciphertext)
+	if err != nil {
+		return nil, errwrap.Wrapf("error decrypting data: {{err}}", err)
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,215 @@
+package transit
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/builtin/credential/approle"
+	transitbackend "github.com/hashicorp/vault/builtin/logical/transit"
+	"github.com/hashicorp/vault/helper/logging"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/vault"
+)
+
+type testTransitClient struct {
+	keyVersion int
+	closed     bool
+}
+
+func (m *testTransitClient) Close() {
+	m.closed = true
+}
+
+func (m *testTransitClient) Encrypt(plaintext []byte) ([]byte, error) {
+	encoded := base64.StdEncoding.EncodeToString(plaintext)
+	return []byte(fmt.Sprintf("vault:v%d:%s", m.keyVersion, encoded)), nil
+}
+
+func (m *testTransitClient) Decrypt(ciphertext []byte) ([]byte, error) {
+	splitKey := strings.SplitN(string(ciphertext), ":", 3)
+	if len(splitKey) != 3 {
+		return nil, fmt.Errorf("invalid ciphertext returned")
+	}
+	return base64.StdEncoding.DecodeString(splitKey[2])
+}
+
+func TestTransitSeal_Lifecycle(t *testing.T) {
+	s := NewSeal(logging.NewVaultLogger(log.Trace))
+	client := &testTransitClient{keyVersion: 1}
+	s.client = client
+
+	input := []byte("foo")
+	swi, err := s.Encrypt(context.Background(), input)
+	if err != nil {
+		t.Fatalf("err: %s", err.Error())
+	}
+	if s.KeyID() != "v1" || swi.KeyInfo.KeyID != "v1" {
+		t.Fatalf("bad: key ID %q, blob key ID %q", s.KeyID(), swi.KeyInfo.KeyID)
+	}
+
+	// Rotating the transit key changes the key ID
+	client.keyVersion = 2
+	if _, err := s.Encrypt(context.Background(), input); err != nil {
+		t.Fatalf("err: %s", err.Error())
+	}
+	if s.KeyID() != "v2" {
+		t.Fatalf("bad: key ID %q", s.KeyID())
+	}
+
+	pt, err := s.Decrypt(context.Background(), swi)
+	if err != nil {
+		t.Fatalf("err: %s", err.Error())
+	}
+	if !reflect.DeepEqual(input, pt) {
+		t.Fatalf("expected %s, got %s", input, pt)
+	}
+
+	if err := s.Finalize(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !client.closed {
+		t.Fatal("expected the client to be closed")
+	}
+}
+
+func TestTransitSeal_SetConfig(t *testing.T) {
+	coreConfig := &vault.CoreConfig{
+		LogicalBackends: map[string]logical.Factory{
+			"transit": transitbackend.Factory,
+		},
+		CredentialBackends: map[string]logical.Factory{
+			"approle": approle.Factory,
+		},
+	}
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+		NumCores:    1,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	core := cluster.Cores[0]
+	vault.TestWaitActive(t, core.Core)
+	client := core.Client
+
+	if err := client.Sys().Mount("transit-seal", &api.MountInput{
+		Type: "transit",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Logical().Write("transit-seal/keys/unseal", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Sys().PutPolicy("unseal", `
+path "transit-seal/encrypt/unseal" {
+	capabilities = ["update"]
+}
+path "transit-seal/decrypt/unseal" {
+	capabilities = ["update"]
+}`); err != nil {
+		t.Fatal(err)
+	}
+
+	secret, err := client.Auth().Token().Create(&api.TokenCreateRequest{
+		Policies: []string{"unseal"},
+		TTL:      "1h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := map[string]string{
+		"address":     client.Address(),
+		"tls_ca_cert": cluster.CACertPEMFile,
+		"mount_path":  "transit-seal",
+		"key_name":    "unseal",
+	}
+
+	// A token or AppRole credentials are required
+	s := NewSeal(logging.NewVaultLogger(log.Trace))
+	if _, err := s.SetConfig(config); err == nil {
+		t.Fatal("expected error without credentials")
+	}
+
+	config["token"] = secret.Auth.ClientToken
+	sealInfo, err := s.SetConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Finalize(context.Background())
+	if sealInfo["mount_path"] != "transit-seal" || sealInfo["key_name"] != "unseal" {
+		t.Fatalf("bad: %#v", sealInfo)
+	}
+	if s.KeyID() != "v1" {
+		t.Fatalf("bad: key ID %q", s.KeyID())
+	}
+
+	input := []byte("foo")
+	swi, err := s.Encrypt(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotate the transit key, older blobs can still be decrypted
+	if _, err := client.Logical().Write("transit-seal/keys/unseal/rotate", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Encrypt(context.Background(), input); err != nil {
+		t.Fatal(err)
+	}
+	if s.KeyID() != "v2" {
+		t.Fatalf("bad: key ID %q", s.KeyID())
+	}
+	pt, err := s.Decrypt(context.Background(), swi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(input, pt) {
+		t.Fatalf("expected %s, got %s", input, pt)
+	}
+
+	// Log in with AppRole instead of a token
+	if err := client.Sys().EnableAuthWithOptions("approle", &api.EnableAuthOptions{
+		Type: "approle",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Logical().Write("auth/approle/role/unseal", map[string]interface{}{
+		"policies":  "unseal",
+		"token_ttl": "1h",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	roleID, err := client.Logical().Read("auth/approle/role/unseal/role-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secretID, err := client.Logical().Write("auth/approle/role/unseal/secret-id", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delete(config, "token")
+	config["role_id"] = roleID.Data["role_id"].(string)
+	config["secret_id"] = secretID.Data["secret_id"].(string)
+	approleSeal := NewSeal(logging.NewVaultLogger(log.Trace))
+	if _, err := approleSeal.SetConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	defer approleSeal.Finalize(context.Background())
+
+	pt, err = approleSeal.Decrypt(context.Background(), swi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(input, pt) {
+		t.Fatalf("expected %s, got %s", input, pt)
+	}
+}
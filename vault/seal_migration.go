@@ -0,0 +1,713 @@
+package vault
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/errwrap"
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/consts"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
+	"github.com/hashicorp/vault/shamir"
+	"github.com/hashicorp/vault/vault/seal"
+)
+
+const (
+	// coreSealMigrationPath is the path used to store the last online seal
+	// migration, so that standbys switch to the new seal before becoming
+	// active. This is inside the barrier as the seal configuration may hold
+	// credentials.
+	coreSealMigrationPath = "core/seal-migration"
+)
+
+const (
+	// SealMigrationStateMigrating is the state of an online seal migration
+	// while the master key and recovery key are moved to the new seal
+	SealMigrationStateMigrating = "migrating"
+
+	// SealMigrationStateRewrapping is the state of an online seal migration
+	// while the seal wrapped storage entries are rewrapped with the new seal
+	SealMigrationStateRewrapping = "rewrapping"
+
+	// SealMigrationStateComplete is the state of a completed online seal
+	// migration
+	SealMigrationStateComplete = "complete"
+
+	// SealMigrationStateFailed is the state of a failed online seal
+	// migration
+	SealMigrationStateFailed = "failed"
+)
+
+// sealMigrationPaths are the storage entries outside of the barrier that an
+// online seal migration changes, which are restored on rollback
+var sealMigrationPaths = []string{
+	barrierSealConfigPath,
+	recoverySealConfigPlaintextPath,
+	recoveryKeyPath,
+	StoredBarrierKeysPath,
+}
+
+// SealFactory creates a seal of the given type from its configuration, as
+// found in the seal stanza of the server configuration.
+type SealFactory func(sealType string, config map[string]string) (Seal, error)
+
+// SealMigrationConfig is an online seal migration attempt waiting for key
+// shares
+type SealMigrationConfig struct {
+	Type     string
+	Config   map[string]string
+	Nonce    string
+	Progress [][]byte
+
+	seal Seal
+}
+
+// SealMigrationStatus is the state of an online seal migration once enough
+// key shares were provided
+type SealMigrationStatus struct {
+	ID               string
+	Type             string
+	State            string
+	Error            string
+	EntriesScanned   int
+	EntriesRewrapped int
+	EntriesFailed    int
+}
+
+// sealMigrationEntry is the last online seal migration, stored at
+// coreSealMigrationPath
+type sealMigrationEntry struct {
+	ID        string            `json:"id"`
+	Type      string            `json:"type"`
+	Config    map[string]string `json:"config"`
+	Rewrapped bool              `json:"rewrapped"`
+}
+
+// SealMigrationThreshold returns the number of key shares required to
+// migrate the seal: unseal key shares with Shamir, recovery key shares
+// otherwise.
+func (c *Core) SealMigrationThreshold(ctx context.Context) (int, error) {
+	var config *SealConfig
+	var err error
+	if c.seal.RecoveryKeySupported() {
+		config, err = c.seal.RecoveryConfig(ctx)
+	} else {
+		config, err = c.seal.BarrierConfig(ctx)
+	}
+	if err != nil {
+		return 0, errwrap.Wrapf("failed to fetch existing config: {{err}}", err)
+	}
+	if config == nil {
+		return 0, ErrNotInit
+	}
+	return config.SecretThreshold, nil
+}
+
+// SealMigrationConfig returns the online seal migration attempt waiting for
+// key shares, if any
+func (c *Core) SealMigrationConfig() *SealMigrationConfig {
+	c.sealMigrationLock.Lock()
+	defer c.sealMigrationLock.Unlock()
+
+	if c.sealMigrationConfig == nil {
+		return nil
+	}
+	return &SealMigrationConfig{
+		Type:     c.sealMigrationConfig.Type,
+		Nonce:    c.sealMigrationConfig.Nonce,
+		Progress: make([][]byte, len(c.sealMigrationConfig.Progress)),
+	}
+}
+
+// SealMigrationStatus returns the state of the last online seal migration
+// performed by this node, if any
+func (c *Core) SealMigrationStatus() *SealMigrationStatus {
+	c.sealMigrationLock.Lock()
+	defer c.sealMigrationLock.Unlock()
+
+	if c.sealMigrationStatus == nil {
+		return nil
+	}
+	status := *c.sealMigrationStatus
+	return &status
+}
+
+// SealMigrationInit starts an online seal migration to the seal of the given
+// type and configuration. The seal is migrated once enough key shares are
+// provided with SealMigrationUpdate.
+func (c *Core) SealMigrationInit(ctx context.Context, sealType string, config map[string]string) (*SealMigrationConfig, logical.HTTPCodedError) {
+	if c.Sealed() {
+		return nil, logical.CodedError(http.StatusServiceUnavailable, consts.ErrSealed.Error())
+	}
+	if c.standby {
+		return nil, logical.CodedError(http.StatusBadRequest, consts.ErrStandby.Error())
+	}
+	if c.sealFactory == nil {
+		return nil, logical.CodedError(http.StatusBadRequest, "online seal migration is not supported by this server")
+	}
+	if sealType == "" {
+		return nil, logical.CodedError(http.StatusBadRequest, "seal type is required")
+	}
+	if sealType == seal.Shamir {
+		return nil, logical.CodedError(http.StatusBadRequest, "migrating to the shamir seal is not supported online")
+	}
+
+	c.sealMigrationLock.Lock()
+	defer c.sealMigrationLock.Unlock()
+
+	if c.sealMigrationConfig != nil {
+		return nil, logical.CodedError(http.StatusBadRequest, "seal migration already in progress")
+	}
+	if c.sealMigrationStatus != nil && c.sealMigrationStatus.State == SealMigrationStateMigrating {
+		return nil, logical.CodedError(http.StatusBadRequest, "seal migration already in progress")
+	}
+	if c.previousSeal != nil {
+		return nil, logical.CodedError(http.StatusBadRequest, "the storage entries seal wrapped by the previous seal have not all been rewrapped")
+	}
+
+	newSeal, err := c.sealFactory(sealType, config)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusBadRequest, errwrap.Wrapf("failed to configure seal: {{err}}", err).Error())
+	}
+	if !newSeal.StoredKeysSupported() || !newSeal.RecoveryKeySupported() {
+		newSeal.Finalize(ctx)
+		return nil, logical.CodedError(http.StatusBadRequest, fmt.Sprintf("seal type %q does not support stored keys and recovery keys", sealType))
+	}
+	newSeal.SetCore(c)
+	if err := newSeal.Init(ctx); err != nil {
+		newSeal.Finalize(ctx)
+		return nil, logical.CodedError(http.StatusInternalServerError, errwrap.Wrapf("failed to initialize seal: {{err}}", err).Error())
+	}
+
+	nonce, err := uuid.GenerateUUID()
+	if err != nil {
+		newSeal.Finalize(ctx)
+		return nil, logical.CodedError(http.StatusInternalServerError, errwrap.Wrapf("error generating nonce for procedure: {{err}}", err).Error())
+	}
+
+	c.sealMigrationConfig = &SealMigrationConfig{
+		Type:   sealType,
+		Config: config,
+		Nonce:  nonce,
+		seal:   newSeal,
+	}
+
+	if c.logger.IsInfo() {
+		c.logger.Info("seal migration initialized", "nonce", nonce, "type", sealType)
+	}
+	return &SealMigrationConfig{
+		Type:  sealType,
+		Nonce: nonce,
+	}, nil
+}
+
+// SealMigrationUpdate is used to provide a key share for the online seal
+// migration in progress. Once the threshold is met, the seal is migrated in
+// the background and the returned status tracks it.
+func (c *Core) SealMigrationUpdate(ctx context.Context, key []byte, nonce string) (*SealMigrationStatus, logical.HTTPCodedError) {
+	if c.Sealed() {
+		return nil, logical.CodedError(http.StatusServiceUnavailable, consts.ErrSealed.Error())
+	}
+	if c.standby {
+		return nil, logical.CodedError(http.StatusBadRequest, consts.ErrStandby.Error())
+	}
+
+	// Verify the key length
+	min, max := c.barrier.KeyLength()
+	max += shamir.ShareOverhead
+	if len(key) < min {
+		return nil, logical.CodedError(http.StatusBadRequest, fmt.Sprintf("key is shorter than minimum %d bytes", min))
+	}
+	if len(key) > max {
+		return nil, logical.CodedError(http.StatusBadRequest, fmt.Sprintf("key is longer than maximum %d bytes", max))
+	}
+
+	threshold, err := c.SealMigrationThreshold(ctx)
+	if err != nil {
+		return nil, logical.CodedError(http.StatusInternalServerError, err.Error())
+	}
+
+	c.sealMigrationLock.Lock()
+	defer c.sealMigrationLock.Unlock()
+
+	config := c.sealMigrationConfig
+	if config == nil {
+		return nil, logical.CodedError(http.StatusBadRequest, "no seal migration in progress")
+	}
+	if nonce != config.Nonce {
+		return nil, logical.CodedError(http.StatusBadRequest, fmt.Sprintf("incorrect nonce supplied; nonce for this seal migration is %q", config.Nonce))
+	}
+
+	// Check if we already have this piece
+	for _, existing := range config.Progress {
+		if subtle.ConstantTimeCompare(existing, key) == 1 {
+			return nil, logical.CodedError(http.StatusBadRequest, "given key has already been provided during this seal migration")
+		}
+	}
+
+	// Store this key
+	config.Progress = append(config.Progress, key)
+
+	// Check if we don't have enough keys to migrate
+	if len(config.Progress) < threshold {
+		if c.logger.IsDebug() {
+			c.logger.Debug("cannot migrate seal yet, not enough keys", "keys", len(config.Progress), "threshold", threshold)
+		}
+		return nil, nil
+	}
+
+	// Recover the master key or recovery key
+	var recoveredKey []byte
+	if threshold == 1 {
+		recoveredKey = make([]byte, len(config.Progress[0]))
+		copy(recoveredKey, config.Progress[0])
+	} else {
+		recoveredKey, err = shamir.Combine(config.Progress)
+	}
+	config.Progress = nil
+	if err != nil {
+		return nil, logical.CodedError(http.StatusInternalServerError, errwrap.Wrapf("failed to compute master key: {{err}}", err).Error())
+	}
+
+	if c.seal.RecoveryKeySupported() {
+		if err := c.seal.VerifyRecoveryKey(ctx, recoveredKey); err != nil {
+			c.logger.Error("seal migration recovery key verification failed", "error", err)
+			return nil, logical.CodedError(http.StatusBadRequest, errwrap.Wrapf("recovery key verification failed: {{err}}", err).Error())
+		}
+	} else {
+		if err := c.barrier.VerifyMaster(recoveredKey); err != nil {
+			c.logger.Error("seal migration master key verification failed", "error", err)
+			return nil, logical.CodedError(http.StatusBadRequest, errwrap.Wrapf("master key verification failed: {{err}}", err).Error())
+		}
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, logical.CodedError(http.StatusInternalServerError, errwrap.Wrapf("error generating seal migration ID: {{err}}", err).Error())
+	}
+
+	c.sealMigrationConfig = nil
+	c.sealMigrationStatus = &SealMigrationStatus{
+		ID:    id,
+		Type:  config.Type,
+		State: SealMigrationStateMigrating,
+	}
+	status := *c.sealMigrationStatus
+
+	// The migration needs the state lock, which is held by the request
+	// calling this, so it runs in the background
+	go c.migrateSeal(c.activeContext, config, id, recoveredKey)
+
+	return &status, nil
+}
+
+// SealMigrationCancel cancels the online seal migration attempt waiting for
+// key shares
+func (c *Core) SealMigrationCancel(ctx context.Context) logical.HTTPCodedError {
+	if c.Sealed() {
+		return logical.CodedError(http.StatusServiceUnavailable, consts.ErrSealed.Error())
+	}
+	if c.standby {
+		return logical.CodedError(http.StatusBadRequest, consts.ErrStandby.Error())
+	}
+
+	c.sealMigrationLock.Lock()
+	defer c.sealMigrationLock.Unlock()
+
+	c.clearSealMigrationConfig(ctx)
+	return nil
+}
+
+// clearSealMigrationConfig clears the online seal migration attempt waiting
+// for key shares. It must be called with the sealMigrationLock held.
+func (c *Core) clearSealMigrationConfig(ctx context.Context) {
+	if c.sealMigrationConfig == nil {
+		return
+	}
+	if err := c.sealMigrationConfig.seal.Finalize(ctx); err != nil {
+		c.logger.Warn("error finalizing seal of canceled seal migration", "error", err)
+	}
+	c.sealMigrationConfig = nil
+}
+
+// migrateSeal migrates the seal and then rewraps the storage entries seal
+// wrapped by the previous seal
+func (c *Core) migrateSeal(ctx context.Context, config *SealMigrationConfig, id string, key []byte) {
+	defer memzero(key)
+
+	err := c.performSealMigration(ctx, config, id, key)
+
+	c.sealMigrationLock.Lock()
+	status := c.sealMigrationStatus
+	if err != nil {
+		status.State = SealMigrationStateFailed
+		status.Error = err.Error()
+		c.sealMigrationLock.Unlock()
+
+		c.logger.Error("seal migration failed", "error", err)
+		if err := config.seal.Finalize(ctx); err != nil {
+			c.logger.Warn("error finalizing seal of failed seal migration", "error", err)
+		}
+		return
+	}
+	status.State = SealMigrationStateRewrapping
+	c.sealMigrationLock.Unlock()
+
+	c.rewrapSealWrapped(ctx, status)
+}
+
+// performSealMigration moves the recovery key and a new master key to the
+// seal being migrated to, and rekeys the barrier with the new master key.
+// The previous seal configuration, keys and master key are restored if any
+// step fails.
+func (c *Core) performSealMigration(ctx context.Context, config *SealMigrationConfig, id string, key []byte) (retErr error) {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	if c.Sealed() {
+		return consts.ErrSealed
+	}
+	if c.standby || ctx.Err() != nil {
+		return errors.New("node is no longer active")
+	}
+
+	oldSeal := c.seal
+	newSeal := config.seal
+
+	barrierConfig, err := oldSeal.BarrierConfig(ctx)
+	if err != nil {
+		return errwrap.Wrapf("failed to fetch barrier config: {{err}}", err)
+	}
+	var recoveryConfig *SealConfig
+	if oldSeal.RecoveryKeySupported() {
+		recoveryConfig, err = oldSeal.RecoveryConfig(ctx)
+		if err != nil {
+			return errwrap.Wrapf("failed to fetch recovery config: {{err}}", err)
+		}
+	} else {
+		// The unseal keys become the recovery keys
+		recoveryConfig = barrierConfig.Clone()
+		recoveryConfig.StoredShares = 0
+		recoveryConfig.PGPKeys = nil
+		recoveryConfig.Backup = false
+	}
+
+	keyring, err := c.barrier.Keyring()
+	if err != nil {
+		return errwrap.Wrapf("failed to fetch keyring: {{err}}", err)
+	}
+	oldMasterKey := make([]byte, len(keyring.MasterKey()))
+	copy(oldMasterKey, keyring.MasterKey())
+	defer memzero(oldMasterKey)
+
+	// Keep what the migration overwrites for rollback
+	snapshot := make(map[string]*physical.Entry, len(sealMigrationPaths))
+	for _, path := range sealMigrationPaths {
+		pe, err := c.physical.Get(ctx, path)
+		if err != nil {
+			return errwrap.Wrapf(fmt.Sprintf("failed to read %q: {{err}}", path), err)
+		}
+		snapshot[path] = pe
+	}
+
+	var rekeyed bool
+	defer func() {
+		if retErr == nil {
+			return
+		}
+
+		c.logger.Error("seal migration failed, rolling back", "error", retErr)
+		c.seal = oldSeal
+		c.previousSeal = nil
+		setSealWrap(c)
+
+		var result *multierror.Error
+		if rekeyed {
+			if err := c.barrier.Rekey(ctx, oldMasterKey); err != nil {
+				result = multierror.Append(result, errwrap.Wrapf("failed to rekey barrier: {{err}}", err))
+			}
+		}
+		for _, path := range sealMigrationPaths {
+			var err error
+			if pe := snapshot[path]; pe != nil {
+				err = c.physical.Put(ctx, pe)
+			} else {
+				err = c.physical.Delete(ctx, path)
+			}
+			if err != nil {
+				result = multierror.Append(result, errwrap.Wrapf(fmt.Sprintf("failed to restore %q: {{err}}", path), err))
+			}
+		}
+		oldSeal.SetCachedBarrierConfig(nil)
+		if oldSeal.RecoveryKeySupported() {
+			oldSeal.SetCachedRecoveryConfig(nil)
+		}
+
+		if err := result.ErrorOrNil(); err != nil {
+			retErr = fmt.Errorf("%v; rollback failed: %v", retErr, err)
+			return
+		}
+		c.logger.Info("seal migration rolled back")
+	}()
+
+	// The recovery key is the master key when migrating from Shamir, and is
+	// unchanged otherwise
+	if err := newSeal.SetRecoveryConfig(ctx, recoveryConfig); err != nil {
+		return errwrap.Wrapf("error setting new recovery config: {{err}}", err)
+	}
+	if err := newSeal.SetRecoveryKey(ctx, key); err != nil {
+		return errwrap.Wrapf("error setting new recovery key information: {{err}}", err)
+	}
+
+	newMasterKey, err := c.barrier.GenerateKey()
+	if err != nil {
+		return errwrap.Wrapf("error generating new master key: {{err}}", err)
+	}
+	defer memzero(newMasterKey)
+	if err := newSeal.SetStoredKeys(ctx, [][]byte{newMasterKey}); err != nil {
+		return errwrap.Wrapf("error storing new master key: {{err}}", err)
+	}
+
+	// Switch seals before the rekey so that the keyring is seal wrapped with
+	// the new seal, keeping the previous one to unwrap what it wrapped
+	c.seal = newSeal
+	c.previousSeal = oldSeal
+	setSealWrap(c)
+
+	rekeyed = true
+	if err := c.barrier.Rekey(ctx, newMasterKey); err != nil {
+		return errwrap.Wrapf("error rekeying barrier during migration: {{err}}", err)
+	}
+
+	if err := newSeal.SetBarrierConfig(ctx, &SealConfig{
+		Type:            newSeal.BarrierType(),
+		SecretShares:    1,
+		SecretThreshold: 1,
+		StoredShares:    1,
+	}); err != nil {
+		return errwrap.Wrapf("error storing barrier config after migration: {{err}}", err)
+	}
+
+	// Write to the canary path, which will force a synchronous truing during
+	// replication
+	if err := c.barrier.Put(ctx, &Entry{
+		Key:   coreKeyringCanaryPath,
+		Value: []byte(id),
+	}); err != nil {
+		return errwrap.Wrapf("failed to save keyring canary: {{err}}", err)
+	}
+
+	// This is written last as standbys switch to the new seal when they find
+	// it, so it must not be left behind by a rollback
+	if err := c.putSealMigrationEntry(ctx, &sealMigrationEntry{
+		ID:     id,
+		Type:   config.Type,
+		Config: config.Config,
+	}); err != nil {
+		return err
+	}
+
+	c.sealMigrationID = id
+	if c.logger.IsInfo() {
+		c.logger.Info("seal migrated", "type", config.Type, "id", id)
+	}
+	return nil
+}
+
+// rewrapSealWrapped rewraps with the current seal the storage entries seal
+// wrapped by c.previousSeal, updating the given status as it goes. Once all
+// of them are rewrapped, the previous seal is no longer used.
+func (c *Core) rewrapSealWrapped(ctx context.Context, status *SealMigrationStatus) {
+	err := c.walkPhysical(ctx, "", func(key string) error {
+		updated, err := rewrapStorageEntry(ctx, c, key)
+
+		c.sealMigrationLock.Lock()
+		status.EntriesScanned++
+		switch {
+		case err != nil:
+			status.EntriesFailed++
+		case updated:
+			status.EntriesRewrapped++
+		}
+		c.sealMigrationLock.Unlock()
+
+		if err != nil {
+			c.logger.Error("failed to rewrap storage entry", "key", key, "error", err)
+		}
+		return nil
+	})
+
+	c.sealMigrationLock.Lock()
+	switch {
+	case err != nil:
+		status.Error = errwrap.Wrapf("failed to walk storage: {{err}}", err).Error()
+	case status.EntriesFailed > 0:
+		status.Error = fmt.Sprintf("failed to rewrap %d storage entries", status.EntriesFailed)
+	}
+	c.sealMigrationLock.Unlock()
+	if err != nil || status.EntriesFailed > 0 {
+		c.setSealMigrationState(status, SealMigrationStateFailed)
+		c.logger.Error("seal wrapped storage entries not rewrapped; the previous seal remains in use to unwrap them", "error", status.Error)
+		return
+	}
+
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	if c.Sealed() || c.standby || ctx.Err() != nil {
+		return
+	}
+
+	entry, err := c.getSealMigrationEntry(ctx)
+	if err == nil && entry != nil && entry.ID == status.ID {
+		entry.Rewrapped = true
+		err = c.putSealMigrationEntry(ctx, entry)
+	}
+	if err != nil {
+		c.logger.Error("failed to record rewrapped storage entries", "error", err)
+	}
+
+	c.previousSeal = nil
+	setSealWrap(c)
+	c.setSealMigrationState(status, SealMigrationStateComplete)
+	if c.logger.IsInfo() {
+		c.logger.Info("seal wrapped storage entries rewrapped", "entries", status.EntriesRewrapped)
+	}
+}
+
+func (c *Core) setSealMigrationState(status *SealMigrationStatus, state string) {
+	c.sealMigrationLock.Lock()
+	status.State = state
+	c.sealMigrationLock.Unlock()
+}
+
+// walkPhysical calls the given function with every key of the physical
+// storage under the given prefix
+func (c *Core) walkPhysical(ctx context.Context, prefix string, walkFn func(key string) error) error {
+	keys, err := c.sealUnwrapper.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if strings.HasSuffix(key, "/") {
+			if err := c.walkPhysical(ctx, prefix+key, walkFn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := walkFn(prefix + key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setupSealRewrap resumes rewrapping the storage entries seal wrapped by the
+// previous seal, when this node switched seals while standby
+func (c *Core) setupSealRewrap(ctx context.Context) {
+	if c.previousSeal == nil {
+		return
+	}
+
+	c.sealMigrationLock.Lock()
+	defer c.sealMigrationLock.Unlock()
+	if c.sealMigrationStatus != nil && c.sealMigrationStatus.ID == c.sealMigrationID &&
+		c.sealMigrationStatus.State == SealMigrationStateRewrapping {
+		return
+	}
+
+	c.sealMigrationStatus = &SealMigrationStatus{
+		ID:    c.sealMigrationID,
+		Type:  c.seal.BarrierType(),
+		State: SealMigrationStateRewrapping,
+	}
+	go c.rewrapSealWrapped(ctx, c.sealMigrationStatus)
+}
+
+// loadSealMigrationID records the last online seal migration as the one the
+// seal of this node is up to date with. It is called on unseal, which can
+// only succeed with the current seal.
+func (c *Core) loadSealMigrationID(ctx context.Context) error {
+	entry, err := c.getSealMigrationEntry(ctx)
+	if err != nil {
+		return err
+	}
+	c.sealMigrationID = ""
+	if entry != nil {
+		c.sealMigrationID = entry.ID
+	}
+	return nil
+}
+
+// adoptSealMigration switches to the seal the active node migrated to
+// online since this node was unsealed. It must be called before the master
+// key and keyring, which are seal wrapped, are reloaded.
+func (c *Core) adoptSealMigration(ctx context.Context) error {
+	entry, err := c.getSealMigrationEntry(ctx)
+	if err != nil {
+		return err
+	}
+	if entry == nil || entry.ID == c.sealMigrationID {
+		return nil
+	}
+	if c.sealFactory == nil {
+		return fmt.Errorf("seal was migrated to %q but this server cannot create seals", entry.Type)
+	}
+
+	newSeal, err := c.sealFactory(entry.Type, entry.Config)
+	if err != nil {
+		return errwrap.Wrapf("failed to configure migrated seal: {{err}}", err)
+	}
+	newSeal.SetCore(c)
+	if err := newSeal.Init(ctx); err != nil {
+		newSeal.Finalize(ctx)
+		return errwrap.Wrapf("failed to initialize migrated seal: {{err}}", err)
+	}
+
+	c.logger.Info("switching to the seal migrated to by the previous active node", "type", entry.Type, "id", entry.ID)
+	if !entry.Rewrapped {
+		c.previousSeal = c.seal
+	}
+	c.seal = newSeal
+	c.sealMigrationID = entry.ID
+	setSealWrap(c)
+	return nil
+}
+
+func (c *Core) getSealMigrationEntry(ctx context.Context) (*sealMigrationEntry, error) {
+	entry, err := c.barrier.Get(ctx, coreSealMigrationPath)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to read seal migration: {{err}}", err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var migration sealMigrationEntry
+	if err := json.Unmarshal(entry.Value, &migration); err != nil {
+		return nil, errwrap.Wrapf("failed to decode seal migration: {{err}}", err)
+	}
+	return &migration, nil
+}
+
+func (c *Core) putSealMigrationEntry(ctx context.Context, migration *sealMigrationEntry) error {
+	buf, err := json.Marshal(migration)
+	if err != nil {
+		return errwrap.Wrapf("failed to encode seal migration: {{err}}", err)
+	}
+	if err := c.barrier.Put(ctx, &Entry{
+		Key:   coreSealMigrationPath,
+		Value: buf,
+	}); err != nil {
+		return errwrap.Wrapf("failed to store seal migration: {{err}}", err)
+	}
+	return nil
+}
@@ -0,0 +1,451 @@
+package vault
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	proto "github.com/golang/protobuf/proto"
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/helper/logging"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/physical"
+	"github.com/hashicorp/vault/physical/inmem"
+	"github.com/hashicorp/vault/shamir"
+	"github.com/hashicorp/vault/vault/seal"
+)
+
+// testMigrationAccess is a test seal access that only decrypts what it
+// encrypted, and fails encrypting once failAfter encryptions were done
+type testMigrationAccess struct {
+	*seal.TestSeal
+	keyID     string
+	failAfter int32
+	encrypts  int32
+}
+
+func (a *testMigrationAccess) KeyID() string {
+	return a.keyID
+}
+
+func (a *testMigrationAccess) Encrypt(ctx context.Context, plaintext []byte) (*physical.EncryptedBlobInfo, error) {
+	if n := atomic.AddInt32(&a.encrypts, 1); a.failAfter > 0 && n > a.failAfter {
+		return nil, errors.New("encryption failed")
+	}
+	ret, err := a.TestSeal.Encrypt(ctx, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	ret.KeyInfo = &physical.SealKeyInfo{
+		KeyID: a.keyID,
+	}
+	return ret, nil
+}
+
+func (a *testMigrationAccess) Decrypt(ctx context.Context, in *physical.EncryptedBlobInfo) ([]byte, error) {
+	if in.KeyInfo == nil || in.KeyInfo.KeyID != a.keyID {
+		return nil, errors.New("wrong key")
+	}
+	return a.TestSeal.Decrypt(ctx, in)
+}
+
+func testSealMigrationFactory(access **testMigrationAccess) SealFactory {
+	return func(sealType string, config map[string]string) (Seal, error) {
+		a := &testMigrationAccess{
+			TestSeal: seal.NewTestSeal(logging.NewVaultLogger(log.Trace)),
+			keyID:    config["key_id"],
+		}
+		if access != nil {
+			*access = a
+		}
+		return NewAutoSeal(a), nil
+	}
+}
+
+func testSealMigrate(t *testing.T, core *Core, root string, keys [][]byte, config map[string]string) *SealMigrationStatus {
+	t.Helper()
+	ctx := namespace.RootContext(nil)
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/seal/migrate")
+	req.Data["type"] = seal.Test
+	req.Data["config"] = config
+	req.ClientToken = root
+	resp, err := core.HandleRequest(ctx, req)
+	if err != nil || resp.IsError() {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+	if resp.Data["required"] != len(keys) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	nonce := resp.Data["nonce"].(string)
+
+	for i, key := range keys {
+		req = logical.TestRequest(t, logical.UpdateOperation, "sys/seal/migrate/update")
+		req.Data["key"] = hex.EncodeToString(key)
+		req.Data["nonce"] = nonce
+		req.ClientToken = root
+		resp, err = core.HandleRequest(ctx, req)
+		if err != nil || resp.IsError() {
+			t.Fatalf("err: %v, resp: %#v", err, resp)
+		}
+		if complete := resp.Data["complete"].(bool); complete != (i == len(keys)-1) {
+			t.Fatalf("bad: %#v", resp.Data)
+		}
+	}
+
+	// Wait for the background migration
+	for i := 0; i < 50; i++ {
+		status := core.SealMigrationStatus()
+		if status.State == SealMigrationStateComplete || status.State == SealMigrationStateFailed {
+			return status
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatal("seal migration did not finish")
+	return nil
+}
+
+func testSealWrappedKeyID(t *testing.T, core *Core, key string) string {
+	t.Helper()
+	entry, err := core.underlyingPhysical.Get(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry == nil {
+		t.Fatalf("entry %q not found", key)
+	}
+	eLen := len(entry.Value)
+	if eLen == 0 || entry.Value[eLen-1] != 's' {
+		return ""
+	}
+	se := &physical.EncryptedBlobInfo{}
+	if err := proto.Unmarshal(entry.Value[:eLen-1], se); err != nil || !se.Wrapped || se.KeyInfo == nil {
+		return ""
+	}
+	return se.KeyInfo.KeyID
+}
+
+func TestCore_SealMigration_ShamirToAuto(t *testing.T) {
+	ctx := namespace.RootContext(nil)
+	core := TestCoreWithSealAndUI(t, &CoreConfig{
+		SealFactory: testSealMigrationFactory(nil),
+	})
+	keys, root := TestCoreInit(t, core)
+	for _, key := range keys {
+		if _, err := TestCoreUnseal(core, TestKeyCopy(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Shares are verified before anything is migrated
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/seal/migrate")
+	req.Data["type"] = seal.Test
+	req.ClientToken = root
+	resp, err := core.HandleRequest(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := resp.Data["nonce"].(string)
+	badKeys, err := shamir.Split(make([]byte, 32), len(keys), len(keys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range badKeys {
+		req = logical.TestRequest(t, logical.UpdateOperation, "sys/seal/migrate/update")
+		req.Data["key"] = hex.EncodeToString(key)
+		req.Data["nonce"] = nonce
+		req.ClientToken = root
+		resp, err = core.HandleRequest(ctx, req)
+	}
+	if err == nil {
+		t.Fatal("expected error for bad shares")
+	}
+	if core.SealMigrationStatus() != nil || core.seal.BarrierType() != seal.Shamir {
+		t.Fatal("expected the seal not to be migrated")
+	}
+	req = logical.TestRequest(t, logical.DeleteOperation, "sys/seal/migrate")
+	req.ClientToken = root
+	if _, err := core.HandleRequest(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	status := testSealMigrate(t, core, root, keys, map[string]string{"key_id": "new"})
+	if status.State != SealMigrationStateComplete || status.Type != seal.Test {
+		t.Fatalf("bad: %#v", status)
+	}
+	if core.seal.BarrierType() != seal.Test || !core.seal.RecoveryKeySupported() {
+		t.Fatalf("bad: seal type %q", core.seal.BarrierType())
+	}
+	if keyID := testSealWrappedKeyID(t, core, keyringPath); keyID != "new" {
+		t.Fatalf("bad: keyring wrapped with %q", keyID)
+	}
+
+	// The unseal keys became the recovery keys
+	recoveryConfig, err := core.seal.RecoveryConfig(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recoveryConfig.SecretThreshold != len(keys) {
+		t.Fatalf("bad: %#v", recoveryConfig)
+	}
+	masterKey, err := shamir.Combine(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := core.seal.VerifyRecoveryKey(ctx, masterKey); err != nil {
+		t.Fatal(err)
+	}
+
+	// Vault now unseals with the stored master key
+	if err := core.Seal(root); err != nil {
+		t.Fatal(err)
+	}
+	if err := core.UnsealWithStoredKeys(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if core.Sealed() {
+		t.Fatal("should not be sealed")
+	}
+}
+
+func TestCore_SealMigration_AutoToAuto(t *testing.T) {
+	ctx := namespace.RootContext(nil)
+	var oldAccess, newAccess *testMigrationAccess
+	oldSeal, _ := testSealMigrationFactory(&oldAccess)(seal.Test, map[string]string{"key_id": "old"})
+	core := TestCoreWithSealAndUI(t, &CoreConfig{
+		Seal:        oldSeal,
+		SealFactory: testSealMigrationFactory(&newAccess),
+	})
+	_, recoveryKeys, root := TestCoreInitClusterWrapperSetup(t, core, nil, nil)
+	if err := core.UnsealWithStoredKeys(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// An entry seal wrapped with the old seal
+	if err := core.physical.Put(ctx, &physical.Entry{
+		Key:      "foo",
+		Value:    []byte("bar"),
+		SealWrap: true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if keyID := testSealWrappedKeyID(t, core, "foo"); keyID != "old" {
+		t.Fatalf("bad: entry wrapped with %q", keyID)
+	}
+
+	status := testSealMigrate(t, core, root, recoveryKeys, map[string]string{"key_id": "new"})
+	if status.State != SealMigrationStateComplete || status.EntriesRewrapped < 1 || status.EntriesFailed != 0 {
+		t.Fatalf("bad: %#v", status)
+	}
+	for _, key := range []string{keyringPath, "foo"} {
+		if keyID := testSealWrappedKeyID(t, core, key); keyID != "new" {
+			t.Fatalf("bad: %q wrapped with %q", key, keyID)
+		}
+	}
+	if core.previousSeal != nil {
+		t.Fatal("expected the previous seal to be dropped")
+	}
+
+	// The recovery key is unchanged
+	recoveryKey, err := shamir.Combine(recoveryKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := core.seal.VerifyRecoveryKey(ctx, recoveryKey); err != nil {
+		t.Fatal(err)
+	}
+
+	// The old seal can no longer unseal Vault
+	if err := core.Seal(root); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := oldSeal.GetStoredKeys(ctx)
+	if err == nil && len(keys) > 0 {
+		t.Fatal("expected the old seal not to decrypt the stored keys")
+	}
+	if err := core.UnsealWithStoredKeys(ctx); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := core.physical.Get(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry == nil || string(entry.Value) != "bar" {
+		t.Fatalf("bad: %#v", entry)
+	}
+}
+
+func TestCore_SealMigration_Rollback(t *testing.T) {
+	ctx := namespace.RootContext(nil)
+	var access *testMigrationAccess
+	factory := testSealMigrationFactory(&access)
+	core := TestCoreWithSealAndUI(t, &CoreConfig{
+		SealFactory: func(sealType string, config map[string]string) (Seal, error) {
+			s, err := factory(sealType, config)
+			// Fail seal wrapping the keyring during the rekey, after the
+			// recovery key and the master key were stored
+			access.failAfter = 2
+			return s, err
+		},
+	})
+	keys, root := TestCoreInit(t, core)
+	for _, key := range keys {
+		if _, err := TestCoreUnseal(core, TestKeyCopy(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	status := testSealMigrate(t, core, root, keys, map[string]string{"key_id": "new"})
+	if status.State != SealMigrationStateFailed || status.Error == "" {
+		t.Fatalf("bad: %#v", status)
+	}
+	if core.seal.BarrierType() != seal.Shamir || core.previousSeal != nil {
+		t.Fatalf("bad: seal type %q", core.seal.BarrierType())
+	}
+	if keyID := testSealWrappedKeyID(t, core, keyringPath); keyID != "" {
+		t.Fatalf("bad: keyring wrapped with %q", keyID)
+	}
+	for _, path := range []string{recoverySealConfigPlaintextPath, recoveryKeyPath, StoredBarrierKeysPath} {
+		entry, err := core.physical.Get(ctx, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry != nil {
+			t.Fatalf("expected %q to be removed", path)
+		}
+	}
+
+	// Vault still unseals with the unseal keys
+	if err := core.Seal(root); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if _, err := TestCoreUnseal(core, TestKeyCopy(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if core.Sealed() {
+		t.Fatal("should not be sealed")
+	}
+}
+
+// testFailingCanaryBackend is a physical backend that fails writing the
+// keyring canary once fail is set
+type testFailingCanaryBackend struct {
+	physical.Backend
+	fail int32
+}
+
+func (b *testFailingCanaryBackend) Put(ctx context.Context, entry *physical.Entry) error {
+	if atomic.LoadInt32(&b.fail) == 1 && entry.Key == coreKeyringCanaryPath {
+		return errors.New("put failed")
+	}
+	return b.Backend.Put(ctx, entry)
+}
+
+func TestCore_SealMigration_RollbackCanary(t *testing.T) {
+	logger := logging.NewVaultLogger(log.Trace)
+	inm, err := inmem.NewInmem(nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := &testFailingCanaryBackend{Backend: inm}
+	conf := testCoreConfig(t, backend, logger)
+	conf.SealFactory = testSealMigrationFactory(nil)
+	core, err := NewCore(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, root := TestCoreInit(t, core)
+	for _, key := range keys {
+		if _, err := TestCoreUnseal(core, TestKeyCopy(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	atomic.StoreInt32(&backend.fail, 1)
+	status := testSealMigrate(t, core, root, keys, map[string]string{"key_id": "new"})
+	if status.State != SealMigrationStateFailed || status.Error == "" {
+		t.Fatalf("bad: %#v", status)
+	}
+	atomic.StoreInt32(&backend.fail, 0)
+
+	// The migration is not recorded, so the next active node keeps the
+	// rolled back seal
+	migration, err := core.getSealMigrationEntry(namespace.RootContext(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migration != nil {
+		t.Fatalf("bad: %#v", migration)
+	}
+
+	if err := core.Seal(root); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if _, err := TestCoreUnseal(core, TestKeyCopy(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if core.Sealed() {
+		t.Fatal("should not be sealed")
+	}
+	if core.seal.BarrierType() != seal.Shamir {
+		t.Fatalf("bad: seal type %q", core.seal.BarrierType())
+	}
+}
+
+func TestCore_SealMigration_Standby(t *testing.T) {
+	cluster := NewTestCluster(t, &CoreConfig{
+		SealFactory: testSealMigrationFactory(nil),
+	}, nil)
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	cores := cluster.Cores
+	root := cluster.RootToken
+	TestWaitActive(t, cores[0].Core)
+
+	status := testSealMigrate(t, cores[0].Core, root, cluster.BarrierKeys, map[string]string{"key_id": "new"})
+	if status.State != SealMigrationStateComplete {
+		t.Fatalf("bad: %#v", status)
+	}
+
+	// The standbys switch to the new seal when they become active
+	err := cores[0].StepDown(context.Background(), &logical.Request{
+		Operation:   logical.UpdateOperation,
+		Path:        "sys/step-down",
+		ClientToken: root,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var active *TestClusterCore
+	for i := 0; i < 30 && active == nil; i++ {
+		time.Sleep(time.Second)
+		for _, core := range cores[1:] {
+			if standby, _ := core.Standby(); !standby {
+				active = core
+			}
+		}
+	}
+	if active == nil {
+		t.Fatal("no standby became active")
+	}
+	if active.seal.BarrierType() != seal.Test {
+		t.Fatalf("bad: seal type %q", active.seal.BarrierType())
+	}
+
+	req := logical.TestRequest(t, logical.ReadOperation, "sys/mounts")
+	req.ClientToken = root
+	resp, err := active.HandleRequest(namespace.RootContext(nil), req)
+	if err != nil || resp.IsError() {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+}
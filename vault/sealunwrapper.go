@@ -8,19 +8,26 @@ import (
 	"sync/atomic"
 
 	proto "github.com/golang/protobuf/proto"
+	"github.com/hashicorp/errwrap"
 	log "github.com/hashicorp/go-hclog"
+	multierror "github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/helper/locksutil"
 	"github.com/hashicorp/vault/physical"
+	"github.com/hashicorp/vault/vault/seal"
 )
 
-// NewSealUnwrapper creates a new seal unwrapper
+// NewSealUnwrapper creates a new seal unwrapper. Besides unwrapping entries
+// left behind by a downgrade, it seal wraps the entries that must be seal
+// wrapped once a seal supporting it is set with setSealWrap.
 func NewSealUnwrapper(underlying physical.Backend, logger log.Logger) physical.Backend {
 	ret := &sealUnwrapper{
 		underlying:   underlying,
 		logger:       logger,
 		locks:        locksutil.CreateLocks(),
 		allowUnwraps: new(uint32),
+		sealWrap:     new(atomic.Value),
 	}
+	ret.sealWrap.Store(&sealWrapConfig{})
 
 	if underTxn, ok := underlying.(physical.Transactional); ok {
 		return &transactionalSealUnwrapper{
@@ -40,6 +47,36 @@ type sealUnwrapper struct {
 	logger       log.Logger
 	locks        []*locksutil.LockEntry
 	allowUnwraps *uint32
+
+	// sealWrap holds the *sealWrapConfig used to seal wrap entries
+	sealWrap *atomic.Value
+}
+
+// sealWrapConfig holds what the seal unwrapper needs to seal wrap entries
+type sealWrapConfig struct {
+	// access is used to seal wrap entries. It is nil when the seal does not
+	// support seal wrapping, such as with Shamir.
+	access seal.Access
+
+	// unwrapAccesses are also tried to unwrap entries, such as the seal being
+	// migrated from. Entries unwrapped by one of them are wrapped again with
+	// access when read by the active node.
+	unwrapAccesses []seal.Access
+
+	// wrapPath returns whether the entry at the given key must be seal
+	// wrapped, given whether the entry itself asks for it
+	wrapPath func(key string, requested bool) bool
+}
+
+// shouldWrap returns whether an entry must be seal wrapped
+func (c *sealWrapConfig) shouldWrap(key string, requested bool) bool {
+	if c.access == nil {
+		return false
+	}
+	if c.wrapPath == nil {
+		return requested
+	}
+	return c.wrapPath(key, requested)
 }
 
 // transactionalSealUnwrapper is a seal unwrapper that wraps a physical that is transactional
@@ -56,41 +93,36 @@ func (d *sealUnwrapper) Put(ctx context.Context, entry *physical.Entry) error {
 	locksutil.LockForKey(d.locks, entry.Key).Lock()
 	defer locksutil.LockForKey(d.locks, entry.Key).Unlock()
 
-	return d.underlying.Put(ctx, entry)
+	wrapped, err := d.wrap(ctx, entry)
+	if err != nil {
+		return err
+	}
+	return d.underlying.Put(ctx, wrapped)
 }
 
 func (d *sealUnwrapper) Get(ctx context.Context, key string) (*physical.Entry, error) {
+	entry, _, err := d.get(ctx, key)
+	return entry, err
+}
+
+// get reads the entry at the given key. On the active node, it also stores
+// the entry again if it is not stored the way it would be stored now, and
+// returns whether it did.
+func (d *sealUnwrapper) get(ctx context.Context, key string) (*physical.Entry, bool, error) {
 	entry, err := d.underlying.Get(ctx, key)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	if entry == nil {
-		return nil, nil
+		return nil, false, nil
 	}
 
-	var performUnwrap bool
-	se := &physical.EncryptedBlobInfo{}
-	// If the value ends in our canary value, try to decode the bytes.
-	eLen := len(entry.Value)
-	if eLen > 0 && entry.Value[eLen-1] == 's' {
-		if err := proto.Unmarshal(entry.Value[:eLen-1], se); err == nil {
-			// We unmarshaled successfully which means we need to store it as a
-			// non-proto message
-			performUnwrap = true
-		}
-	}
-	if !performUnwrap {
-		return entry, nil
-	}
-	// It's actually encrypted and we can't read it
-	if se.Wrapped {
-		return nil, fmt.Errorf("cannot decode sealwrapped storage entry %q", entry.Key)
+	entry, update, err := d.unwrap(ctx, entry)
+	if err != nil {
+		return nil, false, err
 	}
-	if atomic.LoadUint32(d.allowUnwraps) != 1 {
-		return &physical.Entry{
-			Key:   entry.Key,
-			Value: se.Ciphertext,
-		}, nil
+	if !update || atomic.LoadUint32(d.allowUnwraps) != 1 {
+		return entry, false, nil
 	}
 
 	locksutil.LockForKey(d.locks, key).Lock()
@@ -99,41 +131,127 @@ func (d *sealUnwrapper) Get(ctx context.Context, key string) (*physical.Entry, e
 	// At this point we need to re-read and re-check
 	entry, err = d.underlying.Get(ctx, key)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	if entry == nil {
-		return nil, nil
+		return nil, false, nil
 	}
 
-	performUnwrap = false
-	se = &physical.EncryptedBlobInfo{}
-	// If the value ends in our canary value, try to decode the bytes.
-	eLen = len(entry.Value)
+	entry, update, err = d.unwrap(ctx, entry)
+	if err != nil {
+		return nil, false, err
+	}
+	if !update || atomic.LoadUint32(d.allowUnwraps) != 1 {
+		return entry, false, nil
+	}
+
+	// Store the entry the way it would be stored now, which upgrades or
+	// downgrades its seal wrapping
+	wrapped, err := d.wrap(ctx, entry)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := d.underlying.Put(ctx, wrapped); err != nil {
+		return nil, false, err
+	}
+	return entry, true, nil
+}
+
+// wrap returns the entry to store for the given entry, seal wrapped if the
+// entry must be seal wrapped. The given entry is not modified as it may be
+// cached by the layers above.
+func (d *sealUnwrapper) wrap(ctx context.Context, entry *physical.Entry) (*physical.Entry, error) {
+	config := d.sealWrap.Load().(*sealWrapConfig)
+	if !config.shouldWrap(entry.Key, entry.SealWrap) {
+		return entry, nil
+	}
+
+	se, err := config.access.Encrypt(ctx, entry.Value)
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("failed to seal wrap storage entry %q: {{err}}", entry.Key), err)
+	}
+	se.Wrapped = true
+	se.Key = entry.Key
+
+	seb, err := proto.Marshal(se)
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("failed to encode seal wrapped storage entry %q: {{err}}", entry.Key), err)
+	}
+
+	return &physical.Entry{
+		Key: entry.Key,
+		// Append the canary used to detect encoded entries
+		Value:    append(seb, 's'),
+		SealWrap: true,
+	}, nil
+}
+
+// unwrap decodes a stored entry. It also returns whether the stored entry
+// differs from what would be stored now, that is whether it was left behind
+// by a downgrade, or its seal wrapping must be upgraded or downgraded.
+func (d *sealUnwrapper) unwrap(ctx context.Context, entry *physical.Entry) (*physical.Entry, bool, error) {
+	config := d.sealWrap.Load().(*sealWrapConfig)
+
+	se := &physical.EncryptedBlobInfo{}
+	var encoded bool
+	// If the value ends in our canary value, try to decode the bytes. We
+	// ignore an error because the canary is not a guarantee; if it doesn't
+	// decode, proceed normally
+	eLen := len(entry.Value)
 	if eLen > 0 && entry.Value[eLen-1] == 's' {
-		// We ignore an error because the canary is not a guarantee; if it
-		// doesn't decode, proceed normally
 		if err := proto.Unmarshal(entry.Value[:eLen-1], se); err == nil {
-			// We unmarshaled successfully which means we need to store it as a
-			// non-proto message
-			performUnwrap = true
+			encoded = true
 		}
 	}
-	if !performUnwrap {
-		return entry, nil
-	}
-	if se.Wrapped {
-		return nil, fmt.Errorf("cannot decode sealwrapped storage entry %q", entry.Key)
+
+	switch {
+	case !encoded:
+		wrap := config.shouldWrap(entry.Key, entry.SealWrap)
+		return &physical.Entry{
+			Key:      entry.Key,
+			Value:    entry.Value,
+			SealWrap: wrap,
+		}, wrap, nil
+
+	case !se.Wrapped:
+		// We unmarshaled successfully which means we need to store it as a
+		// non-proto message
+		return &physical.Entry{
+			Key:      entry.Key,
+			Value:    se.Ciphertext,
+			SealWrap: config.shouldWrap(entry.Key, false),
+		}, true, nil
 	}
 
-	entry = &physical.Entry{
-		Key:   entry.Key,
-		Value: se.Ciphertext,
+	// It's actually encrypted, try the seals that can unwrap it
+	if se.Key != "" && se.Key != entry.Key {
+		return nil, false, fmt.Errorf("seal wrapped storage entry %q holds the key %q", entry.Key, se.Key)
+	}
+	accesses := config.unwrapAccesses
+	if config.access != nil {
+		accesses = append([]seal.Access{config.access}, accesses...)
+	}
+	if len(accesses) == 0 {
+		return nil, false, fmt.Errorf("cannot decode sealwrapped storage entry %q", entry.Key)
 	}
 
-	if atomic.LoadUint32(d.allowUnwraps) != 1 {
-		return entry, nil
+	var errs *multierror.Error
+	for _, access := range accesses {
+		plaintext, err := access.Decrypt(ctx, se)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+
+		wrap := config.shouldWrap(entry.Key, true)
+		return &physical.Entry{
+			Key:      entry.Key,
+			Value:    plaintext,
+			SealWrap: wrap,
+		}, !wrap || access != config.access, nil
 	}
-	return entry, d.underlying.Put(ctx, entry)
+
+	return nil, false, errwrap.Wrapf(fmt.Sprintf("failed to unwrap sealwrapped storage entry %q: {{err}}", entry.Key), errs.ErrorOrNil())
 }
 
 func (d *sealUnwrapper) Delete(ctx context.Context, key string) error {
@@ -159,7 +277,24 @@ func (d *transactionalSealUnwrapper) Transaction(ctx context.Context, txns []*ph
 		defer l.Unlock()
 	}
 
-	if err := d.Transactional.Transaction(ctx, txns); err != nil {
+	// Seal wrap the entries being written
+	wrappedTxns := make([]*physical.TxnEntry, 0, len(txns))
+	for _, curr := range txns {
+		if curr.Operation != physical.PutOperation {
+			wrappedTxns = append(wrappedTxns, curr)
+			continue
+		}
+		wrapped, err := d.wrap(ctx, curr.Entry)
+		if err != nil {
+			return err
+		}
+		wrappedTxns = append(wrappedTxns, &physical.TxnEntry{
+			Operation: curr.Operation,
+			Entry:     wrapped,
+		})
+	}
+
+	if err := d.Transactional.Transaction(ctx, wrappedTxns); err != nil {
 		return err
 	}
 
@@ -178,3 +313,8 @@ func (d *sealUnwrapper) runUnwraps() {
 	// primary
 	atomic.StoreUint32(d.allowUnwraps, 1)
 }
+
+// setSealWrap sets the configuration used to seal wrap entries
+func (d *sealUnwrapper) setSealWrap(config *sealWrapConfig) {
+	d.sealWrap.Store(config)
+}
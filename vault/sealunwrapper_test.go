@@ -10,8 +10,12 @@ import (
 
 	proto "github.com/golang/protobuf/proto"
 	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/helper/logging"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/physical"
 	"github.com/hashicorp/vault/physical/inmem"
+	"github.com/hashicorp/vault/vault/seal"
 )
 
 func TestSealUnwrapper(t *testing.T) {
@@ -105,3 +109,189 @@ func performTestSealUnwrapper(t *testing.T, phys physical.Backend, logger log.Lo
 	checkValue(cluster.Cores[1].Core, true)
 	checkValue(cluster.Cores[0].Core, false)
 }
+
+func TestSealUnwrapper_SealWrap(t *testing.T) {
+	ctx := context.Background()
+	logger := log.New(&log.LoggerOptions{
+		Mutex: &sync.Mutex{},
+	})
+
+	phys, err := inmem.NewInmem(nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unwrapper := NewSealUnwrapper(phys, logger).(*sealUnwrapper)
+	unwrapper.runUnwraps()
+
+	access := seal.NewTestSeal(logger)
+	disabled := false
+	unwrapper.setSealWrap(&sealWrapConfig{
+		access: access,
+		wrapPath: func(key string, requested bool) bool {
+			return !disabled && (requested || key == "wrapped")
+		},
+	})
+
+	checkStored := func(key string, wrapped bool) {
+		t.Helper()
+		entry, err := unwrapper.Get(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(entry.Value, []byte("value")) {
+			t.Fatalf("bad: %q", entry.Value)
+		}
+		if entry.SealWrap != wrapped {
+			t.Fatalf("bad: seal wrap %t", entry.SealWrap)
+		}
+
+		underlying, err := phys.Get(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !wrapped {
+			if !bytes.Equal(underlying.Value, []byte("value")) {
+				t.Fatalf("bad: %q", underlying.Value)
+			}
+			return
+		}
+		eLen := len(underlying.Value)
+		if eLen == 0 || underlying.Value[eLen-1] != 's' {
+			t.Fatalf("expected seal wrapped entry, got %q", underlying.Value)
+		}
+		se := &physical.EncryptedBlobInfo{}
+		if err := proto.Unmarshal(underlying.Value[:eLen-1], se); err != nil {
+			t.Fatal(err)
+		}
+		if !se.Wrapped || se.Key != key || bytes.Equal(se.Ciphertext, []byte("value")) {
+			t.Fatalf("bad: %#v", se)
+		}
+	}
+
+	// Entries are seal wrapped by path or when they ask for it
+	for key, requested := range map[string]bool{"plain": false, "wrapped": false, "requested": true} {
+		if err := unwrapper.Put(ctx, &physical.Entry{Key: key, Value: []byte("value"), SealWrap: requested}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkStored("plain", false)
+	checkStored("wrapped", true)
+	checkStored("requested", true)
+
+	// Entries written before seal wrapping are upgraded on read, but only
+	// by the active node
+	if err := phys.Put(ctx, &physical.Entry{Key: "wrapped", Value: []byte("value")}); err != nil {
+		t.Fatal(err)
+	}
+	unwrapper.stopUnwraps()
+	entry, err := unwrapper.Get(ctx, "wrapped")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(entry.Value, []byte("value")) {
+		t.Fatalf("bad: %q", entry.Value)
+	}
+	underlying, err := phys.Get(ctx, "wrapped")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(underlying.Value, []byte("value")) {
+		t.Fatalf("expected entry not to be upgraded by a standby, got %q", underlying.Value)
+	}
+	unwrapper.runUnwraps()
+	checkStored("wrapped", true)
+
+	// Disabling seal wrapping downgrades entries on read
+	disabled = true
+	checkStored("wrapped", false)
+	disabled = false
+	checkStored("wrapped", true)
+
+	// Entries wrapped by the seal being migrated from are unwrapped when the
+	// new seal does not support seal wrapping
+	unwrapper.setSealWrap(&sealWrapConfig{
+		unwrapAccesses: []seal.Access{access},
+	})
+	checkStored("wrapped", false)
+
+	// Without a seal supporting seal wrapping, seal wrapped entries cannot
+	// be read
+	unwrapper.setSealWrap(&sealWrapConfig{
+		access: access,
+	})
+	checkStored("requested", true)
+	unwrapper.setSealWrap(&sealWrapConfig{})
+	if _, err := unwrapper.Get(ctx, "requested"); err == nil {
+		t.Fatal("expected error reading seal wrapped entry")
+	}
+}
+
+func TestSealUnwrapper_SealWrapCore(t *testing.T) {
+	ctx := namespace.RootContext(nil)
+	logger := logging.NewVaultLogger(log.Trace)
+
+	for _, disableSealWrap := range []bool{false, true} {
+		core := TestCoreWithConfig(t, &CoreConfig{
+			Seal:            NewAutoSeal(seal.NewTestSeal(logger)),
+			DisableSealWrap: disableSealWrap,
+		})
+		_, root := TestCoreInit(t, core)
+		if err := core.UnsealWithStoredKeys(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if core.Sealed() {
+			t.Fatal("should not be sealed")
+		}
+
+		// The passthrough backend seal wraps all of its storage
+		req := logical.TestRequest(t, logical.UpdateOperation, "secret/foo")
+		req.Data["foo"] = "bar"
+		req.ClientToken = root
+		if _, err := core.HandleRequest(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+		me := core.router.MatchingMountEntry(ctx, "secret/")
+		if me == nil {
+			t.Fatal("secret mount not found")
+		}
+
+		isWrapped := func(key string) bool {
+			t.Helper()
+			entry, err := core.underlyingPhysical.Get(ctx, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry == nil {
+				t.Fatalf("entry %q not found", key)
+			}
+			eLen := len(entry.Value)
+			if eLen == 0 || entry.Value[eLen-1] != 's' {
+				return false
+			}
+			se := &physical.EncryptedBlobInfo{}
+			return proto.Unmarshal(entry.Value[:eLen-1], se) == nil && se.Wrapped
+		}
+
+		// The keyring is always seal wrapped
+		if !isWrapped(keyringPath) {
+			t.Fatal("expected keyring to be seal wrapped")
+		}
+		if isWrapped(coreMountConfigPath) {
+			t.Fatal("expected mount table not to be seal wrapped")
+		}
+		if isWrapped(backendBarrierPrefix+me.UUID+"/foo") == disableSealWrap {
+			t.Fatalf("bad: secret seal wrapped %t, seal wrapping disabled %t", disableSealWrap, disableSealWrap)
+		}
+
+		// The secret can still be read through the barrier
+		req = logical.TestRequest(t, logical.ReadOperation, "secret/foo")
+		req.ClientToken = root
+		resp, err := core.HandleRequest(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp == nil || resp.Data["foo"] != "bar" {
+			t.Fatalf("bad: %#v", resp)
+		}
+	}
+}
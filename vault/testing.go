@@ -149,6 +149,8 @@ func TestCoreWithSealAndUI(t testing.T, opts *CoreConfig) *Core {
 	conf.Seal = opts.Seal
 	conf.LicensingConfig = opts.LicensingConfig
 	conf.DisableKeyEncodingChecks = opts.DisableKeyEncodingChecks
+	conf.DisableSealWrap = opts.DisableSealWrap
+	conf.SealFactory = opts.SealFactory
 
 	for k, v := range opts.LogicalBackends {
 		conf.LogicalBackends[k] = v
@@ -782,6 +784,20 @@ func (c *TestCluster) UnsealCoresWithError() error {
 	return nil
 }
 
+// UnsealCore unseals a single core using the cluster's barrier keys, falling
+// back to the stored keys if the seal supports them.
+func (c *TestCluster) UnsealCore(t testing.T, core *TestClusterCore) {
+	for _, key := range c.BarrierKeys {
+		if _, err := core.Core.Unseal(TestKeyCopy(key)); err != nil {
+			t.Fatalf("unseal err: %s", err)
+		}
+	}
+
+	if err := core.UnsealWithStoredKeys(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func (c *TestCluster) EnsureCoresSealed(t testing.T) {
 	t.Helper()
 	if err := c.ensureCoresSealed(); err != nil {
@@ -926,6 +942,11 @@ type TestClusterOptions struct {
 	TempDir            string
 	CACert             []byte
 	CAKey              *ecdsa.PrivateKey
+
+	// PhysicalFactory, if set, is called to create a separate physical
+	// backend for each core instead of sharing a single one. If the returned
+	// backend supports HA it is also used as the core's HA backend.
+	PhysicalFactory func(logger log.Logger) (physical.Backend, error)
 }
 
 var DefaultNumCores = 3
@@ -1206,6 +1227,7 @@ func NewTestCluster(t testing.T, base *CoreConfig, opts *TestClusterOptions) *Te
 		coreConfig.DevToken = base.DevToken
 		coreConfig.EnableRaw = base.EnableRaw
 		coreConfig.DisableSealWrap = base.DisableSealWrap
+		coreConfig.SealFactory = base.SealFactory
 		coreConfig.DevLicenseDuration = base.DevLicenseDuration
 		coreConfig.DisableCache = base.DisableCache
 		if base.BuiltinRegistry != nil {
@@ -1296,6 +1318,17 @@ func NewTestCluster(t testing.T, base *CoreConfig, opts *TestClusterOptions) *Te
 			localConfig.Logger = opts.Logger.Named(fmt.Sprintf("core%d", i))
 		}
 
+		if opts != nil && opts.PhysicalFactory != nil {
+			physLogger := logger.Named(fmt.Sprintf("storage.core%d", i))
+			localConfig.Physical, err = opts.PhysicalFactory(physLogger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if haBackend, ok := localConfig.Physical.(physical.HABackend); ok {
+				localConfig.HAPhysical = haBackend
+			}
+		}
+
 		localConfig.LicensingConfig = testGetLicensingConfig(pubKey)
 
 		c, err := NewCore(&localConfig)